@@ -0,0 +1,61 @@
+package fuse_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A server with its own ReadOp loop, as written before aborts were reported,
+// that records the error ending the loop.
+type readLoopServer struct {
+	readErr chan error
+}
+
+func (s *readLoopServer) ServeOps(c *fuse.Connection) {
+	for {
+		ctx, op, err := c.ReadOp()
+		if err != nil {
+			s.readErr <- err
+			return
+		}
+
+		if op, ok := op.(*fuseops.GetInodeAttributesOp); ok {
+			op.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0755 | os.ModeDir}
+			c.Reply(ctx, nil)
+			continue
+		}
+
+		c.Reply(ctx, fuse.ENOSYS)
+	}
+}
+
+func TestAbortEndsReadOpWithEOF(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Cleanup(func() { fuse.Unmount(dir) })
+
+	s := &readLoopServer{readErr: make(chan error, 1)}
+	mfs, err := fuse.Mount(dir, s, &fuse.MountConfig{})
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	abort(t, dir)
+
+	if err := <-s.readErr; err != io.EOF {
+		t.Errorf("ReadOp: got %v, want EOF", err)
+	}
+
+	if err := mfs.Join(ctx); err != nil {
+		t.Errorf("Join: %v", err)
+	}
+
+	if cause, err := mfs.Cause(); cause != fuse.ExitAborted || err != nil {
+		t.Errorf("Cause: got %v, %v, want %v, nil", cause, err, fuse.ExitAborted)
+	}
+}
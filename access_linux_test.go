@@ -0,0 +1,121 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"golang.org/x/sys/unix"
+)
+
+// A file system with a single file, "foo", which may be read but not written,
+// according to its Access method.
+type accessFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	mu    sync.Mutex
+	masks []uint32
+}
+
+func (fs *accessFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0777 | os.ModeDir}
+	}
+
+	return fuseops.InodeAttributes{Nlink: 1, Mode: 0666}
+}
+
+func (fs *accessFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *accessFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "foo" {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	return nil
+}
+
+func (fs *accessFS) Access(
+	ctx context.Context,
+	op *fuseops.AccessOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Inode != fuseops.RootInodeID {
+		fs.masks = append(fs.masks, op.Mask)
+	}
+
+	if op.Mask&unix.W_OK != 0 {
+		return syscall.EACCES
+	}
+
+	return nil
+}
+
+func TestAccess(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fs := &accessFS{}
+	mfs, err := fuse.Mount(
+		dir,
+		fuseutil.NewFileSystemServer(fs),
+		&fuse.MountConfig{DisableDefaultPermissions: true})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	defer func() {
+		if err := mfs.Join(ctx); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	}()
+
+	defer fuse.Unmount(mfs.Dir())
+
+	// The file system's verdicts are reported by access(2), in spite of the
+	// file's mode.
+	p := path.Join(dir, "foo")
+	if err := unix.Access(p, unix.R_OK); err != nil {
+		t.Errorf("Access(R_OK): %v", err)
+	}
+
+	if err := unix.Access(p, unix.R_OK|unix.W_OK); err != syscall.EACCES {
+		t.Errorf("Access(R_OK|W_OK): got %v, want EACCES", err)
+	}
+
+	if err := unix.Access(p, unix.F_OK); err != nil {
+		t.Errorf("Access(F_OK): %v", err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	want := []uint32{unix.R_OK, unix.R_OK | unix.W_OK, unix.F_OK}
+	if len(fs.masks) != len(want) {
+		t.Fatalf("Got masks %v, want %v", fs.masks, want)
+	}
+
+	for i := range want {
+		if fs.masks[i] != want[i] {
+			t.Errorf("Got masks %v, want %v", fs.masks, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system containing a file "log", whose attributes the kernel may
+// cache for an hour. Mounting it twice stands in for two clients of a network
+// backend.
+type logFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	mu       sync.Mutex
+	contents []byte
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *logFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0777 | os.ModeDir}
+	}
+
+	return fuseops.InodeAttributes{
+		Nlink: 1,
+		Mode:  0666,
+		Size:  uint64(len(fs.contents)),
+	}
+}
+
+func (fs *logFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.Attributes = fs.attributes(op.Inode)
+	op.AttributesExpiration = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (fs *logFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "log" {
+		return fuse.ENOENT
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	op.Entry.AttributesExpiration = time.Now().Add(time.Hour)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	return nil
+}
+
+func (fs *logFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	op.KeepPageCache = true
+	return nil
+}
+
+func (fs *logFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	end := int(op.Offset) + len(op.Data)
+	if end > len(fs.contents) {
+		fs.contents = append(fs.contents, make([]byte, end-len(fs.contents))...)
+	}
+
+	copy(fs.contents[op.Offset:], op.Data)
+	return nil
+}
+
+func (fs *logFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	return nil
+}
+
+func (fs *logFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
+
+// Mount the file system twice, and append to its log through each mount in
+// turn, returning the log's contents.
+func appendTwice(t *testing.T, wrap bool) string {
+	fs := &logFS{}
+	var files []*os.File
+	for range 2 {
+		var server fuseutil.FileSystem = fs
+		if wrap {
+			server = fuseutil.NewAppendFileSystem(fs, fuseutil.AppendConfig{})
+		}
+
+		mfs, err := fuse.Mount(
+			t.TempDir(),
+			fuseutil.NewFileSystemServer(server),
+			&fuse.MountConfig{DisableWritebackCaching: true})
+
+		if err != nil {
+			t.Fatalf("fuse.Mount: %v", err)
+		}
+
+		f, err := os.OpenFile(path.Join(mfs.Dir(), "log"), os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+
+		t.Cleanup(func() {
+			f.Close()
+			if err := fuse.Unmount(mfs.Dir()); err != nil {
+				t.Errorf("Unmount: %v", err)
+			}
+
+			if err := mfs.Join(context.Background()); err != nil {
+				t.Errorf("Joining: %v", err)
+			}
+		})
+
+		files = append(files, f)
+	}
+
+	for _, s := range []string{"taco\n", "burrito\n", "enchilada\n"} {
+		for _, f := range files {
+			if _, err := f.WriteString(s); err != nil {
+				t.Fatalf("WriteString: %v", err)
+			}
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return string(fs.contents)
+}
+
+func TestAppendFileSystem(t *testing.T) {
+	const want = "taco\ntaco\nburrito\nburrito\nenchilada\nenchilada\n"
+
+	// Each kernel appends at the end of the file as it last saw it.
+	if got := appendTwice(t, false); got == want {
+		t.Errorf("Appends without AppendFileSystem weren't clobbered")
+	}
+
+	if got := appendTwice(t, true); got != want {
+		t.Errorf("Contents: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The memory for a single request when MountConfig.PoolOps is set: the op's
+// context and state, and storage for the op itself if it is of one of the
+// common types. Arenas are kept on a freelist and reused once the op has been
+// replied to, so that serving these ops needn't allocate.
+type opArena struct {
+	ctx   opContext
+	state opState
+
+	// ctx.cancel, saved to avoid allocating a method value per request.
+	cancel func()
+
+	lookUp      fuseops.LookUpInodeOp
+	getAttr     fuseops.GetInodeAttributesOp
+	setAttr     fuseops.SetInodeAttributesOp
+	forget      fuseops.ForgetInodeOp
+	openFile    fuseops.OpenFileOp
+	openDir     fuseops.OpenDirOp
+	readFile    fuseops.ReadFileOp
+	writeFile   fuseops.WriteFileOp
+	readDir     fuseops.ReadDirOp
+	readDirPlus fuseops.ReadDirPlusOp
+	flushFile   fuseops.FlushFileOp
+	releaseFile fuseops.ReleaseFileHandleOp
+	releaseDir  fuseops.ReleaseDirHandleOp
+	readSymlink fuseops.ReadSymlinkOp
+	getXattr    fuseops.GetXattrOp
+	listXattr   fuseops.ListXattrOp
+	statFS      fuseops.StatFSOp
+}
+
+func newOpArena() *opArena {
+	a := new(opArena)
+	a.cancel = a.ctx.cancel
+	return a
+}
+
+// Prepare the arena for reuse, dropping references to the previous request's
+// data.
+func (a *opArena) reset() {
+	cancel := a.cancel
+	*a = opArena{}
+	a.cancel = cancel
+}
+
+// Return a pointer to a copy of the supplied op, stored in the arena if it is
+// non-nil and has room for ops of this type, and allocated otherwise.
+func place[T any](a *opArena, v T) *T {
+	var p *T
+	if a != nil {
+		switch any(p).(type) {
+		case *fuseops.LookUpInodeOp:
+			p = any(&a.lookUp).(*T)
+		case *fuseops.GetInodeAttributesOp:
+			p = any(&a.getAttr).(*T)
+		case *fuseops.SetInodeAttributesOp:
+			p = any(&a.setAttr).(*T)
+		case *fuseops.ForgetInodeOp:
+			p = any(&a.forget).(*T)
+		case *fuseops.OpenFileOp:
+			p = any(&a.openFile).(*T)
+		case *fuseops.OpenDirOp:
+			p = any(&a.openDir).(*T)
+		case *fuseops.ReadFileOp:
+			p = any(&a.readFile).(*T)
+		case *fuseops.WriteFileOp:
+			p = any(&a.writeFile).(*T)
+		case *fuseops.ReadDirOp:
+			p = any(&a.readDir).(*T)
+		case *fuseops.ReadDirPlusOp:
+			p = any(&a.readDirPlus).(*T)
+		case *fuseops.FlushFileOp:
+			p = any(&a.flushFile).(*T)
+		case *fuseops.ReleaseFileHandleOp:
+			p = any(&a.releaseFile).(*T)
+		case *fuseops.ReleaseDirHandleOp:
+			p = any(&a.releaseDir).(*T)
+		case *fuseops.ReadSymlinkOp:
+			p = any(&a.readSymlink).(*T)
+		case *fuseops.GetXattrOp:
+			p = any(&a.getXattr).(*T)
+		case *fuseops.ListXattrOp:
+			p = any(&a.listXattr).(*T)
+		case *fuseops.StatFSOp:
+			p = any(&a.statFS).(*T)
+		}
+	}
+
+	if p == nil {
+		p = new(T)
+	}
+
+	*p = v
+	return p
+}
+
+// The context for an op whose memory comes from an arena. It is the
+// equivalent of the context.WithCancel and context.WithValue contexts used
+// otherwise, but needn't be allocated for each request.
+//
+// Its parent must never be cancelled, i.e. must have a nil Done channel.
+type opContext struct {
+	parent context.Context
+	state  *opState
+
+	mu sync.Mutex
+
+	// Created lazily, as by context.WithCancel.
+	//
+	// GUARDED_BY(mu)
+	done chan struct{}
+
+	// GUARDED_BY(mu)
+	err        error
+	afterFuncs []*func()
+}
+
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+func (c *opContext) Deadline() (time.Time, bool) {
+	return c.parent.Deadline()
+}
+
+// LOCKS_EXCLUDED(c.mu)
+func (c *opContext) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done == nil {
+		if c.err != nil {
+			return closedChan
+		}
+
+		c.done = make(chan struct{})
+	}
+
+	return c.done
+}
+
+// LOCKS_EXCLUDED(c.mu)
+func (c *opContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *opContext) Value(key interface{}) interface{} {
+	if key == contextKey {
+		return c.state
+	}
+
+	return c.parent.Value(key)
+}
+
+// AfterFunc arranges for f to be called once the context is cancelled. It
+// allows contexts derived from this one with the context package to be
+// cancelled along with it without starting a goroutine each.
+//
+// Unlike context.AfterFunc, f is called synchronously by cancel, so that the
+// derived contexts are done with this one before the arena is reused.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *opContext) AfterFunc(f func()) (stop func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		go f()
+		return func() bool { return false }
+	}
+
+	p := &f
+	c.afterFuncs = append(c.afterFuncs, p)
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, q := range c.afterFuncs {
+			if q == p {
+				c.afterFuncs = append(c.afterFuncs[:i], c.afterFuncs[i+1:]...)
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// LOCKS_EXCLUDED(c.mu)
+func (c *opContext) cancel() {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+
+	c.err = context.Canceled
+	if c.done != nil {
+		close(c.done)
+	}
+
+	afterFuncs := c.afterFuncs
+	c.afterFuncs = nil
+	c.mu.Unlock()
+
+	// The functions call Err, so must be called without the lock held.
+	for _, f := range afterFuncs {
+		(*f)()
+	}
+}
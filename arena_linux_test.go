@@ -0,0 +1,145 @@
+package fuse
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// Return a connection reading requests from a socket, which preserves message
+// boundaries as /dev/fuse does, and the other end of the socket.
+func newSocketConnection(tb testing.TB, poolOps bool) (*Connection, *os.File) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET, 0)
+	if err != nil {
+		tb.Fatalf("Socketpair: %v", err)
+	}
+
+	dev := os.NewFile(uintptr(fds[0]), "dev")
+	peer := os.NewFile(uintptr(fds[1]), "peer")
+	tb.Cleanup(func() {
+		dev.Close()
+		peer.Close()
+	})
+
+	c := &Connection{
+		cfg: MountConfig{
+			OpContext: context.Background(),
+			PoolOps:   poolOps,
+		},
+		dev:         dev,
+		cancelFuncs: make(map[uint64]func()),
+		protocol:    fusekernel.Protocol{Major: 7, Minor: 31},
+	}
+
+	return c, peer
+}
+
+// Return a request with the supplied opcode and payload.
+func request(unique uint64, opCode uint32, payload []byte) []byte {
+	h := fusekernel.InHeader{
+		Len:    uint32(fusekernel.InHeaderSize + len(payload)),
+		Opcode: opCode,
+		Unique: unique,
+		Nodeid: fuseops.RootInodeID,
+	}
+
+	b := (*[fusekernel.InHeaderSize]byte)(unsafe.Pointer(&h))[:]
+	return append(b, payload...)
+}
+
+// Send a LOOKUP request and read it from the connection, returning the op
+// and its context.
+func lookUp(tb testing.TB, c *Connection, peer *os.File, unique uint64) (*fuseops.LookUpInodeOp, context.Context) {
+	if _, err := peer.Write(request(unique, fusekernel.OpLookup, []byte("taco\x00"))); err != nil {
+		tb.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		tb.Fatalf("ReadOp: %v", err)
+	}
+
+	lookUpOp, ok := op.(*fuseops.LookUpInodeOp)
+	if !ok || lookUpOp.Name != "taco" {
+		tb.Fatalf("unexpected op: %#v", op)
+	}
+
+	return lookUpOp, ctx
+}
+
+func readReply(tb testing.TB, peer *os.File, unique uint64) {
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	if err != nil || n != int(unsafe.Sizeof(fusekernel.OutHeader{})) {
+		tb.Fatalf("Read: %d, %v", n, err)
+	}
+
+	if u := binary.NativeEndian.Uint64(buf[8:]); u != unique {
+		tb.Fatalf("reply to %d, want %d", u, unique)
+	}
+}
+
+func TestPoolOps(t *testing.T) {
+	c, peer := newSocketConnection(t, true)
+
+	op1, ctx := lookUp(t, c, peer, 1)
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := c.Reply(ctx, ENOENT); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+
+	readReply(t, peer, 1)
+
+	// Contexts derived from the op's context are cancelled by the reply, as
+	// usual.
+	<-child.Done()
+
+	// The next op reuses the first's memory.
+	op2, ctx := lookUp(t, c, peer, 2)
+	if op2 != op1 || op2.OpContext.FuseID != 2 {
+		t.Errorf("op not reused: %p, %p", op1, op2)
+	}
+
+	if ctx.Err() != nil {
+		t.Errorf("reused context already cancelled: %v", ctx.Err())
+	}
+
+	c.Reply(ctx, ENOENT)
+	readReply(t, peer, 2)
+}
+
+func benchmarkLookUp(b *testing.B, poolOps bool) {
+	c, peer := newSocketConnection(b, poolOps)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		unique := uint64(i + 1)
+		_, ctx := lookUp(b, c, peer, unique)
+		c.Reply(ctx, ENOENT)
+		readReply(b, peer, unique)
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(float64(after.NumGC-before.NumGC), "gcs")
+}
+
+func BenchmarkLookUp(b *testing.B) {
+	b.Run("Allocated", func(b *testing.B) { benchmarkLookUp(b, false) })
+	b.Run("Pooled", func(b *testing.B) { benchmarkLookUp(b, true) })
+}
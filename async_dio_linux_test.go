@@ -0,0 +1,200 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// The size of the file served by dioFS, several times the largest request.
+const dioFileSize = 8 << 20
+
+// A file system with a single file, "foo", of zeroes, whose reads and writes
+// take a while, as with a backend on the other side of a network.
+type dioFS struct {
+	fuseutil.NotImplementedFileSystem
+	latency time.Duration
+
+	mu sync.Mutex
+
+	// The number of reads and writes in flight, and the most there have been
+	// at once.
+	//
+	// GUARDED_BY(mu)
+	inFlight    int
+	maxInFlight int
+
+	// Whether any read was classified as readahead.
+	//
+	// GUARDED_BY(mu)
+	readahead bool
+}
+
+func (fs *dioFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0777 | os.ModeDir}
+	}
+
+	return fuseops.InodeAttributes{Nlink: 1, Mode: 0666, Size: dioFileSize}
+}
+
+func (fs *dioFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *dioFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "foo" {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	return nil
+}
+
+func (fs *dioFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+// Record an op in flight until the returned function is called.
+func (fs *dioFS) track() func() {
+	fs.mu.Lock()
+	fs.inFlight++
+	fs.maxInFlight = max(fs.maxInFlight, fs.inFlight)
+	fs.mu.Unlock()
+
+	time.Sleep(fs.latency)
+
+	return func() {
+		fs.mu.Lock()
+		fs.inFlight--
+		fs.mu.Unlock()
+	}
+}
+
+func (fs *dioFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	defer fs.track()()
+
+	fs.mu.Lock()
+	fs.readahead = fs.readahead || op.Readahead
+	fs.mu.Unlock()
+
+	n := min(int64(len(op.Dst)), op.Size, dioFileSize-op.Offset)
+	clear(op.Dst[:n])
+	op.BytesRead = int(n)
+	return nil
+}
+
+func (fs *dioFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	defer fs.track()()
+	return nil
+}
+
+// Mount a dioFS, returning the path of its file.
+func mountDIO(t testing.TB, fs *dioFS, cfg *fuse.MountConfig) string {
+	mfs, err := fuse.Mount(t.TempDir(), fuseutil.NewFileSystemServer(fs), cfg)
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	return path.Join(mfs.Dir(), "foo")
+}
+
+func TestAsyncDIO(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		fs := &dioFS{latency: 10 * time.Millisecond}
+		p := mountDIO(t, fs, &fuse.MountConfig{
+			EnableAsyncDIO:    enabled,
+			ClassifyReadahead: true,
+		})
+
+		f, err := os.OpenFile(p, os.O_RDWR|syscall.O_DIRECT, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+
+		buf := make([]byte, dioFileSize)
+		if n, err := f.ReadAt(buf, 0); n != len(buf) || err != nil {
+			t.Errorf("ReadAt: got (%d, %v)", n, err)
+		}
+
+		if n, err := f.WriteAt(buf, 0); n != len(buf) || err != nil {
+			t.Errorf("WriteAt: got (%d, %v)", n, err)
+		}
+
+		f.Close()
+
+		fs.mu.Lock()
+		overlapped := fs.maxInFlight > 1
+		readahead := fs.readahead
+		fs.mu.Unlock()
+
+		if overlapped != enabled {
+			t.Errorf("EnableAsyncDIO %v: requests overlapped: %v", enabled, overlapped)
+		}
+
+		// The pieces of a read aren't readahead, though they look like it.
+		if readahead {
+			t.Errorf("EnableAsyncDIO %v: read classified as readahead", enabled)
+		}
+	}
+}
+
+func BenchmarkDirectRead(b *testing.B) {
+	for _, enabled := range []bool{false, true} {
+		name := "Sync"
+		if enabled {
+			name = "Async"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			fs := &dioFS{latency: time.Millisecond}
+			p := mountDIO(b, fs, &fuse.MountConfig{EnableAsyncDIO: enabled})
+
+			f, err := os.OpenFile(p, os.O_RDONLY|syscall.O_DIRECT, 0)
+			if err != nil {
+				b.Fatalf("OpenFile: %v", err)
+			}
+			defer f.Close()
+
+			buf := make([]byte, dioFileSize)
+			b.SetBytes(dioFileSize)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := f.ReadAt(buf, 0); err != nil {
+					b.Fatalf("ReadAt: %v", err)
+				}
+			}
+		})
+	}
+}
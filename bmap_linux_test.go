@@ -0,0 +1,138 @@
+package fuse_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"golang.org/x/sys/unix"
+)
+
+// The FIBMAP ioctl, from <linux/fs.h>, which x/sys/unix doesn't define.
+const fibmap = 1
+
+// A file system with a single file, "foo", whose block N is stored in block
+// N+100 of the device.
+type bmapFS struct {
+	accessFS
+}
+
+func (fs *bmapFS) Bmap(
+	ctx context.Context,
+	op *fuseops.BmapOp) error {
+	if op.BlockSize != 4096 {
+		return fmt.Errorf("unexpected block size %d", op.BlockSize)
+	}
+
+	op.DeviceBlock = op.Block + 100
+	return nil
+}
+
+// Attach a loop device to a new sparse file, returning its path.
+func setUpLoopDevice(t *testing.T) string {
+	backing, err := os.Create(path.Join(t.TempDir(), "backing"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer backing.Close()
+
+	if err := backing.Truncate(1 << 20); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	ctl, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("No loop devices: %v", err)
+	}
+	defer ctl.Close()
+
+	n, err := unix.IoctlRetInt(int(ctl.Fd()), unix.LOOP_CTL_GET_FREE)
+	if err != nil {
+		t.Skipf("LOOP_CTL_GET_FREE: %v", err)
+	}
+
+	devPath := fmt.Sprintf("/dev/loop%d", n)
+	dev, err := os.OpenFile(devPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("Opening loop device: %v", err)
+	}
+	defer dev.Close()
+
+	err = unix.IoctlSetInt(int(dev.Fd()), unix.LOOP_SET_FD, int(backing.Fd()))
+	if err != nil {
+		t.Skipf("LOOP_SET_FD: %v", err)
+	}
+
+	t.Cleanup(func() {
+		dev, err := os.OpenFile(devPath, os.O_RDWR, 0)
+		if err != nil {
+			t.Errorf("Opening loop device: %v", err)
+			return
+		}
+		defer dev.Close()
+
+		if err := unix.IoctlSetInt(int(dev.Fd()), unix.LOOP_CLR_FD, 0); err != nil {
+			t.Errorf("LOOP_CLR_FD: %v", err)
+		}
+	})
+
+	return devPath
+}
+
+func TestBmap(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Mounting on a block device requires root")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	dev := setUpLoopDevice(t)
+
+	mfs, err := fuse.Mount(
+		dir,
+		fuseutil.NewFileSystemServer(&bmapFS{}),
+		&fuse.MountConfig{
+			BlockDevice: dev,
+			Options:     map[string]string{"blksize": "4096"},
+		})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	defer func() {
+		if err := mfs.Join(ctx); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	}()
+
+	defer fuse.Unmount(mfs.Dir())
+
+	f, err := os.Open(path.Join(dir, "foo"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	// FIBMAP maps the block index in place.
+	block := int32(3)
+	_, _, errno := unix.Syscall(
+		unix.SYS_IOCTL,
+		f.Fd(),
+		fibmap,
+		uintptr(unsafe.Pointer(&block)))
+
+	if errno != 0 {
+		t.Fatalf("FIBMAP: %v", errno)
+	}
+
+	if block != 103 {
+		t.Errorf("FIBMAP: got block %d, want 103", block)
+	}
+}
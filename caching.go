@@ -0,0 +1,136 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import "errors"
+
+// CachingPolicy groups the settings that control what the Linux kernel
+// caches for a file system, and so which changes the file system must tell
+// it about. Rather than setting each field, most file systems should start
+// from the preset matching where their data comes from (NetworkFSCaching,
+// LocalFSCaching or SyntheticFSCaching) and adjust it. See
+// MountConfig.Caching.
+//
+// Whatever the policy, the kernel also caches entries and attributes for as
+// long as the expirations returned by the file system allow, and a
+// fuse.Notifier can be used to invalidate what it has cached early.
+type CachingPolicy struct {
+	// Have the kernel cache writes in its page cache and send them later,
+	// possibly coalesced, rather than sending each write(2) as it is made.
+	// The kernel then trusts its own mtime, ctime and size over those returned
+	// by the file system, so this is only suitable when nothing else changes
+	// files. See MountConfig.DisableWritebackCaching for the details.
+	WritebackCache bool
+
+	// Have the kernel drop the cached pages of a file whenever it sees the
+	// file's size or mtime change in attributes returned by the file system,
+	// so that changes made elsewhere become visible once the attributes
+	// expire. Without this, only a change of size truncates the cache, and
+	// cached pages are otherwise dropped when the file is opened, unless
+	// OpenFileOp.KeepPageCache is set.
+	AutoInvalData bool
+
+	// Have the kernel never drop cached pages because of attributes returned
+	// by the file system, not even when the size changes, leaving it to the
+	// file system to invalidate them with Notifier.InvalidateInode when the
+	// data changes (Linux >= 5.2). May not be combined with AutoInvalData.
+	ExplicitInvalData bool
+
+	// Have the kernel cache symlink targets in its page cache (Linux >= 4.20).
+	// The size in a symlink's attributes must then be the length of its
+	// target, which is truncated to that size. A target changed other than by
+	// the kernel is read again only once the file system invalidates the
+	// inode with Notifier.InvalidateInode.
+	SymlinkCache bool
+
+	// Have the kernel cache directory listings, by defaulting
+	// OpenDirOp.CacheDir and OpenDirOp.KeepCache to true. The file system may
+	// clear them in OpenDir for directories whose listings shouldn't be
+	// cached. The kernel drops the listing when it changes the directory
+	// itself, e.g. by creating a file in it, and otherwise only when the
+	// directory's mtime changes or it is invalidated with
+	// Notifier.InvalidateInode. File systems that don't implement OpenDir
+	// aren't affected.
+	ReaddirCache bool
+
+	// Let the kernel send concurrent LookUpInodeOp and ReadDirOp ops for the
+	// same directory, which it otherwise serializes (Linux >= 4.7). See
+	// MountConfig.EnableParallelDirOps.
+	ParallelDirOps bool
+}
+
+// NetworkFSCaching returns a caching policy for file systems whose data may
+// be changed by other clients without the kernel seeing it, e.g. those backed
+// by a remote server or object store. Writes are sent as they are made, and
+// cached data is dropped when changed attributes are returned, so that other
+// clients' changes are picked up once the attributes expire. Lookups and
+// listings, which wait on round trips, may be served concurrently.
+func NetworkFSCaching() CachingPolicy {
+	return CachingPolicy{
+		AutoInvalData:  true,
+		ParallelDirOps: true,
+	}
+}
+
+// LocalFSCaching returns a caching policy for file systems that are the only
+// way their data is changed, e.g. those backed by a local disk or held in
+// memory. Everything is cached, and writes go through the writeback cache.
+// A file system whose data does change elsewhere must then tell the kernel
+// with a Notifier.
+func LocalFSCaching() CachingPolicy {
+	return CachingPolicy{
+		WritebackCache:    true,
+		ExplicitInvalData: true,
+		SymlinkCache:      true,
+		ReaddirCache:      true,
+		ParallelDirOps:    true,
+	}
+}
+
+// SyntheticFSCaching returns a caching policy for file systems whose contents
+// are generated on demand, like /proc, and may change from one read to the
+// next. Nothing is cached beyond the expirations the file system returns,
+// and writes are sent as they are made. Files whose sizes aren't known in
+// advance should also be opened with OpenFileOp.UseDirectIO, so that reads
+// aren't cut short at the size in their attributes.
+func SyntheticFSCaching() CachingPolicy {
+	return CachingPolicy{
+		ParallelDirOps: true,
+	}
+}
+
+// Check that the policy can be requested from the kernel.
+func (p CachingPolicy) validate() error {
+	if p.AutoInvalData && p.ExplicitInvalData {
+		return errors.New(
+			"CachingPolicy: AutoInvalData and ExplicitInvalData are exclusive")
+	}
+
+	return nil
+}
+
+// Return the caching policy in effect for the config: Caching if set, and
+// otherwise that described by the individual flags it replaces.
+func (c *MountConfig) caching() CachingPolicy {
+	if c.Caching != nil {
+		return *c.Caching
+	}
+
+	return CachingPolicy{
+		WritebackCache: !c.DisableWritebackCaching,
+		SymlinkCache:   c.EnableSymlinkCaching,
+		ParallelDirOps: c.EnableParallelDirOps,
+	}
+}
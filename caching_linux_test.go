@@ -0,0 +1,140 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system that records whether the kernel was asked to cache each
+// listing of the root directory.
+type cachingFS struct {
+	accessFS
+
+	mu        sync.Mutex
+	cacheDirs []bool
+}
+
+func (fs *cachingFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.cacheDirs = append(fs.cacheDirs, op.CacheDir && op.KeepCache)
+	return nil
+}
+
+func (fs *cachingFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	return nil
+}
+
+func (fs *cachingFS) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	return nil
+}
+
+func TestCachingPolicy(t *testing.T) {
+	local := fuse.LocalFSCaching()
+	network := fuse.NetworkFSCaching()
+	synthetic := fuse.SyntheticFSCaching()
+
+	testCases := []struct {
+		name     string
+		cfg      fuse.MountConfig
+		features []string
+		absent   []string
+		cacheDir bool
+	}{
+		{
+			name:     "legacy flags",
+			cfg:      fuse.MountConfig{EnableParallelDirOps: true},
+			features: []string{"WRITEBACK_CACHE", "PARALLEL_DIROPS"},
+			absent:   []string{"AUTO_INVAL_DATA", "EXPLICIT_INVAL_DATA"},
+		},
+		{
+			name: "local",
+			// Overrides the legacy flag.
+			cfg:      fuse.MountConfig{Caching: &local, DisableWritebackCaching: true},
+			features: []string{"WRITEBACK_CACHE", "EXPLICIT_INVAL_DATA", "CACHE_SYMLINKS"},
+			absent:   []string{"AUTO_INVAL_DATA"},
+			cacheDir: true,
+		},
+		{
+			name:     "network",
+			cfg:      fuse.MountConfig{Caching: &network},
+			features: []string{"AUTO_INVAL_DATA", "PARALLEL_DIROPS"},
+			absent:   []string{"WRITEBACK_CACHE", "EXPLICIT_INVAL_DATA", "CACHE_SYMLINKS"},
+		},
+		{
+			name:     "synthetic",
+			cfg:      fuse.MountConfig{Caching: &synthetic},
+			features: []string{"PARALLEL_DIROPS"},
+			absent:   []string{"WRITEBACK_CACHE", "AUTO_INVAL_DATA", "EXPLICIT_INVAL_DATA"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := &cachingFS{}
+			mfs, err := fuse.Mount(t.TempDir(), fuseutil.NewFileSystemServer(fs), &tc.cfg)
+			if err != nil {
+				t.Fatalf("fuse.Mount: %v", err)
+			}
+
+			defer func() {
+				if err := mfs.Join(ctx); err != nil {
+					t.Errorf("Joining: %v", err)
+				}
+			}()
+
+			defer fuse.Unmount(mfs.Dir())
+
+			features := mfs.KernelInfo().Features
+			for _, f := range tc.features {
+				if !slices.Contains(features, f) {
+					t.Errorf("%s missing from %v", f, features)
+				}
+			}
+
+			for _, f := range tc.absent {
+				if slices.Contains(features, f) {
+					t.Errorf("%s present in %v", f, features)
+				}
+			}
+
+			if _, err := os.ReadDir(mfs.Dir()); err != nil {
+				t.Fatalf("ReadDir: %v", err)
+			}
+
+			fs.mu.Lock()
+			defer fs.mu.Unlock()
+
+			if len(fs.cacheDirs) != 1 || fs.cacheDirs[0] != tc.cacheDir {
+				t.Errorf("Got CacheDir %v, want [%v]", fs.cacheDirs, tc.cacheDir)
+			}
+		})
+	}
+}
+
+func TestCachingPolicy_Invalid(t *testing.T) {
+	p := fuse.CachingPolicy{AutoInvalData: true, ExplicitInvalData: true}
+	_, err := fuse.Mount(
+		t.TempDir(),
+		fuseutil.NewFileSystemServer(&cachingFS{}),
+		&fuse.MountConfig{Caching: &p})
+
+	if err == nil {
+		t.Fatalf("Mount succeeded")
+	}
+}
@@ -16,6 +16,7 @@ package fuse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -24,6 +25,7 @@ import (
 	"runtime"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/internal/buffer"
@@ -69,6 +71,20 @@ type Connection struct {
 	dev      *os.File
 	protocol fusekernel.Protocol
 
+	// MountConfig.Tuning, with fields left zero filled in.
+	tuning Tuning
+
+	// What was negotiated with the kernel by Init.
+	kernel KernelInfo
+
+	// Whether the kernel lets handles opened with direct I/O be mapped shared.
+	// See MountConfig.EnableDirectIOMmap.
+	directIOMmap bool
+
+	// Whether writeback caching was negotiated, in which case the kernel also
+	// reads through handles opened write-only, to fill the pages written.
+	writebackCache bool
+
 	mu sync.Mutex
 
 	// A map from fuse "unique" request ID (*not* the op ID for logging used
@@ -77,9 +93,33 @@ type Connection struct {
 	// GUARDED_BY(mu)
 	cancelFuncs map[uint64]func()
 
+	// The number of requests received with each unknown opcode. See
+	// UnknownOps.
+	//
+	// GUARDED_BY(mu)
+	unknownOps map[uint32]uint64
+
+	// The state of each file handle, if tracked. See handles.go.
+	//
+	// GUARDED_BY(mu)
+	handles map[fuseops.HandleID]*handleState
+
+	// Why ReadOp first failed, if it has. See exit.go.
+	//
+	// GUARDED_BY(mu)
+	exitCause ExitCause
+	exitErr   error
+
+	// The number of replies dropped because the kernel had disconnected. See
+	// DroppedReplies.
+	//
+	// GUARDED_BY(mu)
+	droppedReplies uint64
+
 	// Freelists, serviced by freelists.go.
 	inMessages  freelist.Freelist // GUARDED_BY(mu)
 	outMessages freelist.Freelist // GUARDED_BY(mu)
+	arenas      freelist.Freelist // GUARDED_BY(mu)
 }
 
 // State that is maintained for each in-flight op. This is stuffed into the
@@ -89,13 +129,22 @@ type opState struct {
 	outMsg *buffer.OutMessage
 	op     interface{}
 	wlog   *WireLogRecord
+
+	// Non-nil if the op is being profiled. See MountConfig.Profiler.
+	sample *ProfileSample
+
+	// When the op was read, if MountConfig.Metrics is set.
+	start time.Time
+
+	// Non-nil if the op's memory comes from an arena. See MountConfig.PoolOps.
+	arena *opArena
 }
 
 // Return the current wirelog record from the context if the MountConfig
 // contained a non-nil wireLogger, nil otherwise.
 func GetWirelog(ctx context.Context) *WireLogRecord {
 	val := ctx.Value(contextKey)
-	state, ok := val.(opState)
+	state, ok := val.(*opState)
 	if ok {
 		return state.wlog
 	}
@@ -118,6 +167,7 @@ func newConnection(
 		errorLogger: errorLogger,
 		wireLogger:  wireLogger,
 		dev:         dev,
+		tuning:      autotune(cfg.Tuning, runtime.GOMAXPROCS(0)),
 		cancelFuncs: make(map[uint64]func()),
 	}
 
@@ -165,16 +215,41 @@ func (c *Connection) Init() error {
 		c.protocol = initOp.Kernel
 	}
 
+	// Downgrade further if the user has asked us to (checked against the
+	// minimum by Mount).
+	if c.cfg.MaxProtocolMinor != 0 {
+		max := fusekernel.Protocol{Major: fusekernel.ProtoVersionMaxMajor, Minor: c.cfg.MaxProtocolMinor}
+		if max.LT(c.protocol) {
+			c.protocol = max
+		}
+	}
+
+	c.kernel = KernelInfo{
+		KernelProtocol:     Protocol(initOp.Kernel),
+		KernelFeatures:     featureNames(initOp.Flags, initOp.Flags2),
+		KernelMaxReadahead: initOp.MaxReadahead,
+	}
+
 	cacheSymlinks := initOp.Flags&fusekernel.InitCacheSymlinks > 0
+	submounts := initOp.Flags&fusekernel.InitSubmounts > 0
 	noOpenSupport := initOp.Flags&fusekernel.InitNoOpenSupport > 0
 	noOpendirSupport := initOp.Flags&fusekernel.InitNoOpendirSupport > 0
+	passthrough := c.cfg.EnablePassthrough &&
+		initOp.Flags2&fusekernel.InitPassthrough.ForProtocol(c.protocol) != 0
+	idmap := c.cfg.EnableIDMappedMounts &&
+		initOp.Flags2&fusekernel.InitAllowIdmap.ForProtocol(c.protocol) != 0
+	c.directIOMmap = c.cfg.EnableDirectIOMmap &&
+		initOp.Flags2&fusekernel.InitDirectIOAllowMmap.ForProtocol(c.protocol) != 0
 
 	// Respond to the init op.
 	initOp.Library = c.protocol
 	initOp.MaxReadahead = maxReadahead
 	initOp.MaxWrite = buffer.MaxWriteSize
+	initOp.MaxBackground = c.tuning.MaxBackground
+	initOp.CongestionThreshold = c.tuning.CongestionThreshold
 
 	initOp.Flags = 0
+	initOp.Flags2 = 0
 
 	// Tell the kernel not to use pitifully small 4 KiB writes.
 	initOp.Flags |= fusekernel.InitBigWrites
@@ -183,18 +258,48 @@ func (c *Connection) Init() error {
 		initOp.Flags |= fusekernel.InitAsyncRead
 	}
 
+	if c.cfg.EnableAsyncDIO {
+		initOp.Flags |= fusekernel.InitAsyncDIO
+	}
+
+	// Have reads from the device fail with ECONNABORTED rather than ENODEV
+	// after the connection is aborted, so that an abort can be told apart from
+	// an unmount (Linux >= 4.20). ReadOp still returns io.EOF for it, as it did
+	// before the flag was requested; see ExitAborted.
+	initOp.Flags |= fusekernel.InitAbortError
+
 	// kernel 4.20 increases the max from 32 -> 256
 	initOp.Flags |= fusekernel.InitMaxPages
 	initOp.MaxPages = 256
 
-	// Enable writeback caching if the user hasn't asked us not to.
-	if !c.cfg.DisableWritebackCaching {
+	// The kernel doesn't support passthrough alongside writeback caching.
+	caching := c.cfg.caching()
+	if caching.WritebackCache && !passthrough {
 		initOp.Flags |= fusekernel.InitWritebackCache
 	}
 
+	if passthrough {
+		// Backing files may not themselves be on a stacked file system, e.g.
+		// another fuse file system using passthrough or overlayfs.
+		initOp.Flags2 |= fusekernel.InitPassthrough
+		initOp.MaxStackDepth = 1
+	}
+
+	if c.directIOMmap {
+		initOp.Flags2 |= fusekernel.InitDirectIOAllowMmap
+	}
+
+	if caching.AutoInvalData {
+		initOp.Flags |= fusekernel.InitAutoInvalData
+	}
+
+	if caching.ExplicitInvalData {
+		initOp.Flags |= fusekernel.InitExplicitInvalData
+	}
+
 	// Enable caching symlink targets in the kernel page cache if the user opted
 	// into it (might require fixing the size field of inode attributes first):
-	if c.cfg.EnableSymlinkCaching && cacheSymlinks {
+	if caching.SymlinkCache && cacheSymlinks {
 		initOp.Flags |= fusekernel.InitCacheSymlinks
 	}
 
@@ -211,7 +316,7 @@ func (c *Connection) Init() error {
 	}
 
 	// Tell the Kernel to allow sending parallel lookup and readdir operations.
-	if c.cfg.EnableParallelDirOps {
+	if caching.ParallelDirOps {
 		initOp.Flags |= fusekernel.InitParallelDirOps
 	}
 
@@ -219,6 +324,26 @@ func (c *Connection) Init() error {
 		initOp.Flags |= fusekernel.InitAtomicTrunc
 	}
 
+	if c.cfg.EnableSubmounts && submounts {
+		initOp.Flags |= fusekernel.InitSubmounts
+	}
+
+	if idmap {
+		initOp.Flags2 |= fusekernel.InitAllowIdmap
+	}
+
+	if c.cfg.EnableExportSupport {
+		initOp.Flags |= fusekernel.InitExportSupport
+	}
+
+	if c.cfg.EnablePosixLocks {
+		initOp.Flags |= fusekernel.InitPosixLocks
+	}
+
+	if c.cfg.EnableFlockLocks {
+		initOp.Flags |= fusekernel.InitFlockLocks
+	}
+
 	if c.cfg.EnableReaddirplus {
 		// Enable Readdirplus support, allowing the kernel to use Readdirplus
 		initOp.Flags |= fusekernel.InitDoReaddirplus
@@ -229,9 +354,45 @@ func (c *Connection) Init() error {
 		}
 	}
 
+	// Don't ask for features from after the negotiated version, in case it was
+	// capped by MaxProtocolMinor.
+	initOp.Flags = initOp.Flags.ForProtocol(c.protocol)
+	if initOp.Flags2 != 0 {
+		initOp.Flags |= fusekernel.InitExt
+	}
+
+	c.writebackCache = initOp.Flags&fusekernel.InitWritebackCache != 0
+
+	c.kernel.Protocol = Protocol(c.protocol)
+	c.kernel.Features = featureNames(initOp.Flags, initOp.Flags2)
+	c.kernel.MaxReadahead = initOp.MaxReadahead
+	if c.kernel.KernelMaxReadahead < c.kernel.MaxReadahead {
+		c.kernel.MaxReadahead = c.kernel.KernelMaxReadahead
+	}
+	c.kernel.MaxWrite = initOp.MaxWrite
+	c.kernel.MaxBackground = initOp.MaxBackground
+	c.kernel.CongestionThreshold = initOp.CongestionThreshold
+
+	// Zero leaves the kernel's defaults in place.
+	if c.kernel.MaxBackground == 0 {
+		c.kernel.MaxBackground = 12
+	}
+
+	if c.kernel.CongestionThreshold == 0 {
+		c.kernel.CongestionThreshold = 9
+	}
+	if initOp.Flags&fusekernel.InitMaxPages != 0 {
+		c.kernel.MaxPages = initOp.MaxPages
+	}
+
 	return c.Reply(ctx, nil)
 }
 
+// KernelInfo reports what was negotiated with the kernel by Init.
+func (c *Connection) KernelInfo() KernelInfo {
+	return c.kernel
+}
+
 // Log information for an operation with the given ID. calldepth is the depth
 // to use when recovering file:line information with runtime.Caller.
 func (c *Connection) debugLog(
@@ -266,6 +427,15 @@ func (c *Connection) debugLog(
 	c.debugLogger.Println(msg)
 }
 
+// Return the current time according to the configured clock.
+func (c *Connection) now() time.Time {
+	if c.cfg.Clock == nil {
+		return time.Now()
+	}
+
+	return c.cfg.Clock.Now()
+}
+
 // LOCKS_EXCLUDED(c.mu)
 func (c *Connection) recordCancelFunc(
 	fuseID uint64,
@@ -288,9 +458,15 @@ func (c *Connection) recordCancelFunc(
 // LOCKS_EXCLUDED(c.mu)
 func (c *Connection) beginOp(
 	opCode uint32,
-	fuseID uint64) context.Context {
-	// Start with the parent context.
+	fuseID uint64,
+	arena *opArena) context.Context {
+	// Start with the parent context, or the arena's equivalent of a context
+	// derived from it.
 	ctx := c.cfg.OpContext
+	if arena != nil {
+		arena.ctx.parent = ctx
+		ctx = &arena.ctx
+	}
 
 	// Set up a cancellation function.
 	//
@@ -302,7 +478,12 @@ func (c *Connection) beginOp(
 	// Cf. https://github.com/osxfuse/osxfuse/issues/208
 	if opCode != fusekernel.OpForget {
 		var cancel func()
-		ctx, cancel = context.WithCancel(ctx)
+		if arena != nil {
+			cancel = arena.cancel
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+
 		c.recordCancelFunc(fuseID, cancel)
 	}
 
@@ -372,7 +553,7 @@ func (c *Connection) readMessage() (*buffer.InMessage, error) {
 	m := c.getInMessage()
 
 	// Loop past transient errors.
-	for {
+	for attempt := 1; ; attempt++ {
 		// Attempt a read.
 		err := m.Init(c.dev)
 
@@ -380,16 +561,13 @@ func (c *Connection) readMessage() (*buffer.InMessage, error) {
 		//
 		//  *  ENODEV means fuse has hung up.
 		//
-		//  *  EINTR means we should try again. (This seems to happen often on
-		//     OS X, cf. http://golang.org/issue/11180)
+		//  *  EINTR (by default) means we should try again. (This seems to happen
+		//     often on OS X, cf. http://golang.org/issue/11180)
 		//
 		if pe, ok := err.(*os.PathError); ok {
-			switch pe.Err {
-			case syscall.ENODEV:
+			if pe.Err == syscall.ENODEV {
 				err = io.EOF
-
-			case syscall.EINTR:
-				err = nil
+			} else if c.retryDeviceError(false, err, attempt) {
 				continue
 			}
 		}
@@ -405,7 +583,23 @@ func (c *Connection) readMessage() (*buffer.InMessage, error) {
 
 // Write a buffer.OutMessage to the kernel, with writev if vectored IO is useful
 // and write if not.
+//
+// Each call writes exactly one reply. It is tempting to gather several
+// completed replies into a single writev, but fuse_dev_write in the kernel
+// consumes one fuse_out_header per write and rejects the call with EINVAL if
+// the header's length doesn't match the number of bytes written, so replies
+// can't be pipelined through /dev/fuse. The io_uring transport added in Linux
+// 6.14 is the kernel's answer to per-reply syscall overhead.
 func (c *Connection) writeOutMessage(outMsg *buffer.OutMessage) error {
+	for attempt := 1; ; attempt++ {
+		err := c.writeOutMessageOnce(outMsg)
+		if err == nil || replyDropped(err) || !c.retryDeviceError(true, err, attempt) {
+			return err
+		}
+	}
+}
+
+func (c *Connection) writeOutMessageOnce(outMsg *buffer.OutMessage) error {
 	var err error
 	if outMsg.Sglist != nil {
 		if fusekernel.IsPlatformFuseT {
@@ -437,7 +631,9 @@ func (c *Connection) writeMessage(msg []byte) error {
 
 // ReadOp consumes the next op from the kernel process, returning the op and a
 // context that should be used for work related to the op. It returns io.EOF if
-// the kernel has closed the connection.
+// the kernel has closed the connection because the file system was unmounted
+// or the connection was aborted, and otherwise an *ExitError if the connection
+// can't be served further.
 //
 // If err != nil, the user is responsible for later calling c.Reply with the
 // returned context.
@@ -452,15 +648,35 @@ func (c *Connection) ReadOp() (_ context.Context, op interface{}, _ error) {
 		// Read the next message from the kernel.
 		inMsg, err := c.readMessage()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, c.exit(readErrorCause(err), err)
+		}
+
+		var start time.Time
+		if c.cfg.Metrics != nil || c.cfg.Profiler != nil {
+			start = time.Now()
+		}
+
+		var sample *ProfileSample
+		if c.cfg.Profiler != nil {
+			sample = c.cfg.Profiler.read(inMsg.Header().Unique, start)
+		}
+
+		// Take memory for the op from an arena if possible. The arena's context
+		// can't be cancelled by its parent, so one that can be rules it out.
+		var arena *opArena
+		if c.cfg.PoolOps && c.cfg.OpContext.Done() == nil {
+			arena = c.getArena()
 		}
 
 		// Convert the message to an op.
 		outMsg := c.getOutMessage()
-		op, err = convertInMessage(&c.cfg, inMsg, outMsg, c.protocol)
+		op, err = convertInMessage(&c.cfg, inMsg, outMsg, c.protocol, arena)
 		if err != nil {
 			c.putOutMessage(outMsg)
-			return nil, nil, fmt.Errorf("convertInMessage: %v", err)
+			c.putArena(arena)
+			return nil, nil, c.exit(
+				ExitProtocolError,
+				fmt.Errorf("convertInMessage: %w", err))
 		}
 
 		// Choose an ID for this operation for the purposes of logging, and log it.
@@ -471,16 +687,64 @@ func (c *Connection) ReadOp() (_ context.Context, op interface{}, _ error) {
 		// Special case: handle interrupt requests inline.
 		if interruptOp, ok := op.(*interruptOp); ok {
 			c.handleInterrupt(interruptOp.FuseID)
+			c.putArena(arena)
 			continue
 		}
 
 		// Set up a context that remembers information about this op.
-		ctx := c.beginOp(inMsg.Header().Opcode, inMsg.Header().Unique)
+		ctx := c.beginOp(inMsg.Header().Opcode, inMsg.Header().Unique, arena)
 		var wlog *WireLogRecord
 		if c.wireLogger != nil {
 			wlog = NewWireLogRecord()
 		}
-		ctx = context.WithValue(ctx, contextKey, opState{inMsg, outMsg, op, wlog})
+		if sample != nil {
+			sample.Op = opName(op)
+			sample.Decode = time.Since(sample.Start)
+		}
+
+		state := opState{inMsg, outMsg, op, wlog, sample, start, arena}
+		if arena != nil {
+			arena.state = state
+			arena.ctx.state = &arena.state
+		} else {
+			ctx = context.WithValue(ctx, contextKey, &state)
+		}
+
+		// Apply the policy for opcodes we don't know, failing if it says to.
+		if unknown, ok := op.(*unknownOp); ok {
+			if err := c.handleUnknownOp(unknown, inMsg); err != nil {
+				c.Reply(ctx, syscall.ENOSYS)
+				return nil, nil, c.exit(ExitProtocolError, err)
+			}
+		}
+
+		if readOp, ok := op.(*fuseops.ReadFileOp); ok && c.cfg.ClassifyReadahead {
+			c.classifyRead(readOp)
+		}
+
+		// Refuse renames with flags unless the user has said they can honor
+		// them, rather than let them be mistaken for plain renames.
+		if renameOp, ok := op.(*fuseops.RenameOp); ok && renameOp.Flags != 0 && !c.cfg.EnableRenameFlags {
+			c.Reply(ctx, syscall.ENOSYS)
+			continue
+		}
+
+		// Reject writes that would partially overwrite the user's blocks.
+		if writeOp, ok := op.(*fuseops.WriteFileOp); ok && c.cfg.WriteAlignment != 0 {
+			if err := validateWriteAlignment(writeOp, c.cfg.WriteAlignment); err != nil {
+				c.Reply(ctx, err)
+				continue
+			}
+		}
+
+		// Reject reads and writes through handles not opened for them, without
+		// involving the user.
+		if c.cfg.EnforceOpenModes {
+			if err := c.checkOpenMode(op); err != nil {
+				c.Reply(ctx, err)
+				continue
+			}
+		}
 
 		// Return the op to the user.
 		return ctx, op, nil
@@ -501,6 +765,10 @@ func (c *Connection) shouldLogError(
 		return false
 	}
 
+	// Compare errnos, so that wrapped errors are treated like the errno they
+	// will be reported as.
+	err = c.Errno(err)
+
 	switch op.(type) {
 	case *fuseops.LookUpInodeOp:
 		// It is totally normal for the kernel to ask to look up an inode by name
@@ -513,6 +781,11 @@ func (c *Connection) shouldLogError(
 		if err == syscall.ENOSYS || err == syscall.ENODATA || err == syscall.ERANGE {
 			return false
 		}
+	case *fuseops.RenameOp:
+		// Renames with flags are refused this way unless they're enabled.
+		if err == syscall.ENOSYS {
+			return false
+		}
 	case *unknownOp:
 		// Don't bother the user with methods we intentionally don't support.
 		if err == syscall.ENOSYS {
@@ -528,21 +801,49 @@ var writeLock sync.Mutex
 // Reply replies to an op previously read using ReadOp, with the supplied error
 // (or nil if successful). The context must be the context returned by ReadOp.
 //
+// A reply that can't be delivered because the kernel has disconnected, e.g.
+// because the file system was unmounted while the op was in flight, is
+// dropped, and nil is returned. See DroppedReplies.
+//
 // LOCKS_EXCLUDED(c.mu)
 func (c *Connection) Reply(ctx context.Context, opErr error) error {
 	// Extract the state we stuffed in earlier.
 	var key interface{} = contextKey
 	foo := ctx.Value(key)
-	state, ok := foo.(opState)
+	state, ok := foo.(*opState)
 	if !ok {
 		panic(fmt.Sprintf("Reply called with invalid context: %#v", ctx))
 	}
 
+	// The arena, if any, holds the state and the op, so it must be returned
+	// last.
+	defer c.putArena(state.arena)
+
 	op := state.op
 	inMsg := state.inMsg
 	outMsg := state.outMsg
 	fuseID := inMsg.Header().Unique
 
+	if sample := state.sample; sample != nil {
+		replyStart := time.Now()
+		sample.Handler = replyStart.Sub(sample.Start) - sample.Decode
+		defer func() {
+			sample.Reply = time.Since(replyStart)
+			c.cfg.Profiler.record(sample)
+		}()
+	}
+
+	if c.cfg.Metrics != nil {
+		defer func(start time.Time) {
+			name := opName(op)
+			if readOp, ok := op.(*fuseops.ReadFileOp); ok && readOp.Readahead {
+				name += "(readahead)"
+			}
+
+			c.cfg.Metrics.record(name, time.Since(start), opErr != nil)
+		}(state.start)
+	}
+
 	defer func() {
 		// Invoke any callbacks set by the FUSE server after the response to the kernel is
 		// complete and before the inMessage and outMessage memory buffers have been freed.
@@ -558,6 +859,21 @@ func (c *Connection) Reply(ctx context.Context, opErr error) error {
 
 	// Clean up state for this op.
 	c.finishOp(inMsg.Header().Opcode, inMsg.Header().Unique)
+	if opErr == nil && c.cfg.DirectIOFallback != nil {
+		c.fallBackFromDirectIO(op)
+	}
+
+	if c.trackingHandles() {
+		c.trackHandles(op, opErr)
+	}
+
+	// In strict mode, refuse to send a reply that the kernel would
+	// misinterpret, turning it into an error that will be logged below.
+	if opErr == nil && c.cfg.StrictReplies {
+		if err := validateReply(op); err != nil {
+			opErr = fmt.Errorf("invalid reply: %v", err)
+		}
+	}
 
 	logError := c.shouldLogError(op, opErr)
 
@@ -582,6 +898,11 @@ func (c *Connection) Reply(ctx context.Context, opErr error) error {
 
 	if !noResponse {
 		err := c.writeOutMessage(outMsg)
+		if replyDropped(err) {
+			c.dropReply(fuseID, err)
+			return nil
+		}
+
 		if err != nil {
 			writeErrMsg := fmt.Sprintf("writeMessage: %v %v", err, outMsg.OutHeaderBytes())
 			if c.errorLogger != nil {
@@ -592,6 +913,11 @@ func (c *Connection) Reply(ctx context.Context, opErr error) error {
 		outMsg.Sglist = nil
 	}
 
+	var stale *StaleError
+	if errors.As(opErr, &stale) {
+		go c.invalidateStale(stale)
+	}
+
 	if c.wireLogger != nil {
 		entry, err := formatWireLogEntry(op, opErr, state.wlog)
 		if err == nil {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"runtime"
 	"syscall"
 	"time"
 	"unsafe"
@@ -41,7 +42,8 @@ func convertInMessage(
 	config *MountConfig,
 	inMsg *buffer.InMessage,
 	outMsg *buffer.OutMessage,
-	protocol fusekernel.Protocol) (o interface{}, err error) {
+	protocol fusekernel.Protocol,
+	arena *opArena) (o interface{}, err error) {
 	switch inMsg.Header().Opcode {
 	case fusekernel.OpLookup:
 		buf := inMsg.ConsumeBytes(inMsg.Len())
@@ -50,26 +52,71 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpLookup")
 		}
 
-		o = &fuseops.LookUpInodeOp{
+		o = place(arena, fuseops.LookUpInodeOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(buf[:n-1]),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpGetattr:
-		o = &fuseops.GetInodeAttributesOp{
+		o = place(arena, fuseops.GetInodeAttributesOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
+		})
+
+	case fusekernel.OpStatx:
+		type input fusekernel.StatxIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpStatx")
+		}
+
+		to := place(arena, fuseops.StatxOp{
+			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
+			Mask:  in.SxMask,
+			Flags: in.SxFlags,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+		o = to
+
+		if fusekernel.GetattrFlags(in.GetattrFlags)&fusekernel.GetattrFh != 0 {
+			handle := fuseops.HandleID(in.Fh)
+			to.Handle = &handle
+		}
+
+	case fusekernel.OpAccess:
+		type input fusekernel.AccessIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpAccess")
 		}
 
+		o = place(arena, fuseops.AccessOp{
+			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
+			Mask:  in.Mask,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
 	case fusekernel.OpSetattr:
 		type input fusekernel.SetattrIn
 		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
@@ -77,14 +124,15 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpSetattr")
 		}
 
-		to := &fuseops.SetInodeAttributesOp{
+		to := place(arena, fuseops.SetInodeAttributesOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 		o = to
 
 		valid := fusekernel.SetattrValid(in.Valid)
@@ -115,6 +163,11 @@ func convertInMessage(
 			to.Mtime = &t
 		}
 
+		if valid&fusekernel.SetattrCtime != 0 {
+			t := time.Unix(int64(in.Ctime), int64(in.CtimeNsec))
+			to.Ctime = &t
+		}
+
 		if valid.Handle() {
 			t := fuseops.HandleID(in.Fh)
 			to.Handle = &t
@@ -127,15 +180,16 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpForget")
 		}
 
-		o = &fuseops.ForgetInodeOp{
+		o = place(arena, fuseops.ForgetInodeOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			N:     in.Nlookup,
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpBatchForget:
 		type input fusekernel.BatchForgetCountIn
@@ -158,14 +212,15 @@ func convertInMessage(
 			})
 		}
 
-		o = &fuseops.BatchForgetOp{
+		o = place(arena, fuseops.BatchForgetOp{
 			Entries: entries,
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpMkdir:
 		in := (*fusekernel.MkdirIn)(inMsg.Consume(fusekernel.MkdirInSize(protocol)))
@@ -180,7 +235,7 @@ func convertInMessage(
 		}
 		name = name[:i]
 
-		o = &fuseops.MkDirOp{
+		o = place(arena, fuseops.MkDirOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(name),
 
@@ -195,8 +250,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpMknod:
 		in := (*fusekernel.MknodIn)(inMsg.Consume(fusekernel.MknodInSize(protocol)))
@@ -211,7 +267,7 @@ func convertInMessage(
 		}
 		name = name[:i]
 
-		o = &fuseops.MkNodeOp{
+		o = place(arena, fuseops.MkNodeOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(name),
 			Mode:   ConvertFileMode(in.Mode),
@@ -220,8 +276,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpCreate:
 		in := (*fusekernel.CreateIn)(inMsg.Consume(fusekernel.CreateInSize(protocol)))
@@ -236,7 +293,7 @@ func convertInMessage(
 		}
 		name = name[:i]
 
-		o = &fuseops.CreateFileOp{
+		o = place(arena, fuseops.CreateFileOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(name),
 			Mode:   ConvertFileMode(in.Mode),
@@ -244,10 +301,31 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
 			OpenFlags: fusekernel.OpenFlags(in.Flags),
+		})
+
+	case fusekernel.OpTmpfile:
+		// The create message is followed by the name of the kernel's
+		// placeholder dentry, which means nothing to the file system.
+		in := (*fusekernel.CreateIn)(inMsg.Consume(fusekernel.CreateInSize(protocol)))
+		if in == nil {
+			return nil, errors.New("Corrupt OpTmpfile")
 		}
 
+		o = place(arena, fuseops.CreateTmpfileOp{
+			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
+			Mode:   ConvertFileMode(in.Mode),
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+			OpenFlags: fusekernel.OpenFlags(in.Flags),
+		})
+
 	case fusekernel.OpSymlink:
 		// The message is "newName\0target\0".
 		names := inMsg.ConsumeBytes(inMsg.Len())
@@ -260,7 +338,7 @@ func convertInMessage(
 		}
 		newName, target := names[0:i], names[i+1:len(names)-1]
 
-		o = &fuseops.CreateSymlinkOp{
+		o = place(arena, fuseops.CreateSymlinkOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(newName),
 			Target: string(target),
@@ -268,14 +346,30 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
+
+	case fusekernel.OpRename, fusekernel.OpRename2:
+		// The kernel sends RENAME2 only for renames with flags.
+		var newDir uint64
+		var flags uint32
+		if inMsg.Header().Opcode == fusekernel.OpRename2 {
+			type input fusekernel.Rename2In
+			in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+			if in == nil {
+				return nil, errors.New("Corrupt OpRename2")
+			}
 
-	case fusekernel.OpRename:
-		type input fusekernel.RenameIn
-		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
-		if in == nil {
-			return nil, errors.New("Corrupt OpRename")
+			newDir, flags = in.Newdir, in.Flags
+		} else {
+			type input fusekernel.RenameIn
+			in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+			if in == nil {
+				return nil, errors.New("Corrupt OpRename")
+			}
+
+			newDir = in.Newdir
 		}
 
 		names := inMsg.ConsumeBytes(inMsg.Len())
@@ -287,7 +381,7 @@ func convertInMessage(
 		// https://github.com/osxfuse/osxfuse/issues/839
 		//
 		// the simplest fix is just to check for the presence of all-zero flags
-		if len(names) >= 8 &&
+		if inMsg.Header().Opcode == fusekernel.OpRename && len(names) >= 8 &&
 			names[0] == 0 && names[1] == 0 && names[2] == 0 && names[3] == 0 &&
 			names[4] == 0 && names[5] == 0 && names[6] == 0 && names[7] == 0 {
 			names = names[8:]
@@ -305,17 +399,19 @@ func convertInMessage(
 		}
 		oldName, newName := names[:i], names[i+1:len(names)-1]
 
-		o = &fuseops.RenameOp{
+		o = place(arena, fuseops.RenameOp{
 			OldParent: fuseops.InodeID(inMsg.Header().Nodeid),
 			OldName:   string(oldName),
-			NewParent: fuseops.InodeID(in.Newdir),
+			NewParent: fuseops.InodeID(newDir),
 			NewName:   string(newName),
+			Flags:     fuseops.RenameFlags(flags),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpUnlink:
 		buf := inMsg.ConsumeBytes(inMsg.Len())
@@ -324,15 +420,16 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpUnlink")
 		}
 
-		o = &fuseops.UnlinkOp{
+		o = place(arena, fuseops.UnlinkOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(buf[:n-1]),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpRmdir:
 		buf := inMsg.ConsumeBytes(inMsg.Len())
@@ -341,15 +438,16 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpRmdir")
 		}
 
-		o = &fuseops.RmDirOp{
+		o = place(arena, fuseops.RmDirOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(buf[:n-1]),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpOpen:
 		type input fusekernel.OpenIn
@@ -358,25 +456,30 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpOpen")
 		}
 
-		o = &fuseops.OpenFileOp{
+		o = place(arena, fuseops.OpenFileOp{
 			Inode:     fuseops.InodeID(inMsg.Header().Nodeid),
 			OpenFlags: fusekernel.OpenFlags(in.Flags),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpOpendir:
-		o = &fuseops.OpenDirOp{
-			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
+		cacheDir := config.caching().ReaddirCache
+		o = place(arena, fuseops.OpenDirOp{
+			Inode:     fuseops.InodeID(inMsg.Header().Nodeid),
+			CacheDir:  cacheDir,
+			KeepCache: cacheDir,
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpRead:
 		in := (*fusekernel.ReadIn)(inMsg.Consume(fusekernel.ReadInSize(protocol)))
@@ -384,7 +487,7 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpRead")
 		}
 
-		to := &fuseops.ReadFileOp{
+		to := place(arena, fuseops.ReadFileOp{
 			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 			Handle: fuseops.HandleID(in.Fh),
 			Offset: int64(in.Offset),
@@ -393,8 +496,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 		// Use part of the incoming message storage as the read buffer.
 		to.Dst = inMsg.GetFree(int(in.Size))
 		o = to
@@ -405,7 +509,7 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpReaddir")
 		}
 
-		to := &fuseops.ReadDirOp{
+		to := place(arena, fuseops.ReadDirOp{
 			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 			Handle: fuseops.HandleID(in.Fh),
 			Offset: fuseops.DirOffset(in.Offset),
@@ -413,8 +517,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 		o = to
 
 		readSize := int(in.Size)
@@ -434,7 +539,7 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpReaddirplus")
 		}
 
-		to := &fuseops.ReadDirPlusOp{
+		to := place(arena, fuseops.ReadDirPlusOp{
 			ReadDirOp: fuseops.ReadDirOp{
 				Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 				Handle: fuseops.HandleID(in.Fh),
@@ -443,9 +548,10 @@ func convertInMessage(
 					FuseID: inMsg.Header().Unique,
 					Pid:    inMsg.Header().Pid,
 					Uid:    inMsg.Header().Uid,
+					Gid:    inMsg.Header().Gid,
 				},
 			},
-		}
+		})
 		o = to
 
 		readSize := int(in.Size)
@@ -467,14 +573,17 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpRelease")
 		}
 
-		o = &fuseops.ReleaseFileHandleOp{
-			Handle: fuseops.HandleID(in.Fh),
+		o = place(arena, fuseops.ReleaseFileHandleOp{
+			Handle:       fuseops.HandleID(in.Fh),
+			FlockRelease: in.ReleaseFlags&fusekernel.ReleaseFlockUnlock != 0,
+			LockOwner:    in.LockOwner,
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpReleasedir:
 		type input fusekernel.ReleaseIn
@@ -483,14 +592,15 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpReleasedir")
 		}
 
-		o = &fuseops.ReleaseDirHandleOp{
+		o = place(arena, fuseops.ReleaseDirHandleOp{
 			Handle: fuseops.HandleID(in.Fh),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpWrite:
 		in := (*fusekernel.WriteIn)(inMsg.Consume(fusekernel.WriteInSize(protocol)))
@@ -503,7 +613,7 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpWrite")
 		}
 
-		o = &fuseops.WriteFileOp{
+		o = place(arena, fuseops.WriteFileOp{
 			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 			Handle: fuseops.HandleID(in.Fh),
 			Data:   buf,
@@ -512,8 +622,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpFsync, fusekernel.OpFsyncdir:
 		type input fusekernel.FsyncIn
@@ -522,15 +633,16 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpFsync/OpFsyncdir")
 		}
 
-		o = &fuseops.SyncFileOp{
+		o = place(arena, fuseops.SyncFileOp{
 			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 			Handle: fuseops.HandleID(in.Fh),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpSyncFS:
 		type input fusekernel.SyncFSIn
@@ -539,11 +651,68 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpSyncFS")
 		}
 
-		o = &fuseops.SyncFSOp{
+		o = place(arena, fuseops.SyncFSOp{
 			Inode:     fuseops.InodeID(inMsg.Header().Nodeid),
 			OpContext: fuseops.OpContext{Pid: inMsg.Header().Pid},
+		})
+
+	case fusekernel.OpSetupMapping:
+		type input fusekernel.SetupMappingIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpSetupMapping")
+		}
+
+		o = place(arena, fuseops.SetupMappingOp{
+			Inode:         fuseops.InodeID(inMsg.Header().Nodeid),
+			Handle:        fuseops.HandleID(in.Fh),
+			Offset:        in.Foffset,
+			Length:        in.Len,
+			Flags:         fusekernel.SetupMappingFlags(in.Flags),
+			MappingOffset: in.Moffset,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+	case fusekernel.OpRemoveMapping:
+		type input fusekernel.RemoveMappingIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpRemoveMapping")
 		}
 
+		// The entries follow the 4-byte count without padding, so they may be
+		// misaligned; copy each before reading it.
+		mappings := make([]fuseops.RemoveMappingEntry, 0, in.Count)
+		for i := uint32(0); i < in.Count; i++ {
+			var ein fusekernel.RemoveMappingOne
+			b := inMsg.ConsumeBytes(unsafe.Sizeof(ein))
+			if b == nil {
+				return nil, errors.New("Corrupt OpRemoveMapping")
+			}
+
+			copy(unsafe.Slice((*byte)(unsafe.Pointer(&ein)), len(b)), b)
+			mappings = append(mappings, fuseops.RemoveMappingEntry{
+				MappingOffset: ein.Moffset,
+				Length:        ein.Len,
+			})
+		}
+
+		o = place(arena, fuseops.RemoveMappingOp{
+			Inode:    fuseops.InodeID(inMsg.Header().Nodeid),
+			Mappings: mappings,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
 	case fusekernel.OpFlush:
 		type input fusekernel.FlushIn
 		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
@@ -551,28 +720,31 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpFlush")
 		}
 
-		o = &fuseops.FlushFileOp{
-			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
-			Handle: fuseops.HandleID(in.Fh),
+		o = place(arena, fuseops.FlushFileOp{
+			Inode:     fuseops.InodeID(inMsg.Header().Nodeid),
+			Handle:    fuseops.HandleID(in.Fh),
+			LockOwner: in.LockOwner,
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpReadlink:
-		o = &fuseops.ReadSymlinkOp{
+		o = place(arena, fuseops.ReadSymlinkOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpStatfs:
-		o = &fuseops.StatFSOp{}
+		o = place(arena, fuseops.StatFSOp{})
 
 	case fusekernel.OpInterrupt:
 		type input fusekernel.InterruptIn
@@ -592,11 +764,19 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpInit")
 		}
 
-		o = &initOp{
+		initOp := &initOp{
 			Kernel:       fusekernel.Protocol{in.Major, in.Minor},
 			MaxReadahead: in.MaxReadahead,
 			Flags:        fusekernel.InitFlags(in.Flags),
 		}
+		o = initOp
+
+		// Newer Linux kernels follow the flags with more of them.
+		if runtime.GOOS == "linux" && initOp.Flags&fusekernel.InitExt != 0 {
+			if p := inMsg.Consume(4); p != nil {
+				initOp.Flags2 = fusekernel.InitFlags2(*(*uint32)(p))
+			}
+		}
 
 	case fusekernel.OpLink:
 		type input fusekernel.LinkIn
@@ -615,7 +795,7 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpLink (Name not read)")
 		}
 
-		o = &fuseops.CreateLinkOp{
+		o = place(arena, fuseops.CreateLinkOp{
 			Parent: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:   string(name),
 			Target: fuseops.InodeID(in.Oldnodeid),
@@ -623,8 +803,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpRemovexattr:
 		buf := inMsg.ConsumeBytes(inMsg.Len())
@@ -633,15 +814,16 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpRemovexattr")
 		}
 
-		o = &fuseops.RemoveXattrOp{
+		o = place(arena, fuseops.RemoveXattrOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:  string(buf[:n-1]),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 
 	case fusekernel.OpGetxattr:
 		type input fusekernel.GetxattrIn
@@ -657,15 +839,16 @@ func convertInMessage(
 		}
 		name = name[:i]
 
-		to := &fuseops.GetXattrOp{
+		to := place(arena, fuseops.GetXattrOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:  string(name),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 		o = to
 
 		readSize := int(in.Size)
@@ -690,14 +873,15 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpListxattr")
 		}
 
-		to := &fuseops.ListXattrOp{
+		to := place(arena, fuseops.ListXattrOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 		o = to
 
 		readSize := int(in.Size)
@@ -730,7 +914,7 @@ func convertInMessage(
 
 		name, value := payload[:i], payload[i+1:len(payload)]
 
-		o = &fuseops.SetXattrOp{
+		o = place(arena, fuseops.SetXattrOp{
 			Inode: fuseops.InodeID(inMsg.Header().Nodeid),
 			Name:  string(name),
 			Value: value,
@@ -739,8 +923,9 @@ func convertInMessage(
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
-		}
+		})
 	case fusekernel.OpFallocate:
 		type input fusekernel.FallocateIn
 		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
@@ -748,19 +933,192 @@ func convertInMessage(
 			return nil, errors.New("Corrupt OpFallocate")
 		}
 
-		o = &fuseops.FallocateOp{
+		o = place(arena, fuseops.FallocateOp{
 			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
 			Handle: fuseops.HandleID(in.Fh),
 			Offset: in.Offset,
 			Length: in.Length,
-			Mode:   in.Mode,
+			Mode:   fuseops.FallocateMode(in.Mode),
 			OpContext: fuseops.OpContext{
 				FuseID: inMsg.Header().Unique,
 				Pid:    inMsg.Header().Pid,
 				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
 			},
+		})
+
+	case fusekernel.OpBmap:
+		type input fusekernel.BmapIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpBmap")
 		}
 
+		o = place(arena, fuseops.BmapOp{
+			Inode:     fuseops.InodeID(inMsg.Header().Nodeid),
+			BlockSize: in.BlockSize,
+			Block:     in.Block,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+	case fusekernel.OpIoctl:
+		type input fusekernel.IoctlIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpIoctl")
+		}
+
+		arg := inMsg.ConsumeBytes(uintptr(in.InSize))
+		if arg == nil {
+			return nil, errors.New("Corrupt OpIoctl")
+		}
+
+		o = place(arena, fuseops.IoctlOp{
+			Inode:        fuseops.InodeID(inMsg.Header().Nodeid),
+			Handle:       fuseops.HandleID(in.Fh),
+			Dir:          in.Flags&fusekernel.IoctlDir != 0,
+			Unrestricted: in.Flags&fusekernel.IoctlUnrestricted != 0,
+			Compat:       in.Flags&fusekernel.IoctlCompat != 0,
+			Cmd:          in.Cmd,
+			Arg:          in.Arg,
+			Input:        arg,
+			OutputSize:   int(in.OutSize),
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+	case fusekernel.OpCopyFileRange:
+		type input fusekernel.CopyFileRangeIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpCopyFileRange")
+		}
+
+		o = place(arena, fuseops.CopyFileRangeOp{
+			SrcInode:  fuseops.InodeID(inMsg.Header().Nodeid),
+			SrcHandle: fuseops.HandleID(in.FhIn),
+			SrcOffset: in.OffIn,
+			DstInode:  fuseops.InodeID(in.NodeidOut),
+			DstHandle: fuseops.HandleID(in.FhOut),
+			DstOffset: in.OffOut,
+			Length:    in.Len,
+			Flags:     in.Flags,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+	case fusekernel.OpLseek:
+		type input fusekernel.LseekIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpLseek")
+		}
+
+		o = place(arena, fuseops.LseekOp{
+			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
+			Handle: fuseops.HandleID(in.Fh),
+			Offset: int64(in.Offset),
+			Whence: int(in.Whence),
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+	case fusekernel.OpGetlk, fusekernel.OpSetlk, fusekernel.OpSetlkw:
+		in := (*fusekernel.LkIn)(inMsg.Consume(fusekernel.LkInSize(protocol)))
+		if in == nil {
+			return nil, errors.New("Corrupt OpGetlk/OpSetlk/OpSetlkw")
+		}
+
+		inode := fuseops.InodeID(inMsg.Header().Nodeid)
+		handle := fuseops.HandleID(in.Fh)
+		lock := fuseops.FileLock{
+			Start: in.Lk.Start,
+			End:   in.Lk.End,
+			Type:  in.Lk.Type,
+			Pid:   in.Lk.Pid,
+		}
+
+		opCtx := fuseops.OpContext{
+			FuseID: inMsg.Header().Unique,
+			Pid:    inMsg.Header().Pid,
+			Uid:    inMsg.Header().Uid,
+			Gid:    inMsg.Header().Gid,
+		}
+
+		switch inMsg.Header().Opcode {
+		case fusekernel.OpGetlk:
+			o = place(arena, fuseops.GetLkOp{
+				Inode:     inode,
+				Handle:    handle,
+				Owner:     in.Owner,
+				Lock:      lock,
+				Conflict:  fuseops.FileLock{Type: syscall.F_UNLCK},
+				OpContext: opCtx,
+			})
+
+		case fusekernel.OpSetlk:
+			o = place(arena, fuseops.SetLkOp{
+				Inode:     inode,
+				Handle:    handle,
+				Owner:     in.Owner,
+				Lock:      lock,
+				Flock:     in.LkFlags&fusekernel.LkFlock != 0,
+				OpContext: opCtx,
+			})
+
+		default:
+			o = place(arena, fuseops.SetLkWOp{
+				Inode:     inode,
+				Handle:    handle,
+				Owner:     in.Owner,
+				Lock:      lock,
+				Flock:     in.LkFlags&fusekernel.LkFlock != 0,
+				OpContext: opCtx,
+			})
+		}
+
+	case fusekernel.OpPoll:
+		type input fusekernel.PollIn
+		in := (*input)(inMsg.Consume(unsafe.Sizeof(input{})))
+		if in == nil {
+			return nil, errors.New("Corrupt OpPoll")
+		}
+
+		op := place(arena, fuseops.PollOp{
+			Inode:  fuseops.InodeID(inMsg.Header().Nodeid),
+			Handle: fuseops.HandleID(in.Fh),
+			Events: in.Events,
+			OpContext: fuseops.OpContext{
+				FuseID: inMsg.Header().Unique,
+				Pid:    inMsg.Header().Pid,
+				Uid:    inMsg.Header().Uid,
+				Gid:    inMsg.Header().Gid,
+			},
+		})
+
+		if in.Flags&fusekernel.PollScheduleNotify != 0 {
+			op.PollHandle = fuseops.PollHandle(in.Kh)
+		}
+
+		o = op
+
 	default:
 		o = &unknownOp{
 			OpCode: inMsg.Header().Opcode,
@@ -804,10 +1162,7 @@ func (c *Connection) kernelResponse(
 		handled := false
 
 		if !handled {
-			m.OutHeader().Error = -int32(syscall.EIO)
-			if errno, ok := opErr.(syscall.Errno); ok {
-				m.OutHeader().Error = -int32(errno)
-			}
+			m.OutHeader().Error = -int32(c.Errno(opErr))
 
 			// Special case: for some types, convertInMessage grew the message in order
 			// to obtain a destination buffer. Make sure that we shrink back to just
@@ -832,42 +1187,64 @@ func (c *Connection) kernelResponse(
 func (c *Connection) kernelResponseForOp(
 	m *buffer.OutMessage,
 	op interface{}) {
+	// Convert every expiration in the response against the same instant.
+	now := c.now()
+
 	// Create the appropriate output message
 	switch o := op.(type) {
 	case *fuseops.LookUpInodeOp:
 		size := int(fusekernel.EntryOutSize(c.protocol))
 		out := (*fusekernel.EntryOut)(m.Grow(size))
-		convertChildInodeEntry(&o.Entry, out)
+		convertChildInodeEntry(&o.Entry, out, now)
 
 	case *fuseops.GetInodeAttributesOp:
 		size := int(fusekernel.AttrOutSize(c.protocol))
 		out := (*fusekernel.AttrOut)(m.Grow(size))
-		out.AttrValid, out.AttrValidNsec = ConvertExpirationTime(
-			o.AttributesExpiration)
-		convertAttributes(o.Inode, &o.Attributes, &out.Attr)
+		out.AttrValid, out.AttrValidNsec = ConvertExpirationTimeAt(
+			o.AttributesExpiration,
+			now)
+		convertAttributes(o.Inode, c.attributes(o.Inode, &o.Attributes), &out.Attr)
+
+	case *fuseops.StatxOp:
+		out := (*fusekernel.StatxOut)(m.Grow(int(unsafe.Sizeof(fusekernel.StatxOut{}))))
+		out.AttrValid, out.AttrValidNsec = ConvertExpirationTimeAt(
+			o.AttributesExpiration,
+			now)
+		convertStatx(o, c.attributes(o.Inode, &o.Attributes), &out.Stat)
 
 	case *fuseops.SetInodeAttributesOp:
 		size := int(fusekernel.AttrOutSize(c.protocol))
 		out := (*fusekernel.AttrOut)(m.Grow(size))
-		out.AttrValid, out.AttrValidNsec = ConvertExpirationTime(
-			o.AttributesExpiration)
-		convertAttributes(o.Inode, &o.Attributes, &out.Attr)
+		out.AttrValid, out.AttrValidNsec = ConvertExpirationTimeAt(
+			o.AttributesExpiration,
+			now)
+		convertAttributes(o.Inode, c.attributes(o.Inode, &o.Attributes), &out.Attr)
 
 	case *fuseops.MkDirOp:
 		size := int(fusekernel.EntryOutSize(c.protocol))
 		out := (*fusekernel.EntryOut)(m.Grow(size))
-		convertChildInodeEntry(&o.Entry, out)
+		convertChildInodeEntry(&o.Entry, out, now)
 
 	case *fuseops.MkNodeOp:
 		size := int(fusekernel.EntryOutSize(c.protocol))
 		out := (*fusekernel.EntryOut)(m.Grow(size))
-		convertChildInodeEntry(&o.Entry, out)
+		convertChildInodeEntry(&o.Entry, out, now)
 
 	case *fuseops.CreateFileOp:
 		eSize := int(fusekernel.EntryOutSize(c.protocol))
 
 		e := (*fusekernel.EntryOut)(m.Grow(eSize))
-		convertChildInodeEntry(&o.Entry, e)
+		convertChildInodeEntry(&o.Entry, e, now)
+
+		oo := (*fusekernel.OpenOut)(m.Grow(int(unsafe.Sizeof(fusekernel.OpenOut{}))))
+		oo.Fh = uint64(o.Handle)
+		setBackingID(oo, o.BackingID)
+
+	case *fuseops.CreateTmpfileOp:
+		eSize := int(fusekernel.EntryOutSize(c.protocol))
+
+		e := (*fusekernel.EntryOut)(m.Grow(eSize))
+		convertChildInodeEntry(&o.Entry, e, now)
 
 		oo := (*fusekernel.OpenOut)(m.Grow(int(unsafe.Sizeof(fusekernel.OpenOut{}))))
 		oo.Fh = uint64(o.Handle)
@@ -875,12 +1252,12 @@ func (c *Connection) kernelResponseForOp(
 	case *fuseops.CreateSymlinkOp:
 		size := int(fusekernel.EntryOutSize(c.protocol))
 		out := (*fusekernel.EntryOut)(m.Grow(size))
-		convertChildInodeEntry(&o.Entry, out)
+		convertChildInodeEntry(&o.Entry, out, now)
 
 	case *fuseops.CreateLinkOp:
 		size := int(fusekernel.EntryOutSize(c.protocol))
 		out := (*fusekernel.EntryOut)(m.Grow(size))
-		convertChildInodeEntry(&o.Entry, out)
+		convertChildInodeEntry(&o.Entry, out, now)
 
 	case *fuseops.RenameOp:
 		// Empty response
@@ -891,6 +1268,9 @@ func (c *Connection) kernelResponseForOp(
 	case *fuseops.UnlinkOp:
 		// Empty response
 
+	case *fuseops.AccessOp:
+		// Empty response
+
 	case *fuseops.OpenDirOp:
 		out := (*fusekernel.OpenOut)(m.Grow(int(unsafe.Sizeof(fusekernel.OpenOut{}))))
 		out.Fh = uint64(o.Handle)
@@ -930,6 +1310,21 @@ func (c *Connection) kernelResponseForOp(
 			out.OpenFlags |= uint32(fusekernel.OpenDirectIO)
 		}
 
+		if o.NonSeekable {
+			out.OpenFlags |= uint32(fusekernel.OpenNonSeekable)
+		}
+
+		if o.Stream {
+			out.OpenFlags |= uint32(fusekernel.OpenStream)
+		}
+
+		if o.ParallelDirectWrites {
+			flag := fusekernel.OpenParallelDirectWrites.ForProtocol(c.protocol)
+			out.OpenFlags |= uint32(flag)
+		}
+
+		setBackingID(out, o.BackingID)
+
 	case *fuseops.ReadFileOp:
 		if o.Data != nil {
 			m.Append(o.Data...)
@@ -1017,9 +1412,64 @@ func (c *Connection) kernelResponseForOp(
 	case *fuseops.FallocateOp:
 		// Empty response
 
+	case *fuseops.BmapOp:
+		out := (*fusekernel.BmapOut)(m.Grow(int(unsafe.Sizeof(fusekernel.BmapOut{}))))
+		out.Block = o.DeviceBlock
+
 	case *fuseops.SyncFSOp:
 		// Empty response
 
+	case *fuseops.SetupMappingOp:
+		// Empty response
+
+	case *fuseops.RemoveMappingOp:
+		// Empty response
+
+	case *fuseops.IoctlOp:
+		out := (*fusekernel.IoctlOut)(m.Grow(int(unsafe.Sizeof(fusekernel.IoctlOut{}))))
+		if len(o.RetryInput) == 0 && len(o.RetryOutput) == 0 {
+			out.Result = o.Result
+			m.Append(o.Output[:min(len(o.Output), o.OutputSize)])
+			break
+		}
+
+		// Ask the kernel to send the op again with the regions of the caller's
+		// memory, listed input first.
+		out.Flags = fusekernel.IoctlRetry
+		out.InIovs = uint32(len(o.RetryInput))
+		out.OutIovs = uint32(len(o.RetryOutput))
+		for _, regions := range [][]fuseops.IoctlRegion{o.RetryInput, o.RetryOutput} {
+			for _, r := range regions {
+				iov := (*fusekernel.IoctlIovec)(m.Grow(int(unsafe.Sizeof(fusekernel.IoctlIovec{}))))
+				iov.Base = r.Addr
+				iov.Len = r.Len
+			}
+		}
+
+	case *fuseops.CopyFileRangeOp:
+		out := (*fusekernel.WriteOut)(m.Grow(int(unsafe.Sizeof(fusekernel.WriteOut{}))))
+		out.Size = uint32(o.BytesCopied)
+
+	case *fuseops.LseekOp:
+		out := (*fusekernel.LseekOut)(m.Grow(int(unsafe.Sizeof(fusekernel.LseekOut{}))))
+		out.Offset = uint64(o.NewOffset)
+
+	case *fuseops.GetLkOp:
+		out := (*fusekernel.LkOut)(m.Grow(int(unsafe.Sizeof(fusekernel.LkOut{}))))
+		out.Lk = fusekernel.FileLock{
+			Start: o.Conflict.Start,
+			End:   o.Conflict.End,
+			Type:  o.Conflict.Type,
+			Pid:   o.Conflict.Pid,
+		}
+
+	case *fuseops.SetLkOp, *fuseops.SetLkWOp:
+		// Empty response
+
+	case *fuseops.PollOp:
+		out := (*fusekernel.PollOut)(m.Grow(int(unsafe.Sizeof(fusekernel.PollOut{}))))
+		out.Revents = o.Revents
+
 	case *initOp:
 		out := (*fusekernel.InitOut)(m.Grow(int(unsafe.Sizeof(fusekernel.InitOut{}))))
 
@@ -1027,12 +1477,13 @@ func (c *Connection) kernelResponseForOp(
 		out.Minor = o.Library.Minor
 		out.MaxReadahead = o.MaxReadahead
 		out.Flags = uint32(o.Flags)
-		// Default values
-		out.MaxBackground = 12
-		out.CongestionThreshold = 9
+		out.MaxBackground = o.MaxBackground
+		out.CongestionThreshold = o.CongestionThreshold
 		out.MaxWrite = o.MaxWrite
 		out.TimeGran = 1
 		out.MaxPages = o.MaxPages
+		out.Flags2 = uint32(o.Flags2)
+		out.MaxStackDepth = o.MaxStackDepth
 
 	default:
 		panic(fmt.Sprintf("Unexpected op: %#v", op))
@@ -1045,6 +1496,14 @@ func (c *Connection) kernelResponseForOp(
 // General conversions
 ////////////////////////////////////////////////////////////////////////
 
+// Open the file in passthrough mode if a backing file was supplied.
+func setBackingID(out *fusekernel.OpenOut, id fuseops.BackingID) {
+	if id != 0 {
+		out.OpenFlags |= uint32(fusekernel.OpenPassthrough)
+		out.BackingID = int32(id)
+	}
+}
+
 func convertTime(t time.Time) (secs uint64, nsec uint32) {
 	totalNano := t.UnixNano()
 	secs = uint64(totalNano / 1e9)
@@ -1052,6 +1511,19 @@ func convertTime(t time.Time) (secs uint64, nsec uint32) {
 	return secs, nsec
 }
 
+// Return the attributes to report for the supplied inode, applying
+// MountConfig.RootAttributes to those of the root.
+func (c *Connection) attributes(
+	inodeID fuseops.InodeID,
+	in *fuseops.InodeAttributes) *fuseops.InodeAttributes {
+	if inodeID != fuseops.RootInodeID || c.cfg.RootAttributes == nil {
+		return in
+	}
+
+	attrs := c.cfg.RootAttributes.apply(*in)
+	return &attrs
+}
+
 func convertAttributes(
 	inodeID fuseops.InodeID,
 	in *fuseops.InodeAttributes,
@@ -1074,17 +1546,78 @@ func convertAttributes(
 	if out.Mode&(syscall.S_IFCHR|syscall.S_IFBLK) != 0 {
 		out.Rdev = in.Rdev
 	}
+
+	if in.Submount {
+		out.SetSubmount()
+	}
+}
+
+// The STATX_* fields always returned for StatxOp: those of stat(2)
+// (STATX_BASIC_STATS), and the birth time (STATX_BTIME) if known.
+const (
+	statxBasicStats = 0x7ff
+	statxBtime      = 0x800
+)
+
+func convertStatxTime(t time.Time) fusekernel.SxTime {
+	secs, nsec := convertTime(t)
+	return fusekernel.SxTime{Sec: int64(secs), Nsec: nsec}
+}
+
+func convertStatx(
+	op *fuseops.StatxOp,
+	in *fuseops.InodeAttributes,
+	out *fusekernel.Statx) {
+	out.Mask = statxBasicStats
+	out.Attributes = op.StatxAttributes
+	out.AttributesMask = op.StatxAttributesMask
+	out.Ino = uint64(op.Inode)
+	out.Size = in.Size
+	out.Blocks = (in.Size + 512 - 1) / 512
+	out.Atime = convertStatxTime(in.Atime)
+	out.Mtime = convertStatxTime(in.Mtime)
+	out.Ctime = convertStatxTime(in.Ctime)
+	if !in.Crtime.IsZero() {
+		out.Mask |= statxBtime
+		out.Btime = convertStatxTime(in.Crtime)
+	}
+
+	out.Nlink = in.Nlink
+	out.Uid = in.Uid
+	out.Gid = in.Gid
+
+	mode := ConvertGoMode(in.Mode)
+	out.Mode = uint16(mode)
+
+	// Split the device number as Linux's new_decode_dev does, which is how
+	// the kernel reads the Rdev of GetInodeAttributesOp.
+	if t := mode & syscall.S_IFMT; t == syscall.S_IFCHR || t == syscall.S_IFBLK {
+		out.RdevMajor = (in.Rdev & 0xfff00) >> 8
+		out.RdevMinor = (in.Rdev & 0xff) | ((in.Rdev >> 12) & 0xfff00)
+	}
 }
 
 // Convert an absolute cache expiration time to a relative time from now for
 // consumption by the fuse kernel module.
 func ConvertExpirationTime(t time.Time) (secs uint64, nsecs uint32) {
+	return ConvertExpirationTimeAt(t, time.Now())
+}
+
+// Like ConvertExpirationTime, but relative to the supplied time rather than
+// the real current time.
+//
+// If both times came from time.Now, the difference is taken using their
+// monotonic clock readings, so a jump in the wall clock between the two
+// doesn't change the result.
+func ConvertExpirationTimeAt(
+	t time.Time,
+	now time.Time) (secs uint64, nsecs uint32) {
 	// Fuse represents durations as unsigned 64-bit counts of seconds and 32-bit
 	// counts of nanoseconds (https://tinyurl.com/4muvkr6k). So negative
 	// durations are right out. There is no need to cap the positive magnitude,
 	// because 2^64 seconds is well longer than the 2^63 ns range of
 	// time.Duration.
-	d := t.Sub(time.Now())
+	d := t.Sub(now)
 	if d > 0 {
 		secs = uint64(d / time.Second)
 		nsecs = uint32((d % time.Second) / time.Nanosecond)
@@ -1095,11 +1628,12 @@ func ConvertExpirationTime(t time.Time) (secs uint64, nsecs uint32) {
 
 func convertChildInodeEntry(
 	in *fuseops.ChildInodeEntry,
-	out *fusekernel.EntryOut) {
+	out *fusekernel.EntryOut,
+	now time.Time) {
 	out.Nodeid = uint64(in.Child)
 	out.Generation = uint64(in.Generation)
-	out.EntryValid, out.EntryValidNsec = ConvertExpirationTime(in.EntryExpiration)
-	out.AttrValid, out.AttrValidNsec = ConvertExpirationTime(in.AttributesExpiration)
+	out.EntryValid, out.EntryValidNsec = ConvertExpirationTimeAt(in.EntryExpiration, now)
+	out.AttrValid, out.AttrValidNsec = ConvertExpirationTimeAt(in.AttributesExpiration, now)
 
 	convertAttributes(in.Child, &in.Attributes, &out.Attr)
 }
@@ -0,0 +1,200 @@
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/buffer"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"github.com/jacobsa/timeutil"
+)
+
+func TestExpirationUsesConfiguredClock(t *testing.T) {
+	var clock timeutil.SimulatedClock
+	clock.SetTime(time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC))
+
+	c := &Connection{
+		cfg:      MountConfig{Clock: &clock},
+		protocol: fusekernel.Protocol{Major: 7, Minor: 31},
+	}
+
+	op := &fuseops.LookUpInodeOp{
+		Entry: fuseops.ChildInodeEntry{
+			Child:                17,
+			EntryExpiration:      clock.Now().Add(10*time.Second + 5),
+			AttributesExpiration: clock.Now().Add(-time.Second),
+		},
+	}
+
+	var m buffer.OutMessage
+	m.Reset()
+	c.kernelResponseForOp(&m, op)
+
+	seg := m.Sglist[len(m.Sglist)-1]
+	out := (*fusekernel.EntryOut)(unsafe.Pointer(&seg[0]))
+	if out.EntryValid != 10 || out.EntryValidNsec != 5 {
+		t.Errorf("entry valid: got %d.%09d, want 10.000000005", out.EntryValid, out.EntryValidNsec)
+	}
+
+	// Expirations in the past mean no caching at all.
+	if out.AttrValid != 0 || out.AttrValidNsec != 0 {
+		t.Errorf("attr valid: got %d.%09d, want 0", out.AttrValid, out.AttrValidNsec)
+	}
+}
+
+func TestRootAttributes(t *testing.T) {
+	mode := os.FileMode(0750)
+	uid := uint32(1234)
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	c := &Connection{
+		cfg: MountConfig{
+			RootAttributes: &RootAttributes{
+				Mode:  &mode,
+				Uid:   &uid,
+				Mtime: &mtime,
+				Func:  func(attrs *fuseops.InodeAttributes) { attrs.Nlink = 7 },
+			},
+		},
+		protocol: fusekernel.Protocol{Major: 7, Minor: 31},
+	}
+
+	getAttr := func(inode fuseops.InodeID) *fusekernel.Attr {
+		op := &fuseops.GetInodeAttributesOp{
+			Inode: inode,
+			Attributes: fuseops.InodeAttributes{
+				Mode: os.ModeDir | 0777,
+				Uid:  1,
+				Gid:  2,
+			},
+		}
+
+		var m buffer.OutMessage
+		m.Reset()
+		c.kernelResponseForOp(&m, op)
+
+		// The op itself is unchanged.
+		if op.Attributes.Uid != 1 {
+			t.Errorf("op modified: %+v", op.Attributes)
+		}
+
+		seg := m.Sglist[len(m.Sglist)-1]
+		return &(*fusekernel.AttrOut)(unsafe.Pointer(&seg[0])).Attr
+	}
+
+	attr := getAttr(fuseops.RootInodeID)
+	if attr.Mode != syscall.S_IFDIR|0750 || attr.Uid != 1234 || attr.Gid != 2 {
+		t.Errorf("root: mode %o, uid %d, gid %d", attr.Mode, attr.Uid, attr.Gid)
+	}
+
+	if attr.Mtime != uint64(mtime.Unix()) || attr.Nlink != 7 {
+		t.Errorf("root: mtime %d, nlink %d", attr.Mtime, attr.Nlink)
+	}
+
+	// Other inodes are unaffected.
+	attr = getAttr(17)
+	if attr.Mode != syscall.S_IFDIR|0777 || attr.Uid != 1 || attr.Nlink != 0 {
+		t.Errorf("other: mode %o, uid %d, nlink %d", attr.Mode, attr.Uid, attr.Nlink)
+	}
+}
+
+func TestSetattrCtime(t *testing.T) {
+	ctime := time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+
+	// A setattr as sent after writing back dirty pages, with the times the
+	// kernel has kept.
+	var msg struct {
+		header fusekernel.InHeader
+		in     fusekernel.SetattrIn
+	}
+
+	msg.header.Len = uint32(unsafe.Sizeof(msg))
+	msg.header.Opcode = uint32(fusekernel.OpSetattr)
+	msg.header.Nodeid = 17
+	msg.in.Valid = uint32(fusekernel.SetattrMtime | fusekernel.SetattrCtime)
+	msg.in.Ctime = uint64(ctime.Unix())
+	msg.in.CtimeNsec = uint32(ctime.Nanosecond())
+
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&msg)), unsafe.Sizeof(msg))
+	inMsg := buffer.NewInMessage()
+	if err := inMsg.Init(bytes.NewReader(b)); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	var outMsg buffer.OutMessage
+	o, err := convertInMessage(
+		&MountConfig{},
+		inMsg,
+		&outMsg,
+		fusekernel.Protocol{Major: 7, Minor: 31},
+		nil)
+
+	if err != nil {
+		t.Fatalf("convertInMessage: %v", err)
+	}
+
+	op := o.(*fuseops.SetInodeAttributesOp)
+	if op.Mtime == nil || op.Size != nil || op.Mode != nil {
+		t.Errorf("Unexpected fields: %+v", op)
+	}
+
+	if op.Ctime == nil || !op.Ctime.Equal(ctime) {
+		t.Errorf("Ctime: got %v, want %v", op.Ctime, ctime)
+	}
+}
+
+func TestRemoveMapping(t *testing.T) {
+	header := fusekernel.InHeader{
+		Opcode: uint32(fusekernel.OpRemoveMapping),
+		Nodeid: 17,
+	}
+
+	// The entries directly follow the 4-byte count, unaligned.
+	mappings := []fusekernel.RemoveMappingOne{
+		{Moffset: 0, Len: 2 << 20},
+		{Moffset: 8 << 20, Len: 2 << 20},
+	}
+
+	header.Len = uint32(unsafe.Sizeof(header)) + 4 + uint32(len(mappings))*16
+	var b []byte
+	b = append(b, unsafe.Slice((*byte)(unsafe.Pointer(&header)), unsafe.Sizeof(header))...)
+	b = binary.NativeEndian.AppendUint32(b, uint32(len(mappings)))
+	for _, m := range mappings {
+		b = binary.NativeEndian.AppendUint64(b, m.Moffset)
+		b = binary.NativeEndian.AppendUint64(b, m.Len)
+	}
+
+	inMsg := buffer.NewInMessage()
+	if err := inMsg.Init(bytes.NewReader(b)); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	var outMsg buffer.OutMessage
+	o, err := convertInMessage(
+		&MountConfig{},
+		inMsg,
+		&outMsg,
+		fusekernel.Protocol{Major: 7, Minor: 31},
+		nil)
+
+	if err != nil {
+		t.Fatalf("convertInMessage: %v", err)
+	}
+
+	op := o.(*fuseops.RemoveMappingOp)
+	want := []fuseops.RemoveMappingEntry{
+		{MappingOffset: 0, Length: 2 << 20},
+		{MappingOffset: 8 << 20, Length: 2 << 20},
+	}
+
+	if op.Inode != 17 || !reflect.DeepEqual(op.Mappings, want) {
+		t.Errorf("Got %+v, want mappings %+v", op, want)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"syscall"
 
 	"github.com/jacobsa/fuse/fuseops"
 )
@@ -86,11 +87,41 @@ func describeRequest(op interface{}) (s string) {
 			addComponent("mtime %v", *typed.Mtime)
 		}
 
+		if typed.Ctime != nil {
+			addComponent("ctime %v", *typed.Ctime)
+		}
+
+	case *fuseops.StatxOp:
+		if typed.Handle != nil {
+			addComponent("handle %d", *typed.Handle)
+		}
+
+		addComponent("mask %#x", typed.Mask)
+
+	case *fuseops.AccessOp:
+		addComponent("mask %#o", typed.Mask)
+
+	case *fuseops.BmapOp:
+		addComponent("block %d of %d bytes", typed.Block, typed.BlockSize)
+
+	case *fuseops.SetupMappingOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("offset %d", typed.Offset)
+		addComponent("%d bytes", typed.Length)
+		addComponent("at %d", typed.MappingOffset)
+		addComponent("flags %#x", uint64(typed.Flags))
+
+	case *fuseops.RemoveMappingOp:
+		addComponent("%d mappings", len(typed.Mappings))
+
 	case *fuseops.RenameOp:
 		addComponent("old_parent %v", typed.OldParent)
 		addComponent("old_name %q", typed.OldName)
 		addComponent("new_parent %v", typed.NewParent)
 		addComponent("new_name %q", typed.NewName)
+		if typed.Flags != 0 {
+			addComponent("flags %v", typed.Flags)
+		}
 
 	case *fuseops.ReadFileOp:
 		addComponent("handle %d", typed.Handle)
@@ -114,10 +145,59 @@ func describeRequest(op interface{}) (s string) {
 	case *fuseops.FallocateOp:
 		addComponent("offset %d", typed.Offset)
 		addComponent("length %d", typed.Length)
-		addComponent("mode %d", typed.Mode)
+		addComponent("mode %v", typed.Mode)
 
 	case *fuseops.ReleaseFileHandleOp:
 		addComponent("handle %d", typed.Handle)
+
+	case *fuseops.IoctlOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("cmd 0x%08x", typed.Cmd)
+		if typed.Unrestricted {
+			addComponent("unrestricted")
+		}
+
+	case *fuseops.LseekOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("offset %d", typed.Offset)
+		addComponent("whence %d", typed.Whence)
+
+	case *fuseops.GetLkOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("owner 0x%x", typed.Owner)
+		addComponent("lock %s", describeLock(typed.Lock))
+
+	case *fuseops.SetLkOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("owner 0x%x", typed.Owner)
+		addComponent("lock %s", describeLock(typed.Lock))
+		if typed.Flock {
+			addComponent("flock")
+		}
+
+	case *fuseops.SetLkWOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("owner 0x%x", typed.Owner)
+		addComponent("lock %s", describeLock(typed.Lock))
+		if typed.Flock {
+			addComponent("flock")
+		}
+
+	case *fuseops.PollOp:
+		addComponent("handle %d", typed.Handle)
+		addComponent("events 0x%x", typed.Events)
+		if typed.PollHandle != 0 {
+			addComponent("kh %d", typed.PollHandle)
+		}
+
+	case *fuseops.CopyFileRangeOp:
+		addComponent("src_inode %v", typed.SrcInode)
+		addComponent("src_handle %d", typed.SrcHandle)
+		addComponent("src_offset %d", typed.SrcOffset)
+		addComponent("dst_inode %v", typed.DstInode)
+		addComponent("dst_handle %d", typed.DstHandle)
+		addComponent("dst_offset %d", typed.DstOffset)
+		addComponent("%d bytes", typed.Length)
 	}
 
 	// Use just the name if there is no extra info.
@@ -147,7 +227,25 @@ func describeResponse(op interface{}) string {
 	switch typed := op.(type) {
 	case *fuseops.OpenFileOp:
 		addComponent("handle %d", typed.Handle)
+
+	case *fuseops.GetLkOp:
+		addComponent("conflict %s", describeLock(typed.Conflict))
 	}
 
 	return fmt.Sprintf("%s (%s)", opName(op), strings.Join(components, ", "))
 }
+
+// Describe a lock, e.g. "F_WRLCK [0, 99] pid 17".
+func describeLock(l fuseops.FileLock) string {
+	t := fmt.Sprintf("type %d", l.Type)
+	switch l.Type {
+	case syscall.F_RDLCK:
+		t = "F_RDLCK"
+	case syscall.F_WRLCK:
+		t = "F_WRLCK"
+	case syscall.F_UNLCK:
+		t = "F_UNLCK"
+	}
+
+	return fmt.Sprintf("%s [%d, %d] pid %d", t, l.Start, l.End, l.Pid)
+}
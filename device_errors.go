@@ -0,0 +1,115 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// DeviceErrorPolicy controls how a connection handles errors reading requests
+// from and writing replies to the fuse device. The zero value retries reads
+// that fail with EINTR indefinitely and treats every other error as fatal.
+//
+// A fatal read error is returned from Connection.ReadOp, which normally ends
+// the server's loop; a fatal write error is returned from Connection.Reply.
+// ENODEV, which means the file system has been unmounted, is never retried and
+// is reported from ReadOp as io.EOF. A write failing with it, or with ENOENT
+// once the connection has been aborted, drops the reply without an error; see
+// Connection.DroppedReplies.
+type DeviceErrorPolicy struct {
+	// The errnos after which a read or write is retried. If nil, only EINTR is
+	// retried. Adding EAGAIN is useful if the device was opened in
+	// non-blocking mode, e.g. when it is passed in by a parent process; in that
+	// case Backoff should be non-zero to avoid spinning.
+	RetryErrnos []syscall.Errno
+
+	// The maximum number of consecutive retries of a single read or write,
+	// after which the error is treated as fatal. If zero, there is no limit.
+	MaxRetries int
+
+	// How long to wait before the first retry. Each consecutive retry waits
+	// twice as long as the previous one, up to MaxBackoff (if non-zero). If
+	// zero, retries are immediate.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// If non-nil, called for every error reading from or writing to the device
+	// other than those above, whether or not it is retried. Must not block.
+	OnError func(DeviceErrorEvent)
+}
+
+// DeviceErrorEvent describes an error reading from or writing to the fuse
+// device. See DeviceErrorPolicy.OnError.
+type DeviceErrorEvent struct {
+	// Whether the error came from a write, rather than a read.
+	Write bool
+
+	// The error, and the number of consecutive times the read or write has
+	// failed, including this one.
+	Err     error
+	Attempt int
+
+	// Whether the read or write will be retried.
+	Retrying bool
+}
+
+// Decide whether to retry after a read or write from the device has failed for
+// the attempt'th consecutive time, sleeping before returning true as
+// configured.
+func (c *Connection) retryDeviceError(write bool, err error, attempt int) bool {
+	p := &c.cfg.DeviceErrorPolicy
+
+	var errno syscall.Errno
+	retry := false
+	if errors.As(err, &errno) {
+		if p.RetryErrnos == nil {
+			retry = errno == syscall.EINTR
+		} else {
+			for _, e := range p.RetryErrnos {
+				retry = retry || errno == e
+			}
+		}
+	}
+
+	if p.MaxRetries != 0 && attempt > p.MaxRetries {
+		retry = false
+	}
+
+	if p.OnError != nil {
+		p.OnError(DeviceErrorEvent{
+			Write:    write,
+			Err:      err,
+			Attempt:  attempt,
+			Retrying: retry,
+		})
+	}
+
+	if retry && p.Backoff != 0 {
+		d := p.Backoff
+		for i := 1; i < attempt && d < time.Hour; i++ {
+			d *= 2
+		}
+
+		if p.MaxBackoff != 0 && d > p.MaxBackoff {
+			d = p.MaxBackoff
+		}
+
+		time.Sleep(d)
+	}
+
+	return retry
+}
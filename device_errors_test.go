@@ -0,0 +1,60 @@
+package fuse
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRetryDeviceError(t *testing.T) {
+	readErr := func(errno syscall.Errno) error {
+		return &os.PathError{Op: "read", Path: "/dev/fuse", Err: errno}
+	}
+
+	// By default only EINTR is retried, indefinitely.
+	c := &Connection{}
+	if !c.retryDeviceError(false, readErr(syscall.EINTR), 1000) {
+		t.Errorf("EINTR not retried by default")
+	}
+
+	if c.retryDeviceError(false, readErr(syscall.EAGAIN), 1) {
+		t.Errorf("EAGAIN retried by default")
+	}
+
+	if c.retryDeviceError(true, errors.New("taco"), 1) {
+		t.Errorf("non-errno error retried")
+	}
+
+	// A custom policy, with events.
+	var events []DeviceErrorEvent
+	c.cfg.DeviceErrorPolicy = DeviceErrorPolicy{
+		RetryErrnos: []syscall.Errno{syscall.EAGAIN},
+		MaxRetries:  2,
+		OnError:     func(e DeviceErrorEvent) { events = append(events, e) },
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		got := c.retryDeviceError(true, syscall.EAGAIN, attempt)
+		if want := attempt <= 2; got != want {
+			t.Errorf("attempt %d: got %v, want %v", attempt, got, want)
+		}
+	}
+
+	if c.retryDeviceError(false, readErr(syscall.EINTR), 1) {
+		t.Errorf("EINTR retried despite not being listed")
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+
+	e := events[2]
+	if !e.Write || e.Err != syscall.EAGAIN || e.Attempt != 3 || e.Retrying {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	if !events[0].Retrying || events[3].Write {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,131 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A dioFS whose file is opened with direct I/O.
+type mmapFS struct {
+	dioFS
+}
+
+func (fs *mmapFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	op.UseDirectIO = true
+	op.KeepPageCache = true
+	return nil
+}
+
+func mountMmap(t *testing.T, cfg *fuse.MountConfig) *fuse.MountedFileSystem {
+	mfs, err := fuse.Mount(t.TempDir(), fuseutil.NewFileSystemServer(&mmapFS{}), cfg)
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	return mfs
+}
+
+// Map the start of the file shared, returning the error from mmap(2).
+func mmapShared(t *testing.T, mfs *fuse.MountedFileSystem) error {
+	f, err := os.Open(path.Join(mfs.Dir(), "foo"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	m, err := syscall.Mmap(int(f.Fd()), 0, 4096, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	defer syscall.Munmap(m)
+
+	if m[0] != 0 {
+		t.Errorf("Mapped byte: got %d, want 0", m[0])
+	}
+
+	return nil
+}
+
+func TestDirectIOMmap(t *testing.T) {
+	// By default, shared mappings fail.
+	mfs := mountMmap(t, &fuse.MountConfig{})
+	if err := mmapShared(t, mfs); !errors.Is(err, syscall.ENODEV) {
+		t.Errorf("Mmap: got %v, want ENODEV", err)
+	}
+
+	mfs = mountMmap(t, &fuse.MountConfig{EnableDirectIOMmap: true})
+	features := mfs.KernelInfo().Features
+	if !slices.Contains(features, "DIRECT_IO_ALLOW_MMAP") {
+		t.Skipf("Kernel doesn't support DIRECT_IO_ALLOW_MMAP: %v", features)
+	}
+
+	if err := mmapShared(t, mfs); err != nil {
+		t.Errorf("Mmap with EnableDirectIOMmap: %v", err)
+	}
+}
+
+func TestDirectIOFallback(t *testing.T) {
+	var mu sync.Mutex
+	var asked []fuseops.InodeID
+	fallback := func(op *fuseops.OpenFileOp) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		asked = append(asked, op.Inode)
+		return true
+	}
+
+	// The handle is opened in cached mode, and can be mapped.
+	mfs := mountMmap(t, &fuse.MountConfig{DirectIOFallback: fallback})
+	if err := mmapShared(t, mfs); err != nil {
+		t.Errorf("Mmap with DirectIOFallback: %v", err)
+	}
+
+	mu.Lock()
+	if len(asked) != 1 || asked[0] != fuseops.RootInodeID+1 {
+		t.Errorf("DirectIOFallback called for %v", asked)
+	}
+	asked = nil
+	mu.Unlock()
+
+	// There's no need for it if the kernel allows mapping.
+	mfs = mountMmap(t, &fuse.MountConfig{
+		EnableDirectIOMmap: true,
+		DirectIOFallback:   fallback,
+	})
+
+	if !slices.Contains(mfs.KernelInfo().Features, "DIRECT_IO_ALLOW_MMAP") {
+		return
+	}
+
+	if err := mmapShared(t, mfs); err != nil {
+		t.Errorf("Mmap: %v", err)
+	}
+
+	mu.Lock()
+	if len(asked) != 0 {
+		t.Errorf("DirectIOFallback called for %v", asked)
+	}
+	mu.Unlock()
+}
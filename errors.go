@@ -14,7 +14,15 @@
 
 package fuse
 
-import "syscall"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
 
 const (
 	// Errors corresponding to kernel error numbers. These may be treated
@@ -27,4 +35,117 @@ const (
 	ENOSYS    = syscall.ENOSYS
 	ENOTDIR   = syscall.ENOTDIR
 	ENOTEMPTY = syscall.ENOTEMPTY
+	ESTALE    = syscall.ESTALE
 )
+
+// StaleError reports that an inode the kernel knows about no longer exists on
+// the backing store, typically because another client of a network file
+// system deleted or replaced it. It is reported to the kernel as ESTALE.
+//
+// Returning ESTALE alone is often not enough: if the kernel has cached the
+// entry that led to the stale inode, every later attempt to use the name will
+// find the same inode and fail the same way until the entry expires. So once
+// the reply has been sent, the connection also invalidates the kernel's
+// attributes and cached data for Inode and, if Name is non-empty, the entry
+// for Name in Parent. The next access to the name then looks it up afresh.
+// (Some system calls, such as stat, are retried by the kernel with a fresh
+// lookup when they fail with ESTALE; others report it to the caller, who can
+// simply retry.)
+//
+// The invalidations are sent asynchronously, and any error sending them is
+// ignored.
+type StaleError struct {
+	// The stale inode, or zero for none.
+	Inode fuseops.InodeID
+
+	// The entry leading to the stale inode, if known.
+	Parent fuseops.InodeID
+	Name   string
+
+	// The underlying error, if any.
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	msg := fmt.Sprintf("stale inode %d", e.Inode)
+	if e.Name != "" {
+		msg += fmt.Sprintf(" (entry %q in %d)", e.Name, e.Parent)
+	}
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// Errno returns the errno with which an error returned by the file system is
+// reported to the kernel. It is the first of the following that applies:
+//
+//   - ESTALE for a *StaleError, found with errors.As.
+//
+//   - The syscall.Errno wrapped by err, found with errors.As. This covers
+//     errors returned by the os package, such as *os.PathError.
+//
+//   - The errno for the first entry of MountConfig.ErrnoMappings whose Err
+//     matches according to errors.Is.
+//
+//   - ETIMEDOUT for context.DeadlineExceeded and EINTR for context.Canceled.
+//
+//   - ENOENT, EACCES, EEXIST, EINVAL, or EBADF for the corresponding io/fs
+//     sentinel errors (fs.ErrNotExist, etc.).
+//
+//   - MountConfig.TimeoutErrno for errors with a Timeout method returning true.
+//
+//   - EIO.
+func (c *Connection) Errno(err error) syscall.Errno {
+	var stale *StaleError
+	if errors.As(err, &stale) {
+		return ESTALE
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	}
+
+	for _, m := range c.cfg.ErrnoMappings {
+		if errors.Is(err, m.Err) {
+			return m.Errno
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return syscall.ETIMEDOUT
+
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+
+	case errors.Is(err, fs.ErrNotExist):
+		return syscall.ENOENT
+
+	case errors.Is(err, fs.ErrPermission):
+		return syscall.EACCES
+
+	case errors.Is(err, fs.ErrExist):
+		return syscall.EEXIST
+
+	case errors.Is(err, fs.ErrInvalid):
+		return syscall.EINVAL
+
+	case errors.Is(err, fs.ErrClosed):
+		return syscall.EBADF
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() && c.cfg.TimeoutErrno != 0 {
+		return c.cfg.TimeoutErrno
+	}
+
+	return EIO
+}
@@ -0,0 +1,62 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrno(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+
+	c := &Connection{
+		cfg: MountConfig{
+			ErrnoMappings: []ErrnoMapping{
+				{Err: errQuota, Errno: syscall.EDQUOT},
+			},
+		},
+	}
+
+	testCases := []struct {
+		err  error
+		want syscall.Errno
+	}{
+		{syscall.ENOTDIR, syscall.ENOTDIR},
+		{fmt.Errorf("wrapped: %w", syscall.EROFS), syscall.EROFS},
+		{&os.PathError{Op: "open", Path: "foo", Err: syscall.ENOENT}, syscall.ENOENT},
+		{fmt.Errorf("backend: %w", errQuota), syscall.EDQUOT},
+		{context.DeadlineExceeded, syscall.ETIMEDOUT},
+		{fmt.Errorf("fetch: %w", context.Canceled), syscall.EINTR},
+		{fs.ErrNotExist, syscall.ENOENT},
+		{fmt.Errorf("stat: %w", fs.ErrPermission), syscall.EACCES},
+		{fs.ErrExist, syscall.EEXIST},
+		{fs.ErrInvalid, syscall.EINVAL},
+		{fs.ErrClosed, syscall.EBADF},
+		{&StaleError{Inode: 2, Err: syscall.ENOENT}, syscall.ESTALE},
+		{fmt.Errorf("read: %w", &StaleError{Inode: 2}), syscall.ESTALE},
+		{timeoutError{}, syscall.EIO},
+		{errors.New("taco"), syscall.EIO},
+	}
+
+	for _, tc := range testCases {
+		if got := c.Errno(tc.err); got != tc.want {
+			t.Errorf("Errno(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+
+	// The errno used for timeouts is configurable.
+	c.cfg.TimeoutErrno = syscall.EAGAIN
+	if got := c.Errno(fmt.Errorf("dial: %w", timeoutError{})); got != syscall.EAGAIN {
+		t.Errorf("got %v, want EAGAIN", got)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// ExitCause says why a connection stopped being served, so that a daemon can
+// decide e.g. whether to mount the file system again. See
+// MountedFileSystem.Cause.
+type ExitCause int
+
+const (
+	// The connection is still being served.
+	ExitRunning ExitCause = iota
+
+	// The file system was unmounted, e.g. with umount(8) or fusermount -u.
+	ExitUnmounted
+
+	// The connection was aborted from outside the daemon, by writing to the
+	// abort file under /sys/fs/fuse/connections. The mount point remains, and
+	// fails every access with ENOTCONN until it is unmounted. Before Linux
+	// 4.20, and on other platforms, an abort can't be told apart from an
+	// unmount.
+	//
+	// As with an unmount, ReadOp returns io.EOF and Join returns nil, so that
+	// code written before aborts were reported sees no difference; only the
+	// cause tells them apart.
+	ExitAborted
+
+	// Reading a request from the fuse device failed, other than as above.
+	ExitDeviceError
+
+	// The kernel sent a request that couldn't be decoded, or that the
+	// connection's UnknownOpPolicy says to fail on.
+	ExitProtocolError
+
+	// The server stopped reading requests without the connection failing.
+	ExitStopped
+)
+
+func (c ExitCause) String() string {
+	switch c {
+	case ExitRunning:
+		return "running"
+	case ExitUnmounted:
+		return "unmounted"
+	case ExitAborted:
+		return "aborted"
+	case ExitDeviceError:
+		return "device error"
+	case ExitProtocolError:
+		return "protocol error"
+	case ExitStopped:
+		return "stopped"
+	}
+
+	return fmt.Sprintf("ExitCause(%d)", int(c))
+}
+
+// ExitError is returned by Connection.ReadOp and MountedFileSystem.Join when a
+// connection stops being served for any reason other than the file system
+// being unmounted or the connection aborted.
+type ExitError struct {
+	Cause ExitCause
+	Err   error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Cause, e.Err)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Classify an error returned by readMessage.
+func readErrorCause(err error) ExitCause {
+	var pe *os.PathError
+	switch {
+	case err == io.EOF:
+		return ExitUnmounted
+
+	case errors.Is(err, syscall.ECONNABORTED):
+		return ExitAborted
+
+	case errors.As(err, &pe):
+		return ExitDeviceError
+	}
+
+	// Anything else comes from a malformed message.
+	return ExitProtocolError
+}
+
+// Record the reason for the connection failing, if it is the first, and
+// return the error that ReadOp should return for it.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) exit(cause ExitCause, err error) error {
+	c.mu.Lock()
+	if c.exitCause == ExitRunning {
+		c.exitCause = cause
+		c.exitErr = err
+	}
+	c.mu.Unlock()
+
+	if cause == ExitUnmounted || cause == ExitAborted {
+		return io.EOF
+	}
+
+	return &ExitError{Cause: cause, Err: err}
+}
+
+// Return the reason recorded by exit, or ExitStopped if none was, along with
+// the error that Join should return for it.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) exitStatus() (ExitCause, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.exitCause {
+	case ExitRunning:
+		return ExitStopped, nil
+
+	case ExitUnmounted, ExitAborted:
+		return c.exitCause, nil
+	}
+
+	return c.exitCause, &ExitError{Cause: c.exitCause, Err: c.exitErr}
+}
+
+// Return true if a reply couldn't be written because the kernel has
+// disconnected: it fails writes with ENODEV once the file system has been
+// unmounted, and with ENOENT once the connection has been aborted, having
+// forgotten the requests it was waiting on.
+func replyDropped(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && (errno == syscall.ENODEV || errno == syscall.ENOENT)
+}
+
+// Record a reply dropped because the kernel had disconnected. Ops racing with
+// an unmount are routine, so this is logged for debugging rather than as an
+// error.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) dropReply(fuseID uint64, err error) {
+	c.mu.Lock()
+	c.droppedReplies++
+	c.mu.Unlock()
+
+	c.debugLog(fuseID, 2, "-> Reply dropped after disconnect: %v", err)
+}
+
+// DroppedReplies returns the number of replies that Reply has dropped because
+// the kernel had disconnected, e.g. because the file system was unmounted or
+// the connection aborted while ops were in flight. Reply returns nil for
+// these, since no one is waiting for them.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) DroppedReplies() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.droppedReplies
+}
@@ -0,0 +1,166 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestExitCause(t *testing.T) {
+	// A message shorter than a header is a protocol error.
+	c, peer := newSocketConnection(t, false)
+	if _, err := peer.Write([]byte("taco")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var exitErr *ExitError
+	if _, _, err := c.ReadOp(); !errors.As(err, &exitErr) || exitErr.Cause != ExitProtocolError {
+		t.Errorf("ReadOp: got %v, want protocol error", err)
+	}
+
+	// The first cause sticks.
+	peer.Close()
+	if _, _, err := c.ReadOp(); err != io.EOF {
+		t.Errorf("ReadOp after close: got %v, want EOF", err)
+	}
+
+	if cause, err := c.exitStatus(); cause != ExitProtocolError || !errors.As(err, &exitErr) {
+		t.Errorf("exitStatus: got %v, %v", cause, err)
+	}
+
+	// The peer hanging up is an unmount.
+	c, peer = newSocketConnection(t, false)
+	if cause, err := c.exitStatus(); cause != ExitStopped || err != nil {
+		t.Errorf("exitStatus before failing: got %v, %v", cause, err)
+	}
+
+	peer.Close()
+	if _, _, err := c.ReadOp(); err != io.EOF {
+		t.Errorf("ReadOp: got %v, want EOF", err)
+	}
+
+	if cause, err := c.exitStatus(); cause != ExitUnmounted || err != nil {
+		t.Errorf("exitStatus: got %v, %v", cause, err)
+	}
+}
+
+func TestReadErrorCause(t *testing.T) {
+	pathErr := func(errno syscall.Errno) error {
+		return &os.PathError{Op: "read", Path: "/dev/fuse", Err: errno}
+	}
+
+	testCases := []struct {
+		err  error
+		want ExitCause
+	}{
+		{io.EOF, ExitUnmounted},
+		{pathErr(syscall.ECONNABORTED), ExitAborted},
+		{pathErr(syscall.EIO), ExitDeviceError},
+		{errors.New("Unexpectedly read only 4 bytes."), ExitProtocolError},
+	}
+
+	for _, tc := range testCases {
+		if got := readErrorCause(tc.err); got != tc.want {
+			t.Errorf("readErrorCause(%v): got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+// A server for an empty directory that holds lookups of "slow" until release
+// is closed, replying to ops other than GetInodeAttributes with ENOSYS.
+type slowLookUpServer struct {
+	started chan struct{}
+	release chan struct{}
+	replied chan error
+}
+
+func (s *slowLookUpServer) ServeOps(c *Connection) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		ctx, op, err := c.ReadOp()
+		if err != nil {
+			return
+		}
+
+		if op, ok := op.(*fuseops.LookUpInodeOp); ok && op.Name == "slow" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				close(s.started)
+				<-s.release
+				s.replied <- c.Reply(ctx, ENOENT)
+			}()
+
+			continue
+		}
+
+		if op, ok := op.(*fuseops.GetInodeAttributesOp); ok {
+			op.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0755 | os.ModeDir}
+			c.Reply(ctx, nil)
+			continue
+		}
+
+		c.Reply(ctx, ENOSYS)
+	}
+}
+
+func TestReplyAfterUnmount(t *testing.T) {
+	// Forcing an unmount requires root.
+	if os.Getuid() != 0 {
+		return
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := &slowLookUpServer{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		replied: make(chan error, 1),
+	}
+
+	mfs, err := Mount(dir, s, &MountConfig{})
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	statErr := make(chan error)
+	go func() {
+		_, err := os.Stat(path.Join(dir, "slow"))
+		statErr <- err
+	}()
+
+	// Unmount while the lookup is in flight. The forced unmount disconnects the
+	// kernel, failing the lookup, but the mount point stays busy until then.
+	<-s.started
+	syscall.Unmount(dir, syscall.MNT_FORCE)
+	if err := <-statErr; err == nil {
+		t.Errorf("Stat succeeded")
+	}
+
+	if err := Unmount(dir); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+
+	// The late reply is dropped without an error, and doesn't fail Join.
+	close(s.release)
+	if err := <-s.replied; err != nil {
+		t.Errorf("Reply: %v", err)
+	}
+
+	if err := mfs.Join(ctx); err != nil {
+		t.Errorf("Join: %v", err)
+	}
+
+	if n := mfs.DroppedReplies(); n != 1 {
+		t.Errorf("DroppedReplies: got %d, want 1", n)
+	}
+}
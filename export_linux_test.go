@@ -0,0 +1,353 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"golang.org/x/sys/unix"
+)
+
+// A file system with a directory "dir" containing a file "foo", whose inode
+// IDs are assigned by an InodeTable keyed by path, and which records the
+// names it is asked to look up. If noDots is set, it fails lookups of "." and
+// "..".
+type exportFS struct {
+	fuseutil.NotImplementedFileSystem
+	inodes *fuseutil.InodeTable[string]
+	noDots bool
+
+	mu    sync.Mutex
+	names []string
+}
+
+func (fs *exportFS) attributes(p string) fuseops.InodeAttributes {
+	if p == "/dir/foo" {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0444, Size: 4}
+	}
+
+	return fuseops.InodeAttributes{Nlink: 2, Mode: 0555 | os.ModeDir}
+}
+
+func (fs *exportFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	fs.names = append(fs.names, op.Name)
+	fs.mu.Unlock()
+
+	parent, ok := fs.inodes.Resolve(op.Parent)
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	var p string
+	switch {
+	case fs.noDots && (op.Name == "." || op.Name == ".."):
+		return fuse.ENOENT
+	case op.Name == ".":
+		p = parent
+	case op.Name == ".." && parent == "/dir":
+		p = "/"
+	case op.Name == "dir" && parent == "/":
+		p = "/dir"
+	case op.Name == "foo" && parent == "/dir":
+		p = "/dir/foo"
+	default:
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child, op.Entry.Generation = fs.inodes.Acquire(p)
+	op.Entry.Attributes = fs.attributes(p)
+	return nil
+}
+
+func (fs *exportFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	p, ok := fs.inodes.Resolve(op.Inode)
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	op.Attributes = fs.attributes(p)
+	return nil
+}
+
+func (fs *exportFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.inodes.Forget(op.Inode, op.N)
+	return nil
+}
+
+func (fs *exportFS) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	for _, e := range op.Entries {
+		fs.inodes.Forget(e.Inode, e.N)
+	}
+
+	return nil
+}
+
+func (fs *exportFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *exportFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	if op.Offset == 0 {
+		op.BytesRead = copy(op.Dst, "taco")
+	}
+
+	return nil
+}
+
+func (fs *exportFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	return nil
+}
+
+func (fs *exportFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	p, ok := fs.inodes.Resolve(op.Inode)
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	name := "dir"
+	if p == "/dir" {
+		name = "foo"
+	}
+
+	if op.Offset == 0 {
+		op.BytesRead = fuseutil.WriteDirent(op.Dst, fuseutil.Dirent{
+			Offset: 1,
+			Inode:  fs.inodes.ID(path.Join(p, name)),
+			Name:   name,
+		})
+	}
+
+	return nil
+}
+
+// Return the names looked up since the last call.
+func (fs *exportFS) lookups() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	names := fs.names
+	fs.names = nil
+	return names
+}
+
+// Mount an exportFS. If tracked is set, it doesn't answer lookups of "." and
+// "..", and is wrapped in a ParentTrackingFileSystem that does.
+func mountExport(
+	t *testing.T,
+	enabled bool,
+	tracked bool) (*fuse.MountedFileSystem, *exportFS) {
+	fs := &exportFS{
+		inodes: fuseutil.NewInodeTable("/", fuseutil.InodeTableConfig{}),
+		noDots: tracked,
+	}
+
+	var wrapped fuseutil.FileSystem = fs
+	if tracked {
+		wrapped = fuseutil.NewParentTrackingFileSystem(fs, fuseutil.ParentTrackingConfig{})
+	}
+
+	mfs, err := fuse.Mount(
+		t.TempDir(),
+		fuseutil.NewFileSystemServer(wrapped),
+		&fuse.MountConfig{EnableExportSupport: enabled})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	return mfs, fs
+}
+
+// Open the supplied handle relative to the mount point.
+func openByHandle(
+	t *testing.T,
+	mfs *fuse.MountedFileSystem,
+	h unix.FileHandle) (*os.File, error) {
+	mount, err := os.Open(mfs.Dir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer mount.Close()
+
+	fd, err := unix.OpenByHandleAt(int(mount.Fd()), h, unix.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), "handle"), nil
+}
+
+// Have the kernel forget the inodes it no longer uses.
+func dropCaches(t *testing.T) {
+	if err := os.WriteFile("/proc/sys/vm/drop_caches", []byte("2"), 0); err != nil {
+		t.Skipf("Dropping caches: %v", err)
+	}
+}
+
+func TestExportSupport(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Opening by handle requires CAP_DAC_READ_SEARCH")
+	}
+
+	mfs, fs := mountExport(t, true, false)
+	dir := path.Join(mfs.Dir(), "dir")
+	dirHandle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, dir, 0)
+	if err != nil {
+		t.Fatalf("NameToHandleAt: %v", err)
+	}
+
+	fileHandle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, path.Join(dir, "foo"), 0)
+	if err != nil {
+		t.Fatalf("NameToHandleAt: %v", err)
+	}
+
+	// Once the kernel has forgotten the file, it looks it up by ID to open the
+	// handle.
+	dropCaches(t)
+	fs.lookups()
+
+	f, err := openByHandle(t, mfs, fileHandle)
+	if err != nil {
+		t.Fatalf("OpenByHandleAt: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 4)
+	if n, err := f.Read(buf); n != 4 || err != nil || string(buf) != "taco" {
+		t.Errorf("Read: got (%d, %v, %q)", n, err, buf)
+	}
+
+	if names := fs.lookups(); len(names) != 1 || names[0] != "." {
+		t.Errorf("Lookups opening file: got %q, want [.]", names)
+	}
+
+	// A directory is reconnected to the root through its parent.
+	dropCaches(t)
+	fs.lookups()
+
+	d, err := openByHandle(t, mfs, dirHandle)
+	if err != nil {
+		t.Fatalf("OpenByHandleAt: %v", err)
+	}
+	defer d.Close()
+
+	names := fs.lookups()
+	if !slices.Equal(names, []string{".", "..", "dir"}) {
+		t.Errorf("Lookups opening directory: got %q, want [. .. dir]", names)
+	}
+
+	// A handle with the wrong generation is stale. It is the third 32-bit word.
+	b := fileHandle.Bytes()
+	b[8]++
+	_, err = openByHandle(t, mfs, unix.NewFileHandle(fileHandle.Type(), b))
+	if !errors.Is(err, syscall.ESTALE) {
+		t.Errorf("OpenByHandleAt with wrong generation: got %v, want ESTALE", err)
+	}
+}
+
+func TestExportSupport_ParentTracking(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Opening by handle requires CAP_DAC_READ_SEARCH")
+	}
+
+	mfs, fs := mountExport(t, true, true)
+	dir := path.Join(mfs.Dir(), "dir")
+	dirHandle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, dir, 0)
+	if err != nil {
+		t.Fatalf("NameToHandleAt: %v", err)
+	}
+
+	fileHandle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, path.Join(dir, "foo"), 0)
+	if err != nil {
+		t.Fatalf("NameToHandleAt: %v", err)
+	}
+
+	// The lookups of "." and ".." are answered without the file system.
+	for _, h := range []unix.FileHandle{fileHandle, dirHandle} {
+		dropCaches(t)
+		fs.lookups()
+
+		f, err := openByHandle(t, mfs, h)
+		if err != nil {
+			t.Fatalf("OpenByHandleAt: %v", err)
+		}
+		f.Close()
+
+		if names := fs.lookups(); slices.Contains(names, ".") || slices.Contains(names, "..") {
+			t.Errorf("Lookups: got %q", names)
+		}
+	}
+
+	// The generation number remembered for the file is checked.
+	b := fileHandle.Bytes()
+	b[8]++
+	_, err = openByHandle(t, mfs, unix.NewFileHandle(fileHandle.Type(), b))
+	if !errors.Is(err, syscall.ESTALE) {
+		t.Errorf("OpenByHandleAt with wrong generation: got %v, want ESTALE", err)
+	}
+}
+
+func TestExportSupport_NotNegotiated(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Opening by handle requires CAP_DAC_READ_SEARCH")
+	}
+
+	mfs, fs := mountExport(t, false, false)
+	h, _, err := unix.NameToHandleAt(unix.AT_FDCWD, path.Join(mfs.Dir(), "dir"), 0)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("NameToHandleAt: %v", err)
+	}
+
+	// Older kernels give out handles, but can't open them once they have
+	// forgotten the inode.
+	dropCaches(t)
+	fs.lookups()
+
+	_, err = openByHandle(t, mfs, h)
+	if !errors.Is(err, syscall.ESTALE) {
+		t.Errorf("OpenByHandleAt: got %v, want ESTALE", err)
+	}
+
+	if names := fs.lookups(); len(names) != 0 {
+		t.Errorf("Unexpected lookups: %q", names)
+	}
+}
@@ -40,7 +40,9 @@ func (c *Connection) getInMessage() *buffer.InMessage {
 // LOCKS_EXCLUDED(c.mu)
 func (c *Connection) putInMessage(x *buffer.InMessage) {
 	c.mu.Lock()
-	c.inMessages.Put(unsafe.Pointer(x))
+	if c.tuning.Buffers == 0 || c.inMessages.Len() < c.tuning.Buffers {
+		c.inMessages.Put(unsafe.Pointer(x))
+	}
 	c.mu.Unlock()
 }
 
@@ -65,6 +67,40 @@ func (c *Connection) getOutMessage() *buffer.OutMessage {
 // LOCKS_EXCLUDED(c.mu)
 func (c *Connection) putOutMessage(x *buffer.OutMessage) {
 	c.mu.Lock()
-	c.outMessages.Put(unsafe.Pointer(x))
+	if c.tuning.Buffers == 0 || c.outMessages.Len() < c.tuning.Buffers {
+		c.outMessages.Put(unsafe.Pointer(x))
+	}
+	c.mu.Unlock()
+}
+
+////////////////////////////////////////////////////////////////////////
+// opArena
+////////////////////////////////////////////////////////////////////////
+
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) getArena() *opArena {
+	c.mu.Lock()
+	x := (*opArena)(c.arenas.Get())
+	c.mu.Unlock()
+
+	if x == nil {
+		x = newOpArena()
+	}
+
+	return x
+}
+
+// Return the arena to the freelist. The arena may be nil.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) putArena(x *opArena) {
+	if x == nil {
+		return
+	}
+
+	x.reset()
+
+	c.mu.Lock()
+	c.arenas.Put(unsafe.Pointer(x))
 	c.mu.Unlock()
 }
@@ -0,0 +1,198 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// A Handle is an open reference to a file, directory, or symlink on the host,
+// for passthrough file systems that mirror a host directory. Such a file
+// system should keep a handle per inode and resolve names relative to the
+// parent's handle, rather than building and opening paths: a path can be
+// redirected outside the exported tree by a concurrent rename or by replacing
+// a directory with a symlink, but a handle keeps referring to the same inode.
+//
+// Handles never follow symlinks: looking up a symlink yields a handle to the
+// symlink itself, which may be read with Readlink but not opened.
+//
+// On Linux a handle is an O_PATH file descriptor. Elsewhere a directory's
+// handle is a descriptor for the directory, and any other handle is a
+// descriptor for its parent directory together with its name.
+//
+// Handles are safe for concurrent use.
+type Handle struct {
+	// On Linux, an O_PATH descriptor for the file. Elsewhere, a descriptor for
+	// the file if it's a directory, or otherwise for its parent directory.
+	fd int
+
+	// The last component of the file's path, which on Linux is used only by
+	// Stat.
+	name string
+
+	// Whether the file is a directory. Unused on Linux.
+	dir bool
+}
+
+// Lookup returns a handle to the named entry in the directory referred to by
+// h. The name must be a single path component other than "." and "..", or
+// Lookup fails with EINVAL.
+func (h *Handle) Lookup(name string) (*Handle, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, unix.EINVAL
+	}
+
+	return h.lookUp(name)
+}
+
+// Resolve returns a handle to the file at the supplied slash-separated path,
+// relative to the directory referred to by h. It fails with EXDEV if the path
+// is absolute or resolving it would leave the directory.
+//
+// On Linux 5.6 and later this uses openat2 with RESOLVE_BENEATH, and symlinks
+// before the final component are followed as long as they stay beneath the
+// directory. Otherwise each component is looked up in turn, ".." components
+// are rejected with EXDEV, and symlinks before the final component fail with
+// ENOTDIR.
+func (h *Handle) Resolve(rel string) (*Handle, error) {
+	if path.IsAbs(rel) {
+		return nil, unix.EXDEV
+	}
+
+	return h.resolve(rel)
+}
+
+// Resolve the path one component at a time.
+func (h *Handle) walk(rel string) (*Handle, error) {
+	cur, err := h.dup()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(rel, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+
+		var next *Handle
+		if name == ".." {
+			err = unix.EXDEV
+		} else {
+			next, err = cur.lookUp(name)
+		}
+
+		cur.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		cur = next
+	}
+
+	return cur, nil
+}
+
+// Return a new handle referring to the same file.
+func (h *Handle) dup() (*Handle, error) {
+	fd, err := unix.FcntlInt(uintptr(h.fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dup := *h
+	dup.fd = fd
+	return &dup, nil
+}
+
+// Stat returns information about the file, without following symlinks. The
+// Sys method of the result returns a *unix.Stat_t.
+func (h *Handle) Stat() (os.FileInfo, error) {
+	info := &handleInfo{name: h.name}
+	if err := h.stat(&info.st); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Readlink returns the target of the symlink referred to by h.
+func (h *Handle) Readlink() (string, error) {
+	for size := 256; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := h.readlink(buf)
+		if err != nil {
+			return "", err
+		}
+
+		if n < size {
+			return string(buf[:n]), nil
+		}
+	}
+}
+
+// Close releases the handle. Handles looked up from it remain valid.
+func (h *Handle) Close() error {
+	return unix.Close(h.fd)
+}
+
+// An os.FileInfo for a handle.
+type handleInfo struct {
+	name string
+	st   unix.Stat_t
+}
+
+func (fi *handleInfo) Name() string       { return fi.name }
+func (fi *handleInfo) Size() int64        { return fi.st.Size }
+func (fi *handleInfo) ModTime() time.Time { return time.Unix(fi.st.Mtim.Unix()) }
+func (fi *handleInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *handleInfo) Sys() interface{}   { return &fi.st }
+
+func (fi *handleInfo) Mode() os.FileMode {
+	mode := uint32(fi.st.Mode)
+	m := os.FileMode(mode & 0777)
+	switch mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		m |= os.ModeDir
+	case unix.S_IFLNK:
+		m |= os.ModeSymlink
+	case unix.S_IFIFO:
+		m |= os.ModeNamedPipe
+	case unix.S_IFSOCK:
+		m |= os.ModeSocket
+	case unix.S_IFBLK:
+		m |= os.ModeDevice
+	case unix.S_IFCHR:
+		m |= os.ModeDevice | os.ModeCharDevice
+	}
+
+	if mode&unix.S_ISUID != 0 {
+		m |= os.ModeSetuid
+	}
+
+	if mode&unix.S_ISGID != 0 {
+		m |= os.ModeSetgid
+	}
+
+	if mode&unix.S_ISVTX != 0 {
+		m |= os.ModeSticky
+	}
+
+	return m
+}
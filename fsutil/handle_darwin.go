@@ -0,0 +1,113 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const dirFlags = unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+// OpenHandle returns a handle to the file at the supplied path, which is
+// trusted. If the final component is a symlink, it isn't followed.
+func OpenHandle(path string) (*Handle, error) {
+	fd, err := unix.Open(path, dirFlags, 0)
+	if err == nil {
+		return &Handle{fd: fd, name: filepath.Base(path), dir: true}, nil
+	}
+
+	parent, err := OpenHandle(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	defer parent.Close()
+	h, err := parent.lookUp(filepath.Base(path))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return h, nil
+}
+
+func (h *Handle) lookUp(name string) (*Handle, error) {
+	if !h.dir {
+		return nil, unix.ENOTDIR
+	}
+
+	fd, err := unix.Openat(h.fd, name, dirFlags, 0)
+	if err == nil {
+		return &Handle{fd: fd, name: name, dir: true}, nil
+	}
+
+	// Not a directory, or a directory we can't read. Refer to it by name
+	// within a copy of our descriptor, once we know it exists.
+	var st unix.Stat_t
+	if err := unix.Fstatat(h.fd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, err
+	}
+
+	entry, err := h.dup()
+	if err != nil {
+		return nil, err
+	}
+
+	entry.name = name
+	entry.dir = false
+	return entry, nil
+}
+
+func (h *Handle) resolve(rel string) (*Handle, error) {
+	return h.walk(rel)
+}
+
+// Open opens the file referred to by h for I/O, with flags as for os.OpenFile.
+// O_CREAT is ignored. Opening a symlink fails with ELOOP.
+func (h *Handle) Open(flags int) (*os.File, error) {
+	flags = flags&^os.O_CREATE | unix.O_CLOEXEC
+
+	var fd int
+	var err error
+	if h.dir {
+		fd, err = unix.Openat(h.fd, ".", flags, 0)
+	} else {
+		fd, err = unix.Openat(h.fd, h.name, flags|unix.O_NOFOLLOW, 0)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), h.name), nil
+}
+
+func (h *Handle) stat(st *unix.Stat_t) error {
+	if h.dir {
+		return unix.Fstat(h.fd, st)
+	}
+
+	return unix.Fstatat(h.fd, h.name, st, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func (h *Handle) readlink(buf []byte) (int, error) {
+	if h.dir {
+		return 0, unix.EINVAL
+	}
+
+	return unix.Readlinkat(h.fd, h.name, buf)
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const handleFlags = unix.O_PATH | unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+// OpenHandle returns a handle to the file at the supplied path, which is
+// trusted. If the final component is a symlink, it isn't followed.
+func OpenHandle(path string) (*Handle, error) {
+	fd, err := unix.Open(path, handleFlags, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return &Handle{fd: fd, name: filepath.Base(path)}, nil
+}
+
+func (h *Handle) lookUp(name string) (*Handle, error) {
+	fd, err := unix.Openat(h.fd, name, handleFlags, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handle{fd: fd, name: name}, nil
+}
+
+func (h *Handle) resolve(rel string) (*Handle, error) {
+	how := &unix.OpenHow{
+		Flags:   handleFlags,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	}
+
+	for {
+		fd, err := unix.Openat2(h.fd, rel, how)
+		switch err {
+		case nil:
+			return &Handle{fd: fd, name: filepath.Base(rel)}, nil
+
+		// The kernel doesn't support openat2.
+		case unix.ENOSYS:
+			return h.walk(rel)
+
+		// A rename raced with resolving "..".
+		case unix.EAGAIN:
+			continue
+
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Open opens the file referred to by h for I/O, with flags as for os.OpenFile.
+// O_CREAT is ignored. Opening a symlink fails with ELOOP.
+func (h *Handle) Open(flags int) (*os.File, error) {
+	// An O_PATH descriptor can be reopened through its magic link in /proc.
+	// That is the only way to do so, short of open_by_handle_at which requires
+	// CAP_DAC_READ_SEARCH.
+	var st unix.Stat_t
+	if err := h.stat(&st); err != nil {
+		return nil, err
+	}
+
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return nil, unix.ELOOP
+	}
+
+	return os.OpenFile(
+		fmt.Sprintf("/proc/self/fd/%d", h.fd),
+		flags&^os.O_CREATE,
+		0)
+}
+
+func (h *Handle) stat(st *unix.Stat_t) error {
+	return unix.Fstat(h.fd, st)
+}
+
+func (h *Handle) readlink(buf []byte) (int, error) {
+	return unix.Readlinkat(h.fd, "", buf)
+}
@@ -0,0 +1,103 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestHandle(t *testing.T) {
+	// A tree containing a file and symlinks escaping it.
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	err := os.WriteFile(filepath.Join(root, "dir", "file"), []byte("taco"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "dir", "abs")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("../..", filepath.Join(root, "dir", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := OpenHandle(root)
+	if err != nil {
+		t.Fatalf("OpenHandle: %v", err)
+	}
+	defer h.Close()
+
+	// Files beneath the root can be resolved and opened.
+	file, err := h.Resolve("dir/file")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	defer file.Close()
+
+	f, err := file.Open(os.O_RDONLY)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	contents, err := io.ReadAll(f)
+	f.Close()
+	if string(contents) != "taco" || err != nil {
+		t.Errorf("ReadAll: %q, %v", contents, err)
+	}
+
+	// Symlinks are looked up, but not followed or opened.
+	dir, err := h.Lookup("dir")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	defer dir.Close()
+
+	link, err := dir.Lookup("abs")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	defer link.Close()
+
+	fi, err := link.Stat()
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 || fi.Name() != "abs" {
+		t.Errorf("Stat: %v, %v", fi, err)
+	}
+
+	if target, err := link.Readlink(); target != outside || err != nil {
+		t.Errorf("Readlink: %q, %v", target, err)
+	}
+
+	if _, err := link.Open(os.O_RDONLY); err == nil {
+		t.Errorf("Opening a symlink succeeded")
+	}
+
+	// Names and paths leaving the root are rejected.
+	if _, err := dir.Lookup(".."); err != unix.EINVAL {
+		t.Errorf("Lookup(..): got %v, want EINVAL", err)
+	}
+
+	for _, rel := range []string{"/etc", "dir/../..", "dir/abs/x", "dir/up/x"} {
+		if escaped, err := h.Resolve(rel); err == nil {
+			escaped.Close()
+			t.Errorf("Resolve(%q) succeeded", rel)
+		}
+	}
+
+	// The walk used on systems without openat2 agrees.
+	if _, err := h.walk("dir/../dir/file"); err != unix.EXDEV {
+		t.Errorf("walk: got %v, want EXDEV", err)
+	}
+
+	if _, err := h.walk("dir/abs/x"); err == nil {
+		t.Errorf("walk through a symlink succeeded")
+	}
+}
@@ -37,9 +37,24 @@ type OpContext struct {
 
 	// UID of the process that is invoking the operation.
 	// Not filled in case of a writepage operation.
+	//
+	// For ops sent through an ID-mapped mount (see
+	// fuse.MountConfig.EnableIDMappedMounts), this and Gid are the caller's
+	// IDs mapped into the file system's view for ops that create an inode,
+	// i.e. MkDirOp, MkNodeOp, CreateFileOp, CreateTmpfileOp and
+	// CreateSymlinkOp, so that it can be owned by them, and InvalidUidGid for
+	// all other ops.
 	Uid uint32
+
+	// GID of the process that is invoking the operation.
+	// Not filled in case of a writepage operation.
+	Gid uint32
 }
 
+// The Uid and Gid in the OpContext of ops sent through an ID-mapped mount
+// that don't create an inode.
+const InvalidUidGid = ^uint32(0)
+
 // Return statistics about the file system's capacity and available resources.
 //
 // Called by statfs(2) and friends:
@@ -129,6 +144,11 @@ type LookUpInodeOp struct {
 	//
 	// the file system may receive a request to look up the child named "bar" for
 	// the parent foo/.
+	//
+	// If fuse.MountConfig.EnableExportSupport is set, the name may also be ".",
+	// asking for the entry of Parent itself, which needn't be a directory, or
+	// "..", asking for that of the directory containing Parent. The kernel
+	// sends these to open inodes by file handle; see there.
 	Name string
 
 	// The resulting entry. Must be filled out by the file system.
@@ -155,10 +175,52 @@ type GetInodeAttributesOp struct {
 	OpContext            OpContext
 }
 
+// Return the attributes of an inode for statx(2), which include its birth
+// time and its STATX_ATTR_* flags as well as what GetInodeAttributesOp
+// returns.
+//
+// Linux 6.6 and later send this in place of GetInodeAttributesOp when a
+// statx(2) call asks for fields that stat(2) doesn't return, e.g.
+// STATX_BTIME. There's no init flag for it: once it fails with ENOSYS, as it
+// does for file systems that don't implement it, the kernel sends
+// GetInodeAttributesOp instead and reports no birth time.
+type StatxOp struct {
+	// The inode of interest.
+	Inode InodeID
+
+	// If set, the inode is being stat'd through this handle, e.g. by fstat(2).
+	Handle *HandleID
+
+	// The STATX_* fields asked for, and the AT_STATX_* sync flags, as defined
+	// by golang.org/x/sys/unix.
+	Mask  uint32
+	Flags uint32
+
+	// Set by the file system: attributes for the inode, and the time at which
+	// they should expire, as for GetInodeAttributesOp. The birth time is
+	// Attributes.Crtime, and is reported only if it is non-zero.
+	Attributes           InodeAttributes
+	AttributesExpiration time.Time
+
+	// Set by the file system: the STATX_ATTR_* flags of the inode, e.g.
+	// STATX_ATTR_IMMUTABLE, and the mask of those it supports. Fields such as
+	// the mount ID are filled in by the kernel.
+	StatxAttributes     uint64
+	StatxAttributesMask uint64
+	OpContext           OpContext
+}
+
 // Change attributes for an inode.
 //
 // The kernel sends this for obvious cases like chmod(2), and for less obvious
 // cases like ftrunctate(2).
+//
+// With writeback caching (see fuse.MountConfig.DisableWritebackCaching), the
+// kernel keeps the mtime, ctime and size of regular files itself, ignoring
+// those returned by the file system. After writing back dirty pages, e.g. on
+// close(2) or fsync(2), it sends this op with Mtime and Ctime set to the
+// times of the write(2) calls rather than of the WriteFileOps. The file
+// system should store them as given rather than using the current time.
 type SetInodeAttributesOp struct {
 	// The inode of interest.
 	Inode InodeID
@@ -174,6 +236,11 @@ type SetInodeAttributesOp struct {
 	Atime *time.Time
 	Mtime *time.Time
 
+	// Linux only. The inode's change time, set only with writeback caching, as
+	// described above. Otherwise the file system is expected to update the
+	// change time itself when attributes change.
+	Ctime *time.Time
+
 	// Set by the file system: the new attributes for the inode, and the time at
 	// which they should expire. See notes on
 	// ChildInodeEntry.AttributesExpiration for more.
@@ -182,6 +249,26 @@ type SetInodeAttributesOp struct {
 	OpContext            OpContext
 }
 
+// Check whether the calling process may access an inode, for access(2) and
+// friends, and for chdir(2). Return nil to allow the access, or e.g. EACCES to
+// deny it. The caller's GID is available from
+// fuse.MountedFileSystem.GetFuseContext.
+//
+// The kernel sends this only when the file system is mounted without the
+// default_permissions option (see fuse.MountConfig.DisableDefaultPermissions),
+// in which case it checks no permissions itself.
+// If the file system returns ENOSYS, the kernel stops sending it, and lets
+// every later access(2) call succeed.
+type AccessOp struct {
+	// The inode of interest.
+	Inode InodeID
+
+	// The access to check: a combination of R_OK, W_OK and X_OK, or zero
+	// (F_OK) to check only that the inode exists.
+	Mask      uint32
+	OpContext OpContext
+}
+
 // Decrement the reference count for an inode ID previously issued by the file
 // system.
 //
@@ -308,10 +395,16 @@ type MkNodeOp struct {
 	Parent InodeID
 
 	// The name of the child to create, and the mode with which to create it.
+	// Besides regular files, the mode may ask for a character or block device
+	// (os.ModeDevice, with os.ModeCharDevice for the former), a named pipe, or
+	// a socket. The kernel does all I/O on those itself, so the file system
+	// need only record them, and must report the same type in Entry.
 	Name string
 	Mode os.FileMode
 
-	// The device number (only valid if created file is a device)
+	// The device number, if the mode asks for a device, in the kernel's
+	// encoding (on Linux, as returned by unix.Mkdev). The file system should
+	// report it back as the Rdev of the inode's attributes.
 	Rdev uint32
 
 	// Set by the file system: information about the inode that was created.
@@ -360,6 +453,42 @@ type CreateFileOp struct {
 	// The flags from the open(2) call, passed through the kernel's fuse driver
 	// to the FUSE daemon.
 	OpenFlags fusekernel.OpenFlags
+
+	// Set by the file system: if non-zero, the handle is opened in passthrough
+	// mode. See OpenFileOp.BackingID.
+	BackingID BackingID
+}
+
+// Create an unnamed file within a directory and open it, as for open(2) with
+// O_TMPFILE. Unlike CreateFileOp, no entry is added to the parent, which only
+// determines where the file lives (e.g. which quota it is charged to): the
+// file should be given a link count of zero, and deleted once the kernel
+// forgets it, unless it is first given a name with a CreateLinkOp, as for
+// linkat(2) with AT_EMPTY_PATH.
+//
+// If the file system returns ENOSYS, the kernel fails this and all later
+// O_TMPFILE opens with EOPNOTSUPP, prompting most applications to fall back
+// to creating and unlinking a named file.
+type CreateTmpfileOp struct {
+	// The ID of the directory inode within which to create the file.
+	Parent InodeID
+
+	// The mode with which to create the file.
+	Mode os.FileMode
+
+	// Set by the file system: information about the inode that was created.
+	//
+	// The lookup count for the inode is implicitly incremented. See notes on
+	// ForgetInodeOp for more information.
+	Entry ChildInodeEntry
+
+	// Set by the file system: an opaque ID that will be echoed in follow-up
+	// calls for this file, as for CreateFileOp.Handle.
+	Handle    HandleID
+	OpContext OpContext
+
+	// The flags from the open(2) call, including O_TMPFILE.
+	OpenFlags fusekernel.OpenFlags
 }
 
 // Create a symlink inode. If the name already exists, the file system should
@@ -451,6 +580,12 @@ type RenameOp struct {
 	// overwritten within it.
 	NewParent InodeID
 	NewName   string
+
+	// The flags passed to renameat2(2), which are zero for rename(2). They are
+	// non-zero only if MountConfig.EnableRenameFlags is set, in which case the
+	// file system must honor them, and fail with EINVAL if it doesn't support
+	// any of them, so that e.g. RenameNoReplace never replaces a name.
+	Flags     RenameFlags
 	OpContext OpContext
 }
 
@@ -683,10 +818,59 @@ type OpenFileOp struct {
 	// Enabling direct IO ensures that all client operations reach the fuse
 	// layer. This allows for filesystems whose file sizes are not known in
 	// advance, for example, because contents are generated on the fly.
+	//
+	// Linux refuses to map such handles shared with mmap(2) unless
+	// fuse.MountConfig.EnableDirectIOMmap is set; see also
+	// fuse.MountConfig.DirectIOFallback.
 	UseDirectIO bool
 
+	// Linux only. If set along with UseDirectIO, the kernel doesn't serialize
+	// writes through the handle with the inode's lock, so that several may be
+	// in flight to the file system at once, e.g. for a database writing
+	// different pages of one file. The file system must then order
+	// overlapping writes itself. Writes that extend the file, or are made with
+	// O_APPEND, are still serialized.
+	//
+	// This is ignored unless the protocol version in
+	// fuse.MountedFileSystem.KernelInfo is at least 7.38.
+	ParallelDirectWrites bool
+
+	// Linux only. If set, the handle can't be repositioned: lseek(2), pread(2)
+	// and pwrite(2) on it fail with ESPIPE, as for a pipe. Reads and writes
+	// through it still carry the file position as their Offset.
+	NonSeekable bool
+
+	// Linux only (>= 5.2). If set, the handle is a stream, like a pipe or
+	// socket: as with NonSeekable it can't be repositioned, and in addition the
+	// kernel keeps no file position for it, so reads and writes through it all
+	// have Offset zero and may be in flight at the same time, e.g. a reader
+	// waiting for data doesn't hold up a writer. Older kernels ignore it.
+	//
+	// Files whose contents are produced as they are read, whether or not
+	// NonSeekable or Stream is set, should also be opened with UseDirectIO, so
+	// that every read reaches the file system rather than the page cache.
+	Stream bool
+
+	// If non-zero, the handle is opened in passthrough mode: the kernel serves
+	// reads, writes and mmap through it from the backing file with this ID,
+	// registered with fuse.Notifier.OpenBacking, without sending ReadFileOp or
+	// WriteFileOp. Requires fuse.MountConfig.EnablePassthrough.
+	//
+	// The kernel refuses, failing the open(2) with EIO, to mix handles opened
+	// in passthrough mode with others open at the same time for the same
+	// inode, to use different backing files for them, or to combine
+	// passthrough with UseDirectIO. Since it still asks the file system for
+	// attributes, these should reflect changes made through the backing file,
+	// e.g. to its size.
+	BackingID BackingID
+
 	// The flags from the open(2) call, passed through the kernel's fuse driver
 	// to the FUSE daemon.
+	//
+	// O_TRUNC is included only if fuse.MountConfig.EnableAtomicTrunc is set,
+	// in which case the file system must truncate the file to zero bytes
+	// before returning, as the kernel sends no SetInodeAttributesOp for it.
+	// See OpenFlags.IsTruncate.
 	OpenFlags fusekernel.OpenFlags
 
 	OpContext OpContext
@@ -709,8 +893,21 @@ type ReadFileOp struct {
 	// The size of the read.
 	Size int64
 
+	// Whether the read appears to have been issued by the kernel's readahead,
+	// rather than for a process waiting for the data. This is a guess, and is
+	// only made if fuse.MountConfig.ClassifyReadahead is set.
+	//
+	// Failing a readahead read isn't reported to any process; the kernel reads
+	// the pages again when they are needed.
+	Readahead bool
+
 	// The destination buffer, whose length gives the size of the read.
 	// The file system can write to this buffer for non-vectored reads.
+	//
+	// Dst is sent to the kernel in place, without being copied. It normally
+	// begins on a page boundary, so a file system backed by local files may
+	// read into it directly from a file opened with O_DIRECT. Use
+	// fuseutil.IsPageAligned to check before relying on this.
 	Dst []byte
 
 	// Set by the file system:
@@ -795,6 +992,24 @@ type WriteFileOp struct {
 	// be written, except on error (https://tinyurl.com/yuruk5tx). This appears
 	// to be because it uses file mmapping machinery
 	// (https://tinyurl.com/avxy3dvm) to write a page at a time.
+	//
+	// With writeback caching (see fuse.MountConfig.DisableWritebackCaching),
+	// writes are sent after write(2) has returned, gathered into pages, so
+	// that:
+	//
+	// *   Data written through one handle may be sent through any handle open
+	//     for writing on the inode, even after the first has been flushed.
+	//
+	// *   The kernel handles O_APPEND itself, so Offset is where to write even
+	//     for handles opened with it.
+	//
+	// *   The kernel reads the rest of pages it partially overwrites, sending
+	//     ReadFileOps even through handles opened write-only, so file systems
+	//     that open backing files per handle should open them read-write.
+	//
+	// *   The kernel keeps the file's size, mtime and ctime itself, ignoring
+	//     those returned by the file system. The size is passed on by the
+	//     writes themselves, and the times by SetInodeAttributesOp.
 	Data      []byte
 	OpContext OpContext
 
@@ -868,9 +1083,12 @@ type SyncFileOp struct {
 // https://tinyurl.com/unesszdp and the notes on WriteFileOp).
 //
 // Because of cases like dup2(2), FlushFileOps are not necessarily one to one
-// with OpenFileOps. They should not be used for reference counting, and the
+// with OpenFileOps. Every file descriptor referring to an open file shares its
+// handle, including those made by dup(2) and those inherited across fork(2),
+// and each sends a flush when closed, while nothing is sent when they are
+// made. Flushes therefore can't be used for reference counting, and the
 // handle must remain valid even after the flush op is received (use
-// ReleaseFileHandleOp for disposing of it).
+// ReleaseFileHandleOp for disposing of it, and see fuseutil.HandleMap).
 //
 // Typical "real" file systems do not implement this, presumably relying on
 // the kernel to write out the page cache to the block device eventually.
@@ -880,14 +1098,21 @@ type SyncFileOp struct {
 // return any errors that occur.
 type FlushFileOp struct {
 	// The file and handle being flushed.
-	Inode     InodeID
-	Handle    HandleID
+	Inode  InodeID
+	Handle HandleID
+
+	// The owner of the file descriptor being closed, whose POSIX locks on the
+	// file must be released. See GetLkOp.
+	LockOwner uint64
+
 	OpContext OpContext
 }
 
 // Release a previously-minted file handle. The kernel calls this when there
 // are no more references to an open file: all file descriptors are closed
-// and all memory mappings are unmapped.
+// and all memory mappings are unmapped. It is sent once for each OpenFileOp or
+// CreateFileOp, however many processes have shared the handle through dup(2)
+// or fork(2) in between.
 //
 // The kernel guarantees that the handle ID will not be used in further calls
 // to the file system (unless it is reissued by the file system).
@@ -898,7 +1123,13 @@ type ReleaseFileHandleOp struct {
 	// The handle ID to be released. The kernel guarantees that this ID will not
 	// be used in further calls to the file system (unless it is reissued by the
 	// file system).
-	Handle    HandleID
+	Handle HandleID
+
+	// Whether the handle was locked with flock(2), in which case the file
+	// system must release the flock lock held by LockOwner. See SetLkOp.
+	FlockRelease bool
+	LockOwner    uint64
+
 	OpContext OpContext
 }
 
@@ -998,6 +1229,8 @@ type SetXattrOp struct {
 	OpContext OpContext
 }
 
+// Manipulate the allocated space of an open file, as requested by
+// fallocate(2).
 type FallocateOp struct {
 	// The inode and handle we are fallocating
 	Inode  InodeID
@@ -1009,16 +1242,338 @@ type FallocateOp struct {
 	// Length of the byte range
 	Length uint64
 
-	// If Mode is 0x0, allocate disk space within the range specified
-	// If Mode has 0x1, allocate the space but don't increase the file size
-	// If Mode has 0x2, deallocate space within the range specified
-	// If Mode has 0x2, it sbould also have 0x1 (deallocate should not increase
-	// file size)
-	Mode      uint32
+	// What to do with the range. If zero, allocate disk space for it,
+	// increasing the file's size if it extends past the end. Otherwise a
+	// combination of the FallocateMode flags, e.g. FallocatePunchHole
+	// combined with FallocateKeepSize to deallocate it.
+	//
+	// A file system should fail modes it doesn't support with EOPNOTSUPP, as
+	// fuseutil.CheckFallocateMode does, rather than ENOSYS, which the kernel
+	// takes to mean that fallocate(2) isn't supported at all, failing every
+	// later call without sending it. Linux itself fails modes it doesn't
+	// expect fuse file systems to support with EOPNOTSUPP: currently only
+	// FallocateKeepSize, FallocatePunchHole and, on some kernels,
+	// FallocateZeroRange are sent.
+	Mode      FallocateMode
+	OpContext OpContext
+}
+
+// Map a block of a file to the block of the device that holds it, for the
+// FIBMAP ioctl, as used e.g. by boot loaders like lilo to find a kernel image
+// on disk, and by swapon(8) to find the blocks of a swap file.
+//
+// Linux sends this only for file systems mounted on a block device (see
+// fuse.MountConfig.BlockDevice). If the file system returns ENOSYS, the
+// kernel stops sending it, and FIBMAP reports every block as unmapped.
+type BmapOp struct {
+	// The file of interest.
+	Inode InodeID
+
+	// The size of the blocks, in bytes, which is the block size of the mount.
+	BlockSize uint32
+
+	// The index of the block within the file, in units of BlockSize.
+	Block uint64
+
+	// Set by the file system: the index of the block on the device, in units
+	// of BlockSize, or zero if the block isn't mapped, e.g. because it is in a
+	// hole.
+	DeviceBlock uint64
+
 	OpContext OpContext
 }
 
+// Flush dirty state for the whole file system, as for syncfs(2) and sync(2).
+//
+// Linux sends this only to virtiofs file systems, not to those mounted
+// through /dev/fuse as this package mounts them, for which both calls return
+// without waiting on the file system, so that a hung daemon can't block them.
+// Such a file system still sees fsync(2) for each file as SyncFileOp, and
+// close(2) as FlushFileOp, and may flush everything in Destroy on unmount.
 type SyncFSOp struct {
+	// The root of the file system.
 	Inode     InodeID
 	OpContext OpContext
 }
+
+// Map part of a file into the DAX window, the region of guest memory through
+// which a virtio-fs guest accesses file contents directly, without going
+// through the page cache or sending ReadFileOp and WriteFileOp. A daemon
+// implementing this, like virtiofsd, asks the VMM to mmap the range of the
+// file at MappingOffset in the window, replacing any mapping already there.
+//
+// Linux sends this only to virtiofs file systems mounted with -o dax, not to
+// those mounted through /dev/fuse as this package mounts them, so a file
+// system sees it only when served messages read from a virtio-fs queue.
+type SetupMappingOp struct {
+	// The file to map, and the handle through which the guest opened it.
+	Inode  InodeID
+	Handle HandleID
+
+	// The range of the file to map. Both are multiples of the mapping
+	// alignment the daemon advertised.
+	Offset uint64
+	Length uint64
+
+	// The access the mapping must allow: fusekernel.SetupMappingRead, and
+	// fusekernel.SetupMappingWrite if the guest may write to it.
+	Flags fusekernel.SetupMappingFlags
+
+	// The offset within the DAX window at which to map the range.
+	MappingOffset uint64
+
+	OpContext OpContext
+}
+
+// A range of the DAX window to unmap. See RemoveMappingOp.
+type RemoveMappingEntry struct {
+	MappingOffset uint64
+	Length        uint64
+}
+
+// Remove mappings set up with SetupMappingOp, when the guest reclaims parts of
+// the DAX window or the file is truncated or evicted. Like SetupMappingOp,
+// this is sent only to virtiofs file systems mounted with -o dax.
+type RemoveMappingOp struct {
+	// The file whose ranges were mapped.
+	Inode InodeID
+
+	// The ranges of the DAX window to unmap.
+	Mappings []RemoveMappingEntry
+
+	OpContext OpContext
+}
+
+// Perform an ioctl(2) on a file or directory. For file systems, the kernel
+// sends only restricted ioctls, whose argument is a pointer to a buffer of
+// the size encoded in the command, as _IOR and _IOW define, copying the buffer
+// in and out of the calling process. In particular, it sends FS_IOC_GETFLAGS
+// and FS_IOC_SETFLAGS (with a 4-byte argument) for lsattr(1) and chattr(1),
+// having opened the file read-only to do so. If the file system returns
+// ENOSYS, the kernel stops sending this op and fails every ioctl with ENOTTY.
+//
+// Character devices served with CUSE instead receive unrestricted ioctls,
+// whose argument may point anywhere, and which arrive with no input and no
+// room for output. To handle one, the file system sets RetryInput and
+// RetryOutput to the regions of the caller's memory it needs, and the kernel
+// sends the op again with Input holding the concatenated contents of
+// RetryInput, and OutputSize their total length for RetryOutput, to which it
+// copies Output in order. This repeats until the file system replies without
+// asking for a retry.
+type IoctlOp struct {
+	// The inode, and the handle previously returned by OpenFile or OpenDir.
+	Inode  InodeID
+	Handle HandleID
+
+	// Whether the inode is a directory.
+	Dir bool
+
+	// Whether the ioctl is unrestricted; see above.
+	Unrestricted bool
+
+	// Whether the caller is a 32-bit process on a 64-bit kernel, which lays
+	// out structures in the argument accordingly.
+	Compat bool
+
+	// The command, and the address of the caller's argument, which can't be
+	// used to access it.
+	Cmd uint32
+	Arg uint64
+
+	// The contents of the argument, for commands that read it.
+	Input []byte
+
+	// The size of the argument, for commands that write it.
+	OutputSize int
+
+	// Set by the file system: the value returned to the caller, which must not
+	// be negative (return an error instead), and for commands that write to
+	// the argument, at most OutputSize bytes to write.
+	Result int32
+	Output []byte
+
+	// Set by the file system, only for unrestricted ioctls: the regions of the
+	// caller's memory to read and write when retrying. If either is non-empty,
+	// Result and Output are ignored. The kernel accepts at most 256 regions.
+	RetryInput  []IoctlRegion
+	RetryOutput []IoctlRegion
+
+	OpContext OpContext
+}
+
+// A region of the memory of the process making an unrestricted ioctl. See
+// IoctlOp.
+type IoctlRegion struct {
+	Addr uint64
+	Len  uint64
+}
+
+// Copy a range of data from one open file to another within the file system,
+// as requested by copy_file_range(2), so that file systems whose backends can
+// copy data themselves needn't have the kernel read and write it. If the file
+// system returns ENOSYS, the kernel stops sending this op and falls back to
+// copying through ReadFile and WriteFile.
+//
+// The kernel has already flushed any data it has cached for the source range
+// with WriteFile, and invalidates its cache of the destination range
+// afterwards.
+type CopyFileRangeOp struct {
+	// The file to copy from, the handle previously returned by OpenFile or
+	// CreateFile when opening it, and the offset at which to start reading.
+	SrcInode  InodeID
+	SrcHandle HandleID
+	SrcOffset uint64
+
+	// The file to copy to, which may be the same as the source, and its handle
+	// and offset, as above.
+	DstInode  InodeID
+	DstHandle HandleID
+	DstOffset uint64
+
+	// The number of bytes to copy, and the flags passed to copy_file_range(2),
+	// which are currently always zero.
+	Length uint64
+	Flags  uint64
+
+	// Set by the file system: the number of bytes copied, which may be less
+	// than Length, e.g. if the end of the source file was reached, and must
+	// fit in 32 bits.
+	BytesCopied uint64
+
+	OpContext OpContext
+}
+
+// Find the start of the next region of data or hole in an open file, for
+// lseek(2) with SEEK_DATA or SEEK_HOLE, which the kernel only sends as this
+// op. This lets tools such as cp(1) and backup programs skip the holes of
+// sparse files. If the file system returns ENOSYS, the kernel stops sending
+// this op and treats every file as having data up to its size, followed by a
+// hole.
+type LseekOp struct {
+	// The file and the handle previously returned by OpenFile or CreateFile
+	// when opening it.
+	Inode  InodeID
+	Handle HandleID
+
+	// The offset from which to search, and what to search for:
+	// unix.SEEK_DATA or unix.SEEK_HOLE.
+	//
+	// Following lseek(2), the file system should return ENXIO if Offset is at
+	// or past the end of the file, or if Whence is SEEK_DATA and there is no
+	// data at or after Offset. A file has an implicit hole at its end, so a
+	// search for a hole always succeeds otherwise.
+	Offset int64
+	Whence int
+
+	// Set by the file system: the offset found, which the kernel makes the new
+	// offset of the file.
+	NewOffset int64
+
+	OpContext OpContext
+}
+
+// Report which I/O events an open file is ready for, for poll(2), select(2)
+// and epoll(7). This lets file systems serve files whose readiness changes
+// over time, such as FIFOs and event streams. If the file system returns
+// ENOSYS, the kernel stops sending this op and treats every file as always
+// ready for reading and writing.
+type PollOp struct {
+	// The file and the handle previously returned by OpenFile or CreateFile
+	// when opening it.
+	Inode  InodeID
+	Handle HandleID
+
+	// The events the caller is interested in, as a mask of unix.POLLIN,
+	// unix.POLLOUT, etc.
+	Events uint32
+
+	// If non-zero, the caller is going to wait, and the file system should
+	// call Notifier.PollWakeup with this handle once the file becomes ready
+	// for any of the events it isn't ready for now. The kernel then sends a
+	// new PollOp to find out which. The same handle is used for every poll of
+	// the open file, and a wakeup for it with no waiters is harmless.
+	PollHandle PollHandle
+
+	// Set by the file system: the events the file is ready for.
+	Revents uint32
+
+	OpContext OpContext
+}
+
+////////////////////////////////////////////////////////////////////////
+// File locks
+////////////////////////////////////////////////////////////////////////
+
+// Test for a POSIX advisory record lock that would conflict with the supplied
+// one, for fcntl(2) with F_GETLK.
+//
+// The kernel sends GetLkOp, SetLkOp and SetLkWOp only if
+// MountConfig.EnablePosixLocks is set, so that a file system whose files are
+// shared, e.g. a distributed one, can arbitrate locks between clients.
+// Otherwise it manages the locks locally, and they only exclude processes on
+// the same machine. Each lock belongs to an owner, which the kernel
+// identifies with an opaque number. Posix requires an owner's locks on a file
+// to be released when it closes any file descriptor for the file. The kernel
+// doesn't send a SetLkOp for this, so the file system must release them when
+// it receives a FlushFileOp with the owner as its LockOwner.
+type GetLkOp struct {
+	// The file and the handle previously returned by OpenFile or CreateFile
+	// when opening it.
+	Inode  InodeID
+	Handle HandleID
+
+	// The owner asking, and the lock it would like to take.
+	Owner uint64
+	Lock  FileLock
+
+	// Set by the file system: a lock held by another owner that conflicts
+	// with Lock. Initially of type F_UNLCK, which means there is none.
+	Conflict FileLock
+
+	OpContext OpContext
+}
+
+// Set or release a POSIX advisory record lock, for fcntl(2) with F_SETLK,
+// failing with EAGAIN if another owner holds a conflicting lock. The range of
+// the lock is merged with, or split from, any locks the owner already holds
+// on the file, as Posix specifies. See GetLkOp.
+//
+// If MountConfig.EnableFlockLocks is set, the kernel also sends BSD locks,
+// taken with flock(2) with LOCK_NB, as SetLkOps with Flock set. These cover
+// the whole file, and belong to the open file description, i.e. all file
+// descriptors duplicated from the one that took the lock, which the kernel
+// identifies as the owner. Unlike POSIX locks, a flock lock replaces any the
+// owner already holds, and the two kinds don't conflict with each other. The
+// kernel releases a flock lock when its owner is closed with a
+// ReleaseFileHandleOp, rather than a SetLkOp.
+type SetLkOp struct {
+	// The file and the handle previously returned by OpenFile or CreateFile
+	// when opening it.
+	Inode  InodeID
+	Handle HandleID
+
+	// The owner of the lock, and the lock to set, or release if of type
+	// F_UNLCK.
+	Owner uint64
+	Lock  FileLock
+
+	// Whether the lock is a flock lock, rather than a POSIX lock.
+	Flock bool
+
+	OpContext OpContext
+}
+
+// Like SetLkOp, but for F_SETLKW, or flock(2) without LOCK_NB: if another
+// owner holds a conflicting lock, wait for it to be released rather than
+// failing. If the caller is interrupted, the op's context is cancelled, and
+// the file system should return EINTR. The file system may return EDEADLK if
+// waiting would deadlock.
+type SetLkWOp struct {
+	Inode  InodeID
+	Handle HandleID
+	Owner  uint64
+	Lock   FileLock
+	Flock  bool
+
+	OpContext OpContext
+}
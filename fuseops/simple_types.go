@@ -87,18 +87,25 @@ type InodeAttributes struct {
 	//
 	Mode os.FileMode
 
-	// The device number. Only valid if the file is a device
+	// The device number. Only valid if the file is a device; see
+	// MkNodeOp.Rdev.
 	Rdev uint32
 
 	// Time information. See `man 2 stat` for full details.
 	Atime  time.Time // Time of last access
 	Mtime  time.Time // Time of last modification
 	Ctime  time.Time // Time of last modification to inode
-	Crtime time.Time // Time of creation (OS X only)
+	Crtime time.Time // Time of creation (OS X, and StatxOp on Linux)
 
 	// Ownership information
 	Uid uint32
 	Gid uint32
+
+	// Whether the directory is the root of a nested file system, which the
+	// kernel mounts separately, with its own st_dev, so that e.g. find -xdev
+	// and du -x don't cross into it. Linux only, and only if the kernel
+	// offers submounts; see fuse.MountConfig.EnableSubmounts.
+	Submount bool
 }
 
 func (a *InodeAttributes) DebugString() string {
@@ -116,6 +123,11 @@ func (a *InodeAttributes) DebugString() string {
 // reuse inode IDs when they become free, the generation number must change
 // when an ID is reused.
 //
+// The kernel encodes the ID and generation number in the file handles it
+// gives out, and rejects a handle whose generation differs from the one
+// returned for its ID. See fuse.MountConfig.EnableExportSupport and
+// fuseutil.InodeTable.
+//
 // This corresponds to struct inode::i_generation in the VFS layer.
 // (https://tinyurl.com/23sr9svd)
 //
@@ -134,11 +146,36 @@ type GenerationNumber uint64
 // This corresponds to fuse_file_info::fh.
 type HandleID uint64
 
+// BackingID identifies a file registered with the kernel as the backing file
+// of files opened in passthrough mode. See fuse.Notifier.OpenBacking.
+type BackingID int32
+
 // DirOffset is an offset into an open directory handle. This is opaque to
 // FUSE, and can be used for whatever purpose the file system desires. See
 // notes on ReadDirOp.Offset for details.
 type DirOffset uint64
 
+// FileLock describes a POSIX advisory record lock, as set with fcntl(2). See
+// GetLkOp and SetLkOp.
+type FileLock struct {
+	// The first and last bytes of the range, inclusive. A lock extending to
+	// the end of the file, however far it grows, ends at math.MaxInt64.
+	Start uint64
+	End   uint64
+
+	// The type of lock: unix.F_RDLCK, unix.F_WRLCK, or unix.F_UNLCK.
+	Type uint32
+
+	// The process holding the lock, if known.
+	Pid uint32
+}
+
+// PollHandle is an opaque 64-bit number chosen by the kernel to identify the
+// waiters polling an open file. See PollOp.
+//
+// This corresponds to fuse_pollhandle::kh.
+type PollHandle uint64
+
 // ChildInodeEntry contains information about a child inode within its parent
 // directory. It is shared by LookUpInodeOp, MkDirOp, CreateFileOp, etc, and is
 // consumed by the kernel in order to set up a dcache entry.
@@ -227,3 +264,101 @@ type ChildInodeEntry struct {
 	// default. See notes on MountConfig.EnableVnodeCaching for more.
 	EntryExpiration time.Time
 }
+
+// RenameFlags are the flags passed to renameat2(2). See RenameOp.Flags.
+type RenameFlags uint32
+
+const (
+	// Fail with EEXIST rather than replace the new name if it exists.
+	RenameNoReplace RenameFlags = 1 << 0
+
+	// Atomically exchange the old and new names, both of which must exist.
+	RenameExchange RenameFlags = 1 << 1
+
+	// Leave a whiteout, a character device with device number 0/0, at the old
+	// name, as overlay file systems do to hide the name in lower layers.
+	RenameWhiteout RenameFlags = 1 << 2
+)
+
+func (f RenameFlags) String() string {
+	if f == 0 {
+		return "0"
+	}
+
+	var s string
+	for _, n := range []struct {
+		flag RenameFlags
+		name string
+	}{
+		{RenameNoReplace, "NoReplace"},
+		{RenameExchange, "Exchange"},
+		{RenameWhiteout, "Whiteout"},
+	} {
+		if f&n.flag != 0 {
+			s += "+" + n.name
+			f &^= n.flag
+		}
+	}
+
+	if f != 0 {
+		s += fmt.Sprintf("%+#x", uint32(f))
+	}
+
+	return s[1:]
+}
+
+// FallocateMode is the mode of a FallocateOp, made of the FALLOC_FL_* flags
+// passed to fallocate(2).
+type FallocateMode uint32
+
+const (
+	// Allocate the range without changing the file's size, even if the range
+	// extends past its end. May be combined with the flags below, and must be
+	// with FallocatePunchHole.
+	FallocateKeepSize FallocateMode = 0x01
+
+	// Deallocate the range, so that reading it returns zeros.
+	FallocatePunchHole FallocateMode = 0x02
+
+	// Remove the range, shifting the data after it down, so that the file
+	// shrinks by its length.
+	FallocateCollapseRange FallocateMode = 0x08
+
+	// Set the range to zeros, allocating it as for a zero mode.
+	FallocateZeroRange FallocateMode = 0x10
+
+	// Insert a hole of the range's length at its start, shifting the data
+	// after it up, so that the file grows by its length.
+	FallocateInsertRange FallocateMode = 0x20
+)
+
+var fallocateModeNames = []struct {
+	mode FallocateMode
+	name string
+}{
+	{FallocateKeepSize, "KeepSize"},
+	{FallocatePunchHole, "PunchHole"},
+	{FallocateCollapseRange, "CollapseRange"},
+	{FallocateZeroRange, "ZeroRange"},
+	{FallocateInsertRange, "InsertRange"},
+}
+
+func (m FallocateMode) String() string {
+	if m == 0 {
+		return "0"
+	}
+
+	var s string
+	for _, n := range fallocateModeNames {
+		if m&n.mode != 0 {
+			s += "+" + n.name
+			m &^= n.mode
+		}
+	}
+
+	if m != 0 {
+		s += fmt.Sprintf("%+#x", uint32(m))
+	}
+
+	return s[1:]
+}
@@ -0,0 +1,291 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuseprobe reports what the running kernel's FUSE implementation
+// supports, by mounting a trivial file system and exercising it, to help
+// diagnose differences in behavior between environments. See
+// samples/probe_kernel for a command that prints the report.
+package fuseprobe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// Report describes what the kernel supports, as found by Probe.
+type Report struct {
+	// What the kernel offered when the probe file system was mounted, and what
+	// was negotiated with it.
+	fuse.KernelInfo
+
+	// The release of the running kernel, e.g. "6.8.0-45-generic", if known.
+	OSRelease string
+
+	// The sizes in bytes of the largest read and write requests sent by the
+	// kernel when a large file was read and written in one call each, which
+	// may be smaller than negotiated, e.g. because of readahead settings.
+	LargestRead  int
+	LargestWrite int
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// String formats the report for people, one item per line.
+func (r Report) String() string {
+	offered := func(name string) string {
+		return yesNo(slices.Contains(r.KernelFeatures, name))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "OS release:          %s\n", r.OSRelease)
+	fmt.Fprintf(&b, "Kernel protocol:     %v (using %v)\n", r.KernelProtocol, r.Protocol)
+	fmt.Fprintf(&b, "Offered features:    %s\n", strings.Join(r.KernelFeatures, " "))
+	fmt.Fprintf(&b, "Enabled features:    %s\n", strings.Join(r.Features, " "))
+	fmt.Fprintf(
+		&b,
+		"Splice offered:      read=%s write=%s move=%s\n",
+		offered("SPLICE_READ"),
+		offered("SPLICE_WRITE"),
+		offered("SPLICE_MOVE"))
+	fmt.Fprintf(&b, "Max readahead:       %d (kernel offered %d)\n", r.MaxReadahead, r.KernelMaxReadahead)
+	fmt.Fprintf(&b, "Max write:           %d\n", r.MaxWrite)
+	fmt.Fprintf(&b, "Max pages:           %d\n", r.MaxPages)
+	fmt.Fprintf(&b, "Max background:      %d (congested at %d)\n", r.MaxBackground, r.CongestionThreshold)
+	fmt.Fprintf(&b, "Largest read seen:   %d\n", r.LargestRead)
+	fmt.Fprintf(&b, "Largest write seen:  %d\n", r.LargestWrite)
+	return b.String()
+}
+
+// The size of the probe file, which is read and written in one call each.
+const probeSize = 4 << 20
+
+// Probe mounts a trivial file system in a temporary directory with the
+// supplied config, reads and writes a large file in it, and unmounts it,
+// reporting what the kernel supports. The config lets the features enabled be
+// compared with those a file system's own config enables. Mounting requires
+// the same privileges as mounting any other file system, e.g. fusermount(1)
+// on Linux.
+func Probe(ctx context.Context, cfg fuse.MountConfig) (Report, error) {
+	dir, err := os.MkdirTemp("", "fuseprobe")
+	if err != nil {
+		return Report{}, fmt.Errorf("MkdirTemp: %w", err)
+	}
+
+	defer os.Remove(dir)
+
+	fs := &probeFS{}
+	mfs, err := fuse.Mount(dir, fuseutil.NewFileSystemServer(fs), &cfg)
+	if err != nil {
+		return Report{}, fmt.Errorf("Mount: %w", err)
+	}
+
+	r := Report{KernelInfo: mfs.KernelInfo()}
+	if release, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		r.OSRelease = strings.TrimSpace(string(release))
+	}
+
+	exerciseErr := exercise(path.Join(dir, probeName))
+	if err := unmount(ctx, dir); err != nil {
+		return Report{}, err
+	}
+
+	if err := mfs.Join(ctx); err != nil {
+		return Report{}, fmt.Errorf("Join: %w", err)
+	}
+
+	if exerciseErr != nil {
+		return Report{}, exerciseErr
+	}
+
+	r.LargestRead = int(fs.largestRead.Load())
+	r.LargestWrite = int(fs.largestWrite.Load())
+	return r, nil
+}
+
+// Read and write the probe file.
+func exercise(name string) error {
+	if _, err := os.ReadFile(name); err != nil {
+		return fmt.Errorf("ReadFile: %w", err)
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("OpenFile: %w", err)
+	}
+
+	if _, err := f.Write(make([]byte, probeSize)); err != nil {
+		f.Close()
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	return nil
+}
+
+// Unmount the directory, trying again while it is busy, e.g. because the
+// kernel hasn't finished releasing the probe file.
+func unmount(ctx context.Context, dir string) error {
+	for {
+		err := fuse.Unmount(dir)
+		if err == nil {
+			return nil
+		}
+
+		if !strings.Contains(err.Error(), "busy") {
+			return fmt.Errorf("Unmount: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Unmount: %w", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// File system
+////////////////////////////////////////////////////////////////////////
+
+const (
+	probeName  = "probe"
+	probeInode = fuseops.RootInodeID + 1
+)
+
+// A file system containing a single file of zeros, which discards writes,
+// recording the largest reads and writes it sees.
+type probeFS struct {
+	fuseutil.NotImplementedFileSystem
+	largestRead  atomic.Int64
+	largestWrite atomic.Int64
+}
+
+func (fs *probeFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{
+			Nlink: 1,
+			Mode:  0755 | os.ModeDir,
+			Uid:   uint32(os.Getuid()),
+			Gid:   uint32(os.Getgid()),
+		}
+	}
+
+	return fuseops.InodeAttributes{
+		Size:  probeSize,
+		Nlink: 1,
+		Mode:  0644,
+		Uid:   uint32(os.Getuid()),
+		Gid:   uint32(os.Getgid()),
+	}
+}
+
+func record(largest *atomic.Int64, n int64) {
+	for {
+		old := largest.Load()
+		if n <= old || largest.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+func (fs *probeFS) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) error {
+	return nil
+}
+
+func (fs *probeFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != probeName {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = probeInode
+	op.Entry.Attributes = fs.attributes(probeInode)
+	return nil
+}
+
+func (fs *probeFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *probeFS) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *probeFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	return nil
+}
+
+func (fs *probeFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *probeFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	record(&fs.largestRead, op.Size)
+	op.BytesRead = int(max(0, min(int64(len(op.Dst)), probeSize-op.Offset)))
+	clear(op.Dst[:op.BytesRead])
+	return nil
+}
+
+func (fs *probeFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	record(&fs.largestWrite, int64(len(op.Data)))
+	return nil
+}
+
+func (fs *probeFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	return nil
+}
+
+func (fs *probeFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package fuseprobe_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseprobe"
+)
+
+func TestProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	r, err := fuseprobe.Probe(ctx, fuse.MountConfig{MaxProtocolMinor: 28})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+
+	if r.KernelProtocol.Major != 7 || r.Protocol != (fuse.Protocol{Major: 7, Minor: 28}) {
+		t.Errorf("Protocols: %v, %v", r.KernelProtocol, r.Protocol)
+	}
+
+	// Big writes are always enabled, and features from later versions never
+	// are.
+	if !slices.Contains(r.Features, "BIG_WRITES") || slices.Contains(r.Features, "NO_OPENDIR_SUPPORT") {
+		t.Errorf("Features: %v", r.Features)
+	}
+
+	if r.MaxWrite == 0 || r.LargestWrite == 0 || r.LargestWrite > int(r.MaxWrite) {
+		t.Errorf("MaxWrite %d, LargestWrite %d", r.MaxWrite, r.LargestWrite)
+	}
+
+	if r.LargestRead == 0 {
+		t.Errorf("LargestRead is zero")
+	}
+
+	if s := r.String(); !strings.Contains(s, "Kernel protocol:") {
+		t.Errorf("String: %q", s)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fusetesting
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	. "github.com/jacobsa/ogletest"
+	"github.com/jacobsa/syncutil"
+)
+
+// Create n empty files named "file_0", "file_1", etc. in the directory.
+func CreateFiles(dir string, n int) error {
+	for i := 0; i < n; i++ {
+		f, err := os.Create(path.Join(dir, fmt.Sprintf("file_%d", i)))
+		if err != nil {
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run an ogletest test that lists a directory containing n files in small
+// batches while other entries are concurrently created and removed, checking
+// the guarantees Posix makes for readdir(3) under concurrent modification:
+//
+//   - Every entry that exists for the whole listing is returned exactly once.
+//
+//   - No entry is returned more than once, even one added or removed during the
+//     listing (which may or may not be returned).
+//
+// The library passes the offsets chosen by the file system through to the
+// kernel unchanged (see fuseops.ReadDirOp.Offset), so this holds only if the
+// file system's offsets remain stable across modification. The test therefore
+// checks the file system as much as the library.
+func RunReadDirWithConcurrentMutationTest(
+	ctx context.Context,
+	dir string,
+	n int) {
+	err := CreateFiles(dir, n)
+	AssertEq(nil, err)
+
+	// Churn other entries until the listing is complete.
+	b := syncutil.NewBundle(ctx)
+	done := make(chan struct{})
+	b.Add(func(ctx context.Context) error {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+
+			name := path.Join(dir, fmt.Sprintf("churn_%d", i))
+			if err := os.WriteFile(name, nil, 0600); err != nil {
+				return err
+			}
+
+			if i%2 == 0 {
+				if err := os.Remove(name); err != nil {
+					return err
+				}
+			}
+		}
+	})
+
+	// List the directory in small batches, so that the kernel issues many
+	// ReadDir ops with non-zero offsets.
+	seen := make(map[string]int)
+	b.Add(func(ctx context.Context) error {
+		defer close(done)
+
+		f, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		for {
+			names, err := f.Readdirnames(64)
+			for _, name := range names {
+				seen[name]++
+			}
+
+			if err == io.EOF {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	})
+
+	err = b.Join()
+	AssertEq(nil, err)
+
+	for name, count := range seen {
+		ExpectEq(1, count, "%s", name)
+	}
+
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("file_%d", i)
+		ExpectEq(1, seen[name], "%s", name)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fusetesting
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"syscall"
+)
+
+// ReadFileMmapped reads the whole of the file at the supplied path, to the
+// size reported by stat, through a shared read-only mapping, as a program
+// using mmap(2) would. Unlike such a program, it returns an error rather than
+// crashing if a page can't be read, e.g. with SIGBUS because the file system
+// returned less data than the size it reported.
+func ReadFileMmapped(path string) (contents []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(fi.Size())
+	if size == 0 {
+		return []byte{}, nil
+	}
+
+	m, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("Mmap: %w", err)
+	}
+
+	defer syscall.Munmap(m)
+
+	// Turn a fault accessing the mapping into a panic that can be recovered
+	// from, rather than a fatal error.
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			contents = nil
+			err = fmt.Errorf("reading %d bytes of %s through mmap: %v", size, path, r)
+		}
+	}()
+
+	contents = make([]byte, size)
+	copy(contents, m)
+	return contents, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"os"
+	"unsafe"
+)
+
+// IsPageAligned reports whether the buffer begins on a page boundary and its
+// length is a whole number of pages, as required for direct I/O. File systems
+// may use it to decide whether fuseops.ReadFileOp.Dst can be filled by an
+// O_DIRECT read, falling back to a buffered read if not.
+func IsPageAligned(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	pageSize := os.Getpagesize()
+	return uintptr(unsafe.Pointer(&b[0]))%uintptr(pageSize) == 0 &&
+		len(b)%pageSize == 0
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// AppendConfig configures an AppendFileSystem.
+type AppendConfig struct {
+	// Returns the size of the file in the backend, at which the next append to
+	// it is to be written. It is called for each write through a handle opened
+	// with O_APPEND, while no other such write to the same file is in progress.
+	// If nil, the size returned by the wrapped file system's GetInodeAttributes
+	// is used, which must then come from the backend rather than a cache.
+	Size func(ctx context.Context, inode fuseops.InodeID) (uint64, error)
+
+	// If non-nil, used to invalidate the kernel's page cache for a file after
+	// an append is written somewhere other than where the kernel asked, since
+	// the kernel has cached the data at the offset it sent. This happens
+	// asynchronously, as the kernel can't invalidate pages while the write is
+	// outstanding. Not needed if such handles are opened with
+	// OpenFileOp.UseDirectIO.
+	Notifier *fuse.Notifier
+}
+
+// AppendFileSystem is a FileSystem that writes the data written through
+// handles opened with O_APPEND at the end of the file as the backend sees it,
+// rather than where the kernel thinks the end is. Create one with
+// NewAppendFileSystem.
+//
+// Without writeback caching, the kernel handles O_APPEND by sending each write
+// at the file size it last saw, and serializes the appends made through the
+// mount. When several clients append to the same file on a network backend,
+// e.g. processes on different machines writing one log, a client's idea of the
+// size is soon stale, and its appends overwrite the others'. An
+// AppendFileSystem instead writes each append at the size returned by
+// AppendConfig.Size, one append to a file at a time, so that each lands whole
+// after those before it, as POSIX promises for O_APPEND. This requires that
+// the backend's size not change between the call to Size and the write, e.g.
+// because the file system writes with a conditional request that it retries,
+// or because appends from all clients go through one server.
+//
+// Handles are tracked from OpenFile and CreateFile to ReleaseFileHandle, by
+// the flags they were opened with: O_APPEND set later with fcntl(2) isn't
+// seen. Writes through other handles are passed on unchanged. With writeback
+// caching, the kernel handles O_APPEND itself and may send the data through
+// any handle, so this must be used only with
+// fuse.MountConfig.DisableWritebackCaching.
+type AppendFileSystem struct {
+	FileSystem
+	cfg AppendConfig
+
+	mu sync.Mutex
+
+	// The number of unreleased issues of each handle opened with O_APPEND.
+	//
+	// GUARDED_BY(mu)
+	handles map[fuseops.HandleID]int
+
+	// The lock of each file with an append in progress or waiting.
+	//
+	// GUARDED_BY(mu)
+	files map[fuseops.InodeID]*appendLock
+}
+
+type appendLock struct {
+	// Held while appending to the file.
+	mu sync.Mutex
+
+	// The number of appends holding or waiting for mu.
+	//
+	// GUARDED_BY(AppendFileSystem.mu)
+	users int
+}
+
+// NewAppendFileSystem wraps the supplied file system, placing appends as
+// described on AppendFileSystem.
+func NewAppendFileSystem(
+	wrapped FileSystem,
+	cfg AppendConfig) *AppendFileSystem {
+	return &AppendFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		handles:    make(map[fuseops.HandleID]int),
+		files:      make(map[fuseops.InodeID]*appendLock),
+	}
+}
+
+// Return the file's append lock, locked. The caller must call unlock.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *AppendFileSystem) lock(inode fuseops.InodeID) *appendLock {
+	fs.mu.Lock()
+	l := fs.files[inode]
+	if l == nil {
+		l = &appendLock{}
+		fs.files[inode] = l
+	}
+
+	l.users++
+	fs.mu.Unlock()
+
+	l.mu.Lock()
+	return l
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *AppendFileSystem) unlock(inode fuseops.InodeID, l *appendLock) {
+	l.mu.Unlock()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if l.users--; l.users == 0 {
+		delete(fs.files, inode)
+	}
+}
+
+// Record a handle opened by a successful op, if opened with O_APPEND.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *AppendFileSystem) opened(
+	err error,
+	handle fuseops.HandleID,
+	appending bool) error {
+	if err == nil && appending {
+		fs.mu.Lock()
+		fs.handles[handle]++
+		fs.mu.Unlock()
+	}
+
+	return err
+}
+
+// Return the size of the file in the backend.
+func (fs *AppendFileSystem) size(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) (uint64, error) {
+	if fs.cfg.Size != nil {
+		return fs.cfg.Size(ctx, op.Inode)
+	}
+
+	attrs := &fuseops.GetInodeAttributesOp{
+		Inode:     op.Inode,
+		OpContext: op.OpContext,
+	}
+
+	if err := fs.FileSystem.GetInodeAttributes(ctx, attrs); err != nil {
+		return 0, err
+	}
+
+	return attrs.Attributes.Size, nil
+}
+
+func (fs *AppendFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	return fs.opened(
+		fs.FileSystem.OpenFile(ctx, op),
+		op.Handle,
+		op.OpenFlags.IsAppend())
+}
+
+func (fs *AppendFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	return fs.opened(
+		fs.FileSystem.CreateFile(ctx, op),
+		op.Handle,
+		op.OpenFlags.IsAppend())
+}
+
+func (fs *AppendFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.mu.Lock()
+	appending := fs.handles[op.Handle] != 0
+	fs.mu.Unlock()
+
+	if !appending {
+		return fs.FileSystem.WriteFile(ctx, op)
+	}
+
+	l := fs.lock(op.Inode)
+	defer fs.unlock(op.Inode, l)
+
+	size, err := fs.size(ctx, op)
+	if err != nil {
+		return err
+	}
+
+	moved := op.Offset != int64(size)
+	op.Offset = int64(size)
+	if err := fs.FileSystem.WriteFile(ctx, op); err != nil {
+		return err
+	}
+
+	if moved && fs.cfg.Notifier != nil {
+		go fs.cfg.Notifier.InvalidateInode(op.Inode, 0, 0)
+	}
+
+	return nil
+}
+
+func (fs *AppendFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	fs.mu.Lock()
+	if n, ok := fs.handles[op.Handle]; ok {
+		if n == 1 {
+			delete(fs.handles, op.Handle)
+		} else {
+			fs.handles[op.Handle] = n - 1
+		}
+	}
+	fs.mu.Unlock()
+
+	return fs.FileSystem.ReleaseFileHandle(ctx, op)
+}
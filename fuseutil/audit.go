@@ -0,0 +1,410 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// AuditConfig configures an AuditFileSystem.
+type AuditConfig struct {
+	// Receives a record of each successful mutating op, once the wrapped file
+	// system has returned from it and before the reply is sent to the kernel.
+	// It is called concurrently for concurrent ops, so it must be safe for that,
+	// and should hand the record off quickly, e.g. to a buffered channel, since
+	// the caller waits on it. Must be non-nil.
+	Sink func(AuditRecord)
+}
+
+// AuditRecord describes a mutating op that succeeded. Fields that don't apply
+// to the op are zero.
+type AuditRecord struct {
+	// The name of the op's type, e.g. "CreateFileOp", as in WireLogRecord.
+	Operation string
+
+	// When the op was passed to the wrapped file system, and how long it took.
+	StartTime time.Time
+	Duration  time.Duration
+
+	// The calling process. For writes the kernel makes from its page cache
+	// with writeback caching, the IDs are zero rather than those of the process
+	// that wrote the data.
+	Context fuseops.OpContext
+
+	// The inode the op changed, or the one it created. Zero for UnlinkOp and
+	// RmDirOp, whose ops name only the entry removed.
+	Inode fuseops.InodeID
+
+	// The directory entry the op created or removed, or for RenameOp the one it
+	// renamed.
+	Parent fuseops.InodeID
+	Name   string
+
+	// For RenameOp, the entry's new name.
+	NewParent fuseops.InodeID
+	NewName   string
+
+	// For WriteFileOp, FallocateOp and CopyFileRangeOp, the range of the file
+	// changed. The data itself isn't recorded.
+	Offset int64
+	Length int64
+
+	// For SetInodeAttributesOp, the attributes set, keyed by "size", "mode",
+	// "uid", "gid", "atime", "mtime" or "ctime", with values of the types of the
+	// op's fields, dereferenced.
+	Attributes map[string]any
+
+	// For SetXattrOp and RemoveXattrOp, the name of the extended attribute.
+	Xattr string
+}
+
+// AuditFileSystem is a FileSystem that reports each successful op changing the
+// file system's contents to AuditConfig.Sink, e.g. for deployments that must
+// keep an audit log of who changed what. Create one with NewAuditFileSystem.
+//
+// The ops reported are MkDirOp, MkNodeOp, CreateFileOp, CreateTmpfileOp,
+// CreateSymlinkOp, CreateLinkOp, RenameOp, UnlinkOp, RmDirOp, WriteFileOp,
+// FallocateOp, CopyFileRangeOp, SetInodeAttributesOp, SetXattrOp and
+// RemoveXattrOp. Failed ops aren't reported. Inodes are identified by ID
+// only; a sink wanting paths can combine the records of the ops that created
+// and renamed them, or wrap a ParentTrackingFileSystem and ask it.
+type AuditFileSystem struct {
+	FileSystem
+	cfg AuditConfig
+}
+
+// NewAuditFileSystem wraps the supplied file system, reporting the ops it
+// serves as described on AuditFileSystem.
+func NewAuditFileSystem(
+	wrapped FileSystem,
+	cfg AuditConfig) *AuditFileSystem {
+	return &AuditFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Fill in the record's timing and pass it to the sink.
+func (fs *AuditFileSystem) emit(start time.Time, r AuditRecord) {
+	r.StartTime = start
+	r.Duration = time.Since(start)
+	fs.cfg.Sink(r)
+}
+
+func (fs *AuditFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.MkDir(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "MkDirOp",
+		Context:   op.OpContext,
+		Inode:     op.Entry.Child,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.MkNode(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "MkNodeOp",
+		Context:   op.OpContext,
+		Inode:     op.Entry.Child,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.CreateFile(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "CreateFileOp",
+		Context:   op.OpContext,
+		Inode:     op.Entry.Child,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.CreateTmpfile(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "CreateTmpfileOp",
+		Context:   op.OpContext,
+		Inode:     op.Entry.Child,
+		Parent:    op.Parent,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.CreateSymlink(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "CreateSymlinkOp",
+		Context:   op.OpContext,
+		Inode:     op.Entry.Child,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.CreateLink(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "CreateLinkOp",
+		Context:   op.OpContext,
+		Inode:     op.Target,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.Rename(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "RenameOp",
+		Context:   op.OpContext,
+		Parent:    op.OldParent,
+		Name:      op.OldName,
+		NewParent: op.NewParent,
+		NewName:   op.NewName,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.Unlink(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "UnlinkOp",
+		Context:   op.OpContext,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.RmDir(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "RmDirOp",
+		Context:   op.OpContext,
+		Parent:    op.Parent,
+		Name:      op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.WriteFile(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "WriteFileOp",
+		Context:   op.OpContext,
+		Inode:     op.Inode,
+		Offset:    op.Offset,
+		Length:    int64(len(op.Data)),
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.Fallocate(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "FallocateOp",
+		Context:   op.OpContext,
+		Inode:     op.Inode,
+		Offset:    int64(op.Offset),
+		Length:    int64(op.Length),
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.CopyFileRange(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "CopyFileRangeOp",
+		Context:   op.OpContext,
+		Inode:     op.DstInode,
+		Offset:    int64(op.DstOffset),
+		Length:    int64(op.BytesCopied),
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.SetInodeAttributes(ctx, op); err != nil {
+		return err
+	}
+
+	attrs := make(map[string]any)
+	if op.Size != nil {
+		attrs["size"] = *op.Size
+	}
+	if op.Mode != nil {
+		attrs["mode"] = *op.Mode
+	}
+	if op.Uid != nil {
+		attrs["uid"] = *op.Uid
+	}
+	if op.Gid != nil {
+		attrs["gid"] = *op.Gid
+	}
+	if op.Atime != nil {
+		attrs["atime"] = *op.Atime
+	}
+	if op.Mtime != nil {
+		attrs["mtime"] = *op.Mtime
+	}
+	if op.Ctime != nil {
+		attrs["ctime"] = *op.Ctime
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation:  "SetInodeAttributesOp",
+		Context:    op.OpContext,
+		Inode:      op.Inode,
+		Attributes: attrs,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.SetXattr(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "SetXattrOp",
+		Context:   op.OpContext,
+		Inode:     op.Inode,
+		Xattr:     op.Name,
+	})
+
+	return nil
+}
+
+func (fs *AuditFileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	start := time.Now()
+	if err := fs.FileSystem.RemoveXattr(ctx, op); err != nil {
+		return err
+	}
+
+	fs.emit(start, AuditRecord{
+		Operation: "RemoveXattrOp",
+		Context:   op.OpContext,
+		Inode:     op.Inode,
+		Xattr:     op.Name,
+	})
+
+	return nil
+}
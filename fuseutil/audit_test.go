@@ -0,0 +1,73 @@
+package fuseutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system that creates directories as inode 17, accepts writes and
+// attribute changes, and fails unlinks.
+type auditedFS struct {
+	NotImplementedFileSystem
+}
+
+func (fs *auditedFS) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	op.Entry.Child = 17
+	return nil
+}
+
+func (fs *auditedFS) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	return nil
+}
+
+func (fs *auditedFS) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	return nil
+}
+
+func (fs *auditedFS) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return fuse.ENOENT
+}
+
+func TestAuditFileSystem(t *testing.T) {
+	var records []AuditRecord
+	fs := NewAuditFileSystem(&auditedFS{}, AuditConfig{
+		Sink: func(r AuditRecord) { records = append(records, r) },
+	})
+
+	ctx := context.Background()
+	caller := fuseops.OpContext{Pid: 1, Uid: 2, Gid: 3}
+	size := uint64(5)
+
+	fs.MkDir(ctx, &fuseops.MkDirOp{Parent: 1, Name: "foo", OpContext: caller})
+	fs.WriteFile(ctx, &fuseops.WriteFileOp{Inode: 17, Offset: 10, Data: []byte("taco"), OpContext: caller})
+	fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Inode: 17, Size: &size, OpContext: caller})
+
+	// Failed ops aren't reported.
+	if err := fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "bar"}); err != fuse.ENOENT {
+		t.Errorf("Unlink: got %v, want ENOENT", err)
+	}
+
+	want := []AuditRecord{
+		{Operation: "MkDirOp", Context: caller, Inode: 17, Parent: 1, Name: "foo"},
+		{Operation: "WriteFileOp", Context: caller, Inode: 17, Offset: 10, Length: 4},
+		{Operation: "SetInodeAttributesOp", Context: caller, Inode: 17, Attributes: map[string]any{"size": size}},
+	}
+
+	for i := range records {
+		if records[i].StartTime.IsZero() {
+			t.Errorf("Record %d has no start time", i)
+		}
+
+		records[i].StartTime = time.Time{}
+		records[i].Duration = 0
+	}
+
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Records: got %+v, want %+v", records, want)
+	}
+}
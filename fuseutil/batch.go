@@ -0,0 +1,227 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// LookUpInodeBatcher may optionally be implemented by a FileSystem served with
+// a non-zero ServerConfig.BatchWindow. Lookups within the same parent directory
+// that arrive close together are then delivered in a single call, allowing
+// the file system to satisfy them with one backend request.
+//
+// The result must contain one error per op, in the same order; the library
+// replies to each op with its own error. If the slice has the wrong length,
+// every op in the batch fails with EIO.
+//
+// The context is cancelled only once every op in the batch has been
+// interrupted.
+type LookUpInodeBatcher interface {
+	LookUpInodeBatch(
+		ctx context.Context,
+		ops []*fuseops.LookUpInodeOp) []error
+}
+
+// ReadFileBatcher is like LookUpInodeBatcher, for reads from the same file
+// handle. The kernel issues readahead as a series of contiguous reads, which
+// may be served by a single ranged backend request.
+type ReadFileBatcher interface {
+	ReadFileBatch(
+		ctx context.Context,
+		ops []*fuseops.ReadFileOp) []error
+}
+
+type batchKind int
+
+const (
+	lookUpBatch batchKind = iota
+	readBatch
+)
+
+type batchKey struct {
+	kind batchKind
+	id   uint64
+}
+
+type opBatch struct {
+	ctxs []context.Context
+	ops  []interface{}
+
+	// Set by run. done is closed once errs is available.
+	started bool
+	errs    []error
+	done    chan struct{}
+}
+
+// batcher gathers compatible ops into batches, holding each op's goroutine
+// until its batch has been processed.
+type batcher struct {
+	lookUps LookUpInodeBatcher
+	reads   ReadFileBatcher
+	window  time.Duration
+	maxSize int
+
+	mu sync.Mutex
+
+	// Batches that are still accepting ops.
+	//
+	// GUARDED_BY(mu)
+	pending map[batchKey]*opBatch
+}
+
+func newBatcher(
+	fs FileSystem,
+	window time.Duration,
+	maxSize int) *batcher {
+	if maxSize <= 0 {
+		maxSize = 64
+	}
+
+	b := &batcher{
+		window:  window,
+		maxSize: maxSize,
+		pending: make(map[batchKey]*opBatch),
+	}
+
+	b.lookUps, _ = fs.(LookUpInodeBatcher)
+	b.reads, _ = fs.(ReadFileBatcher)
+
+	return b
+}
+
+// If the op is batchable, add it to a batch, wait for the batch to be
+// processed, and return true along with the op's error. Otherwise return
+// false.
+func (b *batcher) dispatch(
+	ctx context.Context,
+	op interface{}) (bool, error) {
+	var key batchKey
+	switch typed := op.(type) {
+	case *fuseops.LookUpInodeOp:
+		if b.lookUps == nil {
+			return false, nil
+		}
+		key = batchKey{lookUpBatch, uint64(typed.Parent)}
+
+	case *fuseops.ReadFileOp:
+		if b.reads == nil {
+			return false, nil
+		}
+		key = batchKey{readBatch, uint64(typed.Handle)}
+
+	default:
+		return false, nil
+	}
+
+	b.mu.Lock()
+	batch := b.pending[key]
+	if batch == nil {
+		batch = &opBatch{done: make(chan struct{})}
+		b.pending[key] = batch
+		time.AfterFunc(b.window, func() { b.run(key, batch) })
+	}
+
+	i := len(batch.ops)
+	batch.ctxs = append(batch.ctxs, ctx)
+	batch.ops = append(batch.ops, op)
+	full := len(batch.ops) >= b.maxSize
+	if full {
+		delete(b.pending, key)
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.run(key, batch)
+	}
+
+	<-batch.done
+	return true, batch.errs[i]
+}
+
+// Hand the batch to the file system, if that hasn't already happened.
+func (b *batcher) run(key batchKey, batch *opBatch) {
+	b.mu.Lock()
+	if batch.started {
+		b.mu.Unlock()
+		return
+	}
+
+	batch.started = true
+	if b.pending[key] == batch {
+		delete(b.pending, key)
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := mergeContexts(batch.ctxs)
+	defer cancel()
+
+	var errs []error
+	switch key.kind {
+	case lookUpBatch:
+		ops := make([]*fuseops.LookUpInodeOp, len(batch.ops))
+		for i, op := range batch.ops {
+			ops[i] = op.(*fuseops.LookUpInodeOp)
+		}
+		errs = b.lookUps.LookUpInodeBatch(ctx, ops)
+
+	case readBatch:
+		ops := make([]*fuseops.ReadFileOp, len(batch.ops))
+		for i, op := range batch.ops {
+			ops[i] = op.(*fuseops.ReadFileOp)
+		}
+		errs = b.reads.ReadFileBatch(ctx, ops)
+	}
+
+	if len(errs) != len(batch.ops) {
+		errs = make([]error, len(batch.ops))
+		for i := range errs {
+			errs[i] = fuse.EIO
+		}
+	}
+
+	batch.errs = errs
+	close(batch.done)
+}
+
+// Return a context carrying the values of the first context, which is
+// cancelled once all of the supplied contexts have been cancelled.
+func mergeContexts(
+	ctxs []context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctxs[0]))
+
+	remaining := int64(len(ctxs))
+	stops := make([]func() bool, len(ctxs))
+	for i, c := range ctxs {
+		stops[i] = context.AfterFunc(c, func() {
+			if atomic.AddInt64(&remaining, -1) == 0 {
+				cancel()
+			}
+		})
+	}
+
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,125 @@
+package fuseutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type batchingFS struct {
+	NotImplementedFileSystem
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (fs *batchingFS) LookUpInodeBatch(
+	ctx context.Context,
+	ops []*fuseops.LookUpInodeOp) []error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var names []string
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		names = append(names, op.Name)
+		if op.Name == "missing" {
+			errs[i] = fuse.ENOENT
+			continue
+		}
+
+		op.Entry.Child = fuseops.InodeID(100 + len(op.Name))
+	}
+
+	fs.batches = append(fs.batches, names)
+	return errs
+}
+
+func TestLookUpInodeBatching(t *testing.T) {
+	fs := &batchingFS{}
+	s := NewFileSystemServerWithConfig(fs, &ServerConfig{
+		BatchWindow: 50 * time.Millisecond,
+	}).(*fileSystemServer)
+
+	names := []string{"a", "bb", "missing"}
+	ops := make([]*fuseops.LookUpInodeOp, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		ops[i] = &fuseops.LookUpInodeOp{Parent: 1, Name: name}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.dispatch(context.Background(), ops[i])
+		}(i)
+	}
+	wg.Wait()
+
+	if len(fs.batches) != 1 || len(fs.batches[0]) != 3 {
+		t.Fatalf("unexpected batches: %v", fs.batches)
+	}
+
+	for i, name := range names {
+		if name == "missing" {
+			if errs[i] != fuse.ENOENT {
+				t.Errorf("%s: got %v, want ENOENT", name, errs[i])
+			}
+			continue
+		}
+
+		if errs[i] != nil {
+			t.Errorf("%s: %v", name, errs[i])
+		}
+
+		if want := fuseops.InodeID(100 + len(name)); ops[i].Entry.Child != want {
+			t.Errorf("%s: got child %d, want %d", name, ops[i].Entry.Child, want)
+		}
+	}
+}
+
+func TestBatchesAreSplitByParentAndSize(t *testing.T) {
+	fs := &batchingFS{}
+	s := NewFileSystemServerWithConfig(fs, &ServerConfig{
+		BatchWindow:  50 * time.Millisecond,
+		MaxBatchSize: 2,
+	}).(*fileSystemServer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		op := &fuseops.LookUpInodeOp{
+			Parent: fuseops.InodeID(1 + i%2),
+			Name:   fmt.Sprint(i),
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.dispatch(context.Background(), op)
+		}()
+	}
+	wg.Wait()
+
+	if len(fs.batches) != 2 {
+		t.Fatalf("unexpected batches: %v", fs.batches)
+	}
+}
+
+func TestBatchingDisabledByDefault(t *testing.T) {
+	fs := &batchingFS{}
+	s := NewFileSystemServer(fs).(*fileSystemServer)
+
+	err := s.dispatch(context.Background(), &fuseops.LookUpInodeOp{Parent: 1})
+	if err != fuse.ENOSYS {
+		t.Errorf("got %v, want ENOSYS", err)
+	}
+
+	if len(fs.batches) != 0 {
+		t.Errorf("unexpected batches: %v", fs.batches)
+	}
+}
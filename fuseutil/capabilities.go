@@ -0,0 +1,207 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Capabilities reports which FileSystem methods a file system implements,
+// keyed by method name, e.g. "LookUpInode", which is also the name of the
+// method's op type without the "Op" suffix. Methods inherited from
+// NotImplementedFileSystem are reported as false. Destroy is not included.
+//
+// Capabilities encodes naturally as a JSON object, for use by tooling.
+type Capabilities map[string]bool
+
+// Implements reports whether the file system implements the method for the
+// supplied op, e.g. a *fuseops.ReadFileOp.
+func (c Capabilities) Implements(op interface{}) bool {
+	t := reflect.TypeOf(op)
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return c[strings.TrimSuffix(t.Name(), "Op")]
+}
+
+// CapabilityReporter is implemented by values that can report the
+// capabilities of a file system, including the fuse.Server returned by
+// NewFileSystemServer.
+//
+// A FileSystem that wraps another and whose methods merely forward to it may
+// implement CapabilityReporter to report the wrapped file system's
+// capabilities, rather than its own.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf reports which methods the supplied file system implements.
+//
+// It does so by examining the file system's type with reflection, following
+// embedded fields, including embedded FileSystem interfaces, to find where
+// each method comes from. A method declared by any type other than
+// NotImplementedFileSystem is considered implemented, even if it, too, only
+// returns ENOSYS.
+func CapabilitiesOf(fs FileSystem) Capabilities {
+	c := make(Capabilities)
+	t := reflect.TypeOf((*FileSystem)(nil)).Elem()
+	for i := 0; i < t.NumMethod(); i++ {
+		name := t.Method(i).Name
+		if name != "Destroy" {
+			c[name] = implemented(reflect.ValueOf(fs), name)
+		}
+	}
+
+	return c
+}
+
+var (
+	notImplementedType = reflect.TypeOf(NotImplementedFileSystem{})
+	reporterType       = reflect.TypeOf((*CapabilityReporter)(nil)).Elem()
+)
+
+// Report whether the value's method with the given name is anything other
+// than NotImplementedFileSystem's. In case of doubt, report true.
+func implemented(v reflect.Value, name string) bool {
+	for v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	if t == notImplementedType || t == reflect.PointerTo(notImplementedType) {
+		return false
+	}
+
+	if declares(t, "Capabilities") && t.Implements(reporterType) && v.CanInterface() {
+		return v.Interface().(CapabilityReporter).Capabilities()[name]
+	}
+
+	if declares(t, name) {
+		return true
+	}
+
+	// The method must be promoted from an embedded field. Find the one in which
+	// it is declared least deeply, as the compiler does.
+	if t.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true
+		}
+
+		v = v.Elem()
+		t = v.Type()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+
+	var field reflect.Value
+	best := -1
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).Anonymous {
+			continue
+		}
+
+		d := methodDepth(t.Field(i).Type, name)
+		if d >= 0 && (best < 0 || d < best) {
+			field = v.Field(i)
+			best = d
+		}
+	}
+
+	if best < 0 {
+		return true
+	}
+
+	if field.Kind() == reflect.Struct && field.CanAddr() {
+		field = field.Addr()
+	}
+
+	return implemented(field, name)
+}
+
+// Return the depth of embedding at which the type's method with the given
+// name is declared, zero meaning the type itself, or -1 if it has no such
+// method.
+func methodDepth(t reflect.Type, name string) int {
+	if t.Kind() == reflect.Interface {
+		if _, ok := t.MethodByName(name); ok {
+			return 0
+		}
+
+		return -1
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if declares(reflect.PointerTo(t), name) {
+		return 0
+	}
+
+	if t.Kind() != reflect.Struct {
+		return -1
+	}
+
+	best := -1
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).Anonymous {
+			continue
+		}
+
+		if d := methodDepth(t.Field(i).Type, name); d >= 0 && (best < 0 || d+1 < best) {
+			best = d + 1
+		}
+	}
+
+	return best
+}
+
+// Report whether the type itself declares the method with the given name, as
+// opposed to its being promoted from an embedded field. Promoted methods, and
+// pointer methods for methods with value receivers, are implemented by
+// wrappers that the compiler generates.
+func declares(t reflect.Type, name string) bool {
+	types := []reflect.Type{t}
+	if t.Kind() == reflect.Ptr {
+		types = append(types, t.Elem())
+	}
+
+	for _, t := range types {
+		m, ok := t.MethodByName(name)
+		if !ok {
+			continue
+		}
+
+		f := runtime.FuncForPC(m.Func.Pointer())
+		if file, _ := f.FileLine(f.Entry()); file != "<autogenerated>" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package fuseutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type partialFS struct {
+	NotImplementedFileSystem
+}
+
+func (fs *partialFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	return nil
+}
+
+func (fs partialFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	return nil
+}
+
+// Embeds partialFS by pointer and adds a method of its own.
+type deeperFS struct {
+	*partialFS
+}
+
+func (fs *deeperFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	return nil
+}
+
+// Forwards everything to the wrapped file system, and says so.
+type forwardingFS struct {
+	FileSystem
+}
+
+func (fs *forwardingFS) StatFS(ctx context.Context, op *fuseops.StatFSOp) error {
+	return fs.FileSystem.StatFS(ctx, op)
+}
+
+func (fs *forwardingFS) Capabilities() Capabilities {
+	return CapabilitiesOf(fs.FileSystem)
+}
+
+func TestCapabilitiesOf(t *testing.T) {
+	check := func(desc string, c Capabilities, implemented ...string) {
+		t.Helper()
+		want := make(map[string]bool)
+		for _, name := range implemented {
+			want[name] = true
+		}
+
+		if len(c) != 44 {
+			t.Errorf("%s: got %d entries, want 44", desc, len(c))
+		}
+
+		for name, ok := range c {
+			if ok != want[name] {
+				t.Errorf("%s: %s: got %v, want %v", desc, name, ok, want[name])
+			}
+		}
+	}
+
+	check("NotImplementedFileSystem", CapabilitiesOf(&NotImplementedFileSystem{}))
+	check("partialFS", CapabilitiesOf(&partialFS{}), "LookUpInode", "ForgetInode")
+	check("deeperFS", CapabilitiesOf(&deeperFS{&partialFS{}}), "LookUpInode", "ForgetInode", "ReadFile")
+
+	// Methods of a wrapper count as implemented, but those it inherits from the
+	// wrapped file system are looked up in that.
+	cached := NewMetadataCacheFileSystem(&partialFS{}, MetadataCacheConfig{TTL: time.Second})
+	check("MetadataCacheFileSystem", CapabilitiesOf(cached),
+		"LookUpInode", "ForgetInode", "BatchForget",
+		"GetXattr", "ListXattr", "SetXattr", "RemoveXattr", "ReadSymlink")
+
+	// Unless the wrapper reports the wrapped file system's capabilities itself.
+	check("forwardingFS", CapabilitiesOf(&forwardingFS{&partialFS{}}), "LookUpInode", "ForgetInode")
+
+	// The server also reports BatchForget, which falls back to ForgetInode.
+	server := NewFileSystemServer(&partialFS{}).(CapabilityReporter)
+	c := server.Capabilities()
+	check("server", c, "LookUpInode", "ForgetInode", "BatchForget")
+
+	if !c.Implements(&fuseops.LookUpInodeOp{}) || c.Implements(&fuseops.ReadFileOp{}) {
+		t.Errorf("Implements: unexpected result")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// FoldCase returns the key under which a case-insensitive file system should
+// index the name, such that two names have the same key exactly when
+// strings.EqualFold reports them equal. Each character is replaced by the
+// smallest one of those that are equal to it under Unicode simple case
+// folding.
+func FoldCase(name string) string {
+	return strings.Map(func(r rune) rune {
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+
+		return min
+	}, name)
+}
+
+// IsCaseRename reports whether the op renames an entry to a name that differs
+// from its current one only by case, within the same directory. To a
+// case-insensitive file system, the new name refers to the entry being
+// renamed, which must keep its inode and have its name updated, rather than
+// being treated as the target to replace.
+func IsCaseRename(op *fuseops.RenameOp) bool {
+	return op.OldParent == op.NewParent &&
+		op.OldName != op.NewName &&
+		strings.EqualFold(op.OldName, op.NewName)
+}
+
+// CaseRenameConfig configures a CaseRenameFileSystem.
+type CaseRenameConfig struct {
+	// Change the name of the entry in the directory from oldName to newName,
+	// which differ only by case, keeping its inode. Must be non-nil.
+	SetName func(
+		ctx context.Context,
+		parent fuseops.InodeID,
+		oldName string,
+		newName string) error
+}
+
+// CaseRenameFileSystem is a FileSystem for case-insensitive, case-preserving
+// file systems, which routes renames that only change the case of a name, as
+// reported by IsCaseRename, to CaseRenameConfig.SetName, and passes other
+// renames on to the wrapped file system. Create one with
+// NewCaseRenameFileSystem.
+//
+// The kernel's dentry cache is case-sensitive, so it sends such renames as it
+// would any other. A file system that handles them with its ordinary rename
+// logic finds the new name already taken by the entry being renamed, and
+// either removes that entry as the target to be replaced, or adds a second
+// entry for the same inode.
+type CaseRenameFileSystem struct {
+	FileSystem
+	cfg CaseRenameConfig
+}
+
+// NewCaseRenameFileSystem wraps the supplied file system, routing renames as
+// described on CaseRenameFileSystem.
+func NewCaseRenameFileSystem(
+	wrapped FileSystem,
+	cfg CaseRenameConfig) *CaseRenameFileSystem {
+	return &CaseRenameFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+func (fs *CaseRenameFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	if IsCaseRename(op) {
+		return fs.cfg.SetName(ctx, op.OldParent, op.OldName, op.NewName)
+	}
+
+	return fs.FileSystem.Rename(ctx, op)
+}
@@ -0,0 +1,64 @@
+package fuseutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestFoldCase(t *testing.T) {
+	names := []string{"taco", "TACO", "Taco", "straße", "STRASSE", "Kelvin", "\u212aelvin", "ΣΊΣΥΦΟΣ", "σίσυφος", "σίσυφοσ"}
+	for _, a := range names {
+		for _, b := range names {
+			if got, want := FoldCase(a) == FoldCase(b), strings.EqualFold(a, b); got != want {
+				t.Errorf("FoldCase(%q) == FoldCase(%q): got %v, want %v", a, b, got, want)
+			}
+		}
+	}
+}
+
+// A file system that records renames.
+type renameFS struct {
+	NotImplementedFileSystem
+	renames []string
+}
+
+func (fs *renameFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	fs.renames = append(fs.renames, op.OldName+"->"+op.NewName)
+	return nil
+}
+
+func TestCaseRenameFileSystem(t *testing.T) {
+	ctx := context.Background()
+	var setNames []string
+	wrapped := &renameFS{}
+	fs := NewCaseRenameFileSystem(wrapped, CaseRenameConfig{
+		SetName: func(ctx context.Context, parent fuseops.InodeID, oldName, newName string) error {
+			setNames = append(setNames, oldName+"->"+newName)
+			return nil
+		},
+	})
+
+	ops := []*fuseops.RenameOp{
+		{OldParent: 1, OldName: "taco", NewParent: 1, NewName: "Taco"},
+		{OldParent: 1, OldName: "taco", NewParent: 2, NewName: "Taco"},
+		{OldParent: 1, OldName: "taco", NewParent: 1, NewName: "burrito"},
+		{OldParent: 1, OldName: "taco", NewParent: 1, NewName: "taco"},
+	}
+
+	for _, op := range ops {
+		if err := fs.Rename(ctx, op); err != nil {
+			t.Fatalf("Rename: %v", err)
+		}
+	}
+
+	if got := strings.Join(setNames, ","); got != "taco->Taco" {
+		t.Errorf("SetName calls: got %q", got)
+	}
+
+	if got := strings.Join(wrapped.renames, ","); got != "taco->Taco,taco->burrito,taco->taco" {
+		t.Errorf("wrapped renames: got %q", got)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A run of contiguous writes to a single handle.
+type writeRun struct {
+	inode  fuseops.InodeID
+	handle fuseops.HandleID
+	ctxs   []context.Context
+	ops    []*fuseops.WriteFileOp
+
+	// The total size of the data in ops, and the offset just past it.
+	size int
+	end  int64
+
+	// Set once the run has been handed to the file system. done is closed once
+	// err is available.
+	started bool
+	err     error
+	done    chan struct{}
+}
+
+// writeCoalescer merges contiguous writes to the same handle, holding each
+// write's goroutine until the merged write has completed.
+type writeCoalescer struct {
+	fs      FileSystem
+	window  time.Duration
+	maxSize int
+
+	mu sync.Mutex
+
+	// Runs that are still accepting writes, by handle.
+	//
+	// GUARDED_BY(mu)
+	pending map[fuseops.HandleID]*writeRun
+
+	// All runs that have not yet completed.
+	//
+	// GUARDED_BY(mu)
+	incomplete map[*writeRun]struct{}
+}
+
+func newWriteCoalescer(
+	fs FileSystem,
+	window time.Duration,
+	maxSize int) *writeCoalescer {
+	if maxSize <= 0 {
+		maxSize = 1 << 20
+	}
+
+	return &writeCoalescer{
+		fs:         fs,
+		window:     window,
+		maxSize:    maxSize,
+		pending:    make(map[fuseops.HandleID]*writeRun),
+		incomplete: make(map[*writeRun]struct{}),
+	}
+}
+
+// Add the write to a run, wait for the run to be written, and return its
+// error.
+func (wc *writeCoalescer) write(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	var run *writeRun
+	for run == nil {
+		wc.mu.Lock()
+		existing := wc.pending[op.Handle]
+
+		// Start a new run if there isn't one.
+		if existing == nil {
+			run = &writeRun{
+				inode:  op.Inode,
+				handle: op.Handle,
+				done:   make(chan struct{}),
+			}
+			wc.pending[op.Handle] = run
+			wc.incomplete[run] = struct{}{}
+			wc.add(run, ctx, op)
+			wc.mu.Unlock()
+
+			time.AfterFunc(wc.window, func() { wc.run(run) })
+			break
+		}
+
+		// Join the existing run if we can.
+		if existing.end == op.Offset && existing.size+len(op.Data) <= wc.maxSize {
+			run = existing
+			wc.add(run, ctx, op)
+
+			full := run.size >= wc.maxSize
+			if full {
+				delete(wc.pending, op.Handle)
+			}
+			wc.mu.Unlock()
+
+			if full {
+				wc.run(run)
+			}
+			break
+		}
+
+		// Otherwise write out the existing run before trying again, so that
+		// writes reach the file system in the order they were received.
+		delete(wc.pending, op.Handle)
+		wc.mu.Unlock()
+
+		wc.run(existing)
+	}
+
+	<-run.done
+	return run.err
+}
+
+// LOCKS_REQUIRED(wc.mu)
+func (wc *writeCoalescer) add(
+	run *writeRun,
+	ctx context.Context,
+	op *fuseops.WriteFileOp) {
+	run.ctxs = append(run.ctxs, ctx)
+	run.ops = append(run.ops, op)
+	run.size += len(op.Data)
+	run.end = op.Offset + int64(len(op.Data))
+}
+
+// Write out the runs whose writes the op could observe, and wait for any of
+// them that were already being written. Other ops don't wait for pending
+// writes.
+func (wc *writeCoalescer) flushFor(op interface{}) {
+	onInode := func(inodes ...fuseops.InodeID) func(*writeRun) bool {
+		return func(run *writeRun) bool {
+			for _, inode := range inodes {
+				if run.inode == inode {
+					return true
+				}
+			}
+
+			return false
+		}
+	}
+
+	var match func(*writeRun) bool
+	switch typed := op.(type) {
+	case *fuseops.ReadFileOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.FlushFileOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.SyncFileOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.GetInodeAttributesOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.SetInodeAttributesOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.FallocateOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.LseekOp:
+		match = onInode(typed.Inode)
+
+	case *fuseops.CopyFileRangeOp:
+		match = onInode(typed.SrcInode, typed.DstInode)
+
+	case *fuseops.ReleaseFileHandleOp:
+		match = func(run *writeRun) bool { return run.handle == typed.Handle }
+
+	case *fuseops.SyncFSOp:
+		match = func(*writeRun) bool { return true }
+
+	default:
+		return
+	}
+
+	wc.flush(match)
+}
+
+// Write out the runs for which match returns true, and wait for any of them
+// that were already being written.
+func (wc *writeCoalescer) flush(match func(*writeRun) bool) {
+	wc.mu.Lock()
+	var runs []*writeRun
+	for run := range wc.incomplete {
+		if match(run) {
+			runs = append(runs, run)
+		}
+	}
+	wc.mu.Unlock()
+
+	for _, run := range runs {
+		wc.run(run)
+		<-run.done
+	}
+}
+
+// Hand the run to the file system, if that hasn't already happened.
+func (wc *writeCoalescer) run(run *writeRun) {
+	wc.mu.Lock()
+	if run.started {
+		wc.mu.Unlock()
+		return
+	}
+
+	run.started = true
+	if wc.pending[run.handle] == run {
+		delete(wc.pending, run.handle)
+	}
+	wc.mu.Unlock()
+
+	if len(run.ops) == 1 {
+		run.err = wc.fs.WriteFile(run.ctxs[0], run.ops[0])
+	} else {
+		first := run.ops[0]
+		merged := &fuseops.WriteFileOp{
+			Inode:     first.Inode,
+			Handle:    first.Handle,
+			Offset:    first.Offset,
+			Data:      make([]byte, 0, run.size),
+			OpContext: first.OpContext,
+		}
+
+		for _, op := range run.ops {
+			merged.Data = append(merged.Data, op.Data...)
+		}
+
+		ctx, cancel := mergeContexts(run.ctxs)
+		run.err = wc.fs.WriteFile(ctx, merged)
+		cancel()
+
+		// The merged data is ours rather than the kernel's, so there is nothing
+		// to wait for before running the callback.
+		if merged.Callback != nil {
+			merged.Callback()
+		}
+	}
+
+	wc.mu.Lock()
+	delete(wc.incomplete, run)
+	wc.mu.Unlock()
+
+	close(run.done)
+}
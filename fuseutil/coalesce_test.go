@@ -0,0 +1,212 @@
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type writeRecord struct {
+	offset int64
+	data   string
+}
+
+type writeRecordingFS struct {
+	NotImplementedFileSystem
+
+	mu     sync.Mutex
+	writes []writeRecord
+	syncs  int
+}
+
+func (fs *writeRecordingFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.writes = append(fs.writes, writeRecord{op.Offset, string(op.Data)})
+	return nil
+}
+
+// Return copies of the writes and the number of syncs so far.
+func (fs *writeRecordingFS) recorded() ([]writeRecord, int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return append([]writeRecord(nil), fs.writes...), fs.syncs
+}
+
+func (fs *writeRecordingFS) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.syncs++
+	return nil
+}
+
+func newCoalescingServer(
+	fs FileSystem,
+	window time.Duration,
+	maxSize int) *fileSystemServer {
+	return NewFileSystemServerWithConfig(fs, &ServerConfig{
+		CoalesceWindow:        window,
+		MaxCoalescedWriteSize: maxSize,
+	}).(*fileSystemServer)
+}
+
+// Issue the writes in order, each once the previous one has joined a run.
+func issueWrites(
+	t *testing.T,
+	s *fileSystemServer,
+	ops []*fuseops.WriteFileOp) {
+	var wg sync.WaitGroup
+	for _, op := range ops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.dispatch(context.Background(), op); err != nil {
+				t.Errorf("WriteFile: %v", err)
+			}
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+}
+
+func TestContiguousWritesAreCoalesced(t *testing.T) {
+	fs := &writeRecordingFS{}
+	s := newCoalescingServer(fs, 100*time.Millisecond, 0)
+
+	issueWrites(t, s, []*fuseops.WriteFileOp{
+		{Handle: 1, Offset: 0, Data: []byte("taco")},
+		{Handle: 1, Offset: 4, Data: []byte("burrito")},
+		{Handle: 1, Offset: 11, Data: []byte("!")},
+	})
+
+	want := []writeRecord{{0, "tacoburrito!"}}
+	if len(fs.writes) != 1 || fs.writes[0] != want[0] {
+		t.Errorf("got %v, want %v", fs.writes, want)
+	}
+}
+
+func TestNonContiguousWritesAreNotCoalesced(t *testing.T) {
+	fs := &writeRecordingFS{}
+	s := newCoalescingServer(fs, 100*time.Millisecond, 0)
+
+	issueWrites(t, s, []*fuseops.WriteFileOp{
+		{Handle: 1, Offset: 0, Data: []byte("taco")},
+		{Handle: 1, Offset: 10, Data: []byte("burrito")},
+		{Handle: 2, Offset: 17, Data: []byte("enchilada")},
+	})
+
+	if len(fs.writes) != 3 {
+		t.Fatalf("got %v, want three writes", fs.writes)
+	}
+
+	// The first two writes share a handle, so must be kept in order.
+	if fs.writes[0].offset != 0 || fs.writes[1].offset != 10 {
+		t.Errorf("writes out of order: %v", fs.writes)
+	}
+}
+
+func TestCoalescedWriteSizeIsBounded(t *testing.T) {
+	fs := &writeRecordingFS{}
+	s := newCoalescingServer(fs, 100*time.Millisecond, 6)
+
+	issueWrites(t, s, []*fuseops.WriteFileOp{
+		{Handle: 1, Offset: 0, Data: []byte("abc")},
+		{Handle: 1, Offset: 3, Data: []byte("def")},
+		{Handle: 1, Offset: 6, Data: []byte("ghi")},
+	})
+
+	want := []writeRecord{{0, "abcdef"}, {6, "ghi"}}
+	if len(fs.writes) != 2 || fs.writes[0] != want[0] || fs.writes[1] != want[1] {
+		t.Errorf("got %v, want %v", fs.writes, want)
+	}
+}
+
+// Dispatch a write that will be held until flushed, returning a channel that
+// receives its error.
+func startPendingWrite(
+	s *fileSystemServer,
+	op *fuseops.WriteFileOp) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.dispatch(context.Background(), op)
+	}()
+
+	// Wait for the write to be pending.
+	for {
+		s.coalescer.mu.Lock()
+		n := len(s.coalescer.pending)
+		s.coalescer.mu.Unlock()
+
+		if n != 0 {
+			return done
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOtherOpsFlushPendingWrites(t *testing.T) {
+	fs := &writeRecordingFS{}
+	s := newCoalescingServer(fs, time.Hour, 0)
+
+	done := startPendingWrite(
+		s,
+		&fuseops.WriteFileOp{Inode: 2, Handle: 1, Data: []byte("taco")})
+
+	if err := s.dispatch(context.Background(), &fuseops.SyncFileOp{Inode: 2, Handle: 1}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	if writes, syncs := fs.recorded(); len(writes) != 1 || syncs != 1 {
+		t.Errorf("sync didn't follow write: %v, %d syncs", writes, syncs)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("WriteFile: %v", err)
+	}
+}
+
+func TestUnrelatedOpsDontFlushPendingWrites(t *testing.T) {
+	fs := &writeRecordingFS{}
+	s := newCoalescingServer(fs, time.Hour, 0)
+
+	done := startPendingWrite(
+		s,
+		&fuseops.WriteFileOp{Inode: 2, Handle: 1, Data: []byte("taco")})
+
+	// A lookup, and an op on another inode, are dispatched without waiting for
+	// the write.
+	ops := []interface{}{
+		&fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: "foo"},
+		&fuseops.GetInodeAttributesOp{Inode: 3},
+		&fuseops.SyncFileOp{Inode: 3, Handle: 4},
+	}
+
+	for _, op := range ops {
+		s.dispatch(context.Background(), op)
+	}
+
+	if writes, _ := fs.recorded(); len(writes) != 0 {
+		t.Fatalf("write flushed by unrelated ops: %v", writes)
+	}
+
+	// An op on the inode does wait for it.
+	s.dispatch(context.Background(), &fuseops.GetInodeAttributesOp{Inode: 2})
+	if writes, _ := fs.recorded(); len(writes) != 1 {
+		t.Errorf("write not flushed by getattr: %v", writes)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("WriteFile: %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// DentryCacheConfig configures a DentryCache.
+type DentryCacheConfig struct {
+	// If non-nil, used by Invalidate to invalidate the kernel's entries along
+	// with the cache's.
+	Notifier *fuse.Notifier
+
+	// The clock used to expire entries. If nil, the system clock is used.
+	Clock timeutil.Clock
+}
+
+// Dentry is a name in a directory. See DentryCache.Names.
+type Dentry struct {
+	Parent fuseops.InodeID
+	Name   string
+}
+
+// DentryCache is a daemon-side mirror of the kernel's cache of names looked up
+// in directories, populated by a DentryCacheFileSystem as it replies to the
+// kernel. File systems can consult it while handling ops, e.g. to find the
+// inode being renamed or unlinked, without a round trip to their backend.
+// Create one with NewDentryCache.
+//
+// An entry lasts as long as the kernel was told it may cache it, i.e. until
+// the ChildInodeEntry's EntryExpiration, and is removed when the name is
+// unlinked, removed, or renamed, or the kernel forgets the child inode.
+// Entries returned in ReadDirPlus replies aren't seen.
+//
+// Since the kernel may have dropped an entry earlier, e.g. under memory
+// pressure, a hit means only that the name referred to the inode when last
+// reported to the kernel, and changes to the file system made other than
+// through the kernel must be reported with Invalidate.
+type DentryCache struct {
+	cfg DentryCacheConfig
+
+	mu sync.Mutex
+
+	// The cached entries, and the names of each child inode that has any.
+	//
+	// INVARIANT: For each k, e in entries, names[e.child] contains k
+	// INVARIANT: For each child, ks in names, ks is non-empty
+	//
+	// GUARDED_BY(mu)
+	entries map[Dentry]dentryEntry
+	names   map[fuseops.InodeID]map[Dentry]struct{}
+}
+
+type dentryEntry struct {
+	child      fuseops.InodeID
+	expiration time.Time
+}
+
+// NewDentryCache returns an empty cache.
+func NewDentryCache(cfg DentryCacheConfig) *DentryCache {
+	return &DentryCache{
+		cfg:     cfg,
+		entries: make(map[Dentry]dentryEntry),
+		names:   make(map[fuseops.InodeID]map[Dentry]struct{}),
+	}
+}
+
+func (c *DentryCache) now() time.Time {
+	if c.cfg.Clock == nil {
+		return time.Now()
+	}
+
+	return c.cfg.Clock.Now()
+}
+
+// LookUp returns the inode the name in the directory refers to, if cached.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) LookUp(
+	parent fuseops.InodeID,
+	name string) (child fuseops.InodeID, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := Dentry{parent, name}
+	e, ok := c.entries[d]
+	if ok && !c.now().Before(e.expiration) {
+		c.remove(d)
+		ok = false
+	}
+
+	return e.child, ok
+}
+
+// Names returns the cached names of the inode, of which there may be several
+// if it is a file with hard links.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) Names(child fuseops.InodeID) []Dentry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var ds []Dentry
+	for d := range c.names[child] {
+		if now.Before(c.entries[d].expiration) {
+			ds = append(ds, d)
+		} else {
+			c.remove(d)
+		}
+	}
+
+	return ds
+}
+
+// Add records that the name in the directory refers to the entry's child, as
+// reported to the kernel. Entries that the kernel won't cache are ignored.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) Add(
+	parent fuseops.InodeID,
+	name string,
+	entry *fuseops.ChildInodeEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := Dentry{parent, name}
+	c.remove(d)
+
+	// A zero child is a negative entry, which isn't mirrored.
+	if entry.Child == 0 || !c.now().Before(entry.EntryExpiration) {
+		return
+	}
+
+	c.add(d, dentryEntry{entry.Child, entry.EntryExpiration})
+}
+
+// Remove discards the entry for the name in the directory, if any.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) Remove(parent fuseops.InodeID, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(Dentry{parent, name})
+}
+
+// Invalidate discards the entry for the name in the directory, and then uses
+// the notifier, if configured, to invalidate the kernel's entry, returning the
+// error from Notifier.InvalidateEntry. Like the latter, it must not be called
+// while handling an op for the directory.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) Invalidate(parent fuseops.InodeID, name string) error {
+	c.Remove(parent, name)
+	if c.cfg.Notifier == nil {
+		return nil
+	}
+
+	return c.cfg.Notifier.InvalidateEntry(parent, name)
+}
+
+// Purge discards the entries referring to the inode.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) Purge(child fuseops.InodeID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for d := range c.names[child] {
+		c.remove(d)
+	}
+}
+
+// Move the entry for a renamed name, replacing any entry for the new name,
+// or swap the entries for the two names if exchange is set.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *DentryCache) rename(from, to Dentry, exchange bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[from]
+	f, fok := c.entries[to]
+	c.remove(from)
+	c.remove(to)
+
+	if ok {
+		c.add(to, e)
+	}
+
+	if fok && exchange {
+		c.add(from, f)
+	}
+}
+
+// LOCKS_REQUIRED(c.mu)
+func (c *DentryCache) add(d Dentry, e dentryEntry) {
+	c.entries[d] = e
+	if c.names[e.child] == nil {
+		c.names[e.child] = make(map[Dentry]struct{})
+	}
+
+	c.names[e.child][d] = struct{}{}
+}
+
+// LOCKS_REQUIRED(c.mu)
+func (c *DentryCache) remove(d Dentry) {
+	e, ok := c.entries[d]
+	if !ok {
+		return
+	}
+
+	delete(c.entries, d)
+	delete(c.names[e.child], d)
+	if len(c.names[e.child]) == 0 {
+		delete(c.names, e.child)
+	}
+}
+
+// DentryCacheFileSystem is a FileSystem that populates a DentryCache with the
+// entries it returns to the kernel. Create one with
+// NewDentryCacheFileSystem.
+type DentryCacheFileSystem struct {
+	FileSystem
+	cache *DentryCache
+}
+
+// NewDentryCacheFileSystem wraps the supplied file system, recording the
+// entries it returns in the cache, which the wrapped file system may consult.
+func NewDentryCacheFileSystem(
+	wrapped FileSystem,
+	cache *DentryCache) *DentryCacheFileSystem {
+	return &DentryCacheFileSystem{
+		FileSystem: wrapped,
+		cache:      cache,
+	}
+}
+
+func (fs *DentryCacheFileSystem) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	err := fs.FileSystem.LookUpInode(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	} else {
+		fs.cache.Remove(op.Parent, op.Name)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	err := fs.FileSystem.MkDir(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	err := fs.FileSystem.MkNode(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	err := fs.FileSystem.CreateSymlink(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	err := fs.FileSystem.CreateLink(ctx, op)
+	if err == nil {
+		fs.cache.Add(op.Parent, op.Name, &op.Entry)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	err := fs.FileSystem.Rename(ctx, op)
+	if err == nil {
+		fs.cache.rename(
+			Dentry{op.OldParent, op.OldName},
+			Dentry{op.NewParent, op.NewName},
+			op.Flags&fuseops.RenameExchange != 0)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	err := fs.FileSystem.Unlink(ctx, op)
+	if err == nil {
+		fs.cache.Remove(op.Parent, op.Name)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	err := fs.FileSystem.RmDir(ctx, op)
+	if err == nil {
+		fs.cache.Remove(op.Parent, op.Name)
+	}
+
+	return err
+}
+
+func (fs *DentryCacheFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.cache.Purge(op.Inode)
+	return fs.FileSystem.ForgetInode(ctx, op)
+}
+
+func (fs *DentryCacheFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	for _, e := range op.Entries {
+		fs.cache.Purge(e.Inode)
+	}
+
+	return fs.FileSystem.BatchForget(ctx, op)
+}
@@ -0,0 +1,90 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// A file system in which every name refers to inode 3, apart from "missing",
+// and whose entries may be cached for a minute.
+type namesFS struct {
+	NotImplementedFileSystem
+	clock timeutil.Clock
+}
+
+func (fs *namesFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	if op.Name == "missing" {
+		return syscall.ENOENT
+	}
+
+	op.Entry.Child = 3
+	op.Entry.EntryExpiration = fs.clock.Now().Add(time.Minute)
+	return nil
+}
+
+func (fs *namesFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	return nil
+}
+
+func (fs *namesFS) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return nil
+}
+
+func TestDentryCache(t *testing.T) {
+	ctx := context.Background()
+	var clock timeutil.SimulatedClock
+	clock.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	cache := NewDentryCache(DentryCacheConfig{Clock: &clock})
+	fs := NewDentryCacheFileSystem(&namesFS{clock: &clock}, cache)
+
+	for _, name := range []string{"taco", "burrito", "missing"} {
+		fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 2, Name: name})
+	}
+
+	if child, ok := cache.LookUp(2, "taco"); !ok || child != 3 {
+		t.Errorf("LookUp(taco): %v, %v", child, ok)
+	}
+
+	if _, ok := cache.LookUp(2, "missing"); ok {
+		t.Errorf("LookUp(missing) hit")
+	}
+
+	if names := cache.Names(3); len(names) != 2 {
+		t.Errorf("Names: %v", names)
+	}
+
+	// Renames move entries, and unlinks remove them.
+	fs.Rename(ctx, &fuseops.RenameOp{OldParent: 2, OldName: "taco", NewParent: 4, NewName: "enchilada"})
+	fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 2, Name: "burrito"})
+
+	names := cache.Names(3)
+	if len(names) != 1 || names[0] != (Dentry{4, "enchilada"}) {
+		t.Errorf("Names after rename and unlink: %v", names)
+	}
+
+	// Exchanges swap entries rather than drop the one replaced.
+	fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 2, Name: "salsa"})
+	fs.Rename(ctx, &fuseops.RenameOp{OldParent: 2, OldName: "salsa", NewParent: 4, NewName: "enchilada", Flags: fuseops.RenameExchange})
+	if names := cache.Names(3); len(names) != 2 {
+		t.Errorf("Names after exchange: %v", names)
+	}
+
+	// Entries expire along with the kernel's.
+	clock.AdvanceTime(time.Minute)
+	if _, ok := cache.LookUp(4, "enchilada"); ok {
+		t.Errorf("LookUp hit after expiration")
+	}
+
+	// Forgetting the child purges its entries.
+	fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 2, Name: "taco"})
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 3, N: 1})
+	if names := cache.Names(3); len(names) != 0 {
+		t.Errorf("Names after forget: %v", names)
+	}
+}
@@ -15,9 +15,13 @@
 package fuseutil
 
 import (
+	"encoding/binary"
+	"fmt"
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/internal/fusekernel"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/jacobsa/fuse/fuseops"
@@ -65,6 +69,50 @@ type DirentPlus struct {
 	Entry fuseops.ChildInodeEntry
 }
 
+// The maximum length of a name, in bytes. See NAME_MAX.
+const maxNameLen = 255
+
+// ValidateName returns an error wrapping syscall.EINVAL if the supplied name
+// can't be used as the name of a child: if it is empty, "." or "..", longer
+// than 255 bytes, or contains a NUL or '/'. The kernel doesn't expect such
+// names in directory entries or in replies to LookUpInodeOp, and handing it
+// one can make it misbehave, e.g. by resolving paths through the wrong inode.
+//
+// File systems whose backends may contain arbitrary names, e.g. object stores,
+// should check names with ValidateName before returning them, and skip or
+// sanitize (see SanitizeName) those that fail. (The "." and ".." entries that
+// file systems write for ReadDirOp are fine, of course.)
+func ValidateName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("%w: invalid name %q", syscall.EINVAL, name)
+
+	case len(name) > maxNameLen:
+		return fmt.Errorf("%w: name of length %d too long", syscall.EINVAL, len(name))
+
+	case strings.ContainsAny(name, "/\x00"):
+		return fmt.Errorf("%w: name %q contains NUL or '/'", syscall.EINVAL, name)
+	}
+
+	return nil
+}
+
+// SanitizeName returns the supplied name with each NUL and '/' replaced by the
+// supplied replacement, e.g. "_" or "\u2215" (DIVISION SLASH), which must not
+// itself contain NUL or '/'. The result may still fail ValidateName, if it is
+// empty, "." or "..", or is too long.
+//
+// Note that sanitizing can map distinct names to the same one, so file systems
+// must choose a replacement that doesn't occur in their backends' names or
+// cope with collisions.
+func SanitizeName(name string, replacement string) string {
+	if strings.ContainsAny(replacement, "/\x00") {
+		panic(fmt.Sprintf("invalid replacement %q", replacement))
+	}
+
+	return strings.NewReplacer("/", replacement, "\x00", replacement).Replace(name)
+}
+
 type fuse_dirent struct {
 	ino     uint64
 	off     uint64
@@ -120,10 +168,47 @@ func WriteDirent(buf []byte, d Dirent) (n int) {
 	return n
 }
 
+// ReadDirent parses the directory entry written by WriteDirent at the start of
+// buf, returning it and the number of bytes it occupies, including padding,
+// or zero if buf doesn't hold a whole entry. The padding following the last
+// entry in buf may be missing.
+func ReadDirent(buf []byte) (d Dirent, n int) {
+	const direntAlignment = 8
+	const direntSize = 8 + 8 + 4 + 4
+
+	if len(buf) < direntSize {
+		return Dirent{}, 0
+	}
+
+	// Decode the fields one by one rather than casting buf to a *fuse_dirent,
+	// since it need not be aligned, which some 32-bit platforms require.
+	namelen := int(binary.NativeEndian.Uint32(buf[16:]))
+	end := direntSize + namelen
+	if end > len(buf) {
+		return Dirent{}, 0
+	}
+
+	d = Dirent{
+		Offset: fuseops.DirOffset(binary.NativeEndian.Uint64(buf[8:])),
+		Inode:  fuseops.InodeID(binary.NativeEndian.Uint64(buf)),
+		Name:   string(buf[direntSize:end]),
+		Type:   DirentType(binary.NativeEndian.Uint32(buf[20:])),
+	}
+
+	end = (end + direntAlignment - 1) / direntAlignment * direntAlignment
+	return d, min(end, len(buf))
+}
+
 // Write the supplied directory entry with attributes into the given buffer in the format
 // expected in fuseops.ReadDirPlusOp.Dst returning the number of bytes written.
 // Return zero if the entry would not fit.
 func WriteDirentPlus(buf []byte, d DirentPlus) (n int) {
+	return WriteDirentPlusAt(buf, d, time.Now())
+}
+
+// Like WriteDirentPlus, but converts the entry's expiration times relative to
+// the supplied time. See fuse.MountConfig.Clock.
+func WriteDirentPlusAt(buf []byte, d DirentPlus, now time.Time) (n int) {
 	type fuse_entry_out struct {
 		nodeid           uint64
 		generation       uint64
@@ -169,8 +254,8 @@ func WriteDirentPlus(buf []byte, d DirentPlus) (n int) {
 		return 0
 	}
 
-	entryValid, entryValidNsec := fuse.ConvertExpirationTime(d.Entry.EntryExpiration)
-	attrValid, attrValidNsec := fuse.ConvertExpirationTime(d.Entry.AttributesExpiration)
+	entryValid, entryValidNsec := fuse.ConvertExpirationTimeAt(d.Entry.EntryExpiration, now)
+	attrValid, attrValidNsec := fuse.ConvertExpirationTimeAt(d.Entry.AttributesExpiration, now)
 	var rdev uint32
 	mode := fuse.ConvertGoMode(d.Entry.Attributes.Mode)
 	if mode&(syscall.S_IFCHR|syscall.S_IFBLK) != 0 {
@@ -213,6 +298,10 @@ func WriteDirentPlus(buf []byte, d DirentPlus) (n int) {
 		},
 	}
 
+	if d.Entry.Attributes.Submount {
+		dp.entry_out.attr.SetSubmount()
+	}
+
 	n += copy(buf[n:], (*[direntPlusHeaderSize]byte)(unsafe.Pointer(&dp))[:])
 
 	// Write the name afterward.
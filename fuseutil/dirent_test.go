@@ -0,0 +1,80 @@
+package fuseutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"taco", ".bashrc", "...", "a\\b", strings.Repeat("x", 255)} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q): %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", ".", "..", "a/b", "a\x00b", strings.Repeat("x", 256)} {
+		if err := ValidateName(name); !errors.Is(err, syscall.EINVAL) {
+			t.Errorf("ValidateName(%q): got %v, want EINVAL", name, err)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	if got := SanitizeName("a/b\x00c//", "∕"); got != "a∕b∕c∕∕" {
+		t.Errorf("SanitizeName: got %q", got)
+	}
+
+	if got := SanitizeName("taco", "_"); got != "taco" {
+		t.Errorf("SanitizeName: got %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SanitizeName accepted an invalid replacement")
+		}
+	}()
+
+	SanitizeName("a/b", "/")
+}
+
+func TestWriteDirent(t *testing.T) {
+	d := Dirent{Offset: 2, Inode: 17, Name: "taco", Type: DT_File}
+
+	// struct fuse_dirent in host order, padded to 8 bytes.
+	var want []byte
+	want = binary.NativeEndian.AppendUint64(want, 17)
+	want = binary.NativeEndian.AppendUint64(want, 2)
+	want = binary.NativeEndian.AppendUint32(want, 4)
+	want = binary.NativeEndian.AppendUint32(want, syscall.DT_REG)
+	want = append(want, "taco\x00\x00\x00\x00"...)
+
+	buf := make([]byte, 64)
+	n := WriteDirent(buf, d)
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("WriteDirent: got %x, want %x", buf[:n], want)
+	}
+
+	if n := WriteDirent(buf[:len(want)-1], d); n != 0 {
+		t.Errorf("WriteDirent into short buffer: got %d", n)
+	}
+
+	// Read it back from a misaligned buffer.
+	misaligned := append([]byte{0}, want...)[1:]
+	got, n := ReadDirent(misaligned)
+	if got != d || n != len(want) {
+		t.Errorf("ReadDirent: got (%+v, %d)", got, n)
+	}
+
+	// The final padding may be missing, but not the name.
+	if _, n := ReadDirent(want[:28]); n != 28 {
+		t.Errorf("ReadDirent without padding: got %d, want 28", n)
+	}
+
+	if _, n := ReadDirent(want[:27]); n != 0 {
+		t.Errorf("ReadDirent of truncated name: got %d, want 0", n)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// CheckFallocateMode returns EOPNOTSUPP, as fallocate(2) does for modes a file
+// system doesn't support, if the op's mode has any flags not in supported,
+// and nil otherwise. A FileSystem's Fallocate method may call it first:
+//
+//	err := fuseutil.CheckFallocateMode(
+//		op, fuseops.FallocateKeepSize|fuseops.FallocatePunchHole)
+//	if err != nil {
+//		return err
+//	}
+//
+// Punching a hole without FallocateKeepSize is invalid, and fails with
+// EOPNOTSUPP too.
+func CheckFallocateMode(
+	op *fuseops.FallocateOp,
+	supported fuseops.FallocateMode) error {
+	if op.Mode&^supported != 0 {
+		return syscall.EOPNOTSUPP
+	}
+
+	if op.Mode&fuseops.FallocatePunchHole != 0 &&
+		op.Mode&fuseops.FallocateKeepSize == 0 {
+		return syscall.EOPNOTSUPP
+	}
+
+	return nil
+}
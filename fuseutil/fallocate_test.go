@@ -0,0 +1,34 @@
+package fuseutil
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestCheckFallocateMode(t *testing.T) {
+	const supported = fuseops.FallocateKeepSize | fuseops.FallocatePunchHole
+	cases := []struct {
+		mode fuseops.FallocateMode
+		want error
+	}{
+		{0, nil},
+		{fuseops.FallocateKeepSize, nil},
+		{fuseops.FallocateKeepSize | fuseops.FallocatePunchHole, nil},
+		{fuseops.FallocatePunchHole, syscall.EOPNOTSUPP},
+		{fuseops.FallocateZeroRange, syscall.EOPNOTSUPP},
+		{fuseops.FallocateCollapseRange, syscall.EOPNOTSUPP},
+	}
+
+	for _, c := range cases {
+		op := &fuseops.FallocateOp{Mode: c.mode}
+		if err := CheckFallocateMode(op, supported); err != c.want {
+			t.Errorf("%v: got %v, want %v", c.mode, err, c.want)
+		}
+	}
+
+	if s := (fuseops.FallocateKeepSize | fuseops.FallocatePunchHole | 0x100).String(); s != "KeepSize+PunchHole+0x100" {
+		t.Errorf("String: got %q", s)
+	}
+}
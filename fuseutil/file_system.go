@@ -16,8 +16,8 @@ package fuseutil
 
 import (
 	"context"
-	"io"
 	"sync"
+	"time"
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
@@ -65,6 +65,19 @@ type FileSystem interface {
 	SetXattr(context.Context, *fuseops.SetXattrOp) error
 	Fallocate(context.Context, *fuseops.FallocateOp) error
 	SyncFS(context.Context, *fuseops.SyncFSOp) error
+	Ioctl(context.Context, *fuseops.IoctlOp) error
+	CopyFileRange(context.Context, *fuseops.CopyFileRangeOp) error
+	Lseek(context.Context, *fuseops.LseekOp) error
+	Poll(context.Context, *fuseops.PollOp) error
+	GetLk(context.Context, *fuseops.GetLkOp) error
+	SetLk(context.Context, *fuseops.SetLkOp) error
+	SetLkW(context.Context, *fuseops.SetLkWOp) error
+	Statx(context.Context, *fuseops.StatxOp) error
+	CreateTmpfile(context.Context, *fuseops.CreateTmpfileOp) error
+	Access(context.Context, *fuseops.AccessOp) error
+	Bmap(context.Context, *fuseops.BmapOp) error
+	SetupMapping(context.Context, *fuseops.SetupMappingOp) error
+	RemoveMapping(context.Context, *fuseops.RemoveMappingOp) error
 
 	// Regard all inodes (including the root inode) as having their lookup counts
 	// decremented to zero, and clean up any resources associated with the file
@@ -73,8 +86,10 @@ type FileSystem interface {
 }
 
 // Create a fuse.Server that handles ops by calling the associated FileSystem
-// method.Respond with the resulting error. Unsupported ops are responded to
-// directly with ENOSYS.
+// method.Respond with the resulting error. Unsupported ops, including those
+// for which the file system inherits NotImplementedFileSystem's method, are
+// responded to directly with ENOSYS. The returned server implements
+// CapabilityReporter.
 //
 // Each call to a FileSystem method (except ForgetInode) is made on
 // its own goroutine, and is free to block. ForgetInode may be called
@@ -84,16 +99,113 @@ type FileSystem interface {
 // (It is safe to naively process ops concurrently because the kernel
 // guarantees to serialize operations that the user expects to happen in order,
 // cf. https://tinyurl.com/bddm85v5, fuse-devel thread "Fuse guarantees on
-// concurrent requests").
+// concurrent requests"). The wrappers in this package are also safe when
+// fuse.MountConfig.EnableParallelDirOps lets lookups and listings of the same
+// directory run concurrently.
 func NewFileSystemServer(fs FileSystem) fuse.Server {
-	return &fileSystemServer{
-		fs: fs,
+	return NewFileSystemServerWithConfig(fs, nil)
+}
+
+// ServerConfig contains optional settings for NewFileSystemServerWithConfig.
+// The zero value gives the same behavior as NewFileSystemServer.
+type ServerConfig struct {
+	// If non-zero, and the file system implements LookUpInodeBatcher or
+	// ReadFileBatcher, compatible ops that arrive within this long of the first
+	// op in a batch are delivered to the file system together. Each op in the
+	// batch is delayed by up to this long, so it should be small compared to the
+	// latency of a backend round trip.
+	BatchWindow time.Duration
+
+	// The maximum number of ops in a single batch. A batch that fills up is
+	// dispatched without waiting for the window to close. If zero, 64 is used.
+	MaxBatchSize int
+
+	// If non-zero, writes to the same handle that arrive within this long of
+	// each other and are contiguous are merged into a single WriteFileOp before
+	// being passed to the file system, which sees fewer, larger writes. Each
+	// constituent write is replied to with the error for the merged write.
+	//
+	// Pending writes to an inode are flushed before an op that could observe
+	// them is dispatched: a read, flush, fsync, getattr, setattr, fallocate,
+	// lseek, or copy_file_range on the inode, a release of the handle, or a
+	// syncfs. Other ops, e.g. lookups, don't wait for them.
+	CoalesceWindow time.Duration
+
+	// The maximum size in bytes of a merged write. If zero, 1 MiB is used.
+	MaxCoalescedWriteSize int
+
+	// If non-zero, and the file system implements ReadFileRanger, reads are
+	// split into ranges of at most this many bytes, which are fetched
+	// concurrently. Reads batched for a ReadFileBatcher aren't split.
+	ParallelReadSize int
+
+	// The maximum number of ranges of a single read fetched at once. If zero,
+	// 4 is used.
+	MaxParallelReads int
+}
+
+// Like NewFileSystemServer, but with additional settings. The config may be
+// nil.
+func NewFileSystemServerWithConfig(
+	fs FileSystem,
+	cfg *ServerConfig) fuse.Server {
+	if cfg == nil {
+		cfg = &ServerConfig{}
+	}
+
+	s := &fileSystemServer{
+		fs:   fs,
+		caps: CapabilitiesOf(fs),
+	}
+
+	// BatchForget falls back to ForgetInode.
+	s.caps["BatchForget"] = s.caps["BatchForget"] || s.caps["ForgetInode"]
+
+	if cfg.BatchWindow > 0 {
+		s.batcher = newBatcher(fs, cfg.BatchWindow, cfg.MaxBatchSize)
+		s.caps["LookUpInode"] = s.caps["LookUpInode"] || s.batcher.lookUps != nil
+		s.caps["ReadFile"] = s.caps["ReadFile"] || s.batcher.reads != nil
+	}
+
+	if r, ok := fs.(ReadFileRanger); ok && cfg.ParallelReadSize > 0 {
+		s.ranger = r
+		s.parallelReadSize = cfg.ParallelReadSize
+		s.maxParallelReads = cfg.MaxParallelReads
+		if s.maxParallelReads == 0 {
+			s.maxParallelReads = 4
+		}
+
+		s.caps["ReadFile"] = true
+	}
+
+	if cfg.CoalesceWindow > 0 {
+		s.coalescer = newWriteCoalescer(
+			fs,
+			cfg.CoalesceWindow,
+			cfg.MaxCoalescedWriteSize)
 	}
+
+	return s
 }
 
 type fileSystemServer struct {
 	fs          FileSystem
 	opsInFlight sync.WaitGroup
+
+	// The ops that the server can handle. Others are replied to with ENOSYS
+	// without calling the file system.
+	caps Capabilities
+
+	// Non-nil if batching is enabled.
+	batcher *batcher
+
+	// Non-nil if write coalescing is enabled.
+	coalescer *writeCoalescer
+
+	// Non-nil if parallel reads are enabled.
+	ranger           ReadFileRanger
+	parallelReadSize int
+	maxParallelReads int
 }
 
 func (s *fileSystemServer) ServeOps(c *fuse.Connection) {
@@ -104,14 +216,45 @@ func (s *fileSystemServer) ServeOps(c *fuse.Connection) {
 		s.fs.Destroy()
 	}()
 
+	// Read ops with as many goroutines as the connection is tuned for, limiting
+	// the number handled at once.
+	tuning := c.Tuning()
+	var workers chan struct{}
+	if tuning.Workers > 0 {
+		workers = make(chan struct{}, tuning.Workers)
+	}
+
+	var readers sync.WaitGroup
+	for i := 0; i < max(tuning.Readers, 1); i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			s.readOps(c, workers)
+		}()
+	}
+
+	readers.Wait()
+}
+
+// Read and dispatch ops until the connection is closed. If workers is
+// non-nil, a slot in it is held while handling each op. Ops wait for a slot in
+// their own goroutines, so that reading continues, and with it the handling of
+// interrupts, while all the slots are taken.
+func (s *fileSystemServer) readOps(
+	c *fuse.Connection,
+	workers chan struct{}) {
 	for {
+		// Any error ends serving, and is reported by MountedFileSystem.Join.
 		ctx, op, err := c.ReadOp()
-		if err == io.EOF {
+		if err != nil {
 			break
 		}
 
-		if err != nil {
-			panic(err)
+		// Reply to ops that the file system doesn't implement directly, without
+		// the cost of a goroutine.
+		if !s.caps.Implements(op) {
+			c.Reply(ctx, fuse.ENOSYS)
+			continue
 		}
 
 		s.opsInFlight.Add(1)
@@ -120,18 +263,68 @@ func (s *fileSystemServer) ServeOps(c *fuse.Connection) {
 			// forget inode ops, which may come in a
 			// flurry from the kernel and are generally
 			// cheap for the file system to handle
-			s.handleOp(c, ctx, op)
+			s.handleOp(c, ctx, op, nil)
 		} else {
-			go s.handleOp(c, ctx, op)
+			go s.handleOp(c, ctx, op, workers)
 		}
 	}
 }
 
+// Capabilities reports the ops that the server passes to the file system,
+// which include LookUpInode and ReadFile if they are batched and the file
+// system implements LookUpInodeBatcher or ReadFileBatcher, ReadFile if reads
+// are split for a ReadFileRanger, and BatchForget if
+// the file system implements ForgetInode. Other ops are replied to with
+// ENOSYS. See CapabilityReporter.
+func (s *fileSystemServer) Capabilities() Capabilities {
+	c := make(Capabilities, len(s.caps))
+	for name, ok := range s.caps {
+		c[name] = ok
+	}
+
+	return c
+}
+
 func (s *fileSystemServer) handleOp(
 	c *fuse.Connection,
 	ctx context.Context,
-	op interface{}) {
+	op interface{},
+	workers chan struct{}) {
 	defer s.opsInFlight.Done()
+	if workers != nil {
+		select {
+		case workers <- struct{}{}:
+			defer func() { <-workers }()
+
+		// Don't wait for a slot for an op that has been interrupted.
+		case <-ctx.Done():
+			c.Reply(ctx, ctx.Err())
+			return
+		}
+	}
+
+	err := s.dispatch(ctx, op)
+	c.Reply(ctx, err)
+}
+
+// Call the file system method appropriate for the op, returning the error
+// with which the op should be replied to.
+func (s *fileSystemServer) dispatch(
+	ctx context.Context,
+	op interface{}) error {
+	if s.coalescer != nil {
+		if typed, ok := op.(*fuseops.WriteFileOp); ok {
+			return s.coalescer.write(ctx, typed)
+		}
+
+		s.coalescer.flushFor(op)
+	}
+
+	if s.batcher != nil {
+		if ok, err := s.batcher.dispatch(ctx, op); ok {
+			return err
+		}
+	}
 
 	// Dispatch to the appropriate method.
 	var err error
@@ -210,7 +403,11 @@ func (s *fileSystemServer) handleOp(
 		err = s.fs.OpenFile(ctx, typed)
 
 	case *fuseops.ReadFileOp:
-		err = s.fs.ReadFile(ctx, typed)
+		if s.ranger != nil {
+			err = readParallel(ctx, s.ranger, typed, s.parallelReadSize, s.maxParallelReads)
+		} else {
+			err = s.fs.ReadFile(ctx, typed)
+		}
 
 	case *fuseops.WriteFileOp:
 		err = s.fs.WriteFile(ctx, typed)
@@ -244,7 +441,46 @@ func (s *fileSystemServer) handleOp(
 
 	case *fuseops.SyncFSOp:
 		err = s.fs.SyncFS(ctx, typed)
+
+	case *fuseops.IoctlOp:
+		err = s.fs.Ioctl(ctx, typed)
+
+	case *fuseops.CopyFileRangeOp:
+		err = s.fs.CopyFileRange(ctx, typed)
+
+	case *fuseops.LseekOp:
+		err = s.fs.Lseek(ctx, typed)
+
+	case *fuseops.PollOp:
+		err = s.fs.Poll(ctx, typed)
+
+	case *fuseops.GetLkOp:
+		err = s.fs.GetLk(ctx, typed)
+
+	case *fuseops.SetLkOp:
+		err = s.fs.SetLk(ctx, typed)
+
+	case *fuseops.SetLkWOp:
+		err = s.fs.SetLkW(ctx, typed)
+
+	case *fuseops.StatxOp:
+		err = s.fs.Statx(ctx, typed)
+
+	case *fuseops.CreateTmpfileOp:
+		err = s.fs.CreateTmpfile(ctx, typed)
+
+	case *fuseops.AccessOp:
+		err = s.fs.Access(ctx, typed)
+
+	case *fuseops.BmapOp:
+		err = s.fs.Bmap(ctx, typed)
+
+	case *fuseops.SetupMappingOp:
+		err = s.fs.SetupMapping(ctx, typed)
+
+	case *fuseops.RemoveMappingOp:
+		err = s.fs.RemoveMapping(ctx, typed)
 	}
 
-	c.Reply(ctx, err)
+	return err
 }
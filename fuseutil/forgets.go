@@ -0,0 +1,329 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// ForgetConfig configures a ForgetFileSystem.
+type ForgetConfig struct {
+	// Called with inodes that the kernel no longer references. Must be
+	// non-nil. Calls are never concurrent.
+	OnForget func(inodes []fuseops.InodeID)
+
+	// If non-zero, inodes that the kernel stops referencing are gathered for
+	// this long before being passed to OnForget, so that the forgets sent in a
+	// flurry when the kernel evicts its cache are delivered together, and an
+	// inode that is looked up again in the meantime isn't delivered at all. If
+	// zero, OnForget is called while handling each forget.
+	Window time.Duration
+}
+
+// ForgetFileSystem is a FileSystem that keeps count of the references the
+// kernel holds to each inode, and calls a function exactly once when the
+// kernel stops referencing one, so that file systems can release the
+// resources associated with inodes reliably. Create one with
+// NewForgetFileSystem.
+//
+// References are counted from the entries returned by LookUpInode, MkDir,
+// MkNode, CreateFile, CreateTmpfile, CreateSymlink, CreateLink, and
+// ReadDirPlus, and released by ForgetInode and BatchForget, which aren't passed
+// on to the wrapped file system. An inode is delivered to ForgetConfig.OnForget
+// once its count falls to zero, and again each time that happens after it is
+// referenced again. When the file system is destroyed, e.g. because it was
+// unmounted, the kernel sends no forgets for the inodes it still references,
+// and those are delivered before the wrapped file system's Destroy is called.
+type ForgetFileSystem struct {
+	FileSystem
+	cfg ForgetConfig
+
+	// Held while calling OnForget.
+	deliverMu sync.Mutex
+
+	mu sync.Mutex
+
+	// The number of references the kernel holds to each inode that has any.
+	//
+	// GUARDED_BY(mu)
+	lookups map[fuseops.InodeID]uint64
+
+	// Inodes whose counts have fallen to zero, waiting for the window to end,
+	// and the timer that ends it, if running.
+	//
+	// GUARDED_BY(mu)
+	pending map[fuseops.InodeID]struct{}
+	timer   *time.Timer
+}
+
+// NewForgetFileSystem wraps the supplied file system, delivering forgets as
+// described on ForgetFileSystem.
+func NewForgetFileSystem(
+	wrapped FileSystem,
+	cfg ForgetConfig) *ForgetFileSystem {
+	return &ForgetFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		lookups:    make(map[fuseops.InodeID]uint64),
+		pending:    make(map[fuseops.InodeID]struct{}),
+	}
+}
+
+// Lookups returns the number of references the kernel holds to the inode.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ForgetFileSystem) Lookups(inode fuseops.InodeID) uint64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.lookups[inode]
+}
+
+// Record a reference to the entry returned by a successful op.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ForgetFileSystem) entry(err error, e *fuseops.ChildInodeEntry) error {
+	if err == nil && e.Child != 0 {
+		fs.mu.Lock()
+		fs.lookups[e.Child]++
+		fs.mu.Unlock()
+	}
+
+	return err
+}
+
+// Release n references to the inode, adding it to forgotten if that was the
+// last.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *ForgetFileSystem) forget(
+	inode fuseops.InodeID,
+	n uint64,
+	forgotten []fuseops.InodeID) []fuseops.InodeID {
+	count, ok := fs.lookups[inode]
+	if !ok {
+		return forgotten
+	}
+
+	if n < count {
+		fs.lookups[inode] = count - n
+		return forgotten
+	}
+
+	delete(fs.lookups, inode)
+	return append(forgotten, inode)
+}
+
+// Deliver the inodes whose references were released by a forget op, now or
+// when the window ends.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ForgetFileSystem) forgot(forgotten []fuseops.InodeID) {
+	if len(forgotten) == 0 {
+		return
+	}
+
+	if fs.cfg.Window == 0 {
+		fs.deliver(forgotten)
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, inode := range forgotten {
+		fs.pending[inode] = struct{}{}
+	}
+
+	if fs.timer == nil {
+		fs.timer = time.AfterFunc(fs.cfg.Window, fs.flush)
+	}
+}
+
+// Deliver the pending inodes that haven't been referenced again.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ForgetFileSystem) flush() {
+	fs.deliverMu.Lock()
+	defer fs.deliverMu.Unlock()
+
+	fs.mu.Lock()
+	var inodes []fuseops.InodeID
+	for inode := range fs.pending {
+		if fs.lookups[inode] == 0 {
+			inodes = append(inodes, inode)
+		}
+	}
+
+	clear(fs.pending)
+	fs.timer = nil
+	fs.mu.Unlock()
+
+	if len(inodes) != 0 {
+		fs.cfg.OnForget(inodes)
+	}
+}
+
+// LOCKS_EXCLUDED(fs.deliverMu)
+func (fs *ForgetFileSystem) deliver(inodes []fuseops.InodeID) {
+	fs.deliverMu.Lock()
+	defer fs.deliverMu.Unlock()
+	fs.cfg.OnForget(inodes)
+}
+
+// Record the references implied by a buffer of fuse_direntplus structures:
+// the kernel takes one to each entry other than "." and "..".
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ForgetFileSystem) direntsPlus(buf []byte) {
+	const header = int(unsafe.Sizeof(fusekernel.EntryOut{}))
+	const nodeidOffset = unsafe.Offsetof(fusekernel.EntryOut{}.Nodeid)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for len(buf) >= header+fusekernel.DirentSize {
+		d := buf[header:]
+		namelen := int(binary.NativeEndian.Uint32(d[16:]))
+		size := header + fusekernel.DirentSize + namelen
+		if size > len(buf) {
+			return
+		}
+
+		name := string(d[fusekernel.DirentSize : fusekernel.DirentSize+namelen])
+		nodeid := fuseops.InodeID(binary.NativeEndian.Uint64(buf[nodeidOffset:]))
+		if nodeid != 0 && name != "." && name != ".." {
+			fs.lookups[nodeid]++
+		}
+
+		// Skip the padding following the entry, which may be missing at the end.
+		size = (size + 7) / 8 * 8
+		if size > len(buf) {
+			return
+		}
+
+		buf = buf[size:]
+	}
+}
+
+func (fs *ForgetFileSystem) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	return fs.entry(fs.FileSystem.LookUpInode(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	return fs.entry(fs.FileSystem.MkDir(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	return fs.entry(fs.FileSystem.MkNode(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	return fs.entry(fs.FileSystem.CreateFile(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	return fs.entry(fs.FileSystem.CreateTmpfile(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	return fs.entry(fs.FileSystem.CreateSymlink(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	return fs.entry(fs.FileSystem.CreateLink(ctx, op), &op.Entry)
+}
+
+func (fs *ForgetFileSystem) ReadDirPlus(
+	ctx context.Context,
+	op *fuseops.ReadDirPlusOp) error {
+	err := fs.FileSystem.ReadDirPlus(ctx, op)
+	if err == nil && op.BytesRead <= len(op.Dst) {
+		fs.direntsPlus(op.Dst[:op.BytesRead])
+	}
+
+	return err
+}
+
+func (fs *ForgetFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.mu.Lock()
+	forgotten := fs.forget(op.Inode, op.N, nil)
+	fs.mu.Unlock()
+
+	fs.forgot(forgotten)
+	return nil
+}
+
+func (fs *ForgetFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	var forgotten []fuseops.InodeID
+	fs.mu.Lock()
+	for _, e := range op.Entries {
+		forgotten = fs.forget(e.Inode, e.N, forgotten)
+	}
+	fs.mu.Unlock()
+
+	fs.forgot(forgotten)
+	return nil
+}
+
+func (fs *ForgetFileSystem) Destroy() {
+	// Deliver the pending inodes, and then those still referenced.
+	fs.mu.Lock()
+	if fs.timer != nil {
+		fs.timer.Stop()
+	}
+	fs.mu.Unlock()
+
+	fs.flush()
+
+	fs.mu.Lock()
+	var inodes []fuseops.InodeID
+	for inode := range fs.lookups {
+		inodes = append(inodes, inode)
+	}
+
+	clear(fs.lookups)
+	fs.mu.Unlock()
+
+	if len(inodes) != 0 {
+		fs.deliver(inodes)
+	}
+
+	fs.FileSystem.Destroy()
+}
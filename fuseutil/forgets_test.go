@@ -0,0 +1,105 @@
+package fuseutil
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system in which every name refers to the inode with the same number.
+type numberFS struct {
+	NotImplementedFileSystem
+	destroyed bool
+}
+
+func (fs *numberFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	op.Entry.Child = fuseops.InodeID(len(op.Name))
+	return nil
+}
+
+func (fs *numberFS) Destroy() {
+	fs.destroyed = true
+}
+
+func TestForgetFileSystem(t *testing.T) {
+	ctx := context.Background()
+	var delivered [][]fuseops.InodeID
+	wrapped := &numberFS{}
+	fs := NewForgetFileSystem(wrapped, ForgetConfig{
+		OnForget: func(inodes []fuseops.InodeID) {
+			slices.Sort(inodes)
+			delivered = append(delivered, inodes)
+		},
+	})
+
+	lookUp := func(name string) {
+		if err := fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 1, Name: name}); err != nil {
+			t.Fatalf("LookUpInode: %v", err)
+		}
+	}
+
+	lookUp("aa")
+	lookUp("aa")
+	lookUp("bbb")
+	lookUp("cccc")
+	if n := fs.Lookups(2); n != 2 {
+		t.Errorf("Lookups(2): got %d, want 2", n)
+	}
+
+	// Inodes are delivered once their counts fall to zero.
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 2, N: 1})
+	fs.BatchForget(ctx, &fuseops.BatchForgetOp{Entries: []fuseops.BatchForgetEntry{
+		{Inode: 2, N: 1},
+		{Inode: 3, N: 1},
+		{Inode: 99, N: 1},
+	}})
+
+	if want := [][]fuseops.InodeID{{2, 3}}; !slices.EqualFunc(delivered, want, slices.Equal) {
+		t.Errorf("delivered: got %v, want %v", delivered, want)
+	}
+
+	// Those still referenced are delivered on destruction.
+	fs.Destroy()
+	if want := [][]fuseops.InodeID{{2, 3}, {4}}; !slices.EqualFunc(delivered, want, slices.Equal) {
+		t.Errorf("delivered: got %v, want %v", delivered, want)
+	}
+
+	if !wrapped.destroyed {
+		t.Errorf("wrapped file system not destroyed")
+	}
+}
+
+func TestForgetFileSystemWindow(t *testing.T) {
+	ctx := context.Background()
+	delivered := make(chan []fuseops.InodeID, 10)
+	fs := NewForgetFileSystem(&numberFS{}, ForgetConfig{
+		OnForget: func(inodes []fuseops.InodeID) {
+			slices.Sort(inodes)
+			delivered <- inodes
+		},
+		Window: 50 * time.Millisecond,
+	})
+
+	for _, name := range []string{"aa", "bbb", "cccc"} {
+		fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 1, Name: name})
+	}
+
+	// Forgets within the window are delivered together, except for inodes
+	// looked up again.
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 2, N: 1})
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 3, N: 1})
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 4, N: 1})
+	fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: 1, Name: "bbb"})
+
+	if got := <-delivered; !slices.Equal(got, []fuseops.InodeID{2, 4}) {
+		t.Errorf("delivered: got %v, want [2 4]", got)
+	}
+
+	fs.Destroy()
+	if got := <-delivered; !slices.Equal(got, []fuseops.InodeID{3}) {
+		t.Errorf("delivered on destruction: got %v, want [3]", got)
+	}
+}
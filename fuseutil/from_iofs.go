@@ -0,0 +1,407 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// NewFSFromIOFS returns a read-only file system serving the contents of the
+// supplied io/fs.FS, such as an embed.FS, a zip.Reader, or an fstest.MapFS,
+// so that it can be mounted with NewFileSystemServer. It is the reverse of
+// NewIOFS.
+//
+// Files and directories are owned by the user running the process, with the
+// permissions reported by fsys, and their times are all the modification
+// time. Symbolic links are followed if fsys follows them in fs.Stat, and
+// otherwise can't be read. Ops that would modify the file system fail with
+// ENOSYS; mount with MountConfig.ReadOnly to have the kernel reject them with
+// EROFS instead.
+//
+// Each path is assigned an inode ID when first seen, which is kept for the
+// life of the file system, so memory use grows with the number of distinct
+// paths looked up or listed. Each open directory handle lists the directory
+// once, when opened. Files are read with ReadAt if they implement
+// io.ReaderAt, concurrently, and otherwise one read at a time, by seeking or,
+// failing that, reading sequentially and reopening the file to go backwards.
+func NewFSFromIOFS(fsys fs.FS) FileSystem {
+	return &fromIOFS{
+		fsys:    fsys,
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+		paths:   map[fuseops.InodeID]string{fuseops.RootInodeID: "."},
+		inodes:  map[string]fuseops.InodeID{".": fuseops.RootInodeID},
+		handles: make(map[fuseops.HandleID]any),
+	}
+}
+
+type fromIOFS struct {
+	NotImplementedFileSystem
+	fsys fs.FS
+	uid  uint32
+	gid  uint32
+
+	mu sync.Mutex
+
+	// The path of each inode, and the inode of each path.
+	//
+	// GUARDED_BY(mu)
+	paths  map[fuseops.InodeID]string
+	inodes map[string]fuseops.InodeID
+
+	// The *fromIOFSDir or *fromIOFSFile for each open handle.
+	//
+	// GUARDED_BY(mu)
+	handles    map[fuseops.HandleID]any
+	nextHandle fuseops.HandleID
+}
+
+// An open directory, listed when opened.
+type fromIOFSDir struct {
+	entries []Dirent
+}
+
+// An open file.
+type fromIOFSFile struct {
+	name string
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	f fs.File
+
+	// The offset of f, for files read sequentially.
+	//
+	// GUARDED_BY(mu)
+	offset int64
+}
+
+// Return the path of the inode.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *fromIOFS) path(inode fuseops.InodeID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.paths[inode]
+	if !ok {
+		return "", syscall.ESTALE
+	}
+
+	return p, nil
+}
+
+// Return the inode for the path, assigning one if necessary.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *fromIOFS) inode(p string) fuseops.InodeID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.inodes[p]
+	if !ok {
+		id = fuseops.InodeID(len(s.inodes) + 1)
+		s.inodes[p] = id
+		s.paths[id] = p
+	}
+
+	return id
+}
+
+// Record the open handle, returning its ID.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *fromIOFS) open(h any) fuseops.HandleID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextHandle++
+	s.handles[s.nextHandle] = h
+	return s.nextHandle
+}
+
+// Return the open handle with the supplied ID.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *fromIOFS) handle(id fuseops.HandleID) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[id]
+}
+
+// Remove the open handle with the supplied ID, returning it.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *fromIOFS) release(id fuseops.HandleID) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.handles[id]
+	delete(s.handles, id)
+	return h
+}
+
+func (s *fromIOFS) attributes(info fs.FileInfo) fuseops.InodeAttributes {
+	return fuseops.InodeAttributes{
+		Size:  uint64(info.Size()),
+		Nlink: 1,
+		Mode:  info.Mode(),
+		Atime: info.ModTime(),
+		Mtime: info.ModTime(),
+		Ctime: info.ModTime(),
+		Uid:   s.uid,
+		Gid:   s.gid,
+	}
+}
+
+func direntType(mode fs.FileMode) DirentType {
+	switch {
+	case mode.IsDir():
+		return DT_Directory
+	case mode.IsRegular():
+		return DT_File
+	case mode&fs.ModeSymlink != 0:
+		return DT_Link
+	}
+
+	return DT_Unknown
+}
+
+func (s *fromIOFS) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) error {
+	return nil
+}
+
+func (s *fromIOFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	parent, err := s.path(op.Parent)
+	if err != nil {
+		return err
+	}
+
+	p := path.Join(parent, op.Name)
+	if !fs.ValidPath(p) {
+		return syscall.ENOENT
+	}
+
+	info, err := fs.Stat(s.fsys, p)
+	if err != nil {
+		return err
+	}
+
+	op.Entry.Child = s.inode(p)
+	op.Entry.Attributes = s.attributes(info)
+	return nil
+}
+
+func (s *fromIOFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	p, err := s.path(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	info, err := fs.Stat(s.fsys, p)
+	if err != nil {
+		return err
+	}
+
+	op.Attributes = s.attributes(info)
+	return nil
+}
+
+// Inode IDs are kept for the life of the file system, so there is nothing to
+// forget.
+func (s *fromIOFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	return nil
+}
+
+func (s *fromIOFS) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	return nil
+}
+
+func (s *fromIOFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	p, err := s.path(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(s.fsys, p)
+	if err != nil {
+		return err
+	}
+
+	d := &fromIOFSDir{}
+	for _, e := range entries {
+		d.entries = append(d.entries, Dirent{
+			Offset: fuseops.DirOffset(len(d.entries) + 1),
+			Inode:  s.inode(path.Join(p, e.Name())),
+			Name:   e.Name(),
+			Type:   direntType(e.Type()),
+		})
+	}
+
+	op.Handle = s.open(d)
+	return nil
+}
+
+func (s *fromIOFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	d, ok := s.handle(op.Handle).(*fromIOFSDir)
+	if !ok {
+		return syscall.EBADF
+	}
+
+	if op.Offset > fuseops.DirOffset(len(d.entries)) {
+		return nil
+	}
+
+	for _, e := range d.entries[op.Offset:] {
+		n := WriteDirent(op.Dst[op.BytesRead:], e)
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (s *fromIOFS) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	s.release(op.Handle)
+	return nil
+}
+
+func (s *fromIOFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	p, err := s.path(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.fsys.Open(p)
+	if err != nil {
+		return err
+	}
+
+	op.Handle = s.open(&fromIOFSFile{name: p, f: f})
+	op.KeepPageCache = true
+	return nil
+}
+
+func (s *fromIOFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	f, ok := s.handle(op.Handle).(*fromIOFSFile)
+	if !ok {
+		return syscall.EBADF
+	}
+
+	// Files that can be read at an offset are never reopened, and io.ReaderAt
+	// allows concurrent calls.
+	f.mu.Lock()
+	if r, ok := f.f.(io.ReaderAt); ok {
+		f.mu.Unlock()
+		n, err := r.ReadAt(op.Dst, op.Offset)
+		op.BytesRead = n
+		if err == io.EOF {
+			err = nil
+		}
+
+		return err
+	}
+
+	defer f.mu.Unlock()
+
+	if seeker, ok := f.f.(io.Seeker); ok {
+		offset, err := seeker.Seek(op.Offset, io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		f.offset = offset
+	}
+
+	// Go back by reopening the file, or retry if that failed last time.
+	if f.f == nil || op.Offset < f.offset {
+		if f.f != nil {
+			f.f.Close()
+			f.f = nil
+		}
+
+		reopened, err := s.fsys.Open(f.name)
+		if err != nil {
+			return err
+		}
+
+		f.f = reopened
+		f.offset = 0
+	}
+
+	skipped, err := io.CopyN(io.Discard, f.f, op.Offset-f.offset)
+	f.offset += skipped
+	if err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+
+		return err
+	}
+
+	n, err := io.ReadFull(f.f, op.Dst)
+	f.offset += int64(n)
+	op.BytesRead = n
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+
+	return err
+}
+
+func (s *fromIOFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	if f, ok := s.release(op.Handle).(*fromIOFSFile); ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.f != nil {
+			f.f.Close()
+		}
+	}
+
+	return nil
+}
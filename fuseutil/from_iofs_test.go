@@ -0,0 +1,58 @@
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var fromIOFSFiles = fstest.MapFS{
+	"a.txt":           {Data: []byte("taco"), Mode: 0644, ModTime: time.Unix(1, 0)},
+	"dir/b.txt":       {Data: []byte(strings.Repeat("burrito", 1000))},
+	"dir/sub/c.txt":   {Data: []byte("enchilada")},
+	"empty/.keep":     {},
+	"empty-file.txt":  {},
+	"dir/sub/d/e.txt": {Data: []byte("queso"), Mode: 0600},
+}
+
+// Hides the io.ReaderAt and io.Seeker methods of files, so that they can
+// only be read sequentially.
+type sequentialFS struct {
+	fs.FS
+}
+
+func (s sequentialFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+
+	return struct{ fs.File }{f}, nil
+}
+
+func TestFSFromIOFS(t *testing.T) {
+	ctx := context.Background()
+	for _, fsys := range []fs.FS{fromIOFSFiles, sequentialFS{fromIOFSFiles}} {
+		got := NewIOFS(ctx, NewFSFromIOFS(fsys))
+		if err := fstest.TestFS(got, "a.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/d/e.txt", "empty-file.txt"); err != nil {
+			t.Errorf("%T: %v", fsys, err)
+		}
+
+		info, err := fs.Stat(got, "a.txt")
+		if err != nil || info.Mode() != 0644 || !info.ModTime().Equal(time.Unix(1, 0)) {
+			t.Errorf("%T: Stat: %v, %v", fsys, info, err)
+		}
+
+		if _, err := fs.Stat(got, "dir/missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%T: Stat of missing file: %v", fsys, err)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// HandleGuardFileSystem is a FileSystem that keeps a registry of the file and
+// directory handles issued by the file system it wraps, and rejects ops that
+// refer to any other handle with EBADF, without passing them on. This protects
+// file systems that would otherwise misbehave or panic when given a handle
+// they don't know, as can happen when a daemon is restarted and takes over an
+// existing mount whose open files refer to handles issued by its predecessor.
+// Create one with NewHandleGuardFileSystem.
+//
+// File handles are those issued by OpenFile, CreateFile and CreateTmpfile, and
+// are accepted by ReadFile, WriteFile, SyncFile, FlushFile, Fallocate,
+// CopyFileRange (both handles), Lseek, Poll, GetLk, SetLk, SetLkW,
+// ReleaseFileHandle, SetInodeAttributes, and Statx. Directory handles are those
+// issued by OpenDir, and are accepted by ReadDir, ReadDirPlus, SyncFile (for
+// fsyncdir), and ReleaseDirHandle. A handle may be issued more than once, e.g.
+// if the file system always uses zero, in which case it remains valid until
+// each issue of it has been released.
+//
+// This is incompatible with MountConfig.EnableNoOpenSupport and
+// EnableNoOpendirSupport, with which the kernel uses handles that were never
+// issued.
+type HandleGuardFileSystem struct {
+	FileSystem
+
+	mu sync.Mutex
+
+	// The number of unreleased issues of each handle.
+	//
+	// GUARDED_BY(mu)
+	handles map[guardedHandle]int
+}
+
+type guardedHandle struct {
+	id  fuseops.HandleID
+	dir bool
+}
+
+// NewHandleGuardFileSystem wraps the supplied file system, guarding its
+// handles as described on HandleGuardFileSystem.
+func NewHandleGuardFileSystem(wrapped FileSystem) *HandleGuardFileSystem {
+	return &HandleGuardFileSystem{
+		FileSystem: wrapped,
+		handles:    make(map[guardedHandle]int),
+	}
+}
+
+// Handles returns the number of file and directory handles that have been
+// issued and not yet released.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *HandleGuardFileSystem) Handles() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var n int
+	for _, count := range fs.handles {
+		n += count
+	}
+
+	return n
+}
+
+// Record the handle as issued if err is nil, returning err.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *HandleGuardFileSystem) issue(h guardedHandle, err error) error {
+	if err == nil {
+		fs.mu.Lock()
+		fs.handles[h]++
+		fs.mu.Unlock()
+	}
+
+	return err
+}
+
+// Return EBADF unless one of the supplied handles has been issued and not
+// released.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *HandleGuardFileSystem) check(handles ...guardedHandle) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, h := range handles {
+		if fs.handles[h] > 0 {
+			return nil
+		}
+	}
+
+	return syscall.EBADF
+}
+
+// Check the handle and, if it is valid, release one issue of it.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *HandleGuardFileSystem) release(h guardedHandle) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	switch fs.handles[h] {
+	case 0:
+		return syscall.EBADF
+
+	case 1:
+		delete(fs.handles, h)
+
+	default:
+		fs.handles[h]--
+	}
+
+	return nil
+}
+
+func fileHandle(id fuseops.HandleID) guardedHandle {
+	return guardedHandle{id: id}
+}
+
+func dirHandle(id fuseops.HandleID) guardedHandle {
+	return guardedHandle{id: id, dir: true}
+}
+
+func (fs *HandleGuardFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	return fs.issue(fileHandle(op.Handle), err)
+}
+
+func (fs *HandleGuardFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	err := fs.FileSystem.CreateTmpfile(ctx, op)
+	return fs.issue(fileHandle(op.Handle), err)
+}
+
+func (fs *HandleGuardFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	err := fs.FileSystem.OpenFile(ctx, op)
+	return fs.issue(fileHandle(op.Handle), err)
+}
+
+func (fs *HandleGuardFileSystem) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	err := fs.FileSystem.OpenDir(ctx, op)
+	return fs.issue(dirHandle(op.Handle), err)
+}
+
+func (fs *HandleGuardFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	if op.Handle != nil {
+		if err := fs.check(fileHandle(*op.Handle)); err != nil {
+			return err
+		}
+	}
+
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) Statx(
+	ctx context.Context,
+	op *fuseops.StatxOp) error {
+	if op.Handle != nil {
+		if err := fs.check(fileHandle(*op.Handle)); err != nil {
+			return err
+		}
+	}
+
+	return fs.FileSystem.Statx(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReadFile(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.WriteFile(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	// SyncFileOp is used for both fsync and fsyncdir.
+	if err := fs.check(fileHandle(op.Handle), dirHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SyncFile(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.FlushFile(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Fallocate(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	if err := fs.check(fileHandle(op.SrcHandle)); err != nil {
+		return err
+	}
+
+	if err := fs.check(fileHandle(op.DstHandle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CopyFileRange(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) Lseek(
+	ctx context.Context,
+	op *fuseops.LseekOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Lseek(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) Poll(
+	ctx context.Context,
+	op *fuseops.PollOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Poll(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) GetLk(
+	ctx context.Context,
+	op *fuseops.GetLkOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.GetLk(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) SetLk(
+	ctx context.Context,
+	op *fuseops.SetLkOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SetLk(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) SetLkW(
+	ctx context.Context,
+	op *fuseops.SetLkWOp) error {
+	if err := fs.check(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SetLkW(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	if err := fs.release(fileHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReleaseFileHandle(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	if err := fs.check(dirHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReadDir(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) ReadDirPlus(
+	ctx context.Context,
+	op *fuseops.ReadDirPlusOp) error {
+	if err := fs.check(dirHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReadDirPlus(ctx, op)
+}
+
+func (fs *HandleGuardFileSystem) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	if err := fs.release(dirHandle(op.Handle)); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReleaseDirHandle(ctx, op)
+}
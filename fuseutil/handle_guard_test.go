@@ -0,0 +1,119 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system that panics when given a handle it didn't issue.
+type handleFS struct {
+	NotImplementedFileSystem
+	next    fuseops.HandleID
+	handles map[fuseops.HandleID]bool
+}
+
+func (fs *handleFS) issue() fuseops.HandleID {
+	fs.next++
+	fs.handles[fs.next] = true
+	return fs.next
+}
+
+func (fs *handleFS) use(h fuseops.HandleID) error {
+	if !fs.handles[h] {
+		panic("unknown handle")
+	}
+
+	return nil
+}
+
+func (fs *handleFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	op.Handle = fs.issue()
+	return nil
+}
+
+func (fs *handleFS) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	op.Handle = fs.issue()
+	return nil
+}
+
+func (fs *handleFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	return fs.use(op.Handle)
+}
+
+func (fs *handleFS) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	return fs.use(op.Handle)
+}
+
+func (fs *handleFS) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	return fs.use(op.Handle)
+}
+
+func (fs *handleFS) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) error {
+	err := fs.use(op.Handle)
+	delete(fs.handles, op.Handle)
+	return err
+}
+
+func TestHandleGuard(t *testing.T) {
+	ctx := context.Background()
+	fs := NewHandleGuardFileSystem(&handleFS{handles: make(map[fuseops.HandleID]bool)})
+
+	open := &fuseops.OpenFileOp{Inode: 2}
+	if err := fs.OpenFile(ctx, open); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	openDir := &fuseops.OpenDirOp{Inode: 1}
+	if err := fs.OpenDir(ctx, openDir); err != nil {
+		t.Fatalf("OpenDir: %v", err)
+	}
+
+	if n := fs.Handles(); n != 2 {
+		t.Errorf("Handles: got %d, want 2", n)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		call func() error
+		want error
+	}{
+		{"read", func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Handle: open.Handle})
+		}, nil},
+		{"read of a handle never issued", func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Handle: 17})
+		}, syscall.EBADF},
+		{"read of a directory handle", func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Handle: openDir.Handle})
+		}, syscall.EBADF},
+		{"readdir", func() error {
+			return fs.ReadDir(ctx, &fuseops.ReadDirOp{Handle: openDir.Handle})
+		}, nil},
+		{"readdir of a file handle", func() error {
+			return fs.ReadDir(ctx, &fuseops.ReadDirOp{Handle: open.Handle})
+		}, syscall.EBADF},
+		{"fsyncdir", func() error {
+			return fs.SyncFile(ctx, &fuseops.SyncFileOp{Handle: openDir.Handle})
+		}, nil},
+		{"release", func() error {
+			return fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: open.Handle})
+		}, nil},
+		{"read after release", func() error {
+			return fs.ReadFile(ctx, &fuseops.ReadFileOp{Handle: open.Handle})
+		}, syscall.EBADF},
+		{"second release", func() error {
+			return fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: open.Handle})
+		}, syscall.EBADF},
+	} {
+		if err := tc.call(); err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, err, tc.want)
+		}
+	}
+
+	if n := fs.Handles(); n != 1 {
+		t.Errorf("Handles: got %d, want 1", n)
+	}
+}
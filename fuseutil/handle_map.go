@@ -0,0 +1,150 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// HandleMap holds a file system's state for each of the file handles it has
+// issued, e.g. a buffer of data not yet uploaded, and keeps it until the
+// kernel releases the handle. The zero value is an empty map, ready for use,
+// and it is safe for concurrent use.
+//
+// The kernel issues one OpenFileOp or CreateFileOp for each open file
+// description, and every file descriptor referring to it uses the same handle:
+// those made by dup(2) or fcntl(2) with F_DUPFD, those inherited across
+// fork(2), and those passed to another process over a unix socket. Each of
+// them sends a FlushFileOp when closed, but nothing is sent when they are
+// made, so flushes can't be counted to tell when the last one goes away. That
+// is what ReleaseFileHandleOp is for: it is sent once, after every descriptor
+// has been closed and every mapping of the file unmapped. State must
+// therefore be kept until then, and a FlushFileOp should at most write back
+// data, as for fsync(2).
+//
+// A file system that issues the same handle for several opens, e.g. one per
+// inode, is sent a ReleaseFileHandleOp for each, and should record each
+// further issue with Reissue so that the state outlives all but the last.
+type HandleMap[T any] struct {
+	mu sync.Mutex
+
+	// The most recently minted handle.
+	//
+	// GUARDED_BY(mu)
+	last fuseops.HandleID
+
+	// GUARDED_BY(mu)
+	entries map[fuseops.HandleID]*handleMapEntry[T]
+}
+
+type handleMapEntry[T any] struct {
+	value T
+
+	// The number of times the handle has been issued and not yet released.
+	issues int
+}
+
+// Issue mints a new handle holding the supplied state, to be returned by an
+// OpenFileOp or CreateFileOp.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *HandleMap[T]) Issue(v T) fuseops.HandleID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[fuseops.HandleID]*handleMapEntry[T])
+	}
+
+	// Skip handles still in use, in case the counter has wrapped.
+	for {
+		m.last++
+		if _, ok := m.entries[m.last]; !ok {
+			break
+		}
+	}
+
+	m.entries[m.last] = &handleMapEntry[T]{value: v, issues: 1}
+	return m.last
+}
+
+// Reissue records that a handle is being returned by a further open, and so
+// will be released once more before its state is discarded. It returns false
+// if the handle isn't in the map.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *HandleMap[T]) Reissue(h fuseops.HandleID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[h]
+	if e == nil {
+		return false
+	}
+
+	e.issues++
+	return true
+}
+
+// Get returns the state of a handle, or false if it isn't in the map.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *HandleMap[T]) Get(h fuseops.HandleID) (v T, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[h]
+	if e == nil {
+		return v, false
+	}
+
+	return e.value, true
+}
+
+// Release records a ReleaseFileHandleOp for a handle, and returns its state
+// and whether that was its last issue, in which case it has been removed from
+// the map and its resources may be freed. It returns false for both if the
+// handle isn't in the map.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *HandleMap[T]) Release(h fuseops.HandleID) (v T, last bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[h]
+	if e == nil {
+		return v, false
+	}
+
+	e.issues--
+	if e.issues > 0 {
+		return e.value, false
+	}
+
+	delete(m.entries, h)
+	return e.value, true
+}
+
+// Len returns the number of handles in the map.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *HandleMap[T]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.entries)
+}
@@ -0,0 +1,66 @@
+package fuseutil
+
+import (
+	"testing"
+)
+
+func TestHandleMap(t *testing.T) {
+	var m HandleMap[string]
+
+	// Each issue mints a new handle.
+	taco := m.Issue("taco")
+	burrito := m.Issue("burrito")
+	if taco == burrito {
+		t.Fatalf("Issue returned %v twice", taco)
+	}
+
+	if v, ok := m.Get(burrito); !ok || v != "burrito" {
+		t.Errorf("Get: got (%q, %v)", v, ok)
+	}
+
+	// A reissued handle survives until it has been released once per issue.
+	if !m.Reissue(taco) {
+		t.Fatalf("Reissue failed")
+	}
+
+	if v, last := m.Release(taco); v != "taco" || last {
+		t.Errorf("First Release: got (%q, %v), want (taco, false)", v, last)
+	}
+
+	if _, ok := m.Get(taco); !ok {
+		t.Errorf("Handle gone after first release")
+	}
+
+	if v, last := m.Release(taco); v != "taco" || !last {
+		t.Errorf("Second Release: got (%q, %v), want (taco, true)", v, last)
+	}
+
+	if _, ok := m.Get(taco); ok {
+		t.Errorf("Handle still present after last release")
+	}
+
+	// Unknown handles are reported as such.
+	if m.Reissue(taco) {
+		t.Errorf("Reissue of released handle succeeded")
+	}
+
+	if _, last := m.Release(taco); last {
+		t.Errorf("Release of released handle reported last")
+	}
+
+	if n := m.Len(); n != 1 {
+		t.Errorf("Len: got %d, want 1", n)
+	}
+}
+
+func TestHandleMap_Wraparound(t *testing.T) {
+	var m HandleMap[int]
+	in := m.Issue(0)
+
+	// Handles still in use aren't minted again, e.g. once the counter has
+	// wrapped around to them.
+	m.last = in - 1
+	if h := m.Issue(1); h == in {
+		t.Errorf("Issue reused handle %v", h)
+	}
+}
@@ -0,0 +1,521 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HTTPConfig configures NewHTTPFS.
+type HTTPConfig struct {
+	// The URL of each file, keyed by its path in the file system, which must
+	// be valid according to fs.ValidPath, e.g. "layers/base.tar". Directories
+	// are implied by the paths. The servers must report the size of each file
+	// in response to HEAD, and should support range requests.
+	Files map[string]string
+
+	// The client with which to make requests. If nil, http.DefaultClient is
+	// used. Requests aren't cancelled when the reads they serve are
+	// interrupted, so the client should have a timeout.
+	Client *http.Client
+
+	// The size of the segments in which files are fetched and cached. If zero,
+	// 1 MiB is used.
+	SegmentSize int
+
+	// The maximum number of segments cached, across all files. The least
+	// recently used are discarded first. If zero, 64 are cached.
+	CacheSegments int
+
+	// The maximum number of segments fetched at once for a single read. If
+	// zero, 4 are.
+	MaxParallelFetches int
+}
+
+// NewHTTPFS returns a read-only io/fs.FS serving files from HTTP(S) URLs, so
+// that remote artifacts such as container layers and datasets can be mounted
+// with NewFSFromIOFS:
+//
+//	fsys, err := fuseutil.NewHTTPFS(fuseutil.HTTPConfig{Files: urls})
+//	...
+//	server := fuseutil.NewFileSystemServer(fuseutil.NewFSFromIOFS(fsys))
+//
+// The size and modification time of each file are fetched with a HEAD request
+// when the file is first opened or stat'ed, and assumed not to change.
+// Reads are served with range requests for the segments they cover, fetched
+// concurrently and cached in memory, so that nearby reads needn't go back to
+// the server. Files implement io.ReaderAt, for concurrent reads.
+func NewHTTPFS(cfg HTTPConfig) (fs.FS, error) {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	if cfg.SegmentSize == 0 {
+		cfg.SegmentSize = 1 << 20
+	}
+
+	if cfg.CacheSegments == 0 {
+		cfg.CacheSegments = 64
+	}
+
+	if cfg.MaxParallelFetches == 0 {
+		cfg.MaxParallelFetches = 4
+	}
+
+	s := &httpFS{
+		cfg:      cfg,
+		files:    make(map[string]*httpObject),
+		dirs:     map[string][]string{".": nil},
+		segments: make(map[httpSegmentKey]*httpSegment),
+		lru:      list.New(),
+	}
+
+	for name, url := range cfg.Files {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("invalid path %q", name)
+		}
+
+		s.files[name] = &httpObject{url: url}
+	}
+
+	// Add each file to its directory, and each directory to its parent.
+	for name := range cfg.Files {
+		for name != "." {
+			dir := path.Dir(name)
+			if _, ok := s.files[dir]; ok {
+				return nil, fmt.Errorf("path %q is both a file and a directory", dir)
+			}
+
+			_, seen := s.dirs[dir]
+			s.dirs[dir] = append(s.dirs[dir], path.Base(name))
+			if seen {
+				break
+			}
+
+			name = dir
+		}
+	}
+
+	for _, children := range s.dirs {
+		sort.Strings(children)
+	}
+
+	return s, nil
+}
+
+type httpFS struct {
+	cfg HTTPConfig
+
+	// The files, and the sorted names of the children of each directory.
+	files map[string]*httpObject
+	dirs  map[string][]string
+
+	mu sync.Mutex
+
+	// The cached segments, and those being fetched, and a list of the cached
+	// ones, most recently used first.
+	//
+	// GUARDED_BY(mu)
+	segments map[httpSegmentKey]*httpSegment
+	lru      *list.List
+}
+
+// A remote file.
+type httpObject struct {
+	url string
+
+	// Set once the HEAD request succeeds.
+	//
+	// GUARDED_BY(httpFS.mu)
+	info *httpFileInfo
+}
+
+type httpSegmentKey struct {
+	url   string
+	index int64
+}
+
+type httpSegment struct {
+	key httpSegmentKey
+
+	// Closed once the fetch finishes, after which data and err are set.
+	ready chan struct{}
+	data  []byte
+	err   error
+
+	// The segment's place in httpFS.lru, once cached.
+	//
+	// GUARDED_BY(httpFS.mu)
+	elem *list.Element
+}
+
+// Return information about the file or directory, making a HEAD request for
+// a file the first time.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *httpFS) stat(name string) (*httpFileInfo, error) {
+	if _, ok := s.dirs[name]; ok {
+		return &httpFileInfo{name: path.Base(name), dir: true}, nil
+	}
+
+	o, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	s.mu.Lock()
+	info := o.info
+	s.mu.Unlock()
+	if info != nil {
+		return info, nil
+	}
+
+	req, err := http.NewRequest(http.MethodHead, o.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HEAD %s: %s", o.url, resp.Status)
+	}
+
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("HEAD %s: unknown size", o.url)
+	}
+
+	info = &httpFileInfo{name: path.Base(name), size: resp.ContentLength}
+	info.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	s.mu.Lock()
+	o.info = info
+	s.mu.Unlock()
+
+	return info, nil
+}
+
+// Fetch bytes [start, end) of the file.
+func (s *httpFS) fetch(url string, start, end int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+
+	// The server ignored the range, and sent the whole file.
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			return nil, fmt.Errorf("GET %s: %w", url, err)
+		}
+
+	default:
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+
+	return data, nil
+}
+
+// Return the segment of the file with the supplied index, from the cache if
+// possible. Concurrent calls for the same segment share a fetch.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *httpFS) segment(o *httpObject, size int64, index int64) ([]byte, error) {
+	key := httpSegmentKey{o.url, index}
+
+	s.mu.Lock()
+	seg, ok := s.segments[key]
+	if ok {
+		if seg.elem != nil {
+			s.lru.MoveToFront(seg.elem)
+		}
+
+		s.mu.Unlock()
+		<-seg.ready
+		return seg.data, seg.err
+	}
+
+	seg = &httpSegment{key: key, ready: make(chan struct{})}
+	s.segments[key] = seg
+	s.mu.Unlock()
+
+	start := index * int64(s.cfg.SegmentSize)
+	seg.data, seg.err = s.fetch(o.url, start, min(start+int64(s.cfg.SegmentSize), size))
+	close(seg.ready)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Errors aren't cached.
+	if seg.err != nil {
+		delete(s.segments, key)
+		return nil, seg.err
+	}
+
+	seg.elem = s.lru.PushFront(seg)
+	for s.lru.Len() > s.cfg.CacheSegments {
+		evicted := s.lru.Remove(s.lru.Back()).(*httpSegment)
+		delete(s.segments, evicted.key)
+	}
+
+	return seg.data, nil
+}
+
+func (s *httpFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := s.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return info, nil
+}
+
+func (s *httpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := s.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.dir {
+		d := &httpDir{info: info}
+		for _, child := range s.dirs[name] {
+			_, dir := s.dirs[path.Join(name, child)]
+			d.entries = append(d.entries, &httpDirEntry{
+				s:    s,
+				path: path.Join(name, child),
+				dir:  dir,
+			})
+		}
+
+		return d, nil
+	}
+
+	return &httpFile{s: s, path: name, o: s.files[name], info: info}, nil
+}
+
+// An open file, which implements io.ReaderAt and io.Seeker.
+type httpFile struct {
+	s    *httpFS
+	path string
+	o    *httpObject
+	info *httpFileInfo
+
+	// The offset of the next Read.
+	offset int64
+}
+
+func (f *httpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	size := f.info.size
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), size)
+	segmentSize := int64(f.s.cfg.SegmentSize)
+	first, last := off/segmentSize, (end-1)/segmentSize
+
+	// Fetch the segments concurrently, copying each into place.
+	errs := make([]error, last-first+1)
+	sem := make(chan struct{}, f.s.cfg.MaxParallelFetches)
+	var wg sync.WaitGroup
+	for i := first; i <= last; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			data, err := f.s.segment(f.o, size, i)
+			if err != nil {
+				errs[i-first] = err
+				return
+			}
+
+			start := i * segmentSize
+			lo, hi := max(off, start), min(end, start+int64(len(data)))
+			copy(p[lo-off:hi-off], data[lo-start:hi-start])
+		}()
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.path, Err: err}
+		}
+	}
+
+	n := int(end - off)
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+
+	case io.SeekEnd:
+		offset += f.info.size
+
+	default:
+		offset = -1
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *httpFile) Close() error {
+	return nil
+}
+
+// An open directory.
+type httpDir struct {
+	info *httpFileInfo
+
+	// The entries not yet returned by ReadDir.
+	entries []fs.DirEntry
+}
+
+func (d *httpDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *httpDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *httpDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	count := len(d.entries)
+	if n > 0 {
+		count = min(n, count)
+		if count == 0 {
+			return nil, io.EOF
+		}
+	}
+
+	entries := d.entries[:count:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *httpDir) Close() error {
+	return nil
+}
+
+// A directory entry, whose Info makes a HEAD request for a file the first
+// time.
+type httpDirEntry struct {
+	s    *httpFS
+	path string
+	dir  bool
+}
+
+func (e *httpDirEntry) Name() string { return path.Base(e.path) }
+func (e *httpDirEntry) IsDir() bool  { return e.dir }
+
+func (e *httpDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+
+	return 0
+}
+
+func (e *httpDirEntry) Info() (fs.FileInfo, error) {
+	return e.s.Stat(e.path)
+}
+
+// The fs.FileInfo for a file or directory.
+type httpFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *httpFileInfo) Name() string       { return i.name }
+func (i *httpFileInfo) Size() int64        { return i.size }
+func (i *httpFileInfo) ModTime() time.Time { return i.modTime }
+func (i *httpFileInfo) IsDir() bool        { return i.dir }
+func (i *httpFileInfo) Sys() any           { return nil }
+
+func (i *httpFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
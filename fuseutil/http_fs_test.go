@@ -0,0 +1,90 @@
+package fuseutil
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHTTPFS(t *testing.T) {
+	contents := map[string]string{
+		"/taco":    strings.Repeat("taco", 1000),
+		"/burrito": "burrito",
+		"/empty":   "",
+	}
+
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := contents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+
+		http.ServeContent(w, r, "", time.Unix(1, 0), strings.NewReader(c))
+	}))
+	defer server.Close()
+
+	fsys, err := NewHTTPFS(HTTPConfig{
+		Files: map[string]string{
+			"layers/taco.txt":     server.URL + "/taco",
+			"layers/more/burrito": server.URL + "/burrito",
+			"empty":               server.URL + "/empty",
+		},
+		SegmentSize:   100,
+		CacheSegments: 100,
+	})
+
+	if err != nil {
+		t.Fatalf("NewHTTPFS: %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "layers/taco.txt", "layers/more/burrito", "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The whole file is cached now, so reading it again makes no requests.
+	before := gets.Load()
+	f, err := fsys.Open("layers/taco.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	defer f.Close()
+	buf := make([]byte, 4100)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 0)
+	if n != 4000 || err != io.EOF || !bytes.Equal(buf[:n], []byte(contents["/taco"])) {
+		t.Fatalf("ReadAt: %d, %v", n, err)
+	}
+
+	if got := gets.Load(); got != before {
+		t.Errorf("%d more GET requests", got-before)
+	}
+
+	info, err := fs.Stat(fsys, "layers/taco.txt")
+	if err != nil || info.Size() != 4000 || !info.ModTime().Equal(time.Unix(1, 0)) {
+		t.Errorf("Stat: %v, %v", info, err)
+	}
+}
+
+func TestHTTPFS_InvalidPaths(t *testing.T) {
+	for _, files := range []map[string]string{
+		{"/taco": "http://example.com/taco"},
+		{"a": "http://example.com/a", "a/b": "http://example.com/b"},
+	} {
+		if _, err := NewHTTPFS(HTTPConfig{Files: files}); err == nil {
+			t.Errorf("NewHTTPFS(%v) succeeded", files)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"encoding/binary"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Inode flags, as read and written by the FS_IOC_GETFLAGS and FS_IOC_SETFLAGS
+// ioctls. See ioctl_iflags(2).
+const (
+	// The file can't be modified, removed, renamed, or linked to, and no
+	// entries can be added to or removed from the directory.
+	InodeFlagImmutable uint32 = 0x10
+
+	// The file can only be opened for writing in append mode, and can't be
+	// removed, renamed, or linked to, and entries can't be removed from the
+	// directory.
+	InodeFlagAppendOnly uint32 = 0x20
+)
+
+// The low bits of FS_IOC_GETFLAGS and FS_IOC_SETFLAGS, and of their 32-bit
+// variants, which differ in the size bits.
+const (
+	iocGetFlags = 0x6601
+	iocSetFlags = 0x6602
+)
+
+// InodeFlagsConfig configures an InodeFlagsFileSystem.
+type InodeFlagsConfig struct {
+	// Return the flags of the inode. Must be non-nil. Called before most ops
+	// that modify inodes, so it should be cheap.
+	Get func(ctx context.Context, inode fuseops.InodeID) (uint32, error)
+
+	// Set the flags of the inode. If nil, FS_IOC_SETFLAGS fails with
+	// EOPNOTSUPP.
+	Set func(ctx context.Context, inode fuseops.InodeID, flags uint32) error
+}
+
+// InodeFlagsFileSystem is a FileSystem that serves the FS_IOC_GETFLAGS and
+// FS_IOC_SETFLAGS ioctls, used by lsattr(1) and chattr(1), from the configured
+// functions, and enforces the immutable and append-only flags as Linux does
+// for local file systems, failing ops that they forbid with EPERM. Create one
+// with NewInodeFlagsFileSystem.
+//
+// The kernel doesn't know the flags of inodes in fuse file systems, so doesn't
+// enforce them itself. Only root may change the immutable and append-only
+// flags, whereas whether others may change other flags is up to Set.
+//
+// The exceptions to Linux's rules are that writes through a file opened in
+// append mode aren't checked to be at the end of the file, and that times of
+// append-only files may be set, since the kernel updates them with
+// SetInodeAttributes when writeback caching is enabled.
+type InodeFlagsFileSystem struct {
+	FileSystem
+	cfg InodeFlagsConfig
+}
+
+// NewInodeFlagsFileSystem wraps the supplied file system, serving and
+// enforcing flags as described on InodeFlagsFileSystem.
+func NewInodeFlagsFileSystem(
+	wrapped FileSystem,
+	cfg InodeFlagsConfig) *InodeFlagsFileSystem {
+	return &InodeFlagsFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Return EPERM if the inode has any of the flags.
+func (fs *InodeFlagsFileSystem) check(
+	ctx context.Context,
+	inode fuseops.InodeID,
+	forbidden uint32) error {
+	flags, err := fs.cfg.Get(ctx, inode)
+	if err != nil {
+		return err
+	}
+
+	if flags&forbidden != 0 {
+		return syscall.EPERM
+	}
+
+	return nil
+}
+
+// Return the flags of the child with the supplied name, and whether it
+// exists. The child is found with the wrapped file system's LookUpInode, and
+// then forgotten.
+func (fs *InodeFlagsFileSystem) childFlags(
+	ctx context.Context,
+	parent fuseops.InodeID,
+	name string) (uint32, bool, error) {
+	op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+	if err := fs.FileSystem.LookUpInode(ctx, op); err != nil || op.Entry.Child == 0 {
+		// Leave any error to the op being checked.
+		return 0, false, nil
+	}
+
+	defer fs.FileSystem.ForgetInode(ctx, &fuseops.ForgetInodeOp{
+		Inode: op.Entry.Child,
+		N:     1,
+	})
+
+	flags, err := fs.cfg.Get(ctx, op.Entry.Child)
+	return flags, true, err
+}
+
+// Return EPERM if the directory or the child with the supplied name, which is
+// about to be removed, is immutable or append-only.
+func (fs *InodeFlagsFileSystem) checkRemove(
+	ctx context.Context,
+	parent fuseops.InodeID,
+	name string) error {
+	const forbidden = InodeFlagImmutable | InodeFlagAppendOnly
+	if err := fs.check(ctx, parent, forbidden); err != nil {
+		return err
+	}
+
+	flags, _, err := fs.childFlags(ctx, parent, name)
+	if err != nil {
+		return err
+	}
+
+	if flags&forbidden != 0 {
+		return syscall.EPERM
+	}
+
+	return nil
+}
+
+func (fs *InodeFlagsFileSystem) Ioctl(
+	ctx context.Context,
+	op *fuseops.IoctlOp) error {
+	switch op.Cmd & 0xffff {
+	case iocGetFlags:
+		if op.OutputSize < 4 {
+			return syscall.EINVAL
+		}
+
+		flags, err := fs.cfg.Get(ctx, op.Inode)
+		if err != nil {
+			return err
+		}
+
+		op.Output = make([]byte, op.OutputSize)
+		binary.NativeEndian.PutUint32(op.Output, flags)
+		return nil
+
+	case iocSetFlags:
+		if len(op.Input) < 4 {
+			return syscall.EINVAL
+		}
+
+		if fs.cfg.Set == nil {
+			return syscall.EOPNOTSUPP
+		}
+
+		flags, err := fs.cfg.Get(ctx, op.Inode)
+		if err != nil {
+			return err
+		}
+
+		newFlags := binary.NativeEndian.Uint32(op.Input)
+		const guarded = InodeFlagImmutable | InodeFlagAppendOnly
+		if (flags^newFlags)&guarded != 0 && op.OpContext.Uid != 0 {
+			return syscall.EPERM
+		}
+
+		return fs.cfg.Set(ctx, op.Inode, newFlags)
+	}
+
+	return fs.FileSystem.Ioctl(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	if !op.OpenFlags.IsReadOnly() {
+		forbidden := InodeFlagImmutable
+		if !op.OpenFlags.IsAppend() || op.OpenFlags.IsTruncate() {
+			forbidden |= InodeFlagAppendOnly
+		}
+
+		if err := fs.check(ctx, op.Inode, forbidden); err != nil {
+			return err
+		}
+	}
+
+	return fs.FileSystem.OpenFile(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	if err := fs.check(ctx, op.Inode, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.WriteFile(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	if err := fs.check(ctx, op.DstInode, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CopyFileRange(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	forbidden := InodeFlagImmutable
+	if op.Size != nil || op.Mode != nil || op.Uid != nil || op.Gid != nil {
+		forbidden |= InodeFlagAppendOnly
+	}
+
+	changes := op.Size != nil || op.Mode != nil || op.Uid != nil ||
+		op.Gid != nil || op.Atime != nil || op.Mtime != nil || op.Ctime != nil
+	if changes {
+		if err := fs.check(ctx, op.Inode, forbidden); err != nil {
+			return err
+		}
+	}
+
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	forbidden := InodeFlagImmutable
+	if op.Mode&^fuseops.FallocateKeepSize != 0 {
+		forbidden |= InodeFlagAppendOnly
+	}
+
+	if err := fs.check(ctx, op.Inode, forbidden); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Fallocate(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	if err := fs.check(ctx, op.Inode, InodeFlagImmutable|InodeFlagAppendOnly); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SetXattr(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	if err := fs.check(ctx, op.Inode, InodeFlagImmutable|InodeFlagAppendOnly); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.RemoveXattr(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkDir(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkNode(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateFile(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateTmpfile(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateSymlink(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	if err := fs.check(ctx, op.Parent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	if err := fs.check(ctx, op.Target, InodeFlagImmutable|InodeFlagAppendOnly); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateLink(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	if err := fs.checkRemove(ctx, op.Parent, op.Name); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Unlink(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	if err := fs.checkRemove(ctx, op.Parent, op.Name); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.RmDir(ctx, op)
+}
+
+func (fs *InodeFlagsFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	if err := fs.checkRemove(ctx, op.OldParent, op.OldName); err != nil {
+		return err
+	}
+
+	// Entries may be added to an append-only directory, but not replaced.
+	if err := fs.check(ctx, op.NewParent, InodeFlagImmutable); err != nil {
+		return err
+	}
+
+	flags, exists, err := fs.childFlags(ctx, op.NewParent, op.NewName)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		const forbidden = InodeFlagImmutable | InodeFlagAppendOnly
+		if flags&forbidden != 0 {
+			return syscall.EPERM
+		}
+
+		if err := fs.check(ctx, op.NewParent, forbidden); err != nil {
+			return err
+		}
+	}
+
+	return fs.FileSystem.Rename(ctx, op)
+}
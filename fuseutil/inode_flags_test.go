@@ -0,0 +1,147 @@
+package fuseutil
+
+import (
+	"context"
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// A file system in which every name refers to the inode with the same number,
+// and whose modifying ops succeed.
+type flagsFS struct {
+	numberFS
+	forgets int
+}
+
+func (fs *flagsFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	fs.forgets++
+	return nil
+}
+
+func (fs *flagsFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *flagsFS) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	return nil
+}
+
+func (fs *flagsFS) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	return nil
+}
+
+func (fs *flagsFS) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return nil
+}
+
+func (fs *flagsFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	return nil
+}
+
+func TestInodeFlagsFileSystem(t *testing.T) {
+	ctx := context.Background()
+	flags := map[fuseops.InodeID]uint32{}
+	wrapped := &flagsFS{}
+	fs := NewInodeFlagsFileSystem(wrapped, InodeFlagsConfig{
+		Get: func(ctx context.Context, inode fuseops.InodeID) (uint32, error) {
+			return flags[inode], nil
+		},
+		Set: func(ctx context.Context, inode fuseops.InodeID, f uint32) error {
+			flags[inode] = f
+			return nil
+		},
+	})
+
+	setFlags := func(inode fuseops.InodeID, f uint32, uid uint32) error {
+		input := binary.NativeEndian.AppendUint32(nil, f)
+		return fs.Ioctl(ctx, &fuseops.IoctlOp{
+			Inode:     inode,
+			Cmd:       0x40086602,
+			Input:     input,
+			OpContext: fuseops.OpContext{Uid: uid},
+		})
+	}
+
+	// Only root may set the immutable and append-only flags.
+	if err := setFlags(3, InodeFlagImmutable, 1000); err != syscall.EPERM {
+		t.Errorf("SETFLAGS as non-root: got %v, want EPERM", err)
+	}
+
+	if err := setFlags(3, InodeFlagImmutable, 0); err != nil {
+		t.Fatalf("SETFLAGS: %v", err)
+	}
+
+	if err := setFlags(4, InodeFlagAppendOnly, 0); err != nil {
+		t.Fatalf("SETFLAGS: %v", err)
+	}
+
+	getOp := &fuseops.IoctlOp{Inode: 3, Cmd: 0x80086601, OutputSize: 8}
+	if err := fs.Ioctl(ctx, getOp); err != nil {
+		t.Fatalf("GETFLAGS: %v", err)
+	}
+
+	if got := binary.NativeEndian.Uint32(getOp.Output); got != InodeFlagImmutable {
+		t.Errorf("GETFLAGS: got %#x, want %#x", got, InodeFlagImmutable)
+	}
+
+	// Other ioctls go to the wrapped file system.
+	if err := fs.Ioctl(ctx, &fuseops.IoctlOp{Inode: 3, Cmd: 0x5401}); err != syscall.ENOSYS {
+		t.Errorf("other ioctl: got %v, want ENOSYS", err)
+	}
+
+	// Immutable files can't be written or opened for writing.
+	if err := fs.WriteFile(ctx, &fuseops.WriteFileOp{Inode: 3}); err != syscall.EPERM {
+		t.Errorf("WriteFile immutable: got %v, want EPERM", err)
+	}
+
+	wronly := fusekernel.OpenFlags(syscall.O_WRONLY)
+	if err := fs.OpenFile(ctx, &fuseops.OpenFileOp{Inode: 3, OpenFlags: wronly}); err != syscall.EPERM {
+		t.Errorf("OpenFile immutable: got %v, want EPERM", err)
+	}
+
+	if err := fs.OpenFile(ctx, &fuseops.OpenFileOp{Inode: 3}); err != nil {
+		t.Errorf("OpenFile immutable read-only: %v", err)
+	}
+
+	// Append-only files may be opened for writing only in append mode.
+	if err := fs.OpenFile(ctx, &fuseops.OpenFileOp{Inode: 4, OpenFlags: wronly}); err != syscall.EPERM {
+		t.Errorf("OpenFile append-only: got %v, want EPERM", err)
+	}
+
+	appendFlags := wronly | fusekernel.OpenFlags(syscall.O_APPEND)
+	if err := fs.OpenFile(ctx, &fuseops.OpenFileOp{Inode: 4, OpenFlags: appendFlags}); err != nil {
+		t.Errorf("OpenFile append-only with O_APPEND: %v", err)
+	}
+
+	// Neither may be removed or replaced, and the lookups made to find out are
+	// forgotten.
+	if err := fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "abc"}); err != syscall.EPERM {
+		t.Errorf("Unlink immutable: got %v, want EPERM", err)
+	}
+
+	if err := fs.Rename(ctx, &fuseops.RenameOp{OldParent: 1, OldName: "ab", NewParent: 1, NewName: "abcd"}); err != syscall.EPERM {
+		t.Errorf("Rename over append-only: got %v, want EPERM", err)
+	}
+
+	if err := fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "ab"}); err != nil {
+		t.Errorf("Unlink: %v", err)
+	}
+
+	if wrapped.forgets != 4 {
+		t.Errorf("forgets: got %d, want 4", wrapped.forgets)
+	}
+
+	// Entries may be added to an append-only directory, but not to an
+	// immutable one.
+	if err := fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: 4, Name: "x"}); err != nil {
+		t.Errorf("CreateFile in append-only: %v", err)
+	}
+
+	if err := fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: 3, Name: "x"}); err != syscall.EPERM {
+		t.Errorf("CreateFile in immutable: got %v, want EPERM", err)
+	}
+}
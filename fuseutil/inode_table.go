@@ -0,0 +1,247 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// InodeTableConfig configures an InodeTable.
+type InodeTableConfig struct {
+	// The generation number of every ID the table issues. A table issues IDs
+	// afresh each time it is created, so handles issued by an earlier table,
+	// e.g. before the file system was restarted, name different objects by the
+	// same IDs. A different generation makes the kernel reject them as stale
+	// instead. Linux keeps only the low 32 bits. If zero, the low 32 bits of
+	// the time at which the table is created, in nanoseconds.
+	Generation fuseops.GenerationNumber
+
+	// How many IDs the kernel has forgotten to keep resolvable, so that file
+	// handles referring to them can still be opened. Once more have been
+	// forgotten, the least recently forgotten are dropped, and handles to them
+	// become stale. If zero, 65536.
+	Retain int
+}
+
+// InodeTable assigns inode IDs to the objects of a file system's backend,
+// identified by keys of type K, for file systems that may be exported over
+// NFS or opened by file handle. See fuse.MountConfig.EnableExportSupport.
+// Create one with NewInodeTable. It is safe for concurrent use.
+//
+// Keys should identify objects rather than names where the backend allows,
+// e.g. by the backend's own IDs: a handle to a file keyed by its path opens
+// whatever file later takes its place.
+//
+// A file handle outlives the kernel's references to the inode it names, so an
+// ID must stay resolvable after the kernel forgets it, and must not be given
+// to another object while handles to it may be in use. The table never reuses
+// IDs, so every ID it issues has the same generation number, and it keeps the
+// keys of forgotten IDs until they are more than InodeTableConfig.Retain.
+//
+// A file system calls Acquire for each entry it returns to the kernel, e.g.
+// from LookUpInodeOp or ReadDirPlusOp, ID for each entry of a ReadDirOp,
+// Forget for each ForgetInodeOp, and
+// Resolve to find the object for an ID in any op, including the lookups of "."
+// and ".." the kernel sends to open a handle:
+//
+//	func (fs *myFS) LookUpInode(
+//		ctx context.Context,
+//		op *fuseops.LookUpInodeOp) error {
+//		parent, ok := fs.inodes.Resolve(op.Parent)
+//		if !ok {
+//			return fuse.ENOENT
+//		}
+//
+//		key, err := fs.backend.lookUp(parent, op.Name)
+//		if err != nil {
+//			return err
+//		}
+//
+//		op.Entry.Child, op.Entry.Generation = fs.inodes.Acquire(key)
+//		...
+//	}
+type InodeTable[K comparable] struct {
+	cfg InodeTableConfig
+
+	mu sync.Mutex
+
+	// The most recently issued ID.
+	//
+	// GUARDED_BY(mu)
+	last fuseops.InodeID
+
+	// GUARDED_BY(mu)
+	ids map[K]fuseops.InodeID
+
+	// GUARDED_BY(mu)
+	entries map[fuseops.InodeID]*inodeTableEntry[K]
+
+	// IDs with no lookups, least recently forgotten first.
+	//
+	// INVARIANT: Contains exactly the IDs in entries with no lookups.
+	//
+	// GUARDED_BY(mu)
+	forgotten list.List
+}
+
+type inodeTableEntry[K comparable] struct {
+	key K
+
+	// The lookup count the kernel holds.
+	lookups uint64
+
+	// The entry's element in forgotten, if lookups is zero.
+	elem *list.Element
+}
+
+// NewInodeTable creates a table in which the supplied key has ID
+// fuseops.RootInodeID, and is never dropped.
+func NewInodeTable[K comparable](root K, cfg InodeTableConfig) *InodeTable[K] {
+	if cfg.Generation == 0 {
+		cfg.Generation = fuseops.GenerationNumber(uint32(time.Now().UnixNano()))
+	}
+
+	if cfg.Retain == 0 {
+		cfg.Retain = 65536
+	}
+
+	return &InodeTable[K]{
+		cfg:  cfg,
+		last: fuseops.RootInodeID,
+		ids: map[K]fuseops.InodeID{
+			root: fuseops.RootInodeID,
+		},
+		entries: map[fuseops.InodeID]*inodeTableEntry[K]{
+			fuseops.RootInodeID: {key: root, lookups: 1},
+		},
+	}
+}
+
+// Acquire returns the ID of the object with the supplied key, issuing one if
+// it has none, and the generation number to return with it. It counts a
+// lookup of the ID, to be released with Forget.
+//
+// LOCKS_EXCLUDED(t.mu)
+func (t *InodeTable[K]) Acquire(key K) (fuseops.InodeID, fuseops.GenerationNumber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id, e := t.entry(key)
+	if e.elem != nil {
+		t.forgotten.Remove(e.elem)
+		e.elem = nil
+	}
+
+	e.lookups++
+	return id, t.cfg.Generation
+}
+
+// ID returns the ID of the object with the supplied key, issuing one if it has
+// none, without counting a lookup. This is for the entries of a ReadDirOp,
+// which the kernel keeps no reference to, but which must carry the IDs that
+// the objects have in lookups: the kernel lists a directory's parent to find
+// its name when opening a handle to it. A newly issued ID is kept resolvable
+// as though the kernel had forgotten it.
+//
+// LOCKS_EXCLUDED(t.mu)
+func (t *InodeTable[K]) ID(key K) fuseops.InodeID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id, e := t.entry(key)
+	if e.lookups == 0 && e.elem == nil {
+		t.retire(id, e)
+	}
+
+	return id
+}
+
+// Resolve returns the key of the object with the supplied ID, if the table
+// issued the ID and hasn't dropped it.
+//
+// LOCKS_EXCLUDED(t.mu)
+func (t *InodeTable[K]) Resolve(id fuseops.InodeID) (key K, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[id]
+	if !ok {
+		return key, false
+	}
+
+	return e.key, true
+}
+
+// Generation returns the generation number of the IDs the table issues.
+func (t *InodeTable[K]) Generation() fuseops.GenerationNumber {
+	return t.cfg.Generation
+}
+
+// Forget releases n lookups of the supplied ID, as for a ForgetInodeOp. Once
+// none remain, the ID is kept resolvable as described on InodeTable.
+//
+// LOCKS_EXCLUDED(t.mu)
+func (t *InodeTable[K]) Forget(id fuseops.InodeID, n uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[id]
+	if !ok || id == fuseops.RootInodeID || e.lookups == 0 {
+		return
+	}
+
+	if n > e.lookups {
+		n = e.lookups
+	}
+
+	e.lookups -= n
+	if e.lookups != 0 {
+		return
+	}
+
+	t.retire(id, e)
+}
+
+// Find or create the entry for the supplied key.
+//
+// LOCKS_REQUIRED(t.mu)
+func (t *InodeTable[K]) entry(key K) (fuseops.InodeID, *inodeTableEntry[K]) {
+	id, ok := t.ids[key]
+	if !ok {
+		t.last++
+		id = t.last
+		t.ids[key] = id
+		t.entries[id] = &inodeTableEntry[K]{key: key}
+	}
+
+	return id, t.entries[id]
+}
+
+// Add an entry with no lookups to the forgotten list, dropping the least
+// recently forgotten if there are too many.
+//
+// LOCKS_REQUIRED(t.mu)
+func (t *InodeTable[K]) retire(id fuseops.InodeID, e *inodeTableEntry[K]) {
+	e.elem = t.forgotten.PushBack(id)
+	for t.forgotten.Len() > t.cfg.Retain {
+		old := t.forgotten.Remove(t.forgotten.Front()).(fuseops.InodeID)
+		delete(t.ids, t.entries[old].key)
+		delete(t.entries, old)
+	}
+}
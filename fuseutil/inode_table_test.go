@@ -0,0 +1,73 @@
+package fuseutil
+
+import (
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestInodeTable(t *testing.T) {
+	table := NewInodeTable("/", InodeTableConfig{Generation: 17, Retain: 2})
+
+	if key, ok := table.Resolve(fuseops.RootInodeID); !ok || key != "/" {
+		t.Errorf("Resolve root: got (%q, %v)", key, ok)
+	}
+
+	// Keys are given IDs once, and those count lookups.
+	foo, gen := table.Acquire("foo")
+	if foo == fuseops.RootInodeID || gen != 17 {
+		t.Errorf("Acquire: got (%v, %v)", foo, gen)
+	}
+
+	if id, _ := table.Acquire("foo"); id != foo {
+		t.Errorf("Acquire again: got %v, want %v", id, foo)
+	}
+
+	bar, _ := table.Acquire("bar")
+	baz, _ := table.Acquire("baz")
+	if bar == foo || baz == foo || bar == baz {
+		t.Errorf("Duplicate IDs: %v, %v, %v", foo, bar, baz)
+	}
+
+	// Forgotten IDs stay resolvable, up to the configured number.
+	table.Forget(foo, 1)
+	table.Forget(bar, 1)
+	table.Forget(baz, 1)
+	if key, ok := table.Resolve(foo); !ok || key != "foo" {
+		t.Errorf("Resolve foo: got (%q, %v)", key, ok)
+	}
+
+	table.Forget(foo, 1)
+	if _, ok := table.Resolve(bar); ok {
+		t.Errorf("bar still resolvable")
+	}
+
+	for key, id := range map[string]fuseops.InodeID{"foo": foo, "baz": baz} {
+		if _, ok := table.Resolve(id); !ok {
+			t.Errorf("%s not resolvable", key)
+		}
+	}
+
+	// Acquiring a forgotten key keeps its ID, and a dropped one gets a new ID.
+	if id, _ := table.Acquire("baz"); id != baz {
+		t.Errorf("Acquire baz: got %v, want %v", id, baz)
+	}
+
+	if id, _ := table.Acquire("bar"); id == bar {
+		t.Errorf("Acquire bar: got old ID %v", id)
+	}
+
+	// IDs can be issued without lookups, and are then kept as though forgotten.
+	qux := table.ID("qux")
+	if id, _ := table.Acquire("qux"); id != qux {
+		t.Errorf("Acquire qux: got %v, want %v", id, qux)
+	}
+
+	// The root is never dropped.
+	table.Forget(fuseops.RootInodeID, 1)
+	table.Forget(foo, 1)
+	table.Forget(baz, 1)
+	if _, ok := table.Resolve(fuseops.RootInodeID); !ok {
+		t.Errorf("Root dropped")
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// NewIOFS returns an io/fs.FS serving the contents of the supplied file
+// system by calling its methods directly, without mounting it, so that the
+// file system can be tested with testing/fstest.TestFS, or read by code that
+// accepts an fs.FS. Ops are called with the supplied context and a zero
+// OpContext, i.e. as if by root.
+//
+// Paths are resolved with LookUpInode, starting from the root inode, and each
+// lookup is balanced with ForgetInode once the inode is no longer needed, so
+// file systems that count lookups see the same as when mounted. Files are read
+// with OpenFile and ReadFile, and directories listed with OpenDir and
+// ReadDir, looking up each entry to find its fs.FileInfo. Symbolic links are
+// not followed: opening one gives a file whose Stat reports fs.ModeSymlink,
+// and reading from it fails.
+//
+// The file system may be mounted at the same time, since it must already
+// serve concurrent ops.
+func NewIOFS(ctx context.Context, wrapped FileSystem) fs.FS {
+	return &ioFS{
+		ctx: ctx,
+		fs:  wrapped,
+	}
+}
+
+type ioFS struct {
+	ctx context.Context
+	fs  FileSystem
+}
+
+// Release the reference to the inode taken by looking it up.
+func (s *ioFS) forget(inode fuseops.InodeID) {
+	if inode != fuseops.RootInodeID {
+		s.fs.ForgetInode(s.ctx, &fuseops.ForgetInodeOp{Inode: inode, N: 1})
+	}
+}
+
+// Look up the child of the directory with the supplied name, returning its
+// entry, which the caller must forget.
+func (s *ioFS) lookUp(
+	parent fuseops.InodeID,
+	name string) (fuseops.ChildInodeEntry, error) {
+	op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+	if err := s.fs.LookUpInode(s.ctx, op); err != nil {
+		return fuseops.ChildInodeEntry{}, err
+	}
+
+	// A zero child is a negative entry.
+	if op.Entry.Child == 0 {
+		return fuseops.ChildInodeEntry{}, syscall.ENOENT
+	}
+
+	return op.Entry, nil
+}
+
+// Resolve the valid path, returning the inode it refers to and its
+// attributes. The caller must forget the inode.
+func (s *ioFS) walk(name string) (fuseops.InodeID, fuseops.InodeAttributes, error) {
+	op := &fuseops.GetInodeAttributesOp{Inode: fuseops.RootInodeID}
+	if err := s.fs.GetInodeAttributes(s.ctx, op); err != nil {
+		return 0, fuseops.InodeAttributes{}, err
+	}
+
+	inode, attrs := op.Inode, op.Attributes
+	if name == "." {
+		return inode, attrs, nil
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if !attrs.Mode.IsDir() {
+			s.forget(inode)
+			return 0, fuseops.InodeAttributes{}, syscall.ENOTDIR
+		}
+
+		entry, err := s.lookUp(inode, component)
+		s.forget(inode)
+		if err != nil {
+			return 0, fuseops.InodeAttributes{}, err
+		}
+
+		inode, attrs = entry.Child, entry.Attributes
+	}
+
+	return inode, attrs, nil
+}
+
+func (s *ioFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	inode, attrs, err := s.walk(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	f := &ioFile{
+		s:     s,
+		path:  name,
+		inode: inode,
+		info:  ioFileInfo{name: path.Base(name), attrs: attrs},
+	}
+
+	switch {
+	case attrs.Mode.IsDir():
+		op := &fuseops.OpenDirOp{Inode: inode}
+		err = s.fs.OpenDir(s.ctx, op)
+		f.handle = op.Handle
+
+	case attrs.Mode.IsRegular():
+		op := &fuseops.OpenFileOp{Inode: inode}
+		err = s.fs.OpenFile(s.ctx, op)
+		f.handle = op.Handle
+
+	default:
+		// Other files can only be stat'ed.
+		f.unopened = true
+	}
+
+	if err != nil {
+		s.forget(inode)
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return f, nil
+}
+
+// An open file or directory, which implements fs.ReadDirFile, io.ReaderAt
+// and io.Seeker.
+type ioFile struct {
+	s        *ioFS
+	path     string
+	inode    fuseops.InodeID
+	handle   fuseops.HandleID
+	info     ioFileInfo
+	unopened bool
+	closed   bool
+
+	// The offset of the next Read.
+	offset int64
+
+	// For directories, the offset from which to read more entries, those read
+	// but not yet returned, and whether the end has been reached.
+	dirOffset fuseops.DirOffset
+	entries   []fs.DirEntry
+	dirEOF    bool
+}
+
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	return f.info, nil
+}
+
+// Read into p at the offset, returning the number of bytes read, which is
+// zero only at the end of the file.
+func (f *ioFile) readAt(p []byte, off int64) (int, error) {
+	op := &fuseops.ReadFileOp{
+		Inode:  f.inode,
+		Handle: f.handle,
+		Offset: off,
+		Size:   int64(len(p)),
+		Dst:    p,
+	}
+
+	if err := f.s.fs.ReadFile(f.s.ctx, op); err != nil {
+		return 0, err
+	}
+
+	n := op.BytesRead
+	if op.Data != nil {
+		n = 0
+		for _, b := range op.Data {
+			n += copy(p[n:], b)
+		}
+	}
+
+	if op.Callback != nil {
+		op.Callback()
+	}
+
+	return min(n, len(p)), nil
+}
+
+func (f *ioFile) check(op string) error {
+	switch {
+	case f.closed:
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrClosed}
+
+	case f.info.IsDir():
+		return &fs.PathError{Op: op, Path: f.path, Err: syscall.EISDIR}
+
+	case f.unopened:
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	return nil
+}
+
+func (f *ioFile) Read(p []byte) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := f.readAt(p, f.offset)
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: err}
+	}
+
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *ioFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	// Unlike Read, ReadAt must fill p unless it fails.
+	var total int
+	for total < len(p) {
+		n, err := f.readAt(p[total:], off+int64(total))
+		if err != nil {
+			return total, &fs.PathError{Op: "read", Path: f.path, Err: err}
+		}
+
+		if n == 0 {
+			return total, io.EOF
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (f *ioFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.check("seek"); err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+
+	case io.SeekEnd:
+		op := &fuseops.GetInodeAttributesOp{Inode: f.inode}
+		if err := f.s.fs.GetInodeAttributes(f.s.ctx, op); err != nil {
+			return 0, &fs.PathError{Op: "seek", Path: f.path, Err: err}
+		}
+
+		offset += int64(op.Attributes.Size)
+
+	default:
+		offset = -1
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Read a buffer of entries from the directory into f.entries.
+func (f *ioFile) readDirBuffer() error {
+	op := &fuseops.ReadDirOp{
+		Inode:  f.inode,
+		Handle: f.handle,
+		Offset: f.dirOffset,
+		Dst:    make([]byte, 4096),
+	}
+
+	if err := f.s.fs.ReadDir(f.s.ctx, op); err != nil {
+		return err
+	}
+
+	if op.BytesRead == 0 {
+		f.dirEOF = true
+		return nil
+	}
+
+	dirents := appendDirents(nil, op.Dst[:op.BytesRead])
+	if len(dirents) == 0 {
+		return errors.New("ReadDir returned no whole entries")
+	}
+
+	for _, d := range dirents {
+		f.dirOffset = d.Offset
+		if d.Name == "." || d.Name == ".." {
+			continue
+		}
+
+		entry, err := f.s.lookUp(f.inode, d.Name)
+		if errors.Is(err, syscall.ENOENT) {
+			// Removed since it was listed.
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		f.s.forget(entry.Child)
+		f.entries = append(f.entries, ioFileInfo{name: d.Name, attrs: entry.Attributes})
+	}
+
+	return nil
+}
+
+func (f *ioFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	switch {
+	case f.closed:
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: fs.ErrClosed}
+
+	case !f.info.IsDir():
+		return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: syscall.ENOTDIR}
+	}
+
+	for !f.dirEOF && (n <= 0 || len(f.entries) < n) {
+		if err := f.readDirBuffer(); err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.path, Err: err}
+		}
+	}
+
+	count := len(f.entries)
+	if n > 0 {
+		count = min(n, count)
+	}
+
+	entries := f.entries[:count:count]
+	f.entries = f.entries[count:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+
+	return entries, nil
+}
+
+func (f *ioFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+	defer f.s.forget(f.inode)
+
+	var err error
+	switch {
+	case f.unopened:
+	case f.info.IsDir():
+		err = f.s.fs.ReleaseDirHandle(f.s.ctx, &fuseops.ReleaseDirHandleOp{Handle: f.handle})
+
+	default:
+		err = f.s.fs.ReleaseFileHandle(f.s.ctx, &fuseops.ReleaseFileHandleOp{Handle: f.handle})
+	}
+
+	// File systems needn't implement releasing handles.
+	if err != nil && !errors.Is(err, syscall.ENOSYS) {
+		return &fs.PathError{Op: "close", Path: f.path, Err: err}
+	}
+
+	return nil
+}
+
+// The fs.FileInfo and fs.DirEntry for an inode.
+type ioFileInfo struct {
+	name  string
+	attrs fuseops.InodeAttributes
+}
+
+func (i ioFileInfo) Name() string               { return i.name }
+func (i ioFileInfo) Size() int64                { return int64(i.attrs.Size) }
+func (i ioFileInfo) Mode() fs.FileMode          { return i.attrs.Mode }
+func (i ioFileInfo) ModTime() time.Time         { return i.attrs.Mtime }
+func (i ioFileInfo) IsDir() bool                { return i.attrs.Mode.IsDir() }
+func (i ioFileInfo) Sys() any                   { return &i.attrs }
+func (i ioFileInfo) Type() fs.FileMode          { return i.attrs.Mode.Type() }
+func (i ioFileInfo) Info() (fs.FileInfo, error) { return i, nil }
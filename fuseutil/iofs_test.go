@@ -0,0 +1,148 @@
+package fuseutil
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A read-only file system serving a fixed tree, which counts lookups.
+type fixedTreeFS struct {
+	NotImplementedFileSystem
+
+	// Indexed by inode ID.
+	inodes []fixedTreeInode
+
+	mu      sync.Mutex
+	lookups map[fuseops.InodeID]int // GUARDED_BY(mu)
+}
+
+type fixedTreeInode struct {
+	contents string
+	children []fuseops.InodeID // nil for files
+	name     string
+}
+
+func newFixedTreeFS() *fixedTreeFS {
+	return &fixedTreeFS{
+		inodes: []fixedTreeInode{
+			{},
+			fuseops.RootInodeID: {children: []fuseops.InodeID{2, 3, 5}},
+			2:                   {name: "a.txt", contents: "taco"},
+			3:                   {name: "dir", children: []fuseops.InodeID{4}},
+			4:                   {name: "b.txt", contents: "burrito"},
+			5:                   {name: "empty", children: []fuseops.InodeID{}},
+		},
+		lookups: make(map[fuseops.InodeID]int),
+	}
+}
+
+func (fs *fixedTreeFS) attrs(id fuseops.InodeID) fuseops.InodeAttributes {
+	in := fs.inodes[id]
+	if in.children != nil {
+		return fuseops.InodeAttributes{Nlink: 2, Mode: os.ModeDir | 0755}
+	}
+
+	return fuseops.InodeAttributes{Nlink: 1, Mode: 0644, Size: uint64(len(in.contents))}
+}
+
+func (fs *fixedTreeFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	for _, child := range fs.inodes[op.Parent].children {
+		if fs.inodes[child].name == op.Name {
+			op.Entry.Child = child
+			op.Entry.Attributes = fs.attrs(child)
+
+			fs.mu.Lock()
+			fs.lookups[child]++
+			fs.mu.Unlock()
+			return nil
+		}
+	}
+
+	return syscall.ENOENT
+}
+
+func (fs *fixedTreeFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.lookups[op.Inode] -= int(op.N)
+	if fs.lookups[op.Inode] == 0 {
+		delete(fs.lookups, op.Inode)
+	}
+
+	return nil
+}
+
+func (fs *fixedTreeFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attrs(op.Inode)
+	return nil
+}
+
+func (fs *fixedTreeFS) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	return nil
+}
+
+func (fs *fixedTreeFS) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	children := fs.inodes[op.Inode].children
+	for i := int(op.Offset); i < len(children); i++ {
+		n := WriteDirent(op.Dst[op.BytesRead:], Dirent{
+			Offset: fuseops.DirOffset(i + 1),
+			Inode:  children[i],
+			Name:   fs.inodes[children[i]].name,
+		})
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *fixedTreeFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *fixedTreeFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	contents := fs.inodes[op.Inode].contents
+	if op.Offset < int64(len(contents)) {
+		op.BytesRead = copy(op.Dst, contents[op.Offset:])
+	}
+
+	return nil
+}
+
+func TestIOFS(t *testing.T) {
+	tree := newFixedTreeFS()
+	fsys := NewIOFS(context.Background(), tree)
+
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt", "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := fs.ReadFile(fsys, "dir/b.txt")
+	if err != nil || string(contents) != "burrito" {
+		t.Errorf("ReadFile: %q, %v", contents, err)
+	}
+
+	if _, err := fs.Stat(fsys, "a.txt/b.txt"); err == nil {
+		t.Errorf("Stat through a file: got nil error")
+	}
+
+	if _, err := fs.Stat(fsys, "dir/taco"); !os.IsNotExist(err) {
+		t.Errorf("Stat missing file: got %v, want ErrNotExist", err)
+	}
+
+	// Every lookup has been forgotten.
+	if len(tree.lookups) != 0 {
+		t.Errorf("lookups not forgotten: %v", tree.lookups)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// MetadataCacheConfig configures a MetadataCacheFileSystem.
+type MetadataCacheConfig struct {
+	// How long a successful result is cached. Must be positive.
+	TTL time.Duration
+
+	// How long a GetXattr result of ENOATTR is cached. Caching these is
+	// worthwhile because the kernel looks up attributes such as
+	// security.capability that usually don't exist, e.g. before every write.
+	// If zero, errors are never cached.
+	NegativeTTL time.Duration
+
+	// The clock used to expire results. If nil, the system clock is used.
+	Clock timeutil.Clock
+}
+
+// MetadataCacheFileSystem is a FileSystem that caches the results of
+// GetXattr, ListXattr and ReadSymlink from a wrapped file system, for which
+// these are expensive, e.g. because they are remote calls. Create one with
+// NewMetadataCacheFileSystem.
+//
+// Results are cached per inode, op, and attribute name, and expire as
+// configured. The cached results for an inode are purged when the inode's
+// extended attributes are changed through the file system, and when the
+// kernel forgets the inode. Changes made by other means, e.g. by other clients
+// of a network file system, must be reported with Purge or PurgeAndInvalidate.
+type MetadataCacheFileSystem struct {
+	FileSystem
+	cfg MetadataCacheConfig
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	entries map[metadataKey]*metadataEntry
+
+	// Incremented by each purge, so that results fetched concurrently with a
+	// purge aren't cached.
+	//
+	// GUARDED_BY(mu)
+	generation uint64
+}
+
+type metadataOp int
+
+const (
+	metadataGetXattr metadataOp = iota
+	metadataListXattr
+	metadataReadSymlink
+)
+
+type metadataKey struct {
+	inode fuseops.InodeID
+	op    metadataOp
+	name  string
+}
+
+type metadataEntry struct {
+	expiration time.Time
+
+	// The error, or the size of the value, which is present if haveValue is
+	// set. A GetXattr or ListXattr with an empty buffer only returns the size.
+	err       error
+	size      int
+	value     []byte
+	haveValue bool
+}
+
+// NewMetadataCacheFileSystem wraps the supplied file system, caching results
+// as described on MetadataCacheFileSystem.
+func NewMetadataCacheFileSystem(
+	wrapped FileSystem,
+	cfg MetadataCacheConfig) *MetadataCacheFileSystem {
+	return &MetadataCacheFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		entries:    make(map[metadataKey]*metadataEntry),
+	}
+}
+
+// Purge discards the cached results for the supplied inode.
+func (fs *MetadataCacheFileSystem) Purge(inode fuseops.InodeID) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.purge(inode)
+}
+
+// PurgeAndInvalidate discards the cached results for the supplied inode, and
+// then uses the notifier to invalidate the kernel's cache for it, which
+// includes any cached symlink target. It returns the error from
+// Notifier.InvalidateInode.
+func (fs *MetadataCacheFileSystem) PurgeAndInvalidate(
+	n *fuse.Notifier,
+	inode fuseops.InodeID) error {
+	fs.Purge(inode)
+	return n.InvalidateInode(inode, 0, 0)
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *MetadataCacheFileSystem) purge(inode fuseops.InodeID) {
+	fs.generation++
+	for k := range fs.entries {
+		if k.inode == inode {
+			delete(fs.entries, k)
+		}
+	}
+}
+
+func (fs *MetadataCacheFileSystem) now() time.Time {
+	if fs.cfg.Clock == nil {
+		return time.Now()
+	}
+
+	return fs.cfg.Clock.Now()
+}
+
+// Return the unexpired entry for the key, if any, along with the current
+// generation.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *MetadataCacheFileSystem) lookUp(k metadataKey) (*metadataEntry, uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	e := fs.entries[k]
+	if e != nil && !fs.now().Before(e.expiration) {
+		delete(fs.entries, k)
+		e = nil
+	}
+
+	return e, fs.generation
+}
+
+// Cache the result of an op that ran starting at the supplied generation,
+// replacing any existing entry.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *MetadataCacheFileSystem) store(
+	k metadataKey,
+	generation uint64,
+	err error,
+	size int,
+	value []byte,
+	haveValue bool) {
+	ttl := fs.cfg.TTL
+	if err != nil {
+		if !errors.Is(err, fuse.ENOATTR) || fs.cfg.NegativeTTL == 0 {
+			return
+		}
+
+		ttl = fs.cfg.NegativeTTL
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.generation != generation {
+		return
+	}
+
+	e := &metadataEntry{
+		expiration: fs.now().Add(ttl),
+		err:        err,
+		size:       size,
+		haveValue:  haveValue,
+	}
+
+	if haveValue {
+		e.value = append([]byte(nil), value...)
+	}
+
+	fs.entries[k] = e
+}
+
+// Serve a GetXattr or ListXattr op from the cache or the wrapped file system.
+func (fs *MetadataCacheFileSystem) xattr(
+	k metadataKey,
+	dst []byte,
+	bytesRead *int,
+	wrapped func() error) error {
+	e, generation := fs.lookUp(k)
+	if e != nil && (e.err != nil || len(dst) == 0 || e.haveValue) {
+		switch {
+		case e.err != nil:
+			return e.err
+
+		case len(dst) == 0:
+			*bytesRead = e.size
+
+		case len(dst) < e.size:
+			*bytesRead = e.size
+			return syscall.ERANGE
+
+		default:
+			*bytesRead = copy(dst, e.value)
+		}
+
+		return nil
+	}
+
+	err := wrapped()
+	if err == nil && len(dst) != 0 {
+		fs.store(k, generation, nil, *bytesRead, dst[:*bytesRead], true)
+	} else if !errors.Is(err, syscall.ERANGE) {
+		fs.store(k, generation, err, *bytesRead, nil, false)
+	}
+
+	return err
+}
+
+func (fs *MetadataCacheFileSystem) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp) error {
+	k := metadataKey{inode: op.Inode, op: metadataGetXattr, name: op.Name}
+	return fs.xattr(k, op.Dst, &op.BytesRead, func() error {
+		return fs.FileSystem.GetXattr(ctx, op)
+	})
+}
+
+func (fs *MetadataCacheFileSystem) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp) error {
+	k := metadataKey{inode: op.Inode, op: metadataListXattr}
+	return fs.xattr(k, op.Dst, &op.BytesRead, func() error {
+		return fs.FileSystem.ListXattr(ctx, op)
+	})
+}
+
+func (fs *MetadataCacheFileSystem) ReadSymlink(
+	ctx context.Context,
+	op *fuseops.ReadSymlinkOp) error {
+	k := metadataKey{inode: op.Inode, op: metadataReadSymlink}
+	e, generation := fs.lookUp(k)
+	if e != nil {
+		op.Target = string(e.value)
+		return nil
+	}
+
+	err := fs.FileSystem.ReadSymlink(ctx, op)
+	if err == nil {
+		fs.store(k, generation, nil, len(op.Target), []byte(op.Target), true)
+	}
+
+	return err
+}
+
+func (fs *MetadataCacheFileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	// Purge after the change, so that results fetched concurrently aren't
+	// cached either.
+	defer fs.Purge(op.Inode)
+	return fs.FileSystem.SetXattr(ctx, op)
+}
+
+func (fs *MetadataCacheFileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	defer fs.Purge(op.Inode)
+	return fs.FileSystem.RemoveXattr(ctx, op)
+}
+
+func (fs *MetadataCacheFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	// The wrapped file system may reuse the ID of an inode it has forgotten.
+	defer fs.Purge(op.Inode)
+	return fs.FileSystem.ForgetInode(ctx, op)
+}
+
+func (fs *MetadataCacheFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	defer func() {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		for _, e := range op.Entries {
+			fs.purge(e.Inode)
+		}
+	}()
+
+	return fs.FileSystem.BatchForget(ctx, op)
+}
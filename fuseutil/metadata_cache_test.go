@@ -0,0 +1,172 @@
+package fuseutil
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// A file system with extended attributes and symlinks that counts calls.
+type xattrFS struct {
+	NotImplementedFileSystem
+	xattrs map[string]string
+	calls  int
+}
+
+func (fs *xattrFS) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) error {
+	fs.calls++
+	v, ok := fs.xattrs[op.Name]
+	if !ok {
+		return fuse.ENOATTR
+	}
+
+	op.BytesRead = len(v)
+	if len(op.Dst) == 0 {
+		return nil
+	}
+
+	if len(op.Dst) < len(v) {
+		return syscall.ERANGE
+	}
+
+	copy(op.Dst, v)
+	return nil
+}
+
+func (fs *xattrFS) SetXattr(ctx context.Context, op *fuseops.SetXattrOp) error {
+	fs.xattrs[op.Name] = string(op.Value)
+	return nil
+}
+
+func (fs *xattrFS) ReadSymlink(ctx context.Context, op *fuseops.ReadSymlinkOp) error {
+	fs.calls++
+	op.Target = fs.xattrs["target"]
+	return nil
+}
+
+func TestMetadataCache(t *testing.T) {
+	ctx := context.Background()
+	var clock timeutil.SimulatedClock
+	clock.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	wrapped := &xattrFS{xattrs: map[string]string{"user.taco": "burrito"}}
+	fs := NewMetadataCacheFileSystem(wrapped, MetadataCacheConfig{
+		TTL:         time.Minute,
+		NegativeTTL: time.Second,
+		Clock:       &clock,
+	})
+
+	getXattr := func(name string, size int) (string, error) {
+		t.Helper()
+		op := &fuseops.GetXattrOp{Inode: 2, Name: name, Dst: make([]byte, size)}
+		if err := fs.GetXattr(ctx, op); err != nil {
+			return "", err
+		}
+
+		if size == 0 {
+			return strconv.Itoa(op.BytesRead), nil
+		}
+
+		return string(op.Dst[:op.BytesRead]), nil
+	}
+
+	expectCalls := func(want int) {
+		t.Helper()
+		if wrapped.calls != want {
+			t.Errorf("calls: got %d, want %d", wrapped.calls, want)
+		}
+	}
+
+	// Querying the size and then the value calls the file system twice, as
+	// the first result doesn't include the value.
+	if v, err := getXattr("user.taco", 0); v != "7" || err != nil {
+		t.Errorf("size query: %q, %v", v, err)
+	}
+
+	if v, err := getXattr("user.taco", 64); v != "burrito" || err != nil {
+		t.Errorf("GetXattr: %q, %v", v, err)
+	}
+
+	expectCalls(2)
+
+	// Now both are cached, including ERANGE for a short buffer.
+	if v, err := getXattr("user.taco", 0); v != "7" || err != nil {
+		t.Errorf("size query: %q, %v", v, err)
+	}
+
+	if _, err := getXattr("user.taco", 3); err != syscall.ERANGE {
+		t.Errorf("short buffer: got %v, want ERANGE", err)
+	}
+
+	if v, err := getXattr("user.taco", 64); v != "burrito" || err != nil {
+		t.Errorf("GetXattr: %q, %v", v, err)
+	}
+
+	expectCalls(2)
+
+	// Missing attributes are cached for the negative TTL.
+	for i := 0; i < 2; i++ {
+		if _, err := getXattr("security.capability", 64); err != fuse.ENOATTR {
+			t.Errorf("missing attribute: got %v, want ENOATTR", err)
+		}
+	}
+
+	expectCalls(3)
+
+	clock.AdvanceTime(time.Second)
+	getXattr("security.capability", 64)
+	expectCalls(4)
+
+	// Setting an attribute through the file system purges the cache.
+	err := fs.SetXattr(ctx, &fuseops.SetXattrOp{
+		Inode: 2,
+		Name:  "user.taco",
+		Value: []byte("enchilada"),
+	})
+
+	if err != nil {
+		t.Fatalf("SetXattr: %v", err)
+	}
+
+	if v, err := getXattr("user.taco", 64); v != "enchilada" || err != nil {
+		t.Errorf("GetXattr after SetXattr: %q, %v", v, err)
+	}
+
+	expectCalls(5)
+
+	// Symlink targets are cached until they expire or are purged.
+	wrapped.xattrs["target"] = "foo"
+	readSymlink := func() string {
+		op := &fuseops.ReadSymlinkOp{Inode: 3}
+		if err := fs.ReadSymlink(ctx, op); err != nil {
+			t.Fatalf("ReadSymlink: %v", err)
+		}
+
+		return op.Target
+	}
+
+	readSymlink()
+	wrapped.xattrs["target"] = "bar"
+	if target := readSymlink(); target != "foo" {
+		t.Errorf("cached target: got %q, want foo", target)
+	}
+
+	fs.Purge(3)
+	if target := readSymlink(); target != "bar" {
+		t.Errorf("target after Purge: got %q, want bar", target)
+	}
+
+	wrapped.xattrs["target"] = "baz"
+	clock.AdvanceTime(time.Minute)
+	if target := readSymlink(); target != "baz" {
+		t.Errorf("target after expiry: got %q, want baz", target)
+	}
+
+	expectCalls(8)
+}
@@ -0,0 +1,213 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The flags of SetXattrOp. See setxattr(2).
+const (
+	xattrCreate  = 0x1
+	xattrReplace = 0x2
+)
+
+// MetadataXattrConfig configures a MetadataXattrFileSystem.
+type MetadataXattrConfig struct {
+	// The prefix of the names of the extended attributes holding metadata. If
+	// empty, "user." is used.
+	Prefix string
+
+	// Return the metadata of the inode, keyed by attribute name without the
+	// prefix, e.g. the custom headers of an object or the owner recorded in an
+	// archive. Must be non-nil.
+	Get func(ctx context.Context, inode fuseops.InodeID) (map[string][]byte, error)
+
+	// Set or remove an item of metadata, named without the prefix. If either is
+	// nil, the corresponding op fails with EPERM for attributes with the
+	// prefix.
+	Set    func(ctx context.Context, inode fuseops.InodeID, name string, value []byte) error
+	Remove func(ctx context.Context, inode fuseops.InodeID, name string) error
+}
+
+// MetadataXattrFileSystem is a FileSystem that exposes metadata from the
+// backend of the file system it wraps as extended attributes, so that each
+// file system needn't implement GetXattr, ListXattr, SetXattr, and RemoveXattr
+// itself to do so. Create one with NewMetadataXattrFileSystem.
+//
+// Attributes whose names have the configured prefix are served from the
+// metadata, and all others are passed on to the wrapped file system. Its
+// attributes with the prefix, if any, are hidden. ListXattr lists both.
+type MetadataXattrFileSystem struct {
+	FileSystem
+	cfg MetadataXattrConfig
+}
+
+// NewMetadataXattrFileSystem wraps the supplied file system, exposing metadata
+// as described on MetadataXattrFileSystem.
+func NewMetadataXattrFileSystem(
+	wrapped FileSystem,
+	cfg MetadataXattrConfig) *MetadataXattrFileSystem {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "user."
+	}
+
+	return &MetadataXattrFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Copy the value into dst, as for a getxattr(2) or listxattr(2) with a buffer
+// of that size, returning the number of bytes in the value.
+func copyXattr(dst []byte, value []byte) (int, error) {
+	if len(dst) == 0 {
+		return len(value), nil
+	}
+
+	if len(dst) < len(value) {
+		return 0, syscall.ERANGE
+	}
+
+	return copy(dst, value), nil
+}
+
+func (fs *MetadataXattrFileSystem) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp) error {
+	name, ok := strings.CutPrefix(op.Name, fs.cfg.Prefix)
+	if !ok {
+		return fs.FileSystem.GetXattr(ctx, op)
+	}
+
+	md, err := fs.cfg.Get(ctx, op.Inode)
+	if err != nil {
+		return err
+	}
+
+	value, ok := md[name]
+	if !ok {
+		return fuse.ENOATTR
+	}
+
+	op.BytesRead, err = copyXattr(op.Dst, value)
+	return err
+}
+
+func (fs *MetadataXattrFileSystem) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp) error {
+	// Find the size of the wrapped file system's list, then fetch it.
+	wrappedOp := &fuseops.ListXattrOp{
+		Inode:     op.Inode,
+		OpContext: op.OpContext,
+	}
+
+	var list []byte
+	err := fs.FileSystem.ListXattr(ctx, wrappedOp)
+	if err == nil && wrappedOp.BytesRead > 0 {
+		wrappedOp.Dst = make([]byte, wrappedOp.BytesRead)
+		wrappedOp.BytesRead = 0
+		err = fs.FileSystem.ListXattr(ctx, wrappedOp)
+		list = wrappedOp.Dst[:wrappedOp.BytesRead]
+	}
+
+	// A file system without extended attributes of its own has none to list.
+	if err != nil &&
+		!errors.Is(err, syscall.ENOSYS) &&
+		!errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+
+	// Keep the wrapped file system's names without the prefix, and add those
+	// of the metadata.
+	var names []byte
+	for _, name := range strings.Split(string(list), "\x00") {
+		if name != "" && !strings.HasPrefix(name, fs.cfg.Prefix) {
+			names = append(append(names, name...), 0)
+		}
+	}
+
+	md, err := fs.cfg.Get(ctx, op.Inode)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		names = append(append(append(names, fs.cfg.Prefix...), k...), 0)
+	}
+
+	op.BytesRead, err = copyXattr(op.Dst, names)
+	return err
+}
+
+func (fs *MetadataXattrFileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	name, ok := strings.CutPrefix(op.Name, fs.cfg.Prefix)
+	if !ok {
+		return fs.FileSystem.SetXattr(ctx, op)
+	}
+
+	if fs.cfg.Set == nil {
+		return syscall.EPERM
+	}
+
+	if op.Flags&(xattrCreate|xattrReplace) != 0 {
+		md, err := fs.cfg.Get(ctx, op.Inode)
+		if err != nil {
+			return err
+		}
+
+		_, exists := md[name]
+		if exists && op.Flags&xattrCreate != 0 {
+			return syscall.EEXIST
+		}
+
+		if !exists && op.Flags&xattrReplace != 0 {
+			return fuse.ENOATTR
+		}
+	}
+
+	return fs.cfg.Set(ctx, op.Inode, name, op.Value)
+}
+
+func (fs *MetadataXattrFileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	name, ok := strings.CutPrefix(op.Name, fs.cfg.Prefix)
+	if !ok {
+		return fs.FileSystem.RemoveXattr(ctx, op)
+	}
+
+	if fs.cfg.Remove == nil {
+		return syscall.EPERM
+	}
+
+	return fs.cfg.Remove(ctx, op.Inode, name)
+}
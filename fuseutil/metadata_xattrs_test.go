@@ -0,0 +1,89 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system with a single extended attribute of its own.
+type listXattrFS struct {
+	NotImplementedFileSystem
+}
+
+func (fs *listXattrFS) ListXattr(ctx context.Context, op *fuseops.ListXattrOp) error {
+	var err error
+	op.BytesRead, err = copyXattr(op.Dst, []byte("security.selinux\x00user.archive.hidden\x00"))
+	return err
+}
+
+func (fs *listXattrFS) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) error {
+	return syscall.EIO
+}
+
+func TestMetadataXattrs(t *testing.T) {
+	ctx := context.Background()
+	md := map[string][]byte{"uid": []byte("1000")}
+	fs := NewMetadataXattrFileSystem(&listXattrFS{}, MetadataXattrConfig{
+		Prefix: "user.archive.",
+		Get: func(ctx context.Context, inode fuseops.InodeID) (map[string][]byte, error) {
+			return md, nil
+		},
+		Set: func(ctx context.Context, inode fuseops.InodeID, name string, value []byte) error {
+			md[name] = value
+			return nil
+		},
+	})
+
+	get := &fuseops.GetXattrOp{Inode: 2, Name: "user.archive.uid", Dst: make([]byte, 16)}
+	if err := fs.GetXattr(ctx, get); err != nil || string(get.Dst[:get.BytesRead]) != "1000" {
+		t.Errorf("GetXattr: %q, %v", get.Dst[:get.BytesRead], err)
+	}
+
+	get = &fuseops.GetXattrOp{Inode: 2, Name: "user.archive.gid"}
+	if err := fs.GetXattr(ctx, get); err != fuse.ENOATTR {
+		t.Errorf("GetXattr(gid): got %v, want ENOATTR", err)
+	}
+
+	// Other attributes are passed through.
+	get = &fuseops.GetXattrOp{Inode: 2, Name: "security.selinux"}
+	if err := fs.GetXattr(ctx, get); err != syscall.EIO {
+		t.Errorf("GetXattr(security.selinux): got %v, want EIO", err)
+	}
+
+	// Setting respects XATTR_CREATE.
+	set := &fuseops.SetXattrOp{Inode: 2, Name: "user.archive.uid", Value: []byte("0"), Flags: xattrCreate}
+	if err := fs.SetXattr(ctx, set); err != syscall.EEXIST {
+		t.Errorf("SetXattr: got %v, want EEXIST", err)
+	}
+
+	set = &fuseops.SetXattrOp{Inode: 2, Name: "user.archive.gid", Value: []byte("100")}
+	if err := fs.SetXattr(ctx, set); err != nil {
+		t.Errorf("SetXattr: %v", err)
+	}
+
+	// Removing isn't supported.
+	if err := fs.RemoveXattr(ctx, &fuseops.RemoveXattrOp{Inode: 2, Name: "user.archive.uid"}); err != syscall.EPERM {
+		t.Errorf("RemoveXattr: got %v, want EPERM", err)
+	}
+
+	// Listing merges the names, first finding the size.
+	want := "security.selinux\x00user.archive.gid\x00user.archive.uid\x00"
+	list := &fuseops.ListXattrOp{Inode: 2}
+	if err := fs.ListXattr(ctx, list); err != nil || list.BytesRead != len(want) {
+		t.Errorf("ListXattr size: %d, %v", list.BytesRead, err)
+	}
+
+	list = &fuseops.ListXattrOp{Inode: 2, Dst: make([]byte, 64)}
+	if err := fs.ListXattr(ctx, list); err != nil || string(list.Dst[:list.BytesRead]) != want {
+		t.Errorf("ListXattr: %q, %v", list.Dst[:list.BytesRead], err)
+	}
+
+	list = &fuseops.ListXattrOp{Inode: 2, Dst: make([]byte, 8)}
+	if err := fs.ListXattr(ctx, list); err != syscall.ERANGE {
+		t.Errorf("ListXattr with a small buffer: got %v, want ERANGE", err)
+	}
+}
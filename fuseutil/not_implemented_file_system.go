@@ -216,5 +216,83 @@ func (fs *NotImplementedFileSystem) SyncFS(
 	return fuse.ENOSYS
 }
 
+func (fs *NotImplementedFileSystem) Ioctl(
+	ctx context.Context,
+	op *fuseops.IoctlOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) Lseek(
+	ctx context.Context,
+	op *fuseops.LseekOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) Poll(
+	ctx context.Context,
+	op *fuseops.PollOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) GetLk(
+	ctx context.Context,
+	op *fuseops.GetLkOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) SetLk(
+	ctx context.Context,
+	op *fuseops.SetLkOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) SetLkW(
+	ctx context.Context,
+	op *fuseops.SetLkWOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) Statx(
+	ctx context.Context,
+	op *fuseops.StatxOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) Access(
+	ctx context.Context,
+	op *fuseops.AccessOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) Bmap(
+	ctx context.Context,
+	op *fuseops.BmapOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) SetupMapping(
+	ctx context.Context,
+	op *fuseops.SetupMappingOp) error {
+	return fuse.ENOSYS
+}
+
+func (fs *NotImplementedFileSystem) RemoveMapping(
+	ctx context.Context,
+	op *fuseops.RemoveMappingOp) error {
+	return fuse.ENOSYS
+}
+
 func (fs *NotImplementedFileSystem) Destroy() {
 }
@@ -0,0 +1,297 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// EntryOp is the kind of an EntryChange.
+type EntryOp int
+
+const (
+	// A name was linked to Child in Parent, by MkDir, MkNode, CreateFile,
+	// CreateSymlink, or CreateLink.
+	EntryCreate EntryOp = iota
+
+	// A name was removed from Parent, by Unlink or RmDir.
+	EntryRemove
+
+	// A name was moved from Parent to NewParent, replacing any existing entry
+	// there.
+	EntryRename
+)
+
+// EntryChange describes a change to a directory entry made by the file system
+// wrapped by an OrderedEntryFileSystem.
+type EntryChange struct {
+	Op     EntryOp
+	Parent fuseops.InodeID
+	Name   string
+
+	// For EntryCreate, the inode the name now refers to.
+	Child fuseops.InodeID
+
+	// For EntryRename, the destination, and the renameat2(2) flags, e.g.
+	// fuseops.RenameExchange if the two names were swapped.
+	NewParent fuseops.InodeID
+	NewName   string
+	Flags     fuseops.RenameFlags
+}
+
+// Return true if the change modifies the entries of the directory, or links
+// the inode.
+func (c *EntryChange) touches(inode fuseops.InodeID) bool {
+	switch c.Op {
+	case EntryCreate:
+		return c.Parent == inode || c.Child == inode
+
+	case EntryRename:
+		return c.Parent == inode || c.NewParent == inode
+	}
+
+	return c.Parent == inode
+}
+
+// OrderedEntryFileSystem is a FileSystem that gives fsync the ordering
+// guarantees that applications rely on for crash consistency, for a wrapped
+// file system that applies directory entry changes to volatile state and
+// persists them separately. Create one with NewOrderedEntryFileSystem.
+//
+// Each change made successfully by the wrapped file system is recorded and
+// later passed to the commit function, in the order in which the changes
+// completed. (The kernel serializes conflicting changes to a directory, so
+// this order is consistent with what applications observe.) A change is
+// committed only once all earlier changes have been, so a crash never
+// persists a later change without an earlier one. Changes are committed:
+//
+//   - When a directory is fsynced, up to and including the last change to its
+//     entries, so that e.g. the classic "write temporary file, fsync it, rename
+//     it over the original, fsync the directory" sequence is durable once the
+//     final fsync returns.
+//
+//   - When a file is fsynced, up to and including the last change that linked
+//     it, so that a newly created file can be found after a crash once it has
+//     been fsynced, as applications commonly assume.
+//
+//   - When the file system is synced or destroyed, all of them.
+//
+// The fsync is then passed on to the wrapped file system, which remains
+// responsible for the durability of file contents.
+type OrderedEntryFileSystem struct {
+	FileSystem
+
+	commit func(context.Context, []EntryChange) error
+
+	// Held while committing, so that batches are committed in order.
+	commitMu sync.Mutex
+
+	mu sync.Mutex
+
+	// Changes not yet committed, oldest first.
+	//
+	// GUARDED_BY(mu)
+	pending []EntryChange
+}
+
+// NewOrderedEntryFileSystem wraps the supplied file system, passing its
+// directory entry changes to commit as described on OrderedEntryFileSystem.
+// If commit returns an error, the changes remain pending and the error is
+// returned for the fsync that triggered the commit.
+func NewOrderedEntryFileSystem(
+	wrapped FileSystem,
+	commit func(context.Context, []EntryChange) error) *OrderedEntryFileSystem {
+	return &OrderedEntryFileSystem{
+		FileSystem: wrapped,
+		commit:     commit,
+	}
+}
+
+// Pending returns the number of changes that have not yet been committed.
+func (fs *OrderedEntryFileSystem) Pending() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return len(fs.pending)
+}
+
+// Record a change if the op that made it succeeded.
+func (fs *OrderedEntryFileSystem) record(err error, c EntryChange) error {
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	fs.pending = append(fs.pending, c)
+	fs.mu.Unlock()
+
+	return nil
+}
+
+// Commit the changes up to and including the last one touching the inode, or
+// all changes if the inode is zero.
+//
+// LOCKS_EXCLUDED(fs.commitMu, fs.mu)
+func (fs *OrderedEntryFileSystem) commitThrough(
+	ctx context.Context,
+	inode fuseops.InodeID) error {
+	fs.commitMu.Lock()
+	defer fs.commitMu.Unlock()
+
+	fs.mu.Lock()
+	n := len(fs.pending)
+	if inode != 0 {
+		for n > 0 && !fs.pending[n-1].touches(inode) {
+			n--
+		}
+	}
+
+	batch := append([]EntryChange(nil), fs.pending[:n]...)
+	fs.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := fs.commit(ctx, batch); err != nil {
+		return err
+	}
+
+	// Only we remove changes, so the batch is still at the front.
+	fs.mu.Lock()
+	fs.pending = append([]EntryChange(nil), fs.pending[len(batch):]...)
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *OrderedEntryFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	return fs.record(fs.FileSystem.MkDir(ctx, op), EntryChange{
+		Op:     EntryCreate,
+		Parent: op.Parent,
+		Name:   op.Name,
+		Child:  op.Entry.Child,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	return fs.record(fs.FileSystem.MkNode(ctx, op), EntryChange{
+		Op:     EntryCreate,
+		Parent: op.Parent,
+		Name:   op.Name,
+		Child:  op.Entry.Child,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	return fs.record(fs.FileSystem.CreateFile(ctx, op), EntryChange{
+		Op:     EntryCreate,
+		Parent: op.Parent,
+		Name:   op.Name,
+		Child:  op.Entry.Child,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	return fs.record(fs.FileSystem.CreateSymlink(ctx, op), EntryChange{
+		Op:     EntryCreate,
+		Parent: op.Parent,
+		Name:   op.Name,
+		Child:  op.Entry.Child,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	return fs.record(fs.FileSystem.CreateLink(ctx, op), EntryChange{
+		Op:     EntryCreate,
+		Parent: op.Parent,
+		Name:   op.Name,
+		Child:  op.Entry.Child,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	return fs.record(fs.FileSystem.Rename(ctx, op), EntryChange{
+		Op:        EntryRename,
+		Parent:    op.OldParent,
+		Name:      op.OldName,
+		NewParent: op.NewParent,
+		NewName:   op.NewName,
+		Flags:     op.Flags,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	return fs.record(fs.FileSystem.RmDir(ctx, op), EntryChange{
+		Op:     EntryRemove,
+		Parent: op.Parent,
+		Name:   op.Name,
+	})
+}
+
+func (fs *OrderedEntryFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	return fs.record(fs.FileSystem.Unlink(ctx, op), EntryChange{
+		Op:     EntryRemove,
+		Parent: op.Parent,
+		Name:   op.Name,
+	})
+}
+
+// SyncFile is sent for fsync on both files and directories.
+func (fs *OrderedEntryFileSystem) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	if err := fs.commitThrough(ctx, op.Inode); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SyncFile(ctx, op)
+}
+
+func (fs *OrderedEntryFileSystem) SyncFS(
+	ctx context.Context,
+	op *fuseops.SyncFSOp) error {
+	if err := fs.commitThrough(ctx, 0); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SyncFS(ctx, op)
+}
+
+// Destroy makes a best effort to commit any pending changes before destroying
+// the wrapped file system.
+func (fs *OrderedEntryFileSystem) Destroy() {
+	fs.commitThrough(context.Background(), 0)
+	fs.FileSystem.Destroy()
+}
@@ -0,0 +1,297 @@
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A namespace: the entries of each directory.
+type namespace map[fuseops.InodeID]map[string]fuseops.InodeID
+
+func (ns namespace) apply(c EntryChange) {
+	if ns[c.Parent] == nil {
+		ns[c.Parent] = make(map[string]fuseops.InodeID)
+	}
+
+	switch c.Op {
+	case EntryCreate:
+		ns[c.Parent][c.Name] = c.Child
+
+	case EntryRemove:
+		delete(ns[c.Parent], c.Name)
+
+	case EntryRename:
+		if ns[c.NewParent] == nil {
+			ns[c.NewParent] = make(map[string]fuseops.InodeID)
+		}
+
+		ns[c.NewParent][c.NewName] = ns[c.Parent][c.Name]
+		delete(ns[c.Parent], c.Name)
+	}
+}
+
+// Return true if the directory is reachable, i.e. is the root or is linked
+// from some directory.
+func (ns namespace) exists(dir fuseops.InodeID) bool {
+	if dir == fuseops.RootInodeID {
+		return true
+	}
+
+	for _, entries := range ns {
+		for _, child := range entries {
+			if child == dir {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// A file system that makes entry changes to a volatile namespace.
+type volatileFS struct {
+	NotImplementedFileSystem
+	ns    namespace
+	next  fuseops.InodeID
+	syncs int
+}
+
+func (fs *volatileFS) create(
+	parent fuseops.InodeID,
+	name string,
+	e *fuseops.ChildInodeEntry) error {
+	if _, ok := fs.ns[parent][name]; ok {
+		return syscall.EEXIST
+	}
+
+	fs.next++
+	e.Child = fs.next
+	fs.ns.apply(EntryChange{Op: EntryCreate, Parent: parent, Name: name, Child: e.Child})
+	return nil
+}
+
+func (fs *volatileFS) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	return fs.create(op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *volatileFS) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	return fs.create(op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *volatileFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	fs.ns.apply(EntryChange{
+		Op:        EntryRename,
+		Parent:    op.OldParent,
+		Name:      op.OldName,
+		NewParent: op.NewParent,
+		NewName:   op.NewName,
+	})
+
+	return nil
+}
+
+func (fs *volatileFS) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	fs.syncs++
+	return nil
+}
+
+// Set up an OrderedEntryFileSystem whose committed changes are applied to the
+// returned namespace, which is what would survive a crash. Each commit is
+// checked to leave no entry in an unreachable directory.
+func newOrderedTestFS(t *testing.T) (
+	*OrderedEntryFileSystem,
+	*volatileFS,
+	namespace,
+	*error) {
+	wrapped := &volatileFS{
+		ns:   make(namespace),
+		next: fuseops.RootInodeID,
+	}
+
+	persisted := make(namespace)
+	var commitErr error
+	fs := NewOrderedEntryFileSystem(
+		wrapped,
+		func(ctx context.Context, changes []EntryChange) error {
+			if commitErr != nil {
+				return commitErr
+			}
+
+			for _, c := range changes {
+				persisted.apply(c)
+			}
+
+			for dir, entries := range persisted {
+				if len(entries) != 0 && !persisted.exists(dir) {
+					t.Errorf("persisted entries in missing directory %d", dir)
+				}
+			}
+
+			return nil
+		})
+
+	return fs, wrapped, persisted, &commitErr
+}
+
+func TestOrderedEntries_AtomicReplace(t *testing.T) {
+	ctx := context.Background()
+	fs, wrapped, persisted, _ := newOrderedTestFS(t)
+	var root fuseops.InodeID = fuseops.RootInodeID
+
+	// An existing file, durably created.
+	orig := &fuseops.CreateFileOp{Parent: root, Name: "file"}
+	if err := fs.CreateFile(ctx, orig); err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	if err := fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: root}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	// Write a temporary file and fsync it. That makes its name durable too.
+	tmp := &fuseops.CreateFileOp{Parent: root, Name: "file.tmp"}
+	if err := fs.CreateFile(ctx, tmp); err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	if err := fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: tmp.Entry.Child}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	if persisted[root]["file.tmp"] != tmp.Entry.Child {
+		t.Errorf("fsync of a new file didn't persist its entry: %v", persisted)
+	}
+
+	if wrapped.syncs != 2 {
+		t.Errorf("wrapped SyncFile calls: got %d, want 2", wrapped.syncs)
+	}
+
+	// Rename it over the original. Until the directory is fsynced, a crash
+	// leaves the original in place.
+	rename := &fuseops.RenameOp{
+		OldParent: root,
+		OldName:   "file.tmp",
+		NewParent: root,
+		NewName:   "file",
+	}
+
+	if err := fs.Rename(ctx, rename); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if persisted[root]["file"] != orig.Entry.Child {
+		t.Errorf("rename persisted before directory fsync: %v", persisted)
+	}
+
+	if err := fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: root}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	if persisted[root]["file"] != tmp.Entry.Child || len(persisted[root]) != 1 {
+		t.Errorf("unexpected persisted namespace: %v", persisted)
+	}
+
+	if fs.Pending() != 0 {
+		t.Errorf("Pending: got %d, want 0", fs.Pending())
+	}
+}
+
+func TestOrderedEntries_PrefixOrdering(t *testing.T) {
+	ctx := context.Background()
+	fs, _, persisted, _ := newOrderedTestFS(t)
+	var root fuseops.InodeID = fuseops.RootInodeID
+
+	// Create a directory and a file within it, then a file elsewhere.
+	dir := &fuseops.MkDirOp{Parent: root, Name: "dir"}
+	if err := fs.MkDir(ctx, dir); err != nil {
+		t.Fatalf("MkDir: %v", err)
+	}
+
+	other := &fuseops.MkDirOp{Parent: root, Name: "other"}
+	if err := fs.MkDir(ctx, other); err != nil {
+		t.Fatalf("MkDir: %v", err)
+	}
+
+	f := &fuseops.CreateFileOp{Parent: dir.Entry.Child, Name: "f"}
+	if err := fs.CreateFile(ctx, f); err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	g := &fuseops.CreateFileOp{Parent: other.Entry.Child, Name: "g"}
+	if err := fs.CreateFile(ctx, g); err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	// Fsyncing the inner directory must also persist the earlier creation of
+	// the directory itself (checked by the commit function), but not the later
+	// change elsewhere.
+	if err := fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: dir.Entry.Child}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	if persisted[dir.Entry.Child]["f"] != f.Entry.Child {
+		t.Errorf("directory fsync didn't persist its entry: %v", persisted)
+	}
+
+	if _, ok := persisted[other.Entry.Child]["g"]; ok {
+		t.Errorf("directory fsync persisted a later unrelated change: %v", persisted)
+	}
+
+	if fs.Pending() != 1 {
+		t.Errorf("Pending: got %d, want 1", fs.Pending())
+	}
+
+	// Syncing the file system persists everything.
+	if err := fs.SyncFS(ctx, &fuseops.SyncFSOp{}); err != nil && err != syscall.ENOSYS {
+		t.Fatalf("SyncFS: %v", err)
+	}
+
+	if persisted[other.Entry.Child]["g"] != g.Entry.Child {
+		t.Errorf("SyncFS didn't persist everything: %v", persisted)
+	}
+}
+
+func TestOrderedEntries_Errors(t *testing.T) {
+	ctx := context.Background()
+	fs, _, persisted, commitErr := newOrderedTestFS(t)
+	var root fuseops.InodeID = fuseops.RootInodeID
+
+	if err := fs.MkDir(ctx, &fuseops.MkDirOp{Parent: root, Name: "dir"}); err != nil {
+		t.Fatalf("MkDir: %v", err)
+	}
+
+	// Failed changes aren't recorded.
+	err := fs.MkDir(ctx, &fuseops.MkDirOp{Parent: root, Name: "dir"})
+	if err != syscall.EEXIST {
+		t.Fatalf("MkDir: got %v, want EEXIST", err)
+	}
+
+	if fs.Pending() != 1 {
+		t.Errorf("Pending: got %d, want 1", fs.Pending())
+	}
+
+	// A failed commit is reported and leaves the changes pending.
+	*commitErr = errors.New("taco")
+	err = fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: root})
+	if err != *commitErr {
+		t.Fatalf("SyncFile: got %v, want %v", err, *commitErr)
+	}
+
+	if fs.Pending() != 1 || len(persisted[root]) != 0 {
+		t.Errorf("failed commit: %d pending, persisted %v", fs.Pending(), persisted)
+	}
+
+	// Retrying works.
+	*commitErr = nil
+	if err := fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: root}); err != nil {
+		t.Fatalf("SyncFile: %v", err)
+	}
+
+	if fs.Pending() != 0 || len(persisted[root]) != 1 {
+		t.Errorf("retried commit: %d pending, persisted %v", fs.Pending(), persisted)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// ReadFileRanger may optionally be implemented by a FileSystem served with a
+// non-zero ServerConfig.ParallelReadSize. Each ReadFileOp is then split into
+// ranges of at most that size, which are fetched with concurrent calls to
+// ReadFileRange and reassembled into the reply, hiding the latency of each
+// backend request on large reads. ReadFile isn't called.
+type ReadFileRanger interface {
+	// Read into dst the data of the op's file starting at the offset, in the
+	// manner of io.ReaderAt: the number of bytes read is less than len(dst)
+	// only at the end of the file, in which case the error is nil or io.EOF.
+	//
+	// The context is cancelled once the result is no longer needed, because
+	// another range of the same op failed or ended the file.
+	ReadFileRange(
+		ctx context.Context,
+		op *fuseops.ReadFileOp,
+		dst []byte,
+		offset int64) (int, error)
+}
+
+// The result of reading one range of a ReadFileOp.
+type readRange struct {
+	n   int
+	err error
+}
+
+// Serve the read with concurrent calls to ReadFileRange, each for at most
+// size bytes, of which at most parallel are in flight.
+func readParallel(
+	ctx context.Context,
+	r ReadFileRanger,
+	op *fuseops.ReadFileOp,
+	size int,
+	parallel int) error {
+	dst := op.Dst
+	if int64(len(dst)) < op.Size {
+		dst = make([]byte, op.Size)
+		defer func() { op.Data = [][]byte{dst[:op.BytesRead]} }()
+	}
+
+	dst = dst[:op.Size]
+	count := (len(dst) + size - 1) / size
+	results := make([]readRange, count)
+
+	// Ranges after one that fails or ends the file aren't needed, and are
+	// cancelled or not started.
+	var mu sync.Mutex
+	cancels := make([]context.CancelFunc, count)
+	needed := count // GUARDED_BY(mu)
+
+	defer func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].err = ctx.Err()
+		}
+
+		if results[i].err != nil {
+			break
+		}
+
+		mu.Lock()
+		if i >= needed {
+			mu.Unlock()
+			break
+		}
+
+		rangeCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := i * size
+			end := min(start+size, len(dst))
+			n, err := r.ReadFileRange(rangeCtx, op, dst[start:end], op.Offset+int64(start))
+			results[i] = readRange{n, err}
+
+			if n < end-start || (err != nil && err != io.EOF) {
+				mu.Lock()
+				if i+1 < needed {
+					needed = i + 1
+					for _, cancel := range cancels[needed:] {
+						if cancel != nil {
+							cancel()
+						}
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Reply with the data up to the first range that failed or came up short,
+	// failing only if there is none.
+	for i, res := range results {
+		if res.err != nil && res.err != io.EOF {
+			if op.BytesRead == 0 {
+				return res.err
+			}
+
+			return nil
+		}
+
+		op.BytesRead += res.n
+		if res.n < min(size, len(dst)-i*size) {
+			return nil
+		}
+	}
+
+	return nil
+}
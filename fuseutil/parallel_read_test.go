@@ -0,0 +1,99 @@
+package fuseutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file served a range at a time, slowly.
+type rangeFS struct {
+	NotImplementedFileSystem
+	contents []byte
+	failAt   int64
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (fs *rangeFS) ReadFileRange(
+	ctx context.Context,
+	op *fuseops.ReadFileOp,
+	dst []byte,
+	offset int64) (int, error) {
+	fs.mu.Lock()
+	fs.inFlight++
+	fs.maxInFlight = max(fs.maxInFlight, fs.inFlight)
+	fs.mu.Unlock()
+
+	defer func() {
+		fs.mu.Lock()
+		fs.inFlight--
+		fs.mu.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if fs.failAt != 0 && offset >= fs.failAt {
+		return 0, syscall.EIO
+	}
+
+	if offset >= int64(len(fs.contents)) {
+		return 0, io.EOF
+	}
+
+	return copy(dst, fs.contents[offset:]), nil
+}
+
+func TestParallelRead(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 100)
+	fs := &rangeFS{contents: contents}
+	s := NewFileSystemServerWithConfig(fs, &ServerConfig{
+		ParallelReadSize: 64,
+		MaxParallelReads: 3,
+	}).(*fileSystemServer)
+
+	if !s.Capabilities()["ReadFile"] {
+		t.Errorf("ReadFile not reported as implemented")
+	}
+
+	read := func(offset int64, size int) (*fuseops.ReadFileOp, error) {
+		op := &fuseops.ReadFileOp{Offset: offset, Size: int64(size), Dst: make([]byte, size)}
+		err := s.dispatch(context.Background(), op)
+		return op, err
+	}
+
+	// A read is reassembled from concurrent ranges.
+	op, err := read(10, 500)
+	if err != nil || !bytes.Equal(op.Dst[:op.BytesRead], contents[10:510]) {
+		t.Errorf("read: %d bytes, %v", op.BytesRead, err)
+	}
+
+	if fs.maxInFlight != 3 {
+		t.Errorf("max ranges in flight: got %d, want 3", fs.maxInFlight)
+	}
+
+	// A read past the end of the file is short.
+	op, err = read(900, 500)
+	if err != nil || !bytes.Equal(op.Dst[:op.BytesRead], contents[900:]) {
+		t.Errorf("read at end: %d bytes, %v", op.BytesRead, err)
+	}
+
+	// A failure after the first range gives a short read of the ranges before
+	// it (at 100 and 164), and one of the first range fails the read.
+	fs.failAt = 200
+	op, err = read(100, 500)
+	if err != nil || op.BytesRead != 128 {
+		t.Errorf("read with failure: %d bytes, %v", op.BytesRead, err)
+	}
+
+	if _, err = read(200, 500); err != syscall.EIO {
+		t.Errorf("read with failure at start: got %v, want EIO", err)
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// ParentTrackingConfig configures a ParentTrackingFileSystem.
+type ParentTrackingConfig struct {
+	// How many inodes to remember. Once more have been seen, the least recently
+	// seen are dropped, and lookups of "." and ".." for them are passed to the
+	// wrapped file system. If zero, 65536.
+	Capacity int
+}
+
+// ParentTrackingFileSystem is a FileSystem that remembers the directory each
+// inode was found in, and answers the lookups of "." and ".." that the kernel
+// sends to open a file handle whose inode it has forgotten, e.g. when the file
+// system is exported over NFS, so that the wrapped file system needn't keep
+// parent pointers just for them. See fuse.MountConfig.EnableExportSupport.
+// Create one with NewParentTrackingFileSystem.
+//
+// An inode's parent and generation number are taken from the entries returned
+// by LookUpInode, MkDir, MkNode, CreateFile, CreateTmpfile, CreateSymlink and
+// CreateLink, and kept up to date as directories are renamed and removed.
+// Entries returned in ReadDirPlus replies aren't seen. They are remembered
+// after the kernel forgets the inode, since that is when it asks for them, so
+// the wrapped file system must not give a remembered ID to another inode
+// without the kernel having looked it up again.
+//
+// The answer to a lookup of "." or ".." is the remembered inode with the
+// attributes returned by the wrapped file system's GetInodeAttributes, which
+// must therefore accept IDs the kernel has forgotten. The references the
+// kernel takes to these entries are deducted from the counts in ForgetInode
+// and BatchForget before they are passed on, so the wrapped file system sees
+// only the references to entries it returned itself. Lookups of "." and ".."
+// for inodes that aren't remembered are passed to the wrapped file system.
+type ParentTrackingFileSystem struct {
+	FileSystem
+	cfg ParentTrackingConfig
+
+	mu sync.Mutex
+
+	// The remembered inodes, and the names of those that are directories.
+	//
+	// INVARIANT: For each d, id in dirs, entries[id] is a directory named d
+	//
+	// GUARDED_BY(mu)
+	entries map[fuseops.InodeID]*list.Element
+	dirs    map[Dentry]fuseops.InodeID
+
+	// Elements of type *parentEntry, most recently seen first.
+	//
+	// INVARIANT: Contains exactly the elements in entries.
+	//
+	// GUARDED_BY(mu)
+	lru list.List
+
+	// The references the kernel holds to entries answered here.
+	//
+	// GUARDED_BY(mu)
+	lookups map[fuseops.InodeID]uint64
+}
+
+type parentEntry struct {
+	inode      fuseops.InodeID
+	generation fuseops.GenerationNumber
+	parent     fuseops.InodeID
+	name       string
+	dir        bool
+}
+
+// NewParentTrackingFileSystem wraps the supplied file system, answering
+// lookups of "." and ".." as described on ParentTrackingFileSystem.
+func NewParentTrackingFileSystem(
+	wrapped FileSystem,
+	cfg ParentTrackingConfig) *ParentTrackingFileSystem {
+	if cfg.Capacity == 0 {
+		cfg.Capacity = 65536
+	}
+
+	return &ParentTrackingFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		entries:    make(map[fuseops.InodeID]*list.Element),
+		dirs:       make(map[Dentry]fuseops.InodeID),
+		lookups:    make(map[fuseops.InodeID]uint64),
+	}
+}
+
+// Parent returns the directory the inode was last found in, if remembered.
+// The root is its own parent.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ParentTrackingFileSystem) Parent(
+	inode fuseops.InodeID) (parent fuseops.InodeID, ok bool) {
+	if inode == fuseops.RootInodeID {
+		return inode, true
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	elem, ok := fs.entries[inode]
+	if !ok {
+		return 0, false
+	}
+
+	return elem.Value.(*parentEntry).parent, true
+}
+
+// Remember the entry returned by a successful op for a name in the parent.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ParentTrackingFileSystem) entry(
+	err error,
+	parent fuseops.InodeID,
+	name string,
+	e *fuseops.ChildInodeEntry) error {
+	if err != nil || e.Child == 0 || e.Child == fuseops.RootInodeID {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	elem, ok := fs.entries[e.Child]
+	if ok {
+		fs.unname(elem.Value.(*parentEntry))
+		fs.lru.MoveToFront(elem)
+	} else {
+		elem = fs.lru.PushFront(&parentEntry{inode: e.Child})
+		fs.entries[e.Child] = elem
+	}
+
+	pe := elem.Value.(*parentEntry)
+	pe.generation = e.Generation
+	pe.parent = parent
+	pe.name = name
+	pe.dir = e.Attributes.Mode.IsDir()
+	if pe.dir {
+		if old, ok := fs.dirs[Dentry{parent, name}]; ok {
+			fs.remove(old)
+		}
+
+		fs.dirs[Dentry{parent, name}] = e.Child
+	}
+
+	for fs.lru.Len() > fs.cfg.Capacity {
+		fs.remove(fs.lru.Back().Value.(*parentEntry).inode)
+	}
+
+	return nil
+}
+
+// Remove the entry's name from dirs, if it has one.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *ParentTrackingFileSystem) unname(pe *parentEntry) {
+	d := Dentry{pe.parent, pe.name}
+	if pe.dir && fs.dirs[d] == pe.inode {
+		delete(fs.dirs, d)
+	}
+}
+
+// Forget the inode, if remembered.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *ParentTrackingFileSystem) remove(inode fuseops.InodeID) {
+	elem, ok := fs.entries[inode]
+	if !ok {
+		return
+	}
+
+	fs.unname(elem.Value.(*parentEntry))
+	fs.lru.Remove(elem)
+	delete(fs.entries, inode)
+}
+
+// Return the ID and generation number of the inode that a lookup of "." or
+// ".." in the supplied inode refers to, if remembered.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ParentTrackingFileSystem) resolveDot(
+	inode fuseops.InodeID,
+	name string) (fuseops.InodeID, fuseops.GenerationNumber, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if name == ".." && inode != fuseops.RootInodeID {
+		elem, ok := fs.entries[inode]
+		if !ok || !elem.Value.(*parentEntry).dir {
+			return 0, 0, false
+		}
+
+		inode = elem.Value.(*parentEntry).parent
+	}
+
+	if inode == fuseops.RootInodeID {
+		return inode, 0, true
+	}
+
+	elem, ok := fs.entries[inode]
+	if !ok {
+		return 0, 0, false
+	}
+
+	return inode, elem.Value.(*parentEntry).generation, true
+}
+
+// Release up to n of the references the kernel holds to entries answered
+// here, returning the number left to pass on.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *ParentTrackingFileSystem) forget(
+	inode fuseops.InodeID,
+	n uint64) uint64 {
+	count := fs.lookups[inode]
+	if n < count {
+		fs.lookups[inode] = count - n
+		return 0
+	}
+
+	delete(fs.lookups, inode)
+	return n - count
+}
+
+func (fs *ParentTrackingFileSystem) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Name != "." && op.Name != ".." {
+		return fs.entry(
+			fs.FileSystem.LookUpInode(ctx, op),
+			op.Parent,
+			op.Name,
+			&op.Entry)
+	}
+
+	child, generation, ok := fs.resolveDot(op.Parent, op.Name)
+	if !ok {
+		return fs.FileSystem.LookUpInode(ctx, op)
+	}
+
+	attrs := &fuseops.GetInodeAttributesOp{
+		Inode:     child,
+		OpContext: op.OpContext,
+	}
+
+	if err := fs.FileSystem.GetInodeAttributes(ctx, attrs); err != nil {
+		return err
+	}
+
+	op.Entry = fuseops.ChildInodeEntry{
+		Child:                child,
+		Generation:           generation,
+		Attributes:           attrs.Attributes,
+		AttributesExpiration: attrs.AttributesExpiration,
+	}
+
+	fs.mu.Lock()
+	fs.lookups[child]++
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *ParentTrackingFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	return fs.entry(fs.FileSystem.MkDir(ctx, op), op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	return fs.entry(fs.FileSystem.MkNode(ctx, op), op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	return fs.entry(fs.FileSystem.CreateFile(ctx, op), op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	return fs.entry(fs.FileSystem.CreateTmpfile(ctx, op), op.Parent, "", &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	return fs.entry(fs.FileSystem.CreateSymlink(ctx, op), op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	return fs.entry(fs.FileSystem.CreateLink(ctx, op), op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *ParentTrackingFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	if err := fs.FileSystem.Rename(ctx, op); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	from := Dentry{op.OldParent, op.OldName}
+	to := Dentry{op.NewParent, op.NewName}
+	src, srcOK := fs.dirs[from]
+	dst, dstOK := fs.dirs[to]
+
+	// The directory previously at the new name is either moved to the old one
+	// or gone.
+	if dstOK {
+		if op.Flags&fuseops.RenameExchange != 0 {
+			fs.move(dst, from)
+		} else {
+			fs.remove(dst)
+		}
+	}
+
+	if srcOK {
+		fs.move(src, to)
+	}
+
+	return nil
+}
+
+// Record that the directory now has the supplied name.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *ParentTrackingFileSystem) move(inode fuseops.InodeID, d Dentry) {
+	pe := fs.entries[inode].Value.(*parentEntry)
+	fs.unname(pe)
+	pe.parent = d.Parent
+	pe.name = d.Name
+	fs.dirs[d] = inode
+}
+
+func (fs *ParentTrackingFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	if err := fs.FileSystem.RmDir(ctx, op); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	if inode, ok := fs.dirs[Dentry{op.Parent, op.Name}]; ok {
+		fs.remove(inode)
+	}
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *ParentTrackingFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.mu.Lock()
+	op.N = fs.forget(op.Inode, op.N)
+	fs.mu.Unlock()
+
+	if op.N == 0 {
+		return nil
+	}
+
+	return fs.FileSystem.ForgetInode(ctx, op)
+}
+
+func (fs *ParentTrackingFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	entries := op.Entries[:0]
+	fs.mu.Lock()
+	for _, e := range op.Entries {
+		if e.N = fs.forget(e.Inode, e.N); e.N != 0 {
+			entries = append(entries, e)
+		}
+	}
+	fs.mu.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	op.Entries = entries
+	return fs.FileSystem.BatchForget(ctx, op)
+}
@@ -0,0 +1,155 @@
+package fuseutil
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system in which every name is a directory whose ID is given by the
+// names map, with generation number 7, which fails lookups of "." and "..",
+// and which records the forgets it receives.
+type dirsFS struct {
+	NotImplementedFileSystem
+	names map[string]fuseops.InodeID
+
+	forgets map[fuseops.InodeID]uint64
+}
+
+func (fs *dirsFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	child, ok := fs.names[op.Name]
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = child
+	op.Entry.Generation = 7
+	op.Entry.Attributes.Mode = os.ModeDir
+	return nil
+}
+
+func (fs *dirsFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes.Mode = os.ModeDir
+	op.Attributes.Size = uint64(op.Inode)
+	return nil
+}
+
+func (fs *dirsFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	return nil
+}
+
+func (fs *dirsFS) RmDir(ctx context.Context, op *fuseops.RmDirOp) error {
+	return nil
+}
+
+func (fs *dirsFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	fs.forgets[op.Inode] += op.N
+	return nil
+}
+
+func (fs *dirsFS) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) error {
+	for _, e := range op.Entries {
+		fs.forgets[e.Inode] += e.N
+	}
+
+	return nil
+}
+
+func TestParentTracking(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &dirsFS{
+		names:   map[string]fuseops.InodeID{"a": 2, "b": 3, "c": 4},
+		forgets: make(map[fuseops.InodeID]uint64),
+	}
+
+	fs := NewParentTrackingFileSystem(wrapped, ParentTrackingConfig{Capacity: 2})
+
+	lookUp := func(parent fuseops.InodeID, name string) (fuseops.ChildInodeEntry, error) {
+		op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+		err := fs.LookUpInode(ctx, op)
+		return op.Entry, err
+	}
+
+	// Look up /a/b.
+	if _, err := lookUp(fuseops.RootInodeID, "a"); err != nil {
+		t.Fatalf("LookUpInode: %v", err)
+	}
+
+	if _, err := lookUp(2, "b"); err != nil {
+		t.Fatalf("LookUpInode: %v", err)
+	}
+
+	if p, ok := fs.Parent(3); !ok || p != 2 {
+		t.Errorf("Parent: got (%v, %v)", p, ok)
+	}
+
+	// "." and ".." are answered with the remembered IDs and generations.
+	for _, tc := range []struct {
+		parent     fuseops.InodeID
+		name       string
+		child      fuseops.InodeID
+		generation fuseops.GenerationNumber
+	}{
+		{3, ".", 3, 7},
+		{3, "..", 2, 7},
+		{2, "..", fuseops.RootInodeID, 0},
+		{fuseops.RootInodeID, "..", fuseops.RootInodeID, 0},
+	} {
+		e, err := lookUp(tc.parent, tc.name)
+		if err != nil {
+			t.Fatalf("LookUpInode(%v, %q): %v", tc.parent, tc.name, err)
+		}
+
+		if e.Child != tc.child || e.Generation != tc.generation || e.Attributes.Size != uint64(tc.child) {
+			t.Errorf("LookUpInode(%v, %q): got %+v", tc.parent, tc.name, e)
+		}
+	}
+
+	// Renames move directories.
+	err := fs.Rename(ctx, &fuseops.RenameOp{
+		OldParent: 2,
+		OldName:   "b",
+		NewParent: fuseops.RootInodeID,
+		NewName:   "b",
+	})
+
+	if err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if e, err := lookUp(3, ".."); err != nil || e.Child != fuseops.RootInodeID {
+		t.Errorf("LookUpInode after rename: got (%+v, %v)", e, err)
+	}
+
+	// Removed directories are forgotten, and lookups for them passed on.
+	if err := fs.RmDir(ctx, &fuseops.RmDirOp{Parent: fuseops.RootInodeID, Name: "b"}); err != nil {
+		t.Fatalf("RmDir: %v", err)
+	}
+
+	if _, err := lookUp(3, "."); err != fuse.ENOENT {
+		t.Errorf("LookUpInode after rmdir: got %v, want ENOENT", err)
+	}
+
+	// The least recently seen inodes are dropped beyond the capacity.
+	lookUp(fuseops.RootInodeID, "b")
+	lookUp(fuseops.RootInodeID, "c")
+	if _, ok := fs.Parent(2); ok {
+		t.Errorf("Parent of dropped inode is known")
+	}
+
+	// The references to entries answered for "." and ".." aren't passed on.
+	// Those are one each of 2 and 3, and the kernel also holds one of 2 from
+	// the wrapped file system.
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 2, N: 2})
+	fs.BatchForget(ctx, &fuseops.BatchForgetOp{
+		Entries: []fuseops.BatchForgetEntry{{Inode: 3, N: 1}, {Inode: 4, N: 1}},
+	})
+
+	want := map[fuseops.InodeID]uint64{2: 1, 4: 1}
+	if len(wrapped.forgets) != len(want) || wrapped.forgets[2] != 1 || wrapped.forgets[4] != 1 {
+		t.Errorf("Forgets: got %v, want %v", wrapped.forgets, want)
+	}
+}
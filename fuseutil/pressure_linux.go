@@ -0,0 +1,114 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// ReadCgroupPressure reads the memory and CPU pressure of the cgroup v2 group
+// containing the current process. It fails if the unified hierarchy or PSI
+// accounting is not available.
+func ReadCgroupPressure() (Pressure, error) {
+	var p Pressure
+
+	dir, err := ownCgroup()
+	if err != nil {
+		return p, err
+	}
+
+	p.Memory, err = readPressureFile(path.Join(cgroupRoot, dir, "memory.pressure"))
+	if err != nil {
+		return p, err
+	}
+
+	p.CPU, err = readPressureFile(path.Join(cgroupRoot, dir, "cpu.pressure"))
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Find the path of our cgroup within the unified hierarchy, from the "0::"
+// line of /proc/self/cgroup.
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return parseCgroupFile(f)
+}
+
+func parseCgroupFile(r io.Reader) (string, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if dir, ok := strings.CutPrefix(s.Text(), "0::"); ok {
+			return dir, nil
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no cgroup v2 entry found")
+}
+
+func readPressureFile(name string) (float64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return parsePressure(f)
+}
+
+// Parse the "some" avg10 value from a PSI file, whose lines look like:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(r io.Reader) (float64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(f, "avg10="); ok {
+				return strconv.ParseFloat(v, 64)
+			}
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("no \"some avg10\" entry found")
+}
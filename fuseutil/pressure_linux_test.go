@@ -0,0 +1,37 @@
+package fuseutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePressure(t *testing.T) {
+	const contents = "some avg10=12.50 avg60=3.00 avg300=1.00 total=1234\n" +
+		"full avg10=2.00 avg60=1.00 avg300=0.00 total=12\n"
+
+	v, err := parsePressure(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("parsePressure: %v", err)
+	}
+
+	if v != 12.5 {
+		t.Errorf("got %v, want 12.5", v)
+	}
+
+	if _, err := parsePressure(strings.NewReader("")); err == nil {
+		t.Errorf("expected error for empty input")
+	}
+}
+
+func TestParseCgroupFile(t *testing.T) {
+	const contents = "1:name=systemd:/foo\n0::/user.slice/daemon.service\n"
+
+	dir, err := parseCgroupFile(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("parseCgroupFile: %v", err)
+	}
+
+	if dir != "/user.slice/daemon.service" {
+		t.Errorf("got %q", dir)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/syncutil"
+)
+
+// PrewarmRange is a range of a file to read ahead of time. See Prewarm.
+type PrewarmRange struct {
+	Inode  fuseops.InodeID
+	Offset int64
+
+	// The number of bytes to read, or zero to read to the end of the file.
+	Length int64
+}
+
+// PrewarmConfig configures Prewarm.
+type PrewarmConfig struct {
+	// Reads data of the file starting at the offset into dst, in the manner of
+	// io.ReaderAt: the number of bytes read is less than len(dst) only at the
+	// end of the file, in which case the error is nil or io.EOF. This is
+	// normally how the file system reads from its backend to serve a
+	// ReadFileOp, so that the data is then in whatever cache it keeps. Must be
+	// non-nil.
+	Read func(ctx context.Context, inode fuseops.InodeID, dst []byte, offset int64) (int, error)
+
+	// If non-nil, the data read is also pushed into the kernel's page cache
+	// with Notifier.Store, so that the first reads through the mount needn't
+	// reach the file system at all. Open handles must then be opened with
+	// OpenFileOp.KeepPageCache, or the kernel discards the data.
+	Notifier *fuse.Notifier
+
+	// The number of ranges read at once. If zero, 4.
+	Parallelism int
+
+	// The most data read by a call to Read and pushed to the kernel at once,
+	// rounded up to a whole number of pages. If zero, 1 MiB.
+	ChunkSize int
+}
+
+// Prewarm reads the supplied ranges, e.g. a manifest of the files a known
+// application reads on startup, so that reads of them through the mount are
+// served without waiting for the file system's backend. It is normally
+// called once the file system has been mounted, and returns the first error
+// from Read or Notifier.Store, once all the reads in progress have finished.
+//
+// The kernel accepts data only for inodes it has looked up, so for the data
+// to reach its page cache the files must have been looked up first, e.g. by
+// stat'ing their paths in the mount. Ranges of files the kernel doesn't know
+// are still read, but not pushed to the kernel.
+func Prewarm(ctx context.Context, ranges []PrewarmRange, cfg PrewarmConfig) error {
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 4
+	}
+
+	// The kernel marks only whole pages as up to date, so chunks are aligned
+	// to them.
+	pageSize := os.Getpagesize()
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 1 << 20
+	}
+	cfg.ChunkSize = (cfg.ChunkSize + pageSize - 1) / pageSize * pageSize
+
+	b := syncutil.NewBundle(ctx)
+	sem := make(chan struct{}, cfg.Parallelism)
+	for _, r := range ranges {
+		b.Add(func(ctx context.Context) error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			return prewarmRange(ctx, r, cfg, pageSize)
+		})
+	}
+
+	return b.Join()
+}
+
+// Read the range a chunk at a time, pushing each to the kernel if configured.
+func prewarmRange(
+	ctx context.Context,
+	r PrewarmRange,
+	cfg PrewarmConfig,
+	pageSize int) error {
+	offset := r.Offset / int64(pageSize) * int64(pageSize)
+	end := int64(-1)
+	if r.Length != 0 {
+		end = r.Offset + r.Length
+	}
+
+	buf := make([]byte, cfg.ChunkSize)
+	store := cfg.Notifier != nil
+	for end < 0 || offset < end {
+		dst := buf
+		if end >= 0 && end-offset < int64(len(dst)) {
+			dst = dst[:end-offset]
+		}
+
+		n, err := cfg.Read(ctx, r.Inode, dst, offset)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("Read(%v, %d): %w", r.Inode, offset, err)
+		}
+
+		if store && n > 0 {
+			err := cfg.Notifier.Store(r.Inode, offset, dst[:n])
+			switch {
+			case errors.Is(err, syscall.ENOENT):
+				store = false
+
+			case err != nil:
+				return fmt.Errorf("Store(%v, %d): %w", r.Inode, offset, err)
+			}
+		}
+
+		if n < len(dst) {
+			break
+		}
+
+		offset += int64(n)
+	}
+
+	return nil
+}
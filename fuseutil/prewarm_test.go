@@ -0,0 +1,68 @@
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestPrewarm(t *testing.T) {
+	page := int64(os.Getpagesize())
+	size := 3*page + 10
+
+	var mu sync.Mutex
+	reads := make(map[fuseops.InodeID][]int64)
+	read := func(ctx context.Context, inode fuseops.InodeID, dst []byte, offset int64) (int, error) {
+		mu.Lock()
+		reads[inode] = append(reads[inode], offset)
+		mu.Unlock()
+
+		if inode == 4 {
+			return 0, errors.New("taco")
+		}
+
+		if offset >= size {
+			return 0, io.EOF
+		}
+
+		return int(min(int64(len(dst)), size-offset)), nil
+	}
+
+	// Ranges are read a chunk at a time from the start of their first page,
+	// until their end or the end of the file.
+	err := Prewarm(
+		context.Background(),
+		[]PrewarmRange{
+			{Inode: 2},
+			{Inode: 3, Offset: page + 1, Length: page},
+		},
+		PrewarmConfig{Read: read, ChunkSize: 1})
+
+	if err != nil {
+		t.Fatalf("Prewarm: %v", err)
+	}
+
+	if want := []int64{0, page, 2 * page, 3 * page}; !slices.Equal(reads[2], want) {
+		t.Errorf("Reads of 2: got %v, want %v", reads[2], want)
+	}
+
+	if want := []int64{page, 2 * page}; !slices.Equal(reads[3], want) {
+		t.Errorf("Reads of 3: got %v, want %v", reads[3], want)
+	}
+
+	// Errors are returned.
+	err = Prewarm(
+		context.Background(),
+		[]PrewarmRange{{Inode: 4}},
+		PrewarmConfig{Read: read})
+
+	if err == nil {
+		t.Errorf("Prewarm succeeded despite a read error")
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// PunchHoleConfig configures a PunchHoleFileSystem.
+type PunchHoleConfig struct {
+	// The size of each write of zeros. If zero, 1 MiB is used.
+	ChunkSize int
+
+	// If non-nil, called with errors from writing zeros. They are also
+	// returned by the next SyncFile or FlushFile for the inode.
+	OnError func(inode fuseops.InodeID, err error)
+}
+
+// PunchHoleFileSystem is a FileSystem that emulates punching holes with
+// fallocate(2) when the wrapped file system can't, e.g. because its backend
+// has no notion of sparse files, as is common for file systems serving disk
+// images. Create one with NewPunchHoleFileSystem.
+//
+// Punch-hole requests are passed on to the wrapped file system, and if it
+// fails them with ENOSYS or EOPNOTSUPP, the range is instead overwritten with
+// zeros, clamped to the current size of the file so that the size doesn't
+// change, using writes of bounded size through the handle the request came
+// from. The zeros are written in the background, after replying to the
+// request; ops on the inode that could observe them, such as reads, writes,
+// truncation, and fsync, wait for them to be written.
+type PunchHoleFileSystem struct {
+	FileSystem
+	cfg PunchHoleConfig
+
+	mu sync.Mutex
+
+	// Inodes for which zeros are being written, and the errors from writing
+	// zeros not yet reported.
+	//
+	// GUARDED_BY(mu)
+	pending map[fuseops.InodeID]chan struct{}
+	errs    map[fuseops.InodeID]error
+}
+
+// NewPunchHoleFileSystem wraps the supplied file system, emulating punching
+// holes as described on PunchHoleFileSystem.
+func NewPunchHoleFileSystem(
+	wrapped FileSystem,
+	cfg PunchHoleConfig) *PunchHoleFileSystem {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1 << 20
+	}
+
+	return &PunchHoleFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		pending:    make(map[fuseops.InodeID]chan struct{}),
+		errs:       make(map[fuseops.InodeID]error),
+	}
+}
+
+// Wait until no zeros are being written for the inode.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *PunchHoleFileSystem) wait(inode fuseops.InodeID) {
+	fs.mu.Lock()
+	done := fs.pending[inode]
+	fs.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// Wait until no zeros are being written for the inode, then return any error
+// from writing them that hasn't yet been reported.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *PunchHoleFileSystem) waitErr(inode fuseops.InodeID) error {
+	fs.wait(inode)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	err := fs.errs[inode]
+	delete(fs.errs, inode)
+	return err
+}
+
+func (fs *PunchHoleFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	fs.wait(op.Inode)
+
+	err := fs.FileSystem.Fallocate(ctx, op)
+	if op.Mode&fuseops.FallocatePunchHole == 0 ||
+		!(errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EOPNOTSUPP)) {
+		return err
+	}
+
+	// Find the size of the file, which mustn't change.
+	attrs := &fuseops.GetInodeAttributesOp{
+		Inode:     op.Inode,
+		OpContext: op.OpContext,
+	}
+
+	if err := fs.FileSystem.GetInodeAttributes(ctx, attrs); err != nil {
+		return err
+	}
+
+	end := op.Offset + op.Length
+	if end > attrs.Attributes.Size {
+		end = attrs.Attributes.Size
+	}
+
+	if op.Offset >= end {
+		return nil
+	}
+
+	fs.mu.Lock()
+	done := make(chan struct{})
+	fs.pending[op.Inode] = done
+	fs.mu.Unlock()
+
+	go fs.zero(context.WithoutCancel(ctx), op, end, done)
+	return nil
+}
+
+// Write zeros to the range of the file covered by the op, up to end.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *PunchHoleFileSystem) zero(
+	ctx context.Context,
+	op *fuseops.FallocateOp,
+	end uint64,
+	done chan struct{}) {
+	zeros := make([]byte, fs.cfg.ChunkSize)
+
+	var err error
+	for off := op.Offset; off < end && err == nil; off += uint64(len(zeros)) {
+		n := uint64(len(zeros))
+		if end-off < n {
+			n = end - off
+		}
+
+		err = fs.FileSystem.WriteFile(ctx, &fuseops.WriteFileOp{
+			Inode:     op.Inode,
+			Handle:    op.Handle,
+			Offset:    int64(off),
+			Data:      zeros[:n],
+			OpContext: op.OpContext,
+		})
+	}
+
+	if err != nil && fs.cfg.OnError != nil {
+		fs.cfg.OnError(op.Inode, err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err != nil {
+		fs.errs[op.Inode] = err
+	}
+
+	delete(fs.pending, op.Inode)
+	close(done)
+}
+
+func (fs *PunchHoleFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	if op.Size != nil {
+		fs.wait(op.Inode)
+	}
+
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	if op.OpenFlags.IsTruncate() {
+		fs.wait(op.Inode)
+	}
+
+	return fs.FileSystem.OpenFile(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.wait(op.Inode)
+	return fs.FileSystem.ReadFile(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.wait(op.Inode)
+	return fs.FileSystem.WriteFile(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	if err := fs.waitErr(op.Inode); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SyncFile(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	if err := fs.waitErr(op.Inode); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.FlushFile(ctx, op)
+}
+
+func (fs *PunchHoleFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	// The zeros may be being written through this handle. The op doesn't say
+	// which inode the handle is for, so wait for all of them.
+	fs.mu.Lock()
+	var pending []chan struct{}
+	for _, done := range fs.pending {
+		pending = append(pending, done)
+	}
+	fs.mu.Unlock()
+
+	for _, done := range pending {
+		<-done
+	}
+
+	return fs.FileSystem.ReleaseFileHandle(ctx, op)
+}
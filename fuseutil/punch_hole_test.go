@@ -0,0 +1,86 @@
+package fuseutil
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system with a single file that can't punch holes.
+type holelessFS struct {
+	NotImplementedFileSystem
+
+	mu       sync.Mutex
+	contents []byte
+	writes   []int
+}
+
+func (fs *holelessFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	op.Attributes.Size = uint64(len(fs.contents))
+	return nil
+}
+
+func (fs *holelessFS) Fallocate(ctx context.Context, op *fuseops.FallocateOp) error {
+	return syscall.EOPNOTSUPP
+}
+
+func (fs *holelessFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	op.BytesRead = copy(op.Dst, fs.contents[op.Offset:])
+	return nil
+}
+
+func (fs *holelessFS) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.writes = append(fs.writes, len(op.Data))
+	copy(fs.contents[op.Offset:], op.Data)
+	return nil
+}
+
+func TestPunchHoleEmulation(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &holelessFS{contents: bytes.Repeat([]byte("x"), 100)}
+	fs := NewPunchHoleFileSystem(wrapped, PunchHoleConfig{ChunkSize: 16})
+
+	// Punch a hole extending past the end of the file.
+	err := fs.Fallocate(ctx, &fuseops.FallocateOp{
+		Inode:  2,
+		Offset: 40,
+		Length: 100,
+		Mode:   0x3,
+	})
+
+	if err != nil {
+		t.Fatalf("Fallocate: %v", err)
+	}
+
+	// A read waits for the zeros to be written.
+	op := &fuseops.ReadFileOp{Inode: 2, Dst: make([]byte, 200)}
+	if err := fs.ReadFile(ctx, op); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	want := append(bytes.Repeat([]byte("x"), 40), make([]byte, 60)...)
+	if !bytes.Equal(op.Dst[:op.BytesRead], want) {
+		t.Errorf("ReadFile: got %q", op.Dst[:op.BytesRead])
+	}
+
+	// The zeros were written in chunks, and didn't extend the file.
+	if len(wrapped.writes) != 4 || wrapped.writes[3] != 12 {
+		t.Errorf("unexpected writes: %v", wrapped.writes)
+	}
+
+	// Other modes fail as before.
+	err = fs.Fallocate(ctx, &fuseops.FallocateOp{Inode: 2, Length: 10})
+	if err != syscall.EOPNOTSUPP {
+		t.Errorf("Fallocate: got %v, want EOPNOTSUPP", err)
+	}
+}
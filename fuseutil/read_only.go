@@ -0,0 +1,395 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+// ReadOnlyConfig configures a ReadOnlyFileSystem.
+type ReadOnlyConfig struct {
+	// Whether the file system starts out read-only.
+	ReadOnly bool
+
+	// If non-nil, SetReadOnly uses it to have the kernel write back the pages
+	// it has cached for files open for writing before writes start to be
+	// rejected. Otherwise pages dirtied in the kernel's writeback cache (see
+	// MountConfig.EnableWritebackCache) before the switch fail with EROFS when
+	// they are later written back.
+	Notifier *fuse.Notifier
+}
+
+// ReadOnlyFileSystem is a FileSystem that can be switched between read-write
+// and read-only while mounted, e.g. so that a daemon whose backend stops
+// accepting writes can keep serving reads. Create one with
+// NewReadOnlyFileSystem.
+//
+// While read-only, ops that would modify the file system fail with EROFS
+// without being passed on, as they would on a file system mounted with
+// MountConfig.ReadOnly: creating, linking, renaming and removing names,
+// writing, truncating, fallocate, copy_file_range, changing attributes and
+// extended attributes, and opening a file for writing or with O_TRUNC. Handles
+// opened for writing before the switch stay open, but writes through them
+// fail.
+//
+// Unlike remounting with MS_RDONLY, which the fusermount(1) used by
+// unprivileged daemons can't do, the kernel isn't told about the switch, so
+// e.g. the mount is still listed as read-write. For the same reason,
+// access(2) with W_OK fails with EROFS only if the file system is mounted
+// with MountConfig.DisableDefaultPermissions, so that the kernel sends
+// AccessOp; the other checks are passed on, and allowed if the wrapped file
+// system doesn't implement Access.
+type ReadOnlyFileSystem struct {
+	FileSystem
+	cfg ReadOnlyConfig
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	readOnly bool
+
+	// The inode of each handle opened for writing, and the number of times it
+	// has been issued and not yet released.
+	//
+	// GUARDED_BY(mu)
+	writers map[fuseops.HandleID]*writerHandle
+}
+
+var _ FileSystem = &ReadOnlyFileSystem{}
+
+// NewReadOnlyFileSystem wraps the supplied file system so that it can be made
+// read-only. See ReadOnlyFileSystem.
+func NewReadOnlyFileSystem(
+	wrapped FileSystem,
+	cfg ReadOnlyConfig) *ReadOnlyFileSystem {
+	return &ReadOnlyFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		readOnly:   cfg.ReadOnly,
+		writers:    make(map[fuseops.HandleID]*writerHandle),
+	}
+}
+
+// ReadOnly reports whether the file system is currently read-only.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) ReadOnly() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.readOnly
+}
+
+// SetReadOnly switches the file system to read-only or back to read-write.
+//
+// When switching to read-only with a Notifier configured, the kernel is first
+// asked to write back the cached pages of each file open for writing, and the
+// first error doing so is returned, though the switch is made regardless.
+// Writes that race with the switch may still be rejected after having been
+// accepted into the kernel's cache. Since the kernel writes back pages by
+// sending ops, SetReadOnly must not be called while serving an op.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) SetReadOnly(readOnly bool) error {
+	var err error
+	if readOnly && fs.cfg.Notifier != nil {
+		err = fs.writeBack()
+	}
+
+	fs.mu.Lock()
+	fs.readOnly = readOnly
+	fs.mu.Unlock()
+
+	return err
+}
+
+// Ask the kernel to write back the pages of the files open for writing.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) writeBack() error {
+	fs.mu.Lock()
+	inodes := make(map[fuseops.InodeID]struct{})
+	for _, h := range fs.writers {
+		inodes[h.inode] = struct{}{}
+	}
+	fs.mu.Unlock()
+
+	var firstErr error
+	for inode := range inodes {
+		// Invalidating an inode's pages writes back the dirty ones first. The
+		// kernel may have forgotten the inode if the handle was just released.
+		err := fs.cfg.Notifier.InvalidateInode(inode, 0, 0)
+		if err != nil && !errors.Is(err, syscall.ENOENT) && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Return EROFS if the file system is read-only.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) check() error {
+	if fs.ReadOnly() {
+		return syscall.EROFS
+	}
+
+	return nil
+}
+
+// Like check, but for opening a file with the given flags.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) checkOpen(flags fusekernel.OpenFlags) error {
+	if isWriter(flags) || flags.IsTruncate() {
+		return fs.check()
+	}
+
+	return nil
+}
+
+// Record a handle issued by the wrapped file system, if it was opened for
+// writing.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadOnlyFileSystem) opened(
+	inode fuseops.InodeID,
+	handle fuseops.HandleID,
+	flags fusekernel.OpenFlags,
+	err error) error {
+	if err != nil || !isWriter(flags) {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	h := fs.writers[handle]
+	if h == nil {
+		h = &writerHandle{inode: inode}
+		fs.writers[handle] = h
+	}
+
+	h.refs++
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// FileSystem methods
+////////////////////////////////////////////////////////////////////////
+
+func (fs *ReadOnlyFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkDir(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkNode(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	err := fs.FileSystem.CreateFile(ctx, op)
+	return fs.opened(op.Entry.Child, op.Handle, op.OpenFlags, err)
+}
+
+func (fs *ReadOnlyFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	err := fs.FileSystem.CreateTmpfile(ctx, op)
+	return fs.opened(op.Entry.Child, op.Handle, op.OpenFlags, err)
+}
+
+func (fs *ReadOnlyFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateSymlink(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateLink(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Rename(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.RmDir(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Unlink(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	if err := fs.checkOpen(op.OpenFlags); err != nil {
+		return err
+	}
+
+	err := fs.FileSystem.OpenFile(ctx, op)
+	return fs.opened(op.Inode, op.Handle, op.OpenFlags, err)
+}
+
+func (fs *ReadOnlyFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.WriteFile(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	fs.mu.Lock()
+	if h := fs.writers[op.Handle]; h != nil {
+		if h.refs--; h.refs == 0 {
+			delete(fs.writers, op.Handle)
+		}
+	}
+	fs.mu.Unlock()
+
+	return fs.FileSystem.ReleaseFileHandle(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SetXattr(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.RemoveXattr(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) Access(
+	ctx context.Context,
+	op *fuseops.AccessOp) error {
+	if op.Mask&unix.W_OK != 0 {
+		if err := fs.check(); err != nil {
+			return err
+		}
+	}
+
+	// Don't let ENOSYS stop the kernel from asking again once read-only.
+	err := fs.FileSystem.Access(ctx, op)
+	if errors.Is(err, syscall.ENOSYS) {
+		return nil
+	}
+
+	return err
+}
+
+func (fs *ReadOnlyFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.Fallocate(ctx, op)
+}
+
+func (fs *ReadOnlyFileSystem) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	if err := fs.check(); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CopyFileRange(ctx, op)
+}
@@ -0,0 +1,121 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	fs := NewReadOnlyFileSystem(&handlesFS{}, ReadOnlyConfig{})
+
+	// Ops that modify the file system, which handlesFS doesn't implement.
+	modify := map[string]func() error{
+		"MkDir":  func() error { return fs.MkDir(ctx, &fuseops.MkDirOp{}) },
+		"Unlink": func() error { return fs.Unlink(ctx, &fuseops.UnlinkOp{}) },
+		"Rename": func() error { return fs.Rename(ctx, &fuseops.RenameOp{}) },
+		"WriteFile": func() error {
+			return fs.WriteFile(ctx, &fuseops.WriteFileOp{})
+		},
+		"SetInodeAttributes": func() error {
+			return fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{})
+		},
+		"SetXattr": func() error { return fs.SetXattr(ctx, &fuseops.SetXattrOp{}) },
+	}
+
+	open := func(flags fusekernel.OpenFlags) error {
+		op := &fuseops.OpenFileOp{Inode: 7, OpenFlags: flags}
+		return fs.OpenFile(ctx, op)
+	}
+
+	check := func(wantModify error, wantWriteOpen error) {
+		t.Helper()
+		for name, f := range modify {
+			if err := f(); err != wantModify {
+				t.Errorf("ReadOnly %v: %s: got %v, want %v", fs.ReadOnly(), name, err, wantModify)
+			}
+		}
+
+		if err := open(fusekernel.OpenReadOnly); err != nil {
+			t.Errorf("ReadOnly %v: read-only open: %v", fs.ReadOnly(), err)
+		}
+
+		for _, flags := range []fusekernel.OpenFlags{
+			fusekernel.OpenWriteOnly,
+			fusekernel.OpenReadWrite,
+			fusekernel.OpenReadOnly | fusekernel.OpenTruncate,
+		} {
+			if err := open(flags); err != wantWriteOpen {
+				t.Errorf("ReadOnly %v: open %v: got %v, want %v", fs.ReadOnly(), flags, err, wantWriteOpen)
+			}
+		}
+	}
+
+	// Writable to begin with.
+	check(syscall.ENOSYS, nil)
+
+	// Read-only.
+	if err := fs.SetReadOnly(true); err != nil {
+		t.Fatalf("SetReadOnly: %v", err)
+	}
+
+	check(syscall.EROFS, syscall.EROFS)
+
+	// Checks for write access fail too, and others are allowed even though
+	// handlesFS doesn't implement Access, so that the kernel keeps asking.
+	if err := fs.Access(ctx, &fuseops.AccessOp{Mask: unix.W_OK}); err != syscall.EROFS {
+		t.Errorf("Access(W_OK): got %v, want EROFS", err)
+	}
+
+	if err := fs.Access(ctx, &fuseops.AccessOp{Mask: unix.R_OK}); err != nil {
+		t.Errorf("Access(R_OK): %v", err)
+	}
+
+	// And back.
+	if err := fs.SetReadOnly(false); err != nil {
+		t.Fatalf("SetReadOnly: %v", err)
+	}
+
+	check(syscall.ENOSYS, nil)
+}
+
+func TestReadOnly_Writers(t *testing.T) {
+	ctx := context.Background()
+	fs := NewReadOnlyFileSystem(&handlesFS{}, ReadOnlyConfig{})
+
+	// A read-only open isn't tracked, but a write open and a create are.
+	r := &fuseops.OpenFileOp{Inode: 7, OpenFlags: fusekernel.OpenReadOnly}
+	w := &fuseops.OpenFileOp{Inode: 7, OpenFlags: fusekernel.OpenWriteOnly}
+	c := &fuseops.CreateFileOp{OpenFlags: fusekernel.OpenReadWrite}
+	for _, err := range []error{
+		fs.OpenFile(ctx, r),
+		fs.OpenFile(ctx, w),
+		fs.CreateFile(ctx, c),
+	} {
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+	}
+
+	if got := len(fs.writers); got != 2 {
+		t.Fatalf("Got %d writers, want 2", got)
+	}
+
+	if h := fs.writers[c.Handle]; h == nil || h.inode != 5 {
+		t.Errorf("Create handle: got %+v, want inode 5", h)
+	}
+
+	// Releasing them forgets them.
+	for _, h := range []fuseops.HandleID{r.Handle, w.Handle, c.Handle} {
+		fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: h})
+	}
+
+	if got := len(fs.writers); got != 0 {
+		t.Errorf("Got %d writers after release, want 0", got)
+	}
+}
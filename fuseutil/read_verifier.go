@@ -0,0 +1,165 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// ReadVerifyConfig configures a ReadVerifyingFileSystem.
+type ReadVerifyConfig struct {
+	// Check the data returned by the wrapped file system for a read, returning
+	// an error if it doesn't match what the backend says it should be, e.g.
+	// because it doesn't match a checksum or ETag recorded for the object or
+	// block. Must be non-nil.
+	Verify func(ctx context.Context, op *fuseops.ReadFileOp, data []byte) error
+
+	// Called after a mismatch, before the read is retried, to discard anything
+	// the wrapped file system has cached for the inode, so that the retry
+	// fetches the data afresh. May be nil.
+	Invalidate func(ctx context.Context, op *fuseops.ReadFileOp)
+
+	// If non-nil, used to invalidate the kernel's page cache for the inode
+	// after a mismatch, since other pages of the file read earlier may be just
+	// as stale. This happens asynchronously, as the kernel can't invalidate
+	// pages while the read is outstanding.
+	Notifier *fuse.Notifier
+
+	// The number of times a read is retried after a mismatch before it fails
+	// with EIO. If zero, 2 is used.
+	Retries int
+}
+
+// ReadVerifyingFileSystem is a FileSystem that checks the data returned by
+// each read against what the backend says it should be, using a pluggable
+// verifier, and retries reads that don't match after invalidating caches.
+// This is useful when serving content from an eventually consistent store,
+// such as some object stores, in which the data and the metadata used to
+// validate it may briefly disagree. Create one with
+// NewReadVerifyingFileSystem.
+type ReadVerifyingFileSystem struct {
+	FileSystem
+	cfg ReadVerifyConfig
+
+	mu    sync.Mutex
+	stats ReadVerifyStats // GUARDED_BY(mu)
+}
+
+// ReadVerifyStats describes the reads checked by a ReadVerifyingFileSystem.
+type ReadVerifyStats struct {
+	// The number of reads whose data didn't match, including retries.
+	Mismatches uint64
+
+	// The number of reads that failed because they still didn't match after
+	// being retried.
+	Failures uint64
+}
+
+// NewReadVerifyingFileSystem wraps the supplied file system, verifying reads
+// as described on ReadVerifyingFileSystem.
+func NewReadVerifyingFileSystem(
+	wrapped FileSystem,
+	cfg ReadVerifyConfig) *ReadVerifyingFileSystem {
+	if cfg.Retries == 0 {
+		cfg.Retries = 2
+	}
+
+	return &ReadVerifyingFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Stats returns a snapshot of the verification statistics.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ReadVerifyingFileSystem) Stats() ReadVerifyStats {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.stats
+}
+
+// Return the data read by a successful op.
+func readData(op *fuseops.ReadFileOp) []byte {
+	if op.Data == nil {
+		return op.Dst[:op.BytesRead]
+	}
+
+	if len(op.Data) == 1 {
+		return op.Data[0]
+	}
+
+	var data []byte
+	for _, b := range op.Data {
+		data = append(data, b...)
+	}
+
+	return data
+}
+
+func (fs *ReadVerifyingFileSystem) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	for attempt := 0; ; attempt++ {
+		if err := fs.FileSystem.ReadFile(ctx, op); err != nil {
+			return err
+		}
+
+		verifyErr := fs.cfg.Verify(ctx, op, readData(op))
+		if verifyErr == nil {
+			return nil
+		}
+
+		fs.mu.Lock()
+		fs.stats.Mismatches++
+		if attempt == fs.cfg.Retries {
+			fs.stats.Failures++
+		}
+		fs.mu.Unlock()
+
+		// Discard the result of the failed attempt, including any callback
+		// that would have released its buffers after the reply.
+		if op.Callback != nil {
+			op.Callback()
+			op.Callback = nil
+		}
+
+		op.BytesRead = 0
+		op.Data = nil
+
+		if fs.cfg.Invalidate != nil {
+			fs.cfg.Invalidate(ctx, op)
+		}
+
+		if fs.cfg.Notifier != nil {
+			go fs.cfg.Notifier.InvalidateInode(op.Inode, 0, 0)
+		}
+
+		if attempt == fs.cfg.Retries {
+			return fmt.Errorf(
+				"%w: read of inode %d at offset %d: %v",
+				syscall.EIO,
+				op.Inode,
+				op.Offset,
+				verifyErr)
+		}
+	}
+}
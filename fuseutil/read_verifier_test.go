@@ -0,0 +1,84 @@
+package fuseutil
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system whose reads return successive versions of the content, as an
+// eventually consistent store might.
+type versionedFS struct {
+	NotImplementedFileSystem
+	versions []string
+	reads    int
+}
+
+func (fs *versionedFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	v := fs.versions[fs.reads]
+	if fs.reads < len(fs.versions)-1 {
+		fs.reads++
+	}
+
+	// Alternate between the two ways of returning data.
+	if fs.reads%2 == 0 {
+		op.Data = [][]byte{[]byte(v[:2]), []byte(v[2:])}
+	} else {
+		op.BytesRead = copy(op.Dst, v)
+	}
+
+	return nil
+}
+
+func TestReadVerifier(t *testing.T) {
+	ctx := context.Background()
+	etag := md5.Sum([]byte("burrito"))
+	verify := func(ctx context.Context, op *fuseops.ReadFileOp, data []byte) error {
+		if md5.Sum(data) != etag {
+			return errors.New("ETag mismatch")
+		}
+
+		return nil
+	}
+
+	wrapped := &versionedFS{versions: []string{"taco", "enchilada", "burrito"}}
+	var invalidations int
+	fs := NewReadVerifyingFileSystem(wrapped, ReadVerifyConfig{
+		Verify:     verify,
+		Invalidate: func(context.Context, *fuseops.ReadFileOp) { invalidations++ },
+	})
+
+	// The third attempt succeeds.
+	op := &fuseops.ReadFileOp{Inode: 2, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if got := string(readData(op)); got != "burrito" || invalidations != 2 {
+		t.Errorf("ReadFile: got %q after %d invalidations", got, invalidations)
+	}
+
+	// With fewer retries, the read fails.
+	wrapped.reads = 0
+	fs = NewReadVerifyingFileSystem(wrapped, ReadVerifyConfig{
+		Verify:  verify,
+		Retries: 1,
+	})
+
+	op = &fuseops.ReadFileOp{Inode: 2, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); !errors.Is(err, syscall.EIO) {
+		t.Errorf("ReadFile: got %v, want EIO", err)
+	}
+
+	if op.BytesRead != 0 || op.Data != nil {
+		t.Errorf("failed read returned data: %d, %q", op.BytesRead, op.Data)
+	}
+
+	if stats := fs.Stats(); stats.Mismatches != 2 || stats.Failures != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
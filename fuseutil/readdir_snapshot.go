@@ -0,0 +1,196 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// ReadDirSnapshotFileSystem is a FileSystem that gives each open directory a
+// stable listing. Create one with NewReadDirSnapshotFileSystem.
+//
+// Without it, a file system that lists a directory incrementally, resuming
+// from the offset of each ReadDir, may skip or repeat entries when names are
+// created or removed concurrently, though many programs assume that readdir(3)
+// returns each entry that persisted throughout the listing exactly once. Here
+// OpenDir reads the whole listing from the wrapped file system, and ReadDir
+// serves it, using offsets into the listing. Reading again from offset zero
+// after the listing has been read from, as rewinddir(3) does, takes a fresh
+// listing, as Posix requires.
+//
+// The listing of each open directory is held in memory until the handle is
+// released, and the wrapped file system must issue distinct handles for
+// directories that are open at the same time. ReadDirPlus is passed through
+// unchanged: the kernel takes a reference to each inode it returns, so its
+// entries can't be served more than once.
+type ReadDirSnapshotFileSystem struct {
+	FileSystem
+
+	mu sync.Mutex
+
+	// The listing of each open directory.
+	//
+	// GUARDED_BY(mu)
+	snapshots map[fuseops.HandleID]*dirSnapshot
+}
+
+type dirSnapshot struct {
+	entries []Dirent
+
+	// Whether any entries have been served, so that a read from offset zero
+	// means a rewind.
+	read bool
+}
+
+// NewReadDirSnapshotFileSystem wraps the supplied file system, snapshotting
+// listings as described on ReadDirSnapshotFileSystem.
+func NewReadDirSnapshotFileSystem(wrapped FileSystem) *ReadDirSnapshotFileSystem {
+	return &ReadDirSnapshotFileSystem{
+		FileSystem: wrapped,
+		snapshots:  make(map[fuseops.HandleID]*dirSnapshot),
+	}
+}
+
+// Read the whole listing of the directory from the wrapped file system.
+func (fs *ReadDirSnapshotFileSystem) list(
+	ctx context.Context,
+	inode fuseops.InodeID,
+	handle fuseops.HandleID,
+	opCtx fuseops.OpContext) ([]Dirent, error) {
+	var entries []Dirent
+	buf := make([]byte, 8192)
+	var offset fuseops.DirOffset
+	for {
+		op := &fuseops.ReadDirOp{
+			Inode:     inode,
+			Handle:    handle,
+			Offset:    offset,
+			Dst:       buf,
+			OpContext: opCtx,
+		}
+
+		if err := fs.FileSystem.ReadDir(ctx, op); err != nil {
+			return nil, err
+		}
+
+		if op.BytesRead == 0 {
+			return entries, nil
+		}
+
+		n := len(entries)
+		entries = appendDirents(entries, buf[:op.BytesRead])
+		if len(entries) == n {
+			return entries, nil
+		}
+
+		offset = entries[len(entries)-1].Offset
+	}
+}
+
+// Parse the directory entries written by WriteDirent into buf, appending them
+// to entries.
+func appendDirents(entries []Dirent, buf []byte) []Dirent {
+	for {
+		d, n := ReadDirent(buf)
+		if n == 0 {
+			return entries
+		}
+
+		entries = append(entries, d)
+		buf = buf[n:]
+	}
+}
+
+func (fs *ReadDirSnapshotFileSystem) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	if err := fs.FileSystem.OpenDir(ctx, op); err != nil {
+		return err
+	}
+
+	entries, err := fs.list(ctx, op.Inode, op.Handle, op.OpContext)
+	if err != nil {
+		fs.FileSystem.ReleaseDirHandle(ctx, &fuseops.ReleaseDirHandleOp{
+			Handle:    op.Handle,
+			OpContext: op.OpContext,
+		})
+
+		return err
+	}
+
+	fs.mu.Lock()
+	fs.snapshots[op.Handle] = &dirSnapshot{entries: entries}
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *ReadDirSnapshotFileSystem) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	fs.mu.Lock()
+	s := fs.snapshots[op.Handle]
+	rewind := s != nil && op.Offset == 0 && s.read
+	fs.mu.Unlock()
+
+	// Handles not issued by OpenDir, e.g. with
+	// MountConfig.EnableNoOpendirSupport, are passed through.
+	if s == nil {
+		return fs.FileSystem.ReadDir(ctx, op)
+	}
+
+	if rewind {
+		entries, err := fs.list(ctx, op.Inode, op.Handle, op.OpContext)
+		if err != nil {
+			return err
+		}
+
+		s = &dirSnapshot{entries: entries}
+		fs.mu.Lock()
+		fs.snapshots[op.Handle] = s
+		fs.mu.Unlock()
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	s.read = true
+	for i := int(op.Offset); i >= 0 && i < len(s.entries); i++ {
+		d := s.entries[i]
+		d.Offset = fuseops.DirOffset(i + 1)
+
+		n := WriteDirent(op.Dst[op.BytesRead:], d)
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *ReadDirSnapshotFileSystem) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	fs.mu.Lock()
+	delete(fs.snapshots, op.Handle)
+	fs.mu.Unlock()
+
+	return fs.FileSystem.ReleaseDirHandle(ctx, op)
+}
@@ -0,0 +1,110 @@
+package fuseutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A directory listed by index, as many file systems do.
+type listFS struct {
+	NotImplementedFileSystem
+	names []string
+}
+
+func (fs *listFS) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) error {
+	op.Handle = 7
+	return nil
+}
+
+func (fs *listFS) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	for i := int(op.Offset); i < len(fs.names); i++ {
+		n := WriteDirent(op.Dst[op.BytesRead:], Dirent{
+			Offset: fuseops.DirOffset(i + 1),
+			Inode:  fuseops.InodeID(i + 2),
+			Name:   fs.names[i],
+			Type:   DT_File,
+		})
+
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *listFS) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) error {
+	return nil
+}
+
+// Read one entry at a time from the offset, returning the name and the offset
+// of the next entry.
+func readOne(t *testing.T, fs FileSystem, offset fuseops.DirOffset) (string, fuseops.DirOffset) {
+	op := &fuseops.ReadDirOp{Inode: 1, Handle: 7, Offset: offset, Dst: make([]byte, 32)}
+	if err := fs.ReadDir(context.Background(), op); err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	ds := appendDirents(nil, op.Dst[:op.BytesRead])
+	if len(ds) == 0 {
+		return "", 0
+	}
+
+	return ds[0].Name, ds[0].Offset
+}
+
+func readAll(t *testing.T, fs FileSystem, change func()) []string {
+	var names []string
+	var offset fuseops.DirOffset
+	for {
+		name, next := readOne(t, fs, offset)
+		if name == "" {
+			return names
+		}
+
+		names = append(names, name)
+		offset = next
+		if len(names) == 1 {
+			change()
+		}
+	}
+}
+
+func TestReadDirSnapshot(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &listFS{names: []string{"a", "b", "c", "d"}}
+	fs := NewReadDirSnapshotFileSystem(wrapped)
+
+	// Without snapshots, removing an entry already read makes another get
+	// skipped.
+	got := readAll(t, wrapped, func() { wrapped.names = wrapped.names[1:] })
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrapped listing: got %q, want %q", got, want)
+	}
+
+	wrapped.names = []string{"a", "b", "c", "d"}
+	if err := fs.OpenDir(ctx, &fuseops.OpenDirOp{Inode: 1}); err != nil {
+		t.Fatalf("OpenDir: %v", err)
+	}
+
+	got = readAll(t, fs, func() { wrapped.names = []string{"b", "c", "d", "e"} })
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listing: got %q, want %q", got, want)
+	}
+
+	// Rewinding takes a fresh listing.
+	got = readAll(t, fs, func() {})
+	if want := []string{"b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listing after rewind: got %q, want %q", got, want)
+	}
+
+	fs.ReleaseDirHandle(ctx, &fuseops.ReleaseDirHandleOp{Handle: 7})
+	if len(fs.snapshots) != 0 {
+		t.Errorf("%d snapshots after release", len(fs.snapshots))
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The setuid and setgid bits of a mode.
+const setIDBits = os.ModeSetuid | os.ModeSetgid
+
+// What a SetIDFileSystem does with the setuid and setgid bits of an op.
+type SetIDAction int
+
+const (
+	// Pass the op on unchanged.
+	SetIDHonor SetIDAction = iota
+
+	// Clear the bits, and pass on the rest of the op.
+	SetIDClear
+
+	// Fail the op with EPERM.
+	SetIDReject
+)
+
+// SetIDRequest describes an op that would leave a file with the setuid or
+// setgid bit set, for SetIDConfig.Policy.
+type SetIDRequest struct {
+	// The op: a *fuseops.CreateFileOp, CreateTmpfileOp, MkDirOp, MkNodeOp, or
+	// SetInodeAttributesOp.
+	Op interface{}
+
+	// The bits concerned: os.ModeSetuid, os.ModeSetgid, or both.
+	Bits os.FileMode
+
+	// Whether the op changes the owner or group of a file other than a
+	// directory, which already has the bits, rather than creating a file or
+	// setting its mode.
+	Chown bool
+
+	// Whether the caller is root, who may set the bits on any file. The kernel
+	// doesn't report the caller of a SetInodeAttributesOp if
+	// fuse.MountConfig.EnableIDMappedMounts is set, in which case this is
+	// false.
+	Privileged bool
+
+	// Copied from SetIDConfig.NoSuid.
+	NoSuid bool
+}
+
+// SetIDConfig configures a SetIDFileSystem.
+type SetIDConfig struct {
+	// Whether the file system is mounted nosuid, as fuse.Mount does by default
+	// unless the "suid" option is set, so that the kernel ignores the bits
+	// when executing files.
+	NoSuid bool
+
+	// Decide what to do with the bits. If nil, DefaultSetIDPolicy is used.
+	Policy func(ctx context.Context, r SetIDRequest) SetIDAction
+}
+
+// DefaultSetIDPolicy treats the bits as Linux's local file systems do, as far
+// as it can tell from the op: it clears them when the owner or group of a file
+// other than a directory changes, even if root changes it, and otherwise
+// honors them. Unlike those file systems, it doesn't clear the setgid bit set
+// by a caller outside the file's group, since ops don't include the caller's
+// groups.
+func DefaultSetIDPolicy(ctx context.Context, r SetIDRequest) SetIDAction {
+	if r.Chown {
+		return SetIDClear
+	}
+
+	return SetIDHonor
+}
+
+// SetIDFileSystem is a FileSystem that applies a policy to the setuid and
+// setgid bits of files created, chmodded, and chowned through it. Create one
+// with NewSetIDFileSystem.
+//
+// Without it, what happens to the bits is up to the wrapped file system, and
+// most simply store the mode they are given, unlike Linux's local file
+// systems. The kernel clears the bits itself on writes by callers other than
+// root, and when the owner or group changes, but only according to the mode
+// it has cached, and it honors the bits in new modes, whether or not the
+// mount is nosuid.
+//
+// When the owner or group of a file changes without a new mode, the wrapper
+// gets the file's attributes from the wrapped file system to find its bits.
+type SetIDFileSystem struct {
+	FileSystem
+	cfg SetIDConfig
+}
+
+// NewSetIDFileSystem wraps the supplied file system, applying the configured
+// policy as described on SetIDFileSystem.
+func NewSetIDFileSystem(
+	wrapped FileSystem,
+	cfg SetIDConfig) *SetIDFileSystem {
+	if cfg.Policy == nil {
+		cfg.Policy = DefaultSetIDPolicy
+	}
+
+	return &SetIDFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Apply the policy to the supplied mode, returning the mode to pass on.
+func (fs *SetIDFileSystem) apply(
+	ctx context.Context,
+	op interface{},
+	opCtx fuseops.OpContext,
+	mode os.FileMode,
+	chown bool) (os.FileMode, error) {
+	bits := mode & setIDBits
+	if bits == 0 {
+		return mode, nil
+	}
+
+	action := fs.cfg.Policy(ctx, SetIDRequest{
+		Op:         op,
+		Bits:       bits,
+		Chown:      chown,
+		Privileged: opCtx.Uid == 0,
+		NoSuid:     fs.cfg.NoSuid,
+	})
+
+	switch action {
+	case SetIDClear:
+		return mode &^ bits, nil
+
+	case SetIDReject:
+		return mode, syscall.EPERM
+	}
+
+	return mode, nil
+}
+
+func (fs *SetIDFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) (err error) {
+	if op.Mode, err = fs.apply(ctx, op, op.OpContext, op.Mode, false); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateFile(ctx, op)
+}
+
+func (fs *SetIDFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) (err error) {
+	if op.Mode, err = fs.apply(ctx, op, op.OpContext, op.Mode, false); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.CreateTmpfile(ctx, op)
+}
+
+func (fs *SetIDFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) (err error) {
+	if op.Mode, err = fs.apply(ctx, op, op.OpContext, op.Mode, false); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkDir(ctx, op)
+}
+
+func (fs *SetIDFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) (err error) {
+	if op.Mode, err = fs.apply(ctx, op, op.OpContext, op.Mode, false); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.MkNode(ctx, op)
+}
+
+func (fs *SetIDFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	chown := op.Uid != nil || op.Gid != nil
+	if chown && op.Mode == nil {
+		attrs := &fuseops.GetInodeAttributesOp{
+			Inode:     op.Inode,
+			OpContext: op.OpContext,
+		}
+
+		if err := fs.FileSystem.GetInodeAttributes(ctx, attrs); err != nil {
+			return err
+		}
+
+		mode := attrs.Attributes.Mode
+		if !mode.IsDir() {
+			cleared, err := fs.apply(ctx, op, op.OpContext, mode, true)
+			if err != nil {
+				return err
+			}
+
+			// Set the mode along with the owner if the bits were cleared.
+			if cleared != mode {
+				op.Mode = &cleared
+			}
+		}
+	} else if op.Mode != nil {
+		// The kernel sends modes with the file type.
+		mode, err := fs.apply(ctx, op, op.OpContext, *op.Mode, chown && !op.Mode.IsDir())
+		if err != nil {
+			return err
+		}
+
+		op.Mode = &mode
+	}
+
+	return fs.FileSystem.SetInodeAttributes(ctx, op)
+}
@@ -0,0 +1,131 @@
+package fuseutil
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system that records the modes it is given, and whose inodes have the
+// modes in the map.
+type modeFS struct {
+	NotImplementedFileSystem
+	modes map[fuseops.InodeID]os.FileMode
+	got   *os.FileMode
+}
+
+func (fs *modeFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes.Mode = fs.modes[op.Inode]
+	return nil
+}
+
+func (fs *modeFS) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) error {
+	fs.got = op.Mode
+	return nil
+}
+
+func (fs *modeFS) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	fs.got = &op.Mode
+	return nil
+}
+
+func (fs *modeFS) MkDir(ctx context.Context, op *fuseops.MkDirOp) error {
+	fs.got = &op.Mode
+	return nil
+}
+
+func TestSetIDFileSystem_Default(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &modeFS{modes: map[fuseops.InodeID]os.FileMode{
+		2: 0755 | os.ModeSetuid,
+		3: 0755 | os.ModeSetgid | os.ModeDir,
+	}}
+
+	fs := NewSetIDFileSystem(wrapped, SetIDConfig{})
+	uid := uint32(1000)
+
+	// New files keep their bits.
+	err := fs.CreateFile(ctx, &fuseops.CreateFileOp{Mode: 0755 | os.ModeSetuid})
+	if err != nil || *wrapped.got != 0755|os.ModeSetuid {
+		t.Errorf("CreateFile: got (%v, %v)", err, *wrapped.got)
+	}
+
+	// Chowning a file clears them, setting the mode along with the owner.
+	err = fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Inode: 2, Uid: &uid})
+	if err != nil || wrapped.got == nil || *wrapped.got != 0755 {
+		t.Errorf("Chown file: got (%v, %v)", err, wrapped.got)
+	}
+
+	// But not a directory.
+	err = fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Inode: 3, Uid: &uid})
+	if err != nil || wrapped.got != nil {
+		t.Errorf("Chown directory: got (%v, %v)", err, wrapped.got)
+	}
+
+	// Chmod keeps them.
+	mode := 0700 | os.ModeSetgid
+	err = fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Inode: 2, Mode: &mode})
+	if err != nil || *wrapped.got != 0700|os.ModeSetgid {
+		t.Errorf("Chmod: got (%v, %v)", err, *wrapped.got)
+	}
+}
+
+func TestSetIDFileSystem_Policy(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &modeFS{}
+	var requests []SetIDRequest
+	fs := NewSetIDFileSystem(wrapped, SetIDConfig{
+		NoSuid: true,
+		Policy: func(ctx context.Context, r SetIDRequest) SetIDAction {
+			requests = append(requests, r)
+			switch {
+			case r.Privileged:
+				return SetIDHonor
+			case r.Bits&os.ModeSetuid != 0:
+				return SetIDReject
+			default:
+				return SetIDClear
+			}
+		},
+	})
+
+	// Only modes with the bits are checked.
+	if err := fs.MkDir(ctx, &fuseops.MkDirOp{Mode: 0755}); err != nil {
+		t.Errorf("MkDir: %v", err)
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("Policy called for %v", requests)
+	}
+
+	// Non-root callers may not create setuid files, and their setgid bits are
+	// cleared.
+	op := &fuseops.CreateFileOp{
+		Mode:      0755 | os.ModeSetuid,
+		OpContext: fuseops.OpContext{Uid: 1000},
+	}
+
+	if err := fs.CreateFile(ctx, op); err != syscall.EPERM {
+		t.Errorf("CreateFile setuid: got %v, want EPERM", err)
+	}
+
+	op.Mode = 0755 | os.ModeSetgid
+	if err := fs.CreateFile(ctx, op); err != nil || *wrapped.got != 0755 {
+		t.Errorf("CreateFile setgid: got (%v, %v)", err, *wrapped.got)
+	}
+
+	// Root may do either.
+	mode := 0755 | os.ModeSetuid | os.ModeSetgid
+	err := fs.SetInodeAttributes(ctx, &fuseops.SetInodeAttributesOp{Mode: &mode})
+	if err != nil || *wrapped.got != mode {
+		t.Errorf("Chmod as root: got (%v, %v)", err, *wrapped.got)
+	}
+
+	r := requests[len(requests)-1]
+	if r.Bits != os.ModeSetuid|os.ModeSetgid || !r.Privileged || r.Chown || !r.NoSuid {
+		t.Errorf("Unexpected request: %+v", r)
+	}
+}
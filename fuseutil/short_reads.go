@@ -0,0 +1,315 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// ShortReadConfig configures a ShortReadFileSystem.
+type ShortReadConfig struct {
+	// If set, the data returned by a short read is padded with zeros up to the
+	// size last reported for the file, or the end of the read if sooner.
+	ZeroFill bool
+
+	// If non-nil, called for each short read, before it is padded. This is
+	// useful for detecting them in tests or debug builds, e.g. by logging or
+	// panicking.
+	OnShortRead func(ShortRead)
+}
+
+// ShortRead describes a read that returned less data than the size last
+// reported for the file implies it should have. See
+// ShortReadConfig.OnShortRead.
+type ShortRead struct {
+	Inode  fuseops.InodeID
+	Offset int64
+
+	// The number of bytes requested, the number the kernel expected given the
+	// reported size, and the number returned.
+	Size      int64
+	Expected  int64
+	BytesRead int
+
+	// The size last reported for the file.
+	ReportedSize uint64
+}
+
+// ShortReadFileSystem is a FileSystem that detects reads returning less data
+// than the size it last reported for the file. Create one with
+// NewShortReadFileSystem.
+//
+// The kernel treats a short read as the end of the file, and depending on its
+// version and the mount's settings may shrink its idea of the file's size to
+// match. A program reading with read(2) just sees a truncated file, but one
+// that has mapped the file with mmap(2) gets SIGBUS when it accesses a page
+// past the new end. Short reads happen when a file system's backend shrinks a
+// file unexpectedly, e.g. because another client truncated it. They can be
+// reported, and padded with zeros to the reported size so that programs see
+// the file they were told about.
+//
+// The reported size of an inode is taken from the attributes returned by
+// LookUpInode, GetInodeAttributes, SetInodeAttributes, CreateFile,
+// CreateTmpfile and MkNode, reset by OpenFile with O_TRUNC, and extended by
+// writes and fallocate past it, as the kernel does. It is discarded when the kernel forgets the inode. Sizes
+// reported in ReadDirPlus entries are not seen; reads of inodes whose size
+// isn't known are passed through unchecked.
+type ShortReadFileSystem struct {
+	FileSystem
+	cfg ShortReadConfig
+
+	mu sync.Mutex
+
+	// The size last reported to the kernel for each inode.
+	//
+	// GUARDED_BY(mu)
+	sizes map[fuseops.InodeID]uint64
+}
+
+// NewShortReadFileSystem wraps the supplied file system, detecting short reads
+// as described on ShortReadFileSystem.
+func NewShortReadFileSystem(
+	wrapped FileSystem,
+	cfg ShortReadConfig) *ShortReadFileSystem {
+	return &ShortReadFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		sizes:      make(map[fuseops.InodeID]uint64),
+	}
+}
+
+// Record the size reported for an inode.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ShortReadFileSystem) setSize(inode fuseops.InodeID, size uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sizes[inode] = size
+}
+
+// Extend the size recorded for an inode, if known, to at least the supplied
+// size.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *ShortReadFileSystem) extend(inode fuseops.InodeID, size uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if old, ok := fs.sizes[inode]; ok && size > old {
+		fs.sizes[inode] = size
+	}
+}
+
+func (fs *ShortReadFileSystem) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	err := fs.FileSystem.LookUpInode(ctx, op)
+	if err == nil && op.Entry.Child != 0 {
+		fs.setSize(op.Entry.Child, op.Entry.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	err := fs.FileSystem.GetInodeAttributes(ctx, op)
+	if err == nil {
+		fs.setSize(op.Inode, op.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) Statx(
+	ctx context.Context,
+	op *fuseops.StatxOp) error {
+	err := fs.FileSystem.Statx(ctx, op)
+	if err == nil {
+		fs.setSize(op.Inode, op.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	err := fs.FileSystem.SetInodeAttributes(ctx, op)
+	if err == nil {
+		fs.setSize(op.Inode, op.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	if err == nil {
+		fs.setSize(op.Entry.Child, op.Entry.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	err := fs.FileSystem.CreateTmpfile(ctx, op)
+	if err == nil {
+		fs.setSize(op.Entry.Child, op.Entry.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	err := fs.FileSystem.MkNode(ctx, op)
+	if err == nil {
+		fs.setSize(op.Entry.Child, op.Entry.Attributes.Size)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	err := fs.FileSystem.OpenFile(ctx, op)
+	// With MountConfig.EnableAtomicTrunc, the kernel sends O_TRUNC here instead
+	// of a SetInodeAttributes.
+	if err == nil && op.OpenFlags.IsTruncate() {
+		fs.setSize(op.Inode, 0)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	err := fs.FileSystem.WriteFile(ctx, op)
+	if err == nil {
+		fs.extend(op.Inode, uint64(op.Offset)+uint64(len(op.Data)))
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	err := fs.FileSystem.Fallocate(ctx, op)
+	// Only allocating and zeroing without FallocateKeepSize extend the file.
+	if err == nil && op.Mode&^fuseops.FallocateZeroRange == 0 {
+		fs.extend(op.Inode, op.Offset+op.Length)
+	}
+
+	return err
+}
+
+func (fs *ShortReadFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	// The wrapped file system may reuse the ID of an inode it has forgotten.
+	fs.mu.Lock()
+	delete(fs.sizes, op.Inode)
+	fs.mu.Unlock()
+
+	return fs.FileSystem.ForgetInode(ctx, op)
+}
+
+func (fs *ShortReadFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	fs.mu.Lock()
+	for _, e := range op.Entries {
+		delete(fs.sizes, e.Inode)
+	}
+	fs.mu.Unlock()
+
+	return fs.FileSystem.BatchForget(ctx, op)
+}
+
+func (fs *ShortReadFileSystem) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	if err := fs.FileSystem.ReadFile(ctx, op); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	size, ok := fs.sizes[op.Inode]
+	fs.mu.Unlock()
+
+	if !ok || op.Offset < 0 || uint64(op.Offset) >= size {
+		return nil
+	}
+
+	// The kernel expects the whole read, unless it extends past the end of the
+	// file.
+	expected := op.Size
+	if rem := size - uint64(op.Offset); rem < uint64(expected) {
+		expected = int64(rem)
+	}
+
+	n := op.BytesRead
+	if op.Data != nil {
+		n = 0
+		for _, b := range op.Data {
+			n += len(b)
+		}
+	}
+
+	if int64(n) >= expected {
+		return nil
+	}
+
+	if fs.cfg.OnShortRead != nil {
+		fs.cfg.OnShortRead(ShortRead{
+			Inode:        op.Inode,
+			Offset:       op.Offset,
+			Size:         op.Size,
+			Expected:     expected,
+			BytesRead:    n,
+			ReportedSize: size,
+		})
+	}
+
+	if fs.cfg.ZeroFill {
+		zeros := int(expected) - n
+		switch {
+		case op.Data != nil:
+			op.Data = append(op.Data, make([]byte, zeros))
+
+		case len(op.Dst) >= int(expected):
+			clear(op.Dst[n:expected])
+			op.BytesRead = int(expected)
+
+		default:
+			op.Data = [][]byte{op.Dst[:n], make([]byte, zeros)}
+		}
+	}
+
+	return nil
+}
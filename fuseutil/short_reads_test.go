@@ -0,0 +1,119 @@
+package fuseutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// A file system whose file has shrunk since its size was reported.
+type shrunkFS struct {
+	NotImplementedFileSystem
+	vectored bool
+}
+
+func (fs *shrunkFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes.Size = 10
+	return nil
+}
+
+func (fs *shrunkFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *shrunkFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	contents := "taco"[min(op.Offset, 4):]
+	if fs.vectored {
+		op.Data = [][]byte{[]byte(contents)}
+	} else {
+		op.BytesRead = copy(op.Dst, contents)
+	}
+
+	return nil
+}
+
+func TestShortReads(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &shrunkFS{}
+
+	var shortReads []ShortRead
+	fs := NewShortReadFileSystem(wrapped, ShortReadConfig{
+		ZeroFill:    true,
+		OnShortRead: func(r ShortRead) { shortReads = append(shortReads, r) },
+	})
+
+	// Reads of an inode whose size hasn't been reported are passed through.
+	op := &fuseops.ReadFileOp{Inode: 2, Size: 16, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); err != nil || op.BytesRead != 4 {
+		t.Fatalf("ReadFile: %d, %v", op.BytesRead, err)
+	}
+
+	if err := fs.GetInodeAttributes(ctx, &fuseops.GetInodeAttributesOp{Inode: 2}); err != nil {
+		t.Fatalf("GetInodeAttributes: %v", err)
+	}
+
+	// Once it has, short reads are padded to the reported size.
+	op = &fuseops.ReadFileOp{Inode: 2, Offset: 2, Size: 16, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if got := string(op.Dst[:op.BytesRead]); got != "co\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("ReadFile: got %q", got)
+	}
+
+	want := ShortRead{Inode: 2, Offset: 2, Size: 16, Expected: 8, BytesRead: 2, ReportedSize: 10}
+	if len(shortReads) != 1 || shortReads[0] != want {
+		t.Errorf("unexpected short reads: %+v", shortReads)
+	}
+
+	// Vectored reads too.
+	wrapped.vectored = true
+	op = &fuseops.ReadFileOp{Inode: 2, Offset: 8, Size: 16}
+	if err := fs.ReadFile(ctx, op); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if got := string(readData(op)); got != "\x00\x00" {
+		t.Errorf("ReadFile: got %q", got)
+	}
+
+	// Once the kernel forgets the inode, its size is no longer known.
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 2, N: 1})
+	wrapped.vectored = false
+	op = &fuseops.ReadFileOp{Inode: 2, Size: 16, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); err != nil || op.BytesRead != 4 || len(shortReads) != 2 {
+		t.Errorf("ReadFile after forget: %d, %v, %d short reads", op.BytesRead, err, len(shortReads))
+	}
+}
+
+func TestShortReads_AtomicTruncate(t *testing.T) {
+	ctx := context.Background()
+
+	var shortReads []ShortRead
+	fs := NewShortReadFileSystem(&shrunkFS{}, ShortReadConfig{
+		OnShortRead: func(r ShortRead) { shortReads = append(shortReads, r) },
+	})
+
+	if err := fs.GetInodeAttributes(ctx, &fuseops.GetInodeAttributesOp{Inode: 2}); err != nil {
+		t.Fatalf("GetInodeAttributes: %v", err)
+	}
+
+	// Opening with O_TRUNC empties the file, so the size reported before no
+	// longer applies.
+	open := &fuseops.OpenFileOp{Inode: 2, OpenFlags: fusekernel.OpenTruncate}
+	if err := fs.OpenFile(ctx, open); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	op := &fuseops.ReadFileOp{Inode: 2, Size: 16, Dst: make([]byte, 16)}
+	if err := fs.ReadFile(ctx, op); err != nil || op.BytesRead != 4 {
+		t.Fatalf("ReadFile: %d, %v", op.BytesRead, err)
+	}
+
+	if len(shortReads) != 0 {
+		t.Errorf("unexpected short reads: %+v", shortReads)
+	}
+}
@@ -0,0 +1,576 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// NewSubtreeFileSystem returns a file system that exposes the directory at the
+// given slash-separated path within the wrapped file system as its root. Each
+// component of the path is resolved with LookUpInode.
+//
+// This allows one file system to back several mounts with different
+// subtrees, by creating a subtree file system for each. The subtree's root has
+// ID fuseops.RootInodeID in the view, and the wrapped file system's root has
+// the subtree root's ID, so the IDs of all other inodes are unchanged. A
+// lookup of ".." in the root fails, and ".." entries in its listing refer to
+// the root itself, so the view can't be escaped.
+//
+// Each view keeps track of the lookup counts the kernel holds through it. When
+// the view is destroyed it forgets those, and the reference to the root it
+// took on creation, rather than destroying the wrapped file system, which
+// remains the responsibility of its owner.
+func NewSubtreeFileSystem(
+	ctx context.Context,
+	wrapped FileSystem,
+	path string) (FileSystem, error) {
+	var root fuseops.InodeID = fuseops.RootInodeID
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+
+		op := &fuseops.LookUpInodeOp{Parent: root, Name: name}
+		err := wrapped.LookUpInode(ctx, op)
+		if err == nil && op.Entry.Child == 0 {
+			err = syscall.ENOENT
+		}
+
+		// Drop the reference to the intermediate directory.
+		if root != fuseops.RootInodeID {
+			wrapped.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: root, N: 1})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		root = op.Entry.Child
+		if !op.Entry.Attributes.Mode.IsDir() {
+			wrapped.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: root, N: 1})
+			return nil, syscall.ENOTDIR
+		}
+	}
+
+	return &subtreeFS{
+		wrapped: wrapped,
+		root:    root,
+		lookups: make(map[fuseops.InodeID]uint64),
+	}, nil
+}
+
+type subtreeFS struct {
+	wrapped FileSystem
+
+	// The wrapped file system's ID for the root of the view.
+	root fuseops.InodeID
+
+	mu sync.Mutex
+
+	// The lookup counts held by the kernel through this view, by wrapped ID.
+	//
+	// GUARDED_BY(mu)
+	lookups map[fuseops.InodeID]uint64
+}
+
+// Convert an inode ID between the view and the wrapped file system. The
+// conversion is its own inverse.
+func (fs *subtreeFS) swap(id fuseops.InodeID) fuseops.InodeID {
+	switch id {
+	case fuseops.RootInodeID:
+		return fs.root
+
+	case fs.root:
+		return fuseops.RootInodeID
+	}
+
+	return id
+}
+
+// Convert the supplied IDs, returning a function that converts them back.
+func (fs *subtreeFS) swapAll(ids ...*fuseops.InodeID) func() {
+	for _, id := range ids {
+		*id = fs.swap(*id)
+	}
+
+	return func() {
+		for _, id := range ids {
+			*id = fs.swap(*id)
+		}
+	}
+}
+
+// Record a lookup of the wrapped inode.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *subtreeFS) lookedUp(id fuseops.InodeID) {
+	fs.mu.Lock()
+	fs.lookups[id]++
+	fs.mu.Unlock()
+}
+
+// Record the kernel's new reference to the entry returned by a successful op,
+// and convert its ID for the view.
+func (fs *subtreeFS) entry(err error, e *fuseops.ChildInodeEntry) error {
+	if err == nil && e.Child != 0 {
+		fs.lookedUp(e.Child)
+		e.Child = fs.swap(e.Child)
+	}
+
+	return err
+}
+
+// Record a forget of the wrapped inode, returning the count that should be
+// passed on. The kernel never forgets more than it looked up, so this only
+// limits the damage if something else has gone wrong.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *subtreeFS) forgot(id fuseops.InodeID, n uint64) uint64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if n > fs.lookups[id] {
+		n = fs.lookups[id]
+	}
+
+	fs.lookups[id] -= n
+	if fs.lookups[id] == 0 {
+		delete(fs.lookups, id)
+	}
+
+	return n
+}
+
+// Convert the inode IDs in a buffer of fuse_dirent or (if plus is set)
+// fuse_direntplus structures filled in by the wrapped file system for the
+// directory dir, recording the lookups implied by fuse_direntplus.
+func (fs *subtreeFS) convertDirents(
+	buf []byte,
+	dir fuseops.InodeID,
+	plus bool) {
+	var header int
+	if plus {
+		header = int(unsafe.Sizeof(fusekernel.EntryOut{}))
+	}
+
+	const nodeidOffset = unsafe.Offsetof(fusekernel.EntryOut{}.Nodeid)
+	const inoOffset = unsafe.Offsetof(fusekernel.EntryOut{}.Attr) +
+		unsafe.Offsetof(fusekernel.EntryOut{}.Attr.Ino)
+
+	for len(buf) >= header+fusekernel.DirentSize {
+		d := buf[header:]
+		namelen := int(binary.NativeEndian.Uint32(d[16:]))
+		size := header + fusekernel.DirentSize + namelen
+		if size > len(buf) {
+			return
+		}
+
+		name := string(d[fusekernel.DirentSize : fusekernel.DirentSize+namelen])
+
+		// Don't let ".." lead out of the view.
+		if name == ".." && dir == fs.root {
+			binary.NativeEndian.PutUint64(d, uint64(fs.root))
+			if plus {
+				binary.NativeEndian.PutUint64(buf[nodeidOffset:], uint64(fs.root))
+				binary.NativeEndian.PutUint64(buf[inoOffset:], uint64(fs.root))
+			}
+		}
+
+		ino := fuseops.InodeID(binary.NativeEndian.Uint64(d))
+		binary.NativeEndian.PutUint64(d, uint64(fs.swap(ino)))
+
+		if plus {
+			// The kernel takes a reference to each entry other than "." and "..".
+			nodeid := fuseops.InodeID(binary.NativeEndian.Uint64(buf[nodeidOffset:]))
+			if nodeid != 0 && name != "." && name != ".." {
+				fs.lookedUp(nodeid)
+			}
+
+			binary.NativeEndian.PutUint64(buf[nodeidOffset:], uint64(fs.swap(nodeid)))
+			ino := fuseops.InodeID(binary.NativeEndian.Uint64(buf[inoOffset:]))
+			binary.NativeEndian.PutUint64(buf[inoOffset:], uint64(fs.swap(ino)))
+		}
+
+		// Skip the padding following the entry, which may be missing at the end.
+		size = (size + 7) / 8 * 8
+		if size > len(buf) {
+			return
+		}
+
+		buf = buf[size:]
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// FileSystem methods
+////////////////////////////////////////////////////////////////////////
+
+func (fs *subtreeFS) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) error {
+	return fs.wrapped.StatFS(ctx, op)
+}
+
+func (fs *subtreeFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent == fuseops.RootInodeID && op.Name == ".." {
+		return syscall.ENOENT
+	}
+
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.LookUpInode(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.GetInodeAttributes(ctx, op)
+}
+
+func (fs *subtreeFS) Statx(
+	ctx context.Context,
+	op *fuseops.StatxOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Statx(ctx, op)
+}
+
+func (fs *subtreeFS) Access(
+	ctx context.Context,
+	op *fuseops.AccessOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Access(ctx, op)
+}
+
+func (fs *subtreeFS) Bmap(
+	ctx context.Context,
+	op *fuseops.BmapOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Bmap(ctx, op)
+}
+
+func (fs *subtreeFS) SetupMapping(
+	ctx context.Context,
+	op *fuseops.SetupMappingOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SetupMapping(ctx, op)
+}
+
+func (fs *subtreeFS) RemoveMapping(
+	ctx context.Context,
+	op *fuseops.RemoveMappingOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.RemoveMapping(ctx, op)
+}
+
+func (fs *subtreeFS) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SetInodeAttributes(ctx, op)
+}
+
+func (fs *subtreeFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	defer fs.swapAll(&op.Inode)()
+
+	op.N = fs.forgot(op.Inode, op.N)
+	if op.N == 0 {
+		return nil
+	}
+
+	return fs.wrapped.ForgetInode(ctx, op)
+}
+
+func (fs *subtreeFS) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	for i := range op.Entries {
+		e := &op.Entries[i]
+		e.Inode = fs.swap(e.Inode)
+		e.N = fs.forgot(e.Inode, e.N)
+	}
+
+	return fs.wrapped.BatchForget(ctx, op)
+}
+
+func (fs *subtreeFS) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.MkDir(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.MkNode(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.CreateFile(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.CreateTmpfile(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	defer fs.swapAll(&op.Parent, &op.Target)()
+	return fs.entry(fs.wrapped.CreateLink(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.entry(fs.wrapped.CreateSymlink(ctx, op), &op.Entry)
+}
+
+func (fs *subtreeFS) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	defer fs.swapAll(&op.OldParent, &op.NewParent)()
+	return fs.wrapped.Rename(ctx, op)
+}
+
+func (fs *subtreeFS) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.wrapped.RmDir(ctx, op)
+}
+
+func (fs *subtreeFS) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	defer fs.swapAll(&op.Parent)()
+	return fs.wrapped.Unlink(ctx, op)
+}
+
+func (fs *subtreeFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.OpenDir(ctx, op)
+}
+
+func (fs *subtreeFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	defer fs.swapAll(&op.Inode)()
+	err := fs.wrapped.ReadDir(ctx, op)
+	if err == nil && op.BytesRead <= len(op.Dst) {
+		fs.convertDirents(op.Dst[:op.BytesRead], op.Inode, false)
+	}
+
+	return err
+}
+
+func (fs *subtreeFS) ReadDirPlus(
+	ctx context.Context,
+	op *fuseops.ReadDirPlusOp) error {
+	defer fs.swapAll(&op.Inode)()
+	err := fs.wrapped.ReadDirPlus(ctx, op)
+	if err == nil && op.BytesRead <= len(op.Dst) {
+		fs.convertDirents(op.Dst[:op.BytesRead], op.Inode, true)
+	}
+
+	return err
+}
+
+func (fs *subtreeFS) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	return fs.wrapped.ReleaseDirHandle(ctx, op)
+}
+
+func (fs *subtreeFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.OpenFile(ctx, op)
+}
+
+func (fs *subtreeFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.ReadFile(ctx, op)
+}
+
+func (fs *subtreeFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.WriteFile(ctx, op)
+}
+
+func (fs *subtreeFS) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SyncFile(ctx, op)
+}
+
+func (fs *subtreeFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.FlushFile(ctx, op)
+}
+
+func (fs *subtreeFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return fs.wrapped.ReleaseFileHandle(ctx, op)
+}
+
+func (fs *subtreeFS) ReadSymlink(
+	ctx context.Context,
+	op *fuseops.ReadSymlinkOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.ReadSymlink(ctx, op)
+}
+
+func (fs *subtreeFS) RemoveXattr(
+	ctx context.Context,
+	op *fuseops.RemoveXattrOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.RemoveXattr(ctx, op)
+}
+
+func (fs *subtreeFS) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.GetXattr(ctx, op)
+}
+
+func (fs *subtreeFS) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.ListXattr(ctx, op)
+}
+
+func (fs *subtreeFS) SetXattr(
+	ctx context.Context,
+	op *fuseops.SetXattrOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SetXattr(ctx, op)
+}
+
+func (fs *subtreeFS) Fallocate(
+	ctx context.Context,
+	op *fuseops.FallocateOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Fallocate(ctx, op)
+}
+
+func (fs *subtreeFS) SyncFS(
+	ctx context.Context,
+	op *fuseops.SyncFSOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SyncFS(ctx, op)
+}
+
+func (fs *subtreeFS) Ioctl(
+	ctx context.Context,
+	op *fuseops.IoctlOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Ioctl(ctx, op)
+}
+
+func (fs *subtreeFS) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	defer fs.swapAll(&op.SrcInode, &op.DstInode)()
+	return fs.wrapped.CopyFileRange(ctx, op)
+}
+
+func (fs *subtreeFS) Lseek(
+	ctx context.Context,
+	op *fuseops.LseekOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Lseek(ctx, op)
+}
+
+func (fs *subtreeFS) Poll(
+	ctx context.Context,
+	op *fuseops.PollOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.Poll(ctx, op)
+}
+
+func (fs *subtreeFS) GetLk(
+	ctx context.Context,
+	op *fuseops.GetLkOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.GetLk(ctx, op)
+}
+
+func (fs *subtreeFS) SetLk(
+	ctx context.Context,
+	op *fuseops.SetLkOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SetLk(ctx, op)
+}
+
+func (fs *subtreeFS) SetLkW(
+	ctx context.Context,
+	op *fuseops.SetLkWOp) error {
+	defer fs.swapAll(&op.Inode)()
+	return fs.wrapped.SetLkW(ctx, op)
+}
+
+// Destroy forgets the view's references instead of destroying the wrapped
+// file system; see NewSubtreeFileSystem.
+func (fs *subtreeFS) Destroy() {
+	ctx := context.Background()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for id, n := range fs.lookups {
+		fs.wrapped.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: id, N: n})
+	}
+
+	fs.lookups = make(map[fuseops.InodeID]uint64)
+
+	if fs.root != fuseops.RootInodeID {
+		fs.wrapped.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: fs.root, N: 1})
+	}
+}
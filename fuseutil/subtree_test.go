@@ -0,0 +1,167 @@
+package fuseutil
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system containing the directory "a" (inode 2) holding the file "b"
+// (inode 3), which keeps track of lookup counts.
+type treeFS struct {
+	NotImplementedFileSystem
+	lookups map[fuseops.InodeID]int64
+
+	// The IDs of the last ops received.
+	lastInode fuseops.InodeID
+}
+
+var treeChildren = map[fuseops.InodeID]map[string]fuseops.InodeID{
+	1: {"a": 2},
+	2: {"b": 3},
+}
+
+func (fs *treeFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	child, ok := treeChildren[op.Parent][op.Name]
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	fs.lookups[child]++
+	op.Entry.Child = child
+	if _, ok := treeChildren[child]; ok {
+		op.Entry.Attributes.Mode = os.ModeDir | 0755
+	}
+
+	return nil
+}
+
+func (fs *treeFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	fs.lookups[op.Inode] -= int64(op.N)
+	return nil
+}
+
+func (fs *treeFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	fs.lastInode = op.Inode
+	return nil
+}
+
+func (fs *treeFS) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) error {
+	fs.lastInode = op.Inode
+	for i, d := range []Dirent{
+		{Inode: op.Inode, Name: ".", Type: DT_Directory},
+		{Inode: 1, Name: "..", Type: DT_Directory},
+		{Inode: 3, Name: "b", Type: DT_File},
+	} {
+		d.Offset = fuseops.DirOffset(i + 1)
+		op.BytesRead += WriteDirent(op.Dst[op.BytesRead:], d)
+	}
+
+	return nil
+}
+
+func (fs *treeFS) ReadDirPlus(ctx context.Context, op *fuseops.ReadDirPlusOp) error {
+	fs.lookups[3]++
+	op.BytesRead = WriteDirentPlus(op.Dst, DirentPlus{
+		Dirent: Dirent{Offset: 1, Inode: 3, Name: "b", Type: DT_File},
+		Entry:  fuseops.ChildInodeEntry{Child: 3},
+	})
+
+	return nil
+}
+
+// Return the inode numbers in a buffer of fuse_dirent structures.
+func direntInodes(buf []byte) map[string]uint64 {
+	m := make(map[string]uint64)
+	for len(buf) > 0 {
+		namelen := int(binary.NativeEndian.Uint32(buf[16:]))
+		m[string(buf[24:24+namelen])] = binary.NativeEndian.Uint64(buf)
+		buf = buf[(24+namelen+7)/8*8:]
+	}
+
+	return m
+}
+
+func TestSubtreeFileSystem(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &treeFS{lookups: make(map[fuseops.InodeID]int64)}
+
+	fs, err := NewSubtreeFileSystem(ctx, wrapped, "/a/")
+	if err != nil {
+		t.Fatalf("NewSubtreeFileSystem: %v", err)
+	}
+
+	// The root of the view is the wrapped directory.
+	attrsOp := &fuseops.GetInodeAttributesOp{Inode: fuseops.RootInodeID}
+	if err := fs.GetInodeAttributes(ctx, attrsOp); err != nil {
+		t.Fatalf("GetInodeAttributes: %v", err)
+	}
+
+	if wrapped.lastInode != 2 || attrsOp.Inode != fuseops.RootInodeID {
+		t.Errorf("wrapped got inode %d; op has %d afterward", wrapped.lastInode, attrsOp.Inode)
+	}
+
+	// Other IDs are unchanged.
+	lookUp := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: "b"}
+	if err := fs.LookUpInode(ctx, lookUp); err != nil {
+		t.Fatalf("LookUpInode: %v", err)
+	}
+
+	if lookUp.Entry.Child != 3 || lookUp.Parent != fuseops.RootInodeID {
+		t.Errorf("unexpected op after LookUpInode: %+v", lookUp)
+	}
+
+	// The view can't be escaped.
+	err = fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: ".."})
+	if err != syscall.ENOENT {
+		t.Errorf("LookUpInode(..): got %v, want ENOENT", err)
+	}
+
+	readDir := &fuseops.ReadDirOp{Inode: fuseops.RootInodeID, Dst: make([]byte, 4096)}
+	if err := fs.ReadDir(ctx, readDir); err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	got := direntInodes(readDir.Dst[:readDir.BytesRead])
+	if got["."] != 1 || got[".."] != 1 || got["b"] != 3 {
+		t.Errorf("unexpected dirent inodes: %v", got)
+	}
+
+	readDirPlus := &fuseops.ReadDirPlusOp{
+		ReadDirOp: fuseops.ReadDirOp{Inode: fuseops.RootInodeID, Dst: make([]byte, 4096)},
+	}
+
+	if err := fs.ReadDirPlus(ctx, readDirPlus); err != nil {
+		t.Fatalf("ReadDirPlus: %v", err)
+	}
+
+	// Destroying the view drops all of the references it handed out, including
+	// those from ReadDirPlus, and its own reference to its root.
+	fs.Destroy()
+	for id, n := range wrapped.lookups {
+		if n != 0 {
+			t.Errorf("inode %d: lookup count %d after Destroy", id, n)
+		}
+	}
+
+	// Only directories can be roots.
+	_, err = NewSubtreeFileSystem(ctx, wrapped, "a/b")
+	if err != syscall.ENOTDIR {
+		t.Errorf("NewSubtreeFileSystem(a/b): got %v, want ENOTDIR", err)
+	}
+
+	_, err = NewSubtreeFileSystem(ctx, wrapped, "a/c")
+	if err != syscall.ENOENT {
+		t.Errorf("NewSubtreeFileSystem(a/c): got %v, want ENOENT", err)
+	}
+
+	for id, n := range wrapped.lookups {
+		if n != 0 {
+			t.Errorf("inode %d: lookup count %d after failures", id, n)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Pressure is a snapshot of the pressure stall information (PSI) for a
+// cgroup. Each field is the "some" avg10 value reported by the kernel: the
+// percentage of the last ten seconds in which at least one task in the cgroup
+// was stalled waiting for the resource.
+type Pressure struct {
+	Memory float64
+	CPU    float64
+}
+
+// ThrottleConfig controls the behavior of a file system returned by
+// NewThrottledFileSystem.
+type ThrottleConfig struct {
+	// The avg10 percentage at or above which memory or CPU pressure causes
+	// low-priority ops to be throttled. If zero, 10 is used.
+	Threshold float64
+
+	// How long a low-priority op is delayed before being passed on to the
+	// wrapped file system while under pressure. If zero, 100ms is used.
+	Delay time.Duration
+
+	// If true, low-priority ops are failed with EAGAIN rather than delayed
+	// while under pressure. Note that shedding writeback writes causes the
+	// kernel to report an error to the application, so this is only
+	// appropriate when IsLowPriority is restricted to ops that may safely fail.
+	Shed bool
+
+	// How often pressure is re-read. Samples are taken lazily while serving
+	// ops, so an idle file system does no work. If zero, one second is used.
+	PollInterval time.Duration
+
+	// Decide whether an op is low priority. The op is one of the pointer types
+	// in package fuseops. If nil, writes issued by the kernel on behalf of no
+	// process (i.e. page cache writeback, which has a zero PID) and reads
+	// classified as readahead (see fuse.MountConfig.ClassifyReadahead) are low
+	// priority. Shedding the latter is always safe, since the kernel reads the
+	// pages again on demand.
+	IsLowPriority func(op interface{}) bool
+
+	// Read the current pressure. If nil, ReadCgroupPressure is used. Errors
+	// are treated as no pressure.
+	ReadPressure func() (Pressure, error)
+}
+
+// ThrottleStats describes the state of a ThrottledFileSystem.
+type ThrottleStats struct {
+	// The most recently sampled pressure, and whether it was over the
+	// configured threshold.
+	Pressure      Pressure
+	UnderPressure bool
+
+	// The number of low-priority ops that were delayed or shed.
+	Delayed uint64
+	Shed    uint64
+}
+
+// ThrottledFileSystem is a FileSystem that delays or sheds low-priority ops
+// while the cgroup the daemon runs in is under memory or CPU pressure, in
+// order to keep the mount responsive to interactive callers. Create one with
+// NewThrottledFileSystem.
+type ThrottledFileSystem struct {
+	FileSystem
+
+	cfg ThrottleConfig
+
+	mu         sync.Mutex
+	lastSample time.Time
+	stats      ThrottleStats
+}
+
+// NewThrottledFileSystem wraps the supplied file system, throttling
+// low-priority ops according to cfg.
+func NewThrottledFileSystem(
+	wrapped FileSystem,
+	cfg ThrottleConfig) *ThrottledFileSystem {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = 10
+	}
+
+	if cfg.Delay == 0 {
+		cfg.Delay = 100 * time.Millisecond
+	}
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Second
+	}
+
+	if cfg.IsLowPriority == nil {
+		cfg.IsLowPriority = isBackground
+	}
+
+	if cfg.ReadPressure == nil {
+		cfg.ReadPressure = ReadCgroupPressure
+	}
+
+	return &ThrottledFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+	}
+}
+
+// Stats returns a snapshot of the throttling state.
+func (fs *ThrottledFileSystem) Stats() ThrottleStats {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.stats
+}
+
+func isWriteback(op interface{}) bool {
+	if op, ok := op.(*fuseops.WriteFileOp); ok {
+		return op.OpContext.Pid == 0
+	}
+
+	return false
+}
+
+// Return true for writeback writes and readahead reads, which no process is
+// waiting for.
+func isBackground(op interface{}) bool {
+	if op, ok := op.(*fuseops.ReadFileOp); ok {
+		return op.Readahead
+	}
+
+	return isWriteback(op)
+}
+
+// Return true if the pressure is currently over the threshold, re-reading it
+// if the last sample is stale.
+func (fs *ThrottledFileSystem) underPressure() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(fs.lastSample) < fs.cfg.PollInterval {
+		return fs.stats.UnderPressure
+	}
+
+	fs.lastSample = now
+	p, err := fs.cfg.ReadPressure()
+	if err != nil {
+		p = Pressure{}
+	}
+
+	fs.stats.Pressure = p
+	fs.stats.UnderPressure =
+		p.Memory >= fs.cfg.Threshold || p.CPU >= fs.cfg.Threshold
+
+	return fs.stats.UnderPressure
+}
+
+// Throttle the op if it is low priority and we're under pressure. A non-nil
+// result should be returned to the kernel without calling the wrapped file
+// system.
+func (fs *ThrottledFileSystem) throttle(
+	ctx context.Context,
+	op interface{}) error {
+	if !fs.cfg.IsLowPriority(op) || !fs.underPressure() {
+		return nil
+	}
+
+	fs.mu.Lock()
+	if fs.cfg.Shed {
+		fs.stats.Shed++
+		fs.mu.Unlock()
+		return syscall.EAGAIN
+	}
+
+	fs.stats.Delayed++
+	fs.mu.Unlock()
+
+	t := time.NewTimer(fs.cfg.Delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (fs *ThrottledFileSystem) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	if err := fs.throttle(ctx, op); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.ReadFile(ctx, op)
+}
+
+func (fs *ThrottledFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	if err := fs.throttle(ctx, op); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.WriteFile(ctx, op)
+}
+
+func (fs *ThrottledFileSystem) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	if err := fs.throttle(ctx, op); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.SyncFile(ctx, op)
+}
+
+func (fs *ThrottledFileSystem) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	if err := fs.throttle(ctx, op); err != nil {
+		return err
+	}
+
+	return fs.FileSystem.FlushFile(ctx, op)
+}
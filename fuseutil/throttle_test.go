@@ -0,0 +1,95 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type recordingFS struct {
+	NotImplementedFileSystem
+	writes int
+}
+
+func (fs *recordingFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.writes++
+	return nil
+}
+
+func TestThrottledFileSystem(t *testing.T) {
+	pressure := Pressure{}
+	wrapped := &recordingFS{}
+	fs := NewThrottledFileSystem(wrapped, ThrottleConfig{
+		Delay:        time.Millisecond,
+		PollInterval: time.Nanosecond,
+		ReadPressure: func() (Pressure, error) { return pressure, nil },
+	})
+
+	ctx := context.Background()
+	writeback := &fuseops.WriteFileOp{}
+	foreground := &fuseops.WriteFileOp{
+		OpContext: fuseops.OpContext{Pid: 17},
+	}
+
+	// No pressure: nothing is throttled.
+	if err := fs.WriteFile(ctx, writeback); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if s := fs.Stats(); s.UnderPressure || s.Delayed != 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// Under memory pressure, writeback is delayed but foreground writes aren't.
+	pressure.Memory = 50
+	if err := fs.WriteFile(ctx, writeback); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := fs.WriteFile(ctx, foreground); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s := fs.Stats()
+	if !s.UnderPressure || s.Delayed != 1 || s.Pressure.Memory != 50 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	if wrapped.writes != 3 {
+		t.Errorf("wrapped file system saw %d writes, want 3", wrapped.writes)
+	}
+
+	// A cancelled context cuts the delay short.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	fs.cfg.Delay = time.Hour
+	if err := fs.WriteFile(cancelled, writeback); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	// In shedding mode, low-priority ops fail outright.
+	fs.cfg.Shed = true
+	if err := fs.WriteFile(ctx, writeback); err != syscall.EAGAIN {
+		t.Errorf("got %v, want EAGAIN", err)
+	}
+
+	if s := fs.Stats(); s.Shed != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// So do readahead reads, but not reads a process is waiting for.
+	readahead := &fuseops.ReadFileOp{Readahead: true}
+	if err := fs.ReadFile(ctx, readahead); err != syscall.EAGAIN {
+		t.Errorf("got %v, want EAGAIN", err)
+	}
+
+	if err := fs.ReadFile(ctx, &fuseops.ReadFileOp{}); err != syscall.ENOSYS {
+		t.Errorf("got %v, want ENOSYS from the wrapped file system", err)
+	}
+}
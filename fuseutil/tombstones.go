@@ -0,0 +1,484 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// ConsistencyModel describes how soon a backend's lookups and listings
+// reflect changes made through it. See TombstoneFileSystem.
+type ConsistencyModel int
+
+const (
+	// Lookups and listings reflect every completed change, so nothing need be
+	// remembered.
+	StrongConsistency ConsistencyModel = iota
+
+	// Lookups reflect created names at once, but may still find removed names
+	// for a while, as with object stores that offer read-after-write
+	// consistency only for new keys. Tombstones are kept for removed names.
+	CreateConsistency
+
+	// Lookups may miss created names, and find removed names, for a while.
+	// Tombstones are kept for removed names, and markers for created ones.
+	EventualConsistency
+)
+
+// TombstoneConfig configures a TombstoneFileSystem.
+type TombstoneConfig struct {
+	// The consistency model of the wrapped file system's backend.
+	Model ConsistencyModel
+
+	// How long tombstones and markers are kept after the change that made
+	// them: the time within which the backend is expected to converge. If
+	// zero, ten seconds.
+	Window time.Duration
+
+	// The clock used to expire tombstones and markers. If nil, the system
+	// clock is used.
+	Clock timeutil.Clock
+}
+
+// TombstoneFileSystem is a FileSystem that makes lookups immediately after a
+// name is removed or created reflect the change, even when the wrapped file
+// system's backend is eventually consistent. Create one with
+// NewTombstoneFileSystem.
+//
+// When a name is unlinked, removed, or renamed away, a tombstone is kept for
+// it, and until it expires LookUpInode fails with ENOENT without consulting
+// the wrapped file system, and ReadDir omits the name. ReadDirPlus is passed
+// through unchanged, since the kernel takes a reference to each inode it
+// returns. Under EventualConsistency, when a name is created, a marker is
+// kept recording the entry returned, and until it expires, LookUpInode
+// returns that entry, with attributes that the kernel mustn't cache, if the
+// wrapped file system doesn't find the name or finds another inode. The
+// marker of a renamed name moves with it; a name renamed without one is left
+// to the backend. Creating or renaming to a name discards its tombstone, and
+// removing it its marker.
+//
+// Lookups answered from a marker are subtracted from the counts passed to
+// ForgetInode and BatchForget, so that the wrapped file system sees forgets
+// only for the lookups it returned. Since the wrapped file system may release
+// an inode once the kernel forgets it, markers for an inode are discarded
+// when the kernel forgets it. Names changed other than through the kernel
+// aren't seen, and a tombstone hides a name recreated that way until it
+// expires.
+type TombstoneFileSystem struct {
+	FileSystem
+	cfg TombstoneConfig
+
+	mu sync.Mutex
+
+	// The expiration of the tombstone of each removed name, and the marker of
+	// each created name.
+	//
+	// GUARDED_BY(mu)
+	tombstones map[Dentry]time.Time
+	markers    map[Dentry]creationMarker
+
+	// The number of lookups of each inode answered from a marker and not yet
+	// forgotten.
+	//
+	// GUARDED_BY(mu)
+	answered map[fuseops.InodeID]uint64
+
+	// When next to discard expired tombstones and markers.
+	//
+	// GUARDED_BY(mu)
+	nextSweep time.Time
+}
+
+type creationMarker struct {
+	entry      fuseops.ChildInodeEntry
+	expiration time.Time
+}
+
+// NewTombstoneFileSystem wraps the supplied file system, keeping tombstones
+// and markers as described on TombstoneFileSystem.
+func NewTombstoneFileSystem(
+	wrapped FileSystem,
+	cfg TombstoneConfig) *TombstoneFileSystem {
+	if cfg.Window == 0 {
+		cfg.Window = 10 * time.Second
+	}
+
+	return &TombstoneFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		tombstones: make(map[Dentry]time.Time),
+		markers:    make(map[Dentry]creationMarker),
+		answered:   make(map[fuseops.InodeID]uint64),
+	}
+}
+
+func (fs *TombstoneFileSystem) now() time.Time {
+	if fs.cfg.Clock == nil {
+		return time.Now()
+	}
+
+	return fs.cfg.Clock.Now()
+}
+
+// Report whether the name has an unexpired tombstone.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *TombstoneFileSystem) buried(d Dentry, now time.Time) bool {
+	expiration, ok := fs.tombstones[d]
+	return ok && now.Before(expiration)
+}
+
+// Discard expired tombstones and markers, at most once per window.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *TombstoneFileSystem) sweep(now time.Time) {
+	if now.Before(fs.nextSweep) {
+		return
+	}
+
+	fs.nextSweep = now.Add(fs.cfg.Window)
+	for d, expiration := range fs.tombstones {
+		if !now.Before(expiration) {
+			delete(fs.tombstones, d)
+		}
+	}
+
+	for d, m := range fs.markers {
+		if !now.Before(m.expiration) {
+			delete(fs.markers, d)
+		}
+	}
+}
+
+// Record that the name was created with the entry returned by a successful
+// op.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *TombstoneFileSystem) created(
+	err error,
+	parent fuseops.InodeID,
+	name string,
+	entry *fuseops.ChildInodeEntry) error {
+	if err != nil || fs.cfg.Model == StrongConsistency {
+		return err
+	}
+
+	now := fs.now()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.sweep(now)
+	d := Dentry{parent, name}
+	delete(fs.tombstones, d)
+	if fs.cfg.Model == EventualConsistency && entry.Child != 0 {
+		fs.markers[d] = creationMarker{*entry, now.Add(fs.cfg.Window)}
+	}
+
+	return nil
+}
+
+// Record that the name was removed by a successful op.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *TombstoneFileSystem) removed(
+	err error,
+	parent fuseops.InodeID,
+	name string) error {
+	if err != nil || fs.cfg.Model == StrongConsistency {
+		return err
+	}
+
+	now := fs.now()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.sweep(now)
+	d := Dentry{parent, name}
+	delete(fs.markers, d)
+	fs.tombstones[d] = now.Add(fs.cfg.Window)
+	return nil
+}
+
+// Subtract the lookups answered from markers from the n being forgotten for
+// the inode, which the kernel no longer references, returning the rest.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *TombstoneFileSystem) forget(inode fuseops.InodeID, n uint64) uint64 {
+	for d, m := range fs.markers {
+		if m.entry.Child == inode {
+			delete(fs.markers, d)
+		}
+	}
+
+	answered := min(fs.answered[inode], n)
+	delete(fs.answered, inode)
+	return n - answered
+}
+
+// Remove the entries with tombstones from the buffer of fuse_dirent
+// structures, returning the length of the rest, and the offset of the last
+// entry, removed or not.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *TombstoneFileSystem) filter(
+	parent fuseops.InodeID,
+	buf []byte) (n int, last fuseops.DirOffset) {
+	entries := appendDirents(nil, buf)
+	if len(entries) == 0 {
+		return len(buf), 0
+	}
+
+	now := fs.now()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, e := range entries {
+		if !fs.buried(Dentry{parent, e.Name}, now) {
+			n += WriteDirent(buf[n:], e)
+		}
+	}
+
+	return n, entries[len(entries)-1].Offset
+}
+
+func (fs *TombstoneFileSystem) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if fs.cfg.Model == StrongConsistency {
+		return fs.FileSystem.LookUpInode(ctx, op)
+	}
+
+	d := Dentry{op.Parent, op.Name}
+	now := fs.now()
+	fs.mu.Lock()
+	buried := fs.buried(d, now)
+	m, marked := fs.markers[d]
+	marked = marked && now.Before(m.expiration)
+	fs.mu.Unlock()
+
+	if buried {
+		return syscall.ENOENT
+	}
+
+	err := fs.FileSystem.LookUpInode(ctx, op)
+	if !marked {
+		return err
+	}
+
+	switch {
+	case err == nil && op.Entry.Child == m.entry.Child:
+		return nil
+
+	// The backend still has an earlier inode under the name. Release the
+	// lookup of it, since the kernel won't see it.
+	case err == nil && op.Entry.Child != 0:
+		fs.FileSystem.ForgetInode(ctx, &fuseops.ForgetInodeOp{
+			Inode:     op.Entry.Child,
+			N:         1,
+			OpContext: op.OpContext,
+		})
+
+	case err == nil || errors.Is(err, syscall.ENOENT):
+
+	default:
+		return err
+	}
+
+	op.Entry = m.entry
+	op.Entry.AttributesExpiration = time.Time{}
+	if op.Entry.EntryExpiration.After(m.expiration) {
+		op.Entry.EntryExpiration = m.expiration
+	}
+
+	fs.mu.Lock()
+	fs.answered[op.Entry.Child]++
+	fs.mu.Unlock()
+	return nil
+}
+
+func (fs *TombstoneFileSystem) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	err := fs.FileSystem.MkDir(ctx, op)
+	return fs.created(err, op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *TombstoneFileSystem) MkNode(
+	ctx context.Context,
+	op *fuseops.MkNodeOp) error {
+	err := fs.FileSystem.MkNode(ctx, op)
+	return fs.created(err, op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *TombstoneFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	return fs.created(err, op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *TombstoneFileSystem) CreateSymlink(
+	ctx context.Context,
+	op *fuseops.CreateSymlinkOp) error {
+	err := fs.FileSystem.CreateSymlink(ctx, op)
+	return fs.created(err, op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *TombstoneFileSystem) CreateLink(
+	ctx context.Context,
+	op *fuseops.CreateLinkOp) error {
+	err := fs.FileSystem.CreateLink(ctx, op)
+	return fs.created(err, op.Parent, op.Name, &op.Entry)
+}
+
+func (fs *TombstoneFileSystem) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	err := fs.FileSystem.Rename(ctx, op)
+	if err != nil || fs.cfg.Model == StrongConsistency {
+		return err
+	}
+
+	from := Dentry{op.OldParent, op.OldName}
+	to := Dentry{op.NewParent, op.NewName}
+	if from == to {
+		return nil
+	}
+
+	now := fs.now()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.sweep(now)
+
+	// An exchange leaves both names in place, so only their markers move.
+	if op.Flags&fuseops.RenameExchange != 0 {
+		mFrom, okFrom := fs.markers[from]
+		mTo, okTo := fs.markers[to]
+		delete(fs.markers, from)
+		delete(fs.markers, to)
+		if okFrom {
+			fs.markers[to] = mFrom
+		}
+
+		if okTo {
+			fs.markers[from] = mTo
+		}
+
+		return nil
+	}
+
+	delete(fs.tombstones, to)
+	if m, ok := fs.markers[from]; ok {
+		fs.markers[to] = m
+	} else {
+		delete(fs.markers, to)
+	}
+
+	// A whiteout replaces the old name rather than removing it.
+	delete(fs.markers, from)
+	if op.Flags&fuseops.RenameWhiteout == 0 {
+		fs.tombstones[from] = now.Add(fs.cfg.Window)
+	}
+
+	return nil
+}
+
+func (fs *TombstoneFileSystem) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	err := fs.FileSystem.Unlink(ctx, op)
+	return fs.removed(err, op.Parent, op.Name)
+}
+
+func (fs *TombstoneFileSystem) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	err := fs.FileSystem.RmDir(ctx, op)
+	return fs.removed(err, op.Parent, op.Name)
+}
+
+func (fs *TombstoneFileSystem) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	if fs.cfg.Model == StrongConsistency {
+		return fs.FileSystem.ReadDir(ctx, op)
+	}
+
+	// An empty reply means the end of the directory, so read on if every
+	// entry read was removed.
+	for {
+		if err := fs.FileSystem.ReadDir(ctx, op); err != nil {
+			return err
+		}
+
+		if op.BytesRead == 0 || op.BytesRead > len(op.Dst) {
+			return nil
+		}
+
+		n, last := fs.filter(op.Inode, op.Dst[:op.BytesRead])
+		op.BytesRead = n
+		if n != 0 {
+			return nil
+		}
+
+		op.Offset = last
+	}
+}
+
+func (fs *TombstoneFileSystem) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.mu.Lock()
+	n := fs.forget(op.Inode, op.N)
+	fs.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+
+	forwarded := *op
+	forwarded.N = n
+	return fs.FileSystem.ForgetInode(ctx, &forwarded)
+}
+
+func (fs *TombstoneFileSystem) BatchForget(
+	ctx context.Context,
+	op *fuseops.BatchForgetOp) error {
+	forwarded := &fuseops.BatchForgetOp{OpContext: op.OpContext}
+	fs.mu.Lock()
+	for _, e := range op.Entries {
+		if n := fs.forget(e.Inode, e.N); n != 0 {
+			forwarded.Entries = append(forwarded.Entries, fuseops.BatchForgetEntry{
+				Inode: e.Inode,
+				N:     n,
+			})
+		}
+	}
+	fs.mu.Unlock()
+
+	if len(forwarded.Entries) == 0 {
+		return nil
+	}
+
+	return fs.FileSystem.BatchForget(ctx, forwarded)
+}
@@ -0,0 +1,197 @@
+package fuseutil
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+)
+
+// A file system whose backend never reflects changes: "a" to "c" always
+// exist, as inodes 2 to 4, and nothing else does. Created names are given
+// inode 10.
+type staleFS struct {
+	listFS
+	forgets map[fuseops.InodeID]uint64
+}
+
+func (fs *staleFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	for i, name := range fs.names {
+		if name == op.Name {
+			op.Entry.Child = fuseops.InodeID(i + 2)
+			return nil
+		}
+	}
+
+	return syscall.ENOENT
+}
+
+func (fs *staleFS) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	op.Entry.Child = 10
+	op.Entry.AttributesExpiration = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (fs *staleFS) Rename(ctx context.Context, op *fuseops.RenameOp) error {
+	return nil
+}
+
+func (fs *staleFS) Unlink(ctx context.Context, op *fuseops.UnlinkOp) error {
+	return nil
+}
+
+func (fs *staleFS) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) error {
+	fs.forgets[op.Inode] += op.N
+	return nil
+}
+
+func lookUpChild(fs FileSystem, name string) (fuseops.InodeID, error) {
+	op := &fuseops.LookUpInodeOp{Parent: 1, Name: name}
+	err := fs.LookUpInode(context.Background(), op)
+	return op.Entry.Child, err
+}
+
+func TestTombstones(t *testing.T) {
+	ctx := context.Background()
+	var clock timeutil.SimulatedClock
+	clock.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	wrapped := &staleFS{
+		listFS:  listFS{names: []string{"a", "b", "c"}},
+		forgets: make(map[fuseops.InodeID]uint64),
+	}
+
+	fs := NewTombstoneFileSystem(wrapped, TombstoneConfig{
+		Model:  EventualConsistency,
+		Window: time.Minute,
+		Clock:  &clock,
+	})
+
+	// Removed names are hidden from lookups and listings.
+	fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "b"})
+	if _, err := lookUpChild(fs, "b"); err != syscall.ENOENT {
+		t.Errorf("LookUp(b) after unlink: %v", err)
+	}
+
+	if got := readAll(t, fs, func() {}); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("Listing after unlink: %v", got)
+	}
+
+	// Created names are found, with attributes the kernel mustn't cache.
+	create := &fuseops.CreateFileOp{Parent: 1, Name: "taco"}
+	fs.CreateFile(ctx, create)
+	op := &fuseops.LookUpInodeOp{Parent: 1, Name: "taco"}
+	if err := fs.LookUpInode(ctx, op); err != nil || op.Entry.Child != 10 {
+		t.Errorf("LookUp(taco) after create: %v, %v", op.Entry.Child, err)
+	}
+
+	if !op.Entry.AttributesExpiration.IsZero() {
+		t.Errorf("AttributesExpiration: %v", op.Entry.AttributesExpiration)
+	}
+
+	// A name recreated over a tombstone is found, even though the backend
+	// still has the earlier inode, whose lookup is released.
+	fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: 1, Name: "b"})
+	if child, err := lookUpChild(fs, "b"); err != nil || child != 10 {
+		t.Errorf("LookUp(b) after recreate: %v, %v", child, err)
+	}
+
+	if wrapped.forgets[3] != 1 {
+		t.Errorf("Forgets of the earlier inode: %v", wrapped.forgets[3])
+	}
+
+	// Renamed markers move, leaving tombstones behind.
+	fs.Rename(ctx, &fuseops.RenameOp{OldParent: 1, OldName: "taco", NewParent: 1, NewName: "burrito"})
+	if _, err := lookUpChild(fs, "taco"); err != syscall.ENOENT {
+		t.Errorf("LookUp(taco) after rename: %v", err)
+	}
+
+	if child, err := lookUpChild(fs, "burrito"); err != nil || child != 10 {
+		t.Errorf("LookUp(burrito) after rename: %v, %v", child, err)
+	}
+
+	// The kernel forgets the two creates and the three lookups answered from
+	// markers, of which only the creates are passed on. Markers for the inode
+	// are then discarded.
+	fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: 10, N: 5})
+	if wrapped.forgets[10] != 2 {
+		t.Errorf("Forgets of the created inode: %v", wrapped.forgets[10])
+	}
+
+	if _, err := lookUpChild(fs, "burrito"); err != syscall.ENOENT {
+		t.Errorf("LookUp(burrito) after forget: %v", err)
+	}
+
+	// Tombstones expire with the window.
+	fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "c"})
+	clock.AdvanceTime(time.Minute)
+	if child, err := lookUpChild(fs, "c"); err != nil || child != 4 {
+		t.Errorf("LookUp(c) after expiration: %v, %v", child, err)
+	}
+}
+
+func TestTombstones_CreateConsistency(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &staleFS{
+		listFS:  listFS{names: []string{"a", "b", "c"}},
+		forgets: make(map[fuseops.InodeID]uint64),
+	}
+
+	fs := NewTombstoneFileSystem(wrapped, TombstoneConfig{Model: CreateConsistency})
+
+	// Creations are left to the backend, but removals are remembered.
+	fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: 1, Name: "taco"})
+	if _, err := lookUpChild(fs, "taco"); err != syscall.ENOENT {
+		t.Errorf("LookUp(taco) after create: %v", err)
+	}
+
+	fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: 1, Name: "a"})
+	if _, err := lookUpChild(fs, "a"); err != syscall.ENOENT {
+		t.Errorf("LookUp(a) after unlink: %v", err)
+	}
+}
+
+func TestTombstones_RenameFlags(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &staleFS{
+		listFS:  listFS{names: []string{"a", "b", "c"}},
+		forgets: make(map[fuseops.InodeID]uint64),
+	}
+
+	fs := NewTombstoneFileSystem(wrapped, TombstoneConfig{Model: EventualConsistency})
+
+	// Exchanged markers swap names, and neither name is tombstoned.
+	fs.CreateFile(ctx, &fuseops.CreateFileOp{Parent: 1, Name: "taco"})
+	fs.Rename(ctx, &fuseops.RenameOp{
+		OldParent: 1,
+		OldName:   "taco",
+		NewParent: 1,
+		NewName:   "a",
+		Flags:     fuseops.RenameExchange,
+	})
+
+	if child, err := lookUpChild(fs, "a"); err != nil || child != 10 {
+		t.Errorf("LookUp(a) after exchange: %v, %v", child, err)
+	}
+
+	if got := readAll(t, fs, func() {}); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Listing after exchange: %v", got)
+	}
+
+	// A whiteout is left visible at the old name.
+	fs.Rename(ctx, &fuseops.RenameOp{
+		OldParent: 1,
+		OldName:   "b",
+		NewParent: 1,
+		NewName:   "burrito",
+		Flags:     fuseops.RenameWhiteout,
+	})
+
+	if child, err := lookUpChild(fs, "b"); err != nil || child != 3 {
+		t.Errorf("LookUp(b) after whiteout: %v, %v", child, err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+	"syscall"
+)
+
+// WriteBudgetMode says what a WriteBudget does with writes that don't fit.
+type WriteBudgetMode int
+
+const (
+	// Wait for room, delaying the reply to the write and so the process that
+	// made it. This gives natural backpressure without errors.
+	WriteBudgetBlock WriteBudgetMode = iota
+
+	// Fail the write with ENOSPC.
+	WriteBudgetENOSPC
+
+	// Fail the write with EDQUOT.
+	WriteBudgetEDQUOT
+)
+
+// WriteBudgetConfig configures a WriteBudget.
+type WriteBudgetConfig struct {
+	// The number of bytes of deferred writes allowed. Must be positive.
+	Limit int64
+
+	// What to do with writes that don't fit.
+	Mode WriteBudgetMode
+
+	// Once the budget is full, writes keep being blocked or failed until the
+	// bytes in use fall to this level, so that a budget hovering around its
+	// limit doesn't alternate between accepting and refusing every other
+	// write. If zero, three quarters of Limit is used.
+	LowWater int64
+}
+
+// WriteBudgetStats describes the state of a WriteBudget.
+type WriteBudgetStats struct {
+	// The number of bytes of deferred writes, and whether the budget is full,
+	// i.e. has reached its limit and not yet drained to its low-water mark.
+	InUse int64
+	Full  bool
+
+	// The number of writes that waited for room or were failed.
+	Blocked uint64
+	Failed  uint64
+}
+
+// WriteBudget bounds the memory used by a file system that defers writes,
+// replying to WriteFileOps before the data reaches its backend (write-behind),
+// and decides what happens when the backend can't keep up. Create one with
+// NewWriteBudget.
+//
+// The file system calls Acquire for each write before queueing it, returning
+// any error to the kernel, and Release once the data has been written to the
+// backend or discarded:
+//
+//	if err := fs.budget.Acquire(ctx, int64(len(op.Data))); err != nil {
+//		return err
+//	}
+//
+//	fs.queue(op.Inode, op.Offset, bytes.Clone(op.Data))
+//
+// Blocking favors throughput and never fails a write that would eventually
+// succeed, while failing gives the process an immediate error, as a full local
+// disk would.
+type WriteBudget struct {
+	cfg WriteBudgetConfig
+
+	mu sync.Mutex
+
+	// Closed and replaced when the budget stops being full.
+	//
+	// GUARDED_BY(mu)
+	drained chan struct{}
+
+	// GUARDED_BY(mu)
+	stats WriteBudgetStats
+}
+
+// NewWriteBudget returns an empty budget.
+func NewWriteBudget(cfg WriteBudgetConfig) *WriteBudget {
+	if cfg.LowWater == 0 {
+		cfg.LowWater = cfg.Limit / 4 * 3
+	}
+
+	return &WriteBudget{
+		cfg:     cfg,
+		drained: make(chan struct{}),
+	}
+}
+
+// Stats returns a snapshot of the budget's state.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *WriteBudget) Stats() WriteBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Acquire takes room for a write of n bytes from the budget, waiting for it or
+// failing according to the budget's mode if there is none. A write larger than
+// the limit is accepted once the budget is empty. In blocking mode, Acquire
+// returns the context's error if it is cancelled while waiting, e.g. because
+// the write was interrupted.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *WriteBudget) Acquire(ctx context.Context, n int64) error {
+	counted := false
+	for {
+		b.mu.Lock()
+		s := &b.stats
+		if !s.Full && (s.InUse+n <= b.cfg.Limit || s.InUse == 0) {
+			s.InUse += n
+			b.mu.Unlock()
+			return nil
+		}
+
+		s.Full = true
+		switch b.cfg.Mode {
+		case WriteBudgetENOSPC, WriteBudgetEDQUOT:
+			s.Failed++
+			b.mu.Unlock()
+
+			if b.cfg.Mode == WriteBudgetEDQUOT {
+				return syscall.EDQUOT
+			}
+
+			return syscall.ENOSPC
+		}
+
+		if !counted {
+			s.Blocked++
+			counted = true
+		}
+
+		drained := b.drained
+		b.mu.Unlock()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns room for n bytes, taken by Acquire, to the budget.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *WriteBudget) Release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &b.stats
+	s.InUse -= n
+	if s.Full && s.InUse <= b.cfg.LowWater {
+		s.Full = false
+		close(b.drained)
+		b.drained = make(chan struct{})
+	}
+}
@@ -0,0 +1,70 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWriteBudget(t *testing.T) {
+	ctx := context.Background()
+
+	// Failing mode, with hysteresis.
+	b := NewWriteBudget(WriteBudgetConfig{Limit: 100, Mode: WriteBudgetENOSPC})
+	if err := b.Acquire(ctx, 90); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if err := b.Acquire(ctx, 20); err != syscall.ENOSPC {
+		t.Errorf("Acquire when full: got %v, want ENOSPC", err)
+	}
+
+	// Releasing a little isn't enough to accept writes again.
+	b.Release(10)
+	if err := b.Acquire(ctx, 5); err != syscall.ENOSPC {
+		t.Errorf("Acquire above low water: got %v, want ENOSPC", err)
+	}
+
+	b.Release(10)
+	if err := b.Acquire(ctx, 5); err != nil {
+		t.Errorf("Acquire below low water: %v", err)
+	}
+
+	want := WriteBudgetStats{InUse: 75, Failed: 2}
+	if s := b.Stats(); s != want {
+		t.Errorf("got stats %+v, want %+v", s, want)
+	}
+
+	// Blocking mode.
+	b = NewWriteBudget(WriteBudgetConfig{Limit: 100, LowWater: 50})
+	if err := b.Acquire(ctx, 200); err != nil {
+		t.Fatalf("Acquire larger than the limit when empty: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- b.Acquire(ctx, 10) }()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("Acquire didn't block: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	b.Release(200)
+	if err := <-acquired; err != nil {
+		t.Errorf("Acquire after release: %v", err)
+	}
+
+	// Waiting is cut short by cancellation.
+	b.Acquire(ctx, 90)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Acquire(cancelled, 10); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	if s := b.Stats(); s.Blocked != 2 || !s.Full || s.InUse != 100 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
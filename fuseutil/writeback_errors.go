@@ -0,0 +1,290 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// WritebackError describes a failed write issued by the kernel's writeback
+// cache. See WritebackErrorFileSystem.
+type WritebackError struct {
+	Inode  fuseops.InodeID
+	Offset int64
+	Size   int
+	Err    error
+}
+
+// WritebackErrorConfig configures a WritebackErrorFileSystem.
+type WritebackErrorConfig struct {
+	// If non-nil, called with each failed writeback write, after the write has
+	// been recorded as failed. It must not block.
+	OnError func(WritebackError)
+
+	// Decide whether a write was issued by the kernel's writeback cache rather
+	// than on behalf of a process. If nil, writes with a zero PID are.
+	IsWriteback func(*fuseops.WriteFileOp) bool
+}
+
+// WritebackErrorFileSystem is a FileSystem that remembers errors from writes
+// issued by the kernel's writeback cache (see
+// MountConfig.EnableWritebackCache), and reports them from later calls to
+// fsync(2) and close(2), which is otherwise impossible: the kernel discards
+// errors from writeback writes, as the process that made the corresponding
+// write(2) call has long since been told that it succeeded. Create one with
+// NewWritebackErrorFileSystem.
+//
+// Errors are reported with the same semantics as the kernel's errseq
+// mechanism for local file systems: an error recorded for an inode is
+// returned once by SyncFile or FlushFile for each handle that was open on the
+// inode when the error was recorded, and not for handles opened after it. If
+// several errors are recorded before a handle reports one, it reports the most
+// recent.
+//
+// In addition to the errors of writeback writes, the file system can record
+// errors it discovers by other means, e.g. when uploading dirty data to a
+// backend fails, with SetError. An inode's error is discarded once no handles
+// are open on it, as there is then nobody to report it to.
+type WritebackErrorFileSystem struct {
+	FileSystem
+	cfg WritebackErrorConfig
+
+	mu sync.Mutex
+
+	// The most recent error recorded for each inode, and the number of errors
+	// recorded for it so far. Inodes with no errors are absent.
+	//
+	// GUARDED_BY(mu)
+	errs map[fuseops.InodeID]*inodeErr
+
+	// The inode of each open handle, and the number of errors for that inode
+	// that the handle has seen or reported.
+	//
+	// GUARDED_BY(mu)
+	handles map[fuseops.HandleID]*handleErrs
+}
+
+type inodeErr struct {
+	err error
+	seq uint64
+}
+
+type handleErrs struct {
+	inode fuseops.InodeID
+	seen  uint64
+
+	// The number of unreleased issues of the handle.
+	refs int
+}
+
+// NewWritebackErrorFileSystem wraps the supplied file system, reporting
+// writeback errors as described on WritebackErrorFileSystem.
+func NewWritebackErrorFileSystem(
+	wrapped FileSystem,
+	cfg WritebackErrorConfig) *WritebackErrorFileSystem {
+	if cfg.IsWriteback == nil {
+		cfg.IsWriteback = func(op *fuseops.WriteFileOp) bool {
+			return isWriteback(op)
+		}
+	}
+
+	return &WritebackErrorFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		errs:       make(map[fuseops.InodeID]*inodeErr),
+		handles:    make(map[fuseops.HandleID]*handleErrs),
+	}
+}
+
+// SetError records an error for the supplied inode, to be reported by the
+// handles currently open on it as described on WritebackErrorFileSystem.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WritebackErrorFileSystem) SetError(inode fuseops.InodeID, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	e := fs.errs[inode]
+	if e == nil {
+		e = &inodeErr{}
+		fs.errs[inode] = e
+	}
+
+	e.err = err
+	e.seq++
+}
+
+// Err returns the most recent error recorded for the supplied inode, or nil if
+// there is none. It doesn't count as the error having been reported.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WritebackErrorFileSystem) Err(inode fuseops.InodeID) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if e := fs.errs[inode]; e != nil {
+		return e.err
+	}
+
+	return nil
+}
+
+// Record that a handle has been opened on the inode, if err is nil, returning
+// err. The handle sees errors recorded from now on.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WritebackErrorFileSystem) open(
+	inode fuseops.InodeID,
+	handle fuseops.HandleID,
+	err error) error {
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	h := fs.handles[handle]
+	if h == nil || h.inode != inode {
+		h = &handleErrs{inode: inode}
+		if e := fs.errs[inode]; e != nil {
+			h.seen = e.seq
+		}
+
+		fs.handles[handle] = h
+	}
+
+	h.refs++
+	return nil
+}
+
+// Return the error that the handle should report, if any, and mark it as
+// reported.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WritebackErrorFileSystem) report(
+	inode fuseops.InodeID,
+	handle fuseops.HandleID) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	e := fs.errs[inode]
+	h := fs.handles[handle]
+	if e == nil || h == nil || h.inode != inode || h.seen == e.seq {
+		return nil
+	}
+
+	h.seen = e.seq
+	return e.err
+}
+
+// Discard the inode's error if no handles are open on it, since there is then
+// nothing left to report it.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *WritebackErrorFileSystem) forgetErr(inode fuseops.InodeID) {
+	for _, h := range fs.handles {
+		if h.inode == inode {
+			return
+		}
+	}
+
+	delete(fs.errs, inode)
+}
+
+func (fs *WritebackErrorFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	return fs.open(op.Entry.Child, op.Handle, err)
+}
+
+func (fs *WritebackErrorFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	err := fs.FileSystem.CreateTmpfile(ctx, op)
+	return fs.open(op.Entry.Child, op.Handle, err)
+}
+
+func (fs *WritebackErrorFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	err := fs.FileSystem.OpenFile(ctx, op)
+	return fs.open(op.Inode, op.Handle, err)
+}
+
+func (fs *WritebackErrorFileSystem) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	err := fs.FileSystem.WriteFile(ctx, op)
+	if err != nil && fs.cfg.IsWriteback(op) {
+		fs.SetError(op.Inode, err)
+		if fs.cfg.OnError != nil {
+			fs.cfg.OnError(WritebackError{
+				Inode:  op.Inode,
+				Offset: op.Offset,
+				Size:   len(op.Data),
+				Err:    err,
+			})
+		}
+	}
+
+	return err
+}
+
+func (fs *WritebackErrorFileSystem) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	err := fs.FileSystem.SyncFile(ctx, op)
+	if err == nil || err == fuse.ENOSYS {
+		if reported := fs.report(op.Inode, op.Handle); reported != nil {
+			return reported
+		}
+	}
+
+	return err
+}
+
+func (fs *WritebackErrorFileSystem) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	err := fs.FileSystem.FlushFile(ctx, op)
+	if err == nil || err == fuse.ENOSYS {
+		if reported := fs.report(op.Inode, op.Handle); reported != nil {
+			return reported
+		}
+	}
+
+	return err
+}
+
+func (fs *WritebackErrorFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	fs.mu.Lock()
+	if h := fs.handles[op.Handle]; h != nil {
+		if h.refs--; h.refs == 0 {
+			delete(fs.handles, op.Handle)
+			fs.forgetErr(h.inode)
+		}
+	}
+	fs.mu.Unlock()
+
+	return fs.FileSystem.ReleaseFileHandle(ctx, op)
+}
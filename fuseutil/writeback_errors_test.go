@@ -0,0 +1,117 @@
+package fuseutil
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A file system whose writes fail with the configured error, and which issues
+// a new handle for each open.
+type failingWriteFS struct {
+	NotImplementedFileSystem
+	err  error
+	next fuseops.HandleID
+}
+
+func (fs *failingWriteFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	fs.next++
+	op.Handle = fs.next
+	return nil
+}
+
+func (fs *failingWriteFS) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
+	return fs.err
+}
+
+func (fs *failingWriteFS) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
+	return nil
+}
+
+func TestWritebackErrors(t *testing.T) {
+	ctx := context.Background()
+	var reported []WritebackError
+	wrapped := &failingWriteFS{err: syscall.EIO}
+	fs := NewWritebackErrorFileSystem(wrapped, WritebackErrorConfig{
+		OnError: func(e WritebackError) { reported = append(reported, e) },
+	})
+
+	open := func() fuseops.HandleID {
+		op := &fuseops.OpenFileOp{Inode: 2}
+		if err := fs.OpenFile(ctx, op); err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+
+		return op.Handle
+	}
+
+	sync := func(h fuseops.HandleID) error {
+		return fs.SyncFile(ctx, &fuseops.SyncFileOp{Inode: 2, Handle: h})
+	}
+
+	flush := func(h fuseops.HandleID) error {
+		// FlushFile isn't implemented by the wrapped file system.
+		err := fs.FlushFile(ctx, &fuseops.FlushFileOp{Inode: 2, Handle: h})
+		if err == syscall.ENOSYS {
+			err = nil
+		}
+
+		return err
+	}
+
+	h1, h2 := open(), open()
+
+	// Writes on behalf of a process fail as usual, and aren't recorded.
+	write := &fuseops.WriteFileOp{Inode: 2, Handle: h1, Data: []byte("taco")}
+	write.OpContext.Pid = 17
+	if err := fs.WriteFile(ctx, write); err != syscall.EIO {
+		t.Errorf("WriteFile: got %v, want EIO", err)
+	}
+
+	if err := sync(h1); err != nil || len(reported) != 0 {
+		t.Errorf("SyncFile after a process's write: %v, %v", err, reported)
+	}
+
+	// A writeback write fails, and the error is reported once to each handle
+	// open at the time.
+	write.OpContext.Pid = 0
+	write.Offset = 4096
+	fs.WriteFile(ctx, write)
+	if len(reported) != 1 || reported[0].Offset != 4096 || reported[0].Size != 4 {
+		t.Errorf("unexpected reports: %+v", reported)
+	}
+
+	h3 := open()
+	for _, tc := range []struct {
+		desc string
+		err  error
+		want error
+	}{
+		{"first sync of h1", sync(h1), syscall.EIO},
+		{"second sync of h1", sync(h1), nil},
+		{"flush of h2", flush(h2), syscall.EIO},
+		{"sync of h2", sync(h2), nil},
+		{"sync of h3", sync(h3), nil},
+	} {
+		if tc.err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, tc.err, tc.want)
+		}
+	}
+
+	// Errors can also be recorded by the file system.
+	fs.SetError(2, syscall.ENOSPC)
+	if err := sync(h3); err != syscall.ENOSPC {
+		t.Errorf("sync after SetError: got %v, want ENOSPC", err)
+	}
+
+	// The error is discarded once all handles are closed.
+	for _, h := range []fuseops.HandleID{h1, h2, h3} {
+		fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: h})
+	}
+
+	if err := fs.Err(2); err != nil {
+		t.Errorf("Err after release: got %v, want nil", err)
+	}
+}
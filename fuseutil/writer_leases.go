@@ -0,0 +1,257 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuseutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// WriterLeaseConfig configures a WriterLeaseFileSystem.
+type WriterLeaseConfig struct {
+	// Called when a file is opened for writing while no handles open for
+	// writing are open on it, e.g. to acquire a lease or oplock from the
+	// backend's server. An error fails the open. Must be non-nil.
+	OnFirstWriter func(ctx context.Context, inode fuseops.InodeID) error
+
+	// Called once the last handle open for writing on a file has been
+	// released, e.g. to release the lease. Must be non-nil.
+	OnLastWriter func(ctx context.Context, inode fuseops.InodeID)
+}
+
+// WriterLeaseFileSystem is a FileSystem that tells the file system when each
+// file gains its first writer and loses its last, by tracking the handles
+// opened for writing by OpenFile, CreateFile and CreateTmpfile and released by
+// ReleaseFileHandle. Create one with NewWriterLeaseFileSystem.
+//
+// For OpenFile, OnFirstWriter is called before the open is passed on, and if
+// the open then fails, OnLastWriter is called. For CreateFile and
+// CreateTmpfile, the inode is known only once the file has been created, so
+// OnFirstWriter is called afterwards, and if it fails, the new handle is
+// released and the file left in place. OnLastWriter is called after the wrapped
+// file system has released the handle, so it may e.g. have uploaded the file's
+// contents first. Calls for the same inode are never concurrent, and an open
+// for writing waits for the other opens and releases of the same file to
+// finish, so it never sees a lease being released. Handles opened read-only
+// aren't tracked, and the wrapped file system must issue distinct handles for
+// the files that are open at the same time.
+type WriterLeaseFileSystem struct {
+	FileSystem
+	cfg WriterLeaseConfig
+
+	mu sync.Mutex
+
+	// The state of each file with writers or an open or release in progress,
+	// and the inode of each handle open for writing.
+	//
+	// GUARDED_BY(mu)
+	leases  map[fuseops.InodeID]*writerLease
+	handles map[fuseops.HandleID]*writerHandle
+}
+
+type writerLease struct {
+	// Held while opening or releasing a handle for writing.
+	mu sync.Mutex
+
+	// The number of handles open for writing.
+	//
+	// GUARDED_BY(mu)
+	writers int
+
+	// The number of ops holding or waiting for mu.
+	//
+	// GUARDED_BY(WriterLeaseFileSystem.mu)
+	users int
+}
+
+type writerHandle struct {
+	inode fuseops.InodeID
+
+	// The number of unreleased issues of the handle.
+	refs int
+}
+
+// NewWriterLeaseFileSystem wraps the supplied file system, reporting writers
+// as described on WriterLeaseFileSystem.
+func NewWriterLeaseFileSystem(
+	wrapped FileSystem,
+	cfg WriterLeaseConfig) *WriterLeaseFileSystem {
+	return &WriterLeaseFileSystem{
+		FileSystem: wrapped,
+		cfg:        cfg,
+		leases:     make(map[fuseops.InodeID]*writerLease),
+		handles:    make(map[fuseops.HandleID]*writerHandle),
+	}
+}
+
+// Return the inode's lease state, locked. The caller must call unlock.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WriterLeaseFileSystem) lock(inode fuseops.InodeID) *writerLease {
+	fs.mu.Lock()
+	l := fs.leases[inode]
+	if l == nil {
+		l = &writerLease{}
+		fs.leases[inode] = l
+	}
+
+	l.users++
+	fs.mu.Unlock()
+
+	l.mu.Lock()
+	return l
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WriterLeaseFileSystem) unlock(inode fuseops.InodeID, l *writerLease) {
+	writers := l.writers
+	l.mu.Unlock()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if l.users--; l.users == 0 && writers == 0 {
+		delete(fs.leases, inode)
+	}
+}
+
+// Record that the handle was opened for writing on the inode.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *WriterLeaseFileSystem) opened(
+	inode fuseops.InodeID,
+	handle fuseops.HandleID) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	h := fs.handles[handle]
+	if h == nil {
+		h = &writerHandle{inode: inode}
+		fs.handles[handle] = h
+	}
+
+	h.refs++
+}
+
+func isWriter(flags fusekernel.OpenFlags) bool {
+	return !flags.IsReadOnly()
+}
+
+func (fs *WriterLeaseFileSystem) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	if !isWriter(op.OpenFlags) {
+		return fs.FileSystem.OpenFile(ctx, op)
+	}
+
+	l := fs.lock(op.Inode)
+	defer fs.unlock(op.Inode, l)
+
+	if l.writers == 0 {
+		if err := fs.cfg.OnFirstWriter(ctx, op.Inode); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.FileSystem.OpenFile(ctx, op); err != nil {
+		if l.writers == 0 {
+			fs.cfg.OnLastWriter(ctx, op.Inode)
+		}
+
+		return err
+	}
+
+	l.writers++
+	fs.opened(op.Inode, op.Handle)
+	return nil
+}
+
+func (fs *WriterLeaseFileSystem) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	err := fs.FileSystem.CreateFile(ctx, op)
+	if err != nil || !isWriter(op.OpenFlags) {
+		return err
+	}
+
+	return fs.created(ctx, op.Entry.Child, op.Handle, op.OpContext)
+}
+
+func (fs *WriterLeaseFileSystem) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	err := fs.FileSystem.CreateTmpfile(ctx, op)
+	if err != nil || !isWriter(op.OpenFlags) {
+		return err
+	}
+
+	return fs.created(ctx, op.Entry.Child, op.Handle, op.OpContext)
+}
+
+// Take a lease for a handle the wrapped file system opened for writing while
+// creating the inode, releasing the handle if that fails.
+func (fs *WriterLeaseFileSystem) created(
+	ctx context.Context,
+	inode fuseops.InodeID,
+	handle fuseops.HandleID,
+	opCtx fuseops.OpContext) error {
+	l := fs.lock(inode)
+	defer fs.unlock(inode, l)
+
+	if l.writers == 0 {
+		if err := fs.cfg.OnFirstWriter(ctx, inode); err != nil {
+			fs.FileSystem.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{
+				Handle:    handle,
+				OpContext: opCtx,
+			})
+
+			return err
+		}
+	}
+
+	l.writers++
+	fs.opened(inode, handle)
+	return nil
+}
+
+func (fs *WriterLeaseFileSystem) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	fs.mu.Lock()
+	h := fs.handles[op.Handle]
+	if h != nil {
+		if h.refs--; h.refs == 0 {
+			delete(fs.handles, op.Handle)
+		}
+	}
+	fs.mu.Unlock()
+
+	err := fs.FileSystem.ReleaseFileHandle(ctx, op)
+	if h == nil {
+		return err
+	}
+
+	l := fs.lock(h.inode)
+	defer fs.unlock(h.inode, l)
+
+	if l.writers--; l.writers == 0 {
+		fs.cfg.OnLastWriter(ctx, h.inode)
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package fuseutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// A file system that issues a new handle for each open, and fails to open
+// inode 13.
+type handlesFS struct {
+	NotImplementedFileSystem
+	next     fuseops.HandleID
+	released []fuseops.HandleID
+}
+
+func (fs *handlesFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	if op.Inode == 13 {
+		return syscall.EIO
+	}
+
+	fs.next++
+	op.Handle = fs.next
+	return nil
+}
+
+func (fs *handlesFS) CreateFile(ctx context.Context, op *fuseops.CreateFileOp) error {
+	fs.next++
+	op.Entry.Child = 5
+	op.Handle = fs.next
+	return nil
+}
+
+func (fs *handlesFS) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) error {
+	fs.released = append(fs.released, op.Handle)
+	return nil
+}
+
+func TestWriterLeases(t *testing.T) {
+	ctx := context.Background()
+	var events []string
+	var denied fuseops.InodeID
+	wrapped := &handlesFS{}
+	fs := NewWriterLeaseFileSystem(wrapped, WriterLeaseConfig{
+		OnFirstWriter: func(ctx context.Context, inode fuseops.InodeID) error {
+			if inode == denied {
+				return syscall.EAGAIN
+			}
+
+			events = append(events, fmt.Sprintf("first %d", inode))
+			return nil
+		},
+
+		OnLastWriter: func(ctx context.Context, inode fuseops.InodeID) {
+			events = append(events, fmt.Sprintf("last %d", inode))
+		},
+	})
+
+	open := func(inode fuseops.InodeID, flags fusekernel.OpenFlags) (fuseops.HandleID, error) {
+		op := &fuseops.OpenFileOp{Inode: inode, OpenFlags: flags}
+		err := fs.OpenFile(ctx, op)
+		return op.Handle, err
+	}
+
+	release := func(h fuseops.HandleID) {
+		fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: h})
+	}
+
+	// Only the first of several writers, and the last to close, are reported.
+	// Readers aren't.
+	r, _ := open(3, fusekernel.OpenReadOnly)
+	w1, _ := open(3, fusekernel.OpenWriteOnly)
+	w2, _ := open(3, fusekernel.OpenReadWrite)
+	release(w1)
+	release(r)
+	events = append(events, "-")
+	release(w2)
+
+	want := []string{"first 3", "-", "last 3"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Events: %q, want %q", events, want)
+	}
+
+	// A failed open releases the lease it acquired.
+	events = nil
+	if _, err := open(13, fusekernel.OpenWriteOnly); err != syscall.EIO {
+		t.Errorf("Open(13): %v", err)
+	}
+
+	if want := []string{"first 13", "last 13"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("Events after failed open: %q, want %q", events, want)
+	}
+
+	// A refused lease fails the open, and a created file's handle is then
+	// released.
+	events = nil
+	denied = 5
+	if _, err := open(5, fusekernel.OpenReadWrite); err != syscall.EAGAIN {
+		t.Errorf("Open(5): %v", err)
+	}
+
+	create := &fuseops.CreateFileOp{Name: "taco", OpenFlags: fusekernel.OpenWriteOnly}
+	if err := fs.CreateFile(ctx, create); err != syscall.EAGAIN {
+		t.Errorf("CreateFile: %v", err)
+	}
+
+	if n := len(wrapped.released); n == 0 || wrapped.released[n-1] != create.Handle {
+		t.Errorf("Released: %v, want %v last", wrapped.released, create.Handle)
+	}
+
+	denied = 0
+	if err := fs.CreateFile(ctx, create); err != nil {
+		t.Errorf("CreateFile: %v", err)
+	}
+
+	release(create.Handle)
+	if want := []string{"first 5", "last 5"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("Events after create: %q, want %q", events, want)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// The state of a file handle, tracked if MountConfig.ClassifyReadahead,
+// EnforceOpenModes or DirectIOFallback is set.
+type handleState struct {
+	// The number of times the handle has been issued by OpenFile, CreateFile or
+	// CreateTmpfile and not yet released, and the access modes it was issued
+	// with. A file system that issues the same handle for several opens gets
+	// the union of their modes.
+	issues   int
+	readable bool
+	writable bool
+
+	// Whether the handle was opened with direct I/O or O_DIRECT, in which case
+	// the kernel does no readahead, and sequential reads in flight together are
+	// pieces of one read(2), as with MountConfig.EnableAsyncDIO.
+	directIO bool
+
+	// If non-zero, the inode for which the handle was opened in cached mode
+	// instead of with direct I/O. See MountConfig.DirectIOFallback.
+	fallback fuseops.InodeID
+
+	// The number of reads in flight, and the offset at which the most recent
+	// read ended.
+	inFlight int
+	next     int64
+}
+
+func (c *Connection) trackingHandles() bool {
+	return c.cfg.ClassifyReadahead ||
+		c.cfg.EnforceOpenModes ||
+		c.cfg.DirectIOFallback != nil
+}
+
+// Return the state of the handle, creating it if necessary.
+//
+// LOCKS_REQUIRED(c.mu)
+func (c *Connection) handle(h fuseops.HandleID) *handleState {
+	if c.handles == nil {
+		c.handles = make(map[fuseops.HandleID]*handleState)
+	}
+
+	s := c.handles[h]
+	if s == nil {
+		s = &handleState{}
+		c.handles[h] = s
+	}
+
+	return s
+}
+
+// Record an issue of the handle with the supplied open(2) flags.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) issueHandle(
+	h fuseops.HandleID,
+	flags fusekernel.OpenFlags,
+	directIO bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.handle(h)
+	s.issues++
+	s.directIO = s.directIO || directIO || flags.IsDirect()
+
+	switch flags & fusekernel.OpenAccessModeMask {
+	case fusekernel.OpenReadOnly:
+		s.readable = true
+	case fusekernel.OpenWriteOnly:
+		s.writable = true
+	default:
+		s.readable = true
+		s.writable = true
+	}
+}
+
+// Update the state of the handles used by an op that has been replied to.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) trackHandles(op interface{}, opErr error) {
+	switch typed := op.(type) {
+	case *fuseops.OpenFileOp:
+		if opErr == nil {
+			c.issueHandle(typed.Handle, typed.OpenFlags, typed.UseDirectIO)
+		}
+
+	case *fuseops.CreateFileOp:
+		if opErr == nil {
+			c.issueHandle(typed.Handle, typed.OpenFlags, false)
+		}
+
+	case *fuseops.CreateTmpfileOp:
+		if opErr == nil {
+			c.issueHandle(typed.Handle, typed.OpenFlags, false)
+		}
+
+	case *fuseops.ReadFileOp:
+		if c.cfg.ClassifyReadahead {
+			c.mu.Lock()
+			c.handle(typed.Handle).inFlight--
+			c.mu.Unlock()
+		}
+
+	case *fuseops.ReleaseFileHandleOp:
+		c.mu.Lock()
+		if s := c.handles[typed.Handle]; s != nil {
+			s.issues--
+			if s.issues <= 0 {
+				delete(c.handles, typed.Handle)
+
+				// Don't leave pages cached through the handle for later opens.
+				if s.fallback != 0 {
+					go serviceInodeInvalidation(c, s.fallback, 0, 0)
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Open a handle in cached mode rather than with direct I/O if
+// MountConfig.DirectIOFallback asks for it, recording the handle's inode so
+// that the page cache can be invalidated once the handle is released.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) fallBackFromDirectIO(op interface{}) {
+	o, ok := op.(*fuseops.OpenFileOp)
+	if !ok || !o.UseDirectIO || c.directIOMmap || !c.cfg.DirectIOFallback(o) {
+		return
+	}
+
+	o.UseDirectIO = false
+	o.KeepPageCache = false
+
+	c.mu.Lock()
+	c.handle(o.Handle).fallback = o.Inode
+	c.mu.Unlock()
+}
+
+// Return EBADF if the op reads through a handle that wasn't opened for
+// reading, or writes through one that wasn't opened for writing. Handles
+// whose issue wasn't seen are allowed anything, and with writeback caching
+// every handle may be read through.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) checkOpenMode(op interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s *handleState
+	var ok bool
+	switch typed := op.(type) {
+	case *fuseops.ReadFileOp:
+		s = c.handles[typed.Handle]
+		ok = c.writebackCache || s == nil || s.issues == 0 || s.readable
+
+	case *fuseops.WriteFileOp:
+		s = c.handles[typed.Handle]
+		ok = s == nil || s.issues == 0 || s.writable
+
+	default:
+		return nil
+	}
+
+	if !ok {
+		return syscall.EBADF
+	}
+
+	return nil
+}
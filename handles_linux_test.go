@@ -0,0 +1,59 @@
+package fuse
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+func TestEnforceOpenModes(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+	c.cfg.EnforceOpenModes = true
+
+	// Handle 7 is read-only. Handle 8 was never seen being opened.
+	c.trackHandles(&fuseops.OpenFileOp{Handle: 7, OpenFlags: fusekernel.OpenReadOnly}, nil)
+
+	write := func(unique uint64, handle uint64) {
+		in := fusekernel.WriteIn{Fh: handle, Size: 4}
+		payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+		payload = append(payload, "taco"...)
+		if _, err := peer.Write(request(unique, fusekernel.OpWrite, payload)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	// The write through the read-only handle is rejected without being
+	// returned, and the one through the unknown handle is returned.
+	write(1, 7)
+	write(2, 8)
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if op, ok := op.(*fuseops.WriteFileOp); !ok || op.Handle != 8 {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	var out fusekernel.OutHeader
+	buf := (*[unsafe.Sizeof(out)]byte)(unsafe.Pointer(&out))[:]
+	if _, err := peer.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if out.Unique != 1 || out.Error != -int32(syscall.EBADF) {
+		t.Errorf("unexpected reply: %+v", out)
+	}
+
+	c.Reply(ctx, nil)
+
+	// Once released, the handle is no longer checked.
+	c.trackHandles(&fuseops.ReleaseFileHandleOp{Handle: 7}, nil)
+	if _, ok := c.handles[7]; ok {
+		t.Errorf("released handle still tracked")
+	}
+}
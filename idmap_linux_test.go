@@ -0,0 +1,270 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"golang.org/x/sys/unix"
+)
+
+// A file system in which a file "foo" can be created, owned by its creator,
+// and which records the contexts of creations and of attribute requests. Its
+// root is owned by the supplied ID, since the kernel refuses writes to inodes
+// whose owners the mount's ID mapping doesn't cover.
+type idmapFS struct {
+	fuseutil.NotImplementedFileSystem
+	owner uint32
+
+	mu       sync.Mutex
+	file     *fuseops.InodeAttributes
+	creates  []fuseops.OpContext
+	getattrs []fuseops.OpContext
+}
+
+func (fs *idmapFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{
+			Nlink: 1,
+			Mode:  0777 | os.ModeDir,
+			Uid:   fs.owner,
+			Gid:   fs.owner,
+		}
+	}
+
+	return *fs.file
+}
+
+func (fs *idmapFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.getattrs = append(fs.getattrs, op.OpContext)
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *idmapFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Parent != fuseops.RootInodeID || op.Name != "foo" || fs.file == nil {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	return nil
+}
+
+func (fs *idmapFS) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.creates = append(fs.creates, op.OpContext)
+	fs.file = &fuseops.InodeAttributes{
+		Nlink: 1,
+		Mode:  op.Mode,
+		Uid:   op.OpContext.Uid,
+		Gid:   op.OpContext.Gid,
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = *fs.file
+	return nil
+}
+
+func (fs *idmapFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
+
+// Return a user namespace file mapping the supplied ID in the namespace to
+// root outside it, for both users and groups.
+func userNamespace(t *testing.T, id int) *os.File {
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: id, HostID: 0, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: id, HostID: 0, Size: 1}},
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Skipf("Creating a user namespace: %v", err)
+	}
+
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	f, err := os.Open(fmt.Sprintf("/proc/%d/ns/user", cmd.Process.Pid))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+// Mount the file system, and an ID-mapped view of it in which files owned by
+// the supplied ID are owned by root, returning the two directories and the
+// error from mount_setattr(2).
+func mountIDMapped(
+	t *testing.T,
+	fs *idmapFS,
+	enabled bool,
+	id int) (*fuse.MountedFileSystem, string, error) {
+	mfs, err := fuse.Mount(
+		t.TempDir(),
+		fuseutil.NewFileSystemServer(fs),
+		&fuse.MountConfig{EnableIDMappedMounts: enabled})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	ns := userNamespace(t, id)
+	tree, err := unix.OpenTree(
+		unix.AT_FDCWD,
+		mfs.Dir(),
+		unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC)
+
+	if err != nil {
+		t.Fatalf("OpenTree: %v", err)
+	}
+	defer unix.Close(tree)
+
+	err = unix.MountSetattr(tree, "", unix.AT_EMPTY_PATH, &unix.MountAttr{
+		Attr_set:  unix.MOUNT_ATTR_IDMAP,
+		Userns_fd: uint64(ns.Fd()),
+	})
+
+	if err != nil {
+		return mfs, "", err
+	}
+
+	dir := t.TempDir()
+	if err := unix.MoveMount(tree, "", unix.AT_FDCWD, dir, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+		t.Fatalf("MoveMount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := unix.Unmount(dir, unix.MNT_DETACH); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+	})
+
+	return mfs, dir, nil
+}
+
+func TestIDMappedMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("ID-mapping a mount requires CAP_SYS_ADMIN")
+	}
+
+	fs := &idmapFS{owner: 1000}
+	mfs, dir, err := mountIDMapped(t, fs, true, 1000)
+	if features := mfs.KernelInfo().Features; !slices.Contains(features, "ALLOW_IDMAP") {
+		t.Skipf("Kernel doesn't support ID-mapped fuse mounts: %v", features)
+	}
+
+	if err != nil {
+		t.Fatalf("MountSetattr: %v", err)
+	}
+
+	// Root creates the file through the mapped view as user 1000.
+	f, err := os.Create(path.Join(dir, "foo"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	f.Close()
+
+	fs.mu.Lock()
+	if len(fs.creates) != 1 || fs.creates[0].Uid != 1000 || fs.creates[0].Gid != 1000 {
+		t.Errorf("CreateFile contexts: %+v", fs.creates)
+	}
+	fs.getattrs = nil
+	fs.mu.Unlock()
+
+	// It is owned by root in the mapped view, and by 1000 in the other.
+	for p, want := range map[string]uint32{dir: 0, mfs.Dir(): 1000} {
+		var st syscall.Stat_t
+		if err := syscall.Stat(path.Join(p, "foo"), &st); err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+
+		if st.Uid != want || st.Gid != want {
+			t.Errorf("Owner in %s: got %d:%d, want %d", p, st.Uid, st.Gid, want)
+		}
+	}
+
+	// Other ops carry no IDs.
+	fs.mu.Lock()
+	if len(fs.getattrs) == 0 {
+		t.Errorf("No GetInodeAttributes ops")
+	}
+
+	for _, c := range fs.getattrs {
+		if c.Uid != fuseops.InvalidUidGid || c.Gid != fuseops.InvalidUidGid {
+			t.Errorf("GetInodeAttributes context: %+v", c)
+		}
+	}
+	fs.mu.Unlock()
+}
+
+func TestIDMappedMount_NotEnabled(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("ID-mapping a mount requires CAP_SYS_ADMIN")
+	}
+
+	mfs, _, err := mountIDMapped(t, &idmapFS{}, false, 1000)
+	if slices.Contains(mfs.KernelInfo().Features, "ALLOW_IDMAP") {
+		t.Errorf("ALLOW_IDMAP negotiated without EnableIDMappedMounts")
+	}
+
+	if !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("MountSetattr: got %v, want EINVAL", err)
+	}
+}
+
+func TestIDMappedMount_NoDefaultPermissions(t *testing.T) {
+	_, err := fuse.Mount(
+		t.TempDir(),
+		fuseutil.NewFileSystemServer(&idmapFS{}),
+		&fuse.MountConfig{
+			EnableIDMappedMounts:      true,
+			DisableDefaultPermissions: true,
+		})
+
+	if err == nil {
+		t.Fatalf("fuse.Mount succeeded")
+	}
+}
@@ -46,10 +46,17 @@ type InMessage struct {
 	size      int
 }
 
-// NewInMessage creates a new InMessage with its storage initialized.
+// NewInMessage creates a new InMessage with its storage initialized. The
+// storage is page aligned, so that the buffers returned by GetFree may be.
 func NewInMessage() *InMessage {
+	b := make([]byte, bufSize+pageSize)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) % uintptr(pageSize)); rem != 0 {
+		off = pageSize - rem
+	}
+
 	return &InMessage{
-		storage: make([]byte, bufSize),
+		storage: b[off : off+bufSize : off+bufSize],
 	}
 }
 
@@ -145,10 +152,19 @@ func (m *InMessage) ConsumeBytes(n uintptr) []byte {
 	return b
 }
 
-// Get the next n bytes after the message to use them as a temporary buffer
+// Get n bytes after the message to use them as a temporary buffer. The buffer
+// starts at the first page boundary after the message if there is room, which
+// is always the case for requests without data such as reads, so that it may
+// be used for direct I/O without an intermediate copy.
 func (m *InMessage) GetFree(n int) []byte {
 	if n <= 0 || n > len(m.storage)-m.size {
 		return nil
 	}
-	return m.storage[m.size : m.size+n]
+
+	start := (m.size + pageSize - 1) / pageSize * pageSize
+	if n > len(m.storage)-start {
+		start = m.size
+	}
+
+	return m.storage[start : start+n]
 }
@@ -0,0 +1,33 @@
+package buffer
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestGetFreeIsPageAligned(t *testing.T) {
+	m := NewInMessage()
+
+	// Simulate a read request that occupies the start of the buffer.
+	m.size = 80
+
+	b := m.GetFree(1 << 16)
+	if len(b) != 1<<16 {
+		t.Fatalf("got %d bytes, want %d", len(b), 1<<16)
+	}
+
+	if p := uintptr(unsafe.Pointer(&b[0])); p%uintptr(pageSize) != 0 {
+		t.Errorf("buffer at %#x is not page aligned", p)
+	}
+
+	// A request that doesn't leave room for an aligned buffer gets one
+	// directly after the message.
+	b = m.GetFree(MaxWriteSize + 1)
+	if &b[0] != &m.storage[m.size] {
+		t.Errorf("unexpected buffer start")
+	}
+
+	if m.GetFree(bufSize) != nil {
+		t.Errorf("expected nil for oversized request")
+	}
+}
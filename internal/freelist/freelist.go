@@ -38,3 +38,8 @@ func (fl *Freelist) Get() unsafe.Pointer {
 func (fl *Freelist) Put(p unsafe.Pointer) {
 	fl.list = append(fl.list, p)
 }
+
+// Return the number of elements in the freelist.
+func (fl *Freelist) Len() int {
+	return len(fl.list)
+}
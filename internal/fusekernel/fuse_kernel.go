@@ -46,7 +46,7 @@ const (
 	ProtoVersionMinMajor = 7
 	ProtoVersionMinMinor = 18
 	ProtoVersionMaxMajor = 7
-	ProtoVersionMaxMinor = 34
+	ProtoVersionMaxMinor = 41
 )
 
 const (
@@ -66,7 +66,7 @@ type Kstatfs struct {
 	Spare   [6]uint32
 }
 
-type fileLock struct {
+type FileLock struct {
 	Start uint64
 	End   uint64
 	Type  uint32
@@ -106,6 +106,7 @@ const (
 	SetattrAtimeNow  SetattrValid = 1 << 7
 	SetattrMtimeNow  SetattrValid = 1 << 8
 	SetattrLockOwner SetattrValid = 1 << 9 // http://www.mail-archive.com/git-commits-head@vger.kernel.org/msg27852.html
+	SetattrCtime     SetattrValid = 1 << 10
 
 	// OS X only
 	SetattrCrtime   SetattrValid = 1 << 28
@@ -124,6 +125,7 @@ func (fl SetattrValid) Handle() bool    { return fl&SetattrHandle != 0 }
 func (fl SetattrValid) AtimeNow() bool  { return fl&SetattrAtimeNow != 0 }
 func (fl SetattrValid) MtimeNow() bool  { return fl&SetattrMtimeNow != 0 }
 func (fl SetattrValid) LockOwner() bool { return fl&SetattrLockOwner != 0 }
+func (fl SetattrValid) Ctime() bool     { return fl&SetattrCtime != 0 }
 func (fl SetattrValid) Crtime() bool    { return fl&SetattrCrtime != 0 }
 func (fl SetattrValid) Chgtime() bool   { return fl&SetattrChgtime != 0 }
 func (fl SetattrValid) Bkuptime() bool  { return fl&SetattrBkuptime != 0 }
@@ -144,6 +146,7 @@ var setattrValidNames = []flagName{
 	{uint32(SetattrAtimeNow), "SetattrAtimeNow"},
 	{uint32(SetattrMtimeNow), "SetattrMtimeNow"},
 	{uint32(SetattrLockOwner), "SetattrLockOwner"},
+	{uint32(SetattrCtime), "SetattrCtime"},
 	{uint32(SetattrCrtime), "SetattrCrtime"},
 	{uint32(SetattrChgtime), "SetattrChgtime"},
 	{uint32(SetattrBkuptime), "SetattrBkuptime"},
@@ -204,6 +207,11 @@ func (fl OpenFlags) IsAppend() bool {
 	return fl&OpenAppend != 0
 }
 
+// Return true if OpenTruncate is set.
+func (fl OpenFlags) IsTruncate() bool {
+	return fl&OpenTruncate != 0
+}
+
 func accModeName(flags OpenFlags) string {
 	switch flags {
 	case OpenReadOnly:
@@ -229,10 +237,13 @@ var openFlagNames = []flagName{
 type OpenResponseFlags uint32
 
 const (
-	OpenDirectIO    OpenResponseFlags = 1 << 0 // bypass page cache for this open file
-	OpenKeepCache   OpenResponseFlags = 1 << 1 // don't invalidate the data cache on open
-	OpenNonSeekable OpenResponseFlags = 1 << 2 // mark the file as non-seekable (not supported on OS X)
-	OpenCacheDir    OpenResponseFlags = 1 << 3 // allow caching this directory
+	OpenDirectIO             OpenResponseFlags = 1 << 0 // bypass page cache for this open file
+	OpenKeepCache            OpenResponseFlags = 1 << 1 // don't invalidate the data cache on open
+	OpenNonSeekable          OpenResponseFlags = 1 << 2 // mark the file as non-seekable (not supported on OS X)
+	OpenCacheDir             OpenResponseFlags = 1 << 3 // allow caching this directory
+	OpenStream               OpenResponseFlags = 1 << 4 // the file is stream-like (no file position at all)
+	OpenParallelDirectWrites OpenResponseFlags = 1 << 6 // allow concurrent direct writes on the same inode
+	OpenPassthrough          OpenResponseFlags = 1 << 7 // pass I/O through to OpenOut.BackingID
 
 	OpenPurgeAttr OpenResponseFlags = 1 << 30 // OS X
 	OpenPurgeUBC  OpenResponseFlags = 1 << 31 // OS X
@@ -247,36 +258,69 @@ var openResponseFlagNames = []flagName{
 	{uint32(OpenKeepCache), "OpenKeepCache"},
 	{uint32(OpenNonSeekable), "OpenNonSeekable"},
 	{uint32(OpenCacheDir), "OpenCacheDir"},
+	{uint32(OpenStream), "OpenStream"},
+	{uint32(OpenParallelDirectWrites), "OpenParallelDirectWrites"},
+	{uint32(OpenPassthrough), "OpenPassthrough"},
 	{uint32(OpenPurgeAttr), "OpenPurgeAttr"},
 	{uint32(OpenPurgeUBC), "OpenPurgeUBC"},
 }
 
+// The minor protocol version in which each OpenResponseFlags newer than
+// ProtoVersionMinMinor that isn't gated by an init flag was introduced.
+var openResponseFlagMinors = []struct {
+	flag  OpenResponseFlags
+	minor uint32
+}{
+	{OpenParallelDirectWrites, 38},
+}
+
+// Return the subset of the flags that exist in the given protocol version.
+func (fl OpenResponseFlags) ForProtocol(p Protocol) OpenResponseFlags {
+	for _, f := range openResponseFlagMinors {
+		if p.LT(Protocol{7, f.minor}) {
+			fl &^= f.flag
+		}
+	}
+
+	return fl
+}
+
 // The InitFlags are used in the Init exchange.
 type InitFlags uint32
 
 const (
-	InitAsyncRead        InitFlags = 1 << 0
-	InitPosixLocks       InitFlags = 1 << 1
-	InitFileOps          InitFlags = 1 << 2
-	InitAtomicTrunc      InitFlags = 1 << 3
-	InitExportSupport    InitFlags = 1 << 4
-	InitBigWrites        InitFlags = 1 << 5
-	InitDontMask         InitFlags = 1 << 6
-	InitSpliceWrite      InitFlags = 1 << 7
-	InitSpliceMove       InitFlags = 1 << 8
-	InitSpliceRead       InitFlags = 1 << 9
-	InitFlockLocks       InitFlags = 1 << 10
-	InitHasIoctlDir      InitFlags = 1 << 11
-	InitAutoInvalData    InitFlags = 1 << 12
-	InitDoReaddirplus    InitFlags = 1 << 13
-	InitReaddirplusAuto  InitFlags = 1 << 14
-	InitAsyncDIO         InitFlags = 1 << 15
-	InitWritebackCache   InitFlags = 1 << 16
-	InitNoOpenSupport    InitFlags = 1 << 17
-	InitParallelDirOps   InitFlags = 1 << 18
-	InitMaxPages         InitFlags = 1 << 22
-	InitCacheSymlinks    InitFlags = 1 << 23
-	InitNoOpendirSupport InitFlags = 1 << 24
+	InitAsyncRead         InitFlags = 1 << 0
+	InitPosixLocks        InitFlags = 1 << 1
+	InitFileOps           InitFlags = 1 << 2
+	InitAtomicTrunc       InitFlags = 1 << 3
+	InitExportSupport     InitFlags = 1 << 4
+	InitBigWrites         InitFlags = 1 << 5
+	InitDontMask          InitFlags = 1 << 6
+	InitSpliceWrite       InitFlags = 1 << 7
+	InitSpliceMove        InitFlags = 1 << 8
+	InitSpliceRead        InitFlags = 1 << 9
+	InitFlockLocks        InitFlags = 1 << 10
+	InitHasIoctlDir       InitFlags = 1 << 11
+	InitAutoInvalData     InitFlags = 1 << 12
+	InitDoReaddirplus     InitFlags = 1 << 13
+	InitReaddirplusAuto   InitFlags = 1 << 14
+	InitAsyncDIO          InitFlags = 1 << 15
+	InitWritebackCache    InitFlags = 1 << 16
+	InitNoOpenSupport     InitFlags = 1 << 17
+	InitParallelDirOps    InitFlags = 1 << 18
+	InitAbortError        InitFlags = 1 << 21
+	InitMaxPages          InitFlags = 1 << 22
+	InitCacheSymlinks     InitFlags = 1 << 23
+	InitNoOpendirSupport  InitFlags = 1 << 24
+	InitExplicitInvalData InitFlags = 1 << 25
+
+	// Linux only: the kernel mounts directories whose attributes have
+	// AttrSubmount set as submounts (protocol 7.32 and later).
+	InitSubmounts InitFlags = 1 << 27
+
+	// Linux only: InitIn.Flags2 and InitOut.Flags2 are valid (protocol 7.36 and
+	// later). The same bit as InitVolRename on OS X.
+	InitExt InitFlags = 1 << 30
 
 	InitCaseSensitive InitFlags = 1 << 29 // OS X only
 	InitVolRename     InitFlags = 1 << 30 // OS X only
@@ -308,8 +352,11 @@ var initFlagNames = []flagName{
 	{uint32(InitAsyncDIO), "InitAsyncDIO"},
 	{uint32(InitWritebackCache), "InitWritebackCache"},
 	{uint32(InitNoOpenSupport), "InitNoOpenSupport"},
+	{uint32(InitAbortError), "InitAbortError"},
 	{uint32(InitCacheSymlinks), "InitCacheSymlinks"},
 	{uint32(InitNoOpendirSupport), "InitNoOpendirSupport"},
+	{uint32(InitExplicitInvalData), "InitExplicitInvalData"},
+	{uint32(InitSubmounts), "InitSubmounts"},
 
 	{uint32(InitCaseSensitive), "InitCaseSensitive"},
 	{uint32(InitVolRename), "InitVolRename"},
@@ -320,6 +367,79 @@ func (fl InitFlags) String() string {
 	return flagString(uint32(fl), initFlagNames)
 }
 
+// The minor protocol version in which each init flag newer than
+// ProtoVersionMinMinor was introduced, from the changelog in fuse.h.
+var initFlagMinors = []struct {
+	flag  InitFlags
+	minor uint32
+}{
+	{InitAutoInvalData, 20},
+	{InitDoReaddirplus, 21},
+	{InitReaddirplusAuto, 21},
+	{InitAsyncDIO, 22},
+	{InitWritebackCache, 23},
+	{InitNoOpenSupport, 23},
+	{InitParallelDirOps, 25},
+	{InitAbortError, 27},
+	{InitMaxPages, 28},
+	{InitCacheSymlinks, 28},
+	{InitNoOpendirSupport, 29},
+	{InitExplicitInvalData, 30},
+	{InitSubmounts, 32},
+}
+
+// InitFlags2 are the init flags beyond the first 32, exchanged only with
+// InitExt set.
+type InitFlags2 uint32
+
+const (
+	InitDirectIOAllowMmap InitFlags2 = 1 << (36 - 32)
+	InitPassthrough       InitFlags2 = 1 << (37 - 32)
+	InitAllowIdmap        InitFlags2 = 1 << (40 - 32)
+)
+
+func (fl InitFlags2) String() string {
+	return flagString(uint32(fl), initFlags2Names)
+}
+
+var initFlags2Names = []flagName{
+	{uint32(InitDirectIOAllowMmap), "InitDirectIOAllowMmap"},
+	{uint32(InitPassthrough), "InitPassthrough"},
+	{uint32(InitAllowIdmap), "InitAllowIdmap"},
+}
+
+// The minor protocol version in which each of the InitFlags2 was introduced.
+var initFlags2Minors = []struct {
+	flag  InitFlags2
+	minor uint32
+}{
+	{InitDirectIOAllowMmap, 39},
+	{InitPassthrough, 40},
+	{InitAllowIdmap, 41},
+}
+
+// Return the subset of the flags that exist in the given protocol version.
+func (fl InitFlags2) ForProtocol(p Protocol) InitFlags2 {
+	for _, f := range initFlags2Minors {
+		if p.LT(Protocol{7, f.minor}) {
+			fl &^= f.flag
+		}
+	}
+
+	return fl
+}
+
+// Return the subset of the flags that exist in the given protocol version.
+func (fl InitFlags) ForProtocol(p Protocol) InitFlags {
+	for _, f := range initFlagMinors {
+		if p.LT(Protocol{7, f.minor}) {
+			fl &^= f.flag
+		}
+	}
+
+	return fl
+}
+
 func flagString(f uint32, names []flagName) string {
 	var s string
 
@@ -343,7 +463,8 @@ func flagString(f uint32, names []flagName) string {
 type ReleaseFlags uint32
 
 const (
-	ReleaseFlush ReleaseFlags = 1 << 0
+	ReleaseFlush       ReleaseFlags = 1 << 0
+	ReleaseFlockUnlock ReleaseFlags = 1 << 1
 )
 
 func (fl ReleaseFlags) String() string {
@@ -352,6 +473,7 @@ func (fl ReleaseFlags) String() string {
 
 var releaseFlagNames = []flagName{
 	{uint32(ReleaseFlush), "ReleaseFlush"},
+	{uint32(ReleaseFlockUnlock), "ReleaseFlockUnlock"},
 }
 
 // Opcodes
@@ -404,6 +526,8 @@ const (
 	OpSetupMapping  = 48
 	OpRemoveMapping = 49
 	OpSyncFS        = 50
+	OpTmpfile       = 51
+	OpStatx         = 52
 
 	// OS X
 	OpSetvolname = 61
@@ -511,6 +635,13 @@ type RenameIn struct {
 	// "oldname\x00newname\x00" follows
 }
 
+type Rename2In struct {
+	Newdir  uint64
+	Flags   uint32
+	Padding uint32
+	// "oldname\x00newname\x00" follows
+}
+
 // OS X
 type ExchangeIn struct {
 	Olddir  uint64
@@ -530,10 +661,10 @@ type setattrInCommon struct {
 	LockOwner uint64 // unused on OS X?
 	Atime     uint64
 	Mtime     uint64
-	Unused2   uint64
+	Ctime     uint64 // Linux only
 	AtimeNsec uint32
 	MtimeNsec uint32
-	Unused3   uint32
+	CtimeNsec uint32 // Linux only
 	Mode      uint32
 	Unused4   uint32
 	Uid       uint32
@@ -549,7 +680,7 @@ type OpenIn struct {
 type OpenOut struct {
 	Fh        uint64
 	OpenFlags uint32
-	Padding   uint32
+	BackingID int32 // protocol 7.40 and later
 }
 
 type CreateIn struct {
@@ -571,8 +702,8 @@ func CreateInSize(p Protocol) uintptr {
 type ReleaseIn struct {
 	Fh           uint64
 	Flags        uint32
-	ReleaseFlags uint32
-	LockOwner    uint32
+	ReleaseFlags ReleaseFlags
+	LockOwner    uint64
 }
 
 type FlushIn struct {
@@ -707,14 +838,84 @@ type FallocateIn struct {
 	Padding uint32
 }
 
+type IoctlIn struct {
+	Fh      uint64
+	Flags   uint32
+	Cmd     uint32
+	Arg     uint64
+	InSize  uint32
+	OutSize uint32
+}
+
+type IoctlOut struct {
+	Result  int32
+	Flags   uint32
+	InIovs  uint32
+	OutIovs uint32
+}
+
+// Follows IoctlOut when IoctlRetry is set.
+type IoctlIovec struct {
+	Base uint64
+	Len  uint64
+}
+
+// Flags of IoctlIn and IoctlOut.
+const (
+	IoctlCompat       = 1 << 0
+	IoctlUnrestricted = 1 << 1
+	IoctlRetry        = 1 << 2
+	Ioctl32Bit        = 1 << 3
+	IoctlDir          = 1 << 4
+)
+
+type CopyFileRangeIn struct {
+	FhIn      uint64
+	OffIn     uint64
+	NodeidOut uint64
+	FhOut     uint64
+	OffOut    uint64
+	Len       uint64
+	Flags     uint64
+}
+
+type LseekIn struct {
+	Fh      uint64
+	Offset  uint64
+	Whence  uint32
+	Padding uint32
+}
+
+type LseekOut struct {
+	Offset uint64
+}
+
+// Flags that can be seen in PollIn.Flags.
+const PollScheduleNotify = 1 << 0
+
+type PollIn struct {
+	Fh     uint64
+	Kh     uint64
+	Flags  uint32
+	Events uint32
+}
+
+type PollOut struct {
+	Revents uint32
+	Padding uint32
+}
+
 type LkIn struct {
 	Fh      uint64
 	Owner   uint64
-	Lk      fileLock
+	Lk      FileLock
 	LkFlags uint32
 	padding uint32
 }
 
+// Flags that can be seen in LkIn.LkFlags.
+const LkFlock = 1 << 0
+
 func LkInSize(p Protocol) uintptr {
 	switch {
 	case p.LT(Protocol{7, 9}):
@@ -725,7 +926,7 @@ func LkInSize(p Protocol) uintptr {
 }
 
 type LkOut struct {
-	Lk fileLock
+	Lk FileLock
 }
 
 type AccessIn struct {
@@ -753,9 +954,25 @@ type InitOut struct {
 	TimeGran            uint32
 	MaxPages            uint16
 	MapAlignment        uint16
-	Unused              [8]uint32
+	Flags2              uint32 // protocol 7.36 and later
+	MaxStackDepth       uint32 // protocol 7.40 and later
+	Unused              [6]uint32
+}
+
+// The argument of the FUSE_DEV_IOC_BACKING_OPEN ioctl on the fuse device
+// (protocol 7.40 and later), which returns the ID of the backing file.
+type BackingMap struct {
+	Fd      int32
+	Flags   uint32
+	Padding uint64
 }
 
+// Ioctls on the fuse device, from _IOW(229, n, type) in fuse.h.
+const (
+	DevIocBackingOpen  = 1<<30 | 16<<16 | 229<<8 | 1 // struct fuse_backing_map
+	DevIocBackingClose = 1<<30 | 4<<16 | 229<<8 | 2  // uint32_t backing ID
+)
+
 type InterruptIn struct {
 	Unique uint64
 }
@@ -803,6 +1020,7 @@ const (
 	NotifyCodePoll       int32 = 1
 	NotifyCodeInvalInode int32 = 2
 	NotifyCodeInvalEntry int32 = 3
+	NotifyCodeStore      int32 = 4
 )
 
 type NotifyInvalInodeOut struct {
@@ -817,6 +1035,91 @@ type NotifyInvalEntryOut struct {
 	padding uint32
 }
 
+type NotifyPollWakeupOut struct {
+	Kh uint64
+}
+
+type NotifyStoreOut struct {
+	Nodeid  uint64
+	Offset  uint64
+	Size    uint32
+	padding uint32
+}
+
 type SyncFSIn struct {
 	Padding uint64
 }
+
+// Flags for FUSE_SETUPMAPPING (protocol 7.31 and later), giving the access
+// the mapping must allow.
+type SetupMappingFlags uint64
+
+const (
+	SetupMappingWrite SetupMappingFlags = 1 << 0
+	SetupMappingRead  SetupMappingFlags = 1 << 1
+)
+
+type SetupMappingIn struct {
+	Fh      uint64
+	Foffset uint64
+	Len     uint64
+	Flags   uint64
+	Moffset uint64
+}
+
+// FUSE_REMOVEMAPPING's input is a RemoveMappingIn followed by Count
+// RemoveMappingOne.
+type RemoveMappingIn struct {
+	Count uint32
+}
+
+type RemoveMappingOne struct {
+	Moffset uint64
+	Len     uint64
+}
+
+type SxTime struct {
+	Sec      int64
+	Nsec     uint32
+	Reserved int32
+}
+
+type Statx struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	Uid            uint32
+	Gid            uint32
+	Mode           uint16
+	Spare0         [1]uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          SxTime
+	Btime          SxTime
+	Ctime          SxTime
+	Mtime          SxTime
+	RdevMajor      uint32
+	RdevMinor      uint32
+	DevMajor       uint32
+	DevMinor       uint32
+	Spare2         [14]uint64
+}
+
+type StatxIn struct {
+	GetattrFlags uint32
+	Reserved     uint32
+	Fh           uint64
+	SxFlags      uint32
+	SxMask       uint32
+}
+
+type StatxOut struct {
+	AttrValid     uint64 // Cache timeout for the attributes
+	AttrValidNsec uint32
+	Flags         uint32
+	Spare         [2]uint64
+	Stat          Statx
+}
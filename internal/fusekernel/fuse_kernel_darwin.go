@@ -34,6 +34,10 @@ func (a *Attr) SetFlags(f uint32) {
 	a.Flags_ = f
 }
 
+func (a *Attr) SetSubmount() {
+	// Linux only.
+}
+
 type SetattrIn struct {
 	setattrInCommon
 
@@ -59,6 +63,11 @@ func (in *SetattrIn) Flags() uint32 {
 	return in.Flags_
 }
 
+// OS X has no O_DIRECT.
+func (fl OpenFlags) IsDirect() bool {
+	return false
+}
+
 type GetxattrIn struct {
 	getxattrInCommon
 
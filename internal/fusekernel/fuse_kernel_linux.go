@@ -21,9 +21,14 @@ type Attr struct {
 	Gid       uint32
 	Rdev      uint32
 	Blksize   uint32
-	padding   uint32
+	Flags     uint32 // protocol 7.32 and later
 }
 
+// Attr.Flags.
+const (
+	AttrSubmount = 1 << 0
+)
+
 func (a *Attr) Crtime() time.Time {
 	return time.Time{}
 }
@@ -36,6 +41,10 @@ func (a *Attr) SetFlags(f uint32) {
 	// Ignored on Linux.
 }
 
+func (a *Attr) SetSubmount() {
+	a.Flags |= AttrSubmount
+}
+
 type SetattrIn struct {
 	setattrInCommon
 }
@@ -0,0 +1,89 @@
+package fusekernel
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// The structs of the kernel's fuse.h are padded explicitly so that their
+// layout is the same on every architecture, whether 64-bit integers are
+// aligned to 8 bytes, as on arm and mips, or to 4, as on 386. Go aligns them
+// to 4 bytes on all 32-bit architectures, so a missing padding field shifts
+// the fields that follow only there. Run these tests with GOARCH set to each
+// target, e.g. GOARCH=386, or GOARCH=arm under qemu-user.
+
+func TestStructSizes(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"fuse_in_header", unsafe.Sizeof(InHeader{}), 40},
+		{"fuse_out_header", unsafe.Sizeof(OutHeader{}), 16},
+		{"fuse_attr", unsafe.Sizeof(Attr{}), 88},
+		{"fuse_entry_out", unsafe.Sizeof(EntryOut{}), 128},
+		{"fuse_attr_out", unsafe.Sizeof(AttrOut{}), 104},
+		{"fuse_init_out", unsafe.Sizeof(InitOut{}), 64},
+		{"fuse_open_out", unsafe.Sizeof(OpenOut{}), 16},
+		{"fuse_read_in", unsafe.Sizeof(ReadIn{}), 40},
+		{"fuse_write_in", unsafe.Sizeof(WriteIn{}), 40},
+		{"fuse_write_out", unsafe.Sizeof(WriteOut{}), 8},
+		{"fuse_setattr_in", unsafe.Sizeof(SetattrIn{}), 88},
+		{"fuse_statfs_out", unsafe.Sizeof(StatfsOut{}), 80},
+		{"fuse_file_lock", unsafe.Sizeof(FileLock{}), 24},
+		{"fuse_lk_in", unsafe.Sizeof(LkIn{}), 48},
+		{"fuse_lk_out", unsafe.Sizeof(LkOut{}), 24},
+		{"fuse_release_in", unsafe.Sizeof(ReleaseIn{}), 24},
+		{"fuse_flush_in", unsafe.Sizeof(FlushIn{}), 24},
+		{"fuse_ioctl_in", unsafe.Sizeof(IoctlIn{}), 32},
+		{"fuse_ioctl_out", unsafe.Sizeof(IoctlOut{}), 16},
+		{"fuse_poll_in", unsafe.Sizeof(PollIn{}), 24},
+		{"fuse_fallocate_in", unsafe.Sizeof(FallocateIn{}), 32},
+		{"fuse_lseek_in", unsafe.Sizeof(LseekIn{}), 24},
+		{"fuse_copy_file_range_in", unsafe.Sizeof(CopyFileRangeIn{}), 56},
+		{"fuse_statx_in", unsafe.Sizeof(StatxIn{}), 24},
+		{"fuse_statx_out", unsafe.Sizeof(StatxOut{}), 288},
+		{"fuse_notify_inval_inode_out", unsafe.Sizeof(NotifyInvalInodeOut{}), 24},
+		{"fuse_notify_store_out", unsafe.Sizeof(NotifyStoreOut{}), 24},
+		{"fuse_forget_one", unsafe.Sizeof(BatchForgetEntryIn{}), 16},
+		{"fuse_setupmapping_in", unsafe.Sizeof(SetupMappingIn{}), 40},
+		{"fuse_removemapping_one", unsafe.Sizeof(RemoveMappingOne{}), 16},
+		{"fuse_backing_map", unsafe.Sizeof(BackingMap{}), 16},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.want {
+			t.Errorf("%s: got size %d, want %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+// Check the offsets of fields that follow an odd number of 32-bit fields,
+// which are the ones that move if padding is missing.
+func TestStructOffsets(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"fuse_entry_out.attr", unsafe.Offsetof(EntryOut{}.Attr), 40},
+		{"fuse_attr_out.attr", unsafe.Offsetof(AttrOut{}.Attr), 16},
+		{"fuse_attr.flags", unsafe.Offsetof(Attr{}.Flags), 84},
+		{"fuse_init_out.flags2", unsafe.Offsetof(InitOut{}.Flags2), 32},
+		{"fuse_read_in.lock_owner", unsafe.Offsetof(ReadIn{}.LockOwner), 24},
+		{"fuse_write_in.lock_owner", unsafe.Offsetof(WriteIn{}.LockOwner), 24},
+		{"fuse_setattr_in.fh", unsafe.Offsetof(SetattrIn{}.Fh), 8},
+		{"fuse_setattr_in.uid", unsafe.Offsetof(SetattrIn{}.Uid), 76},
+		{"fuse_lk_in.lk_flags", unsafe.Offsetof(LkIn{}.LkFlags), 40},
+		{"fuse_ioctl_in.arg", unsafe.Offsetof(IoctlIn{}.Arg), 16},
+		{"fuse_statx_in.fh", unsafe.Offsetof(StatxIn{}.Fh), 8},
+		{"fuse_statx_out.stat", unsafe.Offsetof(StatxOut{}.Stat), 32},
+		{"fuse_statx.ino", unsafe.Offsetof(Statx{}.Ino), 32},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.want {
+			t.Errorf("%s: got offset %d, want %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fuse
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// Send an IOCTL request with the supplied flags and input, and read it from
+// the connection, returning the op and its context.
+func ioctl(tb testing.TB, c *Connection, peer *os.File, flags uint32, input []byte, outSize uint32) (*fuseops.IoctlOp, context.Context) {
+	in := fusekernel.IoctlIn{
+		Fh:      7,
+		Flags:   flags,
+		Cmd:     0x5401,
+		Arg:     0x1000,
+		InSize:  uint32(len(input)),
+		OutSize: outSize,
+	}
+
+	payload := append((*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:], input...)
+	if _, err := peer.Write(request(1, fusekernel.OpIoctl, payload)); err != nil {
+		tb.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		tb.Fatalf("ReadOp: %v", err)
+	}
+
+	ioctlOp, ok := op.(*fuseops.IoctlOp)
+	if !ok || ioctlOp.Handle != 7 || ioctlOp.Cmd != 0x5401 || ioctlOp.Arg != 0x1000 {
+		tb.Fatalf("unexpected op: %#v", op)
+	}
+
+	return ioctlOp, ctx
+}
+
+func TestIoctlOp(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	op, ctx := ioctl(t, c, peer, fusekernel.IoctlCompat, []byte("taco"), 8)
+	if string(op.Input) != "taco" || op.OutputSize != 8 || !op.Compat || op.Unrestricted {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	// Output beyond OutputSize is dropped.
+	op.Result = 17
+	op.Output = []byte("burritos!")
+	c.Reply(ctx, nil)
+
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	hdr := int(unsafe.Sizeof(fusekernel.OutHeader{}))
+	want := hdr + int(unsafe.Sizeof(fusekernel.IoctlOut{}))
+	if err != nil || n != want+8 {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	if result := int32(binary.NativeEndian.Uint32(buf[hdr:])); result != 17 {
+		t.Errorf("result: got %d, want 17", result)
+	}
+
+	if got := string(buf[want:n]); got != "burritos" {
+		t.Errorf("output: got %q", got)
+	}
+}
+
+func TestIoctlOp_Retry(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	op, ctx := ioctl(t, c, peer, fusekernel.IoctlUnrestricted, nil, 0)
+	if !op.Unrestricted || len(op.Input) != 0 || op.OutputSize != 0 {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	op.RetryInput = []fuseops.IoctlRegion{{Addr: 0x1000, Len: 4}}
+	op.RetryOutput = []fuseops.IoctlRegion{{Addr: 0x2000, Len: 8}, {Addr: 0x3000, Len: 2}}
+	c.Reply(ctx, nil)
+
+	var buf [128]byte
+	n, err := peer.Read(buf[:])
+	hdr := int(unsafe.Sizeof(fusekernel.OutHeader{}))
+	want := hdr + int(unsafe.Sizeof(fusekernel.IoctlOut{})+3*unsafe.Sizeof(fusekernel.IoctlIovec{}))
+	if err != nil || n != want {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	out := (*fusekernel.IoctlOut)(unsafe.Pointer(&buf[hdr]))
+	if out.Flags != fusekernel.IoctlRetry || out.InIovs != 1 || out.OutIovs != 2 {
+		t.Fatalf("unexpected reply: %#v", *out)
+	}
+
+	var got []uint64
+	for i := hdr + int(unsafe.Sizeof(*out)); i < n; i += 8 {
+		got = append(got, binary.NativeEndian.Uint64(buf[i:]))
+	}
+
+	wantIovs := []uint64{0x1000, 4, 0x2000, 8, 0x3000, 2}
+	for i := range wantIovs {
+		if got[i] != wantIovs[i] {
+			t.Fatalf("iovecs: got %#x, want %#x", got, wantIovs)
+		}
+	}
+}
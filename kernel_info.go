@@ -0,0 +1,157 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// Protocol is a FUSE protocol version.
+type Protocol struct {
+	Major uint32
+	Minor uint32
+}
+
+func (p Protocol) String() string {
+	return fmt.Sprintf("%d.%d", p.Major, p.Minor)
+}
+
+// KernelInfo describes what the kernel offered when the file system was
+// mounted, and what was negotiated with it, to help diagnose differences in
+// behavior between environments. See MountedFileSystem.KernelInfo.
+type KernelInfo struct {
+	// The protocol version spoken by the kernel, and the version used for the
+	// connection, which is lower if this package or MountConfig.MaxProtocolMinor
+	// doesn't support the kernel's.
+	KernelProtocol Protocol
+	Protocol       Protocol
+
+	// The features the kernel offered in its init request, and those enabled,
+	// named as in the kernel's fuse.h without the FUSE_ prefix, e.g.
+	// "SPLICE_READ" or "WRITEBACK_CACHE". Which are enabled depends on the
+	// MountConfig.
+	KernelFeatures []string
+	Features       []string
+
+	// The largest readahead the kernel offered, and the largest it will use.
+	KernelMaxReadahead uint32
+	MaxReadahead       uint32
+
+	// The largest write the kernel will send, in bytes, and the largest
+	// request it will send in pages, if the MAX_PAGES feature is enabled.
+	MaxWrite uint32
+	MaxPages uint16
+
+	// The number of background requests, such as readahead and writeback, the
+	// kernel may have in flight, and the number at which it reports the file
+	// system as congested.
+	MaxBackground       uint16
+	CongestionThreshold uint16
+}
+
+// The names of the init flags on Linux, indexed by bit.
+var linuxFeatureNames = [32]string{
+	"ASYNC_READ",
+	"POSIX_LOCKS",
+	"FILE_OPS",
+	"ATOMIC_O_TRUNC",
+	"EXPORT_SUPPORT",
+	"BIG_WRITES",
+	"DONT_MASK",
+	"SPLICE_WRITE",
+	"SPLICE_MOVE",
+	"SPLICE_READ",
+	"FLOCK_LOCKS",
+	"HAS_IOCTL_DIR",
+	"AUTO_INVAL_DATA",
+	"DO_READDIRPLUS",
+	"READDIRPLUS_AUTO",
+	"ASYNC_DIO",
+	"WRITEBACK_CACHE",
+	"NO_OPEN_SUPPORT",
+	"PARALLEL_DIROPS",
+	"HANDLE_KILLPRIV",
+	"POSIX_ACL",
+	"ABORT_ERROR",
+	"MAX_PAGES",
+	"CACHE_SYMLINKS",
+	"NO_OPENDIR_SUPPORT",
+	"EXPLICIT_INVAL_DATA",
+	"MAP_ALIGNMENT",
+	"SUBMOUNTS",
+	"HANDLE_KILLPRIV_V2",
+	"SETXATTR_EXT",
+	"INIT_EXT",
+	"INIT_RESERVED",
+}
+
+// The names of the InitFlags2 on Linux, indexed by bit.
+var linuxFeatureNames2 = []string{
+	"SECURITY_CTX",
+	"HAS_INODE_DAX",
+	"CREATE_SUPP_GROUP",
+	"HAS_EXPIRE_ONLY",
+	"DIRECT_IO_ALLOW_MMAP",
+	"PASSTHROUGH",
+	"NO_EXPORT_SUPPORT",
+	"HAS_RESEND",
+	"ALLOW_IDMAP",
+	"OVER_IO_URING",
+	"REQUEST_TIMEOUT",
+}
+
+// Return the names of the init flags.
+func featureNames(
+	flags fusekernel.InitFlags,
+	flags2 fusekernel.InitFlags2) []string {
+	var names []string
+	for bit := 0; bit < 32; bit++ {
+		if flags&(1<<bit) == 0 {
+			continue
+		}
+
+		name := linuxFeatureNames[bit]
+		if runtime.GOOS == "darwin" {
+			switch fusekernel.InitFlags(1 << bit) {
+			case fusekernel.InitCaseSensitive:
+				name = "CASE_INSENSITIVE"
+			case fusekernel.InitVolRename:
+				name = "VOL_RENAME"
+			case fusekernel.InitXtimes:
+				name = "XTIMES"
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	for bit := 0; bit < 32; bit++ {
+		if flags2&(1<<bit) == 0 {
+			continue
+		}
+
+		name := fmt.Sprintf("FLAGS2_BIT_%d", bit)
+		if bit < len(linuxFeatureNames2) {
+			name = linuxFeatureNames2[bit]
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
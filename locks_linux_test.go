@@ -0,0 +1,134 @@
+package fuse
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+// Send a lock request and read it from the connection, returning the op and
+// its context.
+func lockRequest(tb testing.TB, c *Connection, peer *os.File, opCode uint32) (interface{}, context.Context) {
+	in := fusekernel.LkIn{
+		Fh:    7,
+		Owner: 0xabc,
+		Lk:    fusekernel.FileLock{Start: 10, End: 19, Type: unix.F_WRLCK, Pid: 17},
+	}
+
+	payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+	if _, err := peer.Write(request(1, opCode, payload)); err != nil {
+		tb.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		tb.Fatalf("ReadOp: %v", err)
+	}
+
+	return op, ctx
+}
+
+func TestGetLkOp(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	op, ctx := lockRequest(t, c, peer, fusekernel.OpGetlk)
+	want := fuseops.FileLock{Start: 10, End: 19, Type: unix.F_WRLCK, Pid: 17}
+	getLkOp, ok := op.(*fuseops.GetLkOp)
+	if !ok || getLkOp.Handle != 7 || getLkOp.Owner != 0xabc || getLkOp.Lock != want {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	if getLkOp.Conflict.Type != unix.F_UNLCK {
+		t.Errorf("initial conflict: %#v", getLkOp.Conflict)
+	}
+
+	getLkOp.Conflict = fuseops.FileLock{Start: 0, End: 15, Type: unix.F_RDLCK, Pid: 23}
+	c.Reply(ctx, nil)
+
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	hdr := int(unsafe.Sizeof(fusekernel.OutHeader{}))
+	if err != nil || n != hdr+int(unsafe.Sizeof(fusekernel.LkOut{})) {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	got := (*fusekernel.LkOut)(unsafe.Pointer(&buf[hdr])).Lk
+	if got != (fusekernel.FileLock{Start: 0, End: 15, Type: unix.F_RDLCK, Pid: 23}) {
+		t.Errorf("conflict: got %#v", got)
+	}
+}
+
+func TestSetLkOps(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+	want := fuseops.FileLock{Start: 10, End: 19, Type: unix.F_WRLCK, Pid: 17}
+
+	op, ctx := lockRequest(t, c, peer, fusekernel.OpSetlk)
+	if setLkOp, ok := op.(*fuseops.SetLkOp); !ok || setLkOp.Owner != 0xabc || setLkOp.Lock != want {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	c.Reply(ctx, nil)
+	readReply(t, peer, 1)
+
+	op, ctx = lockRequest(t, c, peer, fusekernel.OpSetlkw)
+	if setLkWOp, ok := op.(*fuseops.SetLkWOp); !ok || setLkWOp.Owner != 0xabc || setLkWOp.Lock != want {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	c.Reply(ctx, nil)
+	readReply(t, peer, 1)
+}
+
+func TestFlock(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	in := fusekernel.LkIn{
+		Fh:      7,
+		Owner:   0xabc,
+		Lk:      fusekernel.FileLock{Start: 0, End: math.MaxInt64, Type: unix.F_WRLCK},
+		LkFlags: fusekernel.LkFlock,
+	}
+
+	payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+	if _, err := peer.Write(request(1, fusekernel.OpSetlk, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if setLkOp, ok := op.(*fuseops.SetLkOp); !ok || !setLkOp.Flock || setLkOp.Owner != 0xabc {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	c.Reply(ctx, nil)
+	readReply(t, peer, 1)
+
+	// Closing the last descriptor releases the lock.
+	release := fusekernel.ReleaseIn{
+		Fh:           7,
+		ReleaseFlags: fusekernel.ReleaseFlockUnlock,
+		LockOwner:    0xabc,
+	}
+
+	payload = (*[unsafe.Sizeof(release)]byte)(unsafe.Pointer(&release))[:]
+	if _, err := peer.Write(request(2, fusekernel.OpRelease, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, op, err = c.ReadOp(); err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if releaseOp, ok := op.(*fuseops.ReleaseFileHandleOp); !ok || !releaseOp.FlockRelease || releaseOp.LockOwner != 0xabc {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+}
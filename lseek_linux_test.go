@@ -0,0 +1,45 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+func TestLseekOp(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	in := fusekernel.LseekIn{Fh: 7, Offset: 4096, Whence: unix.SEEK_HOLE}
+	payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+	if _, err := peer.Write(request(1, fusekernel.OpLseek, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	lseekOp, ok := op.(*fuseops.LseekOp)
+	if !ok || lseekOp.Handle != 7 || lseekOp.Offset != 4096 || lseekOp.Whence != unix.SEEK_HOLE {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	lseekOp.NewOffset = 8192
+	c.Reply(ctx, nil)
+
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	want := int(unsafe.Sizeof(fusekernel.OutHeader{}) + unsafe.Sizeof(fusekernel.LseekOut{}))
+	if err != nil || n != want {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	if off := binary.NativeEndian.Uint64(buf[n-8:]); off != 8192 {
+		t.Errorf("offset: got %d, want 8192", off)
+	}
+}
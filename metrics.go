@@ -0,0 +1,146 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets kept by
+// Metrics. Latencies above the last bound are counted only in the total.
+var LatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Metrics counts the ops handled by a mount, and keeps a histogram of their
+// latencies, from being read from the kernel to being replied to, for each op
+// type. Supply one in MountConfig.Metrics. A Metrics may be shared by several
+// mounts, in which case it records their ops together.
+//
+// Metrics implements expvar.Var, so it can be published without any metrics
+// dependency:
+//
+//	m := new(fuse.Metrics)
+//	expvar.Publish("fuse", m)
+//
+// Its JSON form is also convenient for adapting to other metrics systems; see
+// MarshalJSON.
+type Metrics struct {
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	ops map[string]*OpMetrics
+}
+
+// OpMetrics are the metrics for one op type.
+type OpMetrics struct {
+	// The number of ops, and the number of those that failed.
+	Count  uint64 `json:"count"`
+	Errors uint64 `json:"errors"`
+
+	// The total latency of the ops.
+	Sum time.Duration `json:"sum_ns"`
+
+	// Buckets[i] is the number of ops with latency at most LatencyBuckets[i].
+	// Like Prometheus histogram buckets, these counts are cumulative.
+	Buckets []uint64 `json:"buckets"`
+}
+
+// Record an op of the supplied type that took the supplied time.
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *Metrics) record(op string, latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ops == nil {
+		m.ops = make(map[string]*OpMetrics)
+	}
+
+	om := m.ops[op]
+	if om == nil {
+		om = &OpMetrics{Buckets: make([]uint64, len(LatencyBuckets))}
+		m.ops[op] = om
+	}
+
+	om.Count++
+	if failed {
+		om.Errors++
+	}
+
+	om.Sum += latency
+	for i := sort.Search(len(LatencyBuckets), func(i int) bool {
+		return latency <= LatencyBuckets[i]
+	}); i < len(om.Buckets); i++ {
+		om.Buckets[i]++
+	}
+}
+
+// Snapshot returns a copy of the metrics recorded so far, keyed by op type
+// without the "Op" suffix, e.g. "LookUpInode".
+//
+// LOCKS_EXCLUDED(m.mu)
+func (m *Metrics) Snapshot() map[string]OpMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]OpMetrics, len(m.ops))
+	for name, om := range m.ops {
+		c := *om
+		c.Buckets = append([]uint64(nil), om.Buckets...)
+		snapshot[name] = c
+	}
+
+	return snapshot
+}
+
+// MarshalJSON returns the metrics as a JSON object with two fields:
+// "buckets_ns", the bucket bounds from LatencyBuckets in nanoseconds, and
+// "ops", the result of Snapshot.
+func (m *Metrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Bounds []time.Duration      `json:"buckets_ns"`
+		Ops    map[string]OpMetrics `json:"ops"`
+	}{LatencyBuckets, m.Snapshot()})
+}
+
+// String returns the JSON form of the metrics, as required by expvar.Var.
+func (m *Metrics) String() string {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return "{}"
+	}
+
+	return string(b)
+}
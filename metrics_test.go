@@ -0,0 +1,60 @@
+package fuse
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+var _ expvar.Var = &Metrics{}
+
+func TestMetrics(t *testing.T) {
+	var m Metrics
+	m.record("LookUpInode", 75*time.Microsecond, false)
+	m.record("LookUpInode", 3*time.Millisecond, true)
+	m.record("LookUpInode", time.Minute, false)
+	m.record("ReadFile", 50*time.Microsecond, false)
+
+	var out struct {
+		Bounds []time.Duration      `json:"buckets_ns"`
+		Ops    map[string]OpMetrics `json:"ops"`
+	}
+
+	if err := json.Unmarshal([]byte(m.String()), &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if len(out.Bounds) != len(LatencyBuckets) || len(out.Ops) != 2 {
+		t.Fatalf("unexpected metrics: %s", m.String())
+	}
+
+	lookUp := out.Ops["LookUpInode"]
+	if lookUp.Count != 3 || lookUp.Errors != 1 {
+		t.Errorf("LookUpInode: count %d, errors %d", lookUp.Count, lookUp.Errors)
+	}
+
+	if want := time.Minute + 3075*time.Microsecond; lookUp.Sum != want {
+		t.Errorf("LookUpInode sum: got %v, want %v", lookUp.Sum, want)
+	}
+
+	// Buckets are cumulative, and the minute-long op is only in the count.
+	for i, bound := range LatencyBuckets {
+		var want uint64
+		switch {
+		case bound >= 5*time.Millisecond:
+			want = 2
+		case bound >= 100*time.Microsecond:
+			want = 1
+		}
+
+		if lookUp.Buckets[i] != want {
+			t.Errorf("LookUpInode bucket %v: got %d, want %d", bound, lookUp.Buckets[i], want)
+		}
+	}
+
+	// Bounds are inclusive.
+	if read := out.Ops["ReadFile"]; read.Buckets[0] != 1 {
+		t.Errorf("ReadFile: %+v", read)
+	}
+}
@@ -17,12 +17,13 @@ package fuse
 import (
 	"context"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"os/exec"
 	"strings"
 	"syscall"
+
+	"github.com/jacobsa/fuse/internal/fusekernel"
 )
 
 // Server is an interface for any type that knows how to serve ops read from a
@@ -47,6 +48,30 @@ func Mount(
 		return nil, err
 	}
 
+	if config.MaxProtocolMinor != 0 &&
+		config.MaxProtocolMinor < fusekernel.ProtoVersionMinMinor {
+		return nil, fmt.Errorf(
+			"MaxProtocolMinor %d is older than the minimum supported version 7.%d",
+			config.MaxProtocolMinor,
+			fusekernel.ProtoVersionMinMinor)
+	}
+
+	// The kernel would fail every op.
+	if config.EnableIDMappedMounts && config.DisableDefaultPermissions {
+		return nil, fmt.Errorf(
+			"EnableIDMappedMounts requires default permissions, but DisableDefaultPermissions is set")
+	}
+
+	if err := config.caching().validate(); err != nil {
+		return nil, err
+	}
+
+	if config.MinOpenFiles != 0 {
+		if err := ensureOpenFileLimit(config.MinOpenFiles); err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize the struct.
 	mfs := &MountedFileSystem{
 		dir:                 dir,
@@ -89,10 +114,17 @@ func Mount(
 		config.DebugLogger.Println("Successfully created the connection")
 	}
 
+	mfs.kernel = connection.KernelInfo()
+	mfs.conn = connection
+
 	// Serve the connection in the background. When done, set the join status.
 	go func() {
 		server.ServeOps(connection)
-		mfs.joinStatus = connection.close()
+		mfs.exitCause, mfs.joinStatus = connection.exitStatus()
+		if err := connection.close(); mfs.joinStatus == nil {
+			mfs.joinStatus = err
+		}
+
 		close(mfs.joinStatusAvailable)
 	}()
 
@@ -108,6 +140,35 @@ func Mount(
 	return mfs, nil
 }
 
+// Make sure that the process may have at least n open files, raising
+// RLIMIT_NOFILE if necessary. See MountConfig.MinOpenFiles.
+func ensureOpenFileLimit(n uint64) error {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return fmt.Errorf("getrlimit: %v", err)
+	}
+
+	if lim.Cur >= n {
+		return nil
+	}
+
+	want := lim
+	want.Cur = n
+	if want.Max < n {
+		want.Max = n
+	}
+
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &want); err != nil {
+		return fmt.Errorf(
+			"MinOpenFiles is %d, but RLIMIT_NOFILE is %d with a hard limit of %d, "+
+				"and raising it failed (%v); raise the limit before starting "+
+				"the process, e.g. with ulimit -n",
+			n, lim.Cur, lim.Max, err)
+	}
+
+	return nil
+}
+
 func checkMountPoint(dir string) error {
 	if strings.HasPrefix(dir, "/dev/fd") {
 		return nil
@@ -128,7 +189,8 @@ func checkMountPoint(dir string) error {
 	return nil
 }
 
-func fusermount(binary string, argv []string, additionalEnv []string, wait bool, debugLogger *log.Logger) (*os.File, error) {
+func fusermount(binary string, argv []string, additionalEnv []string, wait bool, cfg *MountConfig) (dev *os.File, err error) {
+	debugLogger := cfg.DebugLogger
 	if debugLogger != nil {
 		debugLogger.Println("Creating a socket pair")
 	}
@@ -153,11 +215,24 @@ func fusermount(binary string, argv []string, additionalEnv []string, wait bool,
 	}
 	// Start fusermount/mount_macfuse/mount_osxfuse.
 	cmd := exec.Command(binary, argv...)
-	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
-	cmd.Env = append(cmd.Env, additionalEnv...)
+	inherited := os.Environ()
+	added := append([]string{"_FUSE_COMMFD=3"}, additionalEnv...)
+	cmd.Env = append(inherited, added...)
 	cmd.ExtraFiles = []*os.File{writeFile}
 	cmd.Stderr = os.Stderr
 
+	// Time everything up to receiving the device from the helper, since with
+	// !wait that is when it is done with the mount.
+	cmdLine := describeCommand(binary, argv)
+	if debugLogger != nil {
+		debugLogger.Printf(
+			"Running %s with environment %s",
+			cmdLine,
+			describeEnv(len(inherited), added))
+	}
+	step := startMountStep(cfg, "Running "+cmdLine)
+	defer func() { step.done(err) }()
+
 	// Run the command.
 	if wait {
 		err = cmd.Run()
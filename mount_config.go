@@ -19,8 +19,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
 )
 
 // Optional configuration accepted by Mount.
@@ -52,6 +58,17 @@ type MountConfig struct {
 	// performed.
 	WireLogger io.Writer
 
+	// If non-zero, steps of mounting and unmounting that take at least this
+	// long are logged to ErrorLogger along with what was being run, e.g. the
+	// fusermount command line. This helps to diagnose multi-second mount
+	// delays caused by fusermount, locking /etc/mtab or an automounter
+	// watching the mount point. Regardless of this setting, DebugLogger gets
+	// the duration of every step, as well as the environment that fusermount
+	// is run with (omitting the variables inherited from this process).
+	//
+	// Unmounting is only timed when done with MountedFileSystem.Unmount.
+	SlowMountThreshold time.Duration
+
 	// Linux only. OS X always behaves as if writeback caching is disabled.
 	//
 	// By default on Linux we allow the kernel to perform writeback caching
@@ -117,6 +134,8 @@ type MountConfig struct {
 	// Setting DisableWritebackCaching disables this behavior. Instead the file
 	// system is called one or more times for each write(2), and the user's
 	// syscall doesn't return until the file system returns.
+	//
+	// Ignored if Caching is set; see CachingPolicy.WritebackCache.
 	DisableWritebackCaching bool
 
 	// OS X only.
@@ -142,7 +161,11 @@ type MountConfig struct {
 	// This is not enabled by default because the old behavior masked a bug:
 	// file systems could return any size in the inode attributes of
 	// symlinks. After enabling caching, the specified size caps the symlink
-	// target.
+	// target. Targets are then cached until the inode is forgotten or
+	// invalidated with Notifier.InvalidateInode, which makes caching worthwhile
+	// for trees resolving many symlinks, e.g. build trees of symlink farms.
+	//
+	// Ignored if Caching is set; see CachingPolicy.SymlinkCache.
 	EnableSymlinkCaching bool
 
 	// Linux only.
@@ -192,9 +215,78 @@ type MountConfig struct {
 	// the kernel
 	EnableAsyncReads bool
 
+	// Linux only. Have the kernel split reads and writes through files opened
+	// with O_DIRECT into requests of the maximum size and send them
+	// concurrently, waiting for all of them before the read(2) or write(2)
+	// returns, rather than sending one request at a time. This speeds up large
+	// direct I/O against a backend with high latency, e.g. a database reading
+	// with O_DIRECT from a network file system.
+	//
+	// The requests may be handled in any order, so a file system must cope
+	// with a write beyond the end of the file that leaves a gap for one yet to
+	// arrive, as described for fuseops.WriteFileOp.Offset. The kernel counts
+	// them as background requests, limited by Tuning.MaxBackground. Files
+	// opened with OpenFileOp.UseDirectIO are only split this way for
+	// asynchronous I/O, e.g. io_submit(2), through descriptors opened with
+	// O_DIRECT.
+	EnableAsyncDIO bool
+
+	// Linux only. Let file systems open files in passthrough mode (Linux >=
+	// 6.9), setting OpenFileOp.BackingID or CreateFileOp.BackingID to a file
+	// registered with Notifier.OpenBacking, so that reads, writes and mmap go
+	// straight to that file in the kernel without involving the file system.
+	// This gives loopback file systems nearly the throughput of the file
+	// system they mirror.
+	//
+	// Registering backing files requires CAP_SYS_ADMIN. The kernel doesn't
+	// support passthrough alongside writeback caching, which is therefore
+	// disabled if the kernel supports passthrough. Whether it does is
+	// reported by the PASSTHROUGH feature in MountedFileSystem.KernelInfo.
+	EnablePassthrough bool
+
+	// Linux only. Let handles opened with OpenFileOp.UseDirectIO be mapped
+	// shared with mmap(2) (Linux >= 6.6). The mapped pages go through the page
+	// cache, which the kernel discards when such a handle is mapped, as well
+	// as when a handle is opened without KeepPageCache.
+	//
+	// Otherwise, and on older kernels, such mappings fail with ENODEV without
+	// the file system being told, which breaks programs that map the files
+	// they open, like some editors and linkers. Private mappings are always
+	// allowed. Whether the kernel supports this is reported by the
+	// DIRECT_IO_ALLOW_MMAP feature in MountedFileSystem.KernelInfo. See also
+	// DirectIOFallback.
+	EnableDirectIOMmap bool
+
+	// Linux only. If non-nil, called for each OpenFileOp that the file system
+	// answers with UseDirectIO set, unless the kernel lets the handle be mapped
+	// shared because of EnableDirectIOMmap. If it returns true, the handle is
+	// opened in cached mode instead, so that it can be mapped: UseDirectIO and
+	// KeepPageCache are cleared, so that the kernel discards the inode's page
+	// cache on open, and the page cache is invalidated again once the handle
+	// is released, so that data cached through it isn't served to later
+	// opens.
+	//
+	// Since mmap(2) doesn't reach the file system, there is no telling which
+	// opens will be mapped. The function may decide from the op, e.g. from the
+	// program run by the process with ID OpContext.Pid, or return true for
+	// all. In cached mode, reads are limited to the file size in the inode's
+	// attributes, so files whose size isn't known in advance, which are often
+	// why direct I/O is used, appear truncated.
+	DirectIOFallback func(op *fuseops.OpenFileOp) bool
+
 	// Flag to enable parallel lookup and readdir operations from the
 	// kernel
 	// Ref: https://github.com/torvalds/linux/commit/5c672ab3f0ee0f78f7acad183f34db0f8781a200
+	//
+	// Without it, the kernel sends one LookUpInodeOp or ReadDirOp at a time for
+	// each directory (besides lookups revalidating names it has cached), which
+	// limits metadata-heavy workloads such as builds or `git status` to one round
+	// trip per directory at a time. With it, those ops may be concurrent with
+	// each other, though never with an op changing the directory's entries. File
+	// systems that keep per-directory state, e.g. a listing fetched by ReadDir,
+	// must lock it accordingly. The wrappers in fuseutil are safe.
+	//
+	// Ignored if Caching is set; see CachingPolicy.ParallelDirOps.
 	EnableParallelDirOps bool
 
 	// Flag to enable atomic truncate during file open operations.
@@ -202,8 +294,70 @@ type MountConfig struct {
 	// op with the O_TRUNC flag set. In comparison, the default behavior is an OpenFile op
 	// without O_TRUNC, followed by a SetInodeAttributes op with the target size set to 0.
 	// Ref: https://github.com/torvalds/linux/commit/6ff958edbf39c014eb06b65ad25b736be08c4e63
+	//
+	// The file system must then truncate the file itself when OpenFileOp has
+	// the flag set, so that the open and truncation happen atomically and in
+	// one round trip; the kernel assumes the file is empty once the open
+	// succeeds. See OpenFileOp.OpenFlags.
 	EnableAtomicTrunc bool
 
+	// Flag to have the kernel mount directories whose attributes have
+	// fuseops.InodeAttributes.Submount set as separate file systems, so that
+	// tools that stay on one file system, like find -xdev, treat nested file
+	// systems exported through this one as they would have on the exporting
+	// side.
+	//
+	// Linux offers submounts only for virtio-fs, not for file systems mounted
+	// through /dev/fuse as this package mounts them, so this has no effect
+	// unless SUBMOUNTS is among the KernelFeatures in
+	// MountedFileSystem.KernelInfo.
+	EnableSubmounts bool
+
+	// Linux only. Flag to let the kernel open inodes by file handle, so that
+	// the file system can be exported over NFS or used with
+	// open_by_handle_at(2). Without it, name_to_handle_at(2) fails with
+	// EOPNOTSUPP on recent kernels, and older ones fail to resolve handles to
+	// inodes they have forgotten with ESTALE.
+	//
+	// A handle names an inode by its ID and generation number, and outlives
+	// the kernel's references to it. To resolve one whose inode the kernel
+	// has forgotten, the kernel sends a LookUpInodeOp for the name "." with
+	// the ID as Parent, whatever the inode's type, and to find the parent of a
+	// directory it has no path to, one for "..", after which it finds the
+	// directory's name by listing the parent with ReadDirOp, matching the
+	// entries' Inode fields. The file system must answer these for any ID it
+	// has issued and not reused, and fail them with ENOENT once the ID is no
+	// longer valid, which the kernel reports as ESTALE. See
+	// fuseutil.InodeTable for help assigning IDs that stay resolvable, and
+	// fuseutil.ParentTrackingFileSystem for answering "." and "..".
+	EnableExportSupport bool
+
+	// Linux only. Declare that the file system supports ID-mapped mounts
+	// (Linux >= 6.12), so that container runtimes can map the owners of its
+	// files with mount_setattr(2) and MOUNT_ATTR_IDMAP. Without it, the kernel
+	// refuses to ID-map the mount.
+	//
+	// The kernel then checks permissions against the mapped IDs, which
+	// requires default permissions, so Mount fails if DisableDefaultPermissions
+	// is also set. For ops that create an inode, it reports the caller's IDs
+	// in fuseops.OpContext mapped into the file system's view, whether or not
+	// the op came through an ID-mapped mount, and InvalidUidGid for all other
+	// ops. Through an ID-mapped mount, the kernel refuses to write to inodes
+	// whose owners the mapping doesn't cover. Whether the kernel supports this
+	// is reported by the ALLOW_IDMAP feature in MountedFileSystem.KernelInfo.
+	EnableIDMappedMounts bool
+
+	// Flag to have the kernel send POSIX advisory record locks, set with
+	// fcntl(2), to the file system as fuseops.GetLkOp, SetLkOp and SetLkWOp,
+	// rather than managing them itself. File systems shared between machines need
+	// this for locks to exclude processes on other machines.
+	EnablePosixLocks bool
+
+	// Flag to have the kernel send BSD locks, set with flock(2), to the file
+	// system as SetLkOp and SetLkWOp with Flock set, rather than managing them
+	// itself. See fuseops.SetLkOp.
+	EnableFlockLocks bool
+
 	// Flag to tell the kernel we support ReadDirPlus, which optimizes performance
 	// by returning not just the directory entries (like ReadDir), but also their inode
 	// attributes, thereby saving one extra Lookup request per directory entry.
@@ -241,6 +395,224 @@ type MountConfig struct {
 	// to always provide ReadFileOp.Dst. If the file system populates ReadFileOp.Data,
 	// that data will be used for a vectored read, irrespective of this flag's value.
 	UseVectoredRead bool
+
+	// Additional errors that should be reported to the kernel as particular
+	// errno values. An error returned by the file system that doesn't wrap a
+	// syscall.Errno is compared against each entry's Err in order using
+	// errors.Is, before the default mappings (see Connection.Errno) are tried.
+	ErrnoMappings []ErrnoMapping
+
+	// The errno with which to report an error that has a Timeout method
+	// returning true, such as a net.Error for a timed out backend request. If
+	// zero, EIO is used. EAGAIN may be a better choice for file systems whose
+	// callers are prepared to retry.
+	TimeoutErrno syscall.Errno
+
+	// A development aid. If set, the fields filled in by the file system for a
+	// successful op are checked before the reply is sent, and invalid
+	// combinations (e.g. a LookUpInodeOp with no Entry.Child, or a BytesRead
+	// larger than the destination buffer) are logged to ErrorLogger and
+	// replied to with EIO rather than handed to the kernel.
+	//
+	// Replies are checked against the op they answer, e.g. that an MkDirOp's
+	// entry is a directory other than the root, that a CreateLinkOp's entry is
+	// its Target, and that an IoctlOp's Output fits in OutputSize, catching
+	// replies that the kernel would otherwise fail with EIO itself, without a
+	// trace of the cause, or silently truncate.
+	StrictReplies bool
+
+	// A development aid for file systems that store data in fixed-size
+	// blocks, e.g. on a block device opened with O_DIRECT. If non-zero, writes
+	// whose offset or length isn't a multiple of this many bytes are logged to
+	// ErrorLogger and rejected with EINVAL without being passed on. This
+	// catches setups that let unaligned writes through, e.g. direct I/O
+	// without the page cache to gather them into whole pages, before they
+	// corrupt the blocks they partially overwrite.
+	WriteAlignment uint32
+
+	// The clock against which the absolute expiration times in op responses
+	// (e.g. ChildInodeEntry.EntryExpiration) are converted to the durations the
+	// kernel expects. If nil, timeutil.RealClock() is used.
+	//
+	// A file system that computes expirations from a timeutil.SimulatedClock,
+	// e.g. to control time in tests, should supply the same clock here. Entries
+	// written by fuseutil.WriteDirentPlus are converted when they are written,
+	// so such a file system should use fuseutil.WriteDirentPlusAt instead.
+	Clock timeutil.Clock
+
+	// If non-zero, the highest minor version of the fuse kernel protocol (whose
+	// major version is 7) to negotiate, even if the kernel supports a newer one.
+	// Features from later versions are then neither requested nor used, which
+	// lets a file system be tested against the behavior of older kernels. Must
+	// be at least 18, the oldest version this package supports.
+	MaxProtocolMinor uint32
+
+	// How to handle errors reading from and writing to the fuse device. The
+	// zero value retries reads interrupted by signals and gives up on anything
+	// else.
+	DeviceErrorPolicy DeviceErrorPolicy
+
+	// How to handle requests with opcodes this package doesn't know. The zero
+	// value replies to them with ENOSYS without logging.
+	UnknownOpPolicy UnknownOpPolicy
+
+	// If non-nil, overrides some or all of the attributes of the root
+	// directory returned by the file system, e.g. to make it owned by a
+	// particular user without special-casing it in GetInodeAttributes.
+	RootAttributes *RootAttributes
+
+	// Linux only. The path of the fuse device to open, e.g. a /dev/fuse
+	// bind-mounted elsewhere in a container. If empty, /dev/fuse is used.
+	//
+	// A custom device path requires mounting directly, as root or with
+	// CAP_SYS_ADMIN, because fusermount opens /dev/fuse itself.
+	DevicePath string
+
+	// Linux only. If set, the path of the block device holding the file
+	// system, which is then mounted with type fuseblk rather than fuse, as
+	// ntfs-3g does. Only such mounts are sent BmapOp, for FIBMAP. Their block
+	// size defaults to 512 bytes, and may be set with the blksize option, e.g.
+	// Options["blksize"] = "4096". The kernel holds the device open
+	// exclusively while it is mounted, so the file system must open it
+	// without O_EXCL.
+	//
+	// Like a custom DevicePath, this requires mounting directly, as root or
+	// with CAP_SYS_ADMIN.
+	BlockDevice string
+
+	// If non-zero, the number of open files the process expects to need, e.g.
+	// for backing files a passthrough file system keeps open for its inodes.
+	// Mount raises the soft RLIMIT_NOFILE limit to at least this number, and the
+	// hard limit too if needed and permitted, failing with an error describing
+	// the limits if that isn't possible.
+	MinOpenFiles uint64
+
+	// If non-nil, used to profile a sample of requests.
+	Profiler *Profiler
+
+	// If non-nil, per-op counts and latency histograms are recorded in it.
+	// Reads classified as readahead (see ClassifyReadahead) are recorded
+	// separately, as "ReadFile(readahead)".
+	Metrics *Metrics
+
+	// Guess which reads were issued by the kernel's readahead rather than on
+	// behalf of a process waiting for the data, and set ReadFileOp.Readahead
+	// for them, so that they can be deprioritized, e.g. by
+	// fuseutil.ThrottledFileSystem. The kernel doesn't say, so this is a
+	// heuristic: a read is readahead if it continues a sequential run of reads
+	// through its handle while an earlier one is still in flight. Reads
+	// through handles opened with OpenFileOp.UseDirectIO or O_DIRECT are never
+	// readahead.
+	ClassifyReadahead bool
+
+	// Track the access mode each file handle was opened with, and reject reads
+	// through handles not opened for reading, and writes through handles not
+	// opened for writing, with EBADF without passing them on. Handles whose
+	// OpenFile or CreateFile wasn't seen, e.g. with EnableNoOpenSupport, are
+	// not checked. With writeback caching, reads are never rejected, since the
+	// kernel reads through write-only handles to fill partially written pages;
+	// file systems must then serve reads through any handle.
+	EnforceOpenModes bool
+
+	// Pass the flags of renameat2(2), e.g. RENAME_NOREPLACE and
+	// RENAME_EXCHANGE, to the file system in RenameOp.Flags. The file system
+	// must then honor them, failing with EINVAL for any it doesn't support.
+	//
+	// Otherwise renames with flags are refused with ENOSYS without involving
+	// the file system, which the kernel remembers, failing them with EINVAL
+	// from then on. Kernels speaking protocol versions before 7.23 never send
+	// flags, so file systems setting this must still cope without them.
+	EnableRenameFlags bool
+
+	// Reduce garbage collection pressure by reusing memory across requests.
+	// The context for each op, and the op itself if it is of one of the most
+	// common types (e.g. LookUpInodeOp, GetInodeAttributesOp, ReadFileOp), are
+	// taken from a per-request arena that is returned to a pool once the op
+	// has been replied to, so that serving them needn't allocate.
+	//
+	// When this is set, the op and its context must not be used once
+	// Connection.Reply has been called for it, even by goroutines started
+	// while handling it. This includes contexts derived from the op's context.
+	//
+	// This has no effect if OpContext can be cancelled.
+	PoolOps bool
+
+	// Linux only. If non-nil, what the kernel should cache, in place of
+	// DisableWritebackCaching, EnableSymlinkCaching and EnableParallelDirOps,
+	// which are then ignored. Start from a preset such as NetworkFSCaching.
+	Caching *CachingPolicy
+
+	// Overrides for the concurrency and memory used to serve the file system.
+	// Fields left zero keep the defaults, or with Tuning.Auto are chosen based
+	// on the number of CPUs.
+	Tuning Tuning
+}
+
+// A mapping from an error to the errno that should be reported to the kernel
+// for it. See MountConfig.ErrnoMappings.
+type ErrnoMapping struct {
+	Err   error
+	Errno syscall.Errno
+}
+
+// RootAttributes overrides attributes of the root directory in replies to
+// GetInodeAttributesOp, SetInodeAttributesOp, and StatxOp. See
+// MountConfig.RootAttributes.
+//
+// The overrides affect only what the kernel sees, and so what it uses for
+// permission checks when the default_permissions option is set; the file
+// system still receives the SetInodeAttributesOps, e.g. from chmod, and may
+// choose to reject them.
+type RootAttributes struct {
+	// If non-nil, the permission bits (including setuid, setgid, and sticky) to
+	// report. The type is always a directory.
+	Mode *os.FileMode
+
+	// If non-nil, the owner and group to report.
+	Uid *uint32
+	Gid *uint32
+
+	// If non-nil, the times to report.
+	Atime *time.Time
+	Mtime *time.Time
+	Ctime *time.Time
+
+	// If non-nil, called after the fields above have been applied, to adjust
+	// the attributes arbitrarily. It must not block.
+	Func func(*fuseops.InodeAttributes)
+}
+
+// Return a copy of the attributes with the overrides applied.
+func (r *RootAttributes) apply(in fuseops.InodeAttributes) fuseops.InodeAttributes {
+	if r.Mode != nil {
+		in.Mode = os.ModeDir | *r.Mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+	}
+
+	if r.Uid != nil {
+		in.Uid = *r.Uid
+	}
+
+	if r.Gid != nil {
+		in.Gid = *r.Gid
+	}
+
+	if r.Atime != nil {
+		in.Atime = *r.Atime
+	}
+
+	if r.Mtime != nil {
+		in.Mtime = *r.Mtime
+	}
+
+	if r.Ctime != nil {
+		in.Ctime = *r.Ctime
+	}
+
+	if r.Func != nil {
+		r.Func(&in)
+	}
+
+	return in
 }
 
 type FUSEImpl uint8
@@ -58,17 +58,39 @@ func directmount(dir string, cfg *MountConfig) (*os.File, error) {
 	if cfg.DebugLogger != nil {
 		cfg.DebugLogger.Println("Preparing for direct mounting")
 	}
+	// fusermount(1) always opens /dev/fuse, so it can't be used with a custom
+	// device path.
+	devPath := cfg.DevicePath
+	fallback := errFallback
+	if devPath == "" {
+		devPath = "/dev/fuse"
+	} else {
+		fallback = fmt.Errorf(
+			"mounting with DevicePath %q requires CAP_SYS_ADMIN, since fusermount "+
+				"can only use /dev/fuse",
+			devPath)
+	}
+
+	if cfg.BlockDevice != "" {
+		fallback = fmt.Errorf(
+			"mounting on BlockDevice %q requires CAP_SYS_ADMIN",
+			cfg.BlockDevice)
+	}
+
 	// We use syscall.Open + os.NewFile instead of os.OpenFile so that the file
 	// is opened in blocking mode. When opened in non-blocking mode, the Go
 	// runtime tries to use poll(2), which does not work with /dev/fuse.
-	fd, err := syscall.Open("/dev/fuse", syscall.O_RDWR, 0644)
+	fd, err := syscall.Open(devPath, syscall.O_RDWR, 0644)
 	if err != nil {
+		if fallback != errFallback {
+			return nil, fmt.Errorf("opening fuse device %q: %w", devPath, err)
+		}
 		return nil, errFallback
 	}
-	dev := os.NewFile(uintptr(fd), "/dev/fuse")
+	dev := os.NewFile(uintptr(fd), devPath)
 
 	if cfg.DebugLogger != nil {
-		cfg.DebugLogger.Println("Successfully opened the /dev/fuse in blocking mode")
+		cfg.DebugLogger.Printf("Successfully opened %s in blocking mode", devPath)
 	}
 	// As per libfuse/fusermount.c:847: https://bit.ly/2SgtWYM#L847
 	data := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d",
@@ -87,6 +109,12 @@ func directmount(dir string, cfg *MountConfig) (*os.File, error) {
 	fsname := opts["fsname"]
 	delete(opts, "fsname") // handled via fstype mount(2) parameter
 	fstype := "fuse"
+	if cfg.BlockDevice != "" {
+		// The kernel reads the device from the source parameter, and has no
+		// use for a file system name.
+		fsname = cfg.BlockDevice
+		fstype = "fuseblk"
+	}
 	if subtype, ok := opts["subtype"]; ok {
 		fstype += "." + subtype
 	}
@@ -96,16 +124,21 @@ func directmount(dir string, cfg *MountConfig) (*os.File, error) {
 	if cfg.DebugLogger != nil {
 		cfg.DebugLogger.Println("Starting the unix mounting")
 	}
-	if err := unix.Mount(
+	step := startMountStep(
+		cfg,
+		fmt.Sprintf("mount(2) of %s on %s with %q", fstype, dir, data))
+	err = unix.Mount(
 		fsname,    // source
 		dir,       // target
 		fstype,    // fstype
 		mountflag, // mountflag
 		data,      // data
-	); err != nil {
+	)
+	step.done(err)
+	if err != nil {
+		dev.Close()
 		if err == syscall.EPERM {
-			return nil, errFallback
-
+			return nil, fallback
 		}
 		return nil, err
 	}
@@ -150,7 +183,7 @@ func mount(dir string, cfg *MountConfig, ready chan<- error) (*os.File, error) {
 			"--",
 			dir,
 		}
-		dev, err := fusermount(fusermountPath, argv, []string{}, true, cfg.DebugLogger)
+		dev, err := fusermount(fusermountPath, argv, []string{}, true, cfg)
 		if err == nil {
 			return dev, nil
 		}
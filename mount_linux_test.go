@@ -1,6 +1,11 @@
 package fuse
 
 import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -35,3 +40,89 @@ func Test_parseFuseFd(t *testing.T) {
 		}
 	})
 }
+
+// A server that replies to every op with ENOSYS.
+type enosysServer struct{}
+
+func (enosysServer) ServeOps(c *Connection) {
+	for {
+		ctx, _, err := c.ReadOp()
+		if err != nil {
+			return
+		}
+
+		c.Reply(ctx, ENOSYS)
+	}
+}
+
+func TestMountWithDevicePath(t *testing.T) {
+	// A custom device path requires mounting directly.
+	if os.Getuid() != 0 {
+		return
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// A link to /dev/fuse stands in for a bind mount of it.
+	devPath := path.Join(t.TempDir(), "fuse")
+	if err := os.Symlink("/dev/fuse", devPath); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	mfs, err := Mount(dir, enosysServer{}, &MountConfig{DevicePath: devPath})
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	if err := Unmount(mfs.Dir()); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+
+	if err := mfs.Join(ctx); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	// A missing device is reported clearly, rather than falling back to
+	// fusermount and /dev/fuse.
+	missing := path.Join(t.TempDir(), "missing")
+	_, err = Mount(dir, enosysServer{}, &MountConfig{DevicePath: missing})
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Mount with missing device: got %v", err)
+	}
+}
+
+func TestEnsureOpenFileLimit(t *testing.T) {
+	var orig syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &orig); err != nil {
+		t.Fatalf("Getrlimit: %v", err)
+	}
+
+	defer syscall.Setrlimit(syscall.RLIMIT_NOFILE, &orig)
+
+	// Lower the soft limit, then ask for more files than that.
+	lowered := orig
+	lowered.Cur = 64
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered); err != nil {
+		t.Fatalf("Setrlimit: %v", err)
+	}
+
+	if err := ensureOpenFileLimit(128); err != nil {
+		t.Fatalf("ensureOpenFileLimit: %v", err)
+	}
+
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatalf("Getrlimit: %v", err)
+	}
+
+	if lim.Cur != 128 || lim.Max != orig.Max {
+		t.Errorf("got limits %d/%d, want 128/%d", lim.Cur, lim.Max, orig.Max)
+	}
+
+	// More files than the kernel allows at all.
+	err := ensureOpenFileLimit(1 << 62)
+	if err == nil || !strings.Contains(err.Error(), "RLIMIT_NOFILE is 128") {
+		t.Errorf("impossible limit: got %v", err)
+	}
+}
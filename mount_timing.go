@@ -0,0 +1,83 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mountStep times one step of mounting or unmounting, e.g. a mount(2) call or
+// a run of fusermount. See MountConfig.SlowMountThreshold.
+type mountStep struct {
+	cfg   *MountConfig
+	desc  string
+	start time.Time
+}
+
+func startMountStep(cfg *MountConfig, desc string) mountStep {
+	return mountStep{
+		cfg:   cfg,
+		desc:  desc,
+		start: time.Now(),
+	}
+}
+
+// done reports how long the step took: always to the debug logger, and to the
+// error logger if it took at least SlowMountThreshold.
+func (s mountStep) done(err error) {
+	elapsed := time.Since(s.start)
+
+	outcome := "succeeded"
+	if err != nil {
+		outcome = fmt.Sprintf("failed (%v)", err)
+	}
+
+	if s.cfg.DebugLogger != nil {
+		s.cfg.DebugLogger.Printf("%s %s after %v", s.desc, outcome, elapsed)
+	}
+
+	threshold := s.cfg.SlowMountThreshold
+	if threshold > 0 && elapsed >= threshold && s.cfg.ErrorLogger != nil {
+		s.cfg.ErrorLogger.Printf(
+			"Slow mount step: %s %s after %v (threshold %v)",
+			s.desc, outcome, elapsed, threshold)
+	}
+}
+
+// describeCommand renders a command line for logging, quoting arguments that
+// contain spaces or are empty.
+func describeCommand(binary string, argv []string) string {
+	parts := make([]string, 0, 1+len(argv))
+	for _, a := range append([]string{binary}, argv...) {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'") {
+			a = fmt.Sprintf("%q", a)
+		}
+		parts = append(parts, a)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// describeEnv renders the environment given to a helper like fusermount for
+// logging. The variables inherited from our own environment may hold secrets,
+// so only their number is shown; the ones we add are shown in full.
+func describeEnv(inherited int, added []string) string {
+	return fmt.Sprintf(
+		"%s (plus %d inherited variables)",
+		strings.Join(added, " "),
+		inherited)
+}
@@ -0,0 +1,68 @@
+package fuse
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMountStep(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		sleep     time.Duration
+		err       error
+		wantSlow  string
+	}{
+		{"disabled", 0, 10 * time.Millisecond, nil, ""},
+		{"fast", time.Hour, 0, nil, ""},
+		{"slow", time.Millisecond, 10 * time.Millisecond, nil, "Slow mount step: Running fusermount succeeded"},
+		{"slow failure", time.Millisecond, 10 * time.Millisecond, errors.New("taco"), "Running fusermount failed (taco)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var errBuf, debugBuf bytes.Buffer
+			cfg := &MountConfig{
+				ErrorLogger:        log.New(&errBuf, "", 0),
+				DebugLogger:        log.New(&debugBuf, "", 0),
+				SlowMountThreshold: tc.threshold,
+			}
+
+			step := startMountStep(cfg, "Running fusermount")
+			time.Sleep(tc.sleep)
+			step.done(tc.err)
+
+			if tc.wantSlow == "" && errBuf.Len() != 0 {
+				t.Errorf("Unexpected error log: %q", errBuf.String())
+			}
+			if !strings.Contains(errBuf.String(), tc.wantSlow) {
+				t.Errorf("Error log %q doesn't contain %q", errBuf.String(), tc.wantSlow)
+			}
+			if !strings.Contains(debugBuf.String(), "Running fusermount") {
+				t.Errorf("Debug log %q doesn't mention the step", debugBuf.String())
+			}
+		})
+	}
+}
+
+func TestDescribeCommand(t *testing.T) {
+	got := describeCommand(
+		"/bin/fusermount3",
+		[]string{"-o", "fsname=my fs,allow_other", "--", ""})
+	want := `/bin/fusermount3 -o "fsname=my fs,allow_other" -- ""`
+	if got != want {
+		t.Errorf("describeCommand: got %s, want %s", got, want)
+	}
+}
+
+func TestDescribeEnv(t *testing.T) {
+	got := describeEnv(17, []string{"_FUSE_COMMFD=3", "_FUSE_COMMVERS=2"})
+	want := "_FUSE_COMMFD=3 _FUSE_COMMVERS=2 (plus 17 inherited variables)"
+	if got != want {
+		t.Errorf("describeEnv: got %q, want %q", got, want)
+	}
+}
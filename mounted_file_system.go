@@ -22,10 +22,14 @@ import (
 // MountedFileSystem represents the status of a mount operation, with a method
 // that waits for unmounting.
 type MountedFileSystem struct {
-	dir string
+	dir    string
+	kernel KernelInfo
+	conn   *Connection
 
-	// The result to return from Join. Not valid until the channel is closed.
+	// The result to return from Join, and the cause to return from Cause. Not
+	// valid until the channel is closed.
 	joinStatus          error
+	exitCause           ExitCause
 	joinStatusAvailable chan struct{}
 }
 
@@ -35,13 +39,29 @@ func (mfs *MountedFileSystem) Dir() string {
 	return mfs.dir
 }
 
+// KernelInfo reports what the kernel offered when the file system was
+// mounted, and what was negotiated with it.
+func (mfs *MountedFileSystem) KernelInfo() KernelInfo {
+	return mfs.kernel
+}
+
+// DroppedReplies returns the number of replies dropped because the kernel had
+// disconnected, e.g. because the file system was unmounted while ops were in
+// flight. See Connection.DroppedReplies.
+func (mfs *MountedFileSystem) DroppedReplies() uint64 {
+	return mfs.conn.DroppedReplies()
+}
+
 // Join blocks until a mounted file system has been unmounted. It does not
 // return successfully until all ops read from the connection have been
 // responded to (i.e. the file system server has finished processing all
 // in-flight ops).
 //
 // The return value will be non-nil if anything unexpected happened while
-// serving. May be called multiple times.
+// serving: an *ExitError if the connection failed other than by the file
+// system being unmounted or the connection aborted, and otherwise any error
+// closing the connection. May be called multiple times. Replies dropped
+// because they raced with the unmount don't make it fail; see DroppedReplies.
 func (mfs *MountedFileSystem) Join(ctx context.Context) error {
 	select {
 	case <-mfs.joinStatusAvailable:
@@ -51,12 +71,24 @@ func (mfs *MountedFileSystem) Join(ctx context.Context) error {
 	}
 }
 
+// Cause returns why the connection stopped being served, as described on
+// ExitCause, and the error that Join returns. It returns ExitRunning until
+// Join would return.
+func (mfs *MountedFileSystem) Cause() (ExitCause, error) {
+	select {
+	case <-mfs.joinStatusAvailable:
+		return mfs.exitCause, mfs.joinStatus
+	default:
+		return ExitRunning, nil
+	}
+}
+
 // GetFuseContext implements the equiv. of FUSE-C fuse_get_context() and thus
 // returns the UID / GID / PID associated with all FUSE requests send by the kernel.
 // ctx parameter must be one of the context from the fuseops handlers (e.g.: CreateFile)
 func (mfs *MountedFileSystem) GetFuseContext(ctx context.Context) (uid, gid, pid uint32, err error) {
 	foo := ctx.Value(contextKey)
-	state, ok := foo.(opState)
+	state, ok := foo.(*opState)
 	if !ok {
 		return 0, 0, 0, fmt.Errorf("GetFuseContext called with invalid context: %#v", ctx)
 	}
@@ -1,6 +1,7 @@
 package fuse
 
 import (
+	"os"
 	"unsafe"
 
 	"github.com/jacobsa/fuse/fuseops"
@@ -13,12 +14,18 @@ import (
 type Notifier struct {
 	inodeInvalidations  chan invalidateInodeCommand
 	dentryInvalidations chan invalidateEntryCommand
+	stores              chan storeCommand
+	pollWakeups         chan pollWakeupCommand
+	backings            chan backingCommand
 }
 
 func NewNotifier() *Notifier {
 	return &Notifier{
 		inodeInvalidations:  make(chan invalidateInodeCommand),
 		dentryInvalidations: make(chan invalidateEntryCommand),
+		stores:              make(chan storeCommand),
+		pollWakeups:         make(chan pollWakeupCommand),
+		backings:            make(chan backingCommand),
 	}
 }
 
@@ -37,6 +44,30 @@ type invalidateEntryCommand struct {
 	done chan<- error
 }
 
+type storeCommand struct {
+	inode  fuseops.InodeID
+	offset int64
+	data   []byte
+	done   chan<- error
+}
+
+type pollWakeupCommand struct {
+	handle fuseops.PollHandle
+	done   chan<- error
+}
+
+// Registers f if non-nil, and otherwise unregisters id.
+type backingCommand struct {
+	f    *os.File
+	id   fuseops.BackingID
+	done chan<- backingResult
+}
+
+type backingResult struct {
+	id  fuseops.BackingID
+	err error
+}
+
 // InvalidateInode notifies the kernel to invalidate an inode cache entry. See
 // the libfuse documentation at
 // https://libfuse.github.io/doxygen/fuse__lowlevel_8h.html#a9cb974af9745294ff446d11cba2422f1
@@ -65,6 +96,66 @@ func (n *Notifier) InvalidateEntry(parent fuseops.InodeID, name string) error {
 	return <-done
 }
 
+// Store pushes data into the kernel's page cache for an inode, as if it had
+// been returned by a read at the given offset. If the data extends past the
+// size of the file known to the kernel, the size is updated to match. See the
+// documentation for fuse_lowlevel_notify_store at
+// https://libfuse.github.io/doxygen/fuse__lowlevel_8h.html for more details.
+//
+// This lets a file system whose files change on the server side, for example
+// a log that is appended to, make the new contents visible to readers without
+// resorting to direct IO. Only the page cache is affected; the data is not
+// passed back to the file system in a WriteFileOp.
+//
+// Store blocks until the kernel write completes, and returns the error from
+// the kernel, if any. ENOENT indicates that the kernel has no cached inode
+// with the given ID, in which case there is nothing to update.
+func (n *Notifier) Store(inode fuseops.InodeID, offset int64, data []byte) error {
+	done := make(chan error)
+	n.stores <- storeCommand{inode, offset, data, done}
+	return <-done
+}
+
+// PollWakeup notifies the kernel that a file polled with the given handle,
+// as received in fuseops.PollOp.PollHandle, may have become ready, so that
+// the kernel polls it again and wakes any waiters it is ready for. See the
+// documentation for fuse_lowlevel_notify_poll at
+// https://libfuse.github.io/doxygen/fuse__lowlevel_8h.html for more details.
+//
+// PollWakeup blocks until the kernel write completes, and returns the error
+// from the kernel, if any. The kernel ignores handles it no longer knows,
+// e.g. because the file has been closed.
+func (n *Notifier) PollWakeup(handle fuseops.PollHandle) error {
+	done := make(chan error)
+	n.pollWakeups <- pollWakeupCommand{handle, done}
+	return <-done
+}
+
+// OpenBacking registers a file with the kernel as a backing file for files
+// opened in passthrough mode, returning the ID with which to refer to it in
+// fuseops.OpenFileOp.BackingID and CreateFileOp.BackingID. The kernel takes
+// its own reference to the file, so f may be closed once this returns. See
+// MountConfig.EnablePassthrough.
+//
+// The kernel looks the ID up only once it receives the reply to the open, so
+// keep it registered until at least the matching ReleaseFileHandleOp, or for
+// as long as the inode may be opened again. EPERM indicates that the process
+// lacks CAP_SYS_ADMIN or that passthrough wasn't negotiated.
+func (n *Notifier) OpenBacking(f *os.File) (fuseops.BackingID, error) {
+	done := make(chan backingResult)
+	n.backings <- backingCommand{f: f, done: done}
+	r := <-done
+	return r.id, r.err
+}
+
+// CloseBacking unregisters a backing file registered with OpenBacking. Files
+// already opened with its ID are unaffected.
+func (n *Notifier) CloseBacking(id fuseops.BackingID) error {
+	done := make(chan backingResult)
+	n.backings <- backingCommand{id: id, done: done}
+	return (<-done).err
+}
+
 func serviceInodeInvalidation(c *Connection, inode fuseops.InodeID, offset, length int64) error {
 	outMsg := c.getOutMessage()
 	defer c.putOutMessage(outMsg)
@@ -101,6 +192,48 @@ func serviceEntryInval(c *Connection, parent fuseops.InodeID, name string) error
 	return c.writeOutMessage(outMsg)
 }
 
+func serviceStore(c *Connection, inode fuseops.InodeID, offset int64, data []byte) error {
+	outMsg := c.getOutMessage()
+	defer c.putOutMessage(outMsg)
+
+	cmd := fusekernel.NotifyStoreOut{
+		Nodeid: uint64(inode),
+		Offset: uint64(offset),
+		Size:   uint32(len(data)),
+	}
+	outMsg.Append(unsafe.Slice((*byte)(unsafe.Pointer(&cmd)), int(unsafe.Sizeof(cmd))))
+	outMsg.Append(data)
+
+	outMsg.OutHeader().Error = fusekernel.NotifyCodeStore
+	outMsg.OutHeader().Len = uint32(outMsg.Len())
+	return c.writeOutMessage(outMsg)
+}
+
+func servicePollWakeup(c *Connection, handle fuseops.PollHandle) error {
+	outMsg := c.getOutMessage()
+	defer c.putOutMessage(outMsg)
+
+	cmd := fusekernel.NotifyPollWakeupOut{
+		Kh: uint64(handle),
+	}
+	outMsg.Append(unsafe.Slice((*byte)(unsafe.Pointer(&cmd)), int(unsafe.Sizeof(cmd))))
+
+	outMsg.OutHeader().Error = fusekernel.NotifyCodePoll
+	outMsg.OutHeader().Len = uint32(outMsg.Len())
+	return c.writeOutMessage(outMsg)
+}
+
+// Invalidate the kernel's state for a stale inode. See StaleError.
+func (c *Connection) invalidateStale(e *StaleError) {
+	if e.Name != "" {
+		serviceEntryInval(c, e.Parent, e.Name)
+	}
+
+	if e.Inode != 0 {
+		serviceInodeInvalidation(c, e.Inode, 0, 0)
+	}
+}
+
 func (n *Notifier) notify(c *Connection, terminate <-chan struct{}) {
 	for {
 		select {
@@ -108,6 +241,18 @@ func (n *Notifier) notify(c *Connection, terminate <-chan struct{}) {
 			i.done <- serviceInodeInvalidation(c, i.inode, i.offset, i.length)
 		case e := <-n.dentryInvalidations:
 			e.done <- serviceEntryInval(c, e.parent, e.name)
+		case s := <-n.stores:
+			s.done <- serviceStore(c, s.inode, s.offset, s.data)
+		case p := <-n.pollWakeups:
+			p.done <- servicePollWakeup(c, p.handle)
+		case b := <-n.backings:
+			var r backingResult
+			if b.f != nil {
+				r.id, r.err = c.openBacking(b.f)
+			} else {
+				r.err = c.closeBacking(b.id)
+			}
+			b.done <- r
 		case <-terminate:
 			return
 		}
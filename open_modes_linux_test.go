@@ -0,0 +1,83 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A logFS whose log may also be read, and whose times may be set.
+type readableLogFS struct {
+	*logFS
+}
+
+func (fs readableLogFS) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs readableLogFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Offset < int64(len(fs.contents)) {
+		op.BytesRead = copy(op.Dst, fs.contents[op.Offset:])
+	}
+
+	return nil
+}
+
+func TestEnforceOpenModes_WritebackCache(t *testing.T) {
+	fs := readableLogFS{&logFS{contents: []byte("taco")}}
+	mfs, err := fuse.Mount(
+		t.TempDir(),
+		fuseutil.NewFileSystemServer(fs),
+		&fuse.MountConfig{EnforceOpenModes: true})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	f, err := os.OpenFile(path.Join(mfs.Dir(), "log"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	// With writeback caching, the kernel fills the rest of the page written by
+	// reading it through the write-only handle.
+	if _, err := f.WriteAt([]byte("burrito"), 2); err != nil {
+		t.Errorf("WriteAt: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if got, want := string(fs.contents), "taburrito"; got != want {
+		t.Errorf("Contents: got %q, want %q", got, want)
+	}
+}
@@ -37,12 +37,15 @@ type initOp struct {
 	Kernel fusekernel.Protocol
 
 	// In/out
-	Flags fusekernel.InitFlags
+	Flags  fusekernel.InitFlags
+	Flags2 fusekernel.InitFlags2
 
 	// Out
-	Library       fusekernel.Protocol
-	MaxReadahead  uint32
-	MaxBackground uint16
-	MaxWrite      uint32
-	MaxPages      uint16
+	Library             fusekernel.Protocol
+	MaxReadahead        uint32
+	MaxBackground       uint16
+	CongestionThreshold uint16
+	MaxWrite            uint32
+	MaxPages            uint16
+	MaxStackDepth       uint32
 }
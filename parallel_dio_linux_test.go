@@ -0,0 +1,94 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A dioFS whose file is opened with direct I/O, and with parallel direct
+// writes if parallel is set.
+type parallelDIOFS struct {
+	dioFS
+	parallel bool
+}
+
+func (fs *parallelDIOFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	op.UseDirectIO = true
+	op.ParallelDirectWrites = fs.parallel
+	return nil
+}
+
+func TestParallelDirectWrites(t *testing.T) {
+	for _, tc := range []struct {
+		parallel bool
+		maxMinor uint32
+	}{
+		{false, 0},
+		{true, 0},
+		// The flag isn't sent for earlier protocol versions.
+		{true, 37},
+	} {
+		fs := &parallelDIOFS{
+			dioFS:    dioFS{latency: 50 * time.Millisecond},
+			parallel: tc.parallel,
+		}
+
+		mfs, err := fuse.Mount(
+			t.TempDir(),
+			fuseutil.NewFileSystemServer(fs),
+			&fuse.MountConfig{MaxProtocolMinor: tc.maxMinor})
+
+		if err != nil {
+			t.Fatalf("fuse.Mount: %v", err)
+		}
+
+		t.Cleanup(func() {
+			if err := fuse.Unmount(mfs.Dir()); err != nil {
+				t.Errorf("Unmount: %v", err)
+			}
+
+			if err := mfs.Join(context.Background()); err != nil {
+				t.Errorf("Joining: %v", err)
+			}
+		})
+
+		f, err := os.OpenFile(path.Join(mfs.Dir(), "foo"), os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+
+		// Write to different pages within the file at once.
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := f.WriteAt(make([]byte, 4096), int64(i)*4096); err != nil {
+					t.Errorf("WriteAt: %v", err)
+				}
+			}()
+		}
+
+		wg.Wait()
+		f.Close()
+
+		fs.mu.Lock()
+		overlapped := fs.maxInFlight > 1
+		fs.mu.Unlock()
+
+		want := tc.parallel && mfs.KernelInfo().Protocol.Minor >= 38
+		if overlapped != want {
+			t.Errorf("%+v: writes overlapped: %v", tc, overlapped)
+		}
+	}
+}
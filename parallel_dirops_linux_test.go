@@ -0,0 +1,113 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system in which every name in the root directory exists, and whose
+// lookups wait a while for another to be in flight at the same time.
+type parallelLookupFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	inFlight int
+
+	// Set when two lookups have been in flight at once.
+	overlapped chan struct{}
+}
+
+func (fs *parallelLookupFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0444}
+	if op.Inode == fuseops.RootInodeID {
+		op.Attributes.Mode = 0777 | os.ModeDir
+	}
+
+	return nil
+}
+
+func (fs *parallelLookupFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	fs.inFlight++
+	if fs.inFlight == 2 {
+		close(fs.overlapped)
+	}
+	fs.mu.Unlock()
+
+	defer func() {
+		fs.mu.Lock()
+		fs.inFlight--
+		fs.mu.Unlock()
+	}()
+
+	select {
+	case <-fs.overlapped:
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0444}
+	return nil
+}
+
+func TestParallelDirOps(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		ctx := context.Background()
+		fs := &parallelLookupFS{overlapped: make(chan struct{})}
+		mfs, err := fuse.Mount(
+			t.TempDir(),
+			fuseutil.NewFileSystemServer(fs),
+			&fuse.MountConfig{EnableParallelDirOps: enabled})
+
+		if err != nil {
+			t.Fatalf("fuse.Mount: %v", err)
+		}
+
+		// Look up two names in the root directory at once.
+		var wg sync.WaitGroup
+		for _, name := range []string{"foo", "bar"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := os.Stat(path.Join(mfs.Dir(), name)); err != nil {
+					t.Errorf("Stat: %v", err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		var overlapped bool
+		select {
+		case <-fs.overlapped:
+			overlapped = true
+		default:
+		}
+
+		if overlapped != enabled {
+			t.Errorf("EnableParallelDirOps %v: lookups overlapped: %v", enabled, overlapped)
+		}
+
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Fatalf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(ctx); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+// Register a backing file with the kernel. See Notifier.OpenBacking.
+func (c *Connection) openBacking(f *os.File) (fuseops.BackingID, error) {
+	m := fusekernel.BackingMap{Fd: int32(f.Fd())}
+	id, _, errno := unix.Syscall(
+		unix.SYS_IOCTL,
+		c.dev.Fd(),
+		fusekernel.DevIocBackingOpen,
+		uintptr(unsafe.Pointer(&m)))
+
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return fuseops.BackingID(id), nil
+}
+
+// Unregister a backing file. See Notifier.CloseBacking.
+func (c *Connection) closeBacking(id fuseops.BackingID) error {
+	arg := uint32(id)
+	_, _, errno := unix.Syscall(
+		unix.SYS_IOCTL,
+		c.dev.Fd(),
+		fusekernel.DevIocBackingClose,
+		uintptr(unsafe.Pointer(&arg)))
+
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system whose file "foo" is opened in passthrough mode to a backing
+// file, and which fails any read or write that reaches it.
+type passthroughFS struct {
+	accessFS
+	notifier *fuse.Notifier
+	backing  *os.File
+}
+
+func (fs *passthroughFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	attrs := fs.accessFS.attributes(inode)
+	if inode != fuseops.RootInodeID {
+		if fi, err := fs.backing.Stat(); err == nil {
+			attrs.Size = uint64(fi.Size())
+		}
+	}
+
+	return attrs
+}
+
+func (fs *passthroughFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	return nil
+}
+
+func (fs *passthroughFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if err := fs.accessFS.LookUpInode(ctx, op); err != nil {
+		return err
+	}
+
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	return nil
+}
+
+func (fs *passthroughFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	id, err := fs.notifier.OpenBacking(fs.backing)
+	if err != nil {
+		return err
+	}
+
+	op.BackingID = id
+	op.Handle = fuseops.HandleID(id)
+	return nil
+}
+
+func (fs *passthroughFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return fs.notifier.CloseBacking(fuseops.BackingID(op.Handle))
+}
+
+func (fs *passthroughFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	return fuse.EIO
+}
+
+func (fs *passthroughFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	return fuse.EIO
+}
+
+func mountPassthrough(t *testing.T, enabled bool) (*fuse.MountedFileSystem, *os.File) {
+	backing, err := os.Create(path.Join(t.TempDir(), "backing"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() { backing.Close() })
+
+	if _, err := backing.WriteString("taco"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+
+	n := fuse.NewNotifier()
+	fs := &passthroughFS{notifier: n, backing: backing}
+	mfs, err := fuse.Mount(
+		t.TempDir(),
+		fuse.NewServerWithNotifier(n, fuseutil.NewFileSystemServer(fs)),
+		&fuse.MountConfig{EnablePassthrough: enabled})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	return mfs, backing
+}
+
+func TestPassthrough(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Registering backing files requires CAP_SYS_ADMIN")
+	}
+
+	mfs, backing := mountPassthrough(t, true)
+	features := mfs.KernelInfo().Features
+	if !slices.Contains(features, "PASSTHROUGH") {
+		t.Skipf("Kernel doesn't support passthrough: %v", features)
+	}
+
+	// Passthrough excludes writeback caching.
+	if slices.Contains(features, "WRITEBACK_CACHE") {
+		t.Errorf("WRITEBACK_CACHE present in %v", features)
+	}
+
+	p := path.Join(mfs.Dir(), "foo")
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 4)
+	if n, err := f.ReadAt(buf, 0); n != 4 || err != nil || string(buf) != "taco" {
+		t.Errorf("ReadAt: got (%d, %v, %q)", n, err, buf)
+	}
+
+	if _, err := f.WriteAt([]byte("burrito"), 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+
+	b, err := os.ReadFile(backing.Name())
+	if err != nil || string(b) != "burrito" {
+		t.Errorf("Backing file: got (%q, %v)", b, err)
+	}
+}
+
+func TestPassthrough_NotNegotiated(t *testing.T) {
+	mfs, _ := mountPassthrough(t, false)
+	if slices.Contains(mfs.KernelInfo().Features, "PASSTHROUGH") {
+		t.Errorf("PASSTHROUGH negotiated without EnablePassthrough")
+	}
+
+	// The file system can't register the backing file, so the open fails.
+	_, err := os.Open(path.Join(mfs.Dir(), "foo"))
+	if !errors.Is(err, syscall.EPERM) {
+		t.Errorf("Open: got %v, want EPERM", err)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+// +build !linux
+
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Passthrough is Linux only.
+func (c *Connection) openBacking(f *os.File) (fuseops.BackingID, error) {
+	return 0, syscall.ENOSYS
+}
+
+func (c *Connection) closeBacking(id fuseops.BackingID) error {
+	return syscall.ENOSYS
+}
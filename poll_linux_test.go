@@ -0,0 +1,88 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+	"golang.org/x/sys/unix"
+)
+
+func TestPollOp(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	in := fusekernel.PollIn{
+		Fh:     7,
+		Kh:     3,
+		Flags:  fusekernel.PollScheduleNotify,
+		Events: unix.POLLIN | unix.POLLOUT,
+	}
+
+	payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+	if _, err := peer.Write(request(1, fusekernel.OpPoll, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	pollOp, ok := op.(*fuseops.PollOp)
+	if !ok || pollOp.Handle != 7 || pollOp.PollHandle != 3 || pollOp.Events != unix.POLLIN|unix.POLLOUT {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	pollOp.Revents = unix.POLLOUT
+	c.Reply(ctx, nil)
+
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	want := int(unsafe.Sizeof(fusekernel.OutHeader{}) + unsafe.Sizeof(fusekernel.PollOut{}))
+	if err != nil || n != want {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	if revents := binary.NativeEndian.Uint32(buf[n-8:]); revents != unix.POLLOUT {
+		t.Errorf("revents: got 0x%x, want 0x%x", revents, unix.POLLOUT)
+	}
+
+	// Without the flag, the caller won't wait, and there is nothing to wake.
+	in.Flags = 0
+	if _, err := peer.Write(request(2, fusekernel.OpPoll, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, op, err = c.ReadOp(); err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if h := op.(*fuseops.PollOp).PollHandle; h != 0 {
+		t.Errorf("PollHandle: got %d, want 0", h)
+	}
+}
+
+func TestPollWakeup(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	if err := servicePollWakeup(c, 3); err != nil {
+		t.Fatalf("servicePollWakeup: %v", err)
+	}
+
+	var buf [64]byte
+	n, err := peer.Read(buf[:])
+	want := int(unsafe.Sizeof(fusekernel.OutHeader{}) + unsafe.Sizeof(fusekernel.NotifyPollWakeupOut{}))
+	if err != nil || n != want {
+		t.Fatalf("Read: %d, %v", n, err)
+	}
+
+	if code := int32(binary.NativeEndian.Uint32(buf[4:])); code != fusekernel.NotifyCodePoll {
+		t.Errorf("code: got %d, want %d", code, fusekernel.NotifyCodePoll)
+	}
+
+	if kh := binary.NativeEndian.Uint64(buf[n-8:]); kh != 3 {
+		t.Errorf("kh: got %d, want 3", kh)
+	}
+}
@@ -0,0 +1,155 @@
+package fuse_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system containing a file "foo" with the supplied contents, which the
+// kernel may cache for an hour, and which counts the ReadFileOps it receives.
+type prewarmFS struct {
+	fuseutil.NotImplementedFileSystem
+	contents []byte
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (fs *prewarmFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0555 | os.ModeDir}
+	}
+
+	return fuseops.InodeAttributes{Nlink: 1, Mode: 0444, Size: uint64(len(fs.contents))}
+}
+
+func (fs *prewarmFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.Attributes = fs.attributes(op.Inode)
+	op.AttributesExpiration = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (fs *prewarmFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "foo" {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	op.Entry.AttributesExpiration = time.Now().Add(time.Hour)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	return nil
+}
+
+func (fs *prewarmFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	op.KeepPageCache = true
+	return nil
+}
+
+// Read from the backend, standing in for the file system's cache.
+func (fs *prewarmFS) read(
+	ctx context.Context,
+	inode fuseops.InodeID,
+	dst []byte,
+	offset int64) (int, error) {
+	if offset >= int64(len(fs.contents)) {
+		return 0, io.EOF
+	}
+
+	return copy(dst, fs.contents[offset:]), nil
+}
+
+func (fs *prewarmFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	fs.reads++
+	fs.mu.Unlock()
+
+	n, _ := fs.read(ctx, op.Inode, op.Dst, op.Offset)
+	op.BytesRead = n
+	return nil
+}
+
+func (fs *prewarmFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
+
+func TestPrewarm(t *testing.T) {
+	contents := bytes.Repeat([]byte("taco"), 10000)
+	fs := &prewarmFS{contents: contents}
+
+	n := fuse.NewNotifier()
+	server := fuse.NewServerWithNotifier(n, fuseutil.NewFileSystemServer(fs))
+	mfs, err := fuse.Mount(t.TempDir(), server, &fuse.MountConfig{})
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	// The kernel must have looked the file up to accept its data. Data for
+	// inodes it doesn't know is read but not pushed.
+	p := path.Join(mfs.Dir(), "foo")
+	if _, err := os.Stat(p); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	err = fuseutil.Prewarm(
+		context.Background(),
+		[]fuseutil.PrewarmRange{
+			{Inode: fuseops.RootInodeID + 1},
+			{Inode: fuseops.RootInodeID + 2, Length: 10},
+		},
+		fuseutil.PrewarmConfig{
+			Read:      fs.read,
+			Notifier:  n,
+			ChunkSize: 8192,
+		})
+
+	if err != nil {
+		t.Fatalf("Prewarm: %v", err)
+	}
+
+	// The file is then read from the page cache.
+	got, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if !bytes.Equal(got, contents) {
+		t.Errorf("ReadFile: got %d bytes, want %d", len(got), len(contents))
+	}
+
+	fs.mu.Lock()
+	if fs.reads != 0 {
+		t.Errorf("%d ReadFileOps after prewarming", fs.reads)
+	}
+	fs.mu.Unlock()
+}
@@ -0,0 +1,227 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// A Profiler records where the time goes for a random sample of requests, to
+// help tell whether latency comes from the kernel, this package, or the file
+// system's handlers. Supply one in MountConfig.Profiler. Each mount needs its
+// own Profiler, since requests are numbered per mount.
+//
+// Each sampled request's time is broken down into phases (see ProfileSample),
+// which are summarized per op type by Summary and WriteSummary, and passed to
+// OnSample if it is set.
+type Profiler struct {
+	// The fraction of requests to sample, from 0 to 1.
+	SampleRate float64
+
+	// If non-nil, called with each sample. It must not block.
+	OnSample func(ProfileSample)
+
+	mu sync.Mutex
+
+	// The unique IDs of the most recently read requests and the times at which
+	// they were read, in a ring, for estimating kernel queue times.
+	//
+	// GUARDED_BY(mu)
+	recent     [256]profilerRead
+	recentNext int
+
+	// GUARDED_BY(mu)
+	summaries map[string]*ProfileSummary
+}
+
+type profilerRead struct {
+	unique uint64
+	time   time.Time
+}
+
+// ProfileSample is the timing breakdown of a single request.
+type ProfileSample struct {
+	// The type of the op, without the "Op" suffix, e.g. "LookUpInode".
+	Op string
+
+	// When the request was read from the kernel.
+	Start time.Time
+
+	// A lower bound on how long the request waited in the kernel's queue
+	// before it was read. The kernel doesn't say when it queued a request, but
+	// it numbers requests in the order they are queued, so a request must
+	// already have been queued when one with a higher number was read. This is
+	// zero when no such request was read first, as is usual when the file
+	// system keeps up.
+	Queue time.Duration
+
+	// The time taken by this package to decode the request into an op.
+	Decode time.Duration
+
+	// The time from the op being returned by Connection.ReadOp to
+	// Connection.Reply being called, which is spent in the file system's
+	// handler and in any queueing within the Server.
+	Handler time.Duration
+
+	// The time taken by Connection.Reply to encode the reply and write it to
+	// the kernel.
+	Reply time.Duration
+}
+
+// ProfileSummary summarizes the samples for one op type.
+type ProfileSummary struct {
+	Op    string
+	Count int
+
+	Queue   PhaseSummary
+	Decode  PhaseSummary
+	Handler PhaseSummary
+	Reply   PhaseSummary
+}
+
+// PhaseSummary summarizes the time spent in one phase across samples.
+type PhaseSummary struct {
+	Total time.Duration
+	Max   time.Duration
+}
+
+func (s *PhaseSummary) add(d time.Duration) {
+	s.Total += d
+	if d > s.Max {
+		s.Max = d
+	}
+}
+
+// Mean returns the mean time spent in the phase by the supplied number of
+// samples.
+func (s PhaseSummary) Mean(count int) time.Duration {
+	if count == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(count)
+}
+
+// NewProfiler returns a profiler that samples the supplied fraction of
+// requests.
+func NewProfiler(sampleRate float64) *Profiler {
+	return &Profiler{SampleRate: sampleRate}
+}
+
+// Note that a request has been read, returning the start of a sample for it
+// if it should be sampled.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *Profiler) read(unique uint64, now time.Time) *ProfileSample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recent[p.recentNext] = profilerRead{unique, now}
+	p.recentNext = (p.recentNext + 1) % len(p.recent)
+
+	if p.SampleRate <= 0 || rand.Float64() >= p.SampleRate {
+		return nil
+	}
+
+	// Find the earliest read of a later request.
+	s := &ProfileSample{Start: now}
+	for _, r := range p.recent {
+		if r.unique > unique && now.Sub(r.time) > s.Queue {
+			s.Queue = now.Sub(r.time)
+		}
+	}
+
+	return s
+}
+
+// Record a completed sample.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *Profiler) record(s *ProfileSample) {
+	if p.OnSample != nil {
+		p.OnSample(*s)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.summaries == nil {
+		p.summaries = make(map[string]*ProfileSummary)
+	}
+
+	sum := p.summaries[s.Op]
+	if sum == nil {
+		sum = &ProfileSummary{Op: s.Op}
+		p.summaries[s.Op] = sum
+	}
+
+	sum.Count++
+	sum.Queue.add(s.Queue)
+	sum.Decode.add(s.Decode)
+	sum.Handler.add(s.Handler)
+	sum.Reply.add(s.Reply)
+}
+
+// Summary returns a summary of the samples recorded since the profiler was
+// created or last reset, for each op type, ordered by op type.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *Profiler) Summary() []ProfileSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var summaries []ProfileSummary
+	for _, s := range p.summaries {
+		summaries = append(summaries, *s)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Op < summaries[j].Op
+	})
+
+	return summaries
+}
+
+// Reset discards the samples recorded so far.
+//
+// LOCKS_EXCLUDED(p.mu)
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.summaries = nil
+}
+
+// WriteSummary writes the summary as a table of the mean and maximum time
+// spent in each phase by each op type.
+func (p *Profiler) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "op\tcount\tqueue\tdecode\thandler\treply\t")
+	for _, s := range p.Summary() {
+		fmt.Fprintf(tw, "%s\t%d\t", s.Op, s.Count)
+		for _, phase := range []PhaseSummary{s.Queue, s.Decode, s.Handler, s.Reply} {
+			fmt.Fprintf(tw, "%v/%v\t", phase.Mean(s.Count), phase.Max)
+		}
+
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
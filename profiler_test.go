@@ -0,0 +1,71 @@
+package fuse
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	var samples []ProfileSample
+	p := NewProfiler(1)
+	p.OnSample = func(s ProfileSample) { samples = append(samples, s) }
+
+	// Request 12 is read before request 10, which must therefore have been
+	// queued for at least the time between the two reads.
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range []struct {
+		unique uint64
+		at     time.Duration
+	}{
+		{8, 0},
+		{12, time.Millisecond},
+		{14, 2 * time.Millisecond},
+		{10, 5 * time.Millisecond},
+	} {
+		s := p.read(r.unique, t0.Add(r.at))
+		s.Op = "LookUpInode"
+		s.Handler = r.at
+		p.record(s)
+	}
+
+	if len(samples) != 4 {
+		t.Fatalf("got %d samples, want 4", len(samples))
+	}
+
+	if samples[1].Queue != 0 || samples[3].Queue != 4*time.Millisecond {
+		t.Errorf("unexpected queue times: %v, %v", samples[1].Queue, samples[3].Queue)
+	}
+
+	summary := p.Summary()
+	if len(summary) != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	s := summary[0]
+	if s.Op != "LookUpInode" || s.Count != 4 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+
+	if s.Handler.Mean(s.Count) != 2*time.Millisecond || s.Handler.Max != 5*time.Millisecond {
+		t.Errorf("handler: mean %v, max %v", s.Handler.Mean(s.Count), s.Handler.Max)
+	}
+
+	var buf strings.Builder
+	if err := p.WriteSummary(&buf); err != nil {
+		t.Fatalf("WriteSummary: %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	want := "LookUpInode 4 1ms/4ms 0s/0s 2ms/5ms 0s/0s"
+	if len(lines) < 2 || strings.Join(strings.Fields(lines[1]), " ") != want {
+		t.Errorf("unexpected table:\n%s", buf.String())
+	}
+
+	// Nothing is sampled at a rate of zero.
+	p.Reset()
+	p.SampleRate = 0
+	if s := p.read(16, t0); s != nil || len(p.Summary()) != 0 {
+		t.Errorf("sampled at rate zero: %+v", s)
+	}
+}
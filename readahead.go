@@ -0,0 +1,35 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Guess whether the read was issued by the kernel's readahead, setting
+// op.Readahead. Readahead reads extend a sequential run of reads through the
+// handle, and arrive while an earlier read is still in flight, which a process
+// waiting for its read(2) to return can't cause.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) classifyRead(op *fuseops.ReadFileOp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.handle(op.Handle)
+	op.Readahead = !r.directIO && r.inFlight > 0 && op.Offset == r.next
+	r.inFlight++
+	r.next = op.Offset + op.Size
+}
@@ -0,0 +1,51 @@
+package fuse
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+func TestClassifyReadahead(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+	c.cfg.ClassifyReadahead = true
+
+	read := func(unique uint64, offset uint64) *fuseops.ReadFileOp {
+		in := fusekernel.ReadIn{Fh: 7, Offset: offset, Size: 4096}
+		payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+		if _, err := peer.Write(request(unique, fusekernel.OpRead, payload)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+
+		_, op, err := c.ReadOp()
+		if err != nil {
+			t.Fatalf("ReadOp: %v", err)
+		}
+
+		return op.(*fuseops.ReadFileOp)
+	}
+
+	// A sequential read issued while another is in flight is readahead.
+	op1 := read(1, 0)
+	op2 := read(2, 4096)
+	if op1.Readahead || !op2.Readahead {
+		t.Errorf("got readahead %v, %v; want false, true", op1.Readahead, op2.Readahead)
+	}
+
+	// Reads that aren't sequential aren't.
+	op3 := read(3, 0)
+	if op3.Readahead {
+		t.Errorf("non-sequential read classified as readahead")
+	}
+
+	for _, op := range []*fuseops.ReadFileOp{op1, op2, op3} {
+		c.trackHandles(op, nil)
+	}
+
+	// Nor are those issued once the others are done.
+	if op := read(4, 4096); op.Readahead {
+		t.Errorf("read with none in flight classified as readahead")
+	}
+}
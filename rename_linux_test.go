@@ -0,0 +1,87 @@
+package fuse
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+func TestRenameFlags(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+
+	rename2 := func(unique uint64, flags fuseops.RenameFlags) {
+		in := fusekernel.Rename2In{Newdir: 3, Flags: uint32(flags)}
+		payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+		payload = append(payload, "taco\x00burrito\x00"...)
+		if _, err := peer.Write(request(unique, fusekernel.OpRename2, payload)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	// Without EnableRenameFlags, renames with flags are refused without being
+	// returned, and plain renames are returned as usual.
+	rename2(1, fuseops.RenameNoReplace)
+
+	in := fusekernel.RenameIn{Newdir: 3}
+	payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+	payload = append(payload, "taco\x00burrito\x00"...)
+	if _, err := peer.Write(request(2, fusekernel.OpRename, payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if op, ok := op.(*fuseops.RenameOp); !ok || op.NewName != "burrito" || op.Flags != 0 {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	var out fusekernel.OutHeader
+	buf := (*[unsafe.Sizeof(out)]byte)(unsafe.Pointer(&out))[:]
+	if _, err := peer.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if out.Unique != 1 || out.Error != -int32(syscall.ENOSYS) {
+		t.Errorf("unexpected reply: %+v", out)
+	}
+
+	c.Reply(ctx, nil)
+	if _, err := peer.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	// With it, they are returned with their flags.
+	c.cfg.EnableRenameFlags = true
+	rename2(3, fuseops.RenameExchange)
+
+	ctx, op, err = c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	want := fuseops.RenameOp{
+		OldParent: 1,
+		OldName:   "taco",
+		NewParent: 3,
+		NewName:   "burrito",
+		Flags:     fuseops.RenameExchange,
+	}
+
+	got, ok := op.(*fuseops.RenameOp)
+	if !ok {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	got.OpContext = fuseops.OpContext{}
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+
+	c.Reply(ctx, nil)
+}
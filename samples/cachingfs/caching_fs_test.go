@@ -32,6 +32,8 @@ import (
 	"github.com/jacobsa/timeutil"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestCachingFS(t *testing.T) { RunTests(t) }
 
 ////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,624 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package casfs contains a sample file system whose file contents are stored
+// as content-addressed blobs, as a template for mounting artifact stores.
+package casfs
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// Config configures NewCASFS.
+type Config struct {
+	// The store holding the chunks of files, and the catalog holding their
+	// manifests. Files already in the catalog appear in the file system.
+	Store   BlobStore
+	Catalog *Catalog
+
+	// The size of the chunks into which files are split. If zero, 64 KiB is
+	// used.
+	ChunkSize int
+
+	// The number of chunks kept in the block cache. If zero, 256 are kept.
+	CacheChunks int
+}
+
+// Create a file system with a single directory, whose files are split into
+// chunks stored by hash in a BlobStore, and listed in manifests kept in a
+// Catalog.
+//
+// Writes are buffered in memory, and committed when the file is flushed (on
+// each close) or synced: the dirty chunks are hashed, those the store doesn't
+// already hold are uploaded, and a new manifest replaces the old one in the
+// catalog in a single step. So identical chunks, within a file or across
+// files, are stored once, and the catalog never refers to chunks that
+// haven't been stored. Until a file is committed, its new contents are
+// visible only through the mount. Chunks read from the store are kept in an
+// LRU block cache.
+func NewCASFS(cfg Config) fuse.Server {
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 64 << 10
+	}
+
+	if cfg.CacheChunks == 0 {
+		cfg.CacheChunks = 256
+	}
+
+	fs := &casFS{
+		store:     cfg.Store,
+		catalog:   cfg.Catalog,
+		chunkSize: cfg.ChunkSize,
+		cache:     newBlockCache(cfg.Store, cfg.CacheChunks),
+		zeros:     make([]byte, cfg.ChunkSize),
+		uid:       uint32(os.Getuid()),
+		gid:       uint32(os.Getgid()),
+		inodes:    make(map[fuseops.InodeID]*casInode),
+		children:  make(map[string]fuseops.InodeID),
+		nextInode: fuseops.RootInodeID + 1,
+	}
+
+	for _, name := range cfg.Catalog.Names() {
+		m, _ := cfg.Catalog.Manifest(name)
+		fs.newInode(name, m)
+	}
+
+	return fuseutil.NewFileSystemServer(fs)
+}
+
+type casFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	store     BlobStore
+	catalog   *Catalog
+	chunkSize int
+	cache     *blockCache
+	uid       uint32
+	gid       uint32
+
+	// A chunk of zeros.
+	zeros []byte
+
+	mu sync.Mutex
+
+	// The files, and the inode of each name in the directory.
+	//
+	// GUARDED_BY(mu)
+	inodes    map[fuseops.InodeID]*casInode
+	children  map[string]fuseops.InodeID
+	nextInode fuseops.InodeID
+}
+
+// A file, whose contents are those of its committed manifest, overlaid with
+// its dirty chunks.
+type casInode struct {
+	mu sync.Mutex
+
+	// The file's name, or empty once it has been unlinked, after which it is
+	// no longer committed.
+	//
+	// GUARDED_BY(mu)
+	name string
+
+	// The size and mtime of the file, and the chunks of the last committed
+	// manifest that are still part of it. Chunks past the end of the list are
+	// zeros.
+	//
+	// GUARDED_BY(mu)
+	size   int64
+	mtime  time.Time
+	chunks []Hash
+
+	// Chunks modified since the last commit, each a whole chunk long.
+	//
+	// GUARDED_BY(mu)
+	dirty map[int64][]byte
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *casFS) newInode(name string, m Manifest) fuseops.InodeID {
+	id := fs.nextInode
+	fs.nextInode++
+
+	fs.inodes[id] = &casInode{
+		name:   name,
+		size:   m.Size,
+		mtime:  m.Mtime,
+		chunks: m.Chunks,
+		dirty:  make(map[int64][]byte),
+	}
+
+	fs.children[name] = id
+	return id
+}
+
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *casFS) inode(id fuseops.InodeID) (*casInode, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	in, ok := fs.inodes[id]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+
+	return in, nil
+}
+
+// LOCKS_REQUIRED(in.mu)
+func (fs *casFS) attributes(in *casInode) fuseops.InodeAttributes {
+	return fuseops.InodeAttributes{
+		Size:  uint64(in.size),
+		Nlink: 1,
+		Mode:  0644,
+		Atime: in.mtime,
+		Mtime: in.mtime,
+		Ctime: in.mtime,
+		Uid:   fs.uid,
+		Gid:   fs.gid,
+	}
+}
+
+// Return the contents of the chunk with the supplied index, which the caller
+// must not modify.
+//
+// LOCKS_REQUIRED(in.mu)
+func (fs *casFS) chunk(
+	ctx context.Context,
+	in *casInode,
+	i int64) ([]byte, error) {
+	if data, ok := in.dirty[i]; ok {
+		return data, nil
+	}
+
+	if i < int64(len(in.chunks)) {
+		return fs.cache.get(ctx, in.chunks[i])
+	}
+
+	return fs.zeros, nil
+}
+
+// Return the dirty copy of the chunk with the supplied index, making one if
+// necessary.
+//
+// LOCKS_REQUIRED(in.mu)
+func (fs *casFS) dirtyChunk(
+	ctx context.Context,
+	in *casInode,
+	i int64) ([]byte, error) {
+	if data, ok := in.dirty[i]; ok {
+		return data, nil
+	}
+
+	data, err := fs.chunk(ctx, in, i)
+	if err != nil {
+		return nil, err
+	}
+
+	data = append([]byte(nil), data...)
+	in.dirty[i] = data
+	return data, nil
+}
+
+// Change the size of the file, keeping the bytes past its end zero.
+//
+// LOCKS_REQUIRED(in.mu)
+func (fs *casFS) truncate(ctx context.Context, in *casInode, size int64) error {
+	if size < in.size {
+		cs := int64(fs.chunkSize)
+		count := (size + cs - 1) / cs
+		for i := range in.dirty {
+			if i >= count {
+				delete(in.dirty, i)
+			}
+		}
+
+		in.chunks = in.chunks[:min(int64(len(in.chunks)), count)]
+
+		// Zero the rest of the last chunk.
+		if size%cs != 0 {
+			data, err := fs.dirtyChunk(ctx, in, size/cs)
+			if err != nil {
+				return err
+			}
+
+			clear(data[size%cs:])
+		}
+	}
+
+	in.size = size
+	return nil
+}
+
+// Store the dirty chunks of the file, and replace its manifest in the
+// catalog.
+//
+// LOCKS_REQUIRED(in.mu)
+func (fs *casFS) commit(ctx context.Context, in *casInode) error {
+	if in.name == "" {
+		return nil
+	}
+
+	cs := int64(fs.chunkSize)
+	m := Manifest{
+		Size:   in.size,
+		Mtime:  in.mtime,
+		Chunks: make([]Hash, (in.size+cs-1)/cs),
+	}
+
+	for i := range m.Chunks {
+		data, err := fs.chunk(ctx, in, int64(i))
+		if err != nil {
+			return err
+		}
+
+		if _, ok := in.dirty[int64(i)]; !ok && i < len(in.chunks) {
+			m.Chunks[i] = in.chunks[i]
+			continue
+		}
+
+		// Only upload chunks the store doesn't already have.
+		h := hashOf(data)
+		ok, err := fs.store.Has(ctx, h)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			if err := fs.store.Put(ctx, h, data); err != nil {
+				return err
+			}
+		}
+
+		fs.cache.add(h, data)
+		m.Chunks[i] = h
+	}
+
+	fs.catalog.Set(in.name, m)
+	in.chunks = m.Chunks
+	clear(in.dirty)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// FileSystem methods
+////////////////////////////////////////////////////////////////////////
+
+func (fs *casFS) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) error {
+	return nil
+}
+
+func (fs *casFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID {
+		return fuse.ENOENT
+	}
+
+	fs.mu.Lock()
+	id, ok := fs.children[op.Name]
+	in := fs.inodes[id]
+	fs.mu.Unlock()
+
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	op.Entry.Child = id
+	op.Entry.Attributes = fs.attributes(in)
+	return nil
+}
+
+func (fs *casFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	if op.Inode == fuseops.RootInodeID {
+		op.Attributes = fuseops.InodeAttributes{
+			Nlink: 1,
+			Mode:  0755 | os.ModeDir,
+			Uid:   fs.uid,
+			Gid:   fs.gid,
+		}
+
+		return nil
+	}
+
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	op.Attributes = fs.attributes(in)
+	return nil
+}
+
+func (fs *casFS) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if op.Size != nil {
+		if err := fs.truncate(ctx, in, int64(*op.Size)); err != nil {
+			return err
+		}
+
+		in.mtime = time.Now()
+	}
+
+	if op.Mtime != nil {
+		in.mtime = *op.Mtime
+	}
+
+	op.Attributes = fs.attributes(in)
+	return nil
+}
+
+func (fs *casFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	return nil
+}
+
+func (fs *casFS) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	if op.Parent != fuseops.RootInodeID {
+		return fuse.ENOENT
+	}
+
+	fs.mu.Lock()
+	if _, ok := fs.children[op.Name]; ok {
+		fs.mu.Unlock()
+		return fuse.EEXIST
+	}
+
+	id := fs.newInode(op.Name, Manifest{Mtime: time.Now()})
+	in := fs.inodes[id]
+	fs.mu.Unlock()
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	// Commit the empty file, so that it is in the catalog even if it is never
+	// written.
+	if err := fs.commit(ctx, in); err != nil {
+		return err
+	}
+
+	op.Entry.Child = id
+	op.Entry.Attributes = fs.attributes(in)
+	return nil
+}
+
+func (fs *casFS) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	fs.mu.Lock()
+	id, ok := fs.children[op.Name]
+	in := fs.inodes[id]
+	delete(fs.children, op.Name)
+	fs.mu.Unlock()
+
+	if op.Parent != fuseops.RootInodeID || !ok {
+		return fuse.ENOENT
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.name = ""
+	fs.catalog.remove(op.Name)
+	return nil
+}
+
+func (fs *casFS) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	if op.OldParent != fuseops.RootInodeID || op.NewParent != fuseops.RootInodeID {
+		return fuse.ENOENT
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	id, ok := fs.children[op.OldName]
+	if !ok {
+		return fuse.ENOENT
+	}
+
+	// The file replaced, if any, is no longer committed.
+	if replaced, ok := fs.children[op.NewName]; ok && replaced != id {
+		in := fs.inodes[replaced]
+		in.mu.Lock()
+		in.name = ""
+		in.mu.Unlock()
+	}
+
+	in := fs.inodes[id]
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	delete(fs.children, op.OldName)
+	fs.children[op.NewName] = id
+	in.name = op.NewName
+	fs.catalog.rename(op.OldName, op.NewName)
+	return nil
+}
+
+func (fs *casFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	return nil
+}
+
+func (fs *casFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	fs.mu.Lock()
+	var entries []fuseutil.Dirent
+	for name, id := range fs.children {
+		entries = append(entries, fuseutil.Dirent{
+			Inode: id,
+			Name:  name,
+			Type:  fuseutil.DT_File,
+		})
+	}
+	fs.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	for i := range entries {
+		entries[i].Offset = fuseops.DirOffset(i + 1)
+	}
+
+	if op.Offset > fuseops.DirOffset(len(entries)) {
+		return nil
+	}
+
+	for _, e := range entries[op.Offset:] {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], e)
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *casFS) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	return nil
+}
+
+func (fs *casFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	_, err := fs.inode(op.Inode)
+	return err
+}
+
+func (fs *casFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	cs := int64(fs.chunkSize)
+	end := min(op.Offset+int64(len(op.Dst)), in.size)
+	for off := op.Offset; off < end; {
+		data, err := fs.chunk(ctx, in, off/cs)
+		if err != nil {
+			return err
+		}
+
+		n := copy(op.Dst[off-op.Offset:end-op.Offset], data[off%cs:])
+		off += int64(n)
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *casFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	cs := int64(fs.chunkSize)
+	for written := 0; written < len(op.Data); {
+		off := op.Offset + int64(written)
+		data, err := fs.dirtyChunk(ctx, in, off/cs)
+		if err != nil {
+			return err
+		}
+
+		written += copy(data[off%cs:], op.Data[written:])
+	}
+
+	in.size = max(in.size, op.Offset+int64(len(op.Data)))
+	in.mtime = time.Now()
+	return nil
+}
+
+func (fs *casFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return fs.commit(ctx, in)
+}
+
+func (fs *casFS) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	// fsync(2) on the directory has nothing to commit.
+	if op.Inode == fuseops.RootInodeID {
+		return nil
+	}
+
+	in, err := fs.inode(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return fs.commit(ctx, in)
+}
+
+func (fs *casFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
@@ -0,0 +1,163 @@
+package casfs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/samples"
+	"github.com/jacobsa/fuse/samples/casfs"
+	. "github.com/jacobsa/ogletest"
+)
+
+func TestMain(m *testing.M) { samples.Main(m) }
+
+func TestCASFS(t *testing.T) { RunTests(t) }
+
+const chunkSize = 4096
+
+type CASFSTest struct {
+	samples.SampleTest
+	store   *casfs.MemBlobStore
+	catalog *casfs.Catalog
+}
+
+func init() { RegisterTestSuite(&CASFSTest{}) }
+
+func (t *CASFSTest) SetUp(ti *TestInfo) {
+	t.store = casfs.NewMemBlobStore()
+	t.catalog = casfs.NewCatalog()
+
+	// Two files sharing their first chunk, stored before mounting.
+	shared := bytes.Repeat([]byte("a"), chunkSize)
+	t.preload("foo", shared, bytes.Repeat([]byte("b"), chunkSize))
+	t.preload("bar", shared, bytes.Repeat([]byte("c"), chunkSize))
+
+	t.Server = casfs.NewCASFS(casfs.Config{
+		Store:     t.store,
+		Catalog:   t.catalog,
+		ChunkSize: chunkSize,
+	})
+
+	t.SampleTest.SetUp(ti)
+}
+
+// Store the chunks and add a manifest for them to the catalog, as another
+// writer of the store would.
+func (t *CASFSTest) preload(name string, chunks ...[]byte) {
+	var m casfs.Manifest
+	for _, c := range chunks {
+		h := casfs.Hash(sha256.Sum256(c))
+		AssertEq(nil, t.store.Put(context.Background(), h, c))
+		m.Chunks = append(m.Chunks, h)
+		m.Size += int64(len(c))
+	}
+
+	m.Mtime = time.Now()
+	t.catalog.Set(name, m)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Tests
+////////////////////////////////////////////////////////////////////////
+
+func (t *CASFSTest) ReadPreloaded() {
+	contents, err := os.ReadFile(path.Join(t.Dir, "foo"))
+	AssertEq(nil, err)
+	AssertEq(2*chunkSize, len(contents))
+	ExpectEq('a', contents[0])
+	ExpectEq('b', contents[chunkSize])
+}
+
+func (t *CASFSTest) SharedChunksAreCached() {
+	_, err := os.ReadFile(path.Join(t.Dir, "foo"))
+	AssertEq(nil, err)
+
+	_, gets, _ := t.store.Stats()
+	ExpectEq(2, gets)
+
+	// Only the chunk that isn't shared with foo is fetched.
+	contents, err := os.ReadFile(path.Join(t.Dir, "bar"))
+	AssertEq(nil, err)
+	ExpectEq('a', contents[0])
+	ExpectEq('c', contents[chunkSize])
+
+	_, gets, _ = t.store.Stats()
+	ExpectEq(3, gets)
+}
+
+func (t *CASFSTest) WritesAreDeduplicated() {
+	blobs, _, puts := t.store.Stats()
+	AssertEq(3, blobs)
+
+	// Two new files with the same contents: one chunk already stored, one
+	// repeated within the file, and one new.
+	contents := bytes.Join([][]byte{
+		bytes.Repeat([]byte("a"), chunkSize),
+		bytes.Repeat([]byte("d"), chunkSize),
+		bytes.Repeat([]byte("d"), chunkSize),
+	}, nil)
+
+	AssertEq(nil, os.WriteFile(path.Join(t.Dir, "baz"), contents, 0644))
+	AssertEq(nil, os.WriteFile(path.Join(t.Dir, "qux"), contents, 0644))
+
+	newBlobs, _, newPuts := t.store.Stats()
+	ExpectEq(blobs+1, newBlobs)
+	ExpectEq(puts+1, newPuts)
+
+	m, ok := t.catalog.Manifest("qux")
+	AssertTrue(ok)
+	ExpectEq(len(contents), m.Size)
+	AssertEq(3, len(m.Chunks))
+	ExpectEq(m.Chunks[1], m.Chunks[2])
+}
+
+func (t *CASFSTest) ManifestIsCommittedOnSync() {
+	f, err := os.OpenFile(path.Join(t.Dir, "foo"), os.O_WRONLY, 0)
+	AssertEq(nil, err)
+	defer f.Close()
+
+	old, _ := t.catalog.Manifest("foo")
+	_, err = f.WriteAt([]byte("taco"), chunkSize)
+	AssertEq(nil, err)
+
+	// The catalog is unchanged until the file is synced.
+	m, _ := t.catalog.Manifest("foo")
+	ExpectEq(old.Chunks[1], m.Chunks[1])
+
+	AssertEq(nil, f.Sync())
+
+	m, _ = t.catalog.Manifest("foo")
+	ExpectEq(old.Chunks[0], m.Chunks[0])
+	ExpectNe(old.Chunks[1], m.Chunks[1])
+	ExpectEq(2*chunkSize, m.Size)
+}
+
+func (t *CASFSTest) Truncate() {
+	p := path.Join(t.Dir, "foo")
+	AssertEq(nil, os.Truncate(p, chunkSize+1))
+
+	contents, err := os.ReadFile(p)
+	AssertEq(nil, err)
+	AssertEq(chunkSize+1, len(contents))
+	ExpectEq('b', contents[chunkSize])
+
+	// Growing again exposes zeros, not the old contents.
+	AssertEq(nil, os.Truncate(p, 2*chunkSize))
+	contents, err = os.ReadFile(p)
+	AssertEq(nil, err)
+	ExpectEq(0, contents[chunkSize+1])
+}
+
+func (t *CASFSTest) UnlinkAndRename() {
+	AssertEq(nil, os.Rename(path.Join(t.Dir, "foo"), path.Join(t.Dir, "bar")))
+	ExpectEq("[bar]", fmt.Sprint(t.catalog.Names()))
+
+	AssertEq(nil, os.Remove(path.Join(t.Dir, "bar")))
+	ExpectEq("[]", fmt.Sprint(t.catalog.Names()))
+}
@@ -0,0 +1,253 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package casfs
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hash identifies a blob by the SHA-256 of its contents.
+type Hash [sha256.Size]byte
+
+func hashOf(data []byte) Hash {
+	return sha256.Sum256(data)
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// BlobStore is a store of immutable blobs keyed by the hash of their
+// contents, such as an object store bucket or an artifact registry.
+type BlobStore interface {
+	// Report whether the store holds the blob.
+	Has(ctx context.Context, h Hash) (bool, error)
+
+	// Return the contents of the blob.
+	Get(ctx context.Context, h Hash) ([]byte, error)
+
+	// Store the blob, whose hash has been checked by the caller. Storing a
+	// blob that is already present must succeed.
+	Put(ctx context.Context, h Hash, data []byte) error
+}
+
+// MemBlobStore is a BlobStore held in memory, which counts requests so that
+// tests can observe deduplication and caching.
+type MemBlobStore struct {
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	blobs map[Hash][]byte
+	gets  int
+	puts  int
+}
+
+func NewMemBlobStore() *MemBlobStore {
+	return &MemBlobStore{
+		blobs: make(map[Hash][]byte),
+	}
+}
+
+func (s *MemBlobStore) Has(ctx context.Context, h Hash) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.blobs[h]
+	return ok, nil
+}
+
+func (s *MemBlobStore) Get(ctx context.Context, h Hash) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gets++
+	data, ok := s.blobs[h]
+	if !ok {
+		return nil, fmt.Errorf("blob %v not found", h)
+	}
+
+	return data, nil
+}
+
+func (s *MemBlobStore) Put(ctx context.Context, h Hash, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.puts++
+	s.blobs[h] = append([]byte(nil), data...)
+	return nil
+}
+
+// Stats returns the number of blobs held, and the number of Get and Put
+// requests made.
+func (s *MemBlobStore) Stats() (blobs, gets, puts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs), s.gets, s.puts
+}
+
+// Manifest describes the committed contents of a file: the hashes of its
+// chunks, in order. All chunks are the same size, the last being padded with
+// zeros, so the chunk containing any offset is found by division. (A
+// production store might trim the last chunk instead, to save space for small
+// files.)
+type Manifest struct {
+	Size   int64
+	Mtime  time.Time
+	Chunks []Hash
+}
+
+// Catalog maps file names to the manifests of their committed contents. It
+// stands in for the database that an artifact store would keep its index in,
+// and is updated atomically when a file is flushed or synced, so that a
+// reader of the catalog sees either the old or the new contents of a file.
+type Catalog struct {
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	manifests map[string]Manifest
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		manifests: make(map[string]Manifest),
+	}
+}
+
+// Manifest returns the committed manifest for the named file.
+func (c *Catalog) Manifest(name string) (Manifest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.manifests[name]
+	return m, ok
+}
+
+// Names returns the names of the files in the catalog, sorted.
+func (c *Catalog) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.manifests {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Set replaces the manifest for the named file, e.g. to add files written by
+// other clients of the store. It doesn't affect a mounted file system that
+// already knows the file.
+func (c *Catalog) Set(name string, m Manifest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifests[name] = m
+}
+
+func (c *Catalog) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.manifests, name)
+}
+
+func (c *Catalog) rename(oldName, newName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.manifests[oldName]
+	delete(c.manifests, oldName)
+	delete(c.manifests, newName)
+	if ok {
+		c.manifests[newName] = m
+	}
+}
+
+// An LRU cache of chunks fetched from the store, so that reads of the same
+// chunk, e.g. by successive small reads or from different files sharing the
+// chunk, needn't fetch it again.
+type blockCache struct {
+	store    BlobStore
+	capacity int
+
+	mu sync.Mutex
+
+	// The cached chunks, most recently used at the front of the list.
+	//
+	// GUARDED_BY(mu)
+	entries map[Hash]*list.Element
+	lru     *list.List
+}
+
+type blockCacheEntry struct {
+	hash Hash
+	data []byte
+}
+
+func newBlockCache(store BlobStore, capacity int) *blockCache {
+	return &blockCache{
+		store:    store,
+		capacity: capacity,
+		entries:  make(map[Hash]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Return the contents of the chunk, which the caller must not modify.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *blockCache) get(ctx context.Context, h Hash) ([]byte, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[h]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*blockCacheEntry).data, nil
+	}
+	c.mu.Unlock()
+
+	data, err := c.store.Get(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(h, data)
+	return data, nil
+}
+
+// Add a chunk to the cache, e.g. one just written to the store.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *blockCache) add(h Hash, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[h]; ok {
+		return
+	}
+
+	c.entries[h] = c.lru.PushFront(&blockCacheEntry{h, data})
+	for c.lru.Len() > c.capacity {
+		evicted := c.lru.Remove(c.lru.Back()).(*blockCacheEntry)
+		delete(c.entries, evicted.hash)
+	}
+}
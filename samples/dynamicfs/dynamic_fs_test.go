@@ -19,6 +19,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestDynamicFS(t *testing.T) { RunTests(t) }
 
 type DynamicFSTest struct {
@@ -30,6 +30,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestErrorFS(t *testing.T) { RunTests(t) }
 
 ////////////////////////////////////////////////////////////////////////
@@ -37,6 +37,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestFlushFS(t *testing.T) { RunTests(t) }
 
 ////////////////////////////////////////////////////////////////////////
@@ -166,15 +168,10 @@ func (t *flushFSTest) getFsyncs() []string {
 
 // Like syscall.Dup2, but correctly annotates the syscall as blocking. See here
 // for more info: https://github.com/golang/go/issues/10202
+//
+// unix.Dup2 uses dup3 where there is no dup2, as on arm64 and riscv64.
 func dup2(oldfd int, newfd int) error {
-	_, _, e1 := syscall.Syscall(
-		syscall.SYS_DUP2, uintptr(oldfd), uintptr(newfd), 0)
-
-	if e1 != 0 {
-		return e1
-	}
-
-	return nil
+	return unix.Dup2(oldfd, newfd)
 }
 
 // Call msync(2) with the MS_SYNC flag on a slice previously returned by
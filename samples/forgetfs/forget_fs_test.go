@@ -25,6 +25,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestForgetFS(t *testing.T) { RunTests(t) }
 
 ////////////////////////////////////////////////////////////////////////
@@ -15,6 +15,7 @@
 package hellofs_test
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -22,15 +23,38 @@ import (
 	"syscall"
 	"testing"
 
+	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fusetesting"
 	"github.com/jacobsa/fuse/samples"
 	"github.com/jacobsa/fuse/samples/hellofs"
 	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
+	"github.com/jacobsa/timeutil"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestHelloFS(t *testing.T) { RunTests(t) }
 
+func TestHelloFSParallel(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+
+			server, err := hellofs.NewHelloFS(timeutil.RealClock())
+			if err != nil {
+				t.Fatalf("NewHelloFS: %v", err)
+			}
+
+			dir := samples.MountForTest(t, server, fuse.MountConfig{})
+			contents, err := os.ReadFile(path.Join(dir, "hello"))
+			if err != nil || string(contents) != "Hello, world!" {
+				t.Errorf("ReadFile: %q, %v", contents, err)
+			}
+		})
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Boilerplate
 ////////////////////////////////////////////////////////////////////////
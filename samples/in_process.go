@@ -65,6 +65,10 @@ func (t *SampleTest) SetUp(ti *ogletest.TestInfo) {
 		cfg.DebugLogger = log.New(os.Stderr, "fuse: ", 0)
 	}
 
+	if *fMaxProtocolMinor != 0 && cfg.MaxProtocolMinor == 0 {
+		cfg.MaxProtocolMinor = uint32(*fMaxProtocolMinor)
+	}
+
 	err := t.initialize(ti.Ctx, t.Server, &cfg)
 	if err != nil {
 		panic(err)
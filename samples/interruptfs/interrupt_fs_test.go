@@ -29,6 +29,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestInterruptFS(t *testing.T) { RunTests(t) }
 
 ////////////////////////////////////////////////////////////////////////
@@ -154,3 +156,62 @@ func (t *InterruptFSTest) InterruptedDuringFlush() {
 	ExpectThat(err, Error(HasSubstr("signal")))
 	ExpectThat(err, Error(HasSubstr("interrupt")))
 }
+
+////////////////////////////////////////////////////////////////////////
+// Limited workers
+////////////////////////////////////////////////////////////////////////
+
+// The same file system, handling a single op at a time.
+type InterruptFSWorkersTest struct {
+	InterruptFSTest
+}
+
+func init() { RegisterTestSuite(&InterruptFSWorkersTest{}) }
+
+func (t *InterruptFSWorkersTest) SetUp(ti *TestInfo) {
+	t.MountConfig.Tuning.Workers = 1
+	t.InterruptFSTest.SetUp(ti)
+}
+
+func (t *InterruptFSWorkersTest) InterruptedWhileWorkersBusy() {
+	t.fs.EnableReadBlocking()
+
+	// Start a sub-process reading the file, returning a channel to which its
+	// result is written.
+	cat := func() (*exec.Cmd, <-chan error) {
+		cmd := exec.Command("cat", path.Join(t.Dir, "foo"))
+		AssertEq(nil, cmd.Start())
+
+		cmdErr := make(chan error, 1)
+		go func() {
+			cmdErr <- cmd.Wait()
+		}()
+
+		return cmd, cmdErr
+	}
+
+	// The first read holds the only worker, and the second process's ops wait
+	// for it.
+	cmd1, cmdErr1 := cat()
+	t.fs.WaitForFirstRead()
+
+	cmd2, cmdErr2 := cat()
+	time.Sleep(10 * time.Millisecond)
+
+	// Each can still be interrupted.
+	for _, c := range []struct {
+		cmd    *exec.Cmd
+		cmdErr <-chan error
+	}{{cmd1, cmdErr1}, {cmd2, cmdErr2}} {
+		c.cmd.Process.Signal(os.Interrupt)
+
+		select {
+		case err := <-c.cmdErr:
+			ExpectThat(err, Error(HasSubstr("interrupt")))
+
+		case <-time.After(5 * time.Second):
+			AddFailure("Command not interrupted")
+			AbortTest()
+		}
+	}
+}
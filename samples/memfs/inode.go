@@ -21,7 +21,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 )
@@ -40,7 +39,7 @@ type inode struct {
 
 	// The current attributes of this inode.
 	//
-	// INVARIANT: attrs.Mode &^ (os.ModePerm|os.ModeDir|os.ModeSymlink) == 0
+	// INVARIANT: attrs.Mode &^ (os.ModePerm|os.ModeType|os.ModeCharDevice) == 0
 	// INVARIANT: !(isDir() && isSymlink())
 	// INVARIANT: attrs.Size == len(contents)
 	attrs fuseops.InodeAttributes
@@ -93,8 +92,8 @@ func newInode(attrs fuseops.InodeAttributes, name string) *inode {
 }
 
 func (in *inode) CheckInvariants() {
-	// INVARIANT: attrs.Mode &^ (os.ModePerm|os.ModeDir|os.ModeSymlink) == 0
-	if !(in.attrs.Mode&^(os.ModePerm|os.ModeDir|os.ModeSymlink) == 0) {
+	// INVARIANT: attrs.Mode &^ (os.ModePerm|os.ModeType|os.ModeCharDevice) == 0
+	if !(in.attrs.Mode&^(os.ModePerm|os.ModeType|os.ModeCharDevice) == 0) {
 		panic(fmt.Sprintf("Unexpected mode: %v", in.attrs.Mode))
 	}
 
@@ -379,9 +378,12 @@ func (in *inode) WriteAt(p []byte, off int64) (int, error) {
 func (in *inode) SetAttributes(
 	size *uint64,
 	mode *os.FileMode,
-	mtime *time.Time) {
-	// Update the modification time.
-	in.attrs.Mtime = time.Now()
+	mtime *time.Time,
+	ctime *time.Time) {
+	// Update the modification and change times.
+	now := time.Now()
+	in.attrs.Mtime = now
+	in.attrs.Ctime = now
 
 	// Truncate?
 	if size != nil {
@@ -409,17 +411,27 @@ func (in *inode) SetAttributes(
 	if mtime != nil {
 		in.attrs.Mtime = *mtime
 	}
-}
 
-func (in *inode) Fallocate(mode uint32, offset uint64, length uint64) error {
-	if mode != 0 {
-		return fuse.ENOSYS
+	// Take the change time the kernel has kept, with writeback caching.
+	if ctime != nil {
+		in.attrs.Ctime = *ctime
 	}
+}
+
+// Allocate, zero, or punch a hole in the range, as for a FallocateOp whose
+// mode fuseutil.CheckFallocateMode has accepted with FallocateKeepSize,
+// FallocatePunchHole and FallocateZeroRange.
+func (in *inode) Fallocate(mode fuseops.FallocateMode, offset uint64, length uint64) {
 	newSize := int(offset + length)
-	if newSize > len(in.contents) {
+	if newSize > len(in.contents) && mode&fuseops.FallocateKeepSize == 0 {
 		padding := make([]byte, newSize-len(in.contents))
 		in.contents = append(in.contents, padding...)
 		in.attrs.Size = offset + length
 	}
-	return nil
+
+	// There are no holes, so punching one zeros the range.
+	if mode&(fuseops.FallocatePunchHole|fuseops.FallocateZeroRange) != 0 &&
+		offset < uint64(len(in.contents)) {
+		clear(in.contents[offset:min(newSize, len(in.contents))])
+	}
 }
@@ -260,6 +260,22 @@ func (fs *memFS) GetInodeAttributes(
 	return nil
 }
 
+func (fs *memFS) Statx(
+	ctx context.Context,
+	op *fuseops.StatxOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Grab the inode.
+	inode := fs.getInodeOrDie(op.Inode)
+
+	// Fill in the response, which includes the creation time.
+	op.Attributes = inode.attrs
+	op.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+
+	return nil
+}
+
 func (fs *memFS) SetInodeAttributes(
 	ctx context.Context,
 	op *fuseops.SetInodeAttributesOp) error {
@@ -277,7 +293,7 @@ func (fs *memFS) SetInodeAttributes(
 	inode := fs.getInodeOrDie(op.Inode)
 
 	// Handle the request.
-	inode.SetAttributes(op.Size, op.Mode, op.Mtime)
+	inode.SetAttributes(op.Size, op.Mode, op.Mtime, op.Ctime)
 
 	// Fill in the response.
 	op.Attributes = inode.attrs
@@ -338,15 +354,32 @@ func (fs *memFS) MkNode(
 	defer fs.mu.Unlock()
 
 	var err error
-	op.Entry, err = fs.createFile(op.Parent, op.Name, op.Mode)
+	op.Entry, err = fs.createFile(op.Parent, op.Name, op.Mode, op.Rdev)
 	return err
 }
 
+// The type of directory entry to record for a child with the given mode.
+func direntType(mode os.FileMode) fuseutil.DirentType {
+	switch {
+	case mode&os.ModeCharDevice != 0:
+		return fuseutil.DT_Char
+	case mode&os.ModeDevice != 0:
+		return fuseutil.DT_Block
+	case mode&os.ModeNamedPipe != 0:
+		return fuseutil.DT_FIFO
+	case mode&os.ModeSocket != 0:
+		return fuseutil.DT_Socket
+	}
+
+	return fuseutil.DT_File
+}
+
 // LOCKS_REQUIRED(fs.mu)
 func (fs *memFS) createFile(
 	parentID fuseops.InodeID,
 	name string,
-	mode os.FileMode) (fuseops.ChildInodeEntry, error) {
+	mode os.FileMode,
+	rdev uint32) (fuseops.ChildInodeEntry, error) {
 	// Grab the parent, which we will update shortly.
 	parent := fs.getInodeOrDie(parentID)
 
@@ -362,6 +395,7 @@ func (fs *memFS) createFile(
 	childAttrs := fuseops.InodeAttributes{
 		Nlink:  1,
 		Mode:   mode,
+		Rdev:   rdev,
 		Atime:  now,
 		Mtime:  now,
 		Ctime:  now,
@@ -374,7 +408,7 @@ func (fs *memFS) createFile(
 	childID, child := fs.allocateInode(childAttrs, name)
 
 	// Add an entry in the parent.
-	parent.AddChild(childID, name, fuseutil.DT_File)
+	parent.AddChild(childID, name, direntType(mode))
 
 	// Fill in the response entry.
 	var entry fuseops.ChildInodeEntry
@@ -395,10 +429,43 @@ func (fs *memFS) CreateFile(
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	op.Entry, err = fs.createFile(op.Parent, op.Name, op.Mode)
+	op.Entry, err = fs.createFile(op.Parent, op.Name, op.Mode, 0)
 	return err
 }
 
+func (fs *memFS) CreateTmpfile(
+	ctx context.Context,
+	op *fuseops.CreateTmpfileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// The file has no name until it is linked into a directory, if ever.
+	now := time.Now()
+	attrs := fuseops.InodeAttributes{
+		Nlink:  0,
+		Mode:   op.Mode,
+		Atime:  now,
+		Mtime:  now,
+		Ctime:  now,
+		Crtime: now,
+		Uid:    fs.uid,
+		Gid:    fs.gid,
+	}
+
+	childID, child := fs.allocateInode(attrs, "")
+
+	// Fill in the response entry.
+	op.Entry.Child = childID
+	op.Entry.Attributes = child.attrs
+
+	// We don't spontaneously mutate, so the kernel can cache as long as it wants
+	// (since it also handles invalidation).
+	op.Entry.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+
+	return nil
+}
+
 func (fs *memFS) CreateSymlink(
 	ctx context.Context,
 	op *fuseops.CreateSymlinkOp) error {
@@ -474,7 +541,7 @@ func (fs *memFS) CreateLink(
 	target.attrs.Ctime = now
 
 	// Add an entry in the parent.
-	parent.AddChild(op.Target, op.Name, fuseutil.DT_File)
+	parent.AddChild(op.Target, op.Name, direntType(target.attrs.Mode))
 
 	// Return the response.
 	op.Entry.Child = op.Target
@@ -502,10 +569,31 @@ func (fs *memFS) Rename(
 		return fuse.ENOENT
 	}
 
+	if op.Flags&^(fuseops.RenameNoReplace|fuseops.RenameExchange) != 0 {
+		return fuse.EINVAL
+	}
+
 	// If the new name exists already in the new parent, make sure it's not a
-	// non-empty directory, then delete it.
+	// non-empty directory, then delete it, unless the names are to be swapped
+	// or the new name must not be replaced.
 	newParent := fs.getInodeOrDie(op.NewParent)
-	existingID, _, ok := newParent.LookUpChild(op.NewName)
+	existingID, existingType, ok := newParent.LookUpChild(op.NewName)
+	if op.Flags&fuseops.RenameExchange != 0 {
+		if !ok {
+			return fuse.ENOENT
+		}
+
+		oldParent.RemoveChild(op.OldName)
+		newParent.RemoveChild(op.NewName)
+		oldParent.AddChild(existingID, op.OldName, existingType)
+		newParent.AddChild(childID, op.NewName, childType)
+		return nil
+	}
+
+	if ok && op.Flags&fuseops.RenameNoReplace != 0 {
+		return fuse.EEXIST
+	}
+
 	if ok {
 		existing := fs.getInodeOrDie(existingID)
 
@@ -651,6 +739,12 @@ func (fs *memFS) OpenFile(
 		}
 	}
 
+	// Only set with EnableAtomicTrunc, in which case truncating is up to us.
+	if op.OpenFlags.IsTruncate() {
+		var zero uint64
+		inode.SetAttributes(&zero, nil, nil, nil)
+	}
+
 	return nil
 }
 
@@ -701,6 +795,36 @@ func (fs *memFS) WriteFile(
 	return err
 }
 
+func (fs *memFS) CopyFileRange(
+	ctx context.Context,
+	op *fuseops.CopyFileRangeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	src := fs.getInodeOrDie(op.SrcInode)
+	dst := fs.getInodeOrDie(op.DstInode)
+
+	// Copy what the source has in the range, through a buffer in case the
+	// ranges overlap.
+	size := uint64(len(src.contents))
+	if op.SrcOffset >= size {
+		return nil
+	}
+
+	buf := make([]byte, min(op.Length, size-op.SrcOffset))
+	n, err := src.ReadAt(buf, int64(op.SrcOffset))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if _, err := dst.WriteAt(buf[:n], int64(op.DstOffset)); err != nil {
+		return err
+	}
+
+	op.BytesCopied = uint64(n)
+	return nil
+}
+
 func (fs *memFS) FlushFile(
 	ctx context.Context,
 	op *fuseops.FlushFileOp) (err error) {
@@ -813,6 +937,12 @@ func (fs *memFS) Fallocate(ctx context.Context,
 	op *fuseops.FallocateOp) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
+	err := fuseutil.CheckFallocateMode(op,
+		fuseops.FallocateKeepSize|fuseops.FallocatePunchHole|fuseops.FallocateZeroRange)
+	if err != nil {
+		return err
+	}
+
 	inode := fs.getInodeOrDie(op.Inode)
 	inode.Fallocate(op.Mode, op.Offset, op.Length)
 	return nil
@@ -0,0 +1,361 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memfs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jacobsa/fuse/fusetesting"
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/sys/unix"
+)
+
+func (t *MemFSTest) CopyFileRange() {
+	var err error
+
+	// Create a source file and a destination file.
+	src := path.Join(t.Dir, "src")
+	err = ioutil.WriteFile(src, []byte("burrito"), 0600)
+	AssertEq(nil, err)
+
+	dst := path.Join(t.Dir, "dst")
+	err = ioutil.WriteFile(dst, []byte("taco"), 0600)
+	AssertEq(nil, err)
+
+	in, err := os.Open(src)
+	t.ToClose = append(t.ToClose, in)
+	AssertEq(nil, err)
+
+	out, err := os.OpenFile(dst, os.O_WRONLY, 0)
+	t.ToClose = append(t.ToClose, out)
+	AssertEq(nil, err)
+
+	// Copy the end of the source over the end of the destination, asking for
+	// more than there is.
+	inOff := int64(3)
+	outOff := int64(2)
+	n, err := unix.CopyFileRange(int(in.Fd()), &inOff, int(out.Fd()), &outOff, 100, 0)
+	AssertEq(nil, err)
+	ExpectEq(4, n)
+	ExpectEq(7, inOff)
+	ExpectEq(6, outOff)
+
+	// Read back the destination.
+	contents, err := ioutil.ReadFile(dst)
+	AssertEq(nil, err)
+	ExpectEq("tarito", string(contents))
+}
+
+func (t *MemFSTest) Fallocate_PunchHole() {
+	var err error
+	fileName := path.Join(t.Dir, "foo")
+
+	// Create a file.
+	err = ioutil.WriteFile(fileName, []byte("burrito"), 0600)
+	AssertEq(nil, err)
+
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	t.ToClose = append(t.ToClose, f)
+	AssertEq(nil, err)
+
+	// Punch a hole extending past the end of the file, which keeps its size.
+	err = unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, 2, 10)
+	AssertEq(nil, err)
+
+	contents, err := ioutil.ReadFile(fileName)
+	AssertEq(nil, err)
+	ExpectEq("bu\x00\x00\x00\x00\x00", string(contents))
+
+	// Unsupported modes fail without disabling fallocate.
+	err = unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_COLLAPSE_RANGE, 0, 4096)
+	ExpectEq(unix.EOPNOTSUPP, err)
+
+	err = unix.Fallocate(int(f.Fd()), 0, 0, 10)
+	AssertEq(nil, err)
+
+	fi, err := f.Stat()
+	AssertEq(nil, err)
+	ExpectEq(10, fi.Size())
+}
+
+func (t *MemFSTest) Rename2_FlagsRefused() {
+	var err error
+
+	oldPath := path.Join(t.Dir, "foo")
+	err = ioutil.WriteFile(oldPath, []byte("taco"), 0400)
+	AssertEq(nil, err)
+
+	// Without EnableRenameFlags, renames with flags fail as if unsupported.
+	err = unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, path.Join(t.Dir, "bar"), unix.RENAME_NOREPLACE)
+	ExpectEq(unix.EINVAL, err)
+
+	_, err = os.Stat(oldPath)
+	ExpectEq(nil, err)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Rename flags
+////////////////////////////////////////////////////////////////////////
+
+type RenameFlagsTest struct {
+	memFSTest
+}
+
+func init() { RegisterTestSuite(&RenameFlagsTest{}) }
+
+func (t *RenameFlagsTest) SetUp(ti *TestInfo) {
+	t.MountConfig.EnableRenameFlags = true
+	t.memFSTest.SetUp(ti)
+}
+
+func (t *RenameFlagsTest) NoReplace() {
+	var err error
+
+	oldPath := path.Join(t.Dir, "foo")
+	err = ioutil.WriteFile(oldPath, []byte("taco"), 0400)
+	AssertEq(nil, err)
+
+	newPath := path.Join(t.Dir, "bar")
+	err = ioutil.WriteFile(newPath, []byte("burrito"), 0400)
+	AssertEq(nil, err)
+
+	// The existing name isn't replaced.
+	err = unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, newPath, unix.RENAME_NOREPLACE)
+	ExpectEq(unix.EEXIST, err)
+
+	contents, err := ioutil.ReadFile(newPath)
+	AssertEq(nil, err)
+	ExpectEq("burrito", string(contents))
+
+	// A new name is linked as usual.
+	newPath = path.Join(t.Dir, "baz")
+	err = unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, newPath, unix.RENAME_NOREPLACE)
+	AssertEq(nil, err)
+
+	contents, err = ioutil.ReadFile(newPath)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+
+	_, err = os.Stat(oldPath)
+	ExpectTrue(os.IsNotExist(err), "err: %v", err)
+}
+
+func (t *RenameFlagsTest) Exchange() {
+	var err error
+
+	// A file and a directory, in different directories.
+	dir := path.Join(t.Dir, "dir")
+	err = os.Mkdir(dir, 0700)
+	AssertEq(nil, err)
+
+	filePath := path.Join(t.Dir, "foo")
+	err = ioutil.WriteFile(filePath, []byte("taco"), 0400)
+	AssertEq(nil, err)
+
+	subdirPath := path.Join(dir, "bar")
+	err = os.Mkdir(subdirPath, 0700)
+	AssertEq(nil, err)
+
+	// Swap them.
+	err = unix.Renameat2(unix.AT_FDCWD, filePath, unix.AT_FDCWD, subdirPath, unix.RENAME_EXCHANGE)
+	AssertEq(nil, err)
+
+	fi, err := os.Stat(filePath)
+	AssertEq(nil, err)
+	ExpectTrue(fi.IsDir())
+
+	contents, err := ioutil.ReadFile(subdirPath)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+
+	// Both names must exist.
+	err = unix.Renameat2(unix.AT_FDCWD, subdirPath, unix.AT_FDCWD, path.Join(t.Dir, "baz"), unix.RENAME_EXCHANGE)
+	ExpectEq(unix.ENOENT, err)
+}
+
+func (t *RenameFlagsTest) Whiteout() {
+	var err error
+
+	oldPath := path.Join(t.Dir, "foo")
+	err = ioutil.WriteFile(oldPath, []byte("taco"), 0400)
+	AssertEq(nil, err)
+
+	// memfs doesn't support whiteouts.
+	err = unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, path.Join(t.Dir, "bar"), unix.RENAME_WHITEOUT)
+	ExpectEq(unix.EINVAL, err)
+
+	_, err = os.Stat(oldPath)
+	ExpectEq(nil, err)
+}
+
+func (t *MemFSTest) Statx_Btime() {
+	var err error
+	fileName := path.Join(t.Dir, "foo")
+
+	// Create a file.
+	createTime := time.Now()
+	err = ioutil.WriteFile(fileName, []byte("taco"), 0600)
+	AssertEq(nil, err)
+
+	// Ask for its birth time, which stat(2) doesn't report.
+	var stat unix.Statx_t
+	err = unix.Statx(
+		unix.AT_FDCWD,
+		fileName,
+		unix.AT_STATX_FORCE_SYNC,
+		unix.STATX_BASIC_STATS|unix.STATX_BTIME,
+		&stat)
+
+	AssertEq(nil, err)
+	AssertNe(0, stat.Mask&unix.STATX_BTIME)
+	ExpectEq(4, stat.Size)
+
+	btime := time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec))
+	ExpectThat(btime, timeutil.TimeNear(createTime, timeSlop))
+}
+
+func (t *MemFSTest) Tmpfile_Link() {
+	var err error
+	fileName := path.Join(t.Dir, "foo")
+
+	// Create an unnamed file and write to it.
+	fd, err := unix.Open(t.Dir, unix.O_TMPFILE|unix.O_RDWR, 0600)
+	AssertEq(nil, err)
+	defer unix.Close(fd)
+
+	_, err = unix.Write(fd, []byte("taco"))
+	AssertEq(nil, err)
+
+	var stat unix.Stat_t
+	err = unix.Fstat(fd, &stat)
+	AssertEq(nil, err)
+	ExpectEq(0, stat.Nlink)
+	ExpectEq(4, stat.Size)
+
+	// It shouldn't show up in the directory.
+	entries, err := fusetesting.ReadDirPicky(t.Dir)
+	AssertEq(nil, err)
+	ExpectThat(entries, ElementsAre())
+
+	// Give it a name.
+	err = unix.Linkat(
+		unix.AT_FDCWD,
+		fmt.Sprintf("/proc/self/fd/%d", fd),
+		unix.AT_FDCWD,
+		fileName,
+		unix.AT_SYMLINK_FOLLOW)
+
+	AssertEq(nil, err)
+
+	// Now it can be found and read by that name.
+	contents, err := ioutil.ReadFile(fileName)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+
+	err = unix.Fstat(fd, &stat)
+	AssertEq(nil, err)
+	ExpectEq(1, stat.Nlink)
+}
+
+func (t *MknodTest) Devices() {
+	var err error
+	charPath := path.Join(t.Dir, "char")
+	blockPath := path.Join(t.Dir, "block")
+
+	// Create a character device with a minor number too large for the old
+	// 16-bit encoding, and a block device. Creating devices needs CAP_MKNOD.
+	charDev := unix.Mkdev(4, 300)
+	err = unix.Mknod(charPath, unix.S_IFCHR|0640, int(charDev))
+	if err == unix.EPERM {
+		return
+	}
+
+	AssertEq(nil, err)
+
+	blockDev := unix.Mkdev(8, 1)
+	err = unix.Mknod(blockPath, unix.S_IFBLK|0600, int(blockDev))
+	AssertEq(nil, err)
+
+	// Stat them.
+	var stat unix.Stat_t
+	err = unix.Lstat(charPath, &stat)
+	AssertEq(nil, err)
+	ExpectEq(unix.S_IFCHR|0640, stat.Mode)
+	ExpectEq(charDev, stat.Rdev)
+
+	err = unix.Lstat(blockPath, &stat)
+	AssertEq(nil, err)
+	ExpectEq(unix.S_IFBLK|0600, stat.Mode)
+	ExpectEq(blockDev, stat.Rdev)
+
+	// Their directory entries should have the right types.
+	entries, err := os.ReadDir(t.Dir)
+	AssertEq(nil, err)
+	AssertEq(2, len(entries))
+
+	ExpectEq("block", entries[0].Name())
+	ExpectEq(os.ModeDevice, entries[0].Type())
+	ExpectEq("char", entries[1].Name())
+	ExpectEq(os.ModeDevice|os.ModeCharDevice, entries[1].Type())
+}
+
+func (t *MknodTest) FIFO() {
+	var err error
+	p := path.Join(t.Dir, "foo")
+
+	err = unix.Mkfifo(p, 0600)
+	AssertEq(nil, err)
+
+	fi, err := os.Lstat(p)
+	AssertEq(nil, err)
+	ExpectEq(os.ModeNamedPipe|0600, fi.Mode())
+
+	entries, err := os.ReadDir(t.Dir)
+	AssertEq(nil, err)
+	AssertEq(1, len(entries))
+	ExpectEq(os.ModeNamedPipe, entries[0].Type())
+
+	// The kernel serves the pipe itself.
+	go func() {
+		ioutil.WriteFile(p, []byte("taco"), 0)
+	}()
+
+	contents, err := ioutil.ReadFile(p)
+	AssertEq(nil, err)
+	ExpectEq("taco", string(contents))
+}
+
+func (t *MknodTest) Socket() {
+	var err error
+	p := path.Join(t.Dir, "foo")
+
+	err = unix.Mknod(p, unix.S_IFSOCK|0600, 0)
+	AssertEq(nil, err)
+
+	fi, err := os.Lstat(p)
+	AssertEq(nil, err)
+	ExpectEq(os.ModeSocket|0600, fi.Mode())
+
+	entries, err := os.ReadDir(t.Dir)
+	AssertEq(nil, err)
+	AssertEq(1, len(entries))
+	ExpectEq(os.ModeSocket, entries[0].Type())
+}
@@ -17,6 +17,7 @@ package memfs_test
 import (
 	"bytes"
 	"encoding/binary"
+	"flag"
 	"io"
 	"io/ioutil"
 	"os"
@@ -40,8 +41,15 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestMemFS(t *testing.T) { RunTests(t) }
 
+var fLargeDirSize = flag.Int(
+	"large_dir_size",
+	5000,
+	"Number of entries to use for large directory tests and benchmarks.")
+
 // The radius we use for "expect mtime is within"-style assertions. We can't
 // share a synchronized clock with the ultimate source of mtimes because with
 // writeback caching enabled the kernel manufactures them based on wall time.
@@ -1059,6 +1067,17 @@ func (t *MemFSTest) Chtimes() {
 	ExpectThat(fi, fusetesting.MtimeIsWithin(expectedMtime, timeSlop))
 }
 
+func (t *MemFSTest) ReadDirLargeDirectoryWhileModifying() {
+	dirName := path.Join(t.Dir, "dir")
+	err := os.Mkdir(dirName, 0700)
+	AssertEq(nil, err)
+
+	fusetesting.RunReadDirWithConcurrentMutationTest(
+		t.Ctx,
+		dirName,
+		*fLargeDirSize)
+}
+
 func (t *MemFSTest) ReadDirWhileModifying() {
 	dirName := path.Join(t.Dir, "dir")
 	createFile := func(name string) {
@@ -2109,6 +2128,15 @@ func (t *atomicOTruncTest) OpenFileWithOTrunc() {
 	} else {
 		t.checkOpenFlagsNotContainsFlag(fileName, fusekernel.OpenTruncate)
 	}
+
+	// Either way, the file was truncated.
+	fi, err := os.Stat(fileName)
+	AssertEq(nil, err)
+	ExpectEq(0, fi.Size())
+
+	contents, err := ioutil.ReadFile(fileName)
+	AssertEq(nil, err)
+	ExpectEq("", string(contents))
 }
 
 type AtmoicOTruncEnabledTest struct {
@@ -0,0 +1,81 @@
+package memfs_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fusetesting"
+	"github.com/jacobsa/fuse/samples/memfs"
+)
+
+// Mount a memfs containing a directory of *fLargeDirSize files, returning the
+// directory's path.
+func mountLargeDir(b *testing.B) string {
+	dir := b.TempDir()
+	mfs, err := fuse.Mount(
+		dir,
+		memfs.NewMemFS(currentUid(), currentGid()),
+		&fuse.MountConfig{OpContext: context.Background()})
+	if err != nil {
+		b.Fatalf("Mount: %v", err)
+	}
+
+	b.Cleanup(func() {
+		if err := fuse.Unmount(dir); err != nil {
+			b.Errorf("Unmount: %v", err)
+			return
+		}
+
+		mfs.Join(context.Background())
+	})
+
+	if err := fusetesting.CreateFiles(dir, *fLargeDirSize); err != nil {
+		b.Fatalf("CreateFiles: %v", err)
+	}
+
+	return dir
+}
+
+func BenchmarkReadDirLargeDirectory(b *testing.B) {
+	dir := mountLargeDir(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(dir)
+		if err != nil {
+			b.Fatalf("Open: %v", err)
+		}
+
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			b.Fatalf("Readdirnames: %v", err)
+		}
+
+		if len(names) != *fLargeDirSize {
+			b.Fatalf("got %d entries, want %d", len(names), *fLargeDirSize)
+		}
+	}
+
+	b.ReportMetric(float64(*fLargeDirSize*b.N)/b.Elapsed().Seconds(), "entries/s")
+}
+
+func BenchmarkReadDirAndStatLargeDirectory(b *testing.B) {
+	dir := mountLargeDir(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		entries, err := fusetesting.ReadDirPicky(dir)
+		if err != nil {
+			b.Fatalf("ReadDirPicky: %v", err)
+		}
+
+		if len(entries) != *fLargeDirSize {
+			b.Fatalf("got %d entries, want %d", len(entries), *fLargeDirSize)
+		}
+	}
+
+	b.ReportMetric(float64(*fLargeDirSize*b.N)/b.Elapsed().Seconds(), "entries/s")
+}
@@ -19,6 +19,8 @@ import (
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/samples/roloopbackfs"
@@ -26,6 +28,10 @@ import (
 
 var fPhysicalPath = flag.String("path", "", "Physical path to loopback.")
 var fMountPoint = flag.String("mount_point", "", "Path to mount point.")
+var fRules = flag.String(
+	"rules",
+	"",
+	"Path to a file of allow/hide/block rules, re-read on SIGHUP.")
 
 var fDebug = flag.Bool("debug", false, "Enable debug logging.")
 
@@ -48,7 +54,20 @@ func main() {
 		log.Fatalf("Failed to create mount point at '%v'", *fMountPoint)
 	}
 
-	server, err := roloopbackfs.NewReadonlyLoopbackServer(*fPhysicalPath, errorLogger)
+	var rules *roloopbackfs.Rules
+	if *fRules != "" {
+		rules = &roloopbackfs.Rules{}
+		if err := loadRules(rules); err != nil {
+			log.Fatalf("loadRules: %v", err)
+		}
+
+		go reloadRulesOnSIGHUP(rules, errorLogger)
+	}
+
+	server, err := roloopbackfs.NewReadonlyLoopbackServerWithRules(
+		*fPhysicalPath,
+		errorLogger,
+		rules)
 	if err != nil {
 		log.Fatalf("makeFS: %v", err)
 	}
@@ -72,3 +91,23 @@ func main() {
 		log.Fatalf("Join: %v", err)
 	}
 }
+
+func loadRules(rules *roloopbackfs.Rules) error {
+	f, err := os.Open(*fRules)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return rules.Load(f)
+}
+
+func reloadRulesOnSIGHUP(rules *roloopbackfs.Rules, logger *log.Logger) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := loadRules(rules); err != nil {
+			logger.Printf("Reloading rules: %v", err)
+		}
+	}
+}
@@ -41,6 +41,7 @@ var fFsyncError = flag.Int("flushfs.fsync_error", 0, "")
 
 var fReadOnly = flag.Bool("read_only", false, "Mount in read-only mode.")
 var fDebug = flag.Bool("debug", false, "Enable debug logging.")
+var fMaxProtocolMinor = flag.Uint("max_protocol_minor", 0, "Cap the protocol version.")
 
 func makeFlushFS() (fuse.Server, error) {
 	// Check the flags.
@@ -128,7 +129,8 @@ func main() {
 	}
 
 	cfg := &fuse.MountConfig{
-		ReadOnly: *fReadOnly,
+		ReadOnly:         *fReadOnly,
+		MaxProtocolMinor: uint32(*fMaxProtocolMinor),
 	}
 
 	if *fDebug {
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/samples/tailfs"
+)
+
+var mountPoint = flag.String("mountpoint", "", "directory to mount the filesystem")
+
+func main() {
+	flag.Parse()
+
+	if *mountPoint == "" {
+		log.Fatalf("--mountpoint is required")
+	}
+
+	server, l := tailfs.NewTailFS()
+	mfs, err := fuse.Mount(*mountPoint, server, &fuse.MountConfig{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Append a line every second; try `tail -f` on the log file.
+	go func() {
+		for t := range time.Tick(time.Second) {
+			line := fmt.Sprintf("%s\n", t.Format(time.RFC3339))
+			if err := l.Append([]byte(line)); err != nil {
+				log.Printf("Append: %v", err)
+			}
+		}
+	}()
+
+	if err := mfs.Join(context.Background()); err != nil {
+		panic(err)
+	}
+}
@@ -14,6 +14,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestNotifyInvalFS(t *testing.T) { RunTests(t) }
 
 func (t *NotifyInvalFSTest) setTime(tv time.Time) {
@@ -0,0 +1,331 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package percallerfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+const (
+	// The names of the entries in the root directory.
+	WhoAmIName = "whoami"
+	HomeName   = "home"
+
+	rootInode   = fuseops.RootInodeID
+	whoAmIInode = fuseops.RootInodeID + 1
+	homeInode   = fuseops.RootInodeID + 2
+)
+
+// Create a file system whose contents depend on who is looking at it, using
+// the caller identity in fuseops.OpContext:
+//
+//   - The file "whoami" contains the UID and PID of the process reading it.
+//
+//   - The directory "home" contains, for each caller, only the names that the
+//     supplied map lists for the caller's UID. Each of those files contains the
+//     UID that owns it.
+//
+// The kernel's caches are shared by every process on the system, so anything
+// that differs between callers must be kept out of them:
+//
+//   - Entries in "home" are returned with a zero entry expiration, so the
+//     kernel asks again on each lookup rather than letting one user see a name
+//     that another user looked up.
+//
+//   - Files are opened with direct IO, so that reads aren't served from a page
+//     cache filled on behalf of somebody else.
+//
+//   - A listing of "home" is fixed when the directory is opened, so that a
+//     handle shared with another process (e.g. across fork) keeps returning
+//     consistent results as the offset advances.
+//
+// Other users can only reach the file system if it is mounted with the
+// allow_other option.
+func NewPerCallerFS(homes map[uint32][]string) fuse.Server {
+	fs := &perCallerFS{
+		homes:       make(map[uint32][]string),
+		inodes:      make(map[homeFile]fuseops.InodeID),
+		nextInode:   homeInode + 1,
+		dirHandles:  make(map[fuseops.HandleID][]fuseutil.Dirent),
+		fileHandles: make(map[fuseops.HandleID][]byte),
+	}
+
+	for uid, names := range homes {
+		names = append([]string(nil), names...)
+		sort.Strings(names)
+		fs.homes[uid] = names
+	}
+
+	return fuseutil.NewFileSystemServer(fs)
+}
+
+// A file in the home directory.
+type homeFile struct {
+	uid  uint32
+	name string
+}
+
+type perCallerFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	// The sorted names visible to each UID in the home directory.
+	homes map[uint32][]string
+
+	mu sync.Mutex
+
+	// Inode IDs for the files in the home directory, allocated on first lookup.
+	//
+	// GUARDED_BY(mu)
+	inodes    map[homeFile]fuseops.InodeID
+	files     []homeFile // Indexed by inode ID less nextInode's initial value
+	nextInode fuseops.InodeID
+
+	// The listing for each open handle on the home directory, and the contents
+	// for each open file handle.
+	//
+	// GUARDED_BY(mu)
+	dirHandles  map[fuseops.HandleID][]fuseutil.Dirent
+	fileHandles map[fuseops.HandleID][]byte
+	nextHandle  fuseops.HandleID
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *perCallerFS) homeFileInode(f homeFile) fuseops.InodeID {
+	id, ok := fs.inodes[f]
+	if !ok {
+		id = fs.nextInode
+		fs.nextInode++
+		fs.inodes[f] = id
+		fs.files = append(fs.files, f)
+	}
+
+	return id
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *perCallerFS) findHomeFile(id fuseops.InodeID) (homeFile, bool) {
+	i := int(id - homeInode - 1)
+	if id <= homeInode || i >= len(fs.files) {
+		return homeFile{}, false
+	}
+
+	return fs.files[i], true
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *perCallerFS) attributes(
+	id fuseops.InodeID) (fuseops.InodeAttributes, error) {
+	switch id {
+	case rootInode, homeInode:
+		return fuseops.InodeAttributes{
+			Nlink: 1,
+			Mode:  0555 | os.ModeDir,
+		}, nil
+
+	case whoAmIInode:
+		// The size isn't known in advance; with direct IO the kernel reads until
+		// it sees EOF.
+		return fuseops.InodeAttributes{
+			Nlink: 1,
+			Mode:  0444,
+		}, nil
+	}
+
+	f, ok := fs.findHomeFile(id)
+	if !ok {
+		return fuseops.InodeAttributes{}, fuse.ENOENT
+	}
+
+	return fuseops.InodeAttributes{
+		Nlink: 1,
+		Mode:  0444,
+		Uid:   f.uid,
+	}, nil
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *perCallerFS) visible(uid uint32, name string) bool {
+	names := fs.homes[uid]
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
+
+func (fs *perCallerFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	switch {
+	case op.Parent == rootInode && op.Name == WhoAmIName:
+		op.Entry.Child = whoAmIInode
+
+	case op.Parent == rootInode && op.Name == HomeName:
+		op.Entry.Child = homeInode
+
+	case op.Parent == homeInode && fs.visible(op.OpContext.Uid, op.Name):
+		// Leave the expirations zero; see NewPerCallerFS.
+		op.Entry.Child = fs.homeFileInode(homeFile{op.OpContext.Uid, op.Name})
+
+	default:
+		return fuse.ENOENT
+	}
+
+	var err error
+	op.Entry.Attributes, err = fs.attributes(op.Entry.Child)
+	return err
+}
+
+func (fs *perCallerFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var err error
+	op.Attributes, err = fs.attributes(op.Inode)
+	return err
+}
+
+func (fs *perCallerFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var entries []fuseutil.Dirent
+	switch op.Inode {
+	case rootInode:
+		entries = []fuseutil.Dirent{
+			{Inode: whoAmIInode, Name: WhoAmIName, Type: fuseutil.DT_File},
+			{Inode: homeInode, Name: HomeName, Type: fuseutil.DT_Directory},
+		}
+
+	case homeInode:
+		uid := op.OpContext.Uid
+		for _, name := range fs.homes[uid] {
+			entries = append(entries, fuseutil.Dirent{
+				Inode: fs.homeFileInode(homeFile{uid, name}),
+				Name:  name,
+				Type:  fuseutil.DT_File,
+			})
+		}
+
+	default:
+		return fuse.ENOTDIR
+	}
+
+	for i := range entries {
+		entries[i].Offset = fuseops.DirOffset(i + 1)
+	}
+
+	op.Handle = fs.nextHandle
+	fs.nextHandle++
+	fs.dirHandles[op.Handle] = entries
+
+	return nil
+}
+
+func (fs *perCallerFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entries, ok := fs.dirHandles[op.Handle]
+	if !ok {
+		return fuse.EIO
+	}
+
+	for i := int(op.Offset); i < len(entries); i++ {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], entries[i])
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return nil
+}
+
+func (fs *perCallerFS) ReleaseDirHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseDirHandleOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.dirHandles, op.Handle)
+	return nil
+}
+
+func (fs *perCallerFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var contents string
+	if op.Inode == whoAmIInode {
+		contents = fmt.Sprintf("uid=%d pid=%d\n", op.OpContext.Uid, op.OpContext.Pid)
+	} else if f, ok := fs.findHomeFile(op.Inode); ok {
+		contents = fmt.Sprintf("This file belongs to uid %d.\n", f.uid)
+	} else {
+		return fuse.EIO
+	}
+
+	op.Handle = fs.nextHandle
+	fs.nextHandle++
+	fs.fileHandles[op.Handle] = []byte(contents)
+	op.UseDirectIO = true
+
+	return nil
+}
+
+func (fs *perCallerFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	contents, ok := fs.fileHandles[op.Handle]
+	if !ok {
+		return fuse.EIO
+	}
+
+	if op.Offset < int64(len(contents)) {
+		op.BytesRead = copy(op.Dst, contents[op.Offset:])
+	}
+
+	return nil
+}
+
+func (fs *perCallerFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.fileHandles, op.Handle)
+	return nil
+}
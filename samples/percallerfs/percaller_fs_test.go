@@ -0,0 +1,105 @@
+package percallerfs_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse/fusetesting"
+	"github.com/jacobsa/fuse/samples"
+	"github.com/jacobsa/fuse/samples/percallerfs"
+
+	. "github.com/jacobsa/oglematchers"
+	. "github.com/jacobsa/ogletest"
+)
+
+func TestMain(m *testing.M) { samples.Main(m) }
+
+func TestPerCallerFS(t *testing.T) { RunTests(t) }
+
+// A UID other than the test's own.
+const otherUID = 4321
+
+type PerCallerFSTest struct {
+	samples.SampleTest
+}
+
+func init() {
+	RegisterTestSuite(&PerCallerFSTest{})
+}
+
+func (t *PerCallerFSTest) SetUp(ti *TestInfo) {
+	t.Server = percallerfs.NewPerCallerFS(map[uint32][]string{
+		uint32(os.Getuid()): {"taco", "burrito"},
+		otherUID:            {"enchilada"},
+	})
+
+	// Only root can let other users in without configuring fuse.conf.
+	if os.Getuid() == 0 {
+		t.MountConfig.Options = map[string]string{"allow_other": ""}
+	}
+
+	t.SampleTest.SetUp(ti)
+}
+
+func (t *PerCallerFSTest) WhoAmI() {
+	contents, err := os.ReadFile(path.Join(t.Dir, percallerfs.WhoAmIName))
+	AssertEq(nil, err)
+	ExpectEq(fmt.Sprintf("uid=%d pid=%d\n", os.Getuid(), os.Getpid()), string(contents))
+}
+
+func (t *PerCallerFSTest) ReadDir_Home() {
+	entries, err := fusetesting.ReadDirPicky(path.Join(t.Dir, percallerfs.HomeName))
+	AssertEq(nil, err)
+	AssertEq(2, len(entries))
+	ExpectEq("burrito", entries[0].Name())
+	ExpectEq("taco", entries[1].Name())
+}
+
+func (t *PerCallerFSTest) ReadOwnFile() {
+	contents, err := os.ReadFile(path.Join(t.Dir, percallerfs.HomeName, "taco"))
+	AssertEq(nil, err)
+	ExpectEq(fmt.Sprintf("This file belongs to uid %d.\n", os.Getuid()), string(contents))
+}
+
+func (t *PerCallerFSTest) OtherUsersFilesAreHidden() {
+	_, err := os.Stat(path.Join(t.Dir, percallerfs.HomeName, "enchilada"))
+	ExpectThat(err, Error(HasSubstr("no such file")))
+}
+
+func (t *PerCallerFSTest) OtherUserSeesOwnView() {
+	// Running as another user requires root.
+	if os.Getuid() != 0 {
+		return
+	}
+
+	home := path.Join(t.Dir, percallerfs.HomeName)
+	asOther := func(name string, args ...string) (string, error) {
+		cmd := exec.Command(name, args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: otherUID, Gid: otherUID},
+		}
+
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	// Look up our own file first, to make sure the kernel doesn't share the
+	// entry with the other user.
+	_, err := os.Stat(path.Join(home, "taco"))
+	AssertEq(nil, err)
+
+	out, err := asOther("ls", home)
+	AssertEq(nil, err, "%s", out)
+	ExpectEq("enchilada\n", out)
+
+	out, err = asOther("cat", path.Join(home, "enchilada"))
+	AssertEq(nil, err, "%s", out)
+	ExpectEq(fmt.Sprintf("This file belongs to uid %d.\n", otherUID), out)
+
+	_, err = asOther("cat", path.Join(home, "taco"))
+	ExpectNe(nil, err)
+}
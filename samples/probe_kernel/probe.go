@@ -0,0 +1,72 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A tool that reports what the running kernel's FUSE implementation
+// supports, for inclusion in bug reports. See package fuseprobe.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseprobe"
+)
+
+var fJSON = flag.Bool("json", false, "Print the report as JSON.")
+var fDebug = flag.Bool("debug", false, "Enable debug logging.")
+var fMaxProtocolMinor = flag.Uint("max_protocol_minor", 0, "If non-zero, the highest protocol minor version to negotiate.")
+var fReaddirplus = flag.Bool("readdirplus", false, "Enable readdirplus, to see whether the kernel accepts it.")
+var fDisableWritebackCaching = flag.Bool("disable_writeback_caching", false, "Disable the writeback cache.")
+var fTimeout = flag.Duration("timeout", 30*time.Second, "How long to wait for the probe.")
+
+func main() {
+	flag.Parse()
+
+	cfg := fuse.MountConfig{
+		FSName:                  "fuseprobe",
+		MaxProtocolMinor:        uint32(*fMaxProtocolMinor),
+		EnableReaddirplus:       *fReaddirplus,
+		DisableWritebackCaching: *fDisableWritebackCaching,
+	}
+
+	if *fDebug {
+		cfg.DebugLogger = log.New(os.Stderr, "fuse: ", 0)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *fTimeout)
+	defer cancel()
+
+	r, err := fuseprobe.Probe(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Probe: %v", err)
+	}
+
+	if *fJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Fatalf("Encode: %v", err)
+		}
+
+		return
+	}
+
+	fmt.Print(r)
+}
@@ -19,12 +19,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
-	"syscall"
 
+	"github.com/jacobsa/fuse/fsutil"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
+	"golang.org/x/sys/unix"
 )
 
 var (
@@ -41,10 +43,12 @@ func nextInodeID() (next fuseops.InodeID) {
 type Inode interface {
 	Id() fuseops.InodeID
 	Path() string
+	Handle() *fsutil.Handle
 	String() string
 	Attributes() (*fuseops.InodeAttributes, error)
 	ListChildren(inodes *sync.Map) ([]*fuseutil.Dirent, error)
 	Contents() ([]byte, error)
+	Readlink() (string, error)
 }
 
 func getOrCreateInode(inodes *sync.Map, parentId fuseops.InodeID, name string) (Inode, error) {
@@ -54,32 +58,49 @@ func getOrCreateInode(inodes *sync.Map, parentId fuseops.InodeID, name string) (
 	}
 	parentPath := parent.(Inode).Path()
 
-	path := filepath.Join(parentPath, name)
-	fileInfo, err := os.Stat(path)
+	// Look up the child relative to the parent's handle rather than by path,
+	// so that a symlink swapped in for a directory can't lead outside the
+	// loopback path.
+	handle, err := parent.(Inode).Handle().Lookup(name)
 	if err != nil {
 		return nil, nil
 	}
-	stat, _ := fileInfo.Sys().(*syscall.Stat_t)
+	fileInfo, err := handle.Stat()
+	if err != nil {
+		handle.Close()
+		return nil, nil
+	}
+	stat := fileInfo.Sys().(*unix.Stat_t)
 
 	inodeEntry := &inodeEntry{
-		id:   fuseops.InodeID(stat.Ino),
-		path: path,
+		id:     fuseops.InodeID(stat.Ino),
+		path:   filepath.Join(parentPath, name),
+		handle: handle,
+	}
+	storedEntry, loaded := inodes.LoadOrStore(inodeEntry.id, inodeEntry)
+	if loaded {
+		handle.Close()
 	}
-	storedEntry, _ := inodes.LoadOrStore(inodeEntry.id, inodeEntry)
 	return storedEntry.(Inode), nil
 }
 
 type inodeEntry struct {
-	id   fuseops.InodeID
-	path string
+	id     fuseops.InodeID
+	path   string
+	handle *fsutil.Handle
 }
 
 var _ Inode = &inodeEntry{}
 
 func NewInode(path string) (Inode, error) {
+	handle, err := fsutil.OpenHandle(path)
+	if err != nil {
+		return nil, err
+	}
 	return &inodeEntry{
-		id:   nextInodeID(),
-		path: path,
+		id:     nextInodeID(),
+		path:   path,
+		handle: handle,
 	}, nil
 }
 
@@ -91,12 +112,16 @@ func (in *inodeEntry) Path() string {
 	return in.path
 }
 
+func (in *inodeEntry) Handle() *fsutil.Handle {
+	return in.handle
+}
+
 func (in *inodeEntry) String() string {
 	return fmt.Sprintf("%v::%v", in.id, in.path)
 }
 
 func (in *inodeEntry) Attributes() (*fuseops.InodeAttributes, error) {
-	fileInfo, err := os.Stat(in.path)
+	fileInfo, err := in.handle.Stat()
 	if err != nil {
 		return &fuseops.InodeAttributes{}, err
 	}
@@ -112,10 +137,18 @@ func (in *inodeEntry) Attributes() (*fuseops.InodeAttributes, error) {
 }
 
 func (in *inodeEntry) ListChildren(inodes *sync.Map) ([]*fuseutil.Dirent, error) {
-	children, err := ioutil.ReadDir(in.path)
+	dir, err := in.handle.Open(os.O_RDONLY)
 	if err != nil {
 		return nil, err
 	}
+	children, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
 	dirents := []*fuseutil.Dirent{}
 	for i, child := range children {
 
@@ -144,5 +177,14 @@ func (in *inodeEntry) ListChildren(inodes *sync.Map) ([]*fuseutil.Dirent, error)
 }
 
 func (in *inodeEntry) Contents() ([]byte, error) {
-	return ioutil.ReadFile(in.path)
+	f, err := in.handle.Open(os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (in *inodeEntry) Readlink() (string, error) {
+	return in.handle.Readlink()
 }
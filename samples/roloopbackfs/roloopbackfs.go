@@ -18,9 +18,12 @@ import (
 	"golang.org/x/net/context"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
+	"syscall"
 
 	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fsutil"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 )
@@ -30,6 +33,7 @@ type readonlyLoopbackFs struct {
 	loopbackPath string
 	inodes       *sync.Map
 	logger       *log.Logger
+	rules        *Rules
 }
 
 var _ fuseutil.FileSystem = &readonlyLoopbackFs{}
@@ -37,25 +41,77 @@ var _ fuseutil.FileSystem = &readonlyLoopbackFs{}
 // Create a file system that mirrors an existing physical path, in a readonly mode
 
 func NewReadonlyLoopbackServer(loopbackPath string, logger *log.Logger) (server fuse.Server, err error) {
+	return NewReadonlyLoopbackServerWithRules(loopbackPath, logger, nil)
+}
 
+// Like NewReadonlyLoopbackServer, but hiding or blocking paths according to
+// the supplied rules, which may be nil. The rules may be replaced while the
+// file system is mounted.
+func NewReadonlyLoopbackServerWithRules(
+	loopbackPath string,
+	logger *log.Logger,
+	rules *Rules) (server fuse.Server, err error) {
 	if _, err = os.Stat(loopbackPath); err != nil {
 		return nil, err
 	}
 
+	handle, err := fsutil.OpenHandle(loopbackPath)
+	if err != nil {
+		return nil, err
+	}
+
 	inodes := &sync.Map{}
 	root := &inodeEntry{
-		id:   fuseops.RootInodeID,
-		path: loopbackPath,
+		id:     fuseops.RootInodeID,
+		path:   loopbackPath,
+		handle: handle,
 	}
 	inodes.Store(root.Id(), root)
 	server = fuseutil.NewFileSystemServer(&readonlyLoopbackFs{
 		loopbackPath: loopbackPath,
 		inodes:       inodes,
 		logger:       logger,
+		rules:        rules,
 	})
 	return
 }
 
+// Return the action the rules specify for the supplied inode.
+func (fs *readonlyLoopbackFs) action(entry Inode) Action {
+	return fs.actionForPath(entry.Path())
+}
+
+// Return the action the rules specify for the supplied physical path.
+func (fs *readonlyLoopbackFs) actionForPath(p string) Action {
+	rel, err := filepath.Rel(fs.loopbackPath, p)
+	if err != nil {
+		return Hide
+	}
+
+	return fs.rules.Action(filepath.ToSlash(rel))
+}
+
+// Load the inode with the supplied ID, returning ENOENT if it doesn't exist or
+// is hidden.
+func (fs *readonlyLoopbackFs) load(id fuseops.InodeID) (Inode, error) {
+	entry, found := fs.inodes.Load(id)
+	if !found || fs.action(entry.(Inode)) == Hide {
+		return nil, fuse.ENOENT
+	}
+
+	return entry.(Inode), nil
+}
+
+// Like load, but also returning EACCES if the inode is blocked.
+func (fs *readonlyLoopbackFs) loadForOpen(id fuseops.InodeID) (Inode, error) {
+	entry, err := fs.load(id)
+	if err == nil && fs.action(entry) == Block {
+		err = syscall.EACCES
+	}
+
+	return entry, err
+}
+
 func (fs *readonlyLoopbackFs) StatFS(
 	ctx context.Context,
 	op *fuseops.StatFSOp) error {
@@ -70,7 +126,7 @@ func (fs *readonlyLoopbackFs) LookUpInode(
 		fs.logger.Printf("fs.LookUpInode for '%v' on '%v': %v", entry, op.Name, err)
 		return fuse.EIO
 	}
-	if entry == nil {
+	if entry == nil || fs.action(entry) == Hide {
 		return fuse.ENOENT
 	}
 	outputEntry := &op.Entry
@@ -87,11 +143,11 @@ func (fs *readonlyLoopbackFs) LookUpInode(
 func (fs *readonlyLoopbackFs) GetInodeAttributes(
 	ctx context.Context,
 	op *fuseops.GetInodeAttributesOp) error {
-	var entry, found = fs.inodes.Load(op.Inode)
-	if !found {
-		return fuse.ENOENT
+	entry, err := fs.load(op.Inode)
+	if err != nil {
+		return err
 	}
-	attributes, err := entry.(Inode).Attributes()
+	attributes, err := entry.Attributes()
 	if err != nil {
 		fs.logger.Printf("fs.GetInodeAttributes for '%v': %v", entry, err)
 		return fuse.EIO
@@ -103,30 +159,32 @@ func (fs *readonlyLoopbackFs) GetInodeAttributes(
 func (fs *readonlyLoopbackFs) OpenDir(
 	ctx context.Context,
 	op *fuseops.OpenDirOp) error {
-	// Allow opening any directory.
-	return nil
+	_, err := fs.loadForOpen(op.Inode)
+	return err
 }
 
 func (fs *readonlyLoopbackFs) ReadDir(
 	ctx context.Context,
 	op *fuseops.ReadDirOp) error {
-	var entry, found = fs.inodes.Load(op.Inode)
-	if !found {
-		return fuse.ENOENT
+	entry, err := fs.loadForOpen(op.Inode)
+	if err != nil {
+		return err
 	}
-	children, err := entry.(Inode).ListChildren(fs.inodes)
+	children, err := entry.ListChildren(fs.inodes)
 	if err != nil {
 		fs.logger.Printf("fs.ReadDir for '%v': %v", entry, err)
 		return fuse.EIO
 	}
 
-	if op.Offset > fuseops.DirOffset(len(children)) {
-		return nil
-	}
-
-	children = children[op.Offset:]
-
+	// Offsets count entries in the physical directory, including those that
+	// are skipped or hidden.
 	for _, child := range children {
+		if child.Offset <= op.Offset {
+			continue
+		}
+		if fs.actionForPath(filepath.Join(entry.Path(), child.Name)) == Hide {
+			continue
+		}
 		bytesWritten := fuseutil.WriteDirent(op.Dst[op.BytesRead:], *child)
 		if bytesWritten == 0 {
 			break
@@ -139,23 +197,18 @@ func (fs *readonlyLoopbackFs) ReadDir(
 func (fs *readonlyLoopbackFs) OpenFile(
 	ctx context.Context,
 	op *fuseops.OpenFileOp) error {
-
-	var _, found = fs.inodes.Load(op.Inode)
-	if !found {
-		return fuse.ENOENT
-	}
-	return nil
-
+	_, err := fs.loadForOpen(op.Inode)
+	return err
 }
 
 func (fs *readonlyLoopbackFs) ReadFile(
 	ctx context.Context,
 	op *fuseops.ReadFileOp) error {
-	var entry, found = fs.inodes.Load(op.Inode)
-	if !found {
-		return fuse.ENOENT
+	entry, err := fs.loadForOpen(op.Inode)
+	if err != nil {
+		return err
 	}
-	contents, err := entry.(Inode).Contents()
+	contents, err := entry.Contents()
 	if err != nil {
 		fs.logger.Printf("fs.ReadFile for '%v': %v", entry, err)
 		return fuse.EIO
@@ -170,6 +223,21 @@ func (fs *readonlyLoopbackFs) ReadFile(
 	return nil
 }
 
+func (fs *readonlyLoopbackFs) ReadSymlink(
+	ctx context.Context,
+	op *fuseops.ReadSymlinkOp) error {
+	entry, err := fs.loadForOpen(op.Inode)
+	if err != nil {
+		return err
+	}
+	op.Target, err = entry.Readlink()
+	if err != nil {
+		fs.logger.Printf("fs.ReadSymlink for '%v': %v", entry, err)
+		return fuse.EIO
+	}
+	return nil
+}
+
 func (fs *readonlyLoopbackFs) ReleaseDirHandle(
 	ctx context.Context,
 	op *fuseops.ReleaseDirHandleOp) error {
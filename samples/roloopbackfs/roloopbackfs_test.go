@@ -27,6 +27,7 @@ import (
 
 	"github.com/jacobsa/fuse/samples"
 	"github.com/jacobsa/fuse/samples/roloopbackfs"
+	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
 )
 
@@ -34,11 +35,14 @@ var (
 	letters = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestReadonlyLoopbackFS(t *testing.T) { RunTests(t) }
 
 type ReadonlyLoopbackFSTest struct {
 	samples.SampleTest
 	physicalPath string
+	rules        *roloopbackfs.Rules
 }
 
 func init() {
@@ -61,9 +65,13 @@ func (t *ReadonlyLoopbackFSTest) SetUp(ti *TestInfo) {
 
 	t.fillPhysicalFS()
 
-	t.Server, err = roloopbackfs.NewReadonlyLoopbackServer(
+	t.rules, err = roloopbackfs.NewRules()
+	AssertEq(nil, err)
+
+	t.Server, err = roloopbackfs.NewReadonlyLoopbackServerWithRules(
 		t.physicalPath,
 		log.New(os.Stdout, "", 0),
+		t.rules,
 	)
 	AssertEq(nil, err)
 	t.SampleTest.SetUp(ti)
@@ -176,3 +184,109 @@ func (t *ReadonlyLoopbackFSTest) ReadFile() {
 	AssertEq(nil, err)
 	AssertEq(20, len(bytes))
 }
+
+func (t *ReadonlyLoopbackFSTest) HiddenPaths() {
+	err := t.rules.Set([]roloopbackfs.Rule{
+		{Pattern: "top_dir_2", Action: roloopbackfs.Hide},
+		{Pattern: "top_dir_1/secondary_dir_1", Action: roloopbackfs.Allow},
+		{Pattern: "*/secondary_dir_*", Action: roloopbackfs.Hide},
+	})
+	AssertEq(nil, err)
+
+	// Hidden entries aren't listed.
+	infos, err := ioutil.ReadDir(t.Dir)
+	AssertEq(nil, err)
+	AssertEq(9, len(infos))
+	for _, info := range infos {
+		ExpectNe("top_dir_2", info.Name())
+	}
+
+	infos, err = ioutil.ReadDir(filepath.Join(t.Dir, "top_dir_1"))
+	AssertEq(nil, err)
+	AssertEq(2, len(infos))
+	ExpectEq("secondary_dir_1", infos[0].Name())
+	ExpectEq("secondary_file.txt", infos[1].Name())
+
+	// Nor can they be looked up, nor can anything beneath them.
+	_, err = os.Stat(filepath.Join(t.Dir, "top_dir_2"))
+	ExpectTrue(os.IsNotExist(err), "err: %v", err)
+
+	_, err = os.Stat(filepath.Join(t.Dir, "top_dir_3", "secondary_dir_2", "file_2.txt"))
+	ExpectTrue(os.IsNotExist(err), "err: %v", err)
+
+	// Replacing the rules takes effect immediately.
+	err = t.rules.Set(nil)
+	AssertEq(nil, err)
+
+	_, err = os.Stat(filepath.Join(t.Dir, "top_dir_3", "secondary_dir_2", "file_2.txt"))
+	ExpectEq(nil, err)
+}
+
+func (t *ReadonlyLoopbackFSTest) BlockedPaths() {
+	err := t.rules.Load(strings.NewReader(`
+# Secrets are visible, but can't be read.
+block */secondary_file.txt
+`))
+	AssertEq(nil, err)
+
+	p := filepath.Join(t.Dir, "top_dir_1", "secondary_file.txt")
+	info, err := os.Stat(p)
+	AssertEq(nil, err)
+	ExpectEq(17, info.Size())
+
+	_, err = ioutil.ReadFile(p)
+	ExpectTrue(os.IsPermission(err), "err: %v", err)
+
+	_, err = ioutil.ReadFile(filepath.Join(t.Dir, "top_dir_1", "secondary_dir_1", "file_2.txt"))
+	ExpectEq(nil, err)
+}
+
+func (t *ReadonlyLoopbackFSTest) MalformedRules() {
+	err := t.rules.Load(strings.NewReader("hide top_dir_1\ndeny top_dir_2\n"))
+	ExpectThat(err, Error(HasSubstr("line 2")))
+
+	err = t.rules.Set([]roloopbackfs.Rule{{Pattern: "[", Action: roloopbackfs.Hide}})
+	ExpectNe(nil, err)
+
+	// The previous rules are still in effect.
+	_, err = os.Stat(filepath.Join(t.Dir, "top_dir_1"))
+	ExpectEq(nil, err)
+	ExpectEq(roloopbackfs.Allow, t.rules.Action("top_dir_1"))
+}
+
+func (t *ReadonlyLoopbackFSTest) Symlinks() {
+	// Symlinks in the physical directory, one of them leading outside it, are
+	// presented as symlinks rather than followed by the file system.
+	outside, err := ioutil.TempDir("", "")
+	AssertEq(nil, err)
+	defer os.RemoveAll(outside)
+
+	err = ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("taco"), 0600)
+	AssertEq(nil, err)
+
+	err = os.Symlink(outside, filepath.Join(t.physicalPath, "top_dir_1", "escape"))
+	AssertEq(nil, err)
+
+	err = os.Symlink("secondary_file.txt", filepath.Join(t.physicalPath, "top_dir_1", "link"))
+	AssertEq(nil, err)
+
+	info, err := os.Lstat(filepath.Join(t.Dir, "top_dir_1", "escape"))
+	AssertEq(nil, err)
+	ExpectEq(os.ModeSymlink, info.Mode()&os.ModeType)
+
+	target, err := os.Readlink(filepath.Join(t.Dir, "top_dir_1", "escape"))
+	AssertEq(nil, err)
+	ExpectEq(outside, target)
+
+	// Relative symlinks work as usual.
+	contents, err := ioutil.ReadFile(filepath.Join(t.Dir, "top_dir_1", "link"))
+	AssertEq(nil, err)
+	ExpectEq(17, len(contents))
+
+	// Blocked symlinks can't be read.
+	err = t.rules.Set([]roloopbackfs.Rule{{Pattern: "*/escape", Action: roloopbackfs.Block}})
+	AssertEq(nil, err)
+
+	_, err = os.Readlink(filepath.Join(t.Dir, "top_dir_1", "escape"))
+	ExpectTrue(os.IsPermission(err), "err: %v", err)
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roloopbackfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+// An Action says what happens to the paths matched by a Rule.
+type Action int
+
+const (
+	// The path is visible and readable.
+	Allow Action = iota
+
+	// The path doesn't appear in directory listings, and looking it up fails
+	// with ENOENT.
+	Hide
+
+	// The path is listed and can be stat'd, but opening it fails with EACCES.
+	Block
+)
+
+var actionNames = map[string]Action{
+	"allow": Allow,
+	"hide":  Hide,
+	"block": Block,
+}
+
+// A Rule applies an action to the paths matched by a glob pattern (see
+// path.Match), relative to the root of the loopback file system and without a
+// leading slash. A pattern matching a directory also matches everything
+// beneath it, so "secret" and "*/.git" act as prefixes.
+type Rule struct {
+	Pattern string
+	Action  Action
+}
+
+// Rules is a list of rules that may be replaced while the file system is
+// mounted. The first rule matching a path decides its action; paths matching
+// no rule are allowed. The root is always allowed.
+//
+// Because the kernel may cache entries and open files, a change may not take
+// effect for paths that are already in use.
+type Rules struct {
+	mu    sync.RWMutex
+	rules []Rule // GUARDED_BY(mu)
+}
+
+// NewRules returns rules initially containing the supplied list.
+func NewRules(rules ...Rule) (*Rules, error) {
+	r := &Rules{}
+	if err := r.Set(rules); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Set replaces the list of rules. It fails, leaving the list unchanged, if any
+// pattern is malformed.
+func (r *Rules) Set(rules []Rule) error {
+	for _, rule := range rules {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", rule.Pattern, err)
+		}
+	}
+
+	rules = append([]Rule(nil), rules...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = rules
+	return nil
+}
+
+// Load replaces the list of rules with those read from the supplied reader,
+// one per line in the form "<action> <pattern>", e.g. "hide *.key". Empty
+// lines and lines starting with '#' are ignored.
+func (r *Rules) Load(reader io.Reader) error {
+	var rules []Rule
+	scanner := bufio.NewScanner(reader)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		action, ok := actionNames[fields[0]]
+		if len(fields) != 2 || !ok {
+			return fmt.Errorf("line %d: expected \"allow|hide|block <pattern>\"", n)
+		}
+
+		rules = append(rules, Rule{Pattern: fields[1], Action: action})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return r.Set(rules)
+}
+
+// Action returns the action for the supplied path, relative to the root. A nil
+// *Rules allows everything.
+func (r *Rules) Action(rel string) Action {
+	if r == nil || rel == "." || rel == "" {
+		return Allow
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rule := range r.rules {
+		// Match the path and each of its ancestors.
+		for p := rel; p != "."; p = path.Dir(p) {
+			if ok, _ := path.Match(rule.Pattern, p); ok {
+				return rule.Action
+			}
+		}
+	}
+
+	return Allow
+}
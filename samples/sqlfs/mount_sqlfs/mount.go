@@ -0,0 +1,79 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os/user"
+	"strconv"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/samples/sqlfs"
+	_ "modernc.org/sqlite"
+)
+
+var fMountPoint = flag.String("mount_point", "", "Path to mount point.")
+var fDatabase = flag.String("database", "", "Path to the SQLite database, created if necessary.")
+
+func main() {
+	flag.Parse()
+
+	if *fMountPoint == "" {
+		log.Fatalf("You must set --mount_point.")
+	}
+
+	if *fDatabase == "" {
+		log.Fatalf("You must set --database.")
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	uid, err := strconv.ParseUint(user.Uid, 10, 32)
+	if err != nil {
+		panic(err)
+	}
+
+	gid, err := strconv.ParseUint(user.Gid, 10, 32)
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := sql.Open("sqlite", *fDatabase+"?_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		log.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	server, err := sqlfs.NewSQLFS(context.Background(), db, uint32(uid), uint32(gid))
+	if err != nil {
+		log.Fatalf("NewSQLFS: %v", err)
+	}
+
+	mfs, err := fuse.Mount(*fMountPoint, server, &fuse.MountConfig{})
+	if err != nil {
+		log.Fatalf("Mount: %v", err)
+	}
+
+	// Wait for it to be unmounted.
+	if err = mfs.Join(context.Background()); err != nil {
+		log.Fatalf("Join: %v", err)
+	}
+}
@@ -0,0 +1,858 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlfs contains a file system that stores its namespace and file
+// contents in a SQLite database, as a template for metadata-heavy file
+// systems backed by a transactional store.
+//
+// The package uses only database/sql, so it doesn't depend on a particular
+// driver. The caller opens the database with a SQLite driver of its choice
+// (e.g. modernc.org/sqlite, as mount_sqlfs does, or
+// github.com/mattn/go-sqlite3). For fsync to mean what applications expect,
+// the database should use synchronous=FULL.
+//
+// The file system writes to the database from the goroutines serving ops, and
+// database/sql may use several connections to it, so the database should also
+// set a busy timeout (e.g. busy_timeout=5000). Without one, a statement that
+// finds the database locked by another connection fails with SQLITE_BUSY
+// rather than waiting. This includes queries through any other handle on the
+// database used while the file system is mounted.
+//
+// This package is its own module, so that the driver used by mount_sqlfs and
+// the tests isn't a dependency of github.com/jacobsa/fuse.
+package sqlfs
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// Inode IDs are allocated by AUTOINCREMENT, which never reuses the ID of a
+// deleted row. That rules out the kernel confusing a new inode with a stale
+// one it still remembers, across restarts as well as within a mount. The root
+// has ID 1, matching fuseops.RootInodeID.
+const schema = `
+CREATE TABLE IF NOT EXISTS inodes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	mode INTEGER NOT NULL,
+	nlink INTEGER NOT NULL,
+	mtime INTEGER NOT NULL,
+	data BLOB NOT NULL DEFAULT x''
+);
+
+CREATE TABLE IF NOT EXISTS dentries (
+	parent INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	child INTEGER NOT NULL,
+	PRIMARY KEY (parent, name)
+);
+`
+
+// A file whose contents have been written but not yet committed.
+type dirtyFile struct {
+	data  []byte
+	mtime time.Time
+}
+
+type sqlFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	db *sql.DB
+
+	// The UID and GID that every inode receives.
+	uid uint32
+	gid uint32
+
+	mu sync.Mutex
+
+	// Writes are buffered here and committed to the database in a single
+	// transaction when the file is synced or renamed, or the file system is
+	// unmounted, so a crash leaves each file with the contents of one of those
+	// commits, never a mix.
+	//
+	// GUARDED_BY(mu)
+	dirty map[fuseops.InodeID]*dirtyFile
+
+	// The kernel's lookup count for each inode it knows about. An unlinked
+	// inode is deleted from the database once its count reaches zero.
+	//
+	// GUARDED_BY(mu)
+	lookups map[fuseops.InodeID]uint64
+}
+
+// Create a file system backed by the supplied SQLite database, creating the
+// schema and root directory if they don't already exist.
+//
+// The supplied UID/GID pair will own every inode. This file system does no
+// permissions checking, and should therefore be mounted with the
+// default_permissions option.
+func NewSQLFS(
+	ctx context.Context,
+	db *sql.DB,
+	uid uint32,
+	gid uint32) (fuse.Server, error) {
+	fs := &sqlFS{
+		db:      db,
+		uid:     uid,
+		gid:     gid,
+		dirty:   make(map[fuseops.InodeID]*dirtyFile),
+		lookups: make(map[fuseops.InodeID]uint64),
+	}
+
+	err := fs.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, schema); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT OR IGNORE INTO inodes (id, mode, nlink, mtime) VALUES (?, ?, 1, ?)",
+			fuseops.RootInodeID,
+			uint32(0700|os.ModeDir),
+			time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+
+		// Inodes that were unlinked while open when the previous mount went away
+		// can no longer be reached.
+		_, err = tx.ExecContext(ctx, "DELETE FROM inodes WHERE nlink = 0")
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fuseutil.NewFileSystemServer(fs), nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// Run f in a transaction, committing if it returns nil and rolling back
+// otherwise.
+func (fs *sqlFS) withTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := fs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Either a *sql.DB or a *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Find the child with the given name in a directory, returning ENOENT if
+// there is none.
+func lookUpChild(
+	ctx context.Context,
+	q querier,
+	parent fuseops.InodeID,
+	name string) (fuseops.InodeID, error) {
+	var child fuseops.InodeID
+	err := q.QueryRowContext(
+		ctx,
+		"SELECT child FROM dentries WHERE parent = ? AND name = ?",
+		parent,
+		name).Scan(&child)
+
+	if err == sql.ErrNoRows {
+		return 0, fuse.ENOENT
+	}
+
+	return child, err
+}
+
+// Read the attributes of an inode, taking into account uncommitted writes.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) getAttributes(
+	ctx context.Context,
+	q querier,
+	id fuseops.InodeID) (fuseops.InodeAttributes, error) {
+	var mode uint32
+	var nlink uint32
+	var mtime int64
+	var size uint64
+	err := q.QueryRowContext(
+		ctx,
+		"SELECT mode, nlink, mtime, length(data) FROM inodes WHERE id = ?",
+		id).Scan(&mode, &nlink, &mtime, &size)
+
+	if err == sql.ErrNoRows {
+		return fuseops.InodeAttributes{}, fuse.ENOENT
+	}
+
+	if err != nil {
+		return fuseops.InodeAttributes{}, err
+	}
+
+	attrs := fuseops.InodeAttributes{
+		Size:  size,
+		Nlink: nlink,
+		Mode:  os.FileMode(mode),
+		Mtime: time.Unix(0, mtime),
+		Uid:   fs.uid,
+		Gid:   fs.gid,
+	}
+
+	if d, ok := fs.dirty[id]; ok {
+		attrs.Size = uint64(len(d.data))
+		attrs.Mtime = d.mtime
+	}
+
+	attrs.Atime = attrs.Mtime
+	attrs.Ctime = attrs.Mtime
+
+	return attrs, nil
+}
+
+// Fill in a ChildInodeEntry for an inode the kernel is about to learn about,
+// incrementing its lookup count.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) fillEntry(
+	ctx context.Context,
+	q querier,
+	id fuseops.InodeID,
+	e *fuseops.ChildInodeEntry) error {
+	attrs, err := fs.getAttributes(ctx, q, id)
+	if err != nil {
+		return err
+	}
+
+	fs.lookups[id]++
+
+	// Nobody else modifies the database, so the kernel can cache as long as it
+	// wants (since it also handles invalidation).
+	e.Child = id
+	e.Attributes = attrs
+	e.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+	e.EntryExpiration = e.AttributesExpiration
+
+	return nil
+}
+
+// Return the uncommitted contents of a file, loading them from the database
+// if there are none.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) getDirty(
+	ctx context.Context,
+	id fuseops.InodeID) (*dirtyFile, error) {
+	if d, ok := fs.dirty[id]; ok {
+		return d, nil
+	}
+
+	d := &dirtyFile{}
+	err := fs.db.QueryRowContext(
+		ctx,
+		"SELECT data FROM inodes WHERE id = ?",
+		id).Scan(&d.data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fs.dirty[id] = d
+	return d, nil
+}
+
+// Either a *sql.DB or a *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Write any uncommitted contents of a file to the database. The caller must
+// remove them from fs.dirty once they have been committed.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) writeDirty(
+	ctx context.Context,
+	e execer,
+	id fuseops.InodeID) error {
+	d, ok := fs.dirty[id]
+	if !ok {
+		return nil
+	}
+
+	_, err := e.ExecContext(
+		ctx,
+		"UPDATE inodes SET data = ?, mtime = ? WHERE id = ?",
+		d.data,
+		d.mtime.UnixNano(),
+		id)
+	return err
+}
+
+// Commit any uncommitted contents of a file to the database.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) commit(ctx context.Context, id fuseops.InodeID) error {
+	if err := fs.writeDirty(ctx, fs.db, id); err != nil {
+		return err
+	}
+
+	delete(fs.dirty, id)
+	return nil
+}
+
+// Insert a new inode and an entry for it in the parent directory, returning
+// the new inode's ID.
+func createChild(
+	ctx context.Context,
+	tx *sql.Tx,
+	parent fuseops.InodeID,
+	name string,
+	mode os.FileMode) (fuseops.InodeID, error) {
+	// Ensure that the name doesn't already exist, so we don't wind up with a
+	// duplicate.
+	_, err := lookUpChild(ctx, tx, parent, name)
+	if err == nil {
+		return 0, fuse.EEXIST
+	}
+
+	if err != fuse.ENOENT {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO inodes (mode, nlink, mtime) VALUES (?, 1, ?)",
+		uint32(mode),
+		time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO dentries (parent, name, child) VALUES (?, ?, ?)",
+		parent,
+		name,
+		id)
+	if err != nil {
+		return 0, err
+	}
+
+	return fuseops.InodeID(id), nil
+}
+
+// Remove a directory entry and drop the link count of its child.
+func removeChild(
+	ctx context.Context,
+	tx *sql.Tx,
+	parent fuseops.InodeID,
+	name string,
+	child fuseops.InodeID) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM dentries WHERE parent = ? AND name = ?",
+		parent,
+		name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE inodes SET nlink = nlink - 1 WHERE id = ?",
+		child)
+	return err
+}
+
+// Return ENOTEMPTY if the inode is a directory with entries.
+func checkEmpty(ctx context.Context, tx *sql.Tx, id fuseops.InodeID) error {
+	var n int
+	err := tx.QueryRowContext(
+		ctx,
+		"SELECT count(*) FROM dentries WHERE parent = ?",
+		id).Scan(&n)
+	if err != nil {
+		return err
+	}
+
+	if n != 0 {
+		return fuse.ENOTEMPTY
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// FileSystem methods
+////////////////////////////////////////////////////////////////////////
+
+func (fs *sqlFS) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) error {
+	return nil
+}
+
+func (fs *sqlFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	child, err := lookUpChild(ctx, fs.db, op.Parent, op.Name)
+	if err != nil {
+		return err
+	}
+
+	return fs.fillEntry(ctx, fs.db, child, &op.Entry)
+}
+
+func (fs *sqlFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	attrs, err := fs.getAttributes(ctx, fs.db, op.Inode)
+	if err != nil {
+		return err
+	}
+
+	op.Attributes = attrs
+	op.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+
+	return nil
+}
+
+func (fs *sqlFS) SetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.SetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// A truncation goes through the dirty contents, and is committed along with
+	// any writes that preceded it.
+	if op.Size != nil {
+		d, err := fs.getDirty(ctx, op.Inode)
+		if err != nil {
+			return err
+		}
+
+		if *op.Size <= uint64(len(d.data)) {
+			d.data = d.data[:*op.Size]
+		} else {
+			d.data = append(d.data, make([]byte, *op.Size-uint64(len(d.data)))...)
+		}
+
+		d.mtime = time.Now()
+	}
+
+	if op.Mtime != nil {
+		if d, ok := fs.dirty[op.Inode]; ok {
+			d.mtime = *op.Mtime
+		}
+	}
+
+	err := fs.withTx(ctx, func(tx *sql.Tx) error {
+		if op.Mode != nil {
+			attrs, err := fs.getAttributes(ctx, tx, op.Inode)
+			if err != nil {
+				return err
+			}
+
+			// Keep the file type bits.
+			mode := attrs.Mode&^os.ModePerm | *op.Mode&os.ModePerm
+			_, err = tx.ExecContext(
+				ctx,
+				"UPDATE inodes SET mode = ? WHERE id = ?",
+				uint32(mode),
+				op.Inode)
+			if err != nil {
+				return err
+			}
+		}
+
+		if op.Mtime != nil {
+			_, err := tx.ExecContext(
+				ctx,
+				"UPDATE inodes SET mtime = ? WHERE id = ?",
+				op.Mtime.UnixNano(),
+				op.Inode)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	attrs, err := fs.getAttributes(ctx, fs.db, op.Inode)
+	if err != nil {
+		return err
+	}
+
+	op.Attributes = attrs
+	op.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+
+	return nil
+}
+
+func (fs *sqlFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.lookups[op.Inode] -= op.N
+	if fs.lookups[op.Inode] != 0 {
+		return nil
+	}
+
+	delete(fs.lookups, op.Inode)
+
+	// The kernel can no longer refer to the inode, so if it has been unlinked
+	// nobody can.
+	res, err := fs.db.ExecContext(
+		ctx,
+		"DELETE FROM inodes WHERE id = ? AND nlink = 0",
+		op.Inode)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n != 0 {
+		delete(fs.dirty, op.Inode)
+	}
+
+	return nil
+}
+
+func (fs *sqlFS) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.withTx(ctx, func(tx *sql.Tx) error {
+		child, err := createChild(ctx, tx, op.Parent, op.Name, op.Mode|os.ModeDir)
+		if err != nil {
+			return err
+		}
+
+		return fs.fillEntry(ctx, tx, child, &op.Entry)
+	})
+}
+
+func (fs *sqlFS) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.withTx(ctx, func(tx *sql.Tx) error {
+		child, err := createChild(ctx, tx, op.Parent, op.Name, op.Mode)
+		if err != nil {
+			return err
+		}
+
+		return fs.fillEntry(ctx, tx, child, &op.Entry)
+	})
+}
+
+// Rename is a single transaction, so it is atomic even across a crash:
+// afterward the database holds either the old name or the new one, and a file
+// that was replaced is either still linked or gone. The renamed file's
+// uncommitted contents are committed in the same transaction, so that a file
+// written and closed, then renamed over another, replaces it whole: after a
+// crash the new name refers to either the old file or all of the new
+// contents.
+func (fs *sqlFS) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var child fuseops.InodeID
+	err := fs.withTx(ctx, func(tx *sql.Tx) (err error) {
+		child, err = lookUpChild(ctx, tx, op.OldParent, op.OldName)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.writeDirty(ctx, tx, child); err != nil {
+			return err
+		}
+
+		// If the new name exists already in the new parent, make sure it's not a
+		// non-empty directory, then unlink it.
+		existing, err := lookUpChild(ctx, tx, op.NewParent, op.NewName)
+		switch {
+		case err == nil && existing == child:
+			// Renaming a file onto itself does nothing.
+			return nil
+
+		case err == nil:
+			if err := checkEmpty(ctx, tx, existing); err != nil {
+				return err
+			}
+
+			err = removeChild(ctx, tx, op.NewParent, op.NewName, existing)
+			if err != nil {
+				return err
+			}
+
+		case err != fuse.ENOENT:
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE dentries SET parent = ?, name = ? WHERE parent = ? AND name = ?",
+			op.NewParent,
+			op.NewName,
+			op.OldParent,
+			op.OldName)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	delete(fs.dirty, child)
+	return nil
+}
+
+func (fs *sqlFS) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.withTx(ctx, func(tx *sql.Tx) error {
+		child, err := lookUpChild(ctx, tx, op.Parent, op.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := checkEmpty(ctx, tx, child); err != nil {
+			return err
+		}
+
+		return removeChild(ctx, tx, op.Parent, op.Name, child)
+	})
+}
+
+func (fs *sqlFS) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.withTx(ctx, func(tx *sql.Tx) error {
+		child, err := lookUpChild(ctx, tx, op.Parent, op.Name)
+		if err != nil {
+			return err
+		}
+
+		return removeChild(ctx, tx, op.Parent, op.Name, child)
+	})
+}
+
+func (fs *sqlFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	return nil
+}
+
+// Entries are returned in rowid order, with the rowid as the offset, so a
+// listing resumes in the right place even if entries are added or removed in
+// the meantime.
+func (fs *sqlFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	rows, err := fs.db.QueryContext(
+		ctx,
+		`SELECT dentries.rowid, name, child, mode
+		FROM dentries JOIN inodes ON child = id
+		WHERE parent = ? AND dentries.rowid > ?
+		ORDER BY dentries.rowid`,
+		op.Inode,
+		op.Offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d fuseutil.Dirent
+		var mode uint32
+		if err := rows.Scan(&d.Offset, &d.Name, &d.Inode, &mode); err != nil {
+			return err
+		}
+
+		d.Type = fuseutil.DT_File
+		if os.FileMode(mode).IsDir() {
+			d.Type = fuseutil.DT_Directory
+		}
+
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], d)
+		if n == 0 {
+			break
+		}
+
+		op.BytesRead += n
+	}
+
+	return rows.Err()
+}
+
+func (fs *sqlFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	return nil
+}
+
+func (fs *sqlFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if d, ok := fs.dirty[op.Inode]; ok {
+		if op.Offset < int64(len(d.data)) {
+			op.BytesRead = copy(op.Dst, d.data[op.Offset:])
+		}
+
+		return nil
+	}
+
+	// SQLite's substr is 1-indexed.
+	var data []byte
+	err := fs.db.QueryRowContext(
+		ctx,
+		"SELECT substr(data, ?, ?) FROM inodes WHERE id = ?",
+		op.Offset+1,
+		len(op.Dst),
+		op.Inode).Scan(&data)
+	if err != nil {
+		return err
+	}
+
+	op.BytesRead = copy(op.Dst, data)
+	return nil
+}
+
+func (fs *sqlFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	d, err := fs.getDirty(ctx, op.Inode)
+	if err != nil {
+		return err
+	}
+
+	end := op.Offset + int64(len(op.Data))
+	if end > int64(len(d.data)) {
+		d.data = append(d.data, make([]byte, end-int64(len(d.data)))...)
+	}
+
+	copy(d.data[op.Offset:], op.Data)
+	d.mtime = time.Now()
+
+	return nil
+}
+
+// Once SyncFile returns, the file's contents have been committed, and are as
+// durable as the database makes its transactions.
+func (fs *sqlFS) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.commit(ctx, op.Inode)
+}
+
+// Closing a file doesn't commit it, leaving a following rename to commit its
+// contents along with its new name. Processes using the mount see the
+// uncommitted contents meanwhile.
+func (fs *sqlFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp) error {
+	return nil
+}
+
+func (fs *sqlFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
+
+// SyncFS is unlikely to be called for a FUSE mount, but if it is, commit
+// everything.
+func (fs *sqlFS) SyncFS(
+	ctx context.Context,
+	op *fuseops.SyncFSOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.commitAll(ctx)
+}
+
+// Commit everything once unmounted, so that the next mount sees it.
+func (fs *sqlFS) Destroy() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.commitAll(context.Background())
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *sqlFS) commitAll(ctx context.Context) error {
+	for id := range fs.dirty {
+		if err := fs.commit(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
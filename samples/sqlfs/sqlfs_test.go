@@ -0,0 +1,173 @@
+package sqlfs_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/samples"
+	"github.com/jacobsa/fuse/samples/sqlfs"
+	_ "modernc.org/sqlite"
+)
+
+func TestMain(m *testing.M) { samples.Main(m) }
+
+// Mount a file system backed by db, returning a function that unmounts it.
+func mount(t *testing.T, db *sql.DB) (dir string, unmount func()) {
+	t.Helper()
+
+	ctx := context.Background()
+	server, err := sqlfs.NewSQLFS(ctx, db, uint32(os.Getuid()), uint32(os.Getgid()))
+	if err != nil {
+		t.Fatalf("NewSQLFS: %v", err)
+	}
+
+	dir = t.TempDir()
+	mfs, err := fuse.Mount(dir, server, &fuse.MountConfig{})
+	if err != nil {
+		t.Fatalf("Mount: %v", err)
+	}
+
+	unmounted := false
+	unmount = func() {
+		if unmounted {
+			return
+		}
+
+		unmounted = true
+		if err := fuse.Unmount(dir); err != nil {
+			t.Fatalf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(ctx); err != nil {
+			t.Fatalf("Join: %v", err)
+		}
+	}
+
+	t.Cleanup(unmount)
+	return dir, unmount
+}
+
+// Return the committed contents of the file with the given name, and whether
+// there is one.
+func committed(t *testing.T, db *sql.DB, name string) (string, bool) {
+	t.Helper()
+
+	var data []byte
+	err := db.QueryRow(
+		"SELECT data FROM inodes JOIN dentries ON inodes.id = dentries.child WHERE dentries.name = ?",
+		name).Scan(&data)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return "", false
+	case err != nil:
+		t.Fatalf("QueryRow: %v", err)
+	}
+
+	return string(data), true
+}
+
+func TestRenameCommitsContentsAndSurvivesRemount(t *testing.T) {
+	db, err := sql.Open("sqlite", path.Join(t.TempDir(), "fs.db")+"?_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	dir, unmount := mount(t, db)
+
+	if err := os.Mkdir(path.Join(dir, "dir"), 0700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	// A file that is synced is committed.
+	f, err := os.Create(path.Join(dir, "dir/foo"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := f.WriteString("taco"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got, _ := committed(t, db, "foo"); got != "taco" {
+		t.Fatalf("committed foo: got %q, want %q", got, "taco")
+	}
+
+	// A file that is written and closed is not, until it is renamed over foo.
+	if err := os.WriteFile(path.Join(dir, "dir/tmp"), []byte("burrito"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if got, _ := committed(t, db, "tmp"); got != "" {
+		t.Fatalf("committed tmp before rename: got %q, want empty", got)
+	}
+
+	if got, _ := committed(t, db, "foo"); got != "taco" {
+		t.Fatalf("committed foo before rename: got %q, want %q", got, "taco")
+	}
+
+	if err := os.Rename(path.Join(dir, "dir/tmp"), path.Join(dir, "dir/foo")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if got, _ := committed(t, db, "foo"); got != "burrito" {
+		t.Fatalf("committed foo after rename: got %q, want %q", got, "burrito")
+	}
+
+	if _, ok := committed(t, db, "tmp"); ok {
+		t.Fatalf("tmp still exists after rename")
+	}
+
+	// A file that is neither synced nor renamed is committed on unmount.
+	if err := os.WriteFile(path.Join(dir, "dir/bar"), []byte("enchilada"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	unmount()
+
+	// Everything is there after mounting again.
+	dir, _ = mount(t, db)
+
+	entries, err := os.ReadDir(path.Join(dir, "dir"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "bar" || names[1] != "foo" {
+		t.Fatalf("ReadDir: got %q, want [bar foo]", names)
+	}
+
+	for name, want := range map[string]string{
+		"foo": "burrito",
+		"bar": "enchilada",
+	} {
+		contents, err := os.ReadFile(path.Join(dir, "dir", name))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+
+		if string(contents) != want {
+			t.Errorf("%s: got %q, want %q", name, contents, want)
+		}
+	}
+}
@@ -118,7 +118,7 @@ func (t *StatFSTest) BlockSizes() {
 		err = syscall.Statfs(t.Dir, &stat)
 		AssertEq(nil, err)
 
-		ExpectEq(bs, stat.Frsize, "%s", desc)
+		ExpectEq(bs, uint32(stat.Frsize), "%s", desc)
 	}
 }
 
@@ -155,6 +155,6 @@ func (t *StatFSTest) IoSizes() {
 		err = syscall.Statfs(t.Dir, &stat)
 		AssertEq(nil, err)
 
-		ExpectEq(bs, stat.Bsize, "%s", desc)
+		ExpectEq(bs, uint32(stat.Bsize), "%s", desc)
 	}
 }
@@ -33,6 +33,8 @@ import (
 	. "github.com/jacobsa/ogletest"
 )
 
+func TestMain(m *testing.M) { samples.Main(m) }
+
 func TestStatFS(t *testing.T) { RunTests(t) }
 
 const fsName = "some_fs_name"
@@ -37,6 +37,12 @@ var fToolPath = flag.String(
 
 var fDebug = flag.Bool("debug", false, "If true, print fuse debug info.")
 
+var fMaxProtocolMinor = flag.Uint(
+	"max_protocol_minor",
+	0,
+	"If non-zero, mount with fuse.MountConfig.MaxProtocolMinor set to this, "+
+		"to run the tests as if on an older kernel.")
+
 // A struct that implements common behavior needed by tests in the samples/
 // directory where the file system is mounted by a subprocess. Use it as an
 // embedded field in your test fixture, calling its SetUp method from your
@@ -267,6 +273,12 @@ func (t *SubprocessTest) initialize(ctx context.Context) error {
 		mountCmd.Args = append(mountCmd.Args, "--debug")
 	}
 
+	if *fMaxProtocolMinor != 0 {
+		mountCmd.Args = append(
+			mountCmd.Args,
+			fmt.Sprintf("--max_protocol_minor=%d", *fMaxProtocolMinor))
+	}
+
 	// Start the command.
 	if err := mountCmd.Start(); err != nil {
 		return fmt.Errorf("mountCmd.Start: %v", err)
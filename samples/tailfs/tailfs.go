@@ -0,0 +1,212 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailfs
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+const (
+	// The name of the single file in the root directory.
+	LogFilename = "log"
+
+	logInode = fuseops.RootInodeID + 1
+)
+
+// Log is the server side of the file served by a file system created with
+// NewTailFS.
+type Log struct {
+	fs *tailFS
+}
+
+// Append data to the end of the log file, and push it to the kernel so that
+// readers of the mounted file, such as `tail -f`, see it without the file
+// system having to use direct IO.
+//
+// REQUIRES: The file system has been mounted.
+func (l *Log) Append(data []byte) error {
+	return l.fs.append(data)
+}
+
+// Create a file system containing a single read-only file named "log", to
+// which the server appends using the returned Log.
+//
+// The kernel is told that the file's attributes and contents never expire, so
+// that readers are served from the page cache. Appends are pushed into that
+// cache with Notifier.Store, which also extends the file size seen by the
+// kernel, and the attributes are then invalidated so that the new mtime is
+// picked up. This is the intended pattern for files that grow on the server
+// side: unlike direct IO, readers still benefit from caching, and unlike
+// invalidating the contents, no round trip is needed to read the new data.
+func NewTailFS() (fuse.Server, *Log) {
+	n := fuse.NewNotifier()
+	fs := &tailFS{
+		notifier: n,
+		mtime:    time.Now(),
+	}
+
+	server := fuse.NewServerWithNotifier(n, fuseutil.NewFileSystemServer(fs))
+	return server, &Log{fs}
+}
+
+type tailFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	notifier *fuse.Notifier
+
+	mu sync.Mutex
+
+	// GUARDED_BY(mu)
+	contents []byte
+	mtime    time.Time
+}
+
+func (fs *tailFS) append(data []byte) error {
+	fs.mu.Lock()
+	offset := int64(len(fs.contents))
+	fs.contents = append(fs.contents, data...)
+	fs.mtime = time.Now()
+	fs.mu.Unlock()
+
+	// ENOENT means the kernel doesn't know about the inode yet, in which case it
+	// will see the new contents when it first looks it up.
+	err := fs.notifier.Store(logInode, offset, data)
+	if err != nil && err != syscall.ENOENT {
+		return err
+	}
+
+	// Invalidate only the attributes (a negative offset leaves the page cache
+	// alone).
+	err = fs.notifier.InvalidateInode(logInode, -1, 0)
+	if err != nil && err != syscall.ENOENT {
+		return err
+	}
+
+	return nil
+}
+
+func (fs *tailFS) fillAttributes(
+	inode fuseops.InodeID,
+	attrs *fuseops.InodeAttributes) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	switch inode {
+	case fuseops.RootInodeID:
+		attrs.Nlink = 1
+		attrs.Mode = 0555 | os.ModeDir
+
+	case logInode:
+		attrs.Nlink = 1
+		attrs.Mode = 0444
+		attrs.Size = uint64(len(fs.contents))
+		attrs.Mtime = fs.mtime
+
+	default:
+		return fuse.ENOENT
+	}
+
+	return nil
+}
+
+func (fs *tailFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != LogFilename {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = logInode
+	distantFuture := time.Now().Add(time.Hour * 300)
+	op.Entry.AttributesExpiration = distantFuture
+	op.Entry.EntryExpiration = distantFuture
+
+	return fs.fillAttributes(logInode, &op.Entry.Attributes)
+}
+
+func (fs *tailFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	op.AttributesExpiration = time.Now().Add(time.Hour * 300)
+	return fs.fillAttributes(op.Inode, &op.Attributes)
+}
+
+func (fs *tailFS) OpenDir(
+	ctx context.Context,
+	op *fuseops.OpenDirOp) error {
+	if op.Inode != fuseops.RootInodeID {
+		return fuse.ENOTDIR
+	}
+
+	return nil
+}
+
+func (fs *tailFS) ReadDir(
+	ctx context.Context,
+	op *fuseops.ReadDirOp) error {
+	if op.Inode != fuseops.RootInodeID {
+		return fuse.ENOTDIR
+	}
+
+	if op.Offset == 0 {
+		op.BytesRead = fuseutil.WriteDirent(op.Dst, fuseutil.Dirent{
+			Offset: 1,
+			Inode:  logInode,
+			Name:   LogFilename,
+			Type:   fuseutil.DT_File,
+		})
+	}
+
+	return nil
+}
+
+func (fs *tailFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	if op.Inode != logInode {
+		return syscall.EISDIR
+	}
+
+	if !op.OpenFlags.IsReadOnly() {
+		return syscall.EACCES
+	}
+
+	// The page cache is kept up to date with Store, so it remains valid across
+	// opens.
+	op.KeepPageCache = true
+
+	return nil
+}
+
+func (fs *tailFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Offset < int64(len(fs.contents)) {
+		op.BytesRead = copy(op.Dst, fs.contents[op.Offset:])
+	}
+
+	return nil
+}
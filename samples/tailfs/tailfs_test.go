@@ -0,0 +1,70 @@
+package tailfs_test
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/jacobsa/fuse/samples"
+	"github.com/jacobsa/fuse/samples/tailfs"
+
+	. "github.com/jacobsa/ogletest"
+)
+
+func TestMain(m *testing.M) { samples.Main(m) }
+
+func TestTailFS(t *testing.T) { RunTests(t) }
+
+func init() {
+	RegisterTestSuite(&TailFSTest{})
+}
+
+type TailFSTest struct {
+	samples.SampleTest
+
+	log *tailfs.Log
+}
+
+func (t *TailFSTest) SetUp(ti *TestInfo) {
+	t.Server, t.log = tailfs.NewTailFS()
+	t.SampleTest.SetUp(ti)
+}
+
+func (t *TailFSTest) AppendBeforeLookUp() {
+	err := t.log.Append([]byte("taco\n"))
+	AssertEq(nil, err)
+
+	contents, err := os.ReadFile(path.Join(t.Dir, tailfs.LogFilename))
+	AssertEq(nil, err)
+	ExpectEq("taco\n", string(contents))
+}
+
+func (t *TailFSTest) FollowAppends() {
+	f, err := os.Open(path.Join(t.Dir, tailfs.LogFilename))
+	AssertEq(nil, err)
+	t.ToClose = append(t.ToClose, f)
+
+	// The file starts out empty.
+	buf := make([]byte, 64)
+	_, err = f.Read(buf)
+	ExpectEq(io.EOF, err)
+
+	// Like tail -f, keep reading from where we left off as the log grows.
+	for _, line := range []string{"taco\n", "burrito\n", "enchilada\n"} {
+		err = t.log.Append([]byte(line))
+		AssertEq(nil, err)
+
+		n, err := f.Read(buf)
+		AssertEq(nil, err)
+		ExpectEq(line, string(buf[:n]))
+
+		_, err = f.Read(buf)
+		ExpectEq(io.EOF, err)
+	}
+
+	// The size seen by stat has grown too.
+	fi, err := f.Stat()
+	AssertEq(nil, err)
+	ExpectEq(len("taco\nburrito\nenchilada\n"), fi.Size())
+}
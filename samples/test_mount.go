@@ -0,0 +1,116 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package samples
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+)
+
+// MountForTest mounts the server for the duration of a standard Go test,
+// returning the mount point. Unlike SampleTest, which is driven by ogletest,
+// it is safe to call from tests and subtests that call t.Parallel, so that
+// large suites can run concurrently:
+//
+//   - Each call mounts at a new directory with a unique name.
+//
+//   - With the -debug flag, the file system's debug log is captured
+//     separately for each test and added to the test's output, rather than
+//     being interleaved with that of other tests on stderr.
+//
+//   - Unmounting, when the test and its subtests have completed, gives up
+//     after a bounded number of retries of "resource busy" errors, failing the
+//     test rather than hanging it.
+//
+// The test fails immediately if the file system can't be mounted.
+func MountForTest(
+	tb testing.TB,
+	server fuse.Server,
+	config fuse.MountConfig) string {
+	tb.Helper()
+
+	var debugLog *testLog
+	if *fDebug && config.DebugLogger == nil {
+		debugLog = &testLog{}
+		config.DebugLogger = log.New(debugLog, "fuse: ", log.Lmicroseconds)
+	}
+
+	if *fMaxProtocolMinor != 0 && config.MaxProtocolMinor == 0 {
+		config.MaxProtocolMinor = uint32(*fMaxProtocolMinor)
+	}
+
+	dir, err := os.MkdirTemp("", "sample_test")
+	if err != nil {
+		tb.Fatalf("MkdirTemp: %v", err)
+	}
+
+	mfs, err := fuse.Mount(dir, server, &config)
+	if err != nil {
+		os.Remove(dir)
+		tb.Fatalf("Mount: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		defer debugLog.flush(tb)
+
+		// Leave the mount point alone if it is still mounted.
+		if err := unmount(dir); err != nil {
+			tb.Errorf("unmount: %v", err)
+			return
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			tb.Errorf("Join: %v", err)
+		}
+
+		if err := os.Remove(dir); err != nil {
+			tb.Errorf("Unlinking mount point: %v", err)
+		}
+	})
+
+	return dir
+}
+
+// A debug log captured for a single test.
+type testLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer // GUARDED_BY(mu)
+}
+
+func (l *testLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// Add the log, if any, to the test's output.
+func (l *testLog) flush(tb testing.TB) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buf.Len() != 0 {
+		tb.Logf("debug log:\n%s", l.buf.String())
+		l.buf.Reset()
+	}
+}
@@ -17,25 +17,38 @@ package samples
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/jacobsa/fuse"
 )
 
+// The number of times unmount tries to unmount a busy file system, over about
+// six seconds in total.
+const maxUnmountAttempts = 20
+
 // Unmount the file system mounted at the supplied directory. Try again on
 // "resource busy" errors, which happen from time to time on OS X (due to weird
 // requests from the Finder) and when tests don't or can't synchronize all
-// events.
+// events, up to maxUnmountAttempts times.
 func unmount(dir string) error {
 	delay := 10 * time.Millisecond
-	for {
-		err := fuse.Unmount(dir)
+	for attempt := 1; ; attempt++ {
+		var err error
+		if os.Getenv(userNamespaceEnv) != "" {
+			// We are root in the mount namespace, and fusermount(1) may not be
+			// installed.
+			err = unmountDirectly(dir)
+		} else {
+			err = fuse.Unmount(dir)
+		}
+
 		if err == nil {
 			return err
 		}
 
-		if strings.Contains(err.Error(), "resource busy") {
+		if strings.Contains(err.Error(), "resource busy") && attempt < maxUnmountAttempts {
 			log.Println("Resource busy error while unmounting; trying again")
 			time.Sleep(delay)
 			delay = time.Duration(1.3 * float64(delay))
@@ -0,0 +1,55 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package samples
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+var fUserNamespace = flag.Bool(
+	"userns",
+	false,
+	"If true, run the tests in a new user and mount namespace in which the "+
+		"current user is root, so that they can mount file systems without "+
+		"privileges or fusermount(1), e.g. in a container. Linux only.")
+
+// Set in the environment of the test binary when it is run again in a user
+// namespace.
+const userNamespaceEnv = "JACOBSA_FUSE_SAMPLES_USERNS"
+
+// Main runs the tests of a package that mounts file systems, e.g. using
+// SampleTest or SubprocessTest, for use as its TestMain function:
+//
+//	func TestMain(m *testing.M) { samples.Main(m) }
+//
+// If the -userns flag is set, the test binary is run again in a new user and
+// mount namespace in which the current user is root, and in which the test
+// file systems are mounted and unmounted directly with mount(2) and
+// umount(2), without needing fusermount(1). Their mounts are then
+// invisible outside the namespace, and vanish with it even if the tests crash.
+// This needs Linux 4.18 or later, read and write access to /dev/fuse, and
+// unprivileged user namespaces to be enabled, which they are in most
+// container runtimes' default configurations. The tests run as root, so
+// those expecting permission checks to fail for ordinary users may fail.
+func Main(m *testing.M) {
+	flag.Parse()
+	if *fUserNamespace && os.Getenv(userNamespaceEnv) == "" {
+		os.Exit(runInUserNamespace())
+	}
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package samples
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func runInUserNamespace() int {
+	fmt.Fprintln(os.Stderr, "-userns is only supported on Linux")
+	return 2
+}
+
+func unmountDirectly(dir string) error {
+	return errors.New("unmounting directly is only supported on Linux")
+}
@@ -0,0 +1,193 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system with a single file "f" that can be replaced by a new inode
+// behind the kernel's back, as if by another client of a network file system.
+// The kernel is told to cache everything for an hour.
+type staleFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	mu       sync.Mutex
+	current  fuseops.InodeID // GUARDED_BY(mu)
+	contents string          // GUARDED_BY(mu)
+}
+
+func (fs *staleFS) replace(contents string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.current++
+	fs.contents = contents
+}
+
+// Return an error if the inode isn't the current one.
+//
+// LOCKS_REQUIRED(fs.mu)
+func (fs *staleFS) check(inode fuseops.InodeID) error {
+	if inode != fs.current {
+		return &fuse.StaleError{Inode: inode, Parent: fuseops.RootInodeID, Name: "f"}
+	}
+
+	return nil
+}
+
+func (fs *staleFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: os.ModeDir | 0555}
+	}
+
+	return fuseops.InodeAttributes{
+		Nlink: 1,
+		Mode:  0444,
+		Size:  uint64(len(fs.contents)),
+	}
+}
+
+func (fs *staleFS) StatFS(ctx context.Context, op *fuseops.StatFSOp) error {
+	return nil
+}
+
+func (fs *staleFS) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Parent != fuseops.RootInodeID || op.Name != "f" {
+		return fuse.ENOENT
+	}
+
+	expiration := time.Now().Add(time.Hour)
+	op.Entry = fuseops.ChildInodeEntry{
+		Child:                fs.current,
+		Attributes:           fs.attributes(fs.current),
+		AttributesExpiration: expiration,
+		EntryExpiration:      expiration,
+	}
+
+	return nil
+}
+
+func (fs *staleFS) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if op.Inode != fuseops.RootInodeID {
+		if err := fs.check(op.Inode); err != nil {
+			return err
+		}
+	}
+
+	op.Attributes = fs.attributes(op.Inode)
+	op.AttributesExpiration = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (fs *staleFS) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.UseDirectIO = true
+	return fs.check(op.Inode)
+}
+
+func (fs *staleFS) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.check(op.Inode); err != nil {
+		return err
+	}
+
+	if op.Offset < int64(len(fs.contents)) {
+		op.BytesRead = copy(op.Dst, fs.contents[op.Offset:])
+	}
+
+	return nil
+}
+
+func TestStaleErrorInvalidatesKernelCache(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "stale_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fs := &staleFS{current: fuseops.RootInodeID}
+	fs.replace("taco")
+
+	// Mount read-only, so that reading doesn't invalidate the cached atime and
+	// thereby cause stat to fetch the attributes again anyway.
+	mfs, err := fuse.Mount(
+		dir,
+		fuseutil.NewFileSystemServer(fs),
+		&fuse.MountConfig{ReadOnly: true})
+
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	defer func() {
+		if err := mfs.Join(ctx); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	}()
+
+	defer fuse.Unmount(mfs.Dir())
+
+	p := path.Join(dir, "f")
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	defer f.Close()
+
+	// Replace the file. Reading through the open handle fails, but the
+	// kernel's cached entry and attributes still refer to the old inode.
+	fs.replace("burrito")
+
+	_, err = f.ReadAt(make([]byte, 16), 0)
+	if !errors.Is(err, syscall.ESTALE) {
+		t.Fatalf("ReadAt: got %v, want ESTALE", err)
+	}
+
+	// The error invalidates them, without waiting for them to expire.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+
+		if fi.Size() == int64(len("burrito")) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Stat still reports the old size %d", fi.Size())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	contents, err := ioutil.ReadFile(p)
+	if string(contents) != "burrito" || err != nil {
+		t.Errorf("ReadFile: %q, %v", contents, err)
+	}
+}
@@ -0,0 +1,147 @@
+package fuse_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system containing a file "foo" that is opened with direct IO and the
+// supplied flags, whose reads each return "taco", and which records the
+// offsets of reads.
+type streamFS struct {
+	fuseutil.NotImplementedFileSystem
+	nonSeekable bool
+	stream      bool
+
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (fs *streamFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	if op.Inode == fuseops.RootInodeID {
+		op.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0555 | os.ModeDir}
+	} else {
+		op.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0444}
+	}
+
+	return nil
+}
+
+func (fs *streamFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "foo" {
+		return fuse.ENOENT
+	}
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fuseops.InodeAttributes{Nlink: 1, Mode: 0444}
+	return nil
+}
+
+func (fs *streamFS) OpenFile(
+	ctx context.Context,
+	op *fuseops.OpenFileOp) error {
+	op.UseDirectIO = true
+	op.NonSeekable = fs.nonSeekable
+	op.Stream = fs.stream
+	return nil
+}
+
+func (fs *streamFS) ReadFile(
+	ctx context.Context,
+	op *fuseops.ReadFileOp) error {
+	fs.mu.Lock()
+	fs.offsets = append(fs.offsets, op.Offset)
+	fs.mu.Unlock()
+
+	op.BytesRead = copy(op.Dst, "taco")
+	return nil
+}
+
+func (fs *streamFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp) error {
+	return nil
+}
+
+// Mount the file system, open its file, and read from it twice.
+func readStream(t *testing.T, fs *streamFS) *os.File {
+	mfs, err := fuse.Mount(t.TempDir(), fuseutil.NewFileSystemServer(fs), &fuse.MountConfig{})
+	if err != nil {
+		t.Fatalf("fuse.Mount: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := fuse.Unmount(mfs.Dir()); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+
+		if err := mfs.Join(context.Background()); err != nil {
+			t.Errorf("Joining: %v", err)
+		}
+	})
+
+	f, err := os.Open(path.Join(mfs.Dir(), "foo"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	buf := make([]byte, 4)
+	for range 2 {
+		if _, err := io.ReadFull(f, buf); err != nil || string(buf) != "taco" {
+			t.Fatalf("Read: got (%q, %v)", buf, err)
+		}
+	}
+
+	return f
+}
+
+func TestNonSeekable(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fs      *streamFS
+		offsets []int64
+	}{
+		{"NonSeekable", &streamFS{nonSeekable: true}, []int64{0, 4}},
+		{"Stream", &streamFS{stream: true}, []int64{0, 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := readStream(t, tc.fs)
+
+			tc.fs.mu.Lock()
+			if !slices.Equal(tc.fs.offsets, tc.offsets) {
+				t.Errorf("Read offsets: got %v, want %v", tc.fs.offsets, tc.offsets)
+			}
+			tc.fs.mu.Unlock()
+
+			if _, err := f.Seek(0, io.SeekStart); !errors.Is(err, syscall.ESPIPE) {
+				t.Errorf("Seek: got %v, want ESPIPE", err)
+			}
+
+			if _, err := f.ReadAt(make([]byte, 4), 0); !errors.Is(err, syscall.ESPIPE) {
+				t.Errorf("ReadAt: got %v, want ESPIPE", err)
+			}
+		})
+	}
+
+	// By default, handles can be repositioned.
+	f := readStream(t, &streamFS{})
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Errorf("Seek: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+// Check the fields that the file system filled in for a successful op,
+// returning an error describing the first problem found. Used when
+// MountConfig.StrictReplies is set.
+func validateReply(op interface{}) error {
+	switch o := op.(type) {
+	case *fuseops.LookUpInodeOp:
+		// A zero child with an entry expiration is a negative entry, telling the
+		// kernel to cache the name's absence.
+		if o.Entry.Child == 0 && o.Entry.EntryExpiration.IsZero() {
+			return fmt.Errorf("Entry.Child not set")
+		}
+
+		// The kernel fails opens by file handle with EIO otherwise.
+		if o.Name == "." && o.Entry.Child != 0 && o.Entry.Child != o.Parent {
+			return fmt.Errorf("Entry.Child %v for \".\" is not Parent %v", o.Entry.Child, o.Parent)
+		}
+
+		if o.Entry.Child != 0 {
+			return validateAttributes(&o.Entry.Attributes)
+		}
+
+	case *fuseops.GetInodeAttributesOp:
+		return validateAttributes(&o.Attributes)
+
+	case *fuseops.SetInodeAttributesOp:
+		return validateAttributes(&o.Attributes)
+
+	case *fuseops.MkDirOp:
+		return validateCreatedEntry(&o.Entry, os.ModeDir)
+
+	case *fuseops.MkNodeOp:
+		return validateCreatedEntry(&o.Entry, o.Mode.Type())
+
+	case *fuseops.OpenFileOp:
+		return validateBackingID(o.BackingID, o.UseDirectIO)
+
+	case *fuseops.CreateFileOp:
+		return validateCreatedEntry(&o.Entry, 0)
+
+	case *fuseops.CreateTmpfileOp:
+		return validateCreatedEntry(&o.Entry, 0)
+
+	case *fuseops.CreateSymlinkOp:
+		return validateCreatedEntry(&o.Entry, os.ModeSymlink)
+
+	case *fuseops.CreateLinkOp:
+		if err := validateChildEntry(&o.Entry); err != nil {
+			return err
+		}
+
+		if o.Entry.Child != o.Target {
+			return fmt.Errorf("Entry.Child %v is not Target %v", o.Entry.Child, o.Target)
+		}
+
+	case *fuseops.ReadFileOp:
+		if o.Data != nil {
+			var n int64
+			for _, b := range o.Data {
+				n += int64(len(b))
+			}
+
+			if n > o.Size {
+				return fmt.Errorf("Data holds %d bytes, exceeding Size %d", n, o.Size)
+			}
+
+			return nil
+		}
+
+		if err := validateBytesRead(o.BytesRead, len(o.Dst)); err != nil {
+			return err
+		}
+
+		if int64(o.BytesRead) > o.Size {
+			return fmt.Errorf("BytesRead %d exceeds Size %d", o.BytesRead, o.Size)
+		}
+
+	case *fuseops.ReadDirOp:
+		if err := validateBytesRead(o.BytesRead, len(o.Dst)); err != nil {
+			return err
+		}
+
+		return validateDirents(o.Dst[:o.BytesRead], 0)
+
+	case *fuseops.ReadDirPlusOp:
+		if err := validateBytesRead(o.BytesRead, len(o.Dst)); err != nil {
+			return err
+		}
+
+		return validateDirents(
+			o.Dst[:o.BytesRead],
+			int(unsafe.Sizeof(fusekernel.EntryOut{})))
+
+	case *fuseops.ReadSymlinkOp:
+		if o.Target == "" {
+			return fmt.Errorf("Target not set")
+		}
+
+	case *fuseops.GetXattrOp:
+		// An empty buffer is a request for the size of the value.
+		if len(o.Dst) != 0 {
+			return validateBytesRead(o.BytesRead, len(o.Dst))
+		}
+
+	case *fuseops.ListXattrOp:
+		if len(o.Dst) != 0 {
+			return validateBytesRead(o.BytesRead, len(o.Dst))
+		}
+
+	case *fuseops.IoctlOp:
+		return validateIoctl(o)
+
+	case *fuseops.CopyFileRangeOp:
+		// The kernel takes the reply to mean that more was copied than asked.
+		if o.BytesCopied > o.Length || o.BytesCopied > math.MaxUint32 {
+			return fmt.Errorf("BytesCopied %d out of range for Length %d", o.BytesCopied, o.Length)
+		}
+
+	case *fuseops.LseekOp:
+		if o.NewOffset < 0 {
+			return fmt.Errorf("negative NewOffset %d", o.NewOffset)
+		}
+	}
+
+	return nil
+}
+
+// The kernel refuses to open a passthrough file with direct I/O.
+func validateBackingID(id fuseops.BackingID, directIO bool) error {
+	if id != 0 && directIO {
+		return fmt.Errorf("BackingID set with UseDirectIO")
+	}
+
+	return nil
+}
+
+// Check that a write covers whole blocks of the given size. Used when
+// MountConfig.WriteAlignment is set.
+func validateWriteAlignment(op *fuseops.WriteFileOp, align uint32) error {
+	a := int64(align)
+	if op.Offset%a != 0 || int64(len(op.Data))%a != 0 {
+		return fmt.Errorf(
+			"write of %d bytes at offset %d not aligned to %d bytes: %w",
+			len(op.Data),
+			op.Offset,
+			align,
+			syscall.EINVAL)
+	}
+
+	return nil
+}
+
+func validateChildEntry(e *fuseops.ChildInodeEntry) error {
+	switch e.Child {
+	case 0:
+		return fmt.Errorf("Entry.Child not set")
+
+	case fuseops.RootInodeID:
+		return fmt.Errorf("Entry.Child is the root inode")
+	}
+
+	return validateAttributes(&e.Attributes)
+}
+
+// Check the entry for an inode created with the given type. The kernel fails
+// the op with EIO unless the attributes it receives are of the same type.
+func validateCreatedEntry(e *fuseops.ChildInodeEntry, typ os.FileMode) error {
+	if err := validateChildEntry(e); err != nil {
+		return err
+	}
+
+	got := ConvertGoMode(e.Attributes.Mode) & syscall.S_IFMT
+	if want := ConvertGoMode(typ) & syscall.S_IFMT; got != want {
+		return fmt.Errorf("Entry.Attributes.Mode %v is not of type %v", e.Attributes.Mode, typ)
+	}
+
+	return nil
+}
+
+// The kernel treats attributes with a size it can't represent as invalid.
+func validateAttributes(a *fuseops.InodeAttributes) error {
+	if a.Size > math.MaxInt64 {
+		return fmt.Errorf("Attributes.Size %d out of range", a.Size)
+	}
+
+	return nil
+}
+
+func validateIoctl(o *fuseops.IoctlOp) error {
+	if len(o.RetryInput) == 0 && len(o.RetryOutput) == 0 {
+		if o.Result < 0 {
+			return fmt.Errorf("negative Result %d", o.Result)
+		}
+
+		// Output beyond OutputSize would otherwise be dropped.
+		if len(o.Output) > o.OutputSize {
+			return fmt.Errorf("Output holds %d bytes, exceeding OutputSize %d", len(o.Output), o.OutputSize)
+		}
+
+		return nil
+	}
+
+	// The kernel fails a retry with EIO otherwise.
+	if !o.Unrestricted {
+		return fmt.Errorf("RetryInput or RetryOutput set for a restricted ioctl")
+	}
+
+	if n := len(o.RetryInput) + len(o.RetryOutput); n > 256 {
+		return fmt.Errorf("%d retry regions, exceeding 256", n)
+	}
+
+	return nil
+}
+
+func validateBytesRead(n int, size int) error {
+	if n < 0 || n > size {
+		return fmt.Errorf("BytesRead %d out of range for buffer of size %d", n, size)
+	}
+
+	return nil
+}
+
+// Walk a buffer of fuse_dirent structures, each preceded by a header of the
+// given size (for fuse_direntplus), checking that they are well formed.
+func validateDirents(buf []byte, header int) error {
+	const align = 8
+	for len(buf) != 0 {
+		if len(buf) < header+fusekernel.DirentSize {
+			return fmt.Errorf("truncated dirent")
+		}
+
+		d := buf[header:]
+		namelen := int(binary.NativeEndian.Uint32(d[16:]))
+		if namelen == 0 || namelen > 255 {
+			return fmt.Errorf("dirent name length %d out of range", namelen)
+		}
+
+		size := header + fusekernel.DirentSize + namelen
+		if len(buf) < size {
+			return fmt.Errorf("truncated dirent")
+		}
+
+		name := d[fusekernel.DirentSize : fusekernel.DirentSize+namelen]
+		if bytes.ContainsAny(name, "/\x00") {
+			return fmt.Errorf("invalid dirent name %q", name)
+		}
+
+		// Skip the padding following the entry, which may be missing at the end.
+		size = (size + align - 1) / align * align
+		if size > len(buf) {
+			size = len(buf)
+		}
+
+		buf = buf[size:]
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package fuse
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+func TestWriteAlignment(t *testing.T) {
+	c, peer := newSocketConnection(t, false)
+	c.cfg.WriteAlignment = 4
+
+	write := func(unique uint64, offset uint64, data string) {
+		in := fusekernel.WriteIn{Fh: 7, Offset: offset, Size: uint32(len(data))}
+		payload := (*[unsafe.Sizeof(in)]byte)(unsafe.Pointer(&in))[:]
+		payload = append(payload, data...)
+		if _, err := peer.Write(request(unique, fusekernel.OpWrite, payload)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	// The unaligned write is rejected without being returned, and the aligned
+	// one is returned.
+	write(1, 2, "taco")
+	write(2, 4, "taco")
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if op, ok := op.(*fuseops.WriteFileOp); !ok || op.Offset != 4 {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	var out fusekernel.OutHeader
+	buf := (*[unsafe.Sizeof(out)]byte)(unsafe.Pointer(&out))[:]
+	if _, err := peer.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if out.Unique != 1 || out.Error != -int32(syscall.EINVAL) {
+		t.Errorf("unexpected reply: %+v", out)
+	}
+
+	c.Reply(ctx, nil)
+}
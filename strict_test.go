@@ -0,0 +1,158 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Append a fuse_dirent for the given name to buf, with padding.
+func appendDirent(buf []byte, name string) []byte {
+	buf = binary.NativeEndian.AppendUint64(buf, 17)
+	buf = binary.NativeEndian.AppendUint64(buf, 1)
+	buf = binary.NativeEndian.AppendUint32(buf, uint32(len(name)))
+	buf = binary.NativeEndian.AppendUint32(buf, 0)
+	buf = append(buf, name...)
+	for len(buf)%8 != 0 {
+		buf = append(buf, 0)
+	}
+
+	return buf
+}
+
+func TestValidateReply(t *testing.T) {
+	dirents := appendDirent(appendDirent(nil, "foo"), "burrito")
+	badDirents := appendDirent(nil, "foo/bar")
+	dir := fuseops.InodeAttributes{Mode: os.ModeDir | 0755}
+	huge := fuseops.InodeAttributes{Size: math.MaxUint64}
+
+	testCases := []struct {
+		name  string
+		op    interface{}
+		valid bool
+	}{
+		{"lookup", &fuseops.LookUpInodeOp{Entry: fuseops.ChildInodeEntry{Child: 2}}, true},
+		{"lookup no child", &fuseops.LookUpInodeOp{}, false},
+		{
+			"lookup dot",
+			&fuseops.LookUpInodeOp{Parent: 3, Name: ".", Entry: fuseops.ChildInodeEntry{Child: 3}},
+			true,
+		},
+		{
+			"lookup dot other",
+			&fuseops.LookUpInodeOp{Parent: 3, Name: ".", Entry: fuseops.ChildInodeEntry{Child: 2}},
+			false,
+		},
+		{
+			"negative lookup",
+			&fuseops.LookUpInodeOp{
+				Entry: fuseops.ChildInodeEntry{EntryExpiration: time.Now()},
+			},
+			true,
+		},
+		{"getattr huge size", &fuseops.GetInodeAttributesOp{Attributes: huge}, false},
+		{"mkdir", &fuseops.MkDirOp{Entry: fuseops.ChildInodeEntry{Child: 2, Attributes: dir}}, true},
+		{"mkdir no child", &fuseops.MkDirOp{}, false},
+		{
+			"mkdir dir device",
+			&fuseops.MkDirOp{
+				Entry: fuseops.ChildInodeEntry{Child: 2, Attributes: fuseops.InodeAttributes{Mode: os.ModeDir | os.ModeDevice}},
+			},
+			true,
+		},
+		{"mkdir root", &fuseops.MkDirOp{Entry: fuseops.ChildInodeEntry{Child: 1, Attributes: dir}}, false},
+		{"mkdir file", &fuseops.MkDirOp{Entry: fuseops.ChildInodeEntry{Child: 2}}, false},
+		{"create", &fuseops.CreateFileOp{Entry: fuseops.ChildInodeEntry{Child: 2}}, true},
+		{"create dir", &fuseops.CreateFileOp{Entry: fuseops.ChildInodeEntry{Child: 2, Attributes: dir}}, false},
+		{
+			"mknod fifo",
+			&fuseops.MkNodeOp{
+				Mode:  os.ModeNamedPipe,
+				Entry: fuseops.ChildInodeEntry{Child: 2, Attributes: fuseops.InodeAttributes{Mode: os.ModeNamedPipe}},
+			},
+			true,
+		},
+		{"link", &fuseops.CreateLinkOp{Target: 2, Entry: fuseops.ChildInodeEntry{Child: 2}}, true},
+		{"link other", &fuseops.CreateLinkOp{Target: 2, Entry: fuseops.ChildInodeEntry{Child: 3}}, false},
+		{"read", &fuseops.ReadFileOp{Size: 4, Dst: make([]byte, 4), BytesRead: 4}, true},
+		{"read overflow", &fuseops.ReadFileOp{Size: 4, Dst: make([]byte, 4), BytesRead: 5}, false},
+		{"read data", &fuseops.ReadFileOp{Size: 4, Data: [][]byte{[]byte("taco")}}, true},
+		{"read data overflow", &fuseops.ReadFileOp{Size: 3, Data: [][]byte{[]byte("taco")}}, false},
+		{
+			"readdir",
+			&fuseops.ReadDirOp{Dst: dirents, BytesRead: len(dirents)},
+			true,
+		},
+		{
+			"readdir bad name",
+			&fuseops.ReadDirOp{Dst: badDirents, BytesRead: len(badDirents)},
+			false,
+		},
+		{
+			"readdir truncated",
+			&fuseops.ReadDirOp{Dst: dirents, BytesRead: 10},
+			false,
+		},
+		{"readlink", &fuseops.ReadSymlinkOp{}, false},
+		{"getxattr size", &fuseops.GetXattrOp{BytesRead: 100}, true},
+		{"getxattr overflow", &fuseops.GetXattrOp{Dst: make([]byte, 8), BytesRead: 100}, false},
+		{"ioctl", &fuseops.IoctlOp{OutputSize: 4, Output: []byte("taco")}, true},
+		{"ioctl overflow", &fuseops.IoctlOp{OutputSize: 3, Output: []byte("taco")}, false},
+		{"ioctl negative", &fuseops.IoctlOp{Result: -1}, false},
+		{
+			"ioctl retry",
+			&fuseops.IoctlOp{Unrestricted: true, RetryInput: []fuseops.IoctlRegion{{Len: 4}}},
+			true,
+		},
+		{
+			"ioctl restricted retry",
+			&fuseops.IoctlOp{RetryInput: []fuseops.IoctlRegion{{Len: 4}}},
+			false,
+		},
+		{"copy", &fuseops.CopyFileRangeOp{Length: 4, BytesCopied: 4}, true},
+		{"copy overflow", &fuseops.CopyFileRangeOp{Length: 4, BytesCopied: 5}, false},
+		{"lseek negative", &fuseops.LseekOp{NewOffset: -1}, false},
+	}
+
+	for _, tc := range testCases {
+		err := validateReply(tc.op)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestValidateWriteAlignment(t *testing.T) {
+	testCases := []struct {
+		offset int64
+		size   int
+		valid  bool
+	}{
+		{0, 4096, true},
+		{8192, 8192, true},
+		{512, 4096, false},
+		{4096, 100, false},
+	}
+
+	for _, tc := range testCases {
+		op := &fuseops.WriteFileOp{Offset: tc.offset, Data: make([]byte, tc.size)}
+		err := validateWriteAlignment(op, 4096)
+		if tc.valid && err != nil {
+			t.Errorf("%d bytes at %d: unexpected error: %v", tc.size, tc.offset, err)
+		}
+
+		if !tc.valid && !errors.Is(err, syscall.EINVAL) {
+			t.Errorf("%d bytes at %d: got %v, want EINVAL", tc.size, tc.offset, err)
+		}
+	}
+}
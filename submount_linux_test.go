@@ -0,0 +1,25 @@
+package fuse
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/fusekernel"
+)
+
+func TestSubmountAttribute(t *testing.T) {
+	for _, submount := range []bool{false, true} {
+		in := fuseops.InodeAttributes{
+			Nlink:    1,
+			Mode:     0755 | os.ModeDir,
+			Submount: submount,
+		}
+
+		var out fusekernel.Attr
+		convertAttributes(17, &in, &out)
+		if got := out.Flags&fusekernel.AttrSubmount != 0; got != submount {
+			t.Errorf("Submount %v: got FUSE_ATTR_SUBMOUNT %v", submount, got)
+		}
+	}
+}
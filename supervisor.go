@@ -0,0 +1,218 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SupervisorConfig configures a Supervisor.
+type SupervisorConfig struct {
+	// The causes after which the file system is mounted again. If nil,
+	// ExitAborted and ExitDeviceError.
+	RemountOn []ExitCause
+
+	// The maximum number of consecutive failed attempts to mount the file
+	// system again, after which the supervisor gives up. If zero, 5.
+	MaxAttempts int
+
+	// How long to wait before the first attempt to mount again. Each
+	// consecutive attempt waits twice as long as the previous one, up to
+	// MaxBackoff (if non-zero).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// If non-nil, called before mounting again with the cause of the previous
+	// mount's exit and the error Join returned for it, returning the server for
+	// the new mount. The kernel keeps nothing from the previous mount, so a
+	// server that tracks inodes' lookup counts or open handles must start
+	// afresh, and a file system may want to re-read state from its backend. If
+	// it returns an error, the supervisor gives up. If nil, the original server
+	// is used again.
+	OnRemount func(ctx context.Context, cause ExitCause, err error) (Server, error)
+}
+
+// Supervisor keeps a file system mounted, mounting it again at the same mount
+// point when its connection is lost, e.g. because it was aborted through
+// /sys/fs/fuse/connections, rather than leaving that to an external process
+// manager. Create one with Supervise.
+type Supervisor struct {
+	dir      string
+	server   Server
+	mountCfg *MountConfig
+	cfg      SupervisorConfig
+
+	mu sync.Mutex
+
+	// The current mount, and the number of times the file system has been
+	// mounted again.
+	//
+	// GUARDED_BY(mu)
+	mfs      *MountedFileSystem
+	remounts int
+
+	// The result to return from Join. Not valid until the channel is closed.
+	joinStatus          error
+	joinStatusAvailable chan struct{}
+}
+
+// Supervise mounts the file system as Mount does, returning any error from
+// doing so, and then supervises it as described on Supervisor until it exits
+// for a cause not in cfg.RemountOn, the supervisor gives up, or the context is
+// cancelled. Cancelling the context stops the supervision without unmounting
+// the file system.
+func Supervise(
+	ctx context.Context,
+	dir string,
+	server Server,
+	config *MountConfig,
+	cfg SupervisorConfig) (*Supervisor, error) {
+	if cfg.RemountOn == nil {
+		cfg.RemountOn = []ExitCause{ExitAborted, ExitDeviceError}
+	}
+
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	mfs, err := Mount(dir, server, config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Supervisor{
+		dir:                 dir,
+		server:              server,
+		mountCfg:            config,
+		cfg:                 cfg,
+		mfs:                 mfs,
+		joinStatusAvailable: make(chan struct{}),
+	}
+
+	go s.supervise(ctx, mfs)
+	return s, nil
+}
+
+// Mounted returns the current mount of the file system.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *Supervisor) Mounted() *MountedFileSystem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mfs
+}
+
+// Remounts returns the number of times the file system has been mounted again.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *Supervisor) Remounts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remounts
+}
+
+// Join blocks until the supervisor stops, returning the error that
+// MountedFileSystem.Join returned for the last mount, or the reason the
+// supervisor gave up. May be called multiple times.
+func (s *Supervisor) Join(ctx context.Context) error {
+	select {
+	case <-s.joinStatusAvailable:
+		return s.joinStatus
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Supervisor) supervise(ctx context.Context, mfs *MountedFileSystem) {
+	defer close(s.joinStatusAvailable)
+
+	for {
+		if err := mfs.Join(ctx); ctx.Err() != nil {
+			s.joinStatus = err
+			return
+		}
+
+		cause, err := mfs.Cause()
+		if !slices.Contains(s.cfg.RemountOn, cause) {
+			s.joinStatus = err
+			return
+		}
+
+		mfs, err = s.remount(ctx, cause, err)
+		if err != nil {
+			s.joinStatus = err
+			return
+		}
+	}
+}
+
+// Mount the file system again after it exited with the supplied cause.
+//
+// LOCKS_EXCLUDED(s.mu)
+func (s *Supervisor) remount(
+	ctx context.Context,
+	cause ExitCause,
+	exitErr error) (*MountedFileSystem, error) {
+	// The old mount point fails every access with ENOTCONN until it is
+	// unmounted. It may also be gone already.
+	Unmount(s.dir)
+
+	server := s.server
+	if s.cfg.OnRemount != nil {
+		var err error
+		server, err = s.cfg.OnRemount(ctx, cause, exitErr)
+		if err != nil {
+			return nil, fmt.Errorf("OnRemount: %w", err)
+		}
+	}
+
+	var err error
+	d := s.cfg.Backoff
+	for attempt := 1; attempt <= s.cfg.MaxAttempts; attempt++ {
+		if d != 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			d *= 2
+			if s.cfg.MaxBackoff != 0 && d > s.cfg.MaxBackoff {
+				d = s.cfg.MaxBackoff
+			}
+		}
+
+		var mfs *MountedFileSystem
+		mfs, err = Mount(s.dir, server, s.mountCfg)
+		if err == nil {
+			s.mu.Lock()
+			s.mfs = mfs
+			s.remounts++
+			s.mu.Unlock()
+
+			return mfs, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"mounting again after %v failed %d times: %w",
+		cause,
+		s.cfg.MaxAttempts,
+		err)
+}
@@ -0,0 +1,102 @@
+package fuse_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// Abort the connection of the file system mounted on dir through fusectl,
+// whose entries are named by the minor device number of the mount.
+func abort(t *testing.T, dir string) {
+	mountinfo, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	var minor string
+	for _, line := range strings.Split(string(mountinfo), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == dir {
+			_, minor, _ = strings.Cut(fields[2], ":")
+		}
+	}
+
+	path := fmt.Sprintf("/sys/fs/fuse/connections/%s/abort", minor)
+	if _, err := os.Stat(path); minor == "" || err != nil {
+		t.Skipf("fusectl not available: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("1"), 0); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestSupervisorRemountsAfterAbort(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Cleanup(func() { fuse.Unmount(dir) })
+
+	var causes []fuse.ExitCause
+	s, err := fuse.Supervise(
+		ctx,
+		dir,
+		fuseutil.NewFileSystemServer(&minimalFS{}),
+		&fuse.MountConfig{},
+		fuse.SupervisorConfig{
+			OnRemount: func(ctx context.Context, cause fuse.ExitCause, err error) (fuse.Server, error) {
+				causes = append(causes, cause)
+				return fuseutil.NewFileSystemServer(&minimalFS{}), nil
+			},
+		})
+
+	if err != nil {
+		t.Fatalf("Supervise: %v", err)
+	}
+
+	first := s.Mounted()
+	abort(t, dir)
+
+	// Wait for the file system to be mounted again.
+	deadline := time.Now().Add(10 * time.Second)
+	for s.Remounts() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if s.Remounts() != 1 {
+		t.Fatalf("Remounts: got %d, want 1", s.Remounts())
+	}
+
+	if cause, _ := first.Cause(); cause != fuse.ExitAborted {
+		t.Errorf("Cause: got %v, want %v", cause, fuse.ExitAborted)
+	}
+
+	if len(causes) != 1 || causes[0] != fuse.ExitAborted {
+		t.Errorf("OnRemount causes: %v", causes)
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		t.Errorf("Statfs: %v", err)
+	}
+
+	// Unmounting stops the supervisor.
+	if err := fuse.Unmount(dir); err != nil {
+		t.Fatalf("Unmount: %v", err)
+	}
+
+	if err := s.Join(ctx); err != nil {
+		t.Errorf("Join: %v", err)
+	}
+
+	if cause, _ := s.Mounted().Cause(); cause != fuse.ExitUnmounted {
+		t.Errorf("Cause: got %v, want %v", cause, fuse.ExitUnmounted)
+	}
+}
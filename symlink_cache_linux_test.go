@@ -0,0 +1,143 @@
+package fuse_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// A file system containing a symlink "link" to target, which counts the
+// ReadSymlinkOps it receives.
+type symlinkFS struct {
+	fuseutil.NotImplementedFileSystem
+
+	mu     sync.Mutex
+	target string
+	reads  int
+}
+
+// LOCKS_REQUIRED(fs.mu)
+func (fs *symlinkFS) attributes(inode fuseops.InodeID) fuseops.InodeAttributes {
+	if inode == fuseops.RootInodeID {
+		return fuseops.InodeAttributes{Nlink: 1, Mode: 0555 | os.ModeDir}
+	}
+
+	return fuseops.InodeAttributes{
+		Nlink: 1,
+		Mode:  0777 | os.ModeSymlink,
+		Size:  uint64(len(fs.target)),
+	}
+}
+
+func (fs *symlinkFS) GetInodeAttributes(
+	ctx context.Context,
+	op *fuseops.GetInodeAttributesOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.Attributes = fs.attributes(op.Inode)
+	op.AttributesExpiration = time.Now().Add(time.Hour)
+	return nil
+}
+
+func (fs *symlinkFS) LookUpInode(
+	ctx context.Context,
+	op *fuseops.LookUpInodeOp) error {
+	if op.Parent != fuseops.RootInodeID || op.Name != "link" {
+		return fuse.ENOENT
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	op.Entry.Child = fuseops.RootInodeID + 1
+	op.Entry.Attributes = fs.attributes(op.Entry.Child)
+	op.Entry.AttributesExpiration = time.Now().Add(time.Hour)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	return nil
+}
+
+func (fs *symlinkFS) ReadSymlink(
+	ctx context.Context,
+	op *fuseops.ReadSymlinkOp) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.reads++
+	op.Target = fs.target
+	return nil
+}
+
+func TestSymlinkCaching(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		fs := &symlinkFS{target: "taco"}
+		n := fuse.NewNotifier()
+		mfs, err := fuse.Mount(
+			t.TempDir(),
+			fuse.NewServerWithNotifier(n, fuseutil.NewFileSystemServer(fs)),
+			&fuse.MountConfig{EnableSymlinkCaching: enabled})
+
+		if err != nil {
+			t.Fatalf("fuse.Mount: %v", err)
+		}
+
+		t.Cleanup(func() {
+			if err := fuse.Unmount(mfs.Dir()); err != nil {
+				t.Errorf("Unmount: %v", err)
+			}
+
+			if err := mfs.Join(context.Background()); err != nil {
+				t.Errorf("Joining: %v", err)
+			}
+		})
+
+		// Read the link's target twice, returning the target read and the number
+		// of ReadSymlinkOps received.
+		p := path.Join(mfs.Dir(), "link")
+		readTwice := func() (string, int) {
+			var target string
+			for range 2 {
+				if target, err = os.Readlink(p); err != nil {
+					t.Fatalf("Readlink: %v", err)
+				}
+			}
+
+			fs.mu.Lock()
+			defer fs.mu.Unlock()
+
+			reads := fs.reads
+			fs.reads = 0
+			return target, reads
+		}
+
+		want := 2
+		if enabled {
+			want = 1
+		}
+
+		if target, reads := readTwice(); target != "taco" || reads != want {
+			t.Errorf("enabled=%v: got %q after %d ReadSymlinkOps, want %q after %d", enabled, target, reads, "taco", want)
+		}
+
+		// A cached target is dropped by invalidating the inode, along with its
+		// attributes, so that the new target isn't truncated to the old size.
+		fs.mu.Lock()
+		fs.target = "enchilada"
+		fs.mu.Unlock()
+
+		if err := n.InvalidateInode(fuseops.RootInodeID+1, 0, 0); err != nil {
+			t.Fatalf("InvalidateInode: %v", err)
+		}
+
+		if target, reads := readTwice(); target != "enchilada" || reads != want {
+			t.Errorf("enabled=%v: got %q after %d ReadSymlinkOps, want %q after %d", enabled, target, reads, "enchilada", want)
+		}
+	}
+}
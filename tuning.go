@@ -0,0 +1,109 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+// Tuning controls the concurrency and memory used to serve a file system.
+// Fields left zero in MountConfig.Tuning keep the defaults: one reader, no
+// limit on the ops handled at once or the buffers kept, and the kernel's own
+// limits on background requests. If Auto is set, they are instead chosen based
+// on the number of CPUs available to the process, i.e. runtime.GOMAXPROCS. See
+// Connection.Tuning for the values in effect.
+type Tuning struct {
+	// Choose the fields left zero based on the number of CPUs.
+	Auto bool
+
+	// The number of goroutines that read requests from the kernel, in servers
+	// that support reading concurrently, such as those created by
+	// fuseutil.NewFileSystemServer. More than one helps only when requests
+	// arrive faster than a single goroutine can decode them. With more than
+	// one, ops that such servers handle as they are read, e.g. ForgetInodeOp,
+	// may be handled concurrently.
+	Readers int
+
+	// The maximum number of ops that such servers handle concurrently. Further
+	// ops are read, and interrupted if the kernel says so, but wait for an
+	// earlier one to finish before being handled. A file system with ops that
+	// block until another op arrives, e.g. SetLkWOp or reads waiting for a
+	// write, must allow enough of them for the op that unblocks them.
+	Workers int
+
+	// The number of request and reply buffers of each kind kept for reuse once
+	// their ops have been replied to. Buffers beyond this are left to the
+	// garbage collector, bounding the memory retained after a burst of
+	// requests. If zero and Workers is set, enough for every op that can be in
+	// flight at once.
+	Buffers int
+
+	// The maximum number of background requests, such as readahead and
+	// writeback, that the kernel will have outstanding, and the number of them
+	// above which it considers the file system congested. The kernel may lower
+	// MaxBackground for mounts by unprivileged users; see max_user_bgreq in
+	// fuse(4)'s documentation. If CongestionThreshold is zero and
+	// MaxBackground is set, three quarters of it.
+	MaxBackground       uint16
+	CongestionThreshold uint16
+}
+
+// Fill in the fields of t that are zero, choosing values suited to the
+// supplied number of CPUs if t.Auto is set.
+func autotune(t Tuning, procs int) Tuning {
+	if procs < 1 {
+		procs = 1
+	}
+
+	if t.Auto {
+		// The kernel's own default is 12, which is too few for a file system
+		// whose backend can serve many reads in parallel.
+		if t.MaxBackground == 0 {
+			t.MaxBackground = uint16(min(max(16*procs, 12), 1024))
+		}
+
+		// Decoding a request is cheap compared to handling it, so a few readers
+		// suffice even on large machines.
+		if t.Readers == 0 {
+			t.Readers = min(max(procs/4, 1), 8)
+		}
+
+		// Background requests are bounded by MaxBackground, and synchronous ones
+		// by the number of threads in other processes using the file system.
+		// Leave plenty of room for the latter, since ops that block for long
+		// periods would otherwise starve the rest.
+		if t.Workers == 0 {
+			t.Workers = int(t.MaxBackground) + 64*procs
+		}
+	}
+
+	// The kernel's default threshold is three quarters of its default limit.
+	if t.MaxBackground != 0 && t.CongestionThreshold == 0 {
+		t.CongestionThreshold = t.MaxBackground / 4 * 3
+	}
+
+	if t.Readers == 0 {
+		t.Readers = 1
+	}
+
+	// Enough buffers for every op that can be in flight at once.
+	if t.Workers != 0 && t.Buffers == 0 {
+		t.Buffers = t.Readers + t.Workers
+	}
+
+	return t
+}
+
+// Tuning returns the tuning in effect for the connection, i.e. that of
+// MountConfig.Tuning with fields left zero filled in automatically.
+func (c *Connection) Tuning() Tuning {
+	return c.tuning
+}
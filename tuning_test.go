@@ -0,0 +1,60 @@
+package fuse
+
+import "testing"
+
+func TestAutotune(t *testing.T) {
+	// By default, nothing is bounded and the kernel's limits are kept.
+	got := autotune(Tuning{}, 4)
+	if want := (Tuning{Readers: 1}); got != want {
+		t.Errorf("defaults: got %+v, want %+v", got, want)
+	}
+
+	// Fields that are set are kept, and the others derived from them.
+	got = autotune(Tuning{MaxBackground: 100, Workers: 10}, 4)
+	want := Tuning{
+		Readers:             1,
+		Workers:             10,
+		Buffers:             1 + 10,
+		MaxBackground:       100,
+		CongestionThreshold: 75,
+	}
+
+	if got != want {
+		t.Errorf("overrides: got %+v, want %+v", got, want)
+	}
+
+	// With Auto, a single CPU gets a little more than the kernel's defaults.
+	got = autotune(Tuning{Auto: true}, 1)
+	want = Tuning{
+		Auto:                true,
+		Readers:             1,
+		Workers:             16 + 64,
+		Buffers:             1 + 16 + 64,
+		MaxBackground:       16,
+		CongestionThreshold: 12,
+	}
+
+	if got != want {
+		t.Errorf("1 CPU: got %+v, want %+v", got, want)
+	}
+
+	// Large machines get more readers and background requests, within limits.
+	got = autotune(Tuning{Auto: true}, 256)
+	if got.Readers != 8 || got.MaxBackground != 1024 || got.CongestionThreshold != 768 {
+		t.Errorf("256 CPUs: got %+v", got)
+	}
+
+	got = autotune(Tuning{Auto: true, MaxBackground: 100, Readers: 3}, 4)
+	want = Tuning{
+		Auto:                true,
+		Readers:             3,
+		Workers:             100 + 64*4,
+		Buffers:             3 + 100 + 64*4,
+		MaxBackground:       100,
+		CongestionThreshold: 75,
+	}
+
+	if got != want {
+		t.Errorf("auto overrides: got %+v, want %+v", got, want)
+	}
+}
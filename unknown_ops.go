@@ -0,0 +1,117 @@
+// Copyright 2026 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"fmt"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/internal/buffer"
+)
+
+// UnknownOpPolicy controls how a connection handles requests with opcodes that
+// this package doesn't know, e.g. because they were added in a newer kernel.
+// Such requests are replied to with ENOSYS, which the kernel generally takes
+// to mean the feature is unsupported. The zero value does so silently, apart
+// from counting them; see Connection.UnknownOps.
+type UnknownOpPolicy struct {
+	// Log the first request with each unknown opcode to the error logger.
+	LogOnce bool
+
+	// If non-nil, called with every request with an unknown opcode, before it
+	// is replied to. Must not block.
+	OnUnknownOp func(UnknownOpEvent)
+
+	// Treat an unknown opcode as fatal: after replying to the request,
+	// Connection.ReadOp returns an *UnknownOpError, which normally ends the
+	// server's loop.
+	Strict bool
+}
+
+// UnknownOpEvent describes a request with an unknown opcode. See
+// UnknownOpPolicy.OnUnknownOp.
+type UnknownOpEvent struct {
+	// The request's opcode, and the inode it is for, if any.
+	OpCode uint32
+	Inode  fuseops.InodeID
+
+	// The raw payload following the request's header. It is valid only for the
+	// duration of the call, and must not be modified.
+	Payload []byte
+}
+
+// UnknownOpError is returned by Connection.ReadOp for a request with an
+// unknown opcode when UnknownOpPolicy.Strict is set.
+type UnknownOpError struct {
+	OpCode uint32
+}
+
+func (e *UnknownOpError) Error() string {
+	return fmt.Sprintf("unknown opcode %d", e.OpCode)
+}
+
+// UnknownOps returns the number of requests received with each unknown opcode.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) UnknownOps() map[uint32]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[uint32]uint64, len(c.unknownOps))
+	for opCode, n := range c.unknownOps {
+		counts[opCode] = n
+	}
+
+	return counts
+}
+
+// Apply the UnknownOpPolicy to the supplied op, read from the supplied
+// message, returning an error if the connection should fail.
+//
+// LOCKS_EXCLUDED(c.mu)
+func (c *Connection) handleUnknownOp(
+	op *unknownOp,
+	inMsg *buffer.InMessage) error {
+	p := &c.cfg.UnknownOpPolicy
+
+	c.mu.Lock()
+	if c.unknownOps == nil {
+		c.unknownOps = make(map[uint32]uint64)
+	}
+
+	c.unknownOps[op.OpCode]++
+	first := c.unknownOps[op.OpCode] == 1
+	c.mu.Unlock()
+
+	if p.LogOnce && first && c.errorLogger != nil {
+		c.errorLogger.Printf(
+			"Received unknown opcode %d, replying ENOSYS; further requests with it won't be logged",
+			op.OpCode)
+	}
+
+	if p.OnUnknownOp != nil {
+		p.OnUnknownOp(UnknownOpEvent{
+			OpCode:  op.OpCode,
+			Inode:   op.Inode,
+			Payload: inMsg.ConsumeBytes(inMsg.Len()),
+		})
+	}
+
+	if p.Strict {
+		return &UnknownOpError{OpCode: op.OpCode}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package fuse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnknownOpPolicy(t *testing.T) {
+	const opCode = 9999
+	c, peer := newSocketConnection(t, false)
+
+	var events []UnknownOpEvent
+	c.cfg.UnknownOpPolicy.OnUnknownOp = func(e UnknownOpEvent) {
+		e.Payload = append([]byte(nil), e.Payload...)
+		events = append(events, e)
+	}
+
+	// By default, the op is returned to be replied to with ENOSYS.
+	if _, err := peer.Write(request(1, opCode, []byte("taco"))); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ctx, op, err := c.ReadOp()
+	if err != nil {
+		t.Fatalf("ReadOp: %v", err)
+	}
+
+	if _, ok := op.(*unknownOp); !ok {
+		t.Fatalf("unexpected op: %#v", op)
+	}
+
+	c.Reply(ctx, ENOSYS)
+	readReply(t, peer, 1)
+
+	if len(events) != 1 || events[0].OpCode != opCode || string(events[0].Payload) != "taco" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// In strict mode, the connection fails after replying.
+	c.cfg.UnknownOpPolicy.Strict = true
+	if _, err := peer.Write(request(2, opCode, nil)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var unknownErr *UnknownOpError
+	if _, _, err := c.ReadOp(); !errors.As(err, &unknownErr) || unknownErr.OpCode != opCode {
+		t.Errorf("ReadOp: got %v, want UnknownOpError", err)
+	}
+
+	readReply(t, peer, 2)
+
+	if counts := c.UnknownOps(); len(counts) != 1 || counts[opCode] != 2 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
@@ -25,3 +25,13 @@ var ErrExternallyManagedMountPoint = errors.New("externally managed mount point,
 func Unmount(dir string) error {
 	return unmount(dir)
 }
+
+// Unmount is like the package-level Unmount function, but also times the
+// unmount as described on MountConfig.SlowMountThreshold, using the config
+// the file system was mounted with.
+func (mfs *MountedFileSystem) Unmount() error {
+	step := startMountStep(&mfs.conn.cfg, "Unmounting "+mfs.dir)
+	err := unmount(mfs.dir)
+	step.done(err)
+	return err
+}